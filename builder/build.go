@@ -48,6 +48,16 @@ type BuildResult struct {
 	// .hex for example (instead of the usual ELF).
 	Binary string
 
+	// A path to the C header file declaring all //export functions, for
+	// -buildmode=c-archive and -buildmode=c-shared. It is stored in the tmpdir
+	// directory, just like Binary.
+	Header string
+
+	// A path to the TypeScript declarations of all //go:wasmexport functions,
+	// for GOOS=js. It is stored in the tmpdir directory, just like Binary, and
+	// is empty if there are no such functions.
+	TypeScript string
+
 	// The directory of the main package. This is useful for testing as the test
 	// binary must be run in the directory of the tested package.
 	MainDir string
@@ -105,6 +115,22 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		fmt.Printf("WORK=%s\n", tmpdir)
 	}
 
+	if config.BuildMode() == "c-archive" {
+		if err := checkArchiveConfig(config); err != nil {
+			return BuildResult{}, err
+		}
+	}
+	if config.Scheduler() == "jspi" && config.GOOS() != "js" {
+		// JSPI is a JavaScript API, so it can't be used outside a browser or
+		// Node.js.
+		return BuildResult{}, fmt.Errorf("-scheduler=jspi is only supported with GOOS=js (for example, -target=wasm)")
+	}
+	if config.Scheduler() == "threads" {
+		if err := checkThreadsConfig(config); err != nil {
+			return BuildResult{}, err
+		}
+	}
+
 	// Look up the build cache directory, which is used to speed up incremental
 	// builds.
 	cacheDir := goenv.Get("GOCACHE")
@@ -163,8 +189,12 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		defer unlock()
 		libcDependencies = append(libcDependencies, libcJob)
 	case "wasi-libc":
-		path := filepath.Join(root, "lib/wasi-libc/sysroot/lib/wasm32-wasi/libc.a")
+		sysroot, libDir := config.WasiLibcSysroot()
+		path := filepath.Join(sysroot, "lib", libDir, "libc.a")
 		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if config.Scheduler() == "threads" {
+				return BuildResult{}, errors.New("could not find wasi-libc with threads support, perhaps you need to run `make wasi-libc-threads`?")
+			}
 			return BuildResult{}, errors.New("could not find wasi-libc, perhaps you need to run `make wasi-libc`?")
 		}
 		libcDependencies = append(libcDependencies, dummyCompileJob(path))
@@ -208,6 +238,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		DefaultStackSize:   config.StackSize(),
 		MaxStackAlloc:      config.MaxStackAlloc(),
 		NeedsStackObjects:  config.NeedsStackObjects(),
+		FramePointers:      config.FramePointers(),
 		Debug:              !config.Options.SkipDWARF, // emit DWARF except when -internal-nodwarf is passed
 		PanicStrategy:      config.PanicStrategy(),
 	}
@@ -242,6 +273,36 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		return result, err
 	}
 
+	// Write a C header for all exported functions, so that C code can call
+	// them.
+	if config.BuildMode() == "c-archive" || config.BuildMode() == "c-shared" {
+		result.Header = filepath.Join(tmpdir, "main.h")
+		name := outpath
+		if name == "" {
+			name = result.MainDir
+		}
+		header, err := compiler.ExportHeader(lprogram.Sorted(), compiler.Sizes(machine), config.BuildMode(), name)
+		if err != nil {
+			return result, err
+		}
+		err = os.WriteFile(result.Header, header, 0666)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Write TypeScript declarations for all //go:wasmexport functions, so that
+	// they can be called from TypeScript through wasm_exec.js.
+	if config.GOOS() == "js" {
+		if declarations := compiler.ExportTypeScript(lprogram.Sorted(), compiler.Sizes(machine)); declarations != nil {
+			result.TypeScript = filepath.Join(tmpdir, "main.d.ts")
+			err := os.WriteFile(result.TypeScript, declarations, 0666)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
 	// Create the *ssa.Program. This does not yet build the entire SSA of the
 	// program so it's pretty fast and doesn't need to be parallelized.
 	program := lprogram.LoadSSA()
@@ -628,6 +689,15 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	// First add all jobs necessary to build this object file, then afterwards
 	// run all jobs in parallel as far as possible.
 
+	// Static libraries are linked by the toolchain of the C firmware, which
+	// generally can't read LLVM bitcode. Therefore, all object files in the
+	// archive must be regular object files.
+	isArchive := config.BuildMode() == "c-archive"
+	cflags := config.CFlags(false)
+	if isArchive {
+		cflags = append(cflags, "-fno-lto")
+	}
+
 	// Add job to write the output object file.
 	objfile := filepath.Join(tmpdir, "main.o")
 	outputObjectFileJob := &compileJob{
@@ -635,6 +705,14 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		dependencies: []*compileJob{programJob},
 		result:       objfile,
 		run: func(*compileJob) error {
+			if isArchive {
+				llvmBuf, err := machine.EmitToMemoryBuffer(mod, llvm.ObjectFile)
+				if err != nil {
+					return err
+				}
+				defer llvmBuf.Dispose()
+				return os.WriteFile(objfile, llvmBuf.Bytes(), 0666)
+			}
 			llvmBuf := llvm.WriteThinLTOBitcodeToMemoryBuffer(mod)
 			defer llvmBuf.Dispose()
 			return os.WriteFile(objfile, llvmBuf.Bytes(), 0666)
@@ -651,15 +729,16 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	ldflags := append(config.LDFlags(), "-o", result.Executable)
 
 	if config.Options.BuildMode == "c-shared" {
-		if !strings.HasPrefix(config.Triple(), "wasm32-") {
+		if !strings.HasPrefix(config.Triple(), "wasm") {
 			return result, fmt.Errorf("buildmode c-shared is only supported on wasm at the moment")
 		}
 		ldflags = append(ldflags, "--no-entry")
 	}
 
 	// Add compiler-rt dependency if needed. Usually this is a simple load from
-	// a cache.
-	if config.Target.RTLib == "compiler-rt" {
+	// a cache. Static libraries use the compiler runtime library of the C
+	// firmware instead.
+	if config.Target.RTLib == "compiler-rt" && !isArchive {
 		job, unlock, err := libCompilerRT.load(config, tmpdir)
 		if err != nil {
 			return result, err
@@ -676,7 +755,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		job := &compileJob{
 			description: "compile extra file " + path,
 			run: func(job *compileJob) error {
-				result, err := compileAndCacheCFile(abspath, tmpdir, config.CFlags(false), config.Options.PrintCommands)
+				result, err := compileAndCacheCFile(abspath, tmpdir, cflags, config.Options.PrintCommands)
 				job.result = result
 				return err
 			},
@@ -689,12 +768,16 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	// bitcode files together.
 	for _, pkg := range lprogram.Sorted() {
 		pkg := pkg
+		pkgCFlags := pkg.CFlags
+		if isArchive {
+			pkgCFlags = append(pkgCFlags[:len(pkgCFlags):len(pkgCFlags)], "-fno-lto")
+		}
 		for _, filename := range pkg.CFiles {
 			abspath := filepath.Join(pkg.Dir, filename)
 			job := &compileJob{
 				description: "compile CGo file " + abspath,
 				run: func(job *compileJob) error {
-					result, err := compileAndCacheCFile(abspath, tmpdir, pkg.CFlags, config.Options.PrintCommands)
+					result, err := compileAndCacheCFile(abspath, tmpdir, pkgCFlags, config.Options.PrintCommands)
 					job.result = result
 					return err
 				},
@@ -709,12 +792,37 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 		ldflags = append(ldflags, lprogram.LDFlags...)
 	}
 
-	// Add libc dependencies, if they exist.
-	linkerDependencies = append(linkerDependencies, libcDependencies...)
-
 	// Add embedded files.
 	linkerDependencies = append(linkerDependencies, embedFileObjects...)
 
+	// Static libraries are not linked: all object files are simply stored in
+	// the archive. The C firmware provides libc.
+	if isArchive {
+		result.Binary = filepath.Join(tmpdir, "main.a")
+		archiveJob := &compileJob{
+			description:  "create archive",
+			dependencies: linkerDependencies,
+			result:       result.Binary,
+			run: func(job *compileJob) error {
+				var objs []string
+				for _, dependency := range job.dependencies {
+					objs = append(objs, dependency.result)
+				}
+				arfile, err := os.Create(result.Binary)
+				if err != nil {
+					return err
+				}
+				defer arfile.Close()
+				return makeArchive(arfile, objs)
+			},
+		}
+		err := runJobs(archiveJob, config.Options.Semaphore)
+		return result, err
+	}
+
+	// Add libc dependencies, if they exist.
+	linkerDependencies = append(linkerDependencies, libcDependencies...)
+
 	// Determine whether the compilation configuration would result in debug
 	// (DWARF) information in the object files.
 	var hasDebug = true
@@ -821,7 +929,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 			}
 
 			// Run wasm-opt for wasm binaries
-			if arch := strings.Split(config.Triple(), "-")[0]; arch == "wasm32" {
+			if arch := strings.Split(config.Triple(), "-")[0]; arch == "wasm32" || arch == "wasm64" {
 				optLevel, _, _ := config.OptLevel()
 				opt := "-" + optLevel
 
@@ -854,7 +962,7 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				}
 			}
 
-			// Run wasm-tools for component-model binaries
+			// Wrap the module in a component for component-model binaries.
 			witPackage := strings.ReplaceAll(config.Target.WITPackage, "{root}", goenv.Get("TINYGOROOT"))
 			if config.Options.WITPackage != "" {
 				witPackage = config.Options.WITPackage
@@ -864,53 +972,11 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 				witWorld = config.Options.WITWorld
 			}
 			if witPackage != "" && witWorld != "" {
-
-				// wasm-tools component embed -w wasi:cli/command
-				// 		$$(tinygo env TINYGOROOT)/lib/wasi-cli/wit/ main.wasm -o embedded.wasm
-				componentEmbedInputFile := result.Binary
-				result.Binary = result.Executable + ".wasm-component-embed"
-				args := []string{
-					"component",
-					"embed",
-					"-w", witWorld,
-					witPackage,
-					componentEmbedInputFile,
-					"-o", result.Binary,
-				}
-
-				wasmtools := goenv.Get("WASMTOOLS")
-				if config.Options.PrintCommands != nil {
-					config.Options.PrintCommands(wasmtools, args...)
-				}
-				cmd := exec.Command(wasmtools, args...)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-
-				err := cmd.Run()
-				if err != nil {
-					return fmt.Errorf("`wasm-tools component embed` failed: %w", err)
-				}
-
-				// wasm-tools component new embedded.wasm -o component.wasm
-				componentNewInputFile := result.Binary
-				result.Binary = result.Executable + ".wasm-component-new"
-				args = []string{
-					"component",
-					"new",
-					componentNewInputFile,
-					"-o", result.Binary,
-				}
-
-				if config.Options.PrintCommands != nil {
-					config.Options.PrintCommands(wasmtools, args...)
-				}
-				cmd = exec.Command(wasmtools, args...)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-
-				err = cmd.Run()
+				inputFile := result.Binary
+				result.Binary = result.Executable + ".wasm-component"
+				err := makeComponent(inputFile, result.Binary, witPackage, witWorld, lprogram, program.Fset)
 				if err != nil {
-					return fmt.Errorf("`wasm-tools component new` failed: %w", err)
+					return err
 				}
 			}
 
@@ -1001,6 +1067,62 @@ func Build(pkgName, outpath, tmpdir string, config *compileopts.Config) (BuildRe
 	return result, nil
 }
 
+// checkArchiveConfig checks whether the configuration can be used to build a
+// static library for linking into C firmware (-buildmode=c-archive).
+func checkArchiveConfig(config *compileopts.Config) error {
+	hasCortexM := false
+	for _, tag := range config.BuildTags() {
+		if tag == "cortexm" {
+			hasCortexM = true
+		}
+	}
+	if !hasCortexM {
+		return errors.New("buildmode c-archive is only supported on Cortex-M at the moment")
+	}
+	if config.Target.LinkerScript != "" {
+		// Chip and board targets initialize the chip themselves, which is
+		// the job of the C firmware.
+		return errors.New("buildmode c-archive requires a generic CPU target like cortex-m4, not a chip or board target")
+	}
+	switch config.Scheduler() {
+	case "none", "tasks":
+	default:
+		return fmt.Errorf("buildmode c-archive does not support -scheduler=%s, use -scheduler=tasks or -scheduler=none instead", config.Scheduler())
+	}
+	switch config.GC() {
+	case "precise", "leaking", "none":
+	default:
+		return fmt.Errorf("buildmode c-archive does not support -gc=%s, use -gc=precise instead", config.GC())
+	}
+	return nil
+}
+
+// checkThreadsConfig checks whether the configuration can be used with
+// -scheduler=threads, where every goroutine runs in its own thread.
+func checkThreadsConfig(config *compileopts.Config) error {
+	if config.Target.Libc != "wasi-libc" || !strings.HasPrefix(config.Triple(), "wasm32-") {
+		return errors.New("-scheduler=threads is only supported on 32-bit WebAssembly with WASI (for example, -target=wasip1-threads)")
+	}
+	hasAtomics := false
+	for _, feature := range strings.Split(config.Features(), ",") {
+		if feature == "+atomics" {
+			hasAtomics = true
+		}
+	}
+	if !hasAtomics {
+		return errors.New("-scheduler=threads requires the atomics feature, use -target=wasip1-threads instead")
+	}
+	if config.BuildMode() == "c-shared" {
+		return errors.New("buildmode c-shared is not supported with -scheduler=threads")
+	}
+	switch config.GC() {
+	case "precise", "conservative":
+	default:
+		return fmt.Errorf("-scheduler=threads does not support -gc=%s, use -gc=precise or -gc=conservative instead", config.GC())
+	}
+	return nil
+}
+
 // createEmbedObjectFile creates a new object file with the given contents, for
 // the embed package.
 func createEmbedObjectFile(data, hexSum, sourceFile, sourceDir, tmpdir string, compilerConfig *compiler.Config) (string, error) {
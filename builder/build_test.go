@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tinygo-org/tinygo/compileopts"
+)
+
+// Test -buildmode=c-archive by linking the archive into a stub RTOS
+// (testdata/rtos.c) that runs goroutines using tinygo_tick. The result is run in
+// QEMU, if it is installed.
+func TestCArchive(t *testing.T) {
+	t.Parallel()
+
+	options := compileopts.Options{
+		Target:        "cortex-m3",
+		BuildMode:     "c-archive",
+		Scheduler:     "tasks",
+		Opt:           "z",
+		Semaphore:     sema,
+		InterpTimeout: 60 * time.Second,
+		Debug:         true,
+		VerifyIR:      true,
+	}
+	spec, err := compileopts.LoadTarget(&options)
+	if err != nil {
+		t.Fatal("could not load target:", err)
+	}
+	config := &compileopts.Config{
+		Options: &options,
+		Target:  spec,
+	}
+	tmpdir := t.TempDir()
+	result, err := Build("testdata/carchive.go", "", tmpdir, config)
+	if err != nil {
+		t.Fatal("could not build:", err)
+	}
+
+	// The firmware links against its own compiler runtime library.
+	job, unlock, err := libCompilerRT.load(config, tmpdir)
+	if err != nil {
+		t.Fatal("could not load compiler-rt:", err)
+	}
+	defer unlock()
+	err = runJobs(job, sema)
+	if err != nil {
+		t.Fatal("could not build compiler-rt:", err)
+	}
+
+	// Build the firmware.
+	rtosObj := filepath.Join(tmpdir, "rtos.o")
+	cflags := append(config.CFlags(false), "-I", filepath.Dir(result.Header), "-c", "-o", rtosObj, "testdata/rtos.c")
+	err = runCCompiler(cflags...)
+	if err != nil {
+		t.Fatal("could not compile rtos.c:", err)
+	}
+	executable := filepath.Join(tmpdir, "rtos.elf")
+	err = link("ld.lld", "-T", "testdata/rtos.ld", "--gc-sections", "-o", executable, rtosObj, result.Binary, job.result)
+	if err != nil {
+		t.Fatal("could not link:", err)
+	}
+
+	if _, err := exec.LookPath("qemu-system-arm"); err != nil {
+		t.Log("qemu-system-arm not found, not running the firmware")
+		return
+	}
+	cmd := exec.Command("qemu-system-arm", "-machine", "lm3s6965evb", "-semihosting", "-nographic", "-kernel", executable)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not run firmware: %v\n%s", err, output)
+	}
+	expected, err := os.ReadFile("testdata/carchive.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output = bytes.ReplaceAll(output, []byte("\r\n"), []byte("\n"))
+	if !bytes.Equal(output, expected) {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+}
@@ -37,6 +37,7 @@ func TestClangAttributes(t *testing.T) {
 		"wasip2",
 		"wasm",
 		"wasm-unknown",
+		"wasm64",
 	}
 	if hasBuiltinTools {
 		// hasBuiltinTools is set when TinyGo is statically linked with LLVM,
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"errors"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"os"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/component"
+	"github.com/tinygo-org/tinygo/loader"
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// makeComponent wraps the core module at inputFile in a WebAssembly component
+// for the given WIT world, and writes it to outputFile. Imports and exports
+// that do not match the world are reported at the //go:wasmimport or
+// //go:wasmexport pragma that declares them.
+func makeComponent(inputFile, outputFile, witPackage, witWorld string, lprogram *loader.Program, fset *token.FileSet) error {
+	res, err := wit.Load(witPackage)
+	if err != nil {
+		return err
+	}
+	world, err := res.World(witWorld)
+	if err != nil {
+		return err
+	}
+	module, err := os.ReadFile(inputFile)
+	if err != nil {
+		return err
+	}
+	data, err := component.Encode(module, res, world)
+	var componentErrs component.Errors
+	if errors.As(err, &componentErrs) {
+		positions := wasmPragmaPositions(lprogram, fset)
+		var errs []error
+		for _, err := range componentErrs {
+			key := "export " + err.Name
+			if err.Module != "" {
+				key = "import " + err.Module + " " + err.Name
+			}
+			errs = append(errs, scanner.Error{
+				Pos: positions[key],
+				Msg: err.Error(),
+			})
+		}
+		return newMultiError(errs, "")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, data, 0666)
+}
+
+// wasmPragmaPositions returns the positions of all //go:wasmimport,
+// //go:wasmexport and //export pragmas in the program, by "import module name"
+// or "export name".
+func wasmPragmaPositions(lprogram *loader.Program, fset *token.FileSet) map[string]token.Position {
+	positions := make(map[string]token.Position)
+	for _, pkg := range lprogram.Sorted() {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				decl, ok := decl.(*ast.FuncDecl)
+				if !ok || decl.Doc == nil {
+					continue
+				}
+				for _, comment := range decl.Doc.List {
+					parts := strings.Fields(comment.Text)
+					var key string
+					switch {
+					case len(parts) == 3 && parts[0] == "//go:wasmimport":
+						key = "import " + parts[1] + " " + parts[2]
+					case len(parts) == 2 && (parts[0] == "//go:wasmexport" || parts[0] == "//export"):
+						key = "export " + parts[1]
+					default:
+						continue
+					}
+					if _, ok := positions[key]; !ok {
+						positions[key] = fset.Position(comment.Slash)
+					}
+				}
+			}
+		}
+	}
+	return positions
+}
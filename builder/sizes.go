@@ -1,7 +1,6 @@
 package builder
 
 import (
-	"bytes"
 	"debug/dwarf"
 	"debug/elf"
 	"debug/macho"
@@ -301,6 +300,45 @@ func readULEB128(buf []byte) (result uint64, n int) {
 	return
 }
 
+// Source: https://en.wikipedia.org/wiki/LEB128#Decode_signed_integer
+func readSLEB128(buf []byte) (result int64, n int) {
+	var shift uint8
+	for {
+		b := buf[n]
+		n++
+		result |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			break
+		}
+	}
+	return
+}
+
+// Parse the constant expression of a WebAssembly data segment offset. This is
+// an i32.const instruction in 32-bit memory and an i64.const instruction in
+// 64-bit memory (memory64), followed by an end instruction.
+func readWasmConstExpr(expr []byte) (uint64, error) {
+	if len(expr) < 3 || expr[len(expr)-1] != 0x0b { // end
+		return 0, fmt.Errorf("unexpected constant expression: %x", expr)
+	}
+	value, n := readSLEB128(expr[1:])
+	if 1+n != len(expr)-1 {
+		return 0, fmt.Errorf("unexpected constant expression: %x", expr)
+	}
+	switch expr[0] {
+	case 0x41: // i32.const
+		return uint64(uint32(value)), nil
+	case 0x42: // i64.const
+		return uint64(value), nil
+	default:
+		return 0, fmt.Errorf("unknown opcode in constant expression: 0x%02x", expr[0])
+	}
+}
+
 // Read a MachO object file and return a line table.
 // Also return an index from symbol name to start address in the line table.
 func readMachOSymbolAddresses(path string) (map[string]int, []addressLine, error) {
@@ -712,12 +750,12 @@ func loadProgramSize(path string, packagePathMap map[string]string) (*programSiz
 				// address for easier processing.
 				var dataSections []memorySection
 				for _, entry := range section.Entries {
-					address, err := wasm.Eval(bytes.NewBuffer(entry.Offset))
+					address, err := readWasmConstExpr(entry.Offset)
 					if err != nil {
 						return nil, fmt.Errorf("could not parse data section address: %w", err)
 					}
 					dataSections = append(dataSections, memorySection{
-						Address: uint64(address[0].(int32)),
+						Address: address,
 						Size:    uint64(len(entry.Data)),
 						Type:    memoryData,
 					})
@@ -90,3 +90,99 @@ func TestBinarySize(t *testing.T) {
 		})
 	}
 }
+
+// Test the data segment offsets of 32-bit and 64-bit WebAssembly.
+func TestReadWasmConstExpr(t *testing.T) {
+	tests := []struct {
+		expr    []byte
+		address uint64
+	}{
+		{[]byte{0x41, 0x80, 0x08, 0x0b}, 1024},                         // i32.const 1024
+		{[]byte{0x41, 0x80, 0x80, 0x80, 0x80, 0x78, 0x0b}, 0x80000000}, // i32.const 0x80000000 (negative)
+		{[]byte{0x42, 0x80, 0x08, 0x0b}, 1024},                         // i64.const 1024
+		{[]byte{0x42, 0x80, 0x80, 0x80, 0x80, 0x10, 0x0b}, 1 << 32},    // i64.const 0x100000000
+	}
+	for _, tc := range tests {
+		address, err := readWasmConstExpr(tc.expr)
+		if err != nil {
+			t.Errorf("could not read %x: %v", tc.expr, err)
+		} else if address != tc.address {
+			t.Errorf("expected address %#x for %x, got %#x", tc.address, tc.expr, address)
+		}
+	}
+	if _, err := readWasmConstExpr([]byte{0x23, 0x00, 0x0b}); err == nil { // global.get 0
+		t.Error("expected an error for an unsupported constant expression")
+	}
+}
+
+// Test that data in a wasm64 memory beyond 4GiB is attributed to the right
+// packages, with no truncation of addresses to 32 bits.
+func TestReadSectionAbove4GiB(t *testing.T) {
+	section := memorySection{Type: memoryData, Address: 1<<32 - 16, Size: 56, Align: 8}
+	addresses := []addressLine{
+		{Address: 1<<32 - 16, Length: 16, Align: 8, File: "/src/a/a.go", IsVariable: true},
+		{Address: 1 << 32, Length: 20, Align: 8, File: "/src/b/b.go", IsVariable: true},
+		{Address: 1<<32 + 24, Length: 16, Align: 8, File: "/src/a/a.go", IsVariable: true},
+		{Address: 16, Length: 16, Align: 8, File: "/src/b/b.go", IsVariable: true}, // below the section
+	}
+	packagePathMap := map[string]string{
+		"/src/a": "a",
+		"/src/b": "b",
+	}
+	sizes := make(map[string]uint64)
+	readSection(section, addresses, func(name string, size uint64, isVariable bool) {
+		sizes[name] += size
+	}, packagePathMap)
+	expected := map[string]uint64{"a": 32, "b": 20, "(padding)": 4}
+	if len(sizes) != len(expected) {
+		t.Errorf("expected sizes %v, got %v", expected, sizes)
+	}
+	for name, size := range expected {
+		if sizes[name] != size {
+			t.Errorf("expected %d bytes for %s, got %d", size, name, sizes[name])
+		}
+	}
+}
+
+// Test that -size works for 32-bit and 64-bit WebAssembly. The exact sizes
+// depend on the binaryen version (see TestBinarySize), so only check that the
+// linear memory is accounted for.
+func TestWasmSize(t *testing.T) {
+	for _, target := range []string{"wasm-unknown", "wasm64"} {
+		target := target
+		t.Run(target, func(t *testing.T) {
+			t.Parallel()
+
+			options := compileopts.Options{
+				Target:        target,
+				Opt:           "z",
+				Semaphore:     sema,
+				InterpTimeout: 60 * time.Second,
+				Debug:         true,
+				VerifyIR:      true,
+			}
+			spec, err := compileopts.LoadTarget(&options)
+			if err != nil {
+				t.Fatal("could not load target:", err)
+			}
+			config := &compileopts.Config{
+				Options: &options,
+				Target:  spec,
+			}
+			result, err := Build("examples/hello-wasm-unknown", "", t.TempDir(), config)
+			if err != nil {
+				t.Fatal("could not build:", err)
+			}
+			sizes, err := loadProgramSize(result.Executable, nil)
+			if err != nil {
+				t.Fatal("could not read program size:", err)
+			}
+			if sizes.Code == 0 {
+				t.Error("no code found")
+			}
+			if sizes.Data+sizes.BSS == 0 {
+				t.Error("no data or bss found in linear memory")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+// Library for TestCArchive. The goroutines started by start are run from the
+// RTOS thread in testdata/rtos.c using tinygo_tick.
+
+import (
+	"runtime"
+	"time"
+)
+
+var done = make(chan int)
+
+//export start
+func start() {
+	go worker(1, 3)
+	go worker(2, 5)
+	go collect()
+}
+
+func worker(id, delay int) {
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		println("worker", id, "step", i)
+	}
+	done <- id
+}
+
+func collect() {
+	sum := 0
+	for i := 0; i < 2; i++ {
+		sum += <-done
+	}
+
+	// Allocate some garbage, and make sure live objects survive a GC cycle.
+	var list [][]byte
+	for i := 0; i < 100; i++ {
+		buf := make([]byte, 100)
+		buf[0] = byte(i)
+		if i%10 == 0 {
+			list = append(list, buf)
+		}
+	}
+	runtime.GC()
+	for i, buf := range list {
+		sum += int(buf[0]) - i*10
+	}
+	println("done:", sum)
+}
+
+func main() {
+}
@@ -0,0 +1,117 @@
+// A tiny stand-in for an RTOS for TestCArchive, running on the lm3s6965evb
+// machine in QEMU. Like an RTOS thread, it runs on the process stack (PSP) and
+// runs Go goroutines by calling tinygo_tick. Time is virtual: sleeping advances
+// the clock immediately, so that the output doesn't depend on the host.
+
+#include <stdint.h>
+#include <stddef.h>
+#include "main.h"
+
+extern uint32_t _sidata, _sdata, _edata, _sbss, _ebss, _estack;
+
+#define UART0_DR (*(volatile uint32_t *)0x4000c000)
+
+// Semihosting exit reasons.
+#define ADP_Stopped_RunTimeErrorUnknown 0x20023
+#define ADP_Stopped_ApplicationExit 0x20026
+
+static uint64_t now;
+static uint8_t heap[16 * 1024] __attribute__((aligned(8)));
+static uint32_t threadStack[1024] __attribute__((aligned(8)));
+
+static void semihostingExit(uint32_t reason) {
+    register uint32_t r0 __asm__("r0") = 0x18; // SYS_EXIT
+    register uint32_t r1 __asm__("r1") = reason;
+    __asm__ __volatile__("bkpt 0xab" : : "r"(r0), "r"(r1) : "memory");
+    for (;;) {
+    }
+}
+
+uint64_t tinygo_host_nanotime(void) {
+    return now;
+}
+
+void tinygo_host_sleep(uint64_t ns) {
+    now += ns;
+}
+
+void tinygo_host_putchar(char c) {
+    UART0_DR = c;
+}
+
+void tinygo_host_abort(void) {
+    semihostingExit(ADP_Stopped_RunTimeErrorUnknown);
+}
+
+// The firmware provides libc.
+void *memcpy(void *dst, const void *src, size_t n) {
+    uint8_t *d = dst;
+    const uint8_t *s = src;
+    while (n--) {
+        *d++ = *s++;
+    }
+    return dst;
+}
+
+void *memmove(void *dst, const void *src, size_t n) {
+    uint8_t *d = dst;
+    const uint8_t *s = src;
+    if (d < s) {
+        while (n--) {
+            *d++ = *s++;
+        }
+    } else {
+        while (n--) {
+            d[n] = s[n];
+        }
+    }
+    return dst;
+}
+
+void *memset(void *dst, int c, size_t n) {
+    uint8_t *d = dst;
+    while (n--) {
+        *d++ = c;
+    }
+    return dst;
+}
+
+void thread(void) {
+    tinygo_init(heap, sizeof(heap));
+    start();
+    for (;;) {
+        int64_t next = tinygo_tick();
+        if (next < 0) {
+            // No goroutine is sleeping anymore.
+            break;
+        }
+        // Sleep until the next goroutine must run.
+        now += next;
+    }
+    semihostingExit(ADP_Stopped_ApplicationExit);
+}
+
+void Reset_Handler(void) {
+    uint32_t *src = &_sidata;
+    for (uint32_t *dst = &_sdata; dst < &_edata; dst++) {
+        *dst = *src++;
+    }
+    for (uint32_t *dst = &_sbss; dst < &_ebss; dst++) {
+        *dst = 0;
+    }
+
+    // Continue on the process stack, like an RTOS thread.
+    __asm__ __volatile__(
+        "msr psp, %0\n"
+        "movs r0, #2\n"
+        "msr control, r0\n"
+        "isb\n"
+        "b thread\n"
+        : : "r"(&threadStack[1024]) : "r0");
+}
+
+__attribute__((section(".isr_vector"), used))
+static const void *vectors[] = {
+    &_estack,
+    Reset_Handler,
+};
@@ -99,6 +99,9 @@ func (c *Config) BuildTags() []string {
 		"math_big_pure_go",                           // to get math/big to work
 		"gc." + c.GC(), "scheduler." + c.Scheduler(), // used inside the runtime package
 		"serial." + c.Serial()}...) // used inside the machine package
+	if c.BuildMode() == "c-archive" {
+		tags = append(tags, "tinygo.carchive") // used inside the runtime package
+	}
 	for i := 1; i <= c.GoMinorVersion; i++ {
 		tags = append(tags, fmt.Sprintf("go1.%d", i))
 	}
@@ -112,6 +115,11 @@ func (c *Config) GC() string {
 	if c.Options.GC != "" {
 		return c.Options.GC
 	}
+	if c.BuildMode() == "c-archive" {
+		// The location of the stack and the globals isn't known when linking
+		// into C firmware, which the precise GC doesn't need.
+		return "precise"
+	}
 	if c.Target.GC != "" {
 		return c.Target.GC
 	}
@@ -129,18 +137,58 @@ func (c *Config) NeedsStackObjects() bool {
 			}
 		}
 
-		return false
+		return c.PreciseStackMaps()
 	default:
 		return false
 	}
 }
 
+// FramePointers returns true if all functions should keep a frame pointer. This
+// is needed when the runtime records the callers of allocation sites (see
+// -tags=gc.allocsites), because llvm.returnaddress can only walk up more than
+// one frame with frame pointers.
+func (c *Config) FramePointers() bool {
+	for _, tag := range c.Options.Tags {
+		if tag == "gc.allocsites" {
+			return true
+		}
+	}
+	return false
+}
+
+// PreciseStackMaps returns true if the stack objects inserted by the compiler
+// describe the exact location of all pointers on the stack, so that the stack
+// does not need to be scanned conservatively. This is used by the precise GC
+// on baremetal systems. Globals are still scanned conservatively.
+func (c *Config) PreciseStackMaps() bool {
+	if c.GC() != "precise" {
+		return false
+	}
+	hasBaremetal := false
+	for _, tag := range c.BuildTags() {
+		switch tag {
+		case "tinygo.wasm":
+			// WebAssembly uses stack objects only to make pointers in
+			// registers visible; the C stack is scanned conservatively.
+			return false
+		case "baremetal":
+			hasBaremetal = true
+		}
+	}
+	return hasBaremetal
+}
+
 // Scheduler returns the scheduler implementation. Valid values are "none",
-// "asyncify" and "tasks".
+// "asyncify", "jspi", "tasks" and "threads".
 func (c *Config) Scheduler() string {
 	if c.Options.Scheduler != "" {
 		return c.Options.Scheduler
 	}
+	if c.BuildMode() == "c-archive" {
+		// Goroutines must be run by the firmware using tinygo_tick, so they
+		// are opt-in using -scheduler=tasks.
+		return "none"
+	}
 	if c.Target.Scheduler != "" {
 		return c.Target.Scheduler
 	}
@@ -192,6 +240,11 @@ func (c *Config) PanicStrategy() string {
 // automatically at compile time, if possible. If it is false, no attempt is
 // made.
 func (c *Config) AutomaticStackSize() bool {
+	if c.BuildMode() == "c-archive" {
+		// Stack sizes are patched in after linking, which is done by the
+		// firmware.
+		return false
+	}
 	if c.Target.AutoStackSize != nil && c.Scheduler() == "tasks" {
 		return *c.Target.AutoStackSize
 	}
@@ -278,8 +331,12 @@ func (c *Config) LibcPath(name string) (path string, precompiled bool) {
 // DefaultBinaryExtension returns the default extension for binaries, such as
 // .exe, .wasm, or no extension (depending on the target).
 func (c *Config) DefaultBinaryExtension() string {
+	if c.BuildMode() == "c-archive" {
+		// Static libraries are always .a files.
+		return ".a"
+	}
 	parts := strings.Split(c.Triple(), "-")
-	if parts[0] == "wasm32" {
+	if parts[0] == "wasm32" || parts[0] == "wasm64" {
 		// WebAssembly files always have the .wasm file extension.
 		return ".wasm"
 	}
@@ -343,10 +400,10 @@ func (c *Config) CFlags(libclang bool) []string {
 			"-isystem", filepath.Join(root, "lib", "musl", "include"),
 		)
 	case "wasi-libc":
-		root := goenv.Get("TINYGOROOT")
+		sysroot, _ := c.WasiLibcSysroot()
 		cflags = append(cflags,
 			"-nostdlibinc",
-			"-isystem", root+"/lib/wasi-libc/sysroot/include")
+			"-isystem", sysroot+"/include")
 	case "wasmbuiltins":
 		// nothing to add (library is purely for builtins)
 	case "mingw-w64":
@@ -389,6 +446,11 @@ func (c *Config) CFlags(libclang bool) []string {
 	if c.ABI() != "" {
 		cflags = append(cflags, "-mabi="+c.ABI())
 	}
+	if c.wasmExceptionHandling() {
+		// Needed to assemble the exception handling instructions in
+		// asm_tinygowasm_eh.S.
+		cflags = append(cflags, "-mexception-handling")
+	}
 	return cflags
 }
 
@@ -422,7 +484,46 @@ func (c *Config) LDFlags() []string {
 // ExtraFiles returns the list of extra files to be built and linked with the
 // executable. This can include extra C and assembly files.
 func (c *Config) ExtraFiles() []string {
-	return c.Target.ExtraFiles
+	files := c.Target.ExtraFiles
+	if c.Scheduler() == "jspi" {
+		files = append(files[:len(files):len(files)], "src/internal/task/task_jspi_wasm.S")
+	}
+	if c.Scheduler() == "threads" {
+		files = append(files[:len(files):len(files)], "src/internal/task/task_threads.c")
+		if strings.HasPrefix(c.Triple(), "wasm") {
+			files = append(files, "src/internal/task/task_threads_wasm.S")
+		}
+	}
+	if c.wasmExceptionHandling() {
+		files = append(files[:len(files):len(files)], "src/runtime/asm_tinygowasm_eh.S")
+	}
+	return files
+}
+
+// WasiLibcSysroot returns the wasi-libc sysroot, and the name of the directory
+// within it that contains libc.a. With -scheduler=threads, a separate build of
+// wasi-libc with pthread support is used.
+func (c *Config) WasiLibcSysroot() (sysroot, libDir string) {
+	root := goenv.Get("TINYGOROOT")
+	if c.Scheduler() == "threads" {
+		return root + "/lib/wasi-libc/sysroot-threads", "wasm32-wasi-threads"
+	}
+	return root + "/lib/wasi-libc/sysroot", "wasm32-wasi"
+}
+
+// wasmExceptionHandling returns whether the WebAssembly exception handling
+// proposal is enabled (using the "+exception-handling" feature). It is used to
+// implement recover().
+func (c *Config) wasmExceptionHandling() bool {
+	if !strings.HasPrefix(c.Triple(), "wasm") {
+		return false
+	}
+	for _, feature := range strings.Split(c.Features(), ",") {
+		if feature == "+exception-handling" {
+			return true
+		}
+	}
+	return false
 }
 
 // DumpSSA returns whether to dump Go SSA while compiling (-dumpssa flag). Only
@@ -56,6 +56,7 @@ type Config struct {
 	DefaultStackSize   uint64
 	MaxStackAlloc      uint64
 	NeedsStackObjects  bool
+	FramePointers      bool // keep frame pointers, for llvm.returnaddress
 	Debug              bool // Whether to emit debug information in the LLVM module.
 	PanicStrategy      string
 }
@@ -843,6 +844,12 @@ func (c *compilerContext) createPackage(irbuilder llvm.Builder, pkg *ssa.Package
 				// with a LLVM intrinsic.
 				continue
 			}
+			if member.Blocks == nil && b.info.jsImport != "" {
+				// Create the glue that calls the imported JavaScript
+				// function.
+				b.createJSImport()
+				continue
+			}
 			if member.Blocks == nil {
 				// Try to define this as an intrinsic function.
 				b.defineIntrinsicFunction()
@@ -27,11 +27,11 @@ import (
 // for the current architecture.
 func (b *builder) supportsRecover() bool {
 	switch b.archFamily() {
-	case "wasm32":
-		// Probably needs to be implemented using the exception handling
-		// proposal of WebAssembly:
+	case "wasm32", "wasm64":
+		// Implemented using the exception handling proposal of WebAssembly,
+		// if it is enabled:
 		// https://github.com/WebAssembly/exception-handling
-		return false
+		return b.hasWasmExceptionHandling()
 	case "riscv64", "xtensa":
 		// TODO: add support for these architectures
 		return false
@@ -228,6 +228,136 @@ li a0, 0
 	b.blockExits[b.currentBlock] = continueBB
 }
 
+// hasWasmExceptionHandling returns whether the WebAssembly exception handling
+// proposal can be used.
+func (b *builder) hasWasmExceptionHandling() bool {
+	for _, feature := range strings.Split(b.Features, ",") {
+		if feature == "+exception-handling" {
+			return true
+		}
+	}
+	return false
+}
+
+// createWasmInvoke is the WebAssembly equivalent of createInvokeCheckpoint
+// followed by the call. WebAssembly doesn't allow jumping back into a function
+// like setjmp does, so instead the call is made by runtime.wasmTry which
+// catches the exception thrown by a panic. The callee is called through a
+// wrapper function that receives all parameters (and stores the result) in a
+// struct on the stack.
+func (b *builder) createWasmInvoke(fnType llvm.Type, fn llvm.Value, args []llvm.Value, name string) llvm.Value {
+	var params []llvm.Value
+	for _, arg := range args {
+		params = append(params, b.expandFormalParam(arg)...)
+	}
+	isDirect := !fn.IsAFunction().IsNil()
+	resultType := fnType.ReturnType()
+	hasResult := resultType.TypeKind() != llvm.VoidTypeKind
+
+	// Put the parameters (and the function pointer, for indirect calls) in a
+	// struct on the stack.
+	var fieldTypes []llvm.Type
+	for _, param := range params {
+		fieldTypes = append(fieldTypes, param.Type())
+	}
+	if !isDirect {
+		fieldTypes = append(fieldTypes, fn.Type())
+	}
+	if hasResult {
+		fieldTypes = append(fieldTypes, resultType)
+	}
+	packedType := b.ctx.StructType(fieldTypes, false)
+	packed, packedSize := b.createTemporaryAlloca(packedType, "invoke.packed")
+	fields := params
+	if !isDirect {
+		fields = append(fields[:len(fields):len(fields)], fn)
+	}
+	for i, field := range fields {
+		gep := b.CreateInBoundsGEP(packedType, packed, []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(i), false),
+		}, "")
+		b.CreateStore(field, gep)
+	}
+
+	// Call the wrapper, and continue at the landing pad on a panic.
+	wrapper := b.createWasmInvokeWrapper(fnType, fn, packedType, isDirect, hasResult)
+	panicked := b.createRuntimeCall("wasmTry", []llvm.Value{wrapper, packed}, "invoke.panicked")
+	continueBB := b.insertBasicBlock("invoke.cont")
+	b.CreateCondBr(panicked, b.landingpad, continueBB)
+	b.SetInsertPointAtEnd(continueBB)
+	b.blockExits[b.currentBlock] = continueBB
+
+	var result llvm.Value
+	if hasResult {
+		gep := b.CreateInBoundsGEP(packedType, packed, []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(len(fieldTypes)-1), false),
+		}, "")
+		result = b.CreateLoad(resultType, gep, name)
+	}
+	b.emitLifetimeEnd(packed, packedSize)
+	return result
+}
+
+// createWasmInvokeWrapper creates the function called by runtime.wasmTry for
+// createWasmInvoke. It returns the function as an uintptr.
+func (b *builder) createWasmInvokeWrapper(fnType llvm.Type, fn llvm.Value, packedType llvm.Type, isDirect, hasResult bool) llvm.Value {
+	var wrapperName string
+	if isDirect {
+		// The wrapper only depends on the called function, so it can be
+		// reused for other calls of the same function.
+		wrapperName = fn.Name() + "$invoke"
+		if wrapper := b.mod.NamedFunction(wrapperName); !wrapper.IsNil() {
+			return llvm.ConstPtrToInt(wrapper, b.uintptrType)
+		}
+	} else {
+		wrapperName = b.llvmFn.Name() + "$invoke"
+	}
+
+	wrapperType := llvm.FunctionType(b.ctx.VoidType(), []llvm.Type{b.dataPtrType}, false)
+	wrapper := llvm.AddFunction(b.mod, wrapperName, wrapperType)
+	b.addStandardAttributes(wrapper)
+	wrapper.SetLinkage(llvm.InternalLinkage)
+	wrapper.SetUnnamedAddr(true)
+
+	wb := b.ctx.NewBuilder()
+	defer wb.Dispose()
+	wb.SetInsertPointAtEnd(b.ctx.AddBasicBlock(wrapper, "entry"))
+
+	// Load all fields from the packed struct.
+	numFields := len(packedType.StructElementTypes())
+	var fields []llvm.Value
+	for i, fieldType := range packedType.StructElementTypes() {
+		if hasResult && i == numFields-1 {
+			break
+		}
+		gep := wb.CreateInBoundsGEP(packedType, wrapper.Param(0), []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(i), false),
+		}, "")
+		fields = append(fields, wb.CreateLoad(fieldType, gep, ""))
+	}
+	callee := fn
+	if !isDirect {
+		callee = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	// Call the function and store the result.
+	result := wb.CreateCall(fnType, callee, fields, "")
+	if hasResult {
+		gep := wb.CreateInBoundsGEP(packedType, wrapper.Param(0), []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(numFields-1), false),
+		}, "")
+		wb.CreateStore(result, gep)
+	}
+	wb.CreateRetVoid()
+
+	return llvm.ConstPtrToInt(wrapper, b.uintptrType)
+}
+
 // isInLoop checks if there is a path from a basic block to itself.
 func isInLoop(start *ssa.BasicBlock) bool {
 	// Use a breadth-first search to scan backwards through the block graph.
@@ -113,7 +113,7 @@ func (b *builder) createGo(instr *ssa.Go) {
 	} else {
 		// The stack size is fixed at compile time. By emitting it here as a
 		// constant, it can be optimized.
-		if (b.Scheduler == "tasks" || b.Scheduler == "asyncify") && b.DefaultStackSize == 0 {
+		if (b.Scheduler == "tasks" || b.Scheduler == "asyncify" || b.Scheduler == "jspi" || b.Scheduler == "threads") && b.DefaultStackSize == 0 {
 			b.addError(instr.Pos(), "default stack size for goroutines is not set")
 		}
 		stackSize = llvm.ConstInt(b.uintptrType, b.DefaultStackSize, false)
@@ -136,13 +136,23 @@ func (b *builder) createWasmExport() {
 
 	const suffix = "#wasmexport"
 
+	// The function that is called by the exported function. With marshalled
+	// parameters and results, this is a wrapper that reads them from memory.
+	fnType, fn := b.llvmFnType, b.llvmFn
+	exportName := b.info.wasmExport
+	if b.info.wasmExportJS {
+		fnType, fn = b.createJSExportWrapper()
+		exportName = jsExportName(exportName, b.fn.Signature, int64(b.targetData.PointerSize()))
+	}
+	hasReturn := fnType.ReturnType().TypeKind() != llvm.VoidTypeKind
+
 	// Declare the exported function.
-	paramTypes := b.llvmFnType.ParamTypes()
-	exportedFnType := llvm.FunctionType(b.llvmFnType.ReturnType(), paramTypes[:len(paramTypes)-1], false)
+	paramTypes := fnType.ParamTypes()
+	exportedFnType := llvm.FunctionType(fnType.ReturnType(), paramTypes[:len(paramTypes)-1], false)
 	exportedFn := llvm.AddFunction(b.mod, b.fn.RelString(nil)+suffix, exportedFnType)
 	b.addStandardAttributes(exportedFn)
 	llvmutil.AppendToGlobal(b.mod, "llvm.used", exportedFn)
-	exportedFn.AddFunctionAttr(b.ctx.CreateStringAttribute("wasm-export-name", b.info.wasmExport))
+	exportedFn.AddFunctionAttr(b.ctx.CreateStringAttribute("wasm-export-name", exportName))
 
 	// Create a builder for this wrapper function.
 	builder := newBuilder(b.compilerContext, b.ctx.NewBuilder(), b.fn)
@@ -167,13 +177,14 @@ func (b *builder) createWasmExport() {
 	// is initialized).
 	builder.createRuntimeCall("wasmExportCheckRun", nil, "")
 
-	if b.Scheduler == "none" {
+	if b.Scheduler == "none" || b.Scheduler == "threads" {
 		// When the scheduler has been disabled, this is really trivial: just
-		// call the function.
+		// call the function. The same is true with threads: the function runs
+		// on the calling thread and may block it.
 		params := exportedFn.Params()
 		params = append(params, llvm.ConstNull(b.dataPtrType)) // context parameter
-		retval := builder.CreateCall(b.llvmFnType, b.llvmFn, params, "")
-		if b.fn.Signature.Results() == nil {
+		retval := builder.CreateCall(fnType, fn, params, "")
+		if !hasReturn {
 			builder.CreateRetVoid()
 		} else {
 			builder.CreateRet(retval)
@@ -207,8 +218,6 @@ func (b *builder) createWasmExport() {
 		//       return state.result
 		//   }
 
-		hasReturn := b.fn.Signature.Results() != nil
-
 		// Build the state struct type.
 		// It stores the function parameters, the 'done' flag, and reserves
 		// space for a return value if needed.
@@ -216,7 +225,7 @@ func (b *builder) createWasmExport() {
 		numParams := len(stateFields)
 		stateFields = append(stateFields, b.ctx.Int1Type()) // 'done' field
 		if hasReturn {
-			stateFields = append(stateFields, b.llvmFnType.ReturnType())
+			stateFields = append(stateFields, fnType.ReturnType())
 		}
 		stateStruct := b.ctx.StructType(stateFields, false)
 
@@ -240,7 +249,7 @@ func (b *builder) createWasmExport() {
 		}
 
 		// Create a new goroutine and add it to the runqueue.
-		wrapper := b.createGoroutineStartWrapper(b.llvmFnType, b.llvmFn, "", false, true, pos)
+		wrapper := b.createGoroutineStartWrapper(fnType, fn, "", false, true, pos)
 		stackSize := llvm.ConstInt(b.uintptrType, b.DefaultStackSize, false)
 		taskStartFnType, taskStartFn := builder.getFunction(b.program.ImportedPackage("internal/task").Members["start"].(*ssa.Function))
 		builder.createCall(taskStartFnType, taskStartFn, []llvm.Value{wrapper, statePtr, stackSize, llvm.Undef(b.dataPtrType)}, "")
@@ -255,7 +264,7 @@ func (b *builder) createWasmExport() {
 				llvm.ConstInt(b.ctx.Int32Type(), 0, false),
 				llvm.ConstInt(b.ctx.Int32Type(), uint64(numParams)+1, false),
 			}, "")
-			retval := builder.CreateLoad(b.llvmFnType.ReturnType(), gep, "retval")
+			retval := builder.CreateLoad(fnType.ReturnType(), gep, "retval")
 			builder.CreateRet(retval)
 		} else {
 			builder.CreateRetVoid()
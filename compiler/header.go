@@ -0,0 +1,549 @@
+package compiler
+
+// This file generates a C header file for all functions exported with
+// //export, similar to the _cgo_export.h file generated by cgo. It is written
+// next to the output file with -buildmode=c-archive and -buildmode=c-shared.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/loader"
+)
+
+// headerPreamble contains the type definitions used in all generated headers.
+// The layout of GoString, GoSlice and GoInterface matches the layout used by
+// the compiler.
+const headerPreamble = `#include <stddef.h>
+#include <stdint.h>
+
+typedef int8_t GoInt8;
+typedef uint8_t GoUint8;
+typedef int16_t GoInt16;
+typedef uint16_t GoUint16;
+typedef int32_t GoInt32;
+typedef uint32_t GoUint32;
+typedef int64_t GoInt64;
+typedef uint64_t GoUint64;
+typedef GoInt%[1]d GoInt;
+typedef GoUint%[1]d GoUint;
+typedef uintptr_t GoUintptr;
+typedef float GoFloat32;
+typedef double GoFloat64;
+#ifdef __cplusplus
+typedef bool GoBool;
+#else
+typedef _Bool GoBool;
+typedef float _Complex GoComplex64;
+typedef double _Complex GoComplex128;
+#endif
+typedef void *GoMap;
+typedef void *GoChan;
+typedef struct { const char *ptr; GoUintptr len; } GoString;
+typedef struct { void *ptr; GoUintptr len; GoUintptr cap; } GoSlice;
+typedef struct { void *type; void *value; } GoInterface;
+`
+
+// headerPreambleTypes lists the types declared in headerPreamble.
+var headerPreambleTypes = []string{
+	"GoInt8", "GoUint8", "GoInt16", "GoUint16", "GoInt32", "GoUint32",
+	"GoInt64", "GoUint64", "GoInt", "GoUint", "GoUintptr", "GoFloat32",
+	"GoFloat64", "GoBool", "GoComplex64", "GoComplex128", "GoMap", "GoChan",
+	"GoString", "GoSlice", "GoInterface",
+}
+
+// cKeywords lists the keywords of C and C++ that are valid identifiers in Go.
+// Names of fields, parameters and types that are keywords get a "_" suffix.
+var cKeywords = map[string]bool{
+	// C
+	"auto": true, "char": true, "double": true, "enum": true, "extern": true,
+	"float": true, "inline": true, "int": true, "long": true, "register": true,
+	"restrict": true, "short": true, "signed": true, "sizeof": true,
+	"static": true, "typedef": true, "union": true, "unsigned": true,
+	"void": true, "volatile": true, "while": true, "do": true, "typeof": true,
+	"_Alignas": true, "_Alignof": true, "_Atomic": true, "_Bool": true,
+	"_Complex": true, "_Generic": true, "_Imaginary": true, "_Noreturn": true,
+	"_Static_assert": true, "_Thread_local": true,
+	// C++
+	"alignas": true, "alignof": true, "and": true, "and_eq": true, "asm": true,
+	"bitand": true, "bitor": true, "bool": true, "catch": true, "char8_t": true,
+	"char16_t": true, "char32_t": true, "class": true, "compl": true,
+	"concept": true, "consteval": true, "constexpr": true, "constinit": true,
+	"const_cast": true, "co_await": true, "co_return": true, "co_yield": true,
+	"decltype": true, "delete": true, "dynamic_cast": true, "explicit": true,
+	"export": true, "false": true, "friend": true, "mutable": true,
+	"namespace": true, "new": true, "noexcept": true, "not": true,
+	"not_eq": true, "nullptr": true, "operator": true, "or": true,
+	"or_eq": true, "private": true, "protected": true, "public": true,
+	"reinterpret_cast": true, "requires": true, "static_assert": true,
+	"static_cast": true, "template": true, "this": true, "thread_local": true,
+	"throw": true, "true": true, "try": true, "typeid": true, "typename": true,
+	"using": true, "virtual": true, "wchar_t": true, "xor": true, "xor_eq": true,
+}
+
+// headerCArchive declares the runtime API used with -buildmode=c-archive. See
+// runtime_cortexm_carchive.go for details.
+const headerCArchive = `
+/* Initialize the Go runtime. Must be called once before any other function. */
+void tinygo_init(void *heap, size_t size);
+
+/* Run goroutines (-scheduler=tasks). Must be called periodically from an RTOS
+   thread. Returns the time in nanoseconds until it must be called again, or -1
+   if no goroutine is sleeping. */
+int64_t tinygo_tick(void);
+
+/* Must be implemented by the firmware. */
+uint64_t tinygo_host_nanotime(void);
+void tinygo_host_sleep(uint64_t ns);
+void tinygo_host_putchar(char c);
+void tinygo_host_abort(void);
+`
+
+// ExportHeader returns a C header declaring all functions exported with
+// //export in the given packages, except for the ones in the standard library.
+// Go types used in these functions are mapped to C types, and struct
+// definitions are emitted for named struct types. The name (usually the output
+// file) is used for the include guard, so that headers of different libraries
+// can be included in the same file.
+//
+// An error is returned if two named types map to the same C name, for example
+// because they have the same name in two packages with the same package name.
+func ExportHeader(pkgs []*loader.Package, sizes types.Sizes, buildMode, name string) ([]byte, error) {
+	g := newHeaderGenerator(sizes)
+	for _, pkg := range pkgs {
+		if pkg.Standard {
+			continue
+		}
+		g.addPackage(pkg.Pkg, pkg.Files)
+	}
+	return g.header(buildMode, headerGuard(name))
+}
+
+// headerGuard returns the include guard for a header with the given name, for
+// example TINYGO_LIBFOO_H for "build/libfoo.a".
+func headerGuard(name string) string {
+	name = filepath.Base(name)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	guard := []byte("TINYGO_")
+	for _, c := range []byte(strings.ToUpper(name)) {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			c = '_'
+		}
+		guard = append(guard, c)
+	}
+	return string(guard) + "_H"
+}
+
+// headerGenerator collects all the declarations for a C header file.
+type headerGenerator struct {
+	sizes    types.Sizes
+	named    map[*types.TypeName]string // C names of the named types that have been declared
+	cNames   map[string]*types.TypeName // named types by their C name
+	typedefs bytes.Buffer               // typedefs for named types, in dependency order
+	structs  []string                   // names of struct types (for forward declarations)
+	funcs    bytes.Buffer               // function declarations
+	err      error                      // first error, if any
+}
+
+func newHeaderGenerator(sizes types.Sizes) *headerGenerator {
+	return &headerGenerator{
+		sizes:  sizes,
+		named:  make(map[*types.TypeName]string),
+		cNames: make(map[string]*types.TypeName),
+	}
+}
+
+// header returns the complete header file, or the first error found while
+// adding declarations.
+func (g *headerGenerator) header(buildMode, guard string) ([]byte, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("/* Code generated by TinyGo. DO NOT EDIT. */\n\n")
+	fmt.Fprintf(buf, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(buf, headerPreamble, g.sizes.Sizeof(types.Typ[types.Int])*8)
+	buf.WriteString("\n#ifdef __cplusplus\nextern \"C\" {\n#endif\n")
+	if buildMode == "c-archive" {
+		buf.WriteString(headerCArchive)
+	}
+	if len(g.structs) != 0 {
+		buf.WriteString("\n")
+		for _, name := range g.structs {
+			fmt.Fprintf(buf, "typedef struct %s %s;\n", name, name)
+		}
+	}
+	if g.typedefs.Len() != 0 {
+		buf.WriteString("\n")
+		buf.Write(g.typedefs.Bytes())
+	}
+	if g.funcs.Len() != 0 {
+		buf.WriteString("\n")
+		buf.Write(g.funcs.Bytes())
+	}
+	fmt.Fprintf(buf, "\n#ifdef __cplusplus\n}\n#endif\n\n#endif /* %s */\n", guard)
+	return buf.Bytes(), nil
+}
+
+// addPackage adds all exported functions in the given package to the header.
+func (g *headerGenerator) addPackage(pkg *types.Package, files []*ast.File) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv != nil || decl.Body == nil {
+				// Only functions with a body are exported, function
+				// declarations without a body are imported.
+				continue
+			}
+			name := exportName(decl)
+			if name == "" {
+				continue
+			}
+			fn, ok := pkg.Scope().Lookup(decl.Name.Name).(*types.Func)
+			if !ok {
+				continue
+			}
+			g.addFunction(name, fn.Type().(*types.Signature))
+		}
+	}
+}
+
+// exportName returns the name given in the //export or //go:export pragma of
+// this function, or the empty string if the function isn't exported this way.
+// It follows the same rules as parsePragmas.
+func exportName(decl *ast.FuncDecl) string {
+	if decl.Doc == nil {
+		return ""
+	}
+	name := ""
+	for _, comment := range decl.Doc.List {
+		parts := strings.Fields(comment.Text)
+		switch parts[0] {
+		case "//export", "//go:export":
+			if len(parts) == 2 {
+				name = parts[1]
+			}
+		case "//go:wasmexport":
+			// //go:wasmexport overrides //export.
+			return ""
+		}
+	}
+	return name
+}
+
+// cParam is a single parameter in a C function declaration.
+type cParam struct {
+	typ  string
+	name string
+}
+
+// addFunction adds a single function declaration to the header. Functions
+// that can't be called from C are added as a comment.
+func (g *headerGenerator) addFunction(name string, sig *types.Signature) {
+	if cKeywords[name] {
+		// The name is the symbol name, so it can't be changed.
+		fmt.Fprintf(&g.funcs, "/* %s: name is a keyword in C or C++ */\n", name)
+		return
+	}
+
+	// Determine the return type. Aggregate values are returned as LLVM
+	// aggregates, which don't match the C calling convention.
+	result := "void"
+	switch sig.Results().Len() {
+	case 0:
+	case 1:
+		t := sig.Results().At(0).Type()
+		if !isScalarType(t) {
+			fmt.Fprintf(&g.funcs, "/* %s: result of type %s cannot be returned to C */\n", name, t)
+			return
+		}
+		result = g.cType(t)
+	default:
+		fmt.Fprintf(&g.funcs, "/* %s: multiple results cannot be returned to C */\n", name)
+		return
+	}
+
+	// Parameters that are aggregates (strings, slices, structs, etc) are split
+	// into their individual fields by the compiler if they have at most
+	// maxFieldsPerParam fields, so do the same here. Larger aggregates are
+	// passed as a single LLVM value, which doesn't match any C type.
+	var params []cParam
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		paramName := param.Name()
+		if paramName == "" || paramName == "_" {
+			paramName = "p" + strconv.Itoa(i)
+		}
+		expanded, err := g.expandParam(param.Type(), paramName)
+		if err != nil {
+			fmt.Fprintf(&g.funcs, "/* %s: %s */\n", name, err)
+			return
+		}
+		if len(expanded) > maxFieldsPerParam {
+			fmt.Fprintf(&g.funcs, "/* %s: parameter %s of type %s has too many fields to be passed from C, use a pointer instead */\n", name, paramName, param.Type())
+			return
+		}
+		params = append(params, expanded...)
+	}
+
+	var paramStrings []string
+	used := make(map[string]bool)
+	for _, param := range params {
+		used[param.name] = true
+	}
+	for _, param := range params {
+		name := param.name
+		if cKeywords[name] {
+			name = uniqueName(name, func(name string) bool { return used[name] })
+			used[name] = true
+		}
+		paramStrings = append(paramStrings, cDecl(param.typ, name))
+	}
+	if len(paramStrings) == 0 {
+		paramStrings = []string{"void"}
+	}
+	fmt.Fprintf(&g.funcs, "%s(%s);\n", cDecl(result, name), strings.Join(paramStrings, ", "))
+}
+
+// expandParam returns the C parameters for a single Go parameter, splitting up
+// aggregate types in the same way as flattenAggregateType. The caller must
+// check that the result has at most maxFieldsPerParam parameters, like
+// expandFormalParamType does.
+func (g *headerGenerator) expandParam(t types.Type, name string) ([]cParam, error) {
+	if isScalarType(t) {
+		return []cParam{{g.cType(t), name}}, nil
+	}
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String:
+			return []cParam{{"const char *", name + "_ptr"}, {"GoUintptr", name + "_len"}}, nil
+		case types.Complex64:
+			return []cParam{{"GoFloat32", name + "_real"}, {"GoFloat32", name + "_imag"}}, nil
+		case types.Complex128:
+			return []cParam{{"GoFloat64", name + "_real"}, {"GoFloat64", name + "_imag"}}, nil
+		}
+	case *types.Slice:
+		return []cParam{{g.cType(types.NewPointer(t.Elem())), name + "_ptr"}, {"GoUintptr", name + "_len"}, {"GoUintptr", name + "_cap"}}, nil
+	case *types.Interface:
+		return []cParam{{"void *", name + "_type"}, {"void *", name + "_value"}}, nil
+	case *types.Struct:
+		var params []cParam
+		for i := 0; i < t.NumFields(); i++ {
+			field := t.Field(i)
+			if g.sizes.Sizeof(field.Type()) == 0 {
+				continue
+			}
+			expanded, err := g.expandParam(field.Type(), name+"_"+fieldName(field, i))
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, expanded...)
+		}
+		return params, nil
+	}
+	// Arrays are passed as a single LLVM value, even if they are part of a
+	// struct, and C cannot pass arrays by value. Function values don't have a
+	// C equivalent.
+	return nil, fmt.Errorf("parameter %s of type %s cannot be passed from C", name, t)
+}
+
+// isScalarType returns whether the given type is passed as a single value.
+func isScalarType(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		return t.Info()&(types.IsString|types.IsComplex) == 0
+	case *types.Pointer, *types.Map, *types.Chan:
+		return true
+	default:
+		return false
+	}
+}
+
+// cType returns the C type for the given Go type, as used in struct fields and
+// function parameters. Named types are declared as needed.
+func (g *headerGenerator) cType(t types.Type) string {
+	if named, ok := t.(*types.Named); ok && named.TypeArgs() == nil && named.Obj().Pkg() != nil {
+		if _, ok := named.Underlying().(*types.Interface); !ok {
+			return g.declareNamed(named)
+		}
+	}
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.Bool:
+			return "GoBool"
+		case types.Int:
+			return "GoInt"
+		case types.Uint:
+			return "GoUint"
+		case types.Uintptr:
+			return "GoUintptr"
+		case types.String:
+			return "GoString"
+		case types.UnsafePointer:
+			return "void *"
+		default:
+			// GoInt8, GoFloat32, GoComplex64, etc. Use the kind to get the
+			// name, as byte and rune are aliases.
+			name := types.Typ[t.Kind()].Name()
+			return "Go" + strings.ToUpper(name[:1]) + name[1:]
+		}
+	case *types.Pointer:
+		switch t.Elem().(type) {
+		case *types.Struct, *types.Array, *types.Signature:
+			// There is no C type for an anonymous struct or array that can be
+			// used here.
+			return "void *"
+		}
+		return g.cType(t.Elem()) + " *"
+	case *types.Slice:
+		return "GoSlice"
+	case *types.Interface:
+		return "GoInterface"
+	case *types.Map:
+		return "GoMap"
+	case *types.Chan:
+		return "GoChan"
+	case *types.Struct:
+		return "struct {" + g.structFields(t) + " }"
+	}
+	// Function values and arrays outside of structs don't have a C
+	// equivalent.
+	return "void *"
+}
+
+// declareNamed declares the given named type in the header (if it hasn't been
+// declared yet) and returns its C name.
+func (g *headerGenerator) declareNamed(named *types.Named) string {
+	obj := named.Obj()
+	if name, ok := g.named[obj]; ok {
+		return name
+	}
+	name := obj.Name()
+	if obj.Pkg().Name() != "main" {
+		name = obj.Pkg().Name() + "_" + name
+	}
+	if cKeywords[name] {
+		name += "_"
+	}
+	if other := g.cNames[name]; other != nil {
+		if g.err == nil {
+			g.err = fmt.Errorf("C header: types %s.%s and %s.%s both have the C name %s", other.Pkg().Path(), other.Name(), obj.Pkg().Path(), obj.Name(), name)
+		}
+	} else if isPreambleType(name) {
+		if g.err == nil {
+			g.err = fmt.Errorf("C header: type %s.%s has the C name %s, which is already used for a Go type", obj.Pkg().Path(), obj.Name(), name)
+		}
+	}
+	g.named[obj] = name
+	g.cNames[name] = obj
+
+	switch t := named.Underlying().(type) {
+	case *types.Struct:
+		// Structs are declared before all typedefs, so that they can be used
+		// through a pointer before they are defined.
+		g.structs = append(g.structs, name)
+		fields := g.structFields(t)
+		fmt.Fprintf(&g.typedefs, "struct %s {%s };\n", name, fields)
+	case *types.Array:
+		elem, suffix := g.arrayType(t)
+		fmt.Fprintf(&g.typedefs, "typedef %s;\n", cDecl(elem, name+suffix))
+	default:
+		typ := g.cType(t)
+		fmt.Fprintf(&g.typedefs, "typedef %s;\n", cDecl(typ, name))
+	}
+	return name
+}
+
+// structFields returns the fields of a C struct with the same layout as the
+// given Go struct.
+//
+// In C++, a struct member cannot have the same name as a type used in the
+// struct, as in "struct Options { Mode Mode; }". Such fields get a "_" suffix,
+// which doesn't change the layout. The same is done for fields that are named
+// like a C or C++ keyword.
+func (g *headerGenerator) structFields(t *types.Struct) string {
+	var fieldTypes, names, suffixes []string
+	used := make(map[string]bool)
+	for i := 0; i < t.NumFields(); i++ {
+		field := t.Field(i)
+		if g.sizes.Sizeof(field.Type()) == 0 {
+			// C doesn't allow zero-sized fields.
+			continue
+		}
+		typ, suffix := g.cType(field.Type()), ""
+		if array, ok := field.Type().(*types.Array); ok {
+			typ, suffix = g.arrayType(array)
+		}
+		fieldTypes = append(fieldTypes, typ)
+		names = append(names, fieldName(field, i))
+		suffixes = append(suffixes, suffix)
+		used[fieldName(field, i)] = true
+	}
+	var fields string
+	for i, name := range names {
+		if g.isTypeName(name) || cKeywords[name] {
+			name = uniqueName(name, func(name string) bool { return used[name] || g.isTypeName(name) })
+			used[name] = true
+		}
+		fields += " " + cDecl(fieldTypes[i], name+suffixes[i]) + ";"
+	}
+	return fields
+}
+
+// isTypeName returns whether name is a type declared in the header.
+func (g *headerGenerator) isTypeName(name string) bool {
+	return g.cNames[name] != nil || isPreambleType(name)
+}
+
+// isPreambleType returns whether name is a type declared in headerPreamble.
+func isPreambleType(name string) bool {
+	for _, typ := range headerPreambleTypes {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueName appends "_" to name until it isn't taken.
+func uniqueName(name string, taken func(string) bool) string {
+	name += "_"
+	for taken(name) {
+		name += "_"
+	}
+	return name
+}
+
+// arrayType returns the C element type and the array suffix (like "[4][8]")
+// for the given array type.
+func (g *headerGenerator) arrayType(t *types.Array) (elem, suffix string) {
+	suffix = "[" + strconv.FormatInt(t.Len(), 10) + "]"
+	if inner, ok := t.Elem().(*types.Array); ok {
+		elem, innerSuffix := g.arrayType(inner)
+		return elem, suffix + innerSuffix
+	}
+	return g.cType(t.Elem()), suffix
+}
+
+// fieldName returns the name of the given struct field as used in C.
+func fieldName(field *types.Var, index int) string {
+	if field.Name() == "_" {
+		return "_" + strconv.Itoa(index)
+	}
+	return field.Name()
+}
+
+// cDecl returns a C declaration of a value with the given type and name.
+func cDecl(typ, name string) string {
+	if strings.HasSuffix(typ, "*") {
+		return typ + name
+	}
+	return typ + " " + name
+}
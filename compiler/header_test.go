@@ -0,0 +1,91 @@
+package compiler
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test the C header generated for //export functions. Pass -update to update
+// the expected output.
+func TestExportHeader(t *testing.T) {
+	t.Parallel()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "testdata/header.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal("could not parse test file:", err)
+	}
+	config := types.Config{
+		Sizes:    types.SizesFor("gc", "arm"),
+		Importer: headerTestImporter{},
+	}
+	pkg, err := config.Check("main", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal("could not typecheck test file:", err)
+	}
+
+	g := newHeaderGenerator(config.Sizes)
+	g.addPackage(pkg, []*ast.File{file})
+	actual, err := g.header("c-archive", headerGuard("build/libtest.a"))
+	if err != nil {
+		t.Fatal("could not generate header:", err)
+	}
+
+	outPath := "testdata/header.h"
+	if *flagUpdate {
+		err := os.WriteFile(outPath, actual, 0666)
+		if err != nil {
+			t.Error("failed to write updated output file:", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal("failed to read golden file:", err)
+	}
+	if strings.ReplaceAll(string(expected), "\r\n", "\n") != string(actual) {
+		t.Errorf("output does not match expected output:\n%s", actual)
+	}
+}
+
+// Test that two named types with the same C name are reported, instead of
+// being declared twice.
+func TestExportHeaderCollision(t *testing.T) {
+	t.Parallel()
+
+	g := newHeaderGenerator(types.SizesFor("gc", "arm"))
+	for _, path := range []string{"example.com/a/util", "example.com/b/util"} {
+		fset := token.NewFileSet()
+		src := "package util\n\ntype Config struct{ X int32 }\n\n//export configure_" + path[12:13] + "\nfunc configure(c *Config) {}\n"
+		file, err := parser.ParseFile(fset, path+"/util.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal("could not parse test file:", err)
+		}
+		config := types.Config{Sizes: g.sizes}
+		pkg, err := config.Check(path, fset, []*ast.File{file}, nil)
+		if err != nil {
+			t.Fatal("could not typecheck test file:", err)
+		}
+		g.addPackage(pkg, []*ast.File{file})
+	}
+	_, err := g.header("c-shared", headerGuard("libtest.so"))
+	expected := "C header: types example.com/a/util.Config and example.com/b/util.Config both have the C name util_Config"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+}
+
+// headerTestImporter only imports the unsafe package.
+type headerTestImporter struct{}
+
+func (headerTestImporter) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	return nil, os.ErrNotExist
+}
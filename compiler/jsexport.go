@@ -0,0 +1,251 @@
+package compiler
+
+// This file implements //go:wasmexport functions with parameters and results
+// that are marshalled by wasm_exec.js, which is used with GOOS=js for
+// functions that use strings, byte slices, structs or an error result.
+//
+// These functions are exported with a name that describes the signature, for
+// example:
+//
+//	jsexport:greet(string,{Name:string,Age:i32})[]u8,error
+//
+// The exported function takes two pointers: one to the parameters and one to
+// the results, both stored as a struct with the usual Go memory layout.
+// wasm_exec.js allocates this memory with tinygo_jsexport_alloc, writes the
+// parameters, calls the function and reads back the results. A non-nil error
+// result is thrown as an exception.
+
+import (
+	"fmt"
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// Prefix of the export name of marshalled //go:wasmexport functions.
+const jsExportPrefix = "jsexport:"
+
+// Whether the parameters or results of a //go:wasmexport function on GOOS=js
+// need to be marshalled by wasm_exec.js, instead of being passed directly as
+// WebAssembly values.
+func needsJSMarshalling(sig *types.Signature) bool {
+	if sig.Results().Len() > 1 {
+		return true
+	}
+	for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+		for i := 0; i < tuple.Len(); i++ {
+			switch typ := tuple.At(i).Type().Underlying().(type) {
+			case *types.Basic:
+				if typ.Kind() == types.String {
+					return true
+				}
+			case *types.Slice, *types.Struct, *types.Interface:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Size in bits of integer types that don't depend on the pointer size.
+var jsIntBits = map[types.BasicKind]int64{
+	types.Int8:   8,
+	types.Uint8:  8,
+	types.Int16:  16,
+	types.Uint16: 16,
+	types.Int32:  32,
+	types.Uint32: 32,
+	types.Int64:  64,
+	types.Uint64: 64,
+}
+
+// Return the name of a marshalled parameter or result type, or the empty
+// string if the type can't be marshalled. The error type is handled
+// separately, as it can only be used as the last result.
+func jsExportType(typ types.Type, ptrSize int64) string {
+	switch typ := typ.Underlying().(type) {
+	case *types.Basic:
+		switch typ.Kind() {
+		case types.Bool:
+			return "bool"
+		case types.String:
+			return "string"
+		case types.Float32:
+			return "f32"
+		case types.Float64:
+			return "f64"
+		}
+		if typ.Info()&types.IsInteger == 0 {
+			return ""
+		}
+		bits, ok := jsIntBits[typ.Kind()]
+		if !ok {
+			bits = ptrSize * 8 // int, uint, uintptr
+		}
+		if typ.Info()&types.IsUnsigned != 0 {
+			return fmt.Sprintf("u%d", bits)
+		}
+		return fmt.Sprintf("i%d", bits)
+	case *types.Slice:
+		if elem, ok := typ.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Uint8 {
+			return "[]u8"
+		}
+	case *types.Struct:
+		var fields []string
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			name := jsFieldName(field, typ.Tag(i))
+			fieldType := jsExportType(field.Type(), ptrSize)
+			if !field.Exported() || name == "" || fieldType == "" {
+				return ""
+			}
+			fields = append(fields, name+":"+fieldType)
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	}
+	return ""
+}
+
+// Return the JavaScript property name of a struct field: the name in the js
+// struct tag or else the Go field name. It returns the empty string if the
+// name in the tag is not a valid identifier.
+func jsFieldName(field *types.Var, tag string) string {
+	name := reflect.StructTag(tag).Get("js")
+	if name == "" {
+		return field.Name()
+	}
+	for i, c := range name {
+		if !(c == '_' || c == '$' || unicode.IsLetter(c) || i != 0 && unicode.IsDigit(c)) {
+			return ""
+		}
+	}
+	return name
+}
+
+// Whether this is the predeclared error type.
+func isErrorType(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+// Whether the last result of this signature is an error.
+func hasErrorResult(sig *types.Signature) bool {
+	results := sig.Results()
+	return results.Len() != 0 && isErrorType(results.At(results.Len()-1).Type())
+}
+
+// Return the export name of a marshalled //go:wasmexport function, which
+// describes its signature. The signature must have been checked by
+// checkJSExport.
+func jsExportName(name string, sig *types.Signature, ptrSize int64) string {
+	var params, results []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, jsExportType(sig.Params().At(i).Type(), ptrSize))
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		typ := sig.Results().At(i).Type()
+		if isErrorType(typ) {
+			results = append(results, "error")
+		} else {
+			results = append(results, jsExportType(typ, ptrSize))
+		}
+	}
+	return jsExportPrefix + name + "(" + strings.Join(params, ",") + ")" + strings.Join(results, ",")
+}
+
+// Check whether the parameters and results of this //go:wasmexport function
+// can be marshalled. It adds an error if this is not the case.
+func (c *compilerContext) checkJSExport(f *ssa.Function, pragma string) {
+	ptrSize := int64(c.targetData.PointerSize())
+	results := f.Signature.Results()
+	for i := 0; i < results.Len(); i++ {
+		result := results.At(i)
+		switch {
+		case i == results.Len()-1 && isErrorType(result.Type()):
+			// An error can be the last result.
+		case i != 0:
+			c.addError(result.Pos(), fmt.Sprintf("%s: too many return values", pragma))
+		case jsExportType(result.Type(), ptrSize) == "":
+			c.addError(result.Pos(), fmt.Sprintf("%s: unsupported result type %s", pragma, result.Type().String()))
+		}
+	}
+	for _, param := range f.Params {
+		if jsExportType(param.Type(), ptrSize) == "" {
+			c.addError(param.Pos(), fmt.Sprintf("%s: unsupported parameter type %s", pragma, param.Type().String()))
+		}
+	}
+}
+
+// Create a function that reads the parameters of a marshalled
+// //go:wasmexport function from memory, calls the function, and writes the
+// results back to memory. It looks like this:
+//
+//	func foo#jsexport(params *struct{...}, results *struct{...}) {
+//	    results.result0, results.err = foo(params.param0, params.param1, ...)
+//	    runtime.jsExportError(&results.err, &results.message)
+//	}
+//
+// The results are stored before the error message is created, so that they
+// stay reachable for the GC: the memory is allocated by
+// runtime.jsExportAlloc.
+func (b *builder) createJSExportWrapper() (llvm.Type, llvm.Value) {
+	var paramTypes []llvm.Type
+	for _, param := range b.fn.Params {
+		paramTypes = append(paramTypes, b.getLLVMType(param.Type()))
+	}
+	paramsType := b.ctx.StructType(paramTypes, false)
+	results := b.fn.Signature.Results()
+	var resultTypes []llvm.Type
+	for i := 0; i < results.Len(); i++ {
+		resultTypes = append(resultTypes, b.getLLVMType(results.At(i).Type()))
+	}
+	hasError := hasErrorResult(b.fn.Signature)
+	if hasError {
+		resultTypes = append(resultTypes, b.getLLVMType(types.Typ[types.String])) // error message
+	}
+	resultsType := b.ctx.StructType(resultTypes, false)
+
+	fnType := llvm.FunctionType(b.ctx.VoidType(), []llvm.Type{b.dataPtrType, b.dataPtrType, b.dataPtrType}, false)
+	fn := llvm.AddFunction(b.mod, b.fn.RelString(nil)+"#jsexport", fnType)
+	fn.SetLinkage(llvm.InternalLinkage)
+	b.addStandardAttributes(fn)
+
+	builder := newBuilder(b.compilerContext, b.ctx.NewBuilder(), b.fn)
+	defer builder.Dispose()
+	builder.SetInsertPointAtEnd(llvm.AddBasicBlock(fn, "entry"))
+	field := func(structType llvm.Type, ptr llvm.Value, index int) llvm.Value {
+		return builder.CreateInBoundsGEP(structType, ptr, []llvm.Value{
+			llvm.ConstInt(b.ctx.Int32Type(), 0, false),
+			llvm.ConstInt(b.ctx.Int32Type(), uint64(index), false),
+		}, "")
+	}
+
+	// Load the parameters and call the function.
+	var params []llvm.Value
+	for i, paramType := range paramTypes {
+		params = append(params, builder.CreateLoad(paramType, field(paramsType, fn.Param(0), i), ""))
+	}
+	params = append(params, llvm.Undef(b.dataPtrType)) // context parameter
+	result := builder.createCall(b.llvmFnType, b.llvmFn, params, "")
+
+	// Store the results.
+	switch results.Len() {
+	case 1:
+		builder.CreateStore(result, field(resultsType, fn.Param(1), 0))
+	case 2:
+		for i := 0; i < 2; i++ {
+			builder.CreateStore(builder.CreateExtractValue(result, i, ""), field(resultsType, fn.Param(1), i))
+		}
+	}
+	if hasError {
+		errPtr := field(resultsType, fn.Param(1), results.Len()-1)
+		messagePtr := field(resultsType, fn.Param(1), results.Len())
+		builder.createRuntimeCall("jsExportError", []llvm.Value{errPtr, messagePtr}, "")
+	}
+	builder.CreateRetVoid()
+
+	return fnType, fn
+}
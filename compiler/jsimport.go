@@ -0,0 +1,218 @@
+package compiler
+
+// This file implements the //go:jsimport pragma, which imports a JavaScript
+// function with typed parameters and results. Unlike calls through syscall/js,
+// arguments are not boxed in js.Value objects: the compiler creates a body for
+// the declared function that passes each value in its WebAssembly form to an
+// import in the "jsimport" module. The import name describes the JavaScript
+// function and its signature, for example:
+//
+//	document.getElementById(string)value
+//	.clearColor(value,f32,f32,f32,f32)
+//
+// wasm_exec.js reads these names when the module is instantiated, and creates
+// glue functions that convert the values and call the JavaScript function.
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+	"tinygo.org/x/go-llvm"
+)
+
+// Name of the import module of //go:jsimport functions.
+const jsImportModule = "jsimport"
+
+// Check the //go:jsimport pragma of a function, and return the JavaScript
+// function path if it is valid.
+func (c *compilerContext) checkJSImport(f *ssa.Function, pragma string, parts []string) (string, bool) {
+	if len(parts) != 2 {
+		c.addError(f.Pos(), fmt.Sprintf("expected one parameter to //go:jsimport, not %d", len(parts)-1))
+		return "", false
+	}
+	if f.Blocks != nil {
+		c.addError(f.Pos(), "can only use //go:jsimport on declarations")
+		return "", false
+	}
+	if c.GOOS != "js" || !strings.HasPrefix(c.Triple, "wasm") {
+		c.addError(f.Pos(), "//go:jsimport is only supported with GOOS=js")
+		return "", false
+	}
+	path := parts[1]
+	valid := true
+	for i, name := range strings.Split(path, ".") {
+		if name == "" && (i != 0 || path == ".") || strings.ContainsAny(name, "(),") {
+			c.addError(f.Pos(), fmt.Sprintf("%s: invalid JavaScript function path", pragma))
+			return "", false
+		}
+	}
+	params := getParams(f.Signature)
+	if strings.HasPrefix(path, ".") && (len(params) == 0 || c.jsImportType(params[0].Type(), false) != "value") {
+		c.addError(f.Pos(), fmt.Sprintf("%s: the first parameter of a method must be a js.Value", pragma))
+		valid = false
+	}
+	for _, param := range params {
+		if c.jsImportType(param.Type(), false) == "" {
+			c.addError(param.Pos(), fmt.Sprintf("%s: unsupported parameter type %s", pragma, param.Type().String()))
+			valid = false
+		}
+	}
+	results := f.Signature.Results()
+	if results.Len() > 1 {
+		c.addError(results.At(1).Pos(), fmt.Sprintf("%s: too many return values", pragma))
+		valid = false
+	} else if results.Len() == 1 && c.jsImportType(results.At(0).Type(), true) == "" {
+		c.addError(results.At(0).Pos(), fmt.Sprintf("%s: unsupported result type %s", pragma, results.At(0).Type().String()))
+		valid = false
+	}
+	return path, valid
+}
+
+// Return the name of a parameter or result type in the import name, or the
+// empty string if the type is not supported. Strings and slices can only be
+// passed as parameters, because they point into the linear memory of the
+// caller.
+func (c *compilerContext) jsImportType(typ types.Type, result bool) string {
+	if isJSValue(typ) {
+		return "value"
+	}
+	switch typ := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case typ.Kind() == types.Bool:
+			return "bool"
+		case typ.Info()&types.IsInteger != 0:
+			prefix := "i"
+			if typ.Info()&types.IsUnsigned != 0 {
+				prefix = "u"
+			}
+			if c.targetData.TypeAllocSize(c.getLLVMType(typ)) > 4 {
+				return prefix + "64"
+			}
+			return prefix + "32"
+		case typ.Kind() == types.Float32:
+			return "f32"
+		case typ.Kind() == types.Float64:
+			return "f64"
+		case typ.Kind() == types.String && !result:
+			return "string"
+		}
+	case *types.Slice:
+		elem, ok := typ.Elem().Underlying().(*types.Basic)
+		if result || !ok || elem.Info()&types.IsNumeric == 0 || elem.Info()&types.IsComplex != 0 {
+			return ""
+		}
+		prefix := "i"
+		switch {
+		case elem.Info()&types.IsFloat != 0:
+			prefix = "f"
+		case elem.Info()&types.IsUnsigned != 0:
+			prefix = "u"
+		}
+		return fmt.Sprintf("[]%s%d", prefix, c.targetData.TypeAllocSize(c.getLLVMType(elem))*8)
+	}
+	return ""
+}
+
+// Whether this is the syscall/js.Value type.
+func isJSValue(typ types.Type) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "syscall/js" && obj.Name() == "Value"
+}
+
+// Index of the ref field in the syscall/js.Value struct.
+func jsValueRefField(typ types.Type) int {
+	st := typ.Underlying().(*types.Struct)
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Name() == "ref" {
+			return i
+		}
+	}
+	panic("syscall/js.Value has no ref field")
+}
+
+// Create the body of a function with the //go:jsimport pragma, which calls
+// the imported glue function.
+func (b *builder) createJSImport() {
+	b.createFunctionStart(true)
+
+	// Convert the parameters to WebAssembly values.
+	var params []llvm.Value
+	var paramTypes []llvm.Type
+	var paramNames []string
+	for _, param := range b.fn.Params {
+		value := b.getValue(param, getPos(b.fn))
+		name := b.jsImportType(param.Type(), false)
+		paramNames = append(paramNames, name)
+		switch {
+		case name == "value":
+			params = append(params, b.CreateExtractValue(value, jsValueRefField(param.Type()), ""))
+		case name == "string" || strings.HasPrefix(name, "[]"):
+			params = append(params, b.CreateExtractValue(value, 0, ""), b.CreateExtractValue(value, 1, ""))
+		case name == "bool" || value.Type().TypeKind() == llvm.IntegerTypeKind && value.Type().IntTypeWidth() < 32:
+			// Small integers are extended, so that JavaScript sees the
+			// correct value.
+			if name[0] == 'i' {
+				value = b.CreateSExt(value, b.ctx.Int32Type(), "")
+			} else {
+				value = b.CreateZExt(value, b.ctx.Int32Type(), "")
+			}
+			params = append(params, value)
+		default:
+			params = append(params, value)
+		}
+	}
+	for _, param := range params {
+		paramTypes = append(paramTypes, param.Type())
+	}
+
+	// Determine the WebAssembly result type.
+	resultName := ""
+	returnType := b.ctx.VoidType()
+	var resultType types.Type
+	if results := b.fn.Signature.Results(); results.Len() == 1 {
+		resultType = results.At(0).Type()
+		resultName = b.jsImportType(resultType, true)
+		returnType = b.getLLVMType(resultType)
+		if resultName == "value" {
+			returnType = b.ctx.Int64Type()
+		} else if returnType.TypeKind() == llvm.IntegerTypeKind && returnType.IntTypeWidth() < 32 {
+			returnType = b.ctx.Int32Type()
+		}
+	}
+
+	// Declare the import, or reuse an existing declaration of the same
+	// function.
+	importName := b.info.jsImport + "(" + strings.Join(paramNames, ",") + ")" + resultName
+	fnType := llvm.FunctionType(returnType, paramTypes, false)
+	fn := b.mod.NamedFunction(jsImportModule + ":" + importName)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(b.mod, jsImportModule+":"+importName, fnType)
+		fn.AddFunctionAttr(b.ctx.CreateStringAttribute("wasm-import-module", jsImportModule))
+		fn.AddFunctionAttr(b.ctx.CreateStringAttribute("wasm-import-name", importName))
+	}
+	result := b.CreateCall(fnType, fn, params, "")
+
+	// Convert the result back to the Go type.
+	switch {
+	case resultType == nil:
+		b.CreateRetVoid()
+		return
+	case resultName == "value":
+		llvmType := b.getLLVMType(resultType)
+		value := b.CreateInsertValue(llvm.ConstNull(llvmType), result, jsValueRefField(resultType), "")
+		b.CreateRet(value)
+	case resultName == "bool":
+		b.CreateRet(b.CreateICmp(llvm.IntNE, result, llvm.ConstInt(b.ctx.Int32Type(), 0, false), ""))
+	case returnType != b.getLLVMType(resultType):
+		b.CreateRet(b.CreateTrunc(result, b.getLLVMType(resultType), ""))
+	default:
+		b.CreateRet(result)
+	}
+}
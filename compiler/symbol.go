@@ -28,6 +28,8 @@ type functionInfo struct {
 	wasmName      string     // wasm-export-name or wasm-import-name in the IR
 	wasmExport    string     // go:wasmexport is defined (export is unset, this adds an exported wrapper)
 	wasmExportPos token.Pos  // position of //go:wasmexport comment
+	wasmExportJS  bool       // go:wasmexport parameters and results are marshalled by wasm_exec.js
+	jsImport      string     // go:jsimport - the JavaScript function path
 	linkName      string     // go:linkname, go:export - the IR function name
 	section       string     // go:section - object file section name
 	exported      bool       // go:export, CGo
@@ -203,7 +205,7 @@ func (c *compilerContext) getFunction(fn *ssa.Function) (llvm.Type, llvm.Value)
 	// External/exported functions may not retain pointer values.
 	// https://golang.org/cmd/cgo/#hdr-Passing_pointers
 	if info.exported {
-		if c.archFamily() == "wasm32" && len(fn.Blocks) == 0 {
+		if strings.HasPrefix(c.Triple, "wasm") && len(fn.Blocks) == 0 {
 			// We need to add the wasm-import-module and the wasm-import-name
 			// attributes.
 			if info.wasmModule != "" {
@@ -357,12 +359,25 @@ func (c *compilerContext) parsePragmas(info *functionInfo, f *ssa.Function) {
 				c.addError(f.Pos(), fmt.Sprintf("//go:wasmexport does not allow main.main to be exported with -buildmode=%s", c.BuildMode))
 				continue
 			}
-			if c.archFamily() != "wasm32" {
+			if !strings.HasPrefix(c.Triple, "wasm") {
 				c.addError(f.Pos(), "//go:wasmexport is only supported on wasm")
 			}
-			c.checkWasmImportExport(f, comment.Text)
+			if c.GOOS == "js" && !isRuntimePackage(c.pkg.Path()) && needsJSMarshalling(f.Signature) {
+				// Strings, byte slices, structs and errors are converted
+				// to and from JavaScript values by wasm_exec.js.
+				c.checkJSExport(f, comment.Text)
+				info.wasmExportJS = true
+			} else {
+				c.checkWasmImportExport(f, comment.Text)
+			}
 			info.wasmExport = name
 			info.wasmExportPos = comment.Slash
+		case "//go:jsimport":
+			// Import a JavaScript function with typed glue code, without
+			// going through syscall/js.
+			if path, ok := c.checkJSImport(f, comment.Text, parts); ok {
+				info.jsImport = path
+			}
 		case "//go:inline":
 			info.inline = inlineHint
 		case "//go:noinline":
@@ -414,7 +429,7 @@ func (c *compilerContext) parsePragmas(info *functionInfo, f *ssa.Function) {
 // The list of allowed types is based on this proposal:
 // https://github.com/golang/go/issues/59149
 func (c *compilerContext) checkWasmImportExport(f *ssa.Function, pragma string) {
-	if c.pkg.Path() == "runtime" || c.pkg.Path() == "syscall/js" || c.pkg.Path() == "syscall" {
+	if isRuntimePackage(c.pkg.Path()) {
 		// The runtime is a special case. Allow all kinds of parameters
 		// (importantly, including pointers).
 		return
@@ -436,6 +451,12 @@ func (c *compilerContext) checkWasmImportExport(f *ssa.Function, pragma string)
 	}
 }
 
+// Whether this package may use any type in //go:wasmimport and
+// //go:wasmexport functions.
+func isRuntimePackage(path string) bool {
+	return path == "runtime" || path == "syscall/js" || path == "syscall"
+}
+
 // Check whether the type maps directly to a WebAssembly type.
 //
 // This reflects the relaxed type restrictions proposed here (except for structs.HostLayout):
@@ -559,6 +580,10 @@ func (c *compilerContext) addStandardDefinedAttributes(llvmFn llvm.Value) {
 		// For details, see: https://llvm.org/docs/LangRef.html#function-attributes
 		llvmFn.AddFunctionAttr(c.ctx.CreateEnumAttribute(llvm.AttributeKindID("uwtable"), 1))
 	}
+	if c.FramePointers {
+		// Needed to walk more than one frame up using llvm.returnaddress.
+		llvmFn.AddFunctionAttr(c.ctx.CreateStringAttribute("frame-pointer", "all"))
+	}
 }
 
 // addStandardAttributes adds all attributes added to defined functions.
@@ -575,6 +600,7 @@ type globalInfo struct {
 	extern   bool   // go:extern
 	align    int    // go:align
 	section  string // go:section
+	tls      bool   // go:threadlocal
 }
 
 // loadASTComments loads comments on globals from the AST, for use later in the
@@ -628,6 +654,12 @@ func (c *compilerContext) getGlobal(g *ssa.Global) llvm.Value {
 			llvmGlobal.SetAlignment(alignment)
 		}
 
+		if info.tls && c.Scheduler == "threads" {
+			// Give every thread its own copy of this global. Without threads,
+			// this is just a regular global.
+			llvmGlobal.SetThreadLocal(true)
+		}
+
 		if c.Debug && !info.extern {
 			// Add debug info.
 			pos := c.program.Fset.Position(g.Pos())
@@ -684,6 +716,8 @@ func (info *globalInfo) parsePragmas(doc *ast.CommentGroup) {
 			if len(parts) == 2 {
 				info.section = parts[1]
 			}
+		case "//go:threadlocal":
+			info.tls = true
 		}
 	}
 }
@@ -105,3 +105,71 @@ func invalidreturn_chan_int() chan int
 //
 //go:wasmimport modulename invalidreturn_string
 func invalidreturn_string() string
+
+//go:jsimport Math.max
+func jsimportValid(a float64, b float64) float64
+
+// ERROR: can only use //go:jsimport on declarations
+//
+//go:jsimport console.log
+func jsimportImplementation() {
+}
+
+// ERROR: expected one parameter to //go:jsimport, not 2
+//
+//go:jsimport console log
+func jsimportParams()
+
+// ERROR: //go:jsimport document..body: invalid JavaScript function path
+//
+//go:jsimport document..body
+func jsimportInvalidPath()
+
+// ERROR: //go:jsimport .focus: the first parameter of a method must be a js.Value
+//
+//go:jsimport .focus
+func jsimportMethod(a float64)
+
+// ERROR: //go:jsimport console.log: unsupported parameter type *int32
+// ERROR: //go:jsimport console.log: unsupported parameter type []string
+// ERROR: //go:jsimport console.log: unsupported parameter type struct{}
+//
+//go:jsimport console.log
+func jsimportInvalidParam(a *int32, b []string, c struct{})
+
+// ERROR: //go:jsimport Date.now: unsupported result type string
+//
+//go:jsimport Date.now
+func jsimportInvalidResult() string
+
+type jsexportStruct struct {
+	Name string
+	Data []byte
+}
+
+//go:wasmexport jsexportValid
+func jsexportValid(s string, b []byte, v jsexportStruct) (jsexportStruct, error) {
+	return v, nil
+}
+
+// ERROR: //go:wasmexport jsexportInvalid: unsupported parameter type []int
+// ERROR: //go:wasmexport jsexportInvalid: unsupported parameter type struct{a int}
+// ERROR: //go:wasmexport jsexportInvalid: unsupported parameter type map[string]int
+//
+//go:wasmexport jsexportInvalid
+func jsexportInvalid(a string, b []int, c struct{ a int }, d map[string]int) {
+}
+
+// ERROR: //go:wasmexport jsexportResults: too many return values
+//
+//go:wasmexport jsexportResults
+func jsexportResults() (string, string) {
+	return "", ""
+}
+
+// ERROR: //go:wasmexport jsexportResult: unsupported result type *int32
+//
+//go:wasmexport jsexportResult
+func jsexportResult() (*int32, error) {
+	return nil, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import "unsafe"
+
+type Point struct {
+	X, Y int32
+}
+
+type Node struct {
+	Value  int
+	Name   string
+	Next   *Node
+	Points [2][3]Point
+	_      uint8
+	Empty  struct{}
+}
+
+type Rect struct {
+	Min, Max Point
+}
+
+type Mode uint8
+
+type Buffer [16]byte
+
+type Options struct {
+	Mode   Mode
+	Buffer Buffer
+	Nested struct {
+		Enabled bool
+		Ratio   float64
+	}
+}
+
+// Names that are keywords in C or C++.
+type class struct {
+	int  int32
+	new  *class
+	int_ int8
+}
+
+//export add
+func add(a, b int) int {
+	return a + b
+}
+
+//export noParams
+func noParams() {
+}
+
+//export byteAt
+func byteAt(s string, i uintptr) byte {
+	return s[i]
+}
+
+//export sum
+func sum(values []int32) int32 {
+	return 0
+}
+
+//export movePoint
+func movePoint(p *Point, delta Point) {
+}
+
+//export fillRect
+func fillRect(r Rect, color uint32) {
+}
+
+//export hash
+func hash(data [4]byte) uint32 {
+	return 0
+}
+
+//export firstNode
+func firstNode(n *Node) *Node {
+	return n
+}
+
+//export configure
+func configure(options *Options, mode Mode, _ unsafe.Pointer) bool {
+	return false
+}
+
+//export lookup
+func lookup(m map[string]int, key interface{}) float32 {
+	return 0
+}
+
+//export divmod
+func divmod(a, b int) (int, int) {
+	return a / b, a % b
+}
+
+//export name
+func name() string {
+	return ""
+}
+
+//export callback
+func callback(fn func()) {
+}
+
+//go:export alsoExported
+func alsoExported(c complex64) {
+}
+
+//export keywords
+func keywords(char byte, c *class, new int) {
+}
+
+//export delete
+func del() {
+}
+
+func notExported() {
+}
+
+// Declarations are imports, not exports.
+//
+//export imported
+func imported(x int)
@@ -0,0 +1,76 @@
+package main
+
+type Person struct {
+	Name    string
+	Age     int32 `js:"age"`
+	Balance int64
+	Address struct {
+		Street string
+		Number uint16
+	}
+}
+
+type Photo struct {
+	Owner Person
+	Data  []byte
+}
+
+type private struct {
+	x int
+}
+
+func main() {
+}
+
+// Add returns the sum of a and b.
+//
+//go:wasmexport add
+func add(a, b int32) int32 {
+	return a + b
+}
+
+//go:wasmexport isEven
+func isEven(n uint64, strict bool) bool {
+	return n%2 == 0
+}
+
+//go:wasmexport pointer
+func pointer(p *int32) uintptr {
+	return 0
+}
+
+// Greet returns a greeting for the given person.
+//
+//go:wasmexport greet
+func greet(p Person) string {
+	return "Hello, " + p.Name
+}
+
+// Thumbnail creates a smaller photo.
+//
+//go:wasmexport thumbnail
+func thumbnail(photo Photo, width, height int) (Photo, error) {
+	return photo, nil
+}
+
+//go:wasmexport validate
+func validate(data []byte) error {
+	return nil
+}
+
+//go:wasmexport point
+func point(x, y float64) struct{ X, Y float64 } {
+	return struct{ X, Y float64 }{x, y}
+}
+
+//go:wasmexport private
+func usePrivate(p private) {
+}
+
+//go:wasmexport no.identifier
+func noIdentifier(_ string) {
+}
+
+// Not exported to JavaScript.
+func notExported() {
+}
@@ -0,0 +1,262 @@
+package compiler
+
+// This file generates TypeScript declarations for all functions exported with
+// //go:wasmexport on GOOS=js, as they are made available by wasm_exec.js in
+// go.exports. They are written next to the output file.
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/loader"
+)
+
+// ExportTypeScript returns TypeScript declarations for all functions exported
+// with //go:wasmexport in the given packages, except for the ones in the
+// standard library. It returns nil if there are no such functions.
+func ExportTypeScript(pkgs []*loader.Package, sizes types.Sizes) []byte {
+	g := newTypeScriptGenerator(sizes)
+	for _, pkg := range pkgs {
+		if pkg.Standard {
+			continue
+		}
+		g.addPackage(pkg.Pkg, pkg.Files)
+	}
+	return g.declarations()
+}
+
+// typeScriptGenerator collects the declarations for a .d.ts file.
+type typeScriptGenerator struct {
+	ptrSize    int64
+	named      map[*types.TypeName]string // named struct types that have been declared
+	names      map[string]bool            // names of declared interfaces
+	interfaces bytes.Buffer               // interfaces for named struct types
+	funcs      bytes.Buffer               // function declarations
+}
+
+func newTypeScriptGenerator(sizes types.Sizes) *typeScriptGenerator {
+	return &typeScriptGenerator{
+		ptrSize: sizes.Sizeof(types.Typ[types.Uintptr]),
+		named:   make(map[*types.TypeName]string),
+		names:   map[string]bool{"Exports": true},
+	}
+}
+
+// declarations returns the complete .d.ts file, or nil if no functions were
+// added.
+func (g *typeScriptGenerator) declarations() []byte {
+	if g.funcs.Len() == 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Code generated by TinyGo. DO NOT EDIT.\n")
+	buf.Write(g.interfaces.Bytes())
+	buf.WriteString("\n/**\n")
+	buf.WriteString(" * Functions exported with //go:wasmexport, available as go.exports after\n")
+	buf.WriteString(" * go.run(instance).\n")
+	buf.WriteString(" */\n")
+	buf.WriteString("export interface Exports {\n")
+	buf.Write(g.funcs.Bytes())
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// addPackage adds all functions exported with //go:wasmexport in the given
+// package.
+func (g *typeScriptGenerator) addPackage(pkg *types.Package, files []*ast.File) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Recv != nil || decl.Body == nil {
+				continue
+			}
+			name := wasmExportName(decl)
+			if name == "" {
+				continue
+			}
+			fn, ok := pkg.Scope().Lookup(decl.Name.Name).(*types.Func)
+			if !ok {
+				continue
+			}
+			g.addFunction(name, fn.Type().(*types.Signature), decl.Doc.Text())
+		}
+	}
+}
+
+// wasmExportName returns the name given in the //go:wasmexport pragma of this
+// function, or the empty string if there is none.
+func wasmExportName(decl *ast.FuncDecl) string {
+	if decl.Doc == nil {
+		return ""
+	}
+	for _, comment := range decl.Doc.List {
+		parts := strings.Fields(comment.Text)
+		if len(parts) == 2 && parts[0] == "//go:wasmexport" {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// addFunction adds a single method to the Exports interface. Functions that
+// can't be called from JavaScript are added as a comment.
+func (g *typeScriptGenerator) addFunction(name string, sig *types.Signature, doc string) {
+	marshalled := needsJSMarshalling(sig)
+	results := sig.Results()
+	if marshalled && hasErrorResult(sig) {
+		doc += "\n@throws {Error} The error returned by the Go function.\n"
+	}
+
+	result := "void"
+	for i := 0; i < results.Len(); i++ {
+		typ := results.At(i).Type()
+		if marshalled && isErrorType(typ) {
+			continue
+		}
+		result = g.tsType(typ, marshalled, true, "\t")
+		if result == "" {
+			fmt.Fprintf(&g.funcs, "\t// %s: result of type %s cannot be returned to JavaScript\n", name, typ)
+			return
+		}
+	}
+
+	var params []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		paramName := param.Name()
+		if paramName == "" || paramName == "_" {
+			paramName = "p" + strconv.Itoa(i)
+		}
+		typ := g.tsType(param.Type(), marshalled, false, "\t")
+		if typ == "" {
+			fmt.Fprintf(&g.funcs, "\t// %s: parameter of type %s cannot be passed from JavaScript\n", name, param.Type())
+			return
+		}
+		params = append(params, paramName+": "+typ)
+	}
+
+	writeDocComment(&g.funcs, "\t", doc)
+	fmt.Fprintf(&g.funcs, "\t%s(%s): %s;\n", tsPropertyName(name), strings.Join(params, ", "), result)
+}
+
+// tsType returns the TypeScript type for the given Go type, or the empty
+// string if it can't be used. Marshalled values are converted by
+// wasm_exec.js, other values are passed directly as WebAssembly values.
+// Anonymous structs are written as object types with the given indent.
+func (g *typeScriptGenerator) tsType(t types.Type, marshalled, result bool, indent string) string {
+	if !marshalled {
+		switch t := t.Underlying().(type) {
+		case *types.Basic:
+			switch {
+			case t.Kind() == types.Bool && !result:
+				return "boolean"
+			case t.Info()&types.IsFloat != 0 || t.Kind() == types.Bool:
+				return "number"
+			case t.Info()&types.IsInteger != 0 || t.Kind() == types.UnsafePointer:
+				if g.sizeof(t) == 8 {
+					return "bigint"
+				}
+				return "number"
+			}
+		case *types.Pointer:
+			return "number" // address in linear memory
+		}
+		return ""
+	}
+
+	name := jsExportType(t, g.ptrSize)
+	switch {
+	case name == "":
+		return ""
+	case name == "bool":
+		return "boolean"
+	case name == "string":
+		return "string"
+	case name == "[]u8":
+		return "Uint8Array"
+	case name == "i64" || name == "u64":
+		return "bigint"
+	case name[0] == 'i' || name[0] == 'u' || name[0] == 'f':
+		return "number"
+	}
+	if named, ok := t.(*types.Named); ok && named.TypeArgs() == nil && named.Obj().Pkg() != nil {
+		return g.declareNamed(named)
+	}
+	return g.tsStruct(t.Underlying().(*types.Struct), indent)
+}
+
+// Size of an integer type in bytes.
+func (g *typeScriptGenerator) sizeof(t *types.Basic) int64 {
+	switch t.Kind() {
+	case types.Int, types.Uint, types.Uintptr, types.UnsafePointer:
+		return g.ptrSize
+	}
+	return jsIntBits[t.Kind()] / 8
+}
+
+// tsStruct returns an object type for a struct, with the given indent for
+// the fields.
+func (g *typeScriptGenerator) tsStruct(t *types.Struct, indent string) string {
+	if t.NumFields() == 0 {
+		return "{}"
+	}
+	buf := &strings.Builder{}
+	buf.WriteString("{\n")
+	for i := 0; i < t.NumFields(); i++ {
+		field := t.Field(i)
+		typ := g.tsType(field.Type(), true, false, indent+"\t")
+		fmt.Fprintf(buf, "%s\t%s: %s;\n", indent, jsFieldName(field, t.Tag(i)), typ)
+	}
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+// declareNamed declares an interface for a named struct type, if it hasn't
+// been declared yet, and returns its name.
+func (g *typeScriptGenerator) declareNamed(named *types.Named) string {
+	obj := named.Obj()
+	if name, ok := g.named[obj]; ok {
+		return name
+	}
+	name := obj.Name()
+	if g.names[name] {
+		// Two types with the same name in different packages.
+		name = obj.Pkg().Name() + "_" + name
+	}
+	g.named[obj] = name
+	g.names[name] = true
+	// Declare the field types first, so that all interfaces are declared in
+	// dependency order.
+	body := g.tsStruct(named.Underlying().(*types.Struct), "")
+	fmt.Fprintf(&g.interfaces, "\nexport interface %s %s\n", name, body)
+	return name
+}
+
+// tsPropertyName returns the name quoted if it isn't a valid identifier, for
+// example because it contains a dot.
+func tsPropertyName(name string) string {
+	for i, c := range name {
+		if !(c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i != 0 && '0' <= c && c <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// writeDocComment writes a JSDoc comment with the given text, if it isn't
+// empty.
+func writeDocComment(buf *bytes.Buffer, indent, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	buf.WriteString(indent + "/**\n")
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(strings.TrimRight(indent+" * "+line, " ") + "\n")
+	}
+	buf.WriteString(indent + " */\n")
+}
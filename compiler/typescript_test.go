@@ -0,0 +1,51 @@
+package compiler
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test the TypeScript declarations generated for //go:wasmexport functions.
+// Pass -update to update the expected output.
+func TestExportTypeScript(t *testing.T) {
+	t.Parallel()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "testdata/typescript.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal("could not parse test file:", err)
+	}
+	config := types.Config{
+		Sizes:    &types.StdSizes{WordSize: 4, MaxAlign: 8}, // wasm32
+		Importer: headerTestImporter{},
+	}
+	pkg, err := config.Check("main", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal("could not typecheck test file:", err)
+	}
+
+	g := newTypeScriptGenerator(config.Sizes)
+	g.addPackage(pkg, []*ast.File{file})
+	actual := g.declarations()
+
+	outPath := "testdata/typescript.d.ts"
+	if *flagUpdate {
+		err := os.WriteFile(outPath, actual, 0666)
+		if err != nil {
+			t.Error("failed to write updated output file:", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal("failed to read golden file:", err)
+	}
+	if strings.ReplaceAll(string(expected), "\r\n", "\n") != string(actual) {
+		t.Errorf("output does not match expected output:\n%s", actual)
+	}
+}
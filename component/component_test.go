@@ -0,0 +1,598 @@
+package component
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// testFunc is a function of a core module built for a test.
+type testFunc struct {
+	module, name string // import module and name, or export name
+	typ          funcType
+}
+
+// buildModule builds a core module with the given imports and exported
+// functions (that trap when called), plus an exported memory.
+func buildModule(imports, exports []testFunc) []byte {
+	var types coreTypes
+	var importItems, funcItems, exportItems, codeItems [][]byte
+	for _, f := range imports {
+		item := appendName(appendName(nil, f.module), f.name)
+		importItems = append(importItems, appendU32(append(item, 0x00), types.index(f.typ)))
+	}
+	for i, f := range exports {
+		funcItems = append(funcItems, appendU32(nil, types.index(f.typ)))
+		exportItems = append(exportItems, appendU32(append(appendName(nil, f.name), 0x00), uint32(len(imports)+i)))
+		codeItems = append(codeItems, []byte{0x03, 0x00, 0x00, 0x0b}) // unreachable
+	}
+	exportItems = append(exportItems, append(appendName(nil, "memory"), 0x02, 0x00))
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = appendVecSection(module, 1, types.section())
+	module = appendVecSection(module, 2, importItems)
+	module = appendVecSection(module, 3, funcItems)
+	module = appendVecSection(module, 5, [][]byte{{0x00, 0x01}})
+	module = appendVecSection(module, 7, exportItems)
+	module = appendVecSection(module, 10, codeItems)
+	return module
+}
+
+func sig(params, results string) funcType {
+	parse := func(s string) []byte {
+		var types []byte
+		for _, name := range strings.Fields(s) {
+			types = append(types, map[string]byte{"i32": valI32, "i64": valI64, "f32": valF32, "f64": valF64}[name])
+		}
+		return types
+	}
+	return funcType{params: parse(params), results: parse(results)}
+}
+
+var (
+	appImports = []testFunc{
+		{"example:app/host", "log", sig("i32 i32", "")},
+		{"example:app/host", "now", sig("", "i64")},
+		{"example:app/host", "[constructor]counter", sig("i32", "i32")},
+		{"example:app/host", "[method]counter.get", sig("i32", "i32")},
+		{"example:app/host", "[resource-drop]counter", sig("i32", "")},
+		{"$root", "tick", sig("", "i32")},
+		{"[export]example:app/api", "[resource-new]handle", sig("i32", "i32")},
+	}
+	appExports = []testFunc{
+		{"", "example:app/api#greet", sig("i32 i32", "i32")},
+		{"", "cabi_post_example:app/api#greet", sig("i32", "")},
+		{"", "example:app/api#add", sig("i32 i32 i32 i32", "i32")},
+		{"", "example:app/api#wrap", sig("i32", "i32")},
+		{"", "example:app/api#[dtor]handle", sig("i32", "")},
+		{"", "run", sig("", "")},
+		{"", "cabi_realloc", sig("i32 i32 i32 i32", "i32")},
+		{"", "_initialize", sig("", "")},
+	}
+)
+
+func loadApp(t *testing.T) (*wit.Resolve, *wit.World) {
+	t.Helper()
+	res, err := wit.Load("testdata/app.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := res.World("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, world
+}
+
+func TestEncode(t *testing.T) {
+	res, world := loadApp(t)
+	data, err := Encode(buildModule(appImports, appExports), res, world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := decodeComponent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"example:app/host", "tick"}; !reflect.DeepEqual(c.imports, expected) {
+		t.Errorf("expected imports %v, got %v", expected, c.imports)
+	}
+	if expected := []string{"example:app/api", "run"}; !reflect.DeepEqual(c.exports, expected) {
+		t.Errorf("expected exports %v, got %v", expected, c.exports)
+	}
+	// The main module, the shim and the fixup module.
+	if c.coreModules != 3 {
+		t.Errorf("expected 3 core modules, got %d", c.coreModules)
+	}
+	// The nested component that exports the api interface.
+	if len(c.components) != 1 {
+		t.Fatalf("expected 1 nested component, got %d", len(c.components))
+	}
+	nested := c.components[0]
+	if expected := []string{"add", "counter", "greet", "handle", "point", "wrap"}; !reflect.DeepEqual(sorted(nested.exports), expected) {
+		t.Errorf("expected nested exports %v, got %v", expected, nested.exports)
+	}
+}
+
+// Check that the encoded component is accepted by wasm-tools, if it is
+// installed.
+func TestValidate(t *testing.T) {
+	if _, err := exec.LookPath("wasm-tools"); err != nil {
+		t.Skip("wasm-tools not found:", err)
+	}
+	res, world := loadApp(t)
+	data, err := Encode(buildModule(appImports, appExports), res, world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.wasm")
+	if err := os.WriteFile(path, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command("wasm-tools", "validate", "--features", "component-model", path).CombinedOutput()
+	if err != nil {
+		t.Errorf("wasm-tools validate failed: %v\n%s", err, output)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	res, world := loadApp(t)
+	imports := append([]testFunc{
+		{"example:app/host", "now", sig("", "i32")},
+		{"example:app/other", "f", sig("", "")},
+		{"example:app/host", "missing", sig("", "")},
+		{"example:app/host", "[resource-new]counter", sig("i32", "i32")},
+	}, appImports[5:]...)
+	var exports []testFunc
+	for _, f := range appExports {
+		if f.name != "example:app/api#add" && f.name != "cabi_realloc" {
+			exports = append(exports, f)
+		}
+	}
+	_, err := Encode(buildModule(imports, exports), res, world)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	expected := []string{
+		"import example:app/host now: has signature () -> (i32), but the canonical ABI requires () -> (i64)",
+		"import example:app/other f: interface example:app/other is not imported by world example:app/app",
+		"import example:app/host missing: function missing is not defined in interface example:app/host",
+		"import example:app/host [resource-new]counter: [resource-new] is only available for exported resources, import it from [export]example:app/host",
+		"export example:app/api#add: world example:app/app exports this function, but the module does not",
+		"export cabi_realloc: the module must export cabi_realloc to receive values of world example:app/app",
+	}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("unexpected errors:\n%s", strings.Join(msgs, "\n"))
+	}
+}
+
+func sorted(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
+
+// decodedComponent is the result of decoding a component in a test. While
+// decoding, all indices are checked against the size of their index space.
+type decodedComponent struct {
+	imports, exports []string
+	coreModules      int
+	components       []*decodedComponent
+
+	coreInstances, coreFuncs, coreTables, coreMemories int
+	types, funcs, instances                            int
+}
+
+func decodeComponent(data []byte) (*decodedComponent, error) {
+	r := &reader{data: data}
+	if string(r.bytes(8)) != preamble {
+		return nil, errors.New("invalid preamble")
+	}
+	c := &decodedComponent{}
+	for r.err == nil && len(r.data) > 0 {
+		id := r.byte()
+		section := &reader{data: r.bytes(int(r.u32()))}
+		var err error
+		switch id {
+		case sectionCoreModule:
+			if !strings.HasPrefix(string(section.data), "\x00asm\x01\x00\x00\x00") {
+				return nil, fmt.Errorf("core module %d: invalid preamble", c.coreModules)
+			}
+			c.coreModules++
+			continue
+		case sectionComponent:
+			nested, err := decodeComponent(section.data)
+			if err != nil {
+				return nil, fmt.Errorf("component %d: %w", len(c.components), err)
+			}
+			c.components = append(c.components, nested)
+			continue
+		}
+		for n := section.u32(); n > 0 && err == nil; n-- {
+			switch id {
+			case sectionCoreInstance:
+				err = c.coreInstance(section)
+			case sectionInstance:
+				err = c.instance(section)
+			case sectionAlias:
+				err = c.alias(section)
+			case sectionType:
+				err = c.defType(section, &c.types)
+				c.types++
+			case sectionCanon:
+				err = c.canon(section)
+			case sectionImport:
+				c.imports = append(c.imports, c.externName(section))
+				err = c.externDesc(section)
+			case sectionExport:
+				c.exports = append(c.exports, c.externName(section))
+				if err = c.sortIndex(section, true); err == nil && section.byte() == 0x01 {
+					err = c.externDesc(section)
+				}
+			default:
+				err = fmt.Errorf("unexpected section %d", id)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if section.err != nil {
+			return nil, section.err
+		}
+		if len(section.data) != 0 {
+			return nil, fmt.Errorf("section %d has %d trailing bytes", id, len(section.data))
+		}
+	}
+	return c, r.err
+}
+
+func checkIndex(kind string, index uint32, size int) error {
+	if int(index) >= size {
+		return fmt.Errorf("%s index %d out of range (%d defined)", kind, index, size)
+	}
+	return nil
+}
+
+func (c *decodedComponent) externName(r *reader) string {
+	if r.byte() != 0x00 {
+		r.err = errors.New("expected a plain name")
+	}
+	return r.name()
+}
+
+// coreSort returns the index space of a core sort.
+func (c *decodedComponent) coreSort(sort byte) (*int, string) {
+	switch sort {
+	case sortCoreFunc:
+		return &c.coreFuncs, "core func"
+	case sortCoreTable:
+		return &c.coreTables, "core table"
+	case sortCoreMemory:
+		return &c.coreMemories, "core memory"
+	case 0x12:
+		return &c.coreInstances, "core instance"
+	}
+	return nil, ""
+}
+
+func (c *decodedComponent) sort(sort byte) (*int, string) {
+	switch sort {
+	case sortFunc:
+		return &c.funcs, "func"
+	case sortType:
+		return &c.types, "type"
+	case 0x04:
+		n := len(c.components)
+		return &n, "component"
+	case sortInstance:
+		return &c.instances, "instance"
+	}
+	return nil, ""
+}
+
+// sortIndex reads a sort and index. Exports add a new item of their sort.
+func (c *decodedComponent) sortIndex(r *reader, export bool) error {
+	space, kind := c.sort(r.byte())
+	if space == nil {
+		return errors.New("invalid sort")
+	}
+	if err := checkIndex(kind, r.u32(), *space); err != nil {
+		return err
+	}
+	if export {
+		*space++
+	}
+	return nil
+}
+
+func (c *decodedComponent) coreInstance(r *reader) error {
+	switch r.byte() {
+	case 0x00:
+		if err := checkIndex("core module", r.u32(), c.coreModules); err != nil {
+			return err
+		}
+		for n := r.u32(); n > 0; n-- {
+			r.name()
+			if r.byte() != 0x12 {
+				return errors.New("expected a core instance argument")
+			}
+			if err := checkIndex("core instance", r.u32(), c.coreInstances); err != nil {
+				return err
+			}
+		}
+	case 0x01:
+		for n := r.u32(); n > 0; n-- {
+			r.name()
+			space, kind := c.coreSort(r.byte())
+			if space == nil {
+				return errors.New("invalid core sort")
+			}
+			if err := checkIndex(kind, r.u32(), *space); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("invalid core instance")
+	}
+	c.coreInstances++
+	return nil
+}
+
+func (c *decodedComponent) instance(r *reader) error {
+	switch r.byte() {
+	case 0x00:
+		if err := checkIndex("component", r.u32(), len(c.components)); err != nil {
+			return err
+		}
+		for n := r.u32(); n > 0; n-- {
+			r.name()
+			if err := c.sortIndex(r, false); err != nil {
+				return err
+			}
+		}
+	case 0x01:
+		for n := r.u32(); n > 0; n-- {
+			c.externName(r)
+			if err := c.sortIndex(r, false); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("invalid instance")
+	}
+	c.instances++
+	return nil
+}
+
+func (c *decodedComponent) alias(r *reader) error {
+	var space *int
+	sort := r.byte()
+	if sort == 0x00 {
+		space, _ = c.coreSort(r.byte())
+	} else {
+		space, _ = c.sort(sort)
+	}
+	if space == nil {
+		return errors.New("invalid alias sort")
+	}
+	switch r.byte() {
+	case 0x00:
+		if err := checkIndex("instance", r.u32(), c.instances); err != nil {
+			return err
+		}
+		r.name()
+	case 0x01:
+		if err := checkIndex("core instance", r.u32(), c.coreInstances); err != nil {
+			return err
+		}
+		r.name()
+	default:
+		return errors.New("outer aliases are only expected in instance types")
+	}
+	*space++
+	return nil
+}
+
+func (c *decodedComponent) canon(r *reader) error {
+	switch r.byte() {
+	case 0x00: // lift
+		r.byte()
+		if err := checkIndex("core func", r.u32(), c.coreFuncs); err != nil {
+			return err
+		}
+		if err := c.canonOpts(r); err != nil {
+			return err
+		}
+		if err := checkIndex("type", r.u32(), c.types); err != nil {
+			return err
+		}
+		c.funcs++
+	case 0x01: // lower
+		r.byte()
+		if err := checkIndex("func", r.u32(), c.funcs); err != nil {
+			return err
+		}
+		if err := c.canonOpts(r); err != nil {
+			return err
+		}
+		c.coreFuncs++
+	case 0x02, 0x03, 0x04: // resource.new, resource.drop, resource.rep
+		if err := checkIndex("type", r.u32(), c.types); err != nil {
+			return err
+		}
+		c.coreFuncs++
+	default:
+		return errors.New("invalid canonical function")
+	}
+	return nil
+}
+
+func (c *decodedComponent) canonOpts(r *reader) error {
+	for n := r.u32(); n > 0; n-- {
+		switch r.byte() {
+		case optMemory:
+			if err := checkIndex("core memory", r.u32(), c.coreMemories); err != nil {
+				return err
+			}
+		case optRealloc, optPostReturn:
+			if err := checkIndex("core func", r.u32(), c.coreFuncs); err != nil {
+				return err
+			}
+		default:
+			return errors.New("unexpected canonical option")
+		}
+	}
+	return nil
+}
+
+func (c *decodedComponent) externDesc(r *reader) error {
+	switch r.byte() {
+	case 0x01:
+		if err := checkIndex("type", r.u32(), c.types); err != nil {
+			return err
+		}
+		c.funcs++
+	case 0x03:
+		if r.byte() == 0x00 { // eq, otherwise sub resource
+			if err := checkIndex("type", r.u32(), c.types); err != nil {
+				return err
+			}
+		}
+		c.types++
+	case 0x05:
+		if err := checkIndex("type", r.u32(), c.types); err != nil {
+			return err
+		}
+		c.instances++
+	default:
+		return errors.New("unexpected extern descriptor")
+	}
+	return nil
+}
+
+// defType reads a type definition. The number of types defined so far is
+// passed by reference, as instance types have their own index space.
+func (c *decodedComponent) defType(r *reader, types *int) error {
+	valType := func() error {
+		// Primitive types are encoded as negative numbers in one byte.
+		if len(r.data) > 0 && r.data[0] >= 0x73 && r.data[0] <= 0x7f {
+			r.byte()
+			return nil
+		}
+		return checkIndex("type", r.u32(), *types)
+	}
+	optValType := func() error {
+		if r.byte() == 0x01 {
+			return valType()
+		}
+		return nil
+	}
+	labels := func(withType bool) error {
+		for n := r.u32(); n > 0; n-- {
+			r.name()
+			if withType {
+				if err := valType(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	switch kind := r.byte(); kind {
+	case 0x72: // record
+		return labels(true)
+	case 0x71: // variant
+		for n := r.u32(); n > 0; n-- {
+			r.name()
+			if err := optValType(); err != nil {
+				return err
+			}
+			r.byte()
+		}
+	case 0x70, 0x6b: // list, option
+		return valType()
+	case 0x6f: // tuple
+		for n := r.u32(); n > 0; n-- {
+			if err := valType(); err != nil {
+				return err
+			}
+		}
+	case 0x6e, 0x6d: // flags, enum
+		return labels(false)
+	case 0x6a: // result
+		if err := optValType(); err != nil {
+			return err
+		}
+		return optValType()
+	case 0x69, 0x68: // own, borrow
+		return checkIndex("type", r.u32(), *types)
+	case 0x40: // func
+		if err := labels(true); err != nil {
+			return err
+		}
+		if r.byte() == 0x00 {
+			return valType()
+		}
+		r.byte()
+	case 0x3f: // resource
+		r.byte()
+		if r.byte() == 0x01 {
+			return checkIndex("core func", r.u32(), c.coreFuncs)
+		}
+	case 0x42: // instance
+		var localTypes int
+		for n := r.u32(); n > 0; n-- {
+			switch r.byte() {
+			case 0x01:
+				if err := c.defType(r, &localTypes); err != nil {
+					return err
+				}
+				localTypes++
+			case 0x02:
+				if r.byte() != sortType || r.byte() != 0x02 || r.u32() != 1 {
+					return errors.New("expected an outer type alias")
+				}
+				if err := checkIndex("outer type", r.u32(), *types); err != nil {
+					return err
+				}
+				localTypes++
+			case 0x04:
+				c.externName(r)
+				switch r.byte() {
+				case 0x01:
+					if err := checkIndex("type", r.u32(), localTypes); err != nil {
+						return err
+					}
+				case 0x03:
+					if r.byte() == 0x00 {
+						if err := checkIndex("type", r.u32(), localTypes); err != nil {
+							return err
+						}
+					}
+					localTypes++
+				default:
+					return errors.New("unexpected export in instance type")
+				}
+			default:
+				return errors.New("unexpected instance type declaration")
+			}
+		}
+	default:
+		if kind < 0x73 {
+			return fmt.Errorf("unexpected type 0x%02x", kind)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,959 @@
+// Package component encodes WebAssembly components, by wrapping a core module
+// with the imports and exports of a WIT world. See:
+// https://github.com/WebAssembly/component-model/blob/main/design/mvp/Binary.md
+//
+// The core module uses the naming conventions of the canonical ABI, which are
+// also used by //go:wasmimport and //go:wasmexport: functions are imported
+// from the interface they belong to (like "wasi:cli/environment@0.2.0"), or
+// "$root" for functions imported by the world itself, and exported as
+// "wasi:cli/run@0.2.0#run". Resources are managed through the
+// "[resource-drop]name" intrinsics, and "[resource-new]name" and
+// "[resource-rep]name" imported from "[export]wasi:pkg/iface" for resources
+// that are exported.
+package component
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// Error is a mismatch between an import or export of the core module and the
+// world it is encoded for.
+type Error struct {
+	Module string // import module, empty for exports
+	Name   string // import or export name
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	if e.Module == "" {
+		return fmt.Sprintf("export %s: %s", e.Name, e.Msg)
+	}
+	return fmt.Sprintf("import %s %s: %s", e.Module, e.Name, e.Msg)
+}
+
+// Errors is a list of problems found while encoding a component.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Section IDs.
+const (
+	sectionCoreModule   = 1
+	sectionCoreInstance = 2
+	sectionComponent    = 4
+	sectionInstance     = 5
+	sectionAlias        = 6
+	sectionType         = 7
+	sectionCanon        = 8
+	sectionImport       = 10
+	sectionExport       = 11
+)
+
+// Sorts.
+const (
+	sortCoreFunc   = 0x00
+	sortCoreTable  = 0x01
+	sortCoreMemory = 0x02
+	sortFunc       = 0x01
+	sortType       = 0x03
+	sortInstance   = 0x05
+)
+
+// Canonical ABI options.
+const (
+	optMemory     = 0x03
+	optRealloc    = 0x04
+	optPostReturn = 0x05
+)
+
+const preamble = "\x00asm\x0d\x00\x01\x00"
+
+// sections accumulates the sections of a component. Consecutive items of the
+// same kind are merged into a single section.
+type sections struct {
+	buf   []byte
+	id    byte
+	items [][]byte
+}
+
+func (s *sections) add(id byte, item []byte) {
+	if id != s.id {
+		s.flush()
+		s.id = id
+	}
+	s.items = append(s.items, item)
+}
+
+func (s *sections) flush() {
+	s.buf = appendVecSection(s.buf, s.id, s.items)
+	s.items = nil
+}
+
+// raw adds a section that is not a vector, like a nested module.
+func (s *sections) raw(id byte, contents []byte) {
+	s.flush()
+	s.buf = appendSection(s.buf, id, contents)
+}
+
+func (s *sections) bytes() []byte {
+	s.flush()
+	return s.buf
+}
+
+// importFunc is a function imported by the core module.
+type importFunc struct {
+	coreImport
+	key      string        // world key of the interface, or "" for "$root"
+	fn       *wit.Function // function to lower, if op is empty
+	op       string        // resource intrinsic: "new", "rep" or "drop"
+	resource int           // type ID of the resource, for intrinsics
+	shim     int           // index in the shim module, or -1
+	index    uint32        // core function index
+}
+
+// exportFunc is a function exported by the world.
+type exportFunc struct {
+	name       string // core export name
+	fn         *wit.Function
+	postReturn bool
+}
+
+type encoder struct {
+	res    *wit.Resolve
+	world  *wit.World
+	module *coreModule
+	errs   Errors
+
+	imports  []*importFunc
+	exports  map[string][]*exportFunc // by world key, "" for world functions
+	dtors    map[int]string           // core export name of resource destructors
+	memory   bool                     // whether memory is needed
+	realloc  bool                     // whether cabi_realloc is needed
+	ifaceKey map[int]string           // world keys of imported interfaces
+	exported map[int]bool             // exported interfaces
+
+	s         sections
+	comp      *typeScope
+	types     map[int]uint32    // type indices at the component level
+	instances map[string]uint32 // instance indices of imported interfaces
+	dtorFunc  map[int]uint32    // core function index of resource destructors
+
+	numTypes, numFuncs, numInstances, numComponents uint32
+	numCoreModules, numCoreInstances, numCoreFuncs  uint32
+}
+
+// Encode wraps a core module in a component that targets the given world.
+// Mismatches between the core module and the world are returned as Errors.
+func Encode(module []byte, res *wit.Resolve, world *wit.World) ([]byte, error) {
+	m, err := readModule(module)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{
+		res:       res,
+		world:     world,
+		module:    m,
+		exports:   make(map[string][]*exportFunc),
+		dtors:     make(map[int]string),
+		ifaceKey:  make(map[int]string),
+		exported:  make(map[int]bool),
+		types:     make(map[int]uint32),
+		instances: make(map[string]uint32),
+		dtorFunc:  make(map[int]uint32),
+	}
+	for key, item := range world.Imports {
+		if item.Interface != nil {
+			e.ifaceKey[*item.Interface] = key
+		}
+	}
+	for _, item := range world.Exports {
+		if item.Interface != nil {
+			e.exported[*item.Interface] = true
+		}
+	}
+	e.checkImports()
+	e.checkExports()
+	if len(e.errs) != 0 {
+		return nil, e.errs
+	}
+	if err := e.encode(module); err != nil {
+		return nil, err
+	}
+	return e.s.bytes(), nil
+}
+
+func (e *encoder) errorf(module, name, format string, args ...interface{}) {
+	e.errs = append(e.errs, &Error{Module: module, Name: name, Msg: fmt.Sprintf(format, args...)})
+}
+
+// checkImports matches the imports of the core module with the world.
+func (e *encoder) checkImports() {
+	worldName := e.res.WorldName(e.world)
+	for _, imp := range e.module.imports {
+		f := &importFunc{coreImport: imp, shim: -1}
+		var iface *wit.Interface
+		var exported bool
+		switch {
+		case imp.module == "$root":
+		case strings.HasPrefix(imp.module, "[export]"):
+			key := strings.TrimPrefix(imp.module, "[export]")
+			item, ok := e.world.Exports[key]
+			if !ok || item.Interface == nil {
+				e.errorf(imp.module, imp.name, "interface %s is not exported by world %s", key, worldName)
+				continue
+			}
+			iface = e.res.Interfaces[*item.Interface]
+			f.key = key
+			exported = true
+		default:
+			item, ok := e.world.Imports[imp.module]
+			if !ok || item.Interface == nil {
+				e.errorf(imp.module, imp.name, "interface %s is not imported by world %s", imp.module, worldName)
+				continue
+			}
+			iface = e.res.Interfaces[*item.Interface]
+			f.key = imp.module
+		}
+
+		var expected funcType
+		if op, name, ok := resourceIntrinsic(imp.name); ok {
+			f.op = op
+			id, ok := e.lookupResource(iface, name)
+			if !ok {
+				e.errorf(imp.module, imp.name, "resource %s is not defined", name)
+				continue
+			}
+			if op != "drop" && !exported {
+				e.errorf(imp.module, imp.name, "[resource-%s] is only available for exported resources, import it from [export]%s", op, imp.module)
+				continue
+			}
+			f.resource = id
+			expected = funcType{params: []byte{valI32}}
+			if op != "drop" {
+				expected.results = []byte{valI32}
+			}
+		} else {
+			if exported {
+				e.errorf(imp.module, imp.name, "only resource intrinsics can be imported from exported interfaces")
+				continue
+			}
+			if iface == nil {
+				item, ok := e.world.Imports[imp.name]
+				if !ok || item.Function == nil {
+					e.errorf(imp.module, imp.name, "function %s is not imported by world %s", imp.name, worldName)
+					continue
+				}
+				f.fn = item.Function
+			} else {
+				f.fn = iface.Functions[imp.name]
+				if f.fn == nil {
+					e.errorf(imp.module, imp.name, "function %s is not defined in interface %s", imp.name, imp.module)
+					continue
+				}
+			}
+			expected = lowerType(e.res, f.fn)
+			if usesMemory(e.res, f.fn) {
+				e.memory = true
+			}
+			if needsRealloc(e.res, f.fn, false) {
+				e.realloc = true
+			}
+		}
+		if !imp.typ.equal(expected) {
+			e.errorf(imp.module, imp.name, "has signature %s, but the canonical ABI requires %s", imp.typ, expected)
+			continue
+		}
+		e.imports = append(e.imports, f)
+	}
+}
+
+// resourceIntrinsic splits an import name like "[resource-drop]name".
+func resourceIntrinsic(name string) (op, resource string, ok bool) {
+	for _, op := range []string{"new", "rep", "drop"} {
+		prefix := "[resource-" + op + "]"
+		if strings.HasPrefix(name, prefix) {
+			return op, name[len(prefix):], true
+		}
+	}
+	return "", "", false
+}
+
+// lookupResource finds a resource in an interface, or in the world if iface
+// is nil.
+func (e *encoder) lookupResource(iface *wit.Interface, name string) (int, bool) {
+	var id int
+	if iface != nil {
+		var ok bool
+		if id, ok = iface.Types[name]; !ok {
+			return 0, false
+		}
+	} else {
+		item, ok := e.world.Imports[name]
+		if !ok || item.Type == nil {
+			return 0, false
+		}
+		id = *item.Type
+	}
+	_, def := e.res.Underlying(wit.Type{ID: id})
+	if def == nil || def.Kind.Kind != "resource" {
+		return 0, false
+	}
+	return id, true
+}
+
+// checkExports checks that the core module exports all functions of the
+// world.
+func (e *encoder) checkExports() {
+	worldName := e.res.WorldName(e.world)
+	for _, key := range sortedKeys(e.world.Exports) {
+		item := e.world.Exports[key]
+		switch {
+		case item.Function != nil:
+			e.checkExport("", key, item.Function, worldName)
+		case item.Interface != nil:
+			iface := e.res.Interfaces[*item.Interface]
+			for _, name := range sortedKeys(iface.Functions) {
+				e.checkExport(key, key+"#"+name, iface.Functions[name], worldName)
+			}
+			for _, id := range sortedTypes(iface.Types) {
+				def := e.res.Types[id]
+				if def.Kind.Kind != "resource" {
+					continue
+				}
+				name := key + "#[dtor]" + *def.Name
+				typ, ok := e.module.funcs[name]
+				if !ok {
+					continue
+				}
+				expected := funcType{params: []byte{valI32}}
+				if !typ.equal(expected) {
+					e.errorf("", name, "has signature %s, but a destructor must have signature %s", typ, expected)
+					continue
+				}
+				e.dtors[id] = name
+			}
+		}
+	}
+	if e.memory && !e.module.memory {
+		e.errorf("", "memory", "the module must export its memory to pass values of world %s through memory", worldName)
+	}
+	if _, ok := e.module.funcs["cabi_realloc"]; e.realloc && !ok {
+		e.errorf("", "cabi_realloc", "the module must export cabi_realloc to receive values of world %s", worldName)
+	} else if typ := e.module.funcs["cabi_realloc"]; ok && !typ.equal(reallocType) {
+		e.errorf("", "cabi_realloc", "has signature %s, but must have signature %s", typ, reallocType)
+	}
+}
+
+var reallocType = funcType{params: []byte{valI32, valI32, valI32, valI32}, results: []byte{valI32}}
+
+func (e *encoder) checkExport(key, name string, fn *wit.Function, worldName string) {
+	typ, ok := e.module.funcs[name]
+	if !ok {
+		e.errorf("", name, "world %s exports this function, but the module does not", worldName)
+		return
+	}
+	expected := liftType(e.res, fn)
+	if !typ.equal(expected) {
+		e.errorf("", name, "has signature %s, but the canonical ABI requires %s", typ, expected)
+		return
+	}
+	f := &exportFunc{name: name, fn: fn}
+	if typ, ok := e.module.funcs["cabi_post_"+name]; ok {
+		expected := funcType{params: expected.results}
+		if !typ.equal(expected) {
+			e.errorf("", "cabi_post_"+name, "has signature %s, but must have signature %s", typ, expected)
+			return
+		}
+		f.postReturn = true
+	}
+	if usesMemory(e.res, fn) {
+		e.memory = true
+	}
+	if needsRealloc(e.res, fn, true) {
+		e.realloc = true
+	}
+	e.exports[key] = append(e.exports[key], f)
+}
+
+// encode writes the component. The main module needs the memory of its own
+// instance for most lowered imports, so these are passed through a shim
+// module that calls them indirectly through a table, which is filled in after
+// the main module is instantiated. The same is done for the destructors of
+// exported resources, which are needed to define the resource types.
+func (e *encoder) encode(module []byte) error {
+	e.s.buf = []byte(preamble)
+	e.comp = &typeScope{
+		res:     e.res,
+		indices: e.types,
+		outer: func(id int, def *wit.TypeDef) (uint32, bool, error) {
+			if def.Name != nil && def.Owner.Interface != nil && !e.exported[*def.Owner.Interface] {
+				return 0, false, fmt.Errorf("type %s is used, but its interface %s is not imported", *def.Name, e.res.InterfaceKey(*def.Owner.Interface))
+			}
+			return 0, false, nil
+		},
+		define: e.defineType,
+		name: func(id int, def *wit.TypeDef, index uint32) (uint32, error) {
+			if def.Owner.World != nil {
+				return e.importType(*def.Name, appendU32([]byte{0x00}, index)), nil
+			}
+			return index, nil
+		},
+		resource: func(id int, def *wit.TypeDef) (uint32, error) {
+			if def.Owner.World != nil {
+				return e.importType(*def.Name, []byte{0x01}), nil
+			}
+			resource := []byte{0x3f, valI32, 0x00}
+			if index, ok := e.dtorFunc[id]; ok {
+				resource = appendU32([]byte{0x3f, valI32, 0x01}, index)
+			}
+			return e.defineType(resource), nil
+		},
+	}
+
+	e.s.raw(sectionCoreModule, module)
+	mainModule := e.numCoreModules
+	e.numCoreModules++
+
+	if err := e.importInterfaces(); err != nil {
+		return err
+	}
+
+	// Import the functions of the world itself.
+	rootFuncs := make(map[string]uint32)
+	for _, f := range e.imports {
+		if f.key != "" || f.fn == nil {
+			continue
+		}
+		if _, ok := rootFuncs[f.name]; ok {
+			continue
+		}
+		typ, err := e.comp.funcType(f.fn)
+		if err != nil {
+			return err
+		}
+		index := e.defineType(typ)
+		e.s.add(sectionImport, appendU32(append(appendName([]byte{0x00}, f.name), sortFunc), index))
+		rootFuncs[f.name] = e.numFuncs
+		e.numFuncs++
+	}
+
+	// Create the shim module.
+	var entries []funcType
+	for _, f := range e.imports {
+		if f.fn != nil && usesMemory(e.res, f.fn) {
+			f.shim = len(entries)
+			entries = append(entries, f.typ)
+		}
+	}
+	dtorShims := make(map[int]int)
+	for _, id := range sortedDtors(e.dtors) {
+		dtorShims[id] = len(entries)
+		entries = append(entries, funcType{params: []byte{valI32}})
+	}
+	var shimInstance uint32
+	var shimFuncs []uint32
+	if len(entries) != 0 {
+		e.s.raw(sectionCoreModule, shimModule(entries))
+		e.s.add(sectionCoreInstance, append(appendU32([]byte{0x00}, e.numCoreModules), 0x00)) // no arguments
+		e.numCoreModules++
+		shimInstance = e.newCoreInstance()
+		for i := range entries {
+			shimFuncs = append(shimFuncs, e.aliasCoreExport(shimInstance, fmt.Sprint(i), sortCoreFunc))
+		}
+	}
+	for _, id := range sortedDtors(e.dtors) {
+		e.dtorFunc[id] = shimFuncs[dtorShims[id]]
+	}
+
+	// Define the types of exported interfaces, so that the resource
+	// intrinsics can refer to them.
+	for _, key := range sortedKeys(e.world.Exports) {
+		item := e.world.Exports[key]
+		if item.Interface == nil {
+			continue
+		}
+		for _, id := range sortedTypes(e.res.Interfaces[*item.Interface].Types) {
+			if _, err := e.comp.typeIndex(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Create the core functions that are imported by the main module.
+	instanceFuncs := make(map[string]uint32)
+	for _, f := range e.imports {
+		switch {
+		case f.shim >= 0:
+			f.index = shimFuncs[f.shim]
+		case f.fn != nil:
+			fn, err := e.importedFunc(f, rootFuncs, instanceFuncs)
+			if err != nil {
+				return err
+			}
+			f.index = e.canon(append(appendU32([]byte{0x01, 0x00}, fn), 0x00)) // no options
+		default:
+			resource, err := e.comp.typeIndex(f.resource)
+			if err != nil {
+				return err
+			}
+			code := map[string]byte{"new": 0x02, "drop": 0x03, "rep": 0x04}[f.op]
+			f.index = e.canon(appendU32([]byte{code}, resource))
+		}
+	}
+
+	// Instantiate the main module.
+	var modules []string
+	byModule := make(map[string][]*importFunc)
+	for _, f := range e.imports {
+		if byModule[f.module] == nil {
+			modules = append(modules, f.module)
+		}
+		byModule[f.module] = append(byModule[f.module], f)
+	}
+	sort.Strings(modules)
+	var args [][]byte
+	for _, module := range modules {
+		funcs := byModule[module]
+		item := appendU32([]byte{0x01}, uint32(len(funcs)))
+		for _, f := range funcs {
+			item = appendU32(append(appendName(item, f.name), sortCoreFunc), f.index)
+		}
+		e.s.add(sectionCoreInstance, item)
+		args = append(args, appendU32(append(appendName(nil, module), 0x12), e.newCoreInstance()))
+	}
+	item := appendU32(appendU32([]byte{0x00}, mainModule), uint32(len(args)))
+	for _, arg := range args {
+		item = append(item, arg...)
+	}
+	e.s.add(sectionCoreInstance, item)
+	mainInstance := e.newCoreInstance()
+
+	if e.module.memory {
+		e.aliasCoreExport(mainInstance, "memory", sortCoreMemory)
+	}
+	var realloc uint32
+	if _, ok := e.module.funcs["cabi_realloc"]; ok {
+		realloc = e.aliasCoreExport(mainInstance, "cabi_realloc", sortCoreFunc)
+	}
+	options := func(fn *wit.Function, lift bool, extra ...[]byte) []byte {
+		var opts [][]byte
+		if usesMemory(e.res, fn) {
+			opts = append(opts, appendU32([]byte{optMemory}, 0))
+		}
+		if needsRealloc(e.res, fn, lift) {
+			opts = append(opts, appendU32([]byte{optRealloc}, realloc))
+		}
+		opts = append(opts, extra...)
+		buf := appendU32(nil, uint32(len(opts)))
+		for _, opt := range opts {
+			buf = append(buf, opt...)
+		}
+		return buf
+	}
+
+	// Fill the table of the shim module, and initialize the main module.
+	fixup := make([]uint32, len(entries))
+	for _, f := range e.imports {
+		if f.shim < 0 {
+			continue
+		}
+		fn, err := e.importedFunc(f, rootFuncs, instanceFuncs)
+		if err != nil {
+			return err
+		}
+		fixup[f.shim] = e.canon(append(appendU32([]byte{0x01, 0x00}, fn), options(f.fn, false)...))
+	}
+	for _, id := range sortedDtors(e.dtors) {
+		fixup[dtorShims[id]] = e.aliasCoreExport(mainInstance, e.dtors[id], sortCoreFunc)
+	}
+	_, initialize := e.module.funcs["_initialize"]
+	if len(entries) != 0 || initialize {
+		var exports [][]byte
+		for i, index := range fixup {
+			exports = append(exports, appendU32(append(appendName(nil, fmt.Sprint(i)), sortCoreFunc), index))
+		}
+		if initialize {
+			index := e.aliasCoreExport(mainInstance, "_initialize", sortCoreFunc)
+			exports = append(exports, appendU32(append(appendName(nil, "_initialize"), sortCoreFunc), index))
+		}
+		if len(entries) != 0 {
+			e.aliasCoreExport(shimInstance, shimTable, sortCoreTable)
+			exports = append(exports, appendU32(append(appendName(nil, shimTable), sortCoreTable), 0))
+		}
+		item := appendU32([]byte{0x01}, uint32(len(exports)))
+		for _, export := range exports {
+			item = append(item, export...)
+		}
+		e.s.add(sectionCoreInstance, item)
+		fixupArgs := e.newCoreInstance()
+
+		e.s.raw(sectionCoreModule, fixupModule(entries, initialize))
+		item = appendU32([]byte{0x00}, e.numCoreModules)
+		item = appendU32(append(appendName(appendU32(item, 1), ""), 0x12), fixupArgs)
+		e.numCoreModules++
+		e.s.add(sectionCoreInstance, item)
+		e.newCoreInstance()
+	}
+
+	// Lift and export the functions of the world.
+	lift := func(f *exportFunc) (uint32, error) {
+		typ, err := e.comp.funcType(f.fn)
+		if err != nil {
+			return 0, err
+		}
+		typeIndex := e.defineType(typ)
+		core := e.aliasCoreExport(mainInstance, f.name, sortCoreFunc)
+		var postReturn [][]byte
+		if f.postReturn {
+			postReturn = append(postReturn, appendU32([]byte{optPostReturn}, e.aliasCoreExport(mainInstance, "cabi_post_"+f.name, sortCoreFunc)))
+		}
+		opts := options(f.fn, true, postReturn...)
+		e.s.add(sectionCanon, appendU32(append(appendU32([]byte{0x00, 0x00}, core), opts...), typeIndex))
+		e.numFuncs++
+		return e.numFuncs - 1, nil
+	}
+	for _, key := range sortedKeys(e.world.Exports) {
+		item := e.world.Exports[key]
+		if item.Function != nil {
+			var f *exportFunc
+			for _, export := range e.exports[""] {
+				if export.name == key {
+					f = export
+				}
+			}
+			fn, err := lift(f)
+			if err != nil {
+				return err
+			}
+			e.s.add(sectionExport, append(appendU32(append(appendName([]byte{0x00}, key), sortFunc), fn), 0x00))
+			e.numFuncs++
+			continue
+		}
+		funcs := make(map[string]uint32)
+		for _, f := range e.exports[key] {
+			fn, err := lift(f)
+			if err != nil {
+				return err
+			}
+			funcs[strings.TrimPrefix(f.name, key+"#")] = fn
+		}
+		if err := e.exportInterface(key, *item.Interface, funcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importInterfaces imports the interfaces that are used by the core module
+// or by exported interfaces, and aliases their types so that they can be used
+// in other types.
+func (e *encoder) importInterfaces() error {
+	var order []int
+	visited := make(map[int]bool)
+	var visit func(id int)
+	visit = func(id int) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		for _, dep := range e.res.InterfaceDeps(id) {
+			if e.ifaceKey[dep] != "" {
+				visit(dep)
+			}
+		}
+		order = append(order, id)
+	}
+	var used []int
+	for _, f := range e.imports {
+		if f.key != "" && !strings.HasPrefix(f.module, "[export]") {
+			used = append(used, *e.world.Imports[f.key].Interface)
+		}
+	}
+	for _, key := range sortedKeys(e.world.Exports) {
+		if item := e.world.Exports[key]; item.Interface != nil {
+			used = append(used, e.res.InterfaceDeps(*item.Interface)...)
+		}
+	}
+	sort.Ints(used)
+	for _, id := range used {
+		if e.ifaceKey[id] != "" {
+			visit(id)
+		}
+	}
+
+	for _, id := range order {
+		key := e.ifaceKey[id]
+		iface := e.res.Interfaces[id]
+		var decls [][]byte
+		var n uint32
+		decl := func(item []byte) uint32 {
+			decls = append(decls, item)
+			n++
+			return n - 1
+		}
+		export := func(name string, bound []byte) uint32 {
+			return decl(append(append(appendName([]byte{0x04, 0x00}, name), sortType), bound...))
+		}
+		scope := &typeScope{
+			res:     e.res,
+			indices: make(map[int]uint32),
+			outer: func(tid int, def *wit.TypeDef) (uint32, bool, error) {
+				if def.Name == nil || def.Owner.Interface == nil || *def.Owner.Interface == id {
+					return 0, false, nil
+				}
+				index, ok := e.types[tid]
+				if !ok {
+					return 0, false, fmt.Errorf("interface %s uses type %s of an interface that is not imported", key, *def.Name)
+				}
+				return decl(appendU32([]byte{0x02, sortType, 0x02, 0x01}, index)), true, nil
+			},
+			define: func(deftype []byte) uint32 {
+				return decl(append([]byte{0x01}, deftype...))
+			},
+			name: func(tid int, def *wit.TypeDef, index uint32) (uint32, error) {
+				return export(*def.Name, appendU32([]byte{0x00}, index)), nil
+			},
+			resource: func(tid int, def *wit.TypeDef) (uint32, error) {
+				return export(*def.Name, []byte{0x01}), nil
+			},
+		}
+		for _, tid := range sortedTypes(iface.Types) {
+			if _, err := scope.typeIndex(tid); err != nil {
+				return err
+			}
+		}
+		for _, name := range sortedKeys(iface.Functions) {
+			typ, err := scope.funcType(iface.Functions[name])
+			if err != nil {
+				return err
+			}
+			index := scope.define(typ)
+			decls = append(decls, appendU32(append(appendName([]byte{0x04, 0x00}, name), sortFunc), index))
+		}
+		instanceType := appendU32([]byte{0x42}, uint32(len(decls)))
+		for _, decl := range decls {
+			instanceType = append(instanceType, decl...)
+		}
+		typeIndex := e.defineType(instanceType)
+		e.s.add(sectionImport, appendU32(append(appendName([]byte{0x00}, key), sortInstance), typeIndex))
+		instance := e.numInstances
+		e.instances[key] = instance
+		e.numInstances++
+		for _, tid := range sortedTypes(iface.Types) {
+			e.s.add(sectionAlias, appendName(appendU32([]byte{sortType, 0x00}, instance), *e.res.Types[tid].Name))
+			e.types[tid] = e.numTypes
+			e.numTypes++
+		}
+	}
+	return nil
+}
+
+// importedFunc returns the component function index of an imported function,
+// aliasing it from its instance if needed.
+func (e *encoder) importedFunc(f *importFunc, rootFuncs, instanceFuncs map[string]uint32) (uint32, error) {
+	if f.key == "" {
+		return rootFuncs[f.name], nil
+	}
+	name := f.key + "#" + f.name
+	if index, ok := instanceFuncs[name]; ok {
+		return index, nil
+	}
+	instance, ok := e.instances[f.key]
+	if !ok {
+		return 0, fmt.Errorf("interface %s is not imported", f.key)
+	}
+	e.s.add(sectionAlias, appendName(appendU32([]byte{sortFunc, 0x00}, instance), f.name))
+	instanceFuncs[name] = e.numFuncs
+	e.numFuncs++
+	return e.numFuncs - 1, nil
+}
+
+// exportInterface exports an interface with the given lifted functions. The
+// types and functions are passed through a nested component that exports
+// them again, so that the exported instance only refers to exported types.
+func (e *encoder) exportInterface(key string, id int, funcs map[string]uint32) error {
+	iface := e.res.Interfaces[id]
+	c := sections{buf: []byte(preamble)}
+	var numTypes, numFuncs uint32
+	define := func(deftype []byte) uint32 {
+		c.add(sectionType, deftype)
+		numTypes++
+		return numTypes - 1
+	}
+
+	// Import all types that are used, as type arguments of the instance.
+	var args [][]byte
+	var numArgs uint32
+	usedNames := make(map[string]bool)
+	importType := func(tid int, def *wit.TypeDef, bound []byte) (uint32, error) {
+		name := "import-type-" + *def.Name
+		if usedNames[name] && def.Owner.Interface != nil {
+			name += "-" + *e.res.Interfaces[*def.Owner.Interface].Name
+		}
+		usedNames[name] = true
+		index, err := e.comp.typeIndex(tid)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, appendU32(append(appendName(nil, name), sortType), index))
+		numArgs++
+		c.add(sectionImport, append(append(appendName([]byte{0x00}, name), sortType), bound...))
+		numTypes++
+		return numTypes - 1, nil
+	}
+	imported := &typeScope{
+		res:     e.res,
+		indices: make(map[int]uint32),
+		outer: func(int, *wit.TypeDef) (uint32, bool, error) {
+			return 0, false, nil
+		},
+		define: define,
+		name: func(tid int, def *wit.TypeDef, index uint32) (uint32, error) {
+			return importType(tid, def, appendU32([]byte{0x00}, index))
+		},
+		resource: func(tid int, def *wit.TypeDef) (uint32, error) {
+			return importType(tid, def, []byte{0x01})
+		},
+	}
+	local := sortedTypes(iface.Types)
+	for _, tid := range local {
+		if _, err := imported.typeIndex(tid); err != nil {
+			return err
+		}
+	}
+	names := sortedKeys(iface.Functions)
+	importedFuncs := make([]uint32, len(names))
+	for i, name := range names {
+		typ, err := imported.funcType(iface.Functions[name])
+		if err != nil {
+			return err
+		}
+		index := define(typ)
+		importName := "import-func-" + strings.NewReplacer("[", "", "]", "-", ".", "-").Replace(name)
+		c.add(sectionImport, appendU32(append(appendName([]byte{0x00}, importName), sortFunc), index))
+		args = append(args, appendU32(append(appendName(nil, importName), sortFunc), funcs[name]))
+		numArgs++
+		importedFuncs[i] = numFuncs
+		numFuncs++
+	}
+
+	// Export the types of the interface, and the functions with types that
+	// refer to these exports.
+	exportedTypes := make(map[int]uint32)
+	for _, tid := range local {
+		name := *e.res.Types[tid].Name
+		c.add(sectionExport, append(appendU32(append(appendName([]byte{0x00}, name), sortType), imported.indices[tid]), 0x00))
+		exportedTypes[tid] = numTypes
+		numTypes++
+	}
+	exported := &typeScope{
+		res:     e.res,
+		indices: exportedTypes,
+		outer: func(tid int, def *wit.TypeDef) (uint32, bool, error) {
+			index, ok := imported.indices[tid]
+			return index, ok && def.Name != nil, nil
+		},
+		define: define,
+		name: func(tid int, def *wit.TypeDef, index uint32) (uint32, error) {
+			return index, nil
+		},
+		resource: func(tid int, def *wit.TypeDef) (uint32, error) {
+			return 0, fmt.Errorf("resource %s is not imported", *def.Name)
+		},
+	}
+	for i, name := range names {
+		typ, err := exported.funcType(iface.Functions[name])
+		if err != nil {
+			return err
+		}
+		index := define(typ)
+		c.add(sectionExport, appendU32(append(appendU32(append(appendName([]byte{0x00}, name), sortFunc), importedFuncs[i]), 0x01, sortFunc), index))
+	}
+
+	e.s.raw(sectionComponent, c.bytes())
+	item := appendU32(appendU32([]byte{0x00}, e.numComponents), numArgs)
+	for _, arg := range args {
+		item = append(item, arg...)
+	}
+	e.numComponents++
+	e.s.add(sectionInstance, item)
+	e.numInstances++
+	e.s.add(sectionExport, append(appendU32(append(appendName([]byte{0x00}, key), sortInstance), e.numInstances-1), 0x00))
+	e.numInstances++
+	return nil
+}
+
+func (e *encoder) defineType(deftype []byte) uint32 {
+	e.s.add(sectionType, deftype)
+	e.numTypes++
+	return e.numTypes - 1
+}
+
+// importType imports a type of the world with the given type bound.
+func (e *encoder) importType(name string, bound []byte) uint32 {
+	e.s.add(sectionImport, append(append(appendName([]byte{0x00}, name), sortType), bound...))
+	e.numTypes++
+	return e.numTypes - 1
+}
+
+// aliasCoreExport aliases an export of a core instance, and returns its index
+// in the index space of its sort. Only functions are counted, as the memory
+// and table are the only ones of their kind.
+func (e *encoder) aliasCoreExport(instance uint32, name string, sort byte) uint32 {
+	e.s.add(sectionAlias, appendName(appendU32([]byte{0x00, sort, 0x01}, instance), name))
+	if sort != sortCoreFunc {
+		return 0
+	}
+	e.numCoreFuncs++
+	return e.numCoreFuncs - 1
+}
+
+// canon adds a canonical function that creates a core function.
+func (e *encoder) canon(item []byte) uint32 {
+	e.s.add(sectionCanon, item)
+	e.numCoreFuncs++
+	return e.numCoreFuncs - 1
+}
+
+func (e *encoder) newCoreInstance() uint32 {
+	e.numCoreInstances++
+	return e.numCoreInstances - 1
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedTypes returns the type IDs of an interface in definition order.
+func sortedTypes(types map[string]int) []int {
+	ids := make([]int, 0, len(types))
+	for _, id := range types {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func sortedDtors(dtors map[int]string) []int {
+	ids := make([]int, 0, len(dtors))
+	for id := range dtors {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
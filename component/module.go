@@ -0,0 +1,362 @@
+package component
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Core WebAssembly value types.
+const (
+	valI32 = 0x7f
+	valI64 = 0x7e
+	valF32 = 0x7d
+	valF64 = 0x7c
+)
+
+// funcType is a core WebAssembly function type. The params and results are
+// value type bytes.
+type funcType struct {
+	params  []byte
+	results []byte
+}
+
+func (t funcType) String() string {
+	names := func(types []byte) string {
+		var s []string
+		for _, t := range types {
+			switch t {
+			case valI32:
+				s = append(s, "i32")
+			case valI64:
+				s = append(s, "i64")
+			case valF32:
+				s = append(s, "f32")
+			case valF64:
+				s = append(s, "f64")
+			default:
+				s = append(s, fmt.Sprintf("0x%02x", t))
+			}
+		}
+		return "(" + strings.Join(s, ", ") + ")"
+	}
+	return names(t.params) + " -> " + names(t.results)
+}
+
+func (t funcType) equal(other funcType) bool {
+	return string(t.params) == string(other.params) && string(t.results) == string(other.results)
+}
+
+// coreImport is a function imported by a core module.
+type coreImport struct {
+	module string
+	name   string
+	typ    funcType
+}
+
+// coreModule contains the parts of a core WebAssembly module that are needed
+// to wrap it in a component: its function imports and its exports.
+type coreModule struct {
+	imports []coreImport
+	funcs   map[string]funcType // exported functions
+	memory  bool                // whether "memory" is exported
+}
+
+// readModule reads the imports and exports of a core module.
+func readModule(data []byte) (*coreModule, error) {
+	r := &reader{data: data}
+	if string(r.bytes(4)) != "\x00asm" || string(r.bytes(4)) != "\x01\x00\x00\x00" {
+		return nil, errors.New("not a core WebAssembly module")
+	}
+	m := &coreModule{funcs: make(map[string]funcType)}
+	var types []funcType
+	var funcTypes []uint32 // type index of each function, including imports
+	for r.err == nil && len(r.data) > 0 {
+		id := r.byte()
+		section := &reader{data: r.bytes(int(r.u32()))}
+		switch id {
+		case 1: // type
+			for n := section.u32(); n > 0; n-- {
+				if form := section.byte(); form != 0x60 {
+					return nil, fmt.Errorf("unsupported type form 0x%02x", form)
+				}
+				var t funcType
+				t.params = section.bytes(int(section.u32()))
+				t.results = section.bytes(int(section.u32()))
+				types = append(types, t)
+			}
+		case 2: // import
+			for n := section.u32(); n > 0; n-- {
+				module := section.name()
+				name := section.name()
+				if kind := section.byte(); kind != 0x00 {
+					return nil, fmt.Errorf("module imports %s %q from %q, but only functions can be imported", externKindName(kind), name, module)
+				}
+				typeIndex := section.u32()
+				if int(typeIndex) >= len(types) {
+					return nil, fmt.Errorf("invalid type index %d", typeIndex)
+				}
+				m.imports = append(m.imports, coreImport{module: module, name: name, typ: types[typeIndex]})
+				funcTypes = append(funcTypes, typeIndex)
+			}
+		case 3: // function
+			for n := section.u32(); n > 0; n-- {
+				funcTypes = append(funcTypes, section.u32())
+			}
+		case 7: // export
+			for n := section.u32(); n > 0; n-- {
+				name := section.name()
+				kind := section.byte()
+				index := section.u32()
+				switch kind {
+				case 0x00:
+					if int(index) >= len(funcTypes) || int(funcTypes[index]) >= len(types) {
+						return nil, fmt.Errorf("invalid function index %d", index)
+					}
+					m.funcs[name] = types[funcTypes[index]]
+				case 0x02:
+					if name == "memory" {
+						m.memory = true
+					}
+				}
+			}
+		}
+		if section.err != nil {
+			return nil, section.err
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return m, nil
+}
+
+func externKindName(kind byte) string {
+	switch kind {
+	case 0x01:
+		return "table"
+	case 0x02:
+		return "memory"
+	case 0x03:
+		return "global"
+	case 0x04:
+		return "tag"
+	}
+	return "function"
+}
+
+// reader decodes the binary format. The first error is kept in err, after
+// which all reads return zero values.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = errors.New("unexpected end of module")
+	}
+	r.data = nil
+}
+
+func (r *reader) byte() byte {
+	if len(r.data) == 0 {
+		r.fail()
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n > len(r.data) {
+		r.fail()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) u32() uint32 {
+	var result uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b := r.byte()
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result
+		}
+	}
+	r.fail()
+	return 0
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+// Encoding helpers for the binary format.
+
+func appendU32(buf []byte, n uint32) []byte {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+// appendS64 appends a signed LEB128 number, which is also used for the s33
+// type indices in component value types.
+func appendS64(buf []byte, n int64) []byte {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && b&0x40 == 0) || (n == -1 && b&0x40 != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+func appendName(buf []byte, name string) []byte {
+	buf = appendU32(buf, uint32(len(name)))
+	return append(buf, name...)
+}
+
+// appendSection appends a section with the given ID and contents.
+func appendSection(buf []byte, id byte, contents []byte) []byte {
+	buf = append(buf, id)
+	buf = appendU32(buf, uint32(len(contents)))
+	return append(buf, contents...)
+}
+
+// appendVecSection appends a section with a vector of items.
+func appendVecSection(buf []byte, id byte, items [][]byte) []byte {
+	if len(items) == 0 {
+		return buf
+	}
+	contents := appendU32(nil, uint32(len(items)))
+	for _, item := range items {
+		contents = append(contents, item...)
+	}
+	return appendSection(buf, id, contents)
+}
+
+// coreTypes deduplicates the function types of a generated core module.
+type coreTypes struct {
+	types   []funcType
+	indices map[string]uint32
+}
+
+func (t *coreTypes) index(typ funcType) uint32 {
+	key := string(typ.params) + "/" + string(typ.results)
+	if index, ok := t.indices[key]; ok {
+		return index
+	}
+	if t.indices == nil {
+		t.indices = make(map[string]uint32)
+	}
+	index := uint32(len(t.types))
+	t.types = append(t.types, typ)
+	t.indices[key] = index
+	return index
+}
+
+func (t *coreTypes) section() [][]byte {
+	var items [][]byte
+	for _, typ := range t.types {
+		item := []byte{0x60}
+		item = appendU32(item, uint32(len(typ.params)))
+		item = append(item, typ.params...)
+		item = appendU32(item, uint32(len(typ.results)))
+		item = append(item, typ.results...)
+		items = append(items, item)
+	}
+	return items
+}
+
+const shimTable = "$imports"
+
+// shimModule returns a core module that exports a function for each of the
+// given types, named by their index. Each function calls the function at the
+// same index in the exported table, which is filled in later by the fixup
+// module. This breaks the cycle where lowered imports need the memory of the
+// main module, which in turn needs those imports to be instantiated.
+func shimModule(entries []funcType) []byte {
+	var types coreTypes
+	var funcs, exports, code [][]byte
+	for i, typ := range entries {
+		typeIndex := types.index(typ)
+		funcs = append(funcs, appendU32(nil, typeIndex))
+		exports = append(exports, append(appendName(nil, fmt.Sprint(i)), appendU32([]byte{0x00}, uint32(i))...))
+		var body []byte
+		body = append(body, 0x00) // no locals
+		for param := range typ.params {
+			body = appendU32(append(body, 0x20), uint32(param)) // local.get
+		}
+		body = appendS64(append(body, 0x41), int64(i))  // i32.const
+		body = appendU32(append(body, 0x11), typeIndex) // call_indirect
+		body = append(body, 0x00, 0x0b)                 // table 0, end
+		code = append(code, append(appendU32(nil, uint32(len(body))), body...))
+	}
+	table := []byte{0x70, 0x01}
+	table = appendU32(table, uint32(len(entries)))
+	table = appendU32(table, uint32(len(entries)))
+	exports = append(exports, append(appendName(nil, shimTable), 0x01, 0x00))
+
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = appendVecSection(module, 1, types.section())
+	module = appendVecSection(module, 3, funcs)
+	module = appendVecSection(module, 4, [][]byte{table})
+	module = appendVecSection(module, 7, exports)
+	module = appendVecSection(module, 10, code)
+	return module
+}
+
+// fixupModule returns a core module that fills the table of the shim module
+// with the imported functions, and then calls the imported _initialize
+// function (if any) as its start function.
+func fixupModule(entries []funcType, initialize bool) []byte {
+	var types coreTypes
+	var imports [][]byte
+	for i, typ := range entries {
+		item := appendName(appendName(nil, ""), fmt.Sprint(i))
+		item = appendU32(append(item, 0x00), types.index(typ))
+		imports = append(imports, item)
+	}
+	var start []byte
+	if initialize {
+		item := appendName(appendName(nil, ""), "_initialize")
+		item = appendU32(append(item, 0x00), types.index(funcType{}))
+		imports = append(imports, item)
+		start = appendU32(nil, uint32(len(entries)))
+	}
+	var elements [][]byte
+	if len(entries) > 0 {
+		item := appendName(appendName(nil, ""), shimTable)
+		item = append(item, 0x01, 0x70, 0x01)
+		item = appendU32(item, uint32(len(entries)))
+		item = appendU32(item, uint32(len(entries)))
+		imports = append(imports, item)
+
+		element := []byte{0x00, 0x41, 0x00, 0x0b} // table 0, offset (i32.const 0)
+		element = appendU32(element, uint32(len(entries)))
+		for i := range entries {
+			element = appendU32(element, uint32(i))
+		}
+		elements = append(elements, element)
+	}
+
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	module = appendVecSection(module, 1, types.section())
+	module = appendVecSection(module, 2, imports)
+	if start != nil {
+		module = appendSection(module, 8, start)
+	}
+	module = appendVecSection(module, 9, elements)
+	return module
+}
@@ -0,0 +1,320 @@
+package component
+
+import (
+	"fmt"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// Primitive value types.
+var primitives = map[string]byte{
+	"bool":    0x7f,
+	"s8":      0x7e,
+	"u8":      0x7d,
+	"s16":     0x7c,
+	"u16":     0x7b,
+	"s32":     0x7a,
+	"u32":     0x79,
+	"s64":     0x78,
+	"u64":     0x77,
+	"f32":     0x76,
+	"float32": 0x76,
+	"f64":     0x75,
+	"float64": 0x75,
+	"char":    0x74,
+	"string":  0x73,
+}
+
+// typeScope assigns indices to WIT types in the type index space of a
+// component or an instance type, and defines them there when they are first
+// used. The callbacks decide how types are introduced in this index space.
+type typeScope struct {
+	res     *wit.Resolve
+	indices map[int]uint32
+
+	// outer returns the index of a type that is not defined in this scope,
+	// like a type of another interface.
+	outer func(id int, def *wit.TypeDef) (index uint32, ok bool, err error)
+
+	// define adds a type definition and returns its index.
+	define func(deftype []byte) uint32
+
+	// name is called for named types after they are defined, and returns
+	// the index they are known by (for example, after exporting them).
+	name func(id int, def *wit.TypeDef, index uint32) (uint32, error)
+
+	// resource introduces a resource type.
+	resource func(id int, def *wit.TypeDef) (uint32, error)
+}
+
+// typeIndex returns the index of the type definition with the given ID.
+func (s *typeScope) typeIndex(id int) (uint32, error) {
+	if index, ok := s.indices[id]; ok {
+		return index, nil
+	}
+	def := s.res.Types[id]
+	index, ok, err := s.outer(id, def)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		s.indices[id] = index
+		return index, nil
+	}
+	switch def.Kind.Kind {
+	case "resource":
+		index, err = s.resource(id, def)
+		if err != nil {
+			return 0, err
+		}
+		s.indices[id] = index
+		return index, nil
+	case "type":
+		t := *def.Kind.Type
+		if t.Primitive != "" {
+			code, ok := primitives[t.Primitive]
+			if !ok {
+				return 0, fmt.Errorf("unknown type %s", t.Primitive)
+			}
+			index = s.define([]byte{code})
+		} else if index, err = s.typeIndex(t.ID); err != nil {
+			return 0, err
+		}
+	default:
+		deftype, err := s.defType(def)
+		if err != nil {
+			return 0, err
+		}
+		index = s.define(deftype)
+	}
+	if def.Name != nil {
+		index, err = s.name(id, def, index)
+		if err != nil {
+			return 0, err
+		}
+	}
+	s.indices[id] = index
+	return index, nil
+}
+
+// appendValType appends the encoding of a value type.
+func (s *typeScope) appendValType(buf []byte, t wit.Type) ([]byte, error) {
+	if t.Primitive != "" {
+		code, ok := primitives[t.Primitive]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", t.Primitive)
+		}
+		return append(buf, code), nil
+	}
+	index, err := s.typeIndex(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return appendS64(buf, int64(index)), nil
+}
+
+// appendOptValType appends a value type that may be absent.
+func (s *typeScope) appendOptValType(buf []byte, t *wit.Type) ([]byte, error) {
+	if t == nil {
+		return append(buf, 0x00), nil
+	}
+	return s.appendValType(append(buf, 0x01), *t)
+}
+
+// defType returns the encoding of a type definition that is not a resource
+// or an alias.
+func (s *typeScope) defType(def *wit.TypeDef) ([]byte, error) {
+	var err error
+	kind := def.Kind
+	switch kind.Kind {
+	case "record":
+		buf := appendU32([]byte{0x72}, uint32(len(kind.Fields)))
+		for _, field := range kind.Fields {
+			buf = appendName(buf, field.Name)
+			if buf, err = s.appendValType(buf, field.Type); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case "variant":
+		buf := appendU32([]byte{0x71}, uint32(len(kind.Cases)))
+		for _, c := range kind.Cases {
+			buf = appendName(buf, c.Name)
+			if buf, err = s.appendOptValType(buf, c.Type); err != nil {
+				return nil, err
+			}
+			buf = append(buf, 0x00)
+		}
+		return buf, nil
+	case "enum":
+		buf := appendU32([]byte{0x6d}, uint32(len(kind.Cases)))
+		for _, c := range kind.Cases {
+			buf = appendName(buf, c.Name)
+		}
+		return buf, nil
+	case "flags":
+		buf := appendU32([]byte{0x6e}, uint32(len(kind.Flags)))
+		for _, flag := range kind.Flags {
+			buf = appendName(buf, flag.Name)
+		}
+		return buf, nil
+	case "tuple":
+		buf := appendU32([]byte{0x6f}, uint32(len(kind.Types)))
+		for _, t := range kind.Types {
+			if buf, err = s.appendValType(buf, t); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case "list":
+		return s.appendValType([]byte{0x70}, *kind.Type)
+	case "option":
+		return s.appendValType([]byte{0x6b}, *kind.Type)
+	case "result":
+		buf, err := s.appendOptValType([]byte{0x6a}, kind.OK)
+		if err != nil {
+			return nil, err
+		}
+		return s.appendOptValType(buf, kind.Err)
+	case "handle":
+		index, err := s.typeIndex(kind.Handle.Resource)
+		if err != nil {
+			return nil, err
+		}
+		code := byte(0x69)
+		if kind.Handle.Borrow {
+			code = 0x68
+		}
+		return appendU32([]byte{code}, index), nil
+	}
+	return nil, fmt.Errorf("%s types are not supported", kind.Kind)
+}
+
+// funcType returns the encoding of the type of a function.
+func (s *typeScope) funcType(fn *wit.Function) ([]byte, error) {
+	var err error
+	buf := appendU32([]byte{0x40}, uint32(len(fn.Params)))
+	for _, param := range fn.Params {
+		buf = appendName(buf, param.Name)
+		if buf, err = s.appendValType(buf, param.Type); err != nil {
+			return nil, err
+		}
+	}
+	if fn.Result == nil {
+		return append(buf, 0x01, 0x00), nil
+	}
+	return s.appendValType(append(buf, 0x00), *fn.Result)
+}
+
+// coreValTypes converts flattened types to core value types.
+func coreValTypes(types []wit.CoreType) []byte {
+	buf := make([]byte, len(types))
+	for i, t := range types {
+		buf[i] = [...]byte{valI32, valI64, valF32, valF64}[t]
+	}
+	return buf
+}
+
+// lowerType returns the signature of the core function that a WIT function
+// is lowered to, which is how the core module imports it.
+func lowerType(res *wit.Resolve, fn *wit.Function) funcType {
+	params := flattenParams(res, fn)
+	var results []wit.CoreType
+	if fn.Result != nil {
+		results = res.Flatten(*fn.Result)
+	}
+	if len(results) > wit.MaxFlatResults {
+		params = append(params, wit.I32)
+		results = nil
+	}
+	return funcType{params: coreValTypes(params), results: coreValTypes(results)}
+}
+
+// liftType returns the signature of the core function that is lifted to a
+// WIT function, which is how the core module exports it.
+func liftType(res *wit.Resolve, fn *wit.Function) funcType {
+	var results []wit.CoreType
+	if fn.Result != nil {
+		results = res.Flatten(*fn.Result)
+	}
+	if len(results) > wit.MaxFlatResults {
+		results = []wit.CoreType{wit.I32}
+	}
+	return funcType{params: coreValTypes(flattenParams(res, fn)), results: coreValTypes(results)}
+}
+
+func flattenParams(res *wit.Resolve, fn *wit.Function) []wit.CoreType {
+	var params []wit.CoreType
+	for _, param := range fn.Params {
+		params = append(params, res.Flatten(param.Type)...)
+	}
+	if len(params) > wit.MaxFlatParams {
+		params = []wit.CoreType{wit.I32}
+	}
+	return params
+}
+
+// usesMemory returns whether calls to this function pass values through
+// linear memory.
+func usesMemory(res *wit.Resolve, fn *wit.Function) bool {
+	var params []wit.CoreType
+	for _, param := range fn.Params {
+		if hasList(res, param.Type) {
+			return true
+		}
+		params = append(params, res.Flatten(param.Type)...)
+	}
+	if fn.Result != nil && (hasList(res, *fn.Result) || len(res.Flatten(*fn.Result)) > wit.MaxFlatResults) {
+		return true
+	}
+	return len(params) > wit.MaxFlatParams
+}
+
+// needsRealloc returns whether values passed in this direction need to be
+// allocated in linear memory by the callee: the parameters of a lifted
+// function, or the result of a lowered function.
+func needsRealloc(res *wit.Resolve, fn *wit.Function, lift bool) bool {
+	if !lift {
+		return fn.Result != nil && hasList(res, *fn.Result)
+	}
+	var params []wit.CoreType
+	for _, param := range fn.Params {
+		if hasList(res, param.Type) {
+			return true
+		}
+		params = append(params, res.Flatten(param.Type)...)
+	}
+	return len(params) > wit.MaxFlatParams
+}
+
+// hasList returns whether the type contains a string or a list, which are
+// stored in separately allocated memory.
+func hasList(res *wit.Resolve, t wit.Type) bool {
+	t, def := res.Underlying(t)
+	if def == nil {
+		return t.Primitive == "string"
+	}
+	switch def.Kind.Kind {
+	case "list":
+		return true
+	case "record":
+		for _, field := range def.Kind.Fields {
+			if hasList(res, field.Type) {
+				return true
+			}
+		}
+	case "tuple":
+		for _, field := range def.Kind.Types {
+			if hasList(res, field) {
+				return true
+			}
+		}
+	case "variant", "option", "result":
+		for _, c := range wit.VariantCases(def) {
+			if c != nil && hasList(res, *c) {
+				return true
+			}
+		}
+	}
+	return false
+}
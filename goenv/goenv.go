@@ -169,11 +169,6 @@ func Get(name string) string {
 		}
 
 		return findWasmOpt()
-	case "WASMTOOLS":
-		if path := os.Getenv("WASMTOOLS"); path != "" {
-			return path
-		}
-		return "wasm-tools"
 	default:
 		return ""
 	}
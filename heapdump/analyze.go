@@ -0,0 +1,482 @@
+package heapdump
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Report is a summary of a heap dump, with objects grouped by type and
+// allocation site.
+type Report struct {
+	HeapSize    uint64
+	UsedBytes   uint64
+	FreeBytes   uint64
+	LargestFree uint64
+	Objects     int
+	Roots       [2]int // number of roots by kind
+	Entries     []*Entry
+}
+
+// Entry is a group of heap objects with the same type and allocation site.
+type Entry struct {
+	Type    string   // likely type (or types) of the objects
+	Site    string   // allocation site, if known
+	Objects int      // number of objects
+	Bytes   uint64   // total size of the objects, in bytes
+	Globals []string // globals that directly reference one of the objects
+	Stack   int      // number of references from the stack
+}
+
+// maxTypeCandidates is the maximum number of type names that is shown when the
+// type of an object is ambiguous.
+const maxTypeCandidates = 3
+
+// maxLayoutWords limits the size of types for which a layout is calculated.
+const maxLayoutWords = 1 << 16
+
+// Analyze groups the objects in the heap dump by type and allocation site. The
+// executable is used to look up allocation sites, types and globals. It may be
+// empty, in which case only the sizes of objects are reported.
+func Analyze(dump *Dump, executable string) (*Report, error) {
+	report := &Report{
+		HeapSize: dump.HeapSize(),
+		Objects:  len(dump.Objects),
+	}
+	report.FreeBytes, report.LargestFree = dump.FreeBytes()
+	report.UsedBytes = report.HeapSize - report.FreeBytes
+
+	var info *debugInfo
+	if executable != "" {
+		var err error
+		info, err = readDebugInfo(executable, dump.PtrSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Group all objects by type and allocation site.
+	entries := make(map[[2]string]*Entry)
+	objectEntries := make(map[uint64]*Entry)
+	for i := range dump.Objects {
+		obj := &dump.Objects[i]
+		typ := info.objectType(dump, obj)
+		site := ""
+		if obj.AllocSite != 0 {
+			site = info.site(info.allocSite(obj))
+		}
+		key := [2]string{typ, site}
+		entry := entries[key]
+		if entry == nil {
+			entry = &Entry{Type: typ, Site: site}
+			entries[key] = entry
+			report.Entries = append(report.Entries, entry)
+		}
+		entry.Objects++
+		entry.Bytes += obj.Size
+		objectEntries[obj.Address] = entry
+	}
+
+	// Attribute roots to the objects they reference.
+	for _, root := range dump.Roots {
+		if int(root.Kind) < len(report.Roots) {
+			report.Roots[root.Kind]++
+		}
+		entry := objectEntries[root.Object]
+		if entry == nil {
+			continue
+		}
+		switch root.Kind {
+		case RootGlobal:
+			name := info.global(root.Address)
+			if name == "" {
+				name = fmt.Sprintf("%#x", root.Address)
+			}
+			found := false
+			for _, global := range entry.Globals {
+				if global == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				entry.Globals = append(entry.Globals, name)
+			}
+		case RootStack:
+			entry.Stack++
+		}
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		a, b := report.Entries[i], report.Entries[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Site < b.Site
+	})
+	for _, entry := range report.Entries {
+		sort.Strings(entry.Globals)
+	}
+	return report, nil
+}
+
+// WriteTo writes the report in a human readable form.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var buf strings.Builder
+	fragmentation := uint64(0)
+	if r.FreeBytes != 0 {
+		fragmentation = (r.FreeBytes - r.LargestFree) * 100 / r.FreeBytes
+	}
+	fmt.Fprintf(&buf, "heap size:    %d bytes\n", r.HeapSize)
+	fmt.Fprintf(&buf, "in use:       %d bytes in %d objects\n", r.UsedBytes, r.Objects)
+	fmt.Fprintf(&buf, "free:         %d bytes (largest free range: %d bytes, %d%% fragmented)\n", r.FreeBytes, r.LargestFree, fragmentation)
+	fmt.Fprintf(&buf, "roots:        %d from globals, %d from the stack\n", r.Roots[RootGlobal], r.Roots[RootStack])
+	fmt.Fprintf(&buf, "\n%8s %7s  %-40s %s\n", "bytes", "objects", "type", "allocation site")
+	for _, entry := range r.Entries {
+		site := entry.Site
+		if site == "" {
+			site = "-"
+		}
+		fmt.Fprintf(&buf, "%8d %7d  %-40s %s\n", entry.Bytes, entry.Objects, entry.Type, site)
+		refs := entry.Globals
+		if entry.Stack != 0 {
+			refs = append(refs[:len(refs):len(refs)], fmt.Sprintf("stack (%d)", entry.Stack))
+		}
+		if len(refs) != 0 {
+			fmt.Fprintf(&buf, "%18sreferenced by: %s\n", "", strings.Join(refs, ", "))
+		}
+	}
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
+
+// debugInfo contains the information from an executable that is needed to
+// attribute heap objects.
+type debugInfo struct {
+	arm            bool                // ARM return addresses have the Thumb bit set
+	lines          []lineRange         // sorted by address
+	funcs          []symbolRange       // sorted by address
+	globals        []symbolRange       // sorted by address
+	layouts        map[string][]string // type names by layout (see layoutKey)
+	stackLayout    uint64              // address of runtime.goroutineStackLayout
+	hasStackLayout bool
+}
+
+type lineRange struct {
+	start, end uint64
+	file       string
+	line       int
+}
+
+type symbolRange struct {
+	start, end uint64
+	name       string
+}
+
+// readDebugInfo reads all symbols, line tables and types from the given ELF
+// file.
+func readDebugInfo(executable string, ptrSize int) (*debugInfo, error) {
+	file, err := elf.Open(executable)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info := &debugInfo{
+		arm:     file.Machine == elf.EM_ARM,
+		layouts: make(map[string][]string),
+	}
+
+	// Read the symbol table for globals and functions.
+	symbols, err := file.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	for _, symbol := range symbols {
+		if symbol.Name == "runtime.goroutineStackLayout" {
+			info.stackLayout = symbol.Value
+			info.hasStackLayout = true
+		}
+		if symbol.Size == 0 {
+			continue
+		}
+		r := symbolRange{start: symbol.Value, end: symbol.Value + symbol.Size, name: symbol.Name}
+		switch elf.ST_TYPE(symbol.Info) {
+		case elf.STT_FUNC:
+			if info.arm {
+				r.start &^= 1
+				r.end &^= 1
+			}
+			info.funcs = append(info.funcs, r)
+		case elf.STT_OBJECT:
+			info.globals = append(info.globals, r)
+		}
+	}
+	sort.Slice(info.funcs, func(i, j int) bool { return info.funcs[i].start < info.funcs[j].start })
+	sort.Slice(info.globals, func(i, j int) bool { return info.globals[i].start < info.globals[j].start })
+
+	// Read the DWARF debug information, for line numbers and types.
+	data, err := file.DWARF()
+	if err != nil {
+		// Allocation sites and types can't be determined, but the symbol table
+		// is still useful.
+		return info, nil
+	}
+	r := data.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			err := info.readLines(data, e)
+			if err != nil {
+				return nil, err
+			}
+		case dwarf.TagTypedef, dwarf.TagStructType:
+			name, _ := e.Val(dwarf.AttrName).(string)
+			if name == "" {
+				continue
+			}
+			typ, err := data.Type(e.Offset)
+			if err != nil {
+				continue
+			}
+			key, ok := layoutKey(typ, int64(ptrSize))
+			if !ok {
+				continue
+			}
+			names := info.layouts[key]
+			found := false
+			for _, n := range names {
+				if n == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				info.layouts[key] = append(names, name)
+			}
+		}
+	}
+	sort.Slice(info.lines, func(i, j int) bool { return info.lines[i].start < info.lines[j].start })
+	for key := range info.layouts {
+		sort.Strings(info.layouts[key])
+	}
+	return info, nil
+}
+
+// readLines reads the line table of a single compile unit.
+func (info *debugInfo) readLines(data *dwarf.Data, cu *dwarf.Entry) error {
+	lr, err := data.LineReader(cu)
+	if err != nil {
+		return err
+	}
+	if lr == nil {
+		return nil
+	}
+	lineEntry := dwarf.LineEntry{
+		EndSequence: true,
+	}
+	for {
+		prevLineEntry := lineEntry
+		err := lr.Next(&lineEntry)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if prevLineEntry.EndSequence || prevLineEntry.File == nil {
+			continue
+		}
+		if prevLineEntry.Address == 0 {
+			// Tombstone value, the code has been removed by the linker.
+			continue
+		}
+		info.lines = append(info.lines, lineRange{
+			start: prevLineEntry.Address,
+			end:   lineEntry.Address,
+			file:  prevLineEntry.File.Name,
+			line:  prevLineEntry.Line,
+		})
+	}
+}
+
+// site returns a human readable allocation site for the given return address.
+func (info *debugInfo) site(returnAddress uint64) string {
+	if info == nil {
+		return fmt.Sprintf("%#x", returnAddress)
+	}
+	// The return address points just past the call instruction, so look up the
+	// address before it.
+	pc := returnAddress
+	if info.arm {
+		pc &^= 1
+	}
+	pc--
+
+	var location string
+	i := sort.Search(len(info.lines), func(i int) bool { return info.lines[i].end > pc })
+	if i < len(info.lines) && info.lines[i].start <= pc {
+		location = fmt.Sprintf("%s:%d", info.lines[i].file, info.lines[i].line)
+	}
+	if fn := findSymbol(info.funcs, pc); fn != "" {
+		if location == "" {
+			return fn
+		}
+		return fn + " (" + location + ")"
+	}
+	if location != "" {
+		return location
+	}
+	return fmt.Sprintf("%#x", returnAddress)
+}
+
+// global returns the name of the global at the given address, or an empty
+// string if unknown.
+func (info *debugInfo) global(address uint64) string {
+	if info == nil {
+		return ""
+	}
+	return findSymbol(info.globals, address)
+}
+
+// findSymbol returns the name of the symbol containing the given address.
+// allocSite returns the return address that best describes where obj was
+// allocated. Many objects are allocated by runtime functions like sliceAppend
+// on behalf of user code, in which case the caller of that function is used.
+func (info *debugInfo) allocSite(obj *Object) uint64 {
+	if info == nil || obj.AllocCaller == 0 {
+		return obj.AllocSite
+	}
+	pc := obj.AllocSite
+	if info.arm {
+		pc &^= 1
+	}
+	if strings.HasPrefix(findSymbol(info.funcs, pc-1), "runtime.") {
+		return obj.AllocCaller
+	}
+	return obj.AllocSite
+}
+
+func findSymbol(symbols []symbolRange, address uint64) string {
+	i := sort.Search(len(symbols), func(i int) bool { return symbols[i].end > address })
+	if i < len(symbols) && symbols[i].start <= address {
+		return symbols[i].name
+	}
+	return ""
+}
+
+// objectType returns the (likely) type of the given heap object, based on its
+// layout. The layout doesn't uniquely identify a type, so this may return
+// multiple candidate types.
+func (info *debugInfo) objectType(dump *Dump, obj *Object) string {
+	if !dump.Precise {
+		return "?"
+	}
+	if obj.LayoutBits == 0 {
+		if info != nil && info.hasStackLayout && obj.Layout == info.stackLayout {
+			return "<goroutine stack>"
+		}
+		return "?"
+	}
+	bitmap := new(big.Int)
+	for i := 0; i < obj.LayoutBits; i++ {
+		if obj.IsPointer(i) {
+			bitmap.SetBit(bitmap, i, 1)
+		}
+	}
+	if bitmap.BitLen() == 0 {
+		return "<no pointers>"
+	}
+	key := fmt.Sprintf("%d:%x", obj.LayoutBits, bitmap)
+	var names []string
+	if info != nil {
+		names = info.layouts[key]
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("<layout %s>", key)
+	}
+	if len(names) > maxTypeCandidates {
+		return strings.Join(names[:maxTypeCandidates], " | ") + fmt.Sprintf(" | (%d more)", len(names)-maxTypeCandidates)
+	}
+	return strings.Join(names, " | ")
+}
+
+// layoutKey returns the object layout of the given type as a string, in the
+// same way the compiler creates the layout for heap allocations (see
+// createObjectLayout in compiler/llvm.go). It returns false for types without
+// pointers, as those can't be distinguished from each other.
+func layoutKey(typ dwarf.Type, ptrSize int64) (string, bool) {
+	// Use the element type for arrays and single-field structs, like the
+	// compiler.
+	for {
+		switch t := typ.(type) {
+		case *dwarf.TypedefType:
+			typ = t.Type
+			continue
+		case *dwarf.ArrayType:
+			typ = t.Type
+			continue
+		case *dwarf.StructType:
+			if len(t.Field) == 1 {
+				typ = t.Field[0].Type
+				continue
+			}
+		}
+		break
+	}
+	size := typ.Size()
+	if size < ptrSize || size%ptrSize != 0 || size/ptrSize > maxLayoutWords {
+		return "", false
+	}
+	bitmap := new(big.Int)
+	if !setPointerBits(bitmap, typ, 0, ptrSize) || bitmap.BitLen() == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%x", size/ptrSize, bitmap), true
+}
+
+// setPointerBits sets the bits in the bitmap for all pointer words in the given
+// type, starting at the given byte offset. It returns false if the layout
+// can't be determined.
+func setPointerBits(bitmap *big.Int, typ dwarf.Type, offset, ptrSize int64) bool {
+	switch t := typ.(type) {
+	case *dwarf.TypedefType:
+		return setPointerBits(bitmap, t.Type, offset, ptrSize)
+	case *dwarf.PtrType:
+		if offset%ptrSize != 0 {
+			return false
+		}
+		bitmap.SetBit(bitmap, int(offset/ptrSize), 1)
+	case *dwarf.StructType:
+		for _, field := range t.Field {
+			if !setPointerBits(bitmap, field.Type, offset+field.ByteOffset, ptrSize) {
+				return false
+			}
+		}
+	case *dwarf.ArrayType:
+		elemSize := t.Type.Size()
+		if t.Count < 0 || elemSize <= 0 {
+			return t.Count == 0 || elemSize == 0
+		}
+		for i := int64(0); i < t.Count; i++ {
+			if !setPointerBits(bitmap, t.Type, offset+i*elemSize, ptrSize) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+// Package heapdump reads heap dumps written by runtime/debug.WriteHeap and
+// attributes the objects in them to types and allocation sites using the
+// debug information in the executable.
+package heapdump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Flags in the heap dump header. Keep in sync with src/runtime/gc_heapdump.go.
+const (
+	flagBigEndian    = 1 << 0
+	flagPrecise      = 1 << 1
+	flagAllocSites   = 1 << 2
+	flagAllocCallers = 1 << 3
+)
+
+var magic = []byte("TGHEAP\x00\x01")
+
+// BlockState is the state of a single heap block.
+type BlockState uint8
+
+const (
+	BlockFree BlockState = iota
+	BlockHead
+	BlockTail
+	BlockMark
+)
+
+func (s BlockState) String() string {
+	switch s {
+	case BlockFree:
+		return "free"
+	case BlockHead:
+		return "head"
+	case BlockTail:
+		return "tail"
+	case BlockMark:
+		return "mark"
+	default:
+		return "<?>"
+	}
+}
+
+// RootKind indicates where a root reference was found.
+type RootKind uint8
+
+const (
+	RootGlobal RootKind = iota // pointer in a global variable
+	RootStack                  // pointer on the stack
+)
+
+func (k RootKind) String() string {
+	switch k {
+	case RootGlobal:
+		return "global"
+	case RootStack:
+		return "stack"
+	default:
+		return "<?>"
+	}
+}
+
+// Dump is a parsed heap dump.
+type Dump struct {
+	ByteOrder  binary.ByteOrder
+	PtrSize    int
+	Precise    bool // objects have a layout (precise GC)
+	AllocSites bool // allocation sites were recorded
+	HeapStart  uint64
+	NumBlocks  uint64
+	BlockSize  uint64
+	States     []byte // raw block states, 2 bits per block
+	Objects    []Object
+	Roots      []Root
+}
+
+// Object is a single heap object.
+type Object struct {
+	Address     uint64 // start of the object, including the layout word (if any)
+	Size        uint64 // size in bytes, rounded up to whole blocks
+	AllocSite   uint64 // return address of the runtime.alloc call, or 0
+	AllocCaller uint64 // return address of the caller of AllocSite, or 0
+	Layout      uint64 // raw layout value
+	LayoutBits  int    // number of bits in Bitmap, 0 if unknown
+	Bitmap      []byte // pointer bitmap (little endian bit order)
+}
+
+// IsPointer returns whether the given word (as counted in the layout, starting
+// after the layout word) may contain a pointer according to the layout.
+func (o *Object) IsPointer(index int) bool {
+	return o.Bitmap[index/8]&(1<<(index%8)) != 0
+}
+
+// Root is a reference from outside the heap (a global or the stack) to a heap
+// object.
+type Root struct {
+	Kind    RootKind
+	Address uint64 // address of the pointer, may be 0 if unknown
+	Object  uint64 // address of the referenced object
+}
+
+// BlockState returns the state of the given block.
+func (d *Dump) BlockState(block uint64) BlockState {
+	return BlockState(d.States[block/4]>>((block%4)*2)) & 3
+}
+
+// HeapSize returns the size of the heap (excluding metadata) in bytes.
+func (d *Dump) HeapSize() uint64 {
+	return d.NumBlocks * d.BlockSize
+}
+
+// FreeBytes returns the number of free bytes in the heap, and the size of the
+// largest run of free blocks.
+func (d *Dump) FreeBytes() (free, largest uint64) {
+	var run uint64
+	for block := uint64(0); block < d.NumBlocks; block++ {
+		if d.BlockState(block) != BlockFree {
+			run = 0
+			continue
+		}
+		free += d.BlockSize
+		run += d.BlockSize
+		if run > largest {
+			largest = run
+		}
+	}
+	return
+}
+
+// reader is a small helper to read values from a heap dump, remembering the
+// first error.
+type reader struct {
+	r       *bufio.Reader
+	order   binary.ByteOrder
+	ptrSize int
+	err     error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	var c byte
+	c, r.err = r.r.ReadByte()
+	return c
+}
+
+func (r *reader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, buf)
+	return buf
+}
+
+func (r *reader) word() uint64 {
+	buf := r.bytes(uint64(r.ptrSize))
+	if r.err != nil {
+		return 0
+	}
+	switch r.ptrSize {
+	case 2:
+		return uint64(r.order.Uint16(buf))
+	case 4:
+		return uint64(r.order.Uint32(buf))
+	default:
+		return r.order.Uint64(buf)
+	}
+}
+
+// Read parses a heap dump.
+func Read(rd io.Reader) (*Dump, error) {
+	r := &reader{r: bufio.NewReader(rd)}
+	if header := r.bytes(uint64(len(magic))); r.err != nil || !bytes.Equal(header, magic) {
+		if r.err == io.EOF || r.err == io.ErrUnexpectedEOF || r.err == nil {
+			return nil, errors.New("not a TinyGo heap dump")
+		}
+		return nil, r.err
+	}
+	ptrSize := r.byte()
+	flags := r.byte()
+	r.bytes(2) // reserved
+	if r.err != nil {
+		return nil, r.err
+	}
+	if ptrSize != 2 && ptrSize != 4 && ptrSize != 8 {
+		return nil, fmt.Errorf("unsupported pointer size %d", ptrSize)
+	}
+	d := &Dump{
+		ByteOrder:  binary.LittleEndian,
+		PtrSize:    int(ptrSize),
+		Precise:    flags&flagPrecise != 0,
+		AllocSites: flags&flagAllocSites != 0,
+	}
+	if flags&flagBigEndian != 0 {
+		d.ByteOrder = binary.BigEndian
+	}
+	r.order = d.ByteOrder
+	r.ptrSize = d.PtrSize
+	d.HeapStart = r.word()
+	d.NumBlocks = r.word()
+	d.BlockSize = r.word()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for {
+		tag := r.byte()
+		if r.err != nil {
+			if r.err == io.EOF {
+				return nil, errors.New("heap dump is truncated")
+			}
+			return nil, r.err
+		}
+		switch tag {
+		case 'S':
+			d.States = r.bytes((d.NumBlocks + 3) / 4)
+		case 'O':
+			obj := Object{
+				Address:   r.word(),
+				Size:      r.word(),
+				AllocSite: r.word(),
+			}
+			if flags&flagAllocCallers != 0 {
+				obj.AllocCaller = r.word()
+			}
+			obj.Layout = r.word()
+			bits := r.word()
+			if bits > d.HeapSize() {
+				return nil, fmt.Errorf("invalid layout size %d for object at %#x", bits, obj.Address)
+			}
+			obj.LayoutBits = int(bits)
+			obj.Bitmap = r.bytes((bits + 7) / 8)
+			d.Objects = append(d.Objects, obj)
+		case 'R':
+			d.Roots = append(d.Roots, Root{
+				Kind:    RootKind(r.byte()),
+				Address: r.word(),
+				Object:  r.word(),
+			})
+		case 'E':
+			if r.err != nil {
+				return nil, r.err
+			}
+			if d.States == nil {
+				return nil, errors.New("heap dump has no block states")
+			}
+			return d, nil
+		default:
+			return nil, fmt.Errorf("unknown record type %q in heap dump", tag)
+		}
+		if r.err == io.EOF {
+			r.err = io.ErrUnexpectedEOF
+		}
+	}
+}
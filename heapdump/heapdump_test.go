@@ -0,0 +1,276 @@
+package heapdump
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// dumpWriter creates heap dumps in the same format as the runtime, for
+// testing.
+type dumpWriter struct {
+	bytes.Buffer
+	order   binary.ByteOrder
+	ptrSize int
+}
+
+func (w *dumpWriter) word(value uint64) {
+	buf := make([]byte, 8)
+	w.order.PutUint64(buf, value)
+	if w.order == binary.BigEndian {
+		buf = buf[8-w.ptrSize:]
+	} else {
+		buf = buf[:w.ptrSize]
+	}
+	w.Write(buf)
+}
+
+func (w *dumpWriter) header(flags byte, heapStart, numBlocks, blockSize uint64) {
+	w.Write(magic)
+	w.Write([]byte{byte(w.ptrSize), flags, 0, 0})
+	w.word(heapStart)
+	w.word(numBlocks)
+	w.word(blockSize)
+}
+
+func (w *dumpWriter) object(address, size, site, layout uint64, bitmap ...byte) {
+	w.WriteByte('O')
+	w.word(address)
+	w.word(size)
+	w.word(site)
+	w.word(layout)
+	bits := 0
+	if len(bitmap) != 0 {
+		bits = int(bitmap[0])
+		bitmap = bitmap[1:]
+	}
+	w.word(uint64(bits))
+	w.Write(bitmap)
+}
+
+func (w *dumpWriter) root(kind RootKind, address, object uint64) {
+	w.WriteByte('R')
+	w.WriteByte(byte(kind))
+	w.word(address)
+	w.word(object)
+}
+
+func TestRead(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		w := &dumpWriter{order: order, ptrSize: 4}
+		flags := byte(flagPrecise | flagAllocSites)
+		if order == binary.BigEndian {
+			flags |= flagBigEndian
+		}
+		// 8 blocks of 16 bytes: head, tail, free, head, free, free, free, free
+		w.header(flags, 0x2000_0000, 8, 16)
+		w.WriteByte('S')
+		w.Write([]byte{0b01_00_10_01, 0b00_00_00_00})
+		w.object(0x2000_0000, 32, 0x1235, 0b01_00011, 2, 0b10)
+		w.object(0x2000_0030, 16, 0, 0b0_00001_1, 1, 0b0)
+		w.root(RootGlobal, 0x2000_1000, 0x2000_0000)
+		w.root(RootStack, 0x2000_2000, 0x2000_0030)
+		w.WriteByte('E')
+
+		dump, err := Read(&w.Buffer)
+		if err != nil {
+			t.Fatalf("%s: could not read dump: %v", order, err)
+		}
+		if dump.PtrSize != 4 || !dump.Precise || !dump.AllocSites || dump.ByteOrder != order {
+			t.Errorf("%s: unexpected header: %+v", order, dump)
+		}
+		if dump.HeapStart != 0x2000_0000 || dump.HeapSize() != 128 {
+			t.Errorf("%s: unexpected heap: start %#x, size %d", order, dump.HeapStart, dump.HeapSize())
+		}
+		states := []BlockState{BlockHead, BlockTail, BlockFree, BlockHead, BlockFree, BlockFree, BlockFree, BlockFree}
+		for i, state := range states {
+			if got := dump.BlockState(uint64(i)); got != state {
+				t.Errorf("%s: block %d: expected state %s, got %s", order, i, state, got)
+			}
+		}
+		if free, largest := dump.FreeBytes(); free != 80 || largest != 64 {
+			t.Errorf("%s: expected 80 free bytes with 64 largest, got %d and %d", order, free, largest)
+		}
+		if len(dump.Objects) != 2 {
+			t.Fatalf("%s: expected 2 objects, got %d", order, len(dump.Objects))
+		}
+		obj := dump.Objects[0]
+		if obj.Address != 0x2000_0000 || obj.Size != 32 || obj.AllocSite != 0x1235 || obj.LayoutBits != 2 {
+			t.Errorf("%s: unexpected object: %+v", order, obj)
+		}
+		if obj.IsPointer(0) || !obj.IsPointer(1) {
+			t.Errorf("%s: unexpected layout bitmap: %08b", order, obj.Bitmap)
+		}
+		expectedRoots := []Root{
+			{RootGlobal, 0x2000_1000, 0x2000_0000},
+			{RootStack, 0x2000_2000, 0x2000_0030},
+		}
+		if len(dump.Roots) != len(expectedRoots) {
+			t.Fatalf("%s: expected %d roots, got %d", order, len(expectedRoots), len(dump.Roots))
+		}
+		for i, root := range expectedRoots {
+			if dump.Roots[i] != root {
+				t.Errorf("%s: root %d: expected %+v, got %+v", order, i, root, dump.Roots[i])
+			}
+		}
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data func(w *dumpWriter)
+		err  string
+	}{
+		{"empty", func(w *dumpWriter) {}, "not a TinyGo heap dump"},
+		{"magic", func(w *dumpWriter) { w.WriteString("not a heap dump") }, "not a TinyGo heap dump"},
+		{"truncated", func(w *dumpWriter) {
+			w.header(0, 0x1000, 4, 16)
+			w.WriteByte('S')
+			w.WriteByte(0)
+		}, "heap dump is truncated"},
+		{"record", func(w *dumpWriter) {
+			w.header(0, 0x1000, 4, 16)
+			w.WriteByte('X')
+		}, `unknown record type 'X' in heap dump`},
+		{"states", func(w *dumpWriter) {
+			w.header(0, 0x1000, 4, 16)
+			w.WriteByte('E')
+		}, "heap dump has no block states"},
+	} {
+		w := &dumpWriter{order: binary.LittleEndian, ptrSize: 4}
+		tc.data(w)
+		_, err := Read(&w.Buffer)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: expected error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	w := &dumpWriter{order: binary.LittleEndian, ptrSize: 4}
+	w.header(flagPrecise, 0x1000, 8, 16)
+	w.WriteByte('S')
+	w.Write([]byte{0b01_10_10_01, 0b00_00_00_01})
+	w.object(0x1000, 16, 0, 0, 2, 0b10)
+	w.object(0x1030, 48, 0, 0, 2, 0b10)
+	w.object(0x1010, 16, 0, 0, 1, 0b0)
+	w.root(RootGlobal, 0x800, 0x1000)
+	w.root(RootGlobal, 0x900, 0x1000)
+	w.root(RootStack, 0x3000, 0x1010)
+	w.WriteByte('E')
+	dump, err := Read(&w.Buffer)
+	if err != nil {
+		t.Fatal("could not read dump:", err)
+	}
+
+	report, err := Analyze(dump, "")
+	if err != nil {
+		t.Fatal("could not analyze dump:", err)
+	}
+	if report.HeapSize != 128 || report.UsedBytes != 80 || report.FreeBytes != 48 || report.LargestFree != 48 {
+		t.Errorf("unexpected heap usage: %+v", report)
+	}
+	if len(report.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(report.Entries))
+	}
+	if e := report.Entries[0]; e.Type != "<layout 2:2>" || e.Objects != 2 || e.Bytes != 64 || len(e.Globals) != 2 {
+		t.Errorf("unexpected first entry: %+v", e)
+	}
+	if e := report.Entries[1]; e.Type != "<no pointers>" || e.Objects != 1 || e.Bytes != 16 || e.Stack != 1 {
+		t.Errorf("unexpected second entry: %+v", e)
+	}
+
+	var out strings.Builder
+	report.WriteTo(&out)
+	for _, line := range []string{
+		"in use:       80 bytes in 3 objects",
+		"roots:        2 from globals, 1 from the stack",
+		"      64       2  <layout 2:2>",
+		"referenced by: 0x800, 0x900",
+		"referenced by: stack (1)",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("report does not contain %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestAllocCaller(t *testing.T) {
+	w := &dumpWriter{order: binary.LittleEndian, ptrSize: 4}
+	w.header(flagAllocSites|flagAllocCallers, 0x1000, 4, 16)
+	w.WriteByte('S')
+	w.WriteByte(0b00_01_01_01)
+	for _, obj := range [][3]uint64{
+		{0x1000, 0x105, 0x205}, // allocated in runtime.sliceAppend
+		{0x1010, 0x205, 0x305}, // allocated directly in main.main
+		{0x1020, 0x105, 0},     // caller unknown
+	} {
+		w.WriteByte('O')
+		w.word(obj[0]) // address
+		w.word(16)     // size
+		w.word(obj[1]) // alloc site
+		w.word(obj[2]) // caller
+		w.word(0)      // layout
+		w.word(0)      // bits
+	}
+	w.WriteByte('E')
+	dump, err := Read(&w.Buffer)
+	if err != nil {
+		t.Fatal("could not read dump:", err)
+	}
+	if len(dump.Objects) != 3 || dump.Objects[0].AllocCaller != 0x205 || dump.Objects[0].Size != 16 {
+		t.Fatalf("unexpected objects: %+v", dump.Objects)
+	}
+
+	info := &debugInfo{
+		funcs: []symbolRange{
+			{0x100, 0x200, "runtime.sliceAppend"},
+			{0x200, 0x300, "main.main"},
+			{0x300, 0x400, "main.run"},
+		},
+	}
+	for i, expected := range []string{"main.main", "main.main", "runtime.sliceAppend"} {
+		if site := info.site(info.allocSite(&dump.Objects[i])); site != expected {
+			t.Errorf("object %d: expected site %s, got %s", i, expected, site)
+		}
+	}
+}
+
+func TestLayoutKey(t *testing.T) {
+	ptr := &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 4}}
+	uintptrType := &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}}
+	str := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 8, Name: "string"},
+		Field: []*dwarf.StructField{
+			{Name: "ptr", Type: ptr, ByteOffset: 0},
+			{Name: "len", Type: uintptrType, ByteOffset: 4},
+		},
+	}
+	for _, tc := range []struct {
+		name string
+		typ  dwarf.Type
+		key  string
+	}{
+		{"pointer", ptr, "1:1"},
+		{"string", str, "2:1"},
+		{"struct", &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 16},
+			Field: []*dwarf.StructField{
+				{Name: "n", Type: uintptrType, ByteOffset: 0},
+				{Name: "s", Type: str, ByteOffset: 4},
+				{Name: "p", Type: ptr, ByteOffset: 12},
+			},
+		}, "4:a"},
+		{"array", &dwarf.ArrayType{CommonType: dwarf.CommonType{ByteSize: 32}, Type: str, Count: 4}, "2:1"},
+		{"typedef", &dwarf.TypedefType{CommonType: dwarf.CommonType{Name: "main.T"}, Type: str}, "2:1"},
+		{"no pointers", uintptrType, ""},
+	} {
+		key, ok := layoutKey(tc.typ, 4)
+		if ok != (tc.key != "") || key != tc.key {
+			t.Errorf("%s: expected layout %q, got %q", tc.name, tc.key, key)
+		}
+	}
+}
@@ -283,6 +283,14 @@ func (r *runner) run(fn *function, params []value, parentMem *memoryView, indent
 
 				// Get the requested memory size to be allocated.
 				size := operands[1].Uint(r)
+				if size > maxObjectSize {
+					// Too big to allocate at compile time.
+					err := r.runAtRuntime(fn, inst, locals, &mem, indent)
+					if err != nil {
+						return nil, mem, err
+					}
+					continue
+				}
 
 				// Get the object layout, if it is available.
 				llvmLayoutType := r.getLLVMTypeFromLayout(operands[2])
@@ -681,6 +689,11 @@ func (r *runner) run(fn *function, params []value, parentMem *memoryView, indent
 				locals[inst.localIndex] = makeLiteralInt(ptrValue, int(operands[0].len(r)*8))
 				continue
 			}
+			if int64(ptr.offset())+offset > maxObjectSize {
+				// The offset doesn't fit in a pointerValue. This can only
+				// happen in an external object that is too big to interpret.
+				return nil, mem, r.errorAt(inst, errUnsupportedRuntimeInst)
+			}
 			ptr, err = ptr.addOffset(int64(offset))
 			if err != nil {
 				return nil, mem, r.errorAt(inst, err)
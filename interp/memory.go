@@ -46,6 +46,11 @@ type object struct {
 	marked         uint8     // 0 means unmarked, 1 means external read, 2 means external write
 }
 
+// maxObjectSize is the largest object that can be interpreted: the offset in a
+// pointerValue is 32 bits. Larger objects are possible with 64-bit pointers
+// (for example on wasm64) and are left for runtime.
+const maxObjectSize = math.MaxUint32
+
 // clone() returns a cloned version of this object, for when an object needs to
 // be written to for example.
 func (obj object) clone() object {
@@ -1198,8 +1203,16 @@ func (r *runner) getValue(llvmValue llvm.Value) value {
 			r.globals[llvmValue] = index
 			r.objects = append(r.objects, obj)
 			if !llvmValue.IsAGlobalVariable().IsNil() {
-				obj.size = uint32(r.targetData.TypeAllocSize(llvmValue.GlobalValueType()))
-				if initializer := llvmValue.Initializer(); !initializer.IsNil() {
+				size := r.targetData.TypeAllocSize(llvmValue.GlobalValueType())
+				obj.size = uint32(size)
+				if llvmValue.IsThreadLocal() {
+					// Every thread has its own copy of a thread-local global,
+					// so treat it as external: its value is only known at
+					// runtime.
+				} else if size > maxObjectSize {
+					// Too big to interpret, so treat it as external.
+					obj.size = 0
+				} else if initializer := llvmValue.Initializer(); !initializer.IsNil() {
 					obj.buffer = r.getValue(initializer)
 					obj.constant = llvmValue.IsGlobalConstant()
 				}
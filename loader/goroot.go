@@ -201,6 +201,11 @@ func listGorootMergeLinks(goroot, tinygoroot string, overrides map[string]bool)
 		}
 	}
 
+	// Add files from TinyGo to packages that are maintained separately.
+	for dst, src := range extraFiles {
+		merges[filepath.Join("src", dst)] = filepath.Join(tinygoSrc, src)
+	}
+
 	// Merge the special directories from goroot.
 	for _, dir := range []string{"bin", "lib", "pkg"} {
 		merges[dir] = filepath.Join(goroot, dir)
@@ -214,6 +219,13 @@ func listGorootMergeLinks(goroot, tinygoroot string, overrides map[string]bool)
 	return merges, nil
 }
 
+// Files that are added to a package in the merged GOROOT, from another
+// directory in TinyGo. This is used for packages that are maintained separately
+// (such as the net package), but that need some TinyGo specific code.
+var extraFiles = map[string]string{
+	"net/netdev_wasip2.go": "internal/netdev/net/netdev_wasip2.go",
+}
+
 // needsSyscallPackage returns whether the syscall package should be overridden
 // with the TinyGo version. This is the case on some targets.
 func needsSyscallPackage(buildTags []string) bool {
@@ -246,6 +258,7 @@ func pathsToOverride(goMinor int, needsSyscallPackage bool) map[string]bool {
 		"internal/fuzz/":              false,
 		"internal/reflectlite/":       false,
 		"internal/gclayout":           false,
+		"internal/netdev/":            false,
 		"internal/task/":              false,
 		"internal/wasi/":              false,
 		"machine/":                    false,
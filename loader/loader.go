@@ -51,6 +51,7 @@ type PackageJSON struct {
 	Name       string
 	ForTest    string
 	Root       string
+	Standard   bool // part of the standard library (including TinyGo overrides)
 	Module     struct {
 		Path      string
 		Main      bool
@@ -30,7 +30,11 @@ import (
 	"github.com/tinygo-org/tinygo/compileopts"
 	"github.com/tinygo-org/tinygo/diagnostics"
 	"github.com/tinygo-org/tinygo/goenv"
+	"github.com/tinygo-org/tinygo/heapdump"
 	"github.com/tinygo-org/tinygo/loader"
+	"github.com/tinygo-org/tinygo/pioasm"
+	"github.com/tinygo-org/tinygo/wit"
+	"github.com/tinygo-org/tinygo/witbindgen"
 	"golang.org/x/tools/go/buildutil"
 	"tinygo.org/x/go-llvm"
 
@@ -185,6 +189,32 @@ func Build(pkgName, outpath string, options *compileopts.Options) error {
 			}
 		}
 
+		if result.Header != "" {
+			// Write the C header next to the output file, for example
+			// libfoo.h for libfoo.a.
+			header, err := os.ReadFile(result.Header)
+			if err != nil {
+				return err
+			}
+			headerPath := strings.TrimSuffix(outpath, filepath.Ext(outpath)) + ".h"
+			if err := os.WriteFile(headerPath, header, 0666); err != nil {
+				return err
+			}
+		}
+
+		if result.TypeScript != "" {
+			// Write the TypeScript declarations next to the output file, for
+			// example main.d.ts for main.wasm.
+			declarations, err := os.ReadFile(result.TypeScript)
+			if err != nil {
+				return err
+			}
+			declarationsPath := strings.TrimSuffix(outpath, filepath.Ext(outpath)) + ".d.ts"
+			if err := os.WriteFile(declarationsPath, declarations, 0666); err != nil {
+				return err
+			}
+		}
+
 		if err := os.Rename(result.Binary, outpath); err != nil {
 			// Moving failed. Do a file copy.
 			inf, err := os.Open(result.Binary)
@@ -1335,6 +1365,43 @@ debugger to be able to debug certain boards if no debugger is integrated. Some
 boards (like the BBC micro:bit and most professional evaluation boards) have an
 integrated debugger.`
 
+	usageHeapdump = `Analyze a heap dump written by runtime/debug.WriteHeap. The objects in the heap
+are grouped by type and allocation site, sorted by the amount of memory they
+use:
+
+	tinygo heapdump [executable] heapdump.bin
+
+The executable must be the ELF file of the program that wrote the heap dump. It
+is used to find allocation sites, types, and globals in the debug information.
+If it is not given, only the sizes of objects are reported.
+
+Types can only be determined when using the precise garbage collector
+(-gc=precise), and even then they are guessed from the pointer layout so there
+may be multiple candidates. Allocation sites are only recorded when building
+with -tags=gc.allocsites.`
+
+	usageWitBindgen = `Generate Go bindings for the imports and exports of a WIT world:
+
+	tinygo wit-bindgen -package-root=example.com/app/bindings [-wit-world=name] [-o=dir] path/to/wit
+
+The WIT package is either a .wit file or a directory with .wit files and a deps
+directory. One Go package is generated for each interface, plus one for the
+world itself. The package root is the import path of the output directory,
+which is used to import the generated packages from each other. Exported
+functions must be set in the Exports variable of the generated package before
+they are called by the host.`
+
+	usagePioasm = `Assemble RP2040 PIO programs into a Go source file:
+
+	tinygo pioasm [-package=name] [-o=file_pio.go] path/to/file.pio
+
+The syntax is the same as that of pioasm from the Pico SDK. For each program, a
+machine.PIOProgram variable is generated together with constants for the wrap
+addresses, public defines and public labels, and a function that returns the
+default state machine configuration. Code in % go { ... %} blocks is copied to
+the output. The package name defaults to $GOPACKAGE, so the command can be used
+from a //go:generate line.`
+
 	usageClean = `Clean the cache directory, normally stored in $HOME/.cache/tinygo. This is not
 normally needed.`
 
@@ -1355,6 +1422,9 @@ commands:
 		gdb:		run/flash and immediately enter GDB
 		lldb:		run/flash and immediately enter LLDB
 		monitor:	open communication port
+		heapdump:	analyze a heap dump
+		wit-bindgen:	generate Go bindings for a WIT world
+		pioasm:		assemble RP2040 PIO programs to Go
 		ports:		list available serial ports
 		env:		list environment variables used during build
 		list:		run go list using the TinyGo root
@@ -1367,18 +1437,87 @@ commands:
 
 var (
 	commandHelp = map[string]string{
-		"build":   usageBuild,
-		"run":     usageRun,
-		"flash":   usageFlash,
-		"monitor": usageMonitor,
-		"gdb":     usageGdb,
-		"clean":   usageClean,
-		"help":    usageHelp,
-		"version": usageVersion,
-		"env":     usageEnv,
+		"build":       usageBuild,
+		"run":         usageRun,
+		"flash":       usageFlash,
+		"monitor":     usageMonitor,
+		"heapdump":    usageHeapdump,
+		"wit-bindgen": usageWitBindgen,
+		"pioasm":      usagePioasm,
+		"gdb":         usageGdb,
+		"clean":       usageClean,
+		"help":        usageHelp,
+		"version":     usageVersion,
+		"env":         usageEnv,
 	}
 )
 
+// HeapDump reads a heap dump written by runtime/debug.WriteHeap and prints a
+// summary of the objects in it. The executable is optional, but is needed to
+// show types and allocation sites.
+func HeapDump(executable, dumpPath string) error {
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dump, err := heapdump.Read(f)
+	if err != nil {
+		return fmt.Errorf("could not read heap dump: %w", err)
+	}
+	report, err := heapdump.Analyze(dump, executable)
+	if err != nil {
+		return fmt.Errorf("could not analyze heap dump: %w", err)
+	}
+	_, err = report.WriteTo(os.Stdout)
+	return err
+}
+
+// PIOAsm assembles the PIO programs in the given .pio file and writes them as
+// Go source code to outpath.
+func PIOAsm(pioPath, outpath, pkg string) error {
+	src, err := os.ReadFile(pioPath)
+	if err != nil {
+		return err
+	}
+	file, err := pioasm.Parse(pioPath, src)
+	if err != nil {
+		return err
+	}
+	out, err := pioasm.GenerateGo(file, pkg, filepath.Base(pioPath))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outpath, out, 0o666)
+}
+
+// WitBindgen generates Go bindings for a world in the given WIT package, and
+// writes them to the output directory.
+func WitBindgen(witPath, worldName, outdir string, config witbindgen.Config) error {
+	res, err := wit.Load(witPath)
+	if err != nil {
+		return err
+	}
+	world, err := res.World(worldName)
+	if err != nil {
+		return err
+	}
+	files, err := witbindgen.Generate(res, world, config)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		path := filepath.Join(outdir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, file.Content, 0o666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func usage(command string) {
 	val, ok := commandHelp[command]
 	if !ok {
@@ -1493,14 +1632,14 @@ func main() {
 	opt := flag.String("opt", "z", "optimization level: 0, 1, 2, s, z")
 	gc := flag.String("gc", "", "garbage collector to use (none, leaking, conservative)")
 	panicStrategy := flag.String("panic", "print", "panic strategy (print, trap)")
-	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify)")
+	scheduler := flag.String("scheduler", "", "which scheduler to use (none, tasks, asyncify, jspi, threads)")
 	serial := flag.String("serial", "", "which serial output to use (none, uart, usb, rtt)")
 	work := flag.Bool("work", false, "print the name of the temporary build directory and do not delete this directory on exit")
 	interpTimeout := flag.Duration("interp-timeout", 180*time.Second, "interp optimization pass timeout")
 	var tags buildutil.TagsFlag
 	flag.Var(&tags, "tags", "a space-separated list of extra build tags")
 	target := flag.String("target", "", "chip/board name or JSON target specification file")
-	buildMode := flag.String("buildmode", "", "build mode to use (default, c-shared)")
+	buildMode := flag.String("buildmode", "", "build mode to use (default, c-shared, c-archive)")
 	var stackSize uint64
 	flag.Func("stack-size", "goroutine stack size (if unknown at compile time)", func(s string) error {
 		size, err := bytesize.Parse(s)
@@ -1544,16 +1683,27 @@ func main() {
 		flag.BoolVar(&flagTest, "test", false, "supply -test flag to go list")
 	}
 	var outpath string
-	if command == "help" || command == "build" || command == "test" {
+	if command == "help" || command == "build" || command == "test" || command == "wit-bindgen" || command == "pioasm" {
 		flag.StringVar(&outpath, "o", "", "output filename")
 	}
 
+	var pioPackage string
+	if command == "help" || command == "pioasm" {
+		flag.StringVar(&pioPackage, "package", os.Getenv("GOPACKAGE"), "package name of the generated Go file")
+	}
+
 	var witPackage, witWorld string
-	if command == "help" || command == "build" || command == "test" || command == "run" {
+	if command == "help" || command == "build" || command == "test" || command == "run" || command == "wit-bindgen" {
 		flag.StringVar(&witPackage, "wit-package", "", "wit package for wasm component embedding")
 		flag.StringVar(&witWorld, "wit-world", "", "wit world for wasm component embedding")
 	}
 
+	var witConfig witbindgen.Config
+	if command == "help" || command == "wit-bindgen" {
+		flag.StringVar(&witConfig.PackageRoot, "package-root", "", "import path of the wit-bindgen output directory")
+		flag.StringVar(&witConfig.CMPackage, "cm", witbindgen.DefaultCMPackage, "import path of the cm package used by wit-bindgen")
+	}
+
 	var testConfig compileopts.TestConfig
 	if command == "help" || command == "test" {
 		flag.BoolVar(&testConfig.CompileOnly, "c", false, "compile the test binary but do not run it")
@@ -1792,6 +1942,54 @@ func main() {
 		handleCompilerError(err)
 		err = Monitor("", *port, config)
 		handleCompilerError(err)
+	case "heapdump":
+		if flag.NArg() < 1 || flag.NArg() > 2 {
+			fmt.Fprintln(os.Stderr, "expected a heap dump, optionally preceded by an executable")
+			usage(command)
+			os.Exit(1)
+		}
+		executable := ""
+		if flag.NArg() == 2 {
+			executable = flag.Arg(0)
+		}
+		err := HeapDump(executable, flag.Arg(flag.NArg()-1))
+		handleCompilerError(err)
+	case "wit-bindgen":
+		witPath := witPackage
+		if flag.NArg() == 1 && witPath == "" {
+			witPath = flag.Arg(0)
+		} else if flag.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "expected a single WIT package path")
+			usage(command)
+			os.Exit(1)
+		}
+		if witPath == "" || witConfig.PackageRoot == "" {
+			fmt.Fprintln(os.Stderr, "both a WIT package and -package-root are required")
+			usage(command)
+			os.Exit(1)
+		}
+		if outpath == "" {
+			outpath = "."
+		}
+		err := WitBindgen(witPath, witWorld, outpath, witConfig)
+		handleCompilerError(err)
+	case "pioasm":
+		if flag.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "expected a single .pio file")
+			usage(command)
+			os.Exit(1)
+		}
+		if pioPackage == "" {
+			fmt.Fprintln(os.Stderr, "no package name: use -package or run from go generate")
+			usage(command)
+			os.Exit(1)
+		}
+		pioPath := flag.Arg(0)
+		if outpath == "" {
+			outpath = strings.TrimSuffix(pioPath, filepath.Ext(pioPath)) + "_pio.go"
+		}
+		err := PIOAsm(pioPath, outpath, pioPackage)
+		handleCompilerError(err)
 	case "ports":
 		serialPortInfo, err := ListSerialPorts()
 		handleCompilerError(err)
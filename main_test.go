@@ -10,8 +10,10 @@ import (
 	"errors"
 	"flag"
 	"io"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
@@ -203,6 +205,12 @@ func TestBuild(t *testing.T) {
 			t.Parallel()
 			runPlatTests(optionsFromTarget("wasip2", sema), tests, t)
 		})
+		t.Run("WASIp1-threads", func(t *testing.T) {
+			t.Parallel()
+			options := optionsFromTarget("wasip1-threads", sema)
+			emuCheck(t, options)
+			runTest("threads.go", options, t, nil, nil)
+		})
 	}
 }
 
@@ -317,12 +325,27 @@ func runPlatTests(options compileopts.Options, tests []string, t *testing.T) {
 			runTest("filesystem.go", options, t, nil, nil)
 		})
 	}
+	if options.Target == "wasip2" {
+		// Sockets are only implemented on wasip2 (using wasi:sockets).
+		t.Run("net.go", func(t *testing.T) {
+			t.Parallel()
+			runTest("net.go", options, t, nil, nil)
+		})
+	}
 	if options.Target == "" || options.Target == "wasm" || isWASI {
 		t.Run("rand.go", func(t *testing.T) {
 			t.Parallel()
 			runTest("rand.go", options, t, nil, nil)
 		})
 	}
+	if isBaremetal && options.Target != "simavr" {
+		t.Run("gcpause.go-precise", func(t *testing.T) {
+			t.Parallel()
+			options := compileopts.Options(options)
+			options.GC = "precise"
+			runTest("gcpause.go", options, t, nil, nil)
+		})
+	}
 	if !isWebAssembly {
 		// The recover() builtin isn't supported yet on WebAssembly and Windows.
 		t.Run("recover.go", func(t *testing.T) {
@@ -699,6 +722,185 @@ func TestWasmExport(t *testing.T) {
 	}
 }
 
+// Test -target=wasm-unknown-host, which imports logging, time and randomness
+// from the host and exports malloc and free.
+func TestWasmUnknownHost(t *testing.T) {
+	t.Parallel()
+
+	// Build the wasm binary.
+	tmpdir := t.TempDir()
+	options := optionsFromTarget("wasm-unknown-host", sema)
+	buildConfig, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/wasmunknownhost.go", ".wasm", tmpdir, buildConfig)
+	if err != nil {
+		t.Fatal("failed to build binary:", err)
+	}
+	data, err := os.ReadFile(result.Binary)
+	if err != nil {
+		t.Fatal("could not read wasm binary: ", err)
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	// Add the host module.
+	var lines []string
+	var randomCalls int
+	start := time.Now()
+	host := r.NewHostModuleBuilder("tinygo_host")
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		buf, ok := m.Memory().Read(ptr, length)
+		if !ok {
+			t.Error("log: out of bounds")
+		}
+		lines = append(lines, string(buf))
+	}).Export("log")
+	host.NewFunctionBuilder().WithFunc(func() int64 {
+		return int64(time.Since(start))
+	}).Export("nanotime")
+	host.NewFunctionBuilder().WithFunc(func() int64 {
+		return 1700000000 * int64(time.Second)
+	}).Export("walltime")
+	host.NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+		randomCalls++
+		if !m.Memory().Write(ptr, bytes.Repeat([]byte{0x42}, int(length))) {
+			t.Error("random: out of bounds")
+		}
+	}).Export("random")
+	if _, err := host.Instantiate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mod, err := r.InstantiateWithConfig(ctx, data, wazero.NewModuleConfig().WithStartFunctions())
+	if err != nil {
+		t.Fatal("could not instantiate wasm module:", err)
+	}
+	call := func(name string, params ...uint64) []uint64 {
+		results, err := mod.ExportedFunction(name).Call(ctx, params...)
+		if err != nil {
+			t.Fatalf("failed to call %s: %v", name, err)
+		}
+		return results
+	}
+	call("_initialize")
+
+	// Pass a buffer allocated with malloc to an exported function.
+	name := "world"
+	ptr := call("malloc", uint64(len(name)))[0]
+	if !mod.Memory().Write(uint32(ptr), []byte(name)) {
+		t.Fatal("malloc returned an invalid pointer:", ptr)
+	}
+	call("greet", ptr, uint64(len(name)))
+	call("free", ptr)
+
+	if expected := []string{"called init", "hello, world"}; !slices.Equal(lines, expected) {
+		t.Errorf("unexpected output\nexpected: %q\nactual:   %q", expected, lines)
+	}
+	if sec := call("now")[0]; sec != 1700000000 {
+		t.Errorf("time.Now returned %d instead of the host wall clock", sec)
+	}
+	if slept := call("sleep")[0]; slept != 1 {
+		t.Error("time.Sleep returned too early")
+	}
+	if randomCalls == 0 {
+		t.Error("random number generator was not seeded by the host")
+	}
+}
+
+// Test that the host imports of wasm-unknown are only added with their build
+// tags, so that a host doesn't need to provide all of them.
+func TestWasmUnknownHostImports(t *testing.T) {
+	t.Parallel()
+
+	tmpdir := t.TempDir()
+	options := optionsFromTarget("wasm-unknown", sema)
+	options.Tags = []string{"wasm_unknown_log"}
+	buildConfig, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/wasmunknownhost.go", ".wasm", tmpdir, buildConfig)
+	if err != nil {
+		t.Fatal("failed to build binary:", err)
+	}
+	data, err := os.ReadFile(result.Binary)
+	if err != nil {
+		t.Fatal("could not read wasm binary: ", err)
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.CompileModule(ctx, data)
+	if err != nil {
+		t.Fatal("could not compile wasm module:", err)
+	}
+	var imports []string
+	for _, f := range mod.ImportedFunctions() {
+		module, name, _ := f.Import()
+		imports = append(imports, module+"."+name)
+	}
+	if expected := []string{"tinygo_host.log"}; !slices.Equal(imports, expected) {
+		t.Errorf("unexpected imports\nexpected: %q\nactual:   %q", expected, imports)
+	}
+}
+
+// Test -target=wasm64 (memory64) using NodeJS, with the host functions of
+// targets/wasm_unknown_host.js.
+func TestWasm64(t *testing.T) {
+	t.Parallel()
+
+	t.Run("heap", func(t *testing.T) {
+		t.Parallel()
+		output := runWasm64(t, "testdata/wasm64.go")
+		checkOutput(t, "testdata/wasm64.txt", output)
+	})
+	t.Run("above4GiB", func(t *testing.T) {
+		t.Parallel()
+		output := runWasm64(t, "testdata/wasm64large.go")
+		if bytes.Contains(output, []byte("could not grow memory beyond 4GiB")) {
+			t.Skip("NodeJS doesn't support memory64 beyond 4GiB (requires NodeJS 22 or later)")
+		}
+		checkOutput(t, "testdata/wasm64large.txt", output)
+	})
+}
+
+// Build the given program for -target=wasm64 as a command, run it with the
+// emulator of the target, and return its output.
+func runWasm64(t *testing.T, path string) []byte {
+	t.Helper()
+
+	tmpdir := t.TempDir()
+	options := optionsFromTarget("wasm64", sema)
+	options.BuildMode = "default"
+	buildConfig, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(path, ".wasm", tmpdir, buildConfig)
+	if err != nil {
+		t.Fatal("failed to build binary:", err)
+	}
+
+	emulator, err := buildConfig.Emulator("", result.Binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	cmd := exec.Command(emulator[0], emulator[1:]...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	if err != nil {
+		t.Error("failed to run node:", err)
+	}
+	return output.Bytes()
+}
+
 // Test js.FuncOf (for syscall/js).
 // This test might be extended in the future to cover more cases in syscall/js.
 func TestWasmFuncOf(t *testing.T) {
@@ -766,6 +968,136 @@ func TestWasmExportJS(t *testing.T) {
 	}
 }
 
+// Test //go:wasmexport functions with parameters and results that are
+// marshalled by wasm_exec.js, and the TypeScript declarations for them.
+func TestWasmExportJSMarshalling(t *testing.T) {
+	tmpdir := t.TempDir()
+	options := optionsFromTarget("wasm", sema)
+	options.BuildMode = "c-shared"
+	buildConfig, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/jsexport.go", ".wasm", tmpdir, buildConfig)
+	if err != nil {
+		t.Fatal("failed to build binary:", err)
+	}
+
+	declarations, err := os.ReadFile(result.TypeScript)
+	if err != nil {
+		t.Fatal("failed to read TypeScript declarations:", err)
+	}
+	for _, decl := range []string{
+		"greet(name: string): string;",
+		"reverse(data: Uint8Array): Uint8Array;",
+		"birthday(p: Person, years: bigint): Person;",
+		"parse(s: string): number;",
+		"check(ok: boolean): void;",
+	} {
+		if !bytes.Contains(declarations, []byte(decl)) {
+			t.Errorf("TypeScript declarations do not contain %q:\n%s", decl, declarations)
+		}
+	}
+
+	output := &bytes.Buffer{}
+	cmd := exec.Command("node", "testdata/jsexport.js", result.Binary)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	if err != nil {
+		t.Error("failed to run node:", err)
+	}
+	checkOutput(t, "testdata/jsexport.txt", output.Bytes())
+}
+
+// Check that a wasip2 component built by TinyGo is accepted by wasm-tools, if
+// it is installed.
+func TestWasip2Validate(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("wasm-tools"); err != nil {
+		t.Skip("wasm-tools not found:", err)
+	}
+	options := optionsFromTarget("wasip2", sema)
+	buildConfig, err := builder.NewConfig(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build("testdata/stdlib.go", ".wasm", t.TempDir(), buildConfig)
+	if err != nil {
+		t.Fatal("failed to build binary:", err)
+	}
+	output, err := exec.Command("wasm-tools", "validate", "--features", "component-model", result.Binary).CombinedOutput()
+	if err != nil {
+		t.Errorf("wasm-tools validate failed: %v\n%s", err, output)
+	}
+}
+
+// Test wasi:http by running a server component with wasmtime serve, and
+// sending requests to it from a client component.
+func TestWasip2HTTP(t *testing.T) {
+	t.Parallel()
+	options := optionsFromTarget("wasip2-http", sema)
+	emuCheck(t, options)
+
+	// Build the server (for the proxy world) and the client (for the command
+	// world).
+	tmpdir := t.TempDir()
+	build := func(name, world string) string {
+		options := options
+		options.WITWorld = world
+		buildConfig, err := builder.NewConfig(&options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := builder.Build("./testdata/wasihttp/"+name, ".wasm", filepath.Join(tmpdir, name), buildConfig)
+		if err != nil {
+			t.Fatal("failed to build binary:", err)
+		}
+		return result.Binary
+	}
+	server := build("server", "")
+	client := build("client", "tinygo:wasip2/http-command")
+
+	// Start the server on a free port, and wait until it accepts connections.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("could not find a free port:", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	serverOutput := &bytes.Buffer{}
+	cmd := exec.Command("wasmtime", "serve", "-Scli", "--addr", addr, server)
+	cmd.Stdout = serverOutput
+	cmd.Stderr = serverOutput
+	if err := cmd.Start(); err != nil {
+		t.Fatal("could not start wasmtime serve:", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if t.Failed() {
+			t.Log("server output:\n" + serverOutput.String())
+		}
+	}()
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Since(start) > 30*time.Second {
+			t.Fatal("server did not start:", err)
+		}
+	}
+
+	// Send requests using the client.
+	output, err := exec.Command("wasmtime", "run", "-Scli", "-Shttp", client, "http://"+addr).CombinedOutput()
+	if err != nil {
+		t.Error("failed to run client:", err)
+	}
+	checkOutput(t, "testdata/wasihttp/client.txt", output)
+}
+
 // Check whether the output of a test equals the expected output.
 func checkOutput(t *testing.T, filename string, actual []byte) {
 	expectedOutput, err := os.ReadFile(filename)
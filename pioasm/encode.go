@@ -0,0 +1,325 @@
+package pioasm
+
+// Instruction encoding. See chapter 3.4 of the RP2040 datasheet.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Opcodes, in bits 15:13 of an instruction.
+const (
+	opJmp  = 0 << 13
+	opWait = 1 << 13
+	opIn   = 2 << 13
+	opOut  = 3 << 13
+	opPush = 4 << 13 // also pull, with bit 7 set
+	opMov  = 5 << 13
+	opIRQ  = 6 << 13
+	opSet  = 7 << 13
+)
+
+// Operands of the jmp instruction.
+var jmpConditions = map[string]uint16{
+	"":      0,
+	"!x":    1,
+	"x--":   2,
+	"!y":    3,
+	"y--":   4,
+	"x!=y":  5,
+	"pin":   6,
+	"!osre": 7,
+}
+
+var waitSources = map[string]uint16{
+	"gpio": 0,
+	"pin":  1,
+	"irq":  2,
+}
+
+var inSources = map[string]uint16{
+	"pins": 0,
+	"x":    1,
+	"y":    2,
+	"null": 3,
+	"isr":  6,
+	"osr":  7,
+}
+
+var outDestinations = map[string]uint16{
+	"pins":    0,
+	"x":       1,
+	"y":       2,
+	"null":    3,
+	"pindirs": 4,
+	"pc":      5,
+	"isr":     6,
+	"exec":    7,
+}
+
+var movDestinations = map[string]uint16{
+	"pins": 0,
+	"x":    1,
+	"y":    2,
+	"exec": 4,
+	"pc":   5,
+	"isr":  6,
+	"osr":  7,
+}
+
+var movSources = map[string]uint16{
+	"pins":   0,
+	"x":      1,
+	"y":      2,
+	"null":   3,
+	"status": 5,
+	"isr":    6,
+	"osr":    7,
+}
+
+var setDestinations = map[string]uint16{
+	"pins":    0,
+	"x":       1,
+	"y":       2,
+	"pindirs": 4,
+}
+
+// Parse an operand that must be one of the keys of the given map.
+func parseOperand(r *tokenReader, what string, operands map[string]uint16) (uint16, error) {
+	tok := r.next()
+	if tok.kind == tokenIdent {
+		if value, ok := operands[strings.ToLower(tok.text)]; ok {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid %s %s", what, tok.text)
+}
+
+// Parse a condition of the jmp instruction, if there is one.
+func parseJmpCondition(r *tokenReader) (uint16, error) {
+	cond := ""
+	switch {
+	case r.peek().isPunct("!"):
+		r.next()
+		cond = "!" + strings.ToLower(r.next().text)
+	case r.peek().isWord("pin"):
+		r.next()
+		cond = "pin"
+	case (r.peek().isWord("x") || r.peek().isWord("y")) && r.peekN(1).isPunct("--"):
+		cond = strings.ToLower(r.next().text) + r.next().text
+	case r.peek().isWord("x") && r.peekN(1).isPunct("!=") && r.peekN(2).isWord("y"):
+		r.next()
+		r.next()
+		r.next()
+		cond = "x!=y"
+	}
+	value, ok := jmpConditions[cond]
+	if !ok {
+		return 0, fmt.Errorf("invalid jmp condition %s", cond)
+	}
+	return value, nil
+}
+
+// Parse a single instruction, including side-set and delay. Operand values
+// are evaluated later, when all labels are known.
+func parseInstruction(r *tokenReader) (inst pendingInstruction, err error) {
+	mnemonic := r.next()
+	if mnemonic.kind != tokenIdent {
+		return inst, fmt.Errorf("expected an instruction but found %s", mnemonic.text)
+	}
+	switch strings.ToLower(mnemonic.text) {
+	case "nop":
+		// Encoded as mov y, y.
+		inst.encode = constant(opMov | 2<<5 | 2)
+	case "jmp":
+		cond, err := parseJmpCondition(r)
+		if err != nil {
+			return inst, err
+		}
+		r.acceptPunct(",")
+		target, err := parseExpr(r)
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = func(eval evalFunc) (uint16, error) {
+			addr, err := eval(target, 0, 31)
+			return opJmp | cond<<5 | uint16(addr), err
+		}
+	case "wait":
+		var polarity expr = numberExpr(1)
+		if _, ok := waitSources[strings.ToLower(r.peek().text)]; !ok {
+			if polarity, err = parseExpr(r); err != nil {
+				return inst, err
+			}
+		}
+		source, err := parseOperand(r, "wait source", waitSources)
+		if err != nil {
+			return inst, err
+		}
+		r.acceptPunct(",")
+		index, rel, err := parseIndex(r, source == waitSources["irq"])
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = func(eval evalFunc) (uint16, error) {
+			pol, err := eval(polarity, 0, 1)
+			if err != nil {
+				return 0, err
+			}
+			idx, err := evalIndex(eval, index, rel, source == waitSources["irq"])
+			return opWait | uint16(pol)<<7 | source<<5 | idx, err
+		}
+	case "in", "out":
+		op, operands, what := uint16(opIn), inSources, "in source"
+		if strings.EqualFold(mnemonic.text, "out") {
+			op, operands, what = opOut, outDestinations, "out destination"
+		}
+		operand, err := parseOperand(r, what, operands)
+		if err != nil {
+			return inst, err
+		}
+		r.acceptPunct(",")
+		count, err := parseExpr(r)
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = func(eval evalFunc) (uint16, error) {
+			n, err := eval(count, 1, 32)
+			return op | operand<<5 | uint16(n)&0x1f, err
+		}
+	case "push", "pull":
+		code := uint16(opPush)
+		flag := "iffull"
+		if strings.EqualFold(mnemonic.text, "pull") {
+			code |= 1 << 7
+			flag = "ifempty"
+		}
+		block := true
+	options:
+		for {
+			switch {
+			case r.acceptWord(flag) != "":
+				code |= 1 << 6
+			case r.acceptWord("block") != "":
+				block = true
+			case r.acceptWord("noblock") != "":
+				block = false
+			default:
+				break options
+			}
+		}
+		if block {
+			code |= 1 << 5
+		}
+		inst.encode = constant(code)
+	case "mov":
+		dst, err := parseOperand(r, "mov destination", movDestinations)
+		if err != nil {
+			return inst, err
+		}
+		r.acceptPunct(",")
+		var op uint16
+		switch {
+		case r.acceptPunct("!"), r.acceptPunct("~"):
+			op = 1
+		case r.acceptPunct("::"):
+			op = 2
+		}
+		src, err := parseOperand(r, "mov source", movSources)
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = constant(opMov | dst<<5 | op<<3 | src)
+	case "irq":
+		code := uint16(opIRQ)
+		switch r.acceptWord("set", "nowait", "wait", "clear") {
+		case "wait":
+			code |= 1 << 5
+		case "clear":
+			code |= 1 << 6
+		}
+		index, rel, err := parseIndex(r, true)
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = func(eval evalFunc) (uint16, error) {
+			idx, err := evalIndex(eval, index, rel, true)
+			return code | idx, err
+		}
+	case "set":
+		dst, err := parseOperand(r, "set destination", setDestinations)
+		if err != nil {
+			return inst, err
+		}
+		r.acceptPunct(",")
+		value, err := parseExpr(r)
+		if err != nil {
+			return inst, err
+		}
+		inst.encode = func(eval evalFunc) (uint16, error) {
+			v, err := eval(value, 0, 31)
+			return opSet | dst<<5 | uint16(v), err
+		}
+	default:
+		return inst, fmt.Errorf("unknown instruction %s", mnemonic.text)
+	}
+
+	// Side-set and delay, in any order.
+	for !r.done() {
+		switch {
+		case r.acceptWord("side", "sideset") != "":
+			if inst.side != nil {
+				return inst, fmt.Errorf("duplicate side-set")
+			}
+			if inst.side, err = parseExpr(r); err != nil {
+				return inst, err
+			}
+		case r.acceptPunct("["):
+			if inst.delay != nil {
+				return inst, fmt.Errorf("duplicate delay")
+			}
+			if inst.delay, err = parseExpr(r); err != nil {
+				return inst, err
+			}
+			if !r.acceptPunct("]") {
+				return inst, fmt.Errorf("expected ] but found %s", r.peek().text)
+			}
+		default:
+			return inst, fmt.Errorf("unexpected %s", r.peek().text)
+		}
+	}
+	return inst, nil
+}
+
+func constant(code uint16) func(eval evalFunc) (uint16, error) {
+	return func(eval evalFunc) (uint16, error) {
+		return code, nil
+	}
+}
+
+// Parse the index of an IRQ flag or pin, with an optional rel suffix for IRQ
+// flags.
+func parseIndex(r *tokenReader, isIRQ bool) (index expr, rel bool, err error) {
+	index, err = parseExpr(r)
+	if err != nil {
+		return nil, false, err
+	}
+	if isIRQ && r.acceptWord("rel") != "" {
+		rel = true
+	}
+	return index, rel, nil
+}
+
+// Evaluate the index of an IRQ flag (0-7, with bit 4 set for rel) or pin
+// (0-31).
+func evalIndex(eval evalFunc, index expr, rel, isIRQ bool) (uint16, error) {
+	if !isIRQ {
+		idx, err := eval(index, 0, 31)
+		return uint16(idx), err
+	}
+	idx, err := eval(index, 0, 7)
+	if rel {
+		idx |= 0x10
+	}
+	return uint16(idx), err
+}
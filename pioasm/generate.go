@@ -0,0 +1,103 @@
+package pioasm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// GenerateGo returns Go source code for all programs in the file, for use
+// with the PIO API of the machine package. For each program it declares:
+//
+//	const <name>WrapTarget, <name>Wrap   // wrap addresses
+//	const <name><Define>                 // public defines
+//	const <name>Offset<Label>            // public labels
+//	var <name>Program machine.PIOProgram
+//	func <name>ProgramDefaultConfig(offset uint8) machine.PIOStateMachineConfig
+//
+// Names are converted from snake_case to camelCase. The source is the name of
+// the .pio file, which is mentioned in the header.
+func GenerateGo(file *File, pkg, source string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by tinygo pioasm from %s. DO NOT EDIT.\n\n", source)
+	buf.WriteString("//go:build rp2040\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	buf.WriteString("import \"machine\"\n")
+
+	if len(file.Defines) != 0 {
+		buf.WriteString("\nconst (\n")
+		for _, d := range file.Defines {
+			fmt.Fprintf(buf, "\t%s = %d\n", goName(d.Name, false), d.Value)
+		}
+		buf.WriteString(")\n")
+	}
+
+	for _, prog := range file.Programs {
+		name := goName(prog.Name, false)
+		fmt.Fprintf(buf, "\n// %s\n\n", prog.Name)
+		buf.WriteString("const (\n")
+		fmt.Fprintf(buf, "\t%sWrapTarget = %d\n", name, prog.WrapTarget)
+		fmt.Fprintf(buf, "\t%sWrap = %d\n", name, prog.Wrap)
+		for _, d := range prog.Defines {
+			fmt.Fprintf(buf, "\t%s%s = %d\n", name, goName(d.Name, true), d.Value)
+		}
+		for _, label := range prog.Labels {
+			fmt.Fprintf(buf, "\t%sOffset%s = %d\n", name, goName(label.Name, true), label.Value)
+		}
+		buf.WriteString(")\n\n")
+
+		fmt.Fprintf(buf, "var %sProgram = machine.PIOProgram{\n", name)
+		buf.WriteString("\tInstructions: []uint16{\n")
+		for i, inst := range prog.Instructions {
+			if i == prog.WrapTarget {
+				buf.WriteString("\t\t//     .wrap_target\n")
+			}
+			fmt.Fprintf(buf, "\t\t0x%04x, // %2d: %s\n", inst.Code, i, inst.Source)
+			if i == prog.Wrap {
+				buf.WriteString("\t\t//     .wrap\n")
+			}
+		}
+		buf.WriteString("\t},\n")
+		fmt.Fprintf(buf, "\tOrigin: %d,\n", prog.Origin)
+		buf.WriteString("}\n\n")
+
+		fmt.Fprintf(buf, "// %sProgramDefaultConfig returns the default state machine configuration\n", name)
+		buf.WriteString("// for the program loaded at the given offset.\n")
+		fmt.Fprintf(buf, "func %sProgramDefaultConfig(offset uint8) machine.PIOStateMachineConfig {\n", name)
+		buf.WriteString("\tcfg := machine.DefaultPIOStateMachineConfig()\n")
+		fmt.Fprintf(buf, "\tcfg.SetWrap(offset+%sWrapTarget, offset+%sWrap)\n", name, name)
+		if prog.SideSet.Count != 0 {
+			fmt.Fprintf(buf, "\tcfg.SetSideset(%d, %t, %t)\n", prog.SideSet.Bits(), prog.SideSet.Optional, prog.SideSet.Pindirs)
+		}
+		buf.WriteString("\treturn cfg\n")
+		buf.WriteString("}\n")
+	}
+
+	for _, code := range file.GoCode {
+		buf.WriteString("\n")
+		buf.WriteString(code)
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format generated code (is there an error in a %% go code block?): %w", err)
+	}
+	return out, nil
+}
+
+// Convert a snake_case name to camelCase. The first letter is only changed to
+// upper case if upper is set.
+func goName(name string, upper bool) string {
+	var b strings.Builder
+	for i, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if i != 0 || upper {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,733 @@
+// Package pioasm implements an assembler for the PIO (programmable I/O) blocks
+// of the RP2040, which accepts the same language as pioasm from the Pico SDK.
+// The assembled programs can be converted to Go code for use with the PIO API
+// in the machine package.
+package pioasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// File is a parsed .pio file, which may contain multiple programs.
+type File struct {
+	Programs []*Program
+
+	// Public defines outside of a program.
+	Defines []Symbol
+
+	// Code blocks for the Go language (% go { ... %}), in source order.
+	GoCode []string
+}
+
+// Program is a single assembled PIO program.
+type Program struct {
+	Name         string
+	Instructions []Instruction
+
+	// Origin is the address the program must be loaded at, or -1 if it can be
+	// loaded anywhere.
+	Origin int
+
+	// WrapTarget and Wrap are the instruction indices where the program wraps
+	// (relative to the start of the program).
+	WrapTarget int
+	Wrap       int
+
+	SideSet SideSet
+
+	// Public defines and labels.
+	Defines []Symbol
+	Labels  []Symbol
+}
+
+// SideSet is the side-set configuration of a program.
+type SideSet struct {
+	Count    int  // number of side-set pins, excluding the enable bit
+	Optional bool // whether an enable bit is used (opt)
+	Pindirs  bool // whether side-set changes pin directions
+}
+
+// Bits returns the number of bits used for side-set, including the enable
+// bit.
+func (s SideSet) Bits() int {
+	if s.Optional {
+		return s.Count + 1
+	}
+	return s.Count
+}
+
+// Instruction is a single encoded instruction.
+type Instruction struct {
+	Code   uint16
+	Source string // source text, without comments
+	Line   int
+}
+
+// Symbol is a public define or label.
+type Symbol struct {
+	Name  string
+	Value int
+}
+
+// Error is an error at a specific line of a .pio file.
+type Error struct {
+	Filename string
+	Line     int
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Filename, e.Line, e.Msg)
+}
+
+// Parse assembles all programs in a .pio file. The filename is only used for
+// error messages.
+func Parse(filename string, src []byte) (*File, error) {
+	p := &parser{
+		filename: filename,
+		file:     &File{},
+		globals:  map[string]int{},
+	}
+	err := p.parse(stripBlockComments(string(src)))
+	if err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+type parser struct {
+	filename string
+	file     *File
+	globals  map[string]int
+	prog     *programBuilder
+}
+
+// programBuilder collects the lines of a program, which are assembled once
+// all labels are known.
+type programBuilder struct {
+	*Program
+	line          int
+	origin        expr
+	sideSet       expr
+	defines       []define
+	labels        map[string]int
+	publicLabels  []string
+	instructions  []pendingInstruction
+	hasWrap       bool
+	hasWrapTarget bool
+}
+
+type define struct {
+	line   int
+	name   string
+	value  expr
+	public bool
+}
+
+type pendingInstruction struct {
+	line   int
+	source string
+	encode func(eval evalFunc) (uint16, error)
+	side   expr
+	delay  expr
+	raw    bool // .word, which has no side-set or delay
+}
+
+type evalFunc func(e expr, min, max int) (int, error)
+
+func (p *parser) errorf(line int, format string, args ...interface{}) error {
+	return &Error{Filename: p.filename, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Replace /* */ comments with spaces, keeping newlines so that line numbers
+// stay the same.
+func stripBlockComments(src string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(src, "/*")
+		if start < 0 {
+			b.WriteString(src)
+			return b.String()
+		}
+		b.WriteString(src[:start])
+		end := strings.Index(src[start+2:], "*/")
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start + 4
+		}
+		b.WriteString(strings.Repeat("\n", strings.Count(src[start:end], "\n")))
+		src = src[end:]
+	}
+}
+
+func (p *parser) parse(src string) error {
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := strings.TrimSpace(lines[i])
+
+		// Language specific code block.
+		if strings.HasPrefix(line, "%") {
+			fields := strings.Fields(line[1:])
+			if len(fields) != 2 || fields[1] != "{" {
+				return p.errorf(lineNum, "expected %% <language> {")
+			}
+			var code []string
+			for i++; ; i++ {
+				if i == len(lines) {
+					return p.errorf(lineNum, "unterminated code block")
+				}
+				if strings.TrimSpace(lines[i]) == "%}" {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			if fields[0] == "go" {
+				p.file.GoCode = append(p.file.GoCode, strings.Join(code, "\n")+"\n")
+			}
+			continue
+		}
+
+		toks, err := lex(line)
+		if err != nil {
+			return p.errorf(lineNum, "%v", err)
+		}
+		if len(toks) == 0 {
+			continue
+		}
+		if err := p.parseLine(lineNum, toks); err != nil {
+			return err
+		}
+	}
+	return p.finishProgram()
+}
+
+func (p *parser) parseLine(line int, toks []token) error {
+	r := &tokenReader{toks: toks}
+	if r.peek().kind == tokenDirective {
+		return p.parseDirective(line, r)
+	}
+
+	// Label, optionally followed by an instruction.
+	public := false
+	if len(toks) >= 3 && toks[0].isWord("public") && toks[2].isPunct(":") {
+		public = true
+		r.next()
+	}
+	if len(toks) >= 2 && r.peek().kind == tokenIdent && r.peekN(1).isPunct(":") {
+		name := r.next().text
+		r.next()
+		if p.prog == nil {
+			return p.errorf(line, "label %s outside of a program", name)
+		}
+		if _, ok := p.prog.labels[name]; ok {
+			return p.errorf(line, "duplicate label %s", name)
+		}
+		p.prog.labels[name] = len(p.prog.instructions)
+		if public {
+			p.prog.publicLabels = append(p.prog.publicLabels, name)
+		}
+		if r.done() {
+			return nil
+		}
+	}
+
+	if p.prog == nil {
+		return p.errorf(line, "instruction outside of a program")
+	}
+	r.start = r.pos
+	inst, err := parseInstruction(r)
+	if err != nil {
+		return p.errorf(line, "%v", err)
+	}
+	inst.line = line
+	inst.source = tokensString(toks[r.start:])
+	p.prog.instructions = append(p.prog.instructions, inst)
+	return nil
+}
+
+func (p *parser) parseDirective(line int, r *tokenReader) error {
+	directive := strings.ToLower(r.next().text)
+	if directive != ".program" && directive != ".define" && p.prog == nil {
+		return p.errorf(line, "%s outside of a program", directive)
+	}
+	var err error
+	switch directive {
+	case ".program":
+		if err := p.finishProgram(); err != nil {
+			return err
+		}
+		name := r.next()
+		if name.kind != tokenIdent || !r.done() {
+			return p.errorf(line, "expected .program <name>")
+		}
+		p.prog = &programBuilder{
+			Program: &Program{Name: name.text, Origin: -1},
+			line:    line,
+			labels:  map[string]int{},
+		}
+		return nil
+	case ".define":
+		d := define{line: line}
+		if r.peek().isWord("public") {
+			r.next()
+			d.public = true
+		}
+		name := r.next()
+		if name.kind != tokenIdent {
+			return p.errorf(line, "expected .define [public] <name> <value>")
+		}
+		d.name = name.text
+		if d.value, err = parseExpr(r); err != nil {
+			break
+		}
+		if p.prog != nil {
+			p.prog.defines = append(p.prog.defines, d)
+			break
+		}
+		// A define outside of a program applies to all following programs.
+		value, err := d.value.eval(p.globals)
+		if err != nil {
+			return p.errorf(line, "%v", err)
+		}
+		p.globals[d.name] = value
+		if d.public {
+			p.file.Defines = append(p.file.Defines, Symbol{Name: d.name, Value: value})
+		}
+	case ".origin":
+		p.prog.origin, err = parseExpr(r)
+	case ".side_set":
+		if p.prog.sideSet, err = parseExpr(r); err != nil {
+			break
+		}
+		for !r.done() {
+			switch word := r.next(); {
+			case word.isWord("opt"):
+				p.prog.SideSet.Optional = true
+			case word.isWord("pindirs"):
+				p.prog.SideSet.Pindirs = true
+			default:
+				return p.errorf(line, "unexpected %s in .side_set", word.text)
+			}
+		}
+	case ".wrap_target":
+		if p.prog.hasWrapTarget {
+			return p.errorf(line, "duplicate .wrap_target")
+		}
+		p.prog.hasWrapTarget = true
+		p.prog.WrapTarget = len(p.prog.instructions)
+	case ".wrap":
+		if p.prog.hasWrap {
+			return p.errorf(line, "duplicate .wrap")
+		}
+		if len(p.prog.instructions) == 0 {
+			return p.errorf(line, ".wrap must follow an instruction")
+		}
+		p.prog.hasWrap = true
+		p.prog.Wrap = len(p.prog.instructions) - 1
+	case ".word":
+		value, err := parseExpr(r)
+		if err != nil {
+			return p.errorf(line, "%v", err)
+		}
+		p.prog.instructions = append(p.prog.instructions, pendingInstruction{
+			line:   line,
+			source: tokensString(r.toks),
+			raw:    true,
+			encode: func(eval evalFunc) (uint16, error) {
+				v, err := eval(value, 0, 0xffff)
+				return uint16(v), err
+			},
+		})
+	case ".lang_opt":
+		// Options for other languages, which don't apply to Go.
+		return nil
+	default:
+		return p.errorf(line, "unknown directive %s", directive)
+	}
+	if err != nil {
+		return p.errorf(line, "%v", err)
+	}
+	if !r.done() {
+		return p.errorf(line, "unexpected %s after %s", r.peek().text, directive)
+	}
+	return nil
+}
+
+// Assemble the current program, now that all labels are known.
+func (p *parser) finishProgram() error {
+	b := p.prog
+	if b == nil {
+		return nil
+	}
+	p.prog = nil
+	prog := b.Program
+
+	symbols := map[string]int{}
+	for name, value := range p.globals {
+		symbols[name] = value
+	}
+	for name, index := range b.labels {
+		symbols[name] = index
+	}
+	for _, d := range b.defines {
+		value, err := d.value.eval(symbols)
+		if err != nil {
+			return p.errorf(d.line, "%v", err)
+		}
+		symbols[d.name] = value
+		if d.public {
+			prog.Defines = append(prog.Defines, Symbol{Name: d.name, Value: value})
+		}
+	}
+	for _, name := range b.publicLabels {
+		prog.Labels = append(prog.Labels, Symbol{Name: name, Value: b.labels[name]})
+	}
+
+	line := b.line
+	eval := func(e expr, min, max int) (int, error) {
+		value, err := e.eval(symbols)
+		if err != nil {
+			return 0, err
+		}
+		if value < min || value > max {
+			return 0, fmt.Errorf("value %d out of range %d..%d", value, min, max)
+		}
+		return value, nil
+	}
+	if b.origin != nil {
+		origin, err := eval(b.origin, 0, 31)
+		if err != nil {
+			return p.errorf(line, ".origin: %v", err)
+		}
+		prog.Origin = origin
+	}
+	if b.sideSet != nil {
+		count, err := eval(b.sideSet, 0, 5)
+		if err != nil {
+			return p.errorf(line, ".side_set: %v", err)
+		}
+		prog.SideSet.Count = count
+		if prog.SideSet.Bits() > 5 {
+			return p.errorf(line, ".side_set: at most 4 pins can be used with opt")
+		}
+	}
+
+	if len(b.instructions) == 0 {
+		return p.errorf(line, "program %s has no instructions", prog.Name)
+	}
+	if len(b.instructions) > 32 {
+		return p.errorf(b.instructions[32].line, "program %s has more than 32 instructions", prog.Name)
+	}
+	if !b.hasWrap {
+		prog.Wrap = len(b.instructions) - 1
+	}
+	if prog.WrapTarget >= len(b.instructions) {
+		return p.errorf(line, ".wrap_target must be followed by an instruction")
+	}
+
+	sideBits := prog.SideSet.Bits()
+	delayMax := 1<<(5-sideBits) - 1
+	for _, inst := range b.instructions {
+		code, err := inst.encode(eval)
+		if err != nil {
+			return p.errorf(inst.line, "%v", err)
+		}
+		if !inst.raw {
+			field := 0
+			if inst.side != nil {
+				if prog.SideSet.Count == 0 {
+					return p.errorf(inst.line, "side-set used without .side_set directive")
+				}
+				side, err := eval(inst.side, 0, 1<<prog.SideSet.Count-1)
+				if err != nil {
+					return p.errorf(inst.line, "side-set: %v", err)
+				}
+				if prog.SideSet.Optional {
+					field |= 0x10
+				}
+				field |= side << (5 - sideBits)
+			} else if prog.SideSet.Count != 0 && !prog.SideSet.Optional {
+				return p.errorf(inst.line, "side-set is required, because .side_set is not opt")
+			}
+			if inst.delay != nil {
+				delay, err := eval(inst.delay, 0, delayMax)
+				if err != nil {
+					return p.errorf(inst.line, "delay: %v", err)
+				}
+				field |= delay
+			}
+			code |= uint16(field) << 8
+		}
+		prog.Instructions = append(prog.Instructions, Instruction{
+			Code:   code,
+			Source: inst.source,
+			Line:   inst.line,
+		})
+	}
+	p.file.Programs = append(p.file.Programs, prog)
+	return nil
+}
+
+// Format tokens as source text, with normalized spacing.
+func tokensString(toks []token) string {
+	var b strings.Builder
+	for i, tok := range toks {
+		if i != 0 && !tok.isPunct(",") && !tok.isPunct("]") && !toks[i-1].isPunct("[") &&
+			!toks[i-1].isPunct("!") && !toks[i-1].isPunct("~") && !toks[i-1].isPunct("::") &&
+			!tok.isPunct("--") && !tok.isPunct("!=") && !toks[i-1].isPunct("!=") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenDirective
+	tokenNumber
+	tokenPunct
+	tokenEOF
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value int
+}
+
+// Whether this is the given keyword. Keywords are case insensitive.
+func (t token) isWord(word string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, word)
+}
+
+func (t token) isPunct(punct string) bool {
+	return t.kind == tokenPunct && t.text == punct
+}
+
+// Split a line into tokens, stopping at a comment.
+func lex(line string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == ';' || strings.HasPrefix(line[i:], "//"):
+			return toks, nil
+		case isIdentChar(c) && !isDigit(c) || c == '.':
+			start := i
+			for i++; i < len(line) && isIdentChar(line[i]); i++ {
+			}
+			kind := tokenIdent
+			if c == '.' {
+				kind = tokenDirective
+			}
+			toks = append(toks, token{kind: kind, text: line[start:i]})
+		case isDigit(c):
+			start := i
+			for i++; i < len(line) && isIdentChar(line[i]); i++ {
+			}
+			value, err := strconv.ParseInt(line[start:i], 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s", line[start:i])
+			}
+			toks = append(toks, token{kind: tokenNumber, text: line[start:i], value: int(value)})
+		default:
+			punct := ""
+			for _, p := range []string{"--", "!=", "::", "!", "~", ",", ":", "[", "]", "(", ")", "+", "-", "*", "/", "="} {
+				if strings.HasPrefix(line[i:], p) {
+					punct = p
+					break
+				}
+			}
+			if punct == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			toks = append(toks, token{kind: tokenPunct, text: punct})
+			i += len(punct)
+		}
+	}
+	return toks, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+type tokenReader struct {
+	toks  []token
+	pos   int
+	start int // start of the instruction (after the label)
+}
+
+func (r *tokenReader) peekN(n int) token {
+	if r.pos+n >= len(r.toks) {
+		return token{kind: tokenEOF, text: "end of line"}
+	}
+	return r.toks[r.pos+n]
+}
+
+func (r *tokenReader) peek() token {
+	return r.peekN(0)
+}
+
+func (r *tokenReader) next() token {
+	tok := r.peek()
+	if r.pos < len(r.toks) {
+		r.pos++
+	}
+	return tok
+}
+
+func (r *tokenReader) done() bool {
+	return r.pos >= len(r.toks)
+}
+
+// Consume the next token if it is one of the given keywords, and return it in
+// lower case.
+func (r *tokenReader) acceptWord(words ...string) string {
+	for _, word := range words {
+		if r.peek().isWord(word) {
+			r.next()
+			return word
+		}
+	}
+	return ""
+}
+
+func (r *tokenReader) acceptPunct(punct string) bool {
+	if r.peek().isPunct(punct) {
+		r.next()
+		return true
+	}
+	return false
+}
+
+// An expression, which is evaluated once all symbols are known.
+type expr interface {
+	eval(symbols map[string]int) (int, error)
+}
+
+type numberExpr int
+
+func (e numberExpr) eval(symbols map[string]int) (int, error) {
+	return int(e), nil
+}
+
+type symbolExpr string
+
+func (e symbolExpr) eval(symbols map[string]int) (int, error) {
+	value, ok := symbols[string(e)]
+	if !ok {
+		return 0, fmt.Errorf("undefined symbol %s", string(e))
+	}
+	return value, nil
+}
+
+type negExpr struct {
+	x expr
+}
+
+func (e negExpr) eval(symbols map[string]int) (int, error) {
+	x, err := e.x.eval(symbols)
+	return -x, err
+}
+
+type binaryExpr struct {
+	op   string
+	x, y expr
+}
+
+func (e binaryExpr) eval(symbols map[string]int) (int, error) {
+	x, err := e.x.eval(symbols)
+	if err != nil {
+		return 0, err
+	}
+	y, err := e.y.eval(symbols)
+	if err != nil {
+		return 0, err
+	}
+	switch e.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	default: // "/"
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return x / y, nil
+	}
+}
+
+// Parse an expression with the usual precedence of + - * /.
+func parseExpr(r *tokenReader) (expr, error) {
+	x, err := parseTerm(r)
+	if err != nil {
+		return nil, err
+	}
+	for r.peek().isPunct("+") || r.peek().isPunct("-") {
+		op := r.next().text
+		y, err := parseTerm(r)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op, x, y}
+	}
+	return x, nil
+}
+
+func parseTerm(r *tokenReader) (expr, error) {
+	x, err := parseUnary(r)
+	if err != nil {
+		return nil, err
+	}
+	for r.peek().isPunct("*") || r.peek().isPunct("/") {
+		op := r.next().text
+		y, err := parseUnary(r)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op, x, y}
+	}
+	return x, nil
+}
+
+func parseUnary(r *tokenReader) (expr, error) {
+	tok := r.next()
+	switch {
+	case tok.kind == tokenNumber:
+		return numberExpr(tok.value), nil
+	case tok.kind == tokenIdent:
+		return symbolExpr(tok.text), nil
+	case tok.isPunct("-"):
+		x, err := parseUnary(r)
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{x}, nil
+	case tok.isPunct("("):
+		x, err := parseExpr(r)
+		if err != nil {
+			return nil, err
+		}
+		if !r.acceptPunct(")") {
+			return nil, fmt.Errorf("expected ) but found %s", r.peek().text)
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("expected a value but found %s", tok.text)
+}
@@ -0,0 +1,229 @@
+package pioasm
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var flagUpdate = flag.Bool("update", false, "update tests based on test output")
+
+// Encodings as produced by pioasm from the Pico SDK.
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		source string
+		code   uint16
+	}{
+		{"nop", 0xa042},
+		{"jmp 3", 0x0003},
+		{"jmp !x 3", 0x0023},
+		{"jmp x-- 3", 0x0043},
+		{"jmp !y, 3", 0x0063},
+		{"jmp y-- 3", 0x0083},
+		{"jmp x!=y 3", 0x00a3},
+		{"jmp pin 3", 0x00c3},
+		{"jmp !osre 3", 0x00e3},
+		{"jmp label", 0x0001},
+		{"wait 1 gpio 0", 0x2080},
+		{"wait 0 pin 2", 0x2022},
+		{"wait 1 irq 4", 0x20c4},
+		{"wait 1 irq 4 rel", 0x20d4},
+		{"in pins, 8", 0x4008},
+		{"in null, 32", 0x4060},
+		{"in osr 1", 0x40e1},
+		{"out x, 1", 0x6021},
+		{"out pindirs, 2", 0x6082},
+		{"out pc, 5", 0x60a5},
+		{"out exec, 16", 0x60f0},
+		{"push", 0x8020},
+		{"push block", 0x8020},
+		{"push iffull noblock", 0x8040},
+		{"pull", 0x80a0},
+		{"pull noblock", 0x8080},
+		{"pull ifempty block", 0x80e0},
+		{"mov x, y", 0xa022},
+		{"mov x, ~y", 0xa02a},
+		{"mov x, !y", 0xa02a},
+		{"mov isr, ::osr", 0xa0d7},
+		{"mov exec, x", 0xa081},
+		{"mov pc, null", 0xa0a3},
+		{"mov y, status", 0xa045},
+		{"irq 5", 0xc005},
+		{"irq set 5", 0xc005},
+		{"irq nowait 1", 0xc001},
+		{"irq wait 1", 0xc021},
+		{"irq clear 3", 0xc043},
+		{"irq wait 0 rel", 0xc030},
+		{"set pins, 0", 0xe000},
+		{"set pindirs, 1", 0xe081},
+		{"set x, 31", 0xe03f},
+		{"set y, 0b101", 0xe045},
+		{"SET Y, 0x1f", 0xe05f},
+		{"set x, (3 + 1) * 2 - 1", 0xe027},
+		{"nop [31]", 0xbf42},
+		{".word 0x1234", 0x1234},
+	} {
+		src := ".program test\n" + tc.source + "\nlabel:\nnop\n"
+		file, err := Parse("test.pio", []byte(src))
+		if err != nil {
+			t.Errorf("%s: %v", tc.source, err)
+			continue
+		}
+		code := file.Programs[0].Instructions[0].Code
+		if code != tc.code {
+			t.Errorf("%s: expected 0x%04x, got 0x%04x", tc.source, tc.code, code)
+		}
+	}
+}
+
+func TestSideSet(t *testing.T) {
+	for _, tc := range []struct {
+		sideSet string
+		source  string
+		code    uint16
+	}{
+		{".side_set 1", "out x, 1 side 0 [2]", 0x6221},
+		{".side_set 1", "jmp !x 0 side 1 [1]", 0x1120},
+		{".side_set 1", "nop [4] side 0", 0xa442},
+		{".side_set 2", "nop side 3 [7]", 0xbf42},
+		{".side_set 1 opt", "nop side 1", 0xb842},
+		{".side_set 1 opt", "nop [7]", 0xa742},
+		{".side_set 5", "nop side 31", 0xbf42},
+	} {
+		src := ".program test\n" + tc.sideSet + "\n" + tc.source + "\n"
+		file, err := Parse("test.pio", []byte(src))
+		if err != nil {
+			t.Errorf("%s: %s: %v", tc.sideSet, tc.source, err)
+			continue
+		}
+		code := file.Programs[0].Instructions[0].Code
+		if code != tc.code {
+			t.Errorf("%s: %s: expected 0x%04x, got 0x%04x", tc.sideSet, tc.source, tc.code, code)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		source string
+		err    string
+	}{
+		{"nop", "test.pio:1: instruction outside of a program"},
+		{".program a\n", "test.pio:1: program a has no instructions"},
+		{".program a\nfoo x", "test.pio:2: unknown instruction foo"},
+		{".program a\njmp missing", "test.pio:2: undefined symbol missing"},
+		{".program a\nset x, 32", "test.pio:2: value 32 out of range 0..31"},
+		{".program a\nout x, 0", "test.pio:2: value 0 out of range 1..32"},
+		{".program a\nmov x, status", ""},
+		{".program a\nmov status, x", "test.pio:2: invalid mov destination status"},
+		{".program a\nirq 8", "test.pio:2: value 8 out of range 0..7"},
+		{".program a\nnop [32]", "test.pio:2: delay: value 32 out of range 0..31"},
+		{".program a\n.side_set 2\nnop side 0 [8]", "test.pio:3: delay: value 8 out of range 0..7"},
+		{".program a\n.side_set 1\nnop", "test.pio:3: side-set is required, because .side_set is not opt"},
+		{".program a\nnop side 1", "test.pio:2: side-set used without .side_set directive"},
+		{".program a\n.side_set 1\nnop side 2", "test.pio:3: side-set: value 2 out of range 0..1"},
+		{".program a\nl:\nl:\nnop", "test.pio:3: duplicate label l"},
+		{".program a\n.wrap\nnop", "test.pio:2: .wrap must follow an instruction"},
+		{".program a\n.unknown", "test.pio:2: unknown directive .unknown"},
+		{".program a\nnop $", "test.pio:2: unexpected character '$'"},
+		{".program a\nnop side 0 side 1", "test.pio:2: duplicate side-set"},
+		{".program a\nset x 1 2", "test.pio:2: unexpected 2"},
+		{".program a\n% go {\nnop", "test.pio:2: unterminated code block"},
+		{".program a\n" + strings.Repeat("nop\n", 33), "test.pio:34: program a has more than 32 instructions"},
+	} {
+		_, err := Parse("test.pio", []byte(tc.source))
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != tc.err {
+			t.Errorf("%q: expected error %q, got %q", tc.source, tc.err, msg)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	src, err := os.ReadFile("testdata/ws2812.pio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Parse("ws2812.pio", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Programs) != 2 {
+		t.Fatalf("expected 2 programs, got %d", len(file.Programs))
+	}
+
+	ws2812 := file.Programs[0]
+	var codes []uint16
+	for _, inst := range ws2812.Instructions {
+		codes = append(codes, inst.Code)
+	}
+	expected := []uint16{0x6221, 0x1123, 0x1400, 0xa442}
+	if !equalCodes(codes, expected) {
+		t.Errorf("ws2812: expected %04x, got %04x", expected, codes)
+	}
+	if ws2812.Origin != -1 || ws2812.WrapTarget != 0 || ws2812.Wrap != 3 {
+		t.Errorf("ws2812: unexpected origin %d or wrap %d..%d", ws2812.Origin, ws2812.WrapTarget, ws2812.Wrap)
+	}
+	if ws2812.SideSet != (SideSet{Count: 1}) {
+		t.Errorf("ws2812: unexpected side-set %+v", ws2812.SideSet)
+	}
+	if len(ws2812.Defines) != 3 || ws2812.Defines[1] != (Symbol{"T2", 5}) {
+		t.Errorf("ws2812: unexpected defines %v", ws2812.Defines)
+	}
+
+	uart := file.Programs[1]
+	if uart.Origin != 4 || uart.Wrap != 8 || uart.SideSet.Bits() != 2 || !uart.SideSet.Pindirs {
+		t.Errorf("uart_rx: unexpected program %+v", uart)
+	}
+	if len(uart.Labels) != 2 || uart.Labels[0] != (Symbol{"start", 0}) || uart.Labels[1] != (Symbol{"good_stop", 8}) {
+		t.Errorf("uart_rx: unexpected labels %v", uart.Labels)
+	}
+	if len(file.GoCode) != 1 {
+		t.Errorf("expected one Go code block, got %d", len(file.GoCode))
+	}
+}
+
+func equalCodes(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGenerateGo(t *testing.T) {
+	src, err := os.ReadFile("testdata/ws2812.pio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Parse("ws2812.pio", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := GenerateGo(file, "ws2812", "ws2812.pio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *flagUpdate {
+		if err := os.WriteFile("testdata/ws2812.go.txt", out, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile("testdata/ws2812.go.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, expected) {
+		t.Errorf("output does not match testdata/ws2812.go.txt, run with -update to update:\n%s", out)
+	}
+}
@@ -1,6 +1,9 @@
 // this is intended to be used as wasm32-unknown-unknown module.
 // to compile it, run:
 // tinygo build -size short -o hello-unknown.wasm -target wasm-unknown -gc=leaking -no-debug ./src/examples/hello-wasm-unknown/
+// Use -target wasm-unknown-host instead to import logging, time and randomness
+// from the host and to export malloc and free (see
+// src/runtime/runtime_wasm_unknown.go).
 package main
 
 // Smoke test: make sure the fmt package can be imported (even if it isn't
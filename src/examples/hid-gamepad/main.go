@@ -0,0 +1,80 @@
+package main
+
+import (
+	"machine"
+	"machine/usb/descriptor"
+	"machine/usb/hid/consumer"
+	"machine/usb/hid/custom"
+	"machine/usb/hid/gamepad"
+	"math"
+	"time"
+)
+
+// A gamepad, media keys, and a vendor defined HID interface with a feature
+// report that turns the LED on and off, next to the USB serial port.
+
+var led = machine.LED
+
+var ledApplication = descriptor.HIDApplication{
+	UsagePage: 0xFF00, // vendor defined
+	Usage:     0x01,
+	Fields: []descriptor.HIDField{
+		{
+			Report:         descriptor.HIDReportFeature,
+			Flags:          descriptor.HIDVariable,
+			Usages:         []uint16{0x01},
+			LogicalMinimum: 0,
+			LogicalMaximum: 1,
+			Size:           8,
+			Count:          1,
+		},
+	},
+}
+
+func main() {
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+
+	var b descriptor.Builder
+	if err := b.Add(descriptor.CDCFunction()); err != nil {
+		panic(err)
+	}
+	g, err := gamepad.New(&b)
+	if err != nil {
+		panic(err)
+	}
+	media, err := consumer.New(&b)
+	if err != nil {
+		panic(err)
+	}
+	h, err := custom.New(&b, ledApplication)
+	if err != nil {
+		panic(err)
+	}
+	h.SetReportHandler(func(r *custom.Report) {
+		led.Set(r.Get(0, 0) != 0)
+	})
+	machine.ConfigureUSB(&b)
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	cnt := 0
+	const f = 0.5
+	for range ticker.C {
+		t := float64(cnt) * 0.01
+		g.SetAxis(gamepad.X, int8(127*math.Sin(2*math.Pi*f*t)))
+		g.SetAxis(gamepad.Y, int8(127*math.Cos(2*math.Pi*f*t)))
+		g.SetHat(gamepad.Hat(cnt / 50 % 9))
+		if cnt%100 < 50 {
+			g.Press(0)
+		} else {
+			g.Release(0)
+		}
+		g.Send()
+
+		// Mute and unmute every 10 seconds.
+		if cnt%1000 == 999 {
+			media.Press(consumer.Mute)
+			media.Release()
+		}
+		cnt++
+	}
+}
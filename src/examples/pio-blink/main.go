@@ -0,0 +1,26 @@
+package main
+
+// This example blinks the LED using a PIO state machine, without any help
+// from the CPU after it has been started.
+
+//go:generate tinygo pioasm blink.pio
+
+import (
+	"machine"
+	"time"
+)
+
+func main() {
+	offset, err := machine.PIO0.AddProgram(&blinkProgram)
+	if err != nil {
+		panic(err)
+	}
+	sm, err := machine.PIO0.ClaimStateMachine()
+	if err != nil {
+		panic(err)
+	}
+	blinkProgramInit(sm, offset, machine.LED, 2)
+	for {
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,59 @@
+// This example receives frames on an RS-485 bus, such as Modbus RTU requests,
+// and sends every frame back. It uses 8E1 framing at 19200 baud, and a frame
+// ends when the bus has been idle for 3.5 character times.
+//
+// Connect the UART to the DI and RO pins of an RS-485 transceiver, and the DE
+// pin below to its DE and /RE pins.
+package main
+
+import (
+	"machine"
+	"time"
+)
+
+// change these to test a different UART or pins if available
+var (
+	uart = machine.DefaultUART
+	tx   = machine.UART_TX_PIN
+	rx   = machine.UART_RX_PIN
+	de   = machine.NoPin
+)
+
+const baudRate = 19200
+
+func main() {
+	err := uart.Configure(machine.UARTConfig{
+		BaudRate: baudRate,
+		TX:       tx,
+		RX:       rx,
+		DE:       de,
+		DataBits: 8,
+		StopBits: 1,
+		Parity:   machine.ParityEven,
+		// 3.5 characters of 11 bits.
+		IdleTime: uint64(35 * 11 * time.Second / 10 / baudRate),
+	})
+	if err != nil {
+		println("could not configure UART:", err.Error())
+		return
+	}
+
+	frame := make([]byte, 0, 256)
+	for {
+		for uart.Buffered() > 0 && len(frame) < cap(frame) {
+			c, _ := uart.ReadByte()
+			frame = append(frame, c)
+		}
+
+		events := uart.Events()
+		if events&(machine.UARTError|machine.UARTBreak) != 0 {
+			// A corrupted frame must be ignored.
+			frame = frame[:0]
+		}
+		if events&machine.UARTIdle != 0 && len(frame) != 0 {
+			uart.Write(frame)
+			frame = frame[:0]
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/adc/midi"
+	"machine/usb/descriptor"
+	"time"
+)
+
+// A USB device with a serial port, a MIDI port and a vendor specific
+// interface that echoes the data it receives on its bulk endpoints.
+
+var (
+	vendorIn  = &descriptor.Endpoint{In: true, Type: usb.ENDPOINT_TYPE_BULK, MaxPacketSize: usb.EndpointPacketSize}
+	vendorOut = &descriptor.Endpoint{Type: usb.ENDPOINT_TYPE_BULK, MaxPacketSize: usb.EndpointPacketSize}
+	vendor    = &descriptor.Function{
+		Interfaces: []descriptor.Interface{
+			{
+				Class:     usb.DEVICE_CLASS_VENDOR_SPECIFIC,
+				Endpoints: []*descriptor.Endpoint{vendorIn, vendorOut},
+			},
+		},
+	}
+)
+
+func main() {
+	var b descriptor.Builder
+	for _, f := range []*descriptor.Function{descriptor.CDCFunction(), descriptor.MIDIFunction(), vendor} {
+		if err := b.Add(f); err != nil {
+			panic(err)
+		}
+	}
+
+	m := midi.Port()
+	m.SetRxHandler(func(msg []byte) {
+		// echo MIDI messages
+		m.Write(msg)
+	})
+
+	b.AddHandlers(
+		[]usb.EndpointConfig{
+			{
+				Index:     vendorOut.Number,
+				IsIn:      false,
+				Type:      usb.ENDPOINT_TYPE_BULK,
+				RxHandler: rxHandler,
+			},
+			{
+				Index: vendorIn.Number,
+				IsIn:  true,
+				Type:  usb.ENDPOINT_TYPE_BULK,
+			},
+		},
+		nil)
+	machine.ConfigureUSB(&b)
+
+	for {
+		println("serial port of a composite device")
+		time.Sleep(time.Second)
+	}
+}
+
+var echo [usb.EndpointPacketSize]byte
+
+func rxHandler(b []byte) {
+	n := copy(echo[:], b)
+	machine.SendUSBInPacket(uint32(vendorIn.Number), echo[:n])
+}
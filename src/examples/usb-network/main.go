@@ -0,0 +1,36 @@
+package main
+
+import (
+	"machine"
+	"machine/usb/cdc/ncm"
+	"machine/usb/descriptor"
+	"time"
+)
+
+// Appears as a USB Ethernet adapter, and prints the frames sent by the host.
+// A real program would pass the frames to a TCP/IP stack.
+
+func main() {
+	var b descriptor.Builder
+	b.Add(descriptor.CDCFunction())
+	eth, err := ncm.New(&b, [6]byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x01})
+	if err != nil {
+		panic(err)
+	}
+	machine.ConfigureUSB(&b)
+
+	var frame [ncm.MaxFrameSize]byte
+	for {
+		n, err := eth.ReadPacket(frame[:])
+		if err != nil {
+			println("error:", err.Error())
+			continue
+		}
+		if n == 0 {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		// Destination, source, and EtherType.
+		println("frame of", n, "bytes, type", uint16(frame[12])<<8|uint16(frame[13]))
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"machine"
+	"machine/usb/msc"
+	"time"
+)
+
+// Presents the data area of the flash memory as a USB disk. Format it from the
+// host the first time it is connected.
+
+func main() {
+	disk := msc.New(machine.Flash)
+
+	for {
+		if disk.Ejected() {
+			println("ejected")
+			for disk.Ejected() {
+				time.Sleep(100 * time.Millisecond)
+			}
+			println("loaded")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/descriptor"
+	"machine/usb/dfu"
+	"machine/usb/vendor"
+	"time"
+)
+
+// A USB device with a serial port, a DFU runtime interface (so that
+// "dfu-util -e" restarts it into its bootloader), and a vendor specific
+// interface that echoes the data it receives. Browsers can use the vendor
+// interface with WebUSB, without a driver, and show a notification with the
+// landing page when the device is plugged in.
+
+// Request to the vendor interface that turns the LED on (wValue 1) or off.
+const requestSetLED = 0x01
+
+var led = machine.LED
+
+func main() {
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+
+	var b descriptor.Builder
+	if err := b.Add(descriptor.CDCFunction()); err != nil {
+		panic(err)
+	}
+	b.WebUSB("https://example.com/configurator")
+	if err := dfu.New(&b); err != nil {
+		panic(err)
+	}
+	v, err := vendor.New(&b, "{975f44d9-0d08-43fd-8b3e-127ca8afff9d}")
+	if err != nil {
+		panic(err)
+	}
+	v.SetRxHandler(func(p []byte) {
+		v.Write(p)
+	})
+	v.SetSetupHandler(func(setup usb.Setup) bool {
+		if setup.BmRequestType&usb.REQUEST_TYPE != usb.REQUEST_VENDOR || setup.BRequest != requestSetLED {
+			return false
+		}
+		led.Set(setup.WValueL != 0)
+		machine.SendZlp()
+		return true
+	})
+	machine.ConfigureUSB(&b)
+
+	for {
+		println("serial port of a WebUSB device")
+		time.Sleep(time.Second)
+	}
+}
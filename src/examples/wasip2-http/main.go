@@ -0,0 +1,45 @@
+// This is a wasi:http proxy that responds to every request, and forwards
+// requests for /proxy/<url> using the outgoing handler. To run it, use:
+//
+//	tinygo build -o server.wasm -target=wasip2-http ./src/examples/wasip2-http
+//	wasmtime serve -Scli server.wasm
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/wasihttp"
+	"strings"
+)
+
+func init() {
+	// Send the requests made by proxy (using http.Get) with wasi:http.
+	wasihttp.InstallTransport()
+
+	// The handler must be registered in an init function: the host calls it
+	// without running main.
+	wasihttp.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/proxy/") {
+			proxy(w, strings.TrimPrefix(r.URL.Path, "/proxy/"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s from %s\n", r.Method, r.URL, r.Host)
+	})
+}
+
+func proxy(w http.ResponseWriter, url string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func main() {
+}
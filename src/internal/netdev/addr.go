@@ -0,0 +1,118 @@
+//go:build wasip2
+
+package netdev
+
+import (
+	"net/netip"
+	"syscall"
+
+	"internal/wasi/io/v0.2.0/streams"
+	"internal/wasi/sockets/v0.2.0/network"
+)
+
+func toIPSocketAddress(ip netip.AddrPort) (network.IPSocketAddress, error) {
+	addr := ip.Addr()
+	if !addr.IsValid() {
+		// Unspecified address, for example when listening on ":8080".
+		addr = netip.IPv4Unspecified()
+	}
+	if !addr.Is4() && !addr.Is4In6() {
+		return network.IPSocketAddress{}, syscall.EAFNOSUPPORT
+	}
+	return network.IPSocketAddressIPv4(network.IPv4SocketAddress{
+		Port:    ip.Port(),
+		Address: network.IPv4Address(addr.Unmap().As4()),
+	}), nil
+}
+
+func fromIPSocketAddress(addr network.IPSocketAddress) netip.AddrPort {
+	if v4 := addr.IPv4(); v4 != nil {
+		return netip.AddrPortFrom(netip.AddrFrom4(v4.Address), v4.Port)
+	}
+	if v6 := addr.IPv6(); v6 != nil {
+		return netip.AddrPortFrom(fromIPv6Address(v6.Address), v6.Port)
+	}
+	return netip.AddrPort{}
+}
+
+func fromIPAddress(addr network.IPAddress) netip.Addr {
+	if v4 := addr.IPv4(); v4 != nil {
+		return netip.AddrFrom4(*v4)
+	}
+	if v6 := addr.IPv6(); v6 != nil {
+		return fromIPv6Address(*v6)
+	}
+	return netip.Addr{}
+}
+
+func fromIPv6Address(addr network.IPv6Address) netip.Addr {
+	var b [16]byte
+	for i, part := range addr {
+		b[i*2] = byte(part >> 8)
+		b[i*2+1] = byte(part)
+	}
+	return netip.AddrFrom16(b)
+}
+
+// Convert a wasi:sockets error code to the closest matching errno value.
+func errorCodeToErrno(err network.ErrorCode) syscall.Errno {
+	switch err {
+	case network.ErrorCodeAccessDenied:
+		return syscall.EACCES
+	case network.ErrorCodeNotSupported:
+		return syscall.EOPNOTSUPP
+	case network.ErrorCodeInvalidArgument:
+		return syscall.EINVAL
+	case network.ErrorCodeOutOfMemory:
+		return syscall.ENOMEM
+	case network.ErrorCodeTimeout:
+		return syscall.ETIMEDOUT
+	case network.ErrorCodeConcurrencyConflict:
+		return syscall.EALREADY
+	case network.ErrorCodeWouldBlock:
+		return syscall.EWOULDBLOCK
+	case network.ErrorCodeNewSocketLimit:
+		return syscall.EMFILE
+	case network.ErrorCodeAddressNotBindable:
+		return syscall.EADDRNOTAVAIL
+	case network.ErrorCodeAddressInUse:
+		return syscall.EADDRINUSE
+	case network.ErrorCodeRemoteUnreachable:
+		return syscall.EHOSTUNREACH
+	case network.ErrorCodeConnectionRefused:
+		return syscall.ECONNREFUSED
+	case network.ErrorCodeConnectionReset:
+		return syscall.ECONNRESET
+	case network.ErrorCodeConnectionAborted:
+		return syscall.ECONNABORTED
+	case network.ErrorCodeDatagramTooLarge:
+		return syscall.EMSGSIZE
+	default:
+		return syscall.EIO
+	}
+}
+
+// Errors returned from a name lookup.
+func resolverError(err network.ErrorCode) error {
+	switch err {
+	case network.ErrorCodeNameUnresolvable, network.ErrorCodePermanentResolverFailure:
+		return errNoSuchHost
+	case network.ErrorCodeTemporaryResolverFailure:
+		return syscall.EAGAIN
+	default:
+		return errorCodeToErrno(err)
+	}
+}
+
+// Errors returned from a TCP stream operation. The stream error only contains
+// a resource with a textual description, which is not very useful to
+// callers.
+func streamError(err streams.StreamError) error {
+	if err.Closed() {
+		return syscall.EPIPE
+	}
+	if e := err.LastOperationFailed(); e != nil {
+		e.ResourceDrop()
+	}
+	return syscall.ECONNRESET
+}
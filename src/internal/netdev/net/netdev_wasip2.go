@@ -0,0 +1,10 @@
+//go:build wasip2
+
+// This file is added to the net package when constructing the GOROOT (see
+// loader/goroot.go), because the net package is maintained separately. It
+// makes sure internal/netdev is part of every wasip2 program that uses the net
+// package.
+
+package net
+
+import _ "internal/netdev" // registers itself using useNetdev
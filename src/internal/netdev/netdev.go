@@ -0,0 +1,472 @@
+//go:build wasip2
+
+// Package netdev implements the network device used by the net package on
+// WASI Preview 2, using the wasi:sockets interfaces. The net package imports it
+// on wasip2 (see net/netdev_wasip2.go), and it registers itself (using
+// net.useNetdev) when it is initialized.
+//
+// Blocking operations wait on a pollable. When a scheduler is in use, only the
+// calling goroutine is paused while waiting, so other goroutines keep running.
+package netdev
+
+import (
+	"errors"
+	"internal/cm"
+	"io"
+	"net/netip"
+	"os"
+	"syscall"
+	"time"
+
+	monotonicclock "internal/wasi/clocks/v0.2.0/monotonic-clock"
+	"internal/wasi/io/v0.2.0/poll"
+	"internal/wasi/io/v0.2.0/streams"
+	instancenetwork "internal/wasi/sockets/v0.2.0/instance-network"
+	ipnamelookup "internal/wasi/sockets/v0.2.0/ip-name-lookup"
+	"internal/wasi/sockets/v0.2.0/network"
+	"internal/wasi/sockets/v0.2.0/tcp"
+	tcpcreatesocket "internal/wasi/sockets/v0.2.0/tcp-create-socket"
+	"internal/wasi/sockets/v0.2.0/udp"
+	udpcreatesocket "internal/wasi/sockets/v0.2.0/udp-create-socket"
+)
+
+// Constants used by the net package for Socket and SetSockOpt.
+const (
+	_AF_INET     = 0x2
+	_SOCK_STREAM = 0x1
+	_SOCK_DGRAM  = 0x2
+	_IPPROTO_TCP = 0x06
+	_IPPROTO_UDP = 0x11
+
+	_SOL_SOCKET   = 0x1
+	_SO_KEEPALIVE = 0x9
+)
+
+var (
+	errNoSuchHost    = errors.New("no such host")
+	errNotConnected  = errors.New("socket is not connected")
+	errNotSupported  = errors.New("operation not supported")
+	errInvalidSocket = errors.New("invalid socket")
+)
+
+// The method set of the net.netdever interface.
+type netdever interface {
+	GetHostByName(name string) (netip.Addr, error)
+	Addr() (netip.Addr, error)
+	Socket(domain int, stype int, protocol int) (int, error)
+	Bind(sockfd int, ip netip.AddrPort) error
+	Connect(sockfd int, host string, ip netip.AddrPort) error
+	Listen(sockfd int, backlog int) error
+	Accept(sockfd int) (int, netip.AddrPort, error)
+	Send(sockfd int, buf []byte, flags int, deadline time.Time) (int, error)
+	Recv(sockfd int, buf []byte, flags int, deadline time.Time) (int, error)
+	Close(sockfd int) error
+	SetSockOpt(sockfd int, level int, opt int, value interface{}) error
+}
+
+//go:linkname useNetdev net.useNetdev
+func useNetdev(dev netdever)
+
+// Wait until one of the given pollables is ready. This is implemented in the
+// runtime (see netdev_pollableWait), which integrates it with the scheduler.
+func pollableWait(pollables []poll.Pollable)
+
+func init() {
+	useNetdev(&device{
+		network: instancenetwork.InstanceNetwork(),
+		sockets: make(map[int]*socket),
+		nextFd:  1,
+	})
+}
+
+// device implements the netdever interface for the net package.
+type device struct {
+	network network.Network
+	sockets map[int]*socket
+	nextFd  int
+}
+
+// socket is a single TCP or UDP socket. The streams are only valid once the
+// socket has been connected (or accepted).
+type socket struct {
+	stype     int
+	tcp       tcp.TCPSocket
+	udp       udp.UDPSocket
+	bound     bool
+	connected bool
+
+	// TCP streams.
+	in  streams.InputStream
+	out streams.OutputStream
+
+	// UDP streams.
+	incoming udp.IncomingDatagramStream
+	outgoing udp.OutgoingDatagramStream
+}
+
+func (d *device) socket(sockfd int) (*socket, error) {
+	sock := d.sockets[sockfd]
+	if sock == nil {
+		return nil, errInvalidSocket
+	}
+	return sock, nil
+}
+
+func (d *device) addSocket(sock *socket) int {
+	fd := d.nextFd
+	d.nextFd++
+	d.sockets[fd] = sock
+	return fd
+}
+
+// GetHostByName resolves the given host name to an IP address, preferring
+// IPv4 addresses as only IPv4 sockets can be created.
+func (d *device) GetHostByName(name string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(name); err == nil {
+		return addr, nil
+	}
+
+	result := ipnamelookup.ResolveAddresses(d.network, name)
+	if err := result.Err(); err != nil {
+		return netip.Addr{}, resolverError(*err)
+	}
+	stream := *result.OK()
+	defer stream.ResourceDrop()
+
+	var found netip.Addr
+	for {
+		result := stream.ResolveNextAddress()
+		if err := result.Err(); err != nil {
+			if *err == network.ErrorCodeWouldBlock {
+				wait(stream.Subscribe(), time.Time{})
+				continue
+			}
+			return netip.Addr{}, resolverError(*err)
+		}
+		next := result.OK().Some()
+		if next == nil {
+			// End of the list.
+			break
+		}
+		addr := fromIPAddress(*next)
+		if addr.Is4() {
+			return addr, nil
+		}
+		if !found.IsValid() {
+			found = addr
+		}
+	}
+	if !found.IsValid() {
+		return netip.Addr{}, errNoSuchHost
+	}
+	return found, nil
+}
+
+// Addr returns the IP address of this device. WASI does not provide a way to
+// query the addresses of the network interfaces, so this is always the
+// unspecified IPv4 address.
+func (d *device) Addr() (netip.Addr, error) {
+	return netip.IPv4Unspecified(), nil
+}
+
+func (d *device) Socket(domain int, stype int, protocol int) (int, error) {
+	if domain != _AF_INET {
+		return -1, syscall.EAFNOSUPPORT
+	}
+	sock := &socket{stype: stype}
+	switch {
+	case stype == _SOCK_STREAM && (protocol == 0 || protocol == _IPPROTO_TCP):
+		result := tcpcreatesocket.CreateTCPSocket(network.IPAddressFamilyIPv4)
+		if err := result.Err(); err != nil {
+			return -1, errorCodeToErrno(*err)
+		}
+		sock.tcp = *result.OK()
+	case stype == _SOCK_DGRAM && (protocol == 0 || protocol == _IPPROTO_UDP):
+		result := udpcreatesocket.CreateUDPSocket(network.IPAddressFamilyIPv4)
+		if err := result.Err(); err != nil {
+			return -1, errorCodeToErrno(*err)
+		}
+		sock.udp = *result.OK()
+	default:
+		return -1, syscall.EPROTONOSUPPORT
+	}
+	return d.addSocket(sock), nil
+}
+
+func (d *device) Bind(sockfd int, ip netip.AddrPort) error {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	addr, err := toIPSocketAddress(ip)
+	if err != nil {
+		return err
+	}
+	if sock.stype == _SOCK_STREAM {
+		if result := sock.tcp.StartBind(d.network, addr); result.IsErr() {
+			return errorCodeToErrno(*result.Err())
+		}
+		err = finish(sock.tcp.Subscribe, func() *network.ErrorCode {
+			result := sock.tcp.FinishBind()
+			return result.Err()
+		})
+	} else {
+		if result := sock.udp.StartBind(d.network, addr); result.IsErr() {
+			return errorCodeToErrno(*result.Err())
+		}
+		err = finish(sock.udp.Subscribe, func() *network.ErrorCode {
+			result := sock.udp.FinishBind()
+			return result.Err()
+		})
+	}
+	if err != nil {
+		return err
+	}
+	sock.bound = true
+	return nil
+}
+
+// Connect connects the socket to the given address. The host name is not
+// needed: it is only used by devices that implement TLS themselves.
+func (d *device) Connect(sockfd int, host string, ip netip.AddrPort) error {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	if sock.connected {
+		return syscall.EISCONN
+	}
+	addr, err := toIPSocketAddress(ip)
+	if err != nil {
+		return err
+	}
+
+	if sock.stype == _SOCK_DGRAM {
+		return sock.connectUDP(d.network, cm.Some(addr))
+	}
+
+	if result := sock.tcp.StartConnect(d.network, addr); result.IsErr() {
+		return errorCodeToErrno(*result.Err())
+	}
+	for {
+		result := sock.tcp.FinishConnect()
+		if err := result.Err(); err != nil {
+			if *err == network.ErrorCodeWouldBlock {
+				wait(sock.tcp.Subscribe(), time.Time{})
+				continue
+			}
+			return errorCodeToErrno(*err)
+		}
+		sock.in = result.OK().F0
+		sock.out = result.OK().F1
+		sock.connected = true
+		return nil
+	}
+}
+
+func (d *device) Listen(sockfd int, backlog int) error {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	if sock.stype != _SOCK_STREAM {
+		return errNotSupported
+	}
+	if backlog > 0 {
+		// This is only a hint, so ignore errors.
+		sock.tcp.SetListenBacklogSize(uint64(backlog))
+	}
+	if result := sock.tcp.StartListen(); result.IsErr() {
+		return errorCodeToErrno(*result.Err())
+	}
+	return finish(sock.tcp.Subscribe, func() *network.ErrorCode {
+		result := sock.tcp.FinishListen()
+		return result.Err()
+	})
+}
+
+func (d *device) Accept(sockfd int) (int, netip.AddrPort, error) {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	if sock.stype != _SOCK_STREAM {
+		return -1, netip.AddrPort{}, errNotSupported
+	}
+	for {
+		result := sock.tcp.Accept()
+		if err := result.Err(); err != nil {
+			if *err == network.ErrorCodeWouldBlock {
+				wait(sock.tcp.Subscribe(), time.Time{})
+				continue
+			}
+			return -1, netip.AddrPort{}, errorCodeToErrno(*err)
+		}
+		client := &socket{
+			stype:     _SOCK_STREAM,
+			tcp:       result.OK().F0,
+			in:        result.OK().F1,
+			out:       result.OK().F2,
+			bound:     true,
+			connected: true,
+		}
+		var raddr netip.AddrPort
+		if addr := client.tcp.RemoteAddress(); addr.IsOK() {
+			raddr = fromIPSocketAddress(*addr.OK())
+		}
+		return d.addSocket(client), raddr, nil
+	}
+}
+
+func (d *device) Send(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	if !sock.connected {
+		return -1, errNotConnected
+	}
+	if sock.stype == _SOCK_DGRAM {
+		return sock.sendUDP(buf, deadline)
+	}
+
+	written := 0
+	for written < len(buf) {
+		result := sock.out.CheckWrite()
+		if err := result.Err(); err != nil {
+			return written, streamError(*err)
+		}
+		n := int(*result.OK())
+		if n == 0 {
+			if err := wait(sock.out.Subscribe(), deadline); err != nil {
+				return written, err
+			}
+			continue
+		}
+		if n > len(buf)-written {
+			n = len(buf) - written
+		}
+		if result := sock.out.Write(cm.ToList(buf[written : written+n])); result.IsErr() {
+			return written, streamError(*result.Err())
+		}
+		written += n
+	}
+	// Start flushing the data. A following CheckWrite will wait for the flush
+	// to complete.
+	if result := sock.out.Flush(); result.IsErr() {
+		return written, streamError(*result.Err())
+	}
+	return written, nil
+}
+
+func (d *device) Recv(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return -1, err
+	}
+	if sock.stype == _SOCK_DGRAM {
+		return sock.recvUDP(d.network, buf, deadline)
+	}
+	if !sock.connected {
+		return -1, errNotConnected
+	}
+
+	for {
+		result := sock.in.Read(uint64(len(buf)))
+		if err := result.Err(); err != nil {
+			if err.Closed() {
+				return 0, io.EOF
+			}
+			return -1, streamError(*err)
+		}
+		data := result.OK().Slice()
+		if len(data) == 0 && len(buf) != 0 {
+			if err := wait(sock.in.Subscribe(), deadline); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		return copy(buf, data), nil
+	}
+}
+
+func (d *device) Close(sockfd int) error {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	delete(d.sockets, sockfd)
+
+	// Child resources (streams) must be dropped before the socket itself.
+	if sock.stype == _SOCK_STREAM {
+		if sock.connected {
+			sock.in.ResourceDrop()
+			sock.out.ResourceDrop()
+		}
+		sock.tcp.ResourceDrop()
+	} else {
+		if sock.connected {
+			sock.incoming.ResourceDrop()
+			sock.outgoing.ResourceDrop()
+		}
+		sock.udp.ResourceDrop()
+	}
+	return nil
+}
+
+func (d *device) SetSockOpt(sockfd int, level int, opt int, value interface{}) error {
+	sock, err := d.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	switch {
+	case sock.stype == _SOCK_STREAM && level == _SOL_SOCKET && opt == _SO_KEEPALIVE:
+		enabled, ok := value.(bool)
+		if !ok {
+			return syscall.EINVAL
+		}
+		if result := sock.tcp.SetKeepAliveEnabled(enabled); result.IsErr() {
+			return errorCodeToErrno(*result.Err())
+		}
+		return nil
+	default:
+		return errNotSupported
+	}
+}
+
+// Wait until the pollable is ready, or the deadline (if not zero) has passed.
+// The pollable is dropped afterwards.
+func wait(p poll.Pollable, deadline time.Time) error {
+	defer p.ResourceDrop()
+	if deadline.IsZero() {
+		pollableWait([]poll.Pollable{p})
+		return nil
+	}
+
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	timer := monotonicclock.SubscribeDuration(monotonicclock.Duration(timeout))
+	defer timer.ResourceDrop()
+	pollableWait([]poll.Pollable{p, timer})
+	if !p.Ready() {
+		return os.ErrDeadlineExceeded
+	}
+	return nil
+}
+
+// Finish an asynchronous start-*/finish-* operation pair, waiting on the
+// socket pollable while the operation is still in progress.
+func finish(subscribe func() poll.Pollable, finishOp func() *network.ErrorCode) error {
+	for {
+		err := finishOp()
+		if err == nil {
+			return nil
+		}
+		if *err != network.ErrorCodeWouldBlock {
+			return errorCodeToErrno(*err)
+		}
+		wait(subscribe(), time.Time{})
+	}
+}
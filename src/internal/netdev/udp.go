@@ -0,0 +1,104 @@
+//go:build wasip2
+
+package netdev
+
+import (
+	"internal/cm"
+	"time"
+
+	"internal/wasi/sockets/v0.2.0/network"
+	"internal/wasi/sockets/v0.2.0/udp"
+)
+
+// Create the datagram streams of a UDP socket. If a remote address is given,
+// only datagrams from and to this address are allowed. The socket must be
+// bound first: if it isn't, it is bound to an ephemeral port.
+func (sock *socket) connectUDP(n network.Network, remote cm.Option[network.IPSocketAddress]) error {
+	if !sock.bound {
+		local := network.IPSocketAddressIPv4(network.IPv4SocketAddress{})
+		if result := sock.udp.StartBind(n, local); result.IsErr() {
+			return errorCodeToErrno(*result.Err())
+		}
+		err := finish(sock.udp.Subscribe, func() *network.ErrorCode {
+			result := sock.udp.FinishBind()
+			return result.Err()
+		})
+		if err != nil {
+			return err
+		}
+		sock.bound = true
+	}
+
+	if sock.connected {
+		// The previous streams must be dropped before calling stream again.
+		sock.incoming.ResourceDrop()
+		sock.outgoing.ResourceDrop()
+		sock.connected = false
+	}
+	result := sock.udp.Stream(remote)
+	if err := result.Err(); err != nil {
+		return errorCodeToErrno(*err)
+	}
+	sock.incoming = result.OK().F0
+	sock.outgoing = result.OK().F1
+	sock.connected = true
+	return nil
+}
+
+// Send a single datagram to the connected remote address.
+func (sock *socket) sendUDP(buf []byte, deadline time.Time) (int, error) {
+	for {
+		result := sock.outgoing.CheckSend()
+		if err := result.Err(); err != nil {
+			return -1, errorCodeToErrno(*err)
+		}
+		if *result.OK() == 0 {
+			if err := wait(sock.outgoing.Subscribe(), deadline); err != nil {
+				return -1, err
+			}
+			continue
+		}
+		break
+	}
+
+	datagrams := []udp.OutgoingDatagram{{
+		Data:          cm.ToList(buf),
+		RemoteAddress: cm.None[network.IPSocketAddress](),
+	}}
+	result := sock.outgoing.Send(cm.ToList(datagrams))
+	if err := result.Err(); err != nil {
+		return -1, errorCodeToErrno(*err)
+	}
+	if *result.OK() == 0 {
+		return -1, errorCodeToErrno(network.ErrorCodeWouldBlock)
+	}
+	return len(buf), nil
+}
+
+// Receive a single datagram. If the datagram is larger than buf, the rest of
+// the datagram is discarded. A socket that is bound but not connected
+// receives datagrams from any address.
+func (sock *socket) recvUDP(n network.Network, buf []byte, deadline time.Time) (int, error) {
+	if !sock.connected {
+		if !sock.bound {
+			return -1, errNotConnected
+		}
+		if err := sock.connectUDP(n, cm.None[network.IPSocketAddress]()); err != nil {
+			return -1, err
+		}
+	}
+	for {
+		result := sock.incoming.Receive(1)
+		if err := result.Err(); err != nil {
+			return -1, errorCodeToErrno(*err)
+		}
+		datagrams := result.OK().Slice()
+		if len(datagrams) == 0 {
+			if err := wait(sock.incoming.Subscribe(), deadline); err != nil {
+				return -1, err
+			}
+			continue
+		}
+		return copy(buf, datagrams[0].Data.Slice()), nil
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !scheduler.threads
+
+package task
+
+// PMutex is a real mutex on systems that can be either preemptive or threaded,
+// and a dummy lock on other (purely cooperative) systems.
+//
+// It is mainly useful for short operations that need a lock when threading may
+// be involved, but which do not need a lock with a purely cooperative
+// scheduler.
+type PMutex struct {
+}
+
+func (m *PMutex) Lock() {
+}
+
+func (m *PMutex) Unlock() {
+}
+
+// GCStopWorld stops all other goroutines that may be modifying the heap. This
+// is a no-op with a cooperative scheduler: no other goroutine can run while
+// the GC is running.
+func GCStopWorld() {
+}
+
+// GCResumeWorld resumes all goroutines stopped by GCStopWorld.
+func GCResumeWorld() {
+}
+
+// GCScanStacks scans the stacks of goroutines that run on their own thread.
+// With a cooperative scheduler, goroutine stacks are found by the GC in other
+// ways so this does nothing.
+func GCScanStacks(sp uintptr) {
+}
+
+// Init initializes the main goroutine when every goroutine runs in its own
+// thread. It is a no-op with a cooperative scheduler.
+func Init(stackTop uintptr) {
+}
@@ -0,0 +1,70 @@
+//go:build scheduler.threads
+
+package task
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Futex is a 32-bit value that threads can wait on until another thread
+// changes it and wakes them up.
+//
+// Waiting is a blocking operation: the GC may run while a goroutine waits on
+// a futex.
+type Futex struct {
+	atomic.Uint32
+}
+
+// Wait blocks the current goroutine while the futex value is equal to cmp, or
+// until it is woken up by Wake or WakeAll. It may return early, so callers
+// must check the value again after it returns.
+func (f *Futex) Wait(cmp uint32) {
+	gcPark()
+	f.wait(cmp, -1)
+	gcUnpark()
+}
+
+// WaitUntil is like Wait, but also returns when the given number of
+// nanoseconds have passed. It returns false on timeout.
+func (f *Futex) WaitUntil(cmp uint32, timeout uint64) bool {
+	if timeout > 1<<63-1 {
+		timeout = 1<<63 - 1
+	}
+	gcPark()
+	result := f.wait(cmp, int64(timeout))
+	gcUnpark()
+	return result != futexTimedOut
+}
+
+// Wake wakes up one goroutine waiting on this futex.
+func (f *Futex) Wake() {
+	tinygo_futex_wake(unsafe.Pointer(&f.Uint32), 1)
+}
+
+// WakeAll wakes up all goroutines waiting on this futex.
+func (f *Futex) WakeAll() {
+	tinygo_futex_wake(unsafe.Pointer(&f.Uint32), ^uint32(0))
+}
+
+// wait is the raw futex wait, which doesn't let the GC run while it waits. It
+// returns one of the futex* constants below.
+func (f *Futex) wait(cmp uint32, timeout int64) uint32 {
+	return tinygo_futex_wait(unsafe.Pointer(&f.Uint32), cmp, timeout)
+}
+
+// Return values of tinygo_futex_wait, as defined by the WebAssembly threads
+// proposal for memory.atomic.wait32.
+const (
+	futexOK       = 0
+	futexNotEqual = 1
+	futexTimedOut = 2
+)
+
+// Implemented in task_threads_wasm.S.
+//
+//export tinygo_futex_wait
+func tinygo_futex_wait(addr unsafe.Pointer, cmp uint32, timeout int64) uint32
+
+//export tinygo_futex_wake
+func tinygo_futex_wake(addr unsafe.Pointer, count uint32) uint32
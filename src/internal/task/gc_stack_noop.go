@@ -1,8 +1,17 @@
-//go:build !(gc.conservative || gc.custom || gc.precise) || !tinygo.wasm
+//go:build !((gc.conservative || gc.custom || gc.precise) && tinygo.wasm) && !(gc.precise && baremetal && scheduler.tasks)
 
 package task
 
+import "unsafe"
+
 type gcData struct{}
 
+func (gcd *gcData) init(stack, args unsafe.Pointer) {
+}
+
 func (gcd *gcData) swap() {
 }
+
+func allocStack(size uintptr) unsafe.Pointer {
+	return runtime_alloc(size, nil)
+}
@@ -0,0 +1,34 @@
+//go:build gc.precise && baremetal && scheduler.tasks
+
+package task
+
+import "unsafe"
+
+//go:linkname swapStackChain runtime.swapStackChain
+func swapStackChain(dst *unsafe.Pointer)
+
+//go:linkname allocStack runtime.allocGoroutineStack
+func allocStack(size uintptr) unsafe.Pointer
+
+type gcData struct {
+	// stackChain points to the word right after the stack canary. It contains
+	// the stack chain of the goroutine while it is paused (which is where the
+	// GC looks for it), and the stack chain of the system stack while the
+	// goroutine is running.
+	stackChain *unsafe.Pointer
+
+	// args keeps the goroutine arguments alive until the goroutine exits (and
+	// the task is freed). The arguments are only referenced from the saved
+	// registers before the goroutine starts, and may afterwards be loaded into
+	// locals that are not tracked in a stack object.
+	args unsafe.Pointer
+}
+
+func (gcd *gcData) init(stack, args unsafe.Pointer) {
+	gcd.stackChain = (*unsafe.Pointer)(unsafe.Add(stack, unsafe.Sizeof(uintptr(0))))
+	gcd.args = args
+}
+
+func (gcd *gcData) swap() {
+	swapStackChain(gcd.stackChain)
+}
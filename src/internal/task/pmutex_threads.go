@@ -0,0 +1,35 @@
+//go:build scheduler.threads
+
+package task
+
+// PMutex is a real mutex on systems that can be either preemptive or threaded,
+// and a dummy lock on other (purely cooperative) systems.
+//
+// It is mainly useful for short operations that need a lock when threading may
+// be involved, but which do not need a lock with a purely cooperative
+// scheduler.
+type PMutex struct {
+	// futex is 0 when unlocked, 1 when locked, and 2 when locked with other
+	// threads (possibly) waiting for it.
+	futex Futex
+}
+
+func (m *PMutex) Lock() {
+	if m.futex.CompareAndSwap(0, 1) {
+		// Fast path: the mutex was not locked.
+		return
+	}
+	for m.futex.Swap(2) != 0 {
+		m.futex.Wait(2)
+	}
+}
+
+func (m *PMutex) Unlock() {
+	switch m.futex.Swap(0) {
+	case 0:
+		runtimePanic("unlock of unlocked mutex")
+	case 2:
+		// Some threads may be waiting for the lock.
+		m.futex.Wake()
+	}
+}
@@ -0,0 +1,137 @@
+//go:build scheduler.jspi
+
+package task
+
+// This file implements goroutines using the JavaScript Promise Integration
+// (JSPI) proposal. Every goroutine runs on its own WebAssembly stack, which is
+// created by calling the tinygo_jspi_start export wrapped in
+// WebAssembly.promising. A goroutine pauses by calling a suspending import,
+// which suspends the WebAssembly stack until the scheduler resumes it again.
+// Unlike asyncify, this doesn't need any code transformation so it results in
+// smaller and faster binaries. See targets/wasm_exec.js for the JavaScript side.
+
+import (
+	"unsafe"
+)
+
+// Stack canary, to detect a stack overflow. The number is a random number
+// generated by random.org. The bit fiddling dance is necessary because
+// otherwise Go wouldn't allow the cast to a smaller integer size.
+const stackCanary = uintptr(uint64(0x670c1333b83bf575) & uint64(^uintptr(0)))
+
+//go:linkname runtimePanic runtime.runtimePanic
+func runtimePanic(str string)
+
+// state is a structure which holds a reference to the state of the task.
+// The assembly in task_jspi_wasm.S depends on the layout of this struct.
+type state struct {
+	// entry is the entry function of the task.
+	entry uintptr
+
+	// args are a pointer to a struct holding the arguments of the function.
+	args unsafe.Pointer
+
+	// csp is the C stack pointer of the goroutine while it is paused. The C
+	// stack (__stack_pointer) is separate from the WebAssembly stack, and
+	// must be switched manually.
+	csp unsafe.Pointer
+
+	// Pointer to the first (lowest address) of the stack. It must never be
+	// overwritten. It can be checked from time to time to see whether a stack
+	// overflow happened in the past.
+	canaryPtr *uintptr
+
+	launched bool
+}
+
+// start creates and starts a new goroutine with the given function and arguments.
+// The new goroutine is immediately started.
+func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
+	t := &Task{}
+	t.state.initialize(fn, args, stackSize)
+	runqueuePushBack(t)
+}
+
+// initialize the state and prepare to call the specified function with the specified argument bundle.
+func (s *state) initialize(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
+	// Save the entry call.
+	s.entry = fn
+	s.args = args
+
+	// Create a C stack.
+	stack := runtime_alloc(stackSize, nil)
+
+	// Set up the stack canary, a random number that should be checked when
+	// switching from the task back to the scheduler. The stack canary pointer
+	// points to the first word of the stack. If it has changed between now and
+	// the next stack switch, there was a stack overflow.
+	s.canaryPtr = (*uintptr)(stack)
+	*s.canaryPtr = stackCanary
+
+	// The C stack grows downwards.
+	s.csp = unsafe.Add(stack, stackSize)
+}
+
+//go:linkname runqueuePushBack runtime.runqueuePushBack
+func runqueuePushBack(*Task)
+
+// currentTask is the current running task, or nil if currently in the scheduler.
+var currentTask *Task
+
+// Current returns the current active task.
+func Current() *Task {
+	return currentTask
+}
+
+// Pause suspends the current task and returns to the scheduler.
+// This function may only be called when running on a goroutine stack, not when running on the system stack.
+func Pause() {
+	if *currentTask.state.canaryPtr != stackCanary {
+		runtimePanic("stack overflow")
+	}
+
+	currentTask.state.pause()
+}
+
+// Start the goroutine on a new WebAssembly stack. This returns when the
+// goroutine pauses for the first time, or when it exits.
+//
+//export tinygo_jspi_launch
+func (*state) launch()
+
+// Resume the goroutine. This suspends the scheduler until the goroutine pauses
+// again, or exits.
+//
+//export tinygo_jspi_resume
+func (*state) resume()
+
+// Suspend the current goroutine, until it is resumed by the scheduler.
+//
+//export tinygo_jspi_pause
+func (*state) pause()
+
+// Resume the task until it pauses or completes.
+// This may only be called from the scheduler.
+func (t *Task) Resume() {
+	// The current task must be saved and restored because this can nest on WASM with JS.
+	prevTask := currentTask
+	t.gcData.swap()
+	currentTask = t
+	if !t.state.launched {
+		t.state.launched = true
+		t.state.launch()
+	} else {
+		t.state.resume()
+	}
+	currentTask = prevTask
+	t.gcData.swap()
+	if *t.state.canaryPtr != stackCanary {
+		runtimePanic("stack overflow")
+	}
+}
+
+// OnSystemStack returns whether the caller is running on the system stack.
+func OnSystemStack() bool {
+	// If there is not an active goroutine, then this must be running on the system stack.
+	return Current() == nil
+}
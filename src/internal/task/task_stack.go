@@ -43,6 +43,12 @@ func Current() *Task {
 // Pause suspends the current task and returns to the scheduler.
 // This function may only be called when running on a goroutine stack, not when running on the system stack or in an interrupt.
 func Pause() {
+	if currentTask == nil {
+		// For example, an exported function called from C that blocks with
+		// -buildmode=c-archive.
+		runtimePanic("blocked outside goroutine")
+	}
+
 	// Check whether the canary (the lowest address of the stack) is still
 	// valid. If it is not, a stack overflow has occurred.
 	if *currentTask.state.canaryPtr != stackCanary {
@@ -72,7 +78,7 @@ func (t *Task) Resume() {
 // initialize the state and prepare to call the specified function with the specified argument bundle.
 func (s *state) initialize(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	// Create a stack.
-	stack := runtime_alloc(stackSize, nil)
+	stack := allocStack(stackSize)
 
 	// Set up the stack canary, a random number that should be checked when
 	// switching from the task back to the scheduler. The stack canary pointer
@@ -109,6 +115,7 @@ func runqueuePushBack(*Task)
 func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
 	t := &Task{}
 	t.state.initialize(fn, args, stackSize)
+	t.gcData.init(unsafe.Pointer(t.state.canaryPtr), args)
 	runqueuePushBack(t)
 }
 
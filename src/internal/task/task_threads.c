@@ -0,0 +1,36 @@
+//go:build scheduler.threads
+
+#include <pthread.h>
+#include <stdint.h>
+
+// This struct must match the first fields of the state struct in
+// task_threads.go.
+struct state {
+    uintptr_t entry;
+    void *args;
+    void *task;
+};
+
+// Implemented in task_threads.go.
+void tinygo_task_started(void *task);
+void tinygo_task_exited(void *task);
+
+static void *tinygo_task_start(void *arg) {
+    struct state *state = arg;
+    tinygo_task_started(state->task);
+    ((void (*)(void *))state->entry)(state->args);
+    tinygo_task_exited(state->task);
+    return NULL;
+}
+
+// Start a new thread for the given goroutine.
+int tinygo_task_spawn(struct state *state, uintptr_t stackSize) {
+    pthread_attr_t attr;
+    pthread_attr_init(&attr);
+    pthread_attr_setstacksize(&attr, stackSize);
+    pthread_attr_setdetachstate(&attr, PTHREAD_CREATE_DETACHED);
+    pthread_t thread;
+    int result = pthread_create(&thread, &attr, tinygo_task_start, state);
+    pthread_attr_destroy(&attr);
+    return result;
+}
@@ -0,0 +1,259 @@
+//go:build scheduler.threads
+
+package task
+
+// This scheduler runs every goroutine on its own operating system thread,
+// created using pthreads (provided by wasi-libc on WebAssembly).
+//
+// The garbage collector is not concurrent, so it needs to stop all other
+// threads before it can mark and sweep the heap. There is no way to interrupt
+// a thread in WebAssembly, so threads stop cooperatively: a thread counts as
+// stopped while it is blocked (in Pause, while waiting for a lock, or while
+// sleeping). Allocating memory also blocks while the GC is running. This means
+// that a goroutine running a long loop that doesn't allocate or block delays
+// the GC until it does, and so does a goroutine blocked in a system call.
+
+import (
+	"unsafe"
+)
+
+//go:linkname runtimePanic runtime.runtimePanic
+func runtimePanic(str string)
+
+//go:linkname markRoots runtime.markRoots
+func markRoots(start, end uintptr)
+
+//export tinygo_getCurrentStackPointer
+func getCurrentStackPointer() uintptr
+
+// Implemented in task_threads.c.
+//
+//export tinygo_task_spawn
+func tinygo_task_spawn(state *state, stackSize uintptr) int32
+
+// state is the state of a goroutine thread. The first three fields are also
+// used from C, in task_threads.c.
+type state struct {
+	// entry is the goroutine start wrapper that is called in the new thread,
+	// with args as the parameter.
+	entry uintptr
+	args  unsafe.Pointer
+
+	// task is the task this state belongs to.
+	task *Task
+
+	// wakeup is set to 1 by Resume, and reset by Pause.
+	wakeup Futex
+
+	// stackTop is the highest address of the thread stack, and sp is the
+	// stack pointer when the thread was last stopped for the GC. Everything in
+	// between is scanned by the GC.
+	stackTop uintptr
+	sp       uintptr
+
+	// next is the next task in the allTasks list.
+	next *Task
+}
+
+// currentTask is the goroutine running on the current thread.
+//
+//go:threadlocal
+var currentTask *Task
+
+// mainTask is the goroutine of the main thread.
+var mainTask Task
+
+// allTasks is a linked list of all running goroutines. This keeps them
+// reachable for the GC, and is used to find all thread stacks.
+var (
+	allTasks  *Task
+	tasksLock PMutex
+)
+
+// Synchronization with the GC, see gcPark, gcUnpark and GCStopWorld.
+var (
+	// gcStopping is 1 while the GC is running, and other threads must not
+	// touch the heap.
+	gcStopping Futex
+
+	// gcRunning is the number of threads that are currently running Go code,
+	// as opposed to being blocked somewhere.
+	gcRunning Futex
+)
+
+// Init initializes the main thread as a goroutine, with the given stack top.
+// It must be called before any other goroutine is started.
+func Init(stackTop uintptr) {
+	mainTask.state.task = &mainTask
+	mainTask.state.stackTop = stackTop
+	currentTask = &mainTask
+	allTasks = &mainTask
+	gcRunning.Store(1)
+}
+
+// Current returns the current active task.
+func Current() *Task {
+	return currentTask
+}
+
+// start creates and starts a new goroutine with the given function and
+// arguments, in a new thread.
+func start(fn uintptr, args unsafe.Pointer, stackSize uintptr) {
+	t := &Task{}
+	t.state.entry = fn
+	t.state.args = args
+	t.state.task = t
+
+	tasksLock.Lock()
+	t.state.next = allTasks
+	allTasks = t
+	tasksLock.Unlock()
+
+	if tinygo_task_spawn(&t.state, stackSize) != 0 {
+		runtimePanic("could not start thread")
+	}
+}
+
+// taskStarted is called at the start of a new goroutine thread, before the
+// goroutine entry function is called.
+//
+//export tinygo_task_started
+func taskStarted(t *Task) {
+	currentTask = t
+	sp := getCurrentStackPointer()
+	t.state.sp = sp
+	t.state.stackTop = sp
+	gcUnpark()
+}
+
+// taskExited is called at the end of a goroutine thread, after the goroutine
+// entry function returned.
+//
+//export tinygo_task_exited
+func taskExited(t *Task) {
+	tasksLock.Lock()
+	for p := &allTasks; *p != nil; p = &(*p).state.next {
+		if *p == t {
+			*p = t.state.next
+			break
+		}
+	}
+	tasksLock.Unlock()
+
+	// This thread won't run any more goroutine code. It may still call into
+	// the runtime (for example, wasi-libc may call free while the thread
+	// exits), but it isn't a goroutine anymore so it doesn't need to be
+	// stopped by the GC.
+	gcPark()
+	currentTask = nil
+}
+
+// Pause suspends the current goroutine until Resume is called. If Resume was
+// already called, it returns immediately.
+func Pause() {
+	t := currentTask
+	for t.state.wakeup.Swap(0) == 0 {
+		t.state.wakeup.Wait(0)
+	}
+}
+
+// Resume the task. If it isn't paused yet, the next call to Pause will return
+// immediately.
+func (t *Task) Resume() {
+	t.state.wakeup.Store(1)
+	t.state.wakeup.Wake()
+}
+
+// Sleep blocks the current goroutine for the given number of nanoseconds.
+func Sleep(duration int64) {
+	var f Futex
+	f.WaitUntil(0, uint64(duration))
+}
+
+// OnSystemStack returns whether the caller is running on the system stack.
+// Every goroutine runs on the stack of its own thread, so there is no separate
+// system stack.
+func OnSystemStack() bool {
+	return false
+}
+
+// gcPark marks the current thread as stopped for the GC. It must not touch
+// the heap until it calls gcUnpark.
+//
+// The compiler makes sure that callers of this function have all their
+// pointers stored on the stack, so that they can be found by the GC.
+//
+//go:noinline
+func gcPark() {
+	t := currentTask
+	if t == nil {
+		// Not a goroutine thread.
+		return
+	}
+	t.state.sp = getCurrentStackPointer()
+	gcRunning.Add(^uint32(0))
+	if gcStopping.Load() != 0 {
+		// The GC is waiting for this thread to stop.
+		gcRunning.WakeAll()
+	}
+}
+
+// gcUnpark marks the current thread as running Go code again. It waits for
+// the GC to finish if it is running.
+func gcUnpark() {
+	if currentTask == nil {
+		// Not a goroutine thread.
+		return
+	}
+	for {
+		for gcStopping.Load() != 0 {
+			gcStopping.wait(1, -1)
+		}
+		gcRunning.Add(1)
+		if gcStopping.Load() == 0 {
+			return
+		}
+		// The GC started just before this thread was marked as running. Back
+		// off and wait for it to finish.
+		gcRunning.Add(^uint32(0))
+		gcRunning.WakeAll()
+	}
+}
+
+// GCStopWorld waits until all other threads are stopped. It must be called
+// with the GC lock held, so that only one thread can run the GC at a time.
+func GCStopWorld() {
+	self := uint32(0)
+	if currentTask != nil {
+		self = 1
+	}
+	gcStopping.Store(1)
+	for {
+		n := gcRunning.Load()
+		if n == self {
+			// No other thread is running.
+			break
+		}
+		gcRunning.wait(n, -1)
+	}
+}
+
+// GCResumeWorld lets all threads stopped by GCStopWorld continue.
+func GCResumeWorld() {
+	gcStopping.Store(0)
+	gcStopping.WakeAll()
+}
+
+// GCScanStacks scans the stacks of all goroutines. All other threads must be
+// stopped using GCStopWorld. The sp parameter is the stack pointer of the
+// current thread.
+func GCScanStacks(sp uintptr) {
+	for t := allTasks; t != nil; t = t.state.next {
+		if t == currentTask {
+			markRoots(sp, t.state.stackTop)
+		} else if t.state.stackTop != 0 {
+			// The stack top is zero when the thread hasn't started yet.
+			markRoots(t.state.sp, t.state.stackTop)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package machine
+
+import (
+	"internal/task"
+	"runtime/interrupt"
+	"runtime/volatile"
+)
+
+// Completion is the handle of an asynchronous operation, such as a SPI or I2C
+// transaction started with TxAsync. The buffers passed to the operation must
+// not be used until it has completed.
+//
+// A Completion is owned by the peripheral and is reused for its next
+// operation, so it must not be kept after the operation has completed.
+type Completion struct {
+	pending uint8 // accessed with volatile loads and stores
+	err     error
+	waiter  *task.Task
+}
+
+// completed is returned by operations that already finished before returning,
+// for example on chips where they are not (yet) interrupt driven.
+var completed Completion
+
+// Done returns whether the operation has completed. It doesn't block.
+func (c *Completion) Done() bool {
+	return volatile.LoadUint8(&c.pending) == 0
+}
+
+// Wait blocks until the operation has completed and returns its error, if
+// any. The calling goroutine is paused until then, so that other goroutines
+// can run. Only one goroutine can wait for a given operation.
+func (c *Completion) Wait() error {
+	for {
+		state := interrupt.Disable()
+		if c.Done() {
+			interrupt.Restore(state)
+			break
+		}
+		c.waiter = task.Current()
+		interrupt.Restore(state)
+		pauseTask()
+	}
+	return c.err
+}
+
+// start prepares the completion for a new operation.
+func (c *Completion) start() {
+	c.err = nil
+	c.waiter = nil
+	volatile.StoreUint8(&c.pending, 1)
+}
+
+// complete marks the operation as completed with the given error and resumes
+// the goroutine waiting for it, if any. It is usually called from an
+// interrupt.
+func (c *Completion) complete(err error) {
+	c.err = err
+	volatile.StoreUint8(&c.pending, 0)
+	if t := c.waiter; t != nil {
+		c.waiter = nil
+		resumeTask(t)
+	}
+}
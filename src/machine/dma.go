@@ -0,0 +1,111 @@
+//go:build rp2040 || (sam && atsamd51) || (sam && atsame5x) || stm32f4
+
+// This file defines the parts of the DMA API that are shared between chips.
+// Each chip implements the DMAChannel type with these methods:
+//
+//	Configure(config DMAConfig)
+//	Prepare(dst, src unsafe.Pointer, count int) error
+//	Trigger()
+//	Busy() bool
+//	Abort()
+//	ChainTo(next *DMAChannel)
+//	SetCallback(callback func(ch *DMAChannel))
+//	Release()
+//
+// A channel is obtained with ClaimDMAChannel, which takes the DMATrigger of
+// the peripheral that paces the transfers (or DMATriggerMemory for memory to
+// memory copies). The available triggers are chip specific.
+//
+// DMA is implemented for the RP2040, the SAMD51/SAME5x and the STM32F4. Other
+// STM32 families have a different DMA controller (with channels instead of
+// streams, and a request multiplexer on newer chips) and don't support this
+// API yet.
+//
+// The interrupt at the end of a transfer is always enabled for claimed
+// channels: besides calling the callback and starting a chained channel, it
+// resumes the goroutine that waits for the transfer in Wait.
+
+package machine
+
+import (
+	"errors"
+	"internal/task"
+	"runtime/interrupt"
+	"unsafe"
+)
+
+var (
+	ErrNoDMAChannel    = errors.New("machine: no DMA channel available")
+	ErrDMABusy         = errors.New("machine: DMA channel busy")
+	ErrDMATransferSize = errors.New("machine: DMA transfer size out of range")
+)
+
+// DMADirection is the direction of a DMA transfer. The peripheral side of a
+// transfer never has its address incremented.
+type DMADirection uint8
+
+const (
+	DMAMemToMem DMADirection = iota
+	DMAMemToPeripheral
+	DMAPeripheralToMem
+)
+
+// DMASize is the size of a single unit of a DMA transfer.
+type DMASize uint8
+
+const (
+	DMASize8 DMASize = iota
+	DMASize16
+	DMASize32
+)
+
+// DMAConfig is the configuration of a DMA channel, which is used for all
+// transfers until it is configured again.
+type DMAConfig struct {
+	Direction DMADirection
+	Size      DMASize
+
+	// FixedMemory stops the memory address from being incremented, for
+	// example to fill a display with a single color or to drop all received
+	// data in a single byte. For memory to memory transfers, this applies to
+	// the source address.
+	FixedMemory bool
+}
+
+// Start starts a transfer of count units from src to dst. It returns
+// immediately: use Wait or SetCallback to know when the transfer is done. The
+// memory that is read or written must stay alive until then.
+func (ch *DMAChannel) Start(dst, src unsafe.Pointer, count int) error {
+	err := ch.Prepare(dst, src, count)
+	if err != nil {
+		return err
+	}
+	ch.Trigger()
+	return nil
+}
+
+// Wait blocks until the current transfer has finished. The calling goroutine
+// is paused until the DMA interrupt signals the end of the transfer, so that
+// other goroutines can run. Only one goroutine can wait for a given channel.
+func (ch *DMAChannel) Wait() {
+	for {
+		state := interrupt.Disable()
+		if !ch.Busy() {
+			ch.waiter = nil
+			interrupt.Restore(state)
+			return
+		}
+		ch.waiter = task.Current()
+		interrupt.Restore(state)
+		pauseTask()
+	}
+}
+
+// wake resumes the goroutine waiting in Wait, if any. It is called from the
+// DMA interrupt at the end of a transfer.
+func (ch *DMAChannel) wake() {
+	if t := ch.waiter; t != nil {
+		ch.waiter = nil
+		resumeTask(t)
+	}
+}
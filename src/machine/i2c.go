@@ -14,6 +14,7 @@ import (
 var _ interface { // 2
 	Configure(config I2CConfig) error
 	Tx(addr uint16, w, r []byte) error
+	TxAsync(addr uint16, w, r []byte) (*Completion, error)
 	SetBaudRate(br uint32) error
 } = (*I2C)(nil)
 
@@ -0,0 +1,17 @@
+//go:build !baremetal || atmega || nrf || sam || stm32 || fe310 || k210 || mimxrt1062 || (esp32c3 && !m5stamp_c3) || esp32
+
+// This file implements the I2C TxAsync function for targets where I2C
+// transactions are not interrupt driven (yet).
+
+package machine
+
+// TxAsync performs a transaction like Tx. On this chip, the transaction is
+// done before TxAsync returns, so the returned Completion is always done and
+// errors are returned directly.
+func (i2c *I2C) TxAsync(addr uint16, w, r []byte) (*Completion, error) {
+	err := i2c.Tx(addr, w, r)
+	if err != nil {
+		return nil, err
+	}
+	return &completed, nil
+}
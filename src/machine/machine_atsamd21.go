@@ -508,6 +508,7 @@ type UART struct {
 	Bus       *sam.SERCOM_USART_Type
 	SERCOM    uint8
 	Interrupt interrupt.Interrupt
+	uartFraming
 }
 
 const (
@@ -599,18 +600,10 @@ func (uart *UART) Configure(config UARTConfig) error {
 	uart.SetBaudRate(config.BaudRate)
 
 	// setup UART frame
-	// SERCOM_USART_CTRLA_FORM( (parityMode == SERCOM_NO_PARITY ? 0 : 1) ) |
-	// dataOrder << SERCOM_USART_CTRLA_DORD_Pos;
-	uart.Bus.CTRLA.SetBits((0 << sam.SERCOM_USART_CTRLA_FORM_Pos) | // no parity
-		(lsbFirst << sam.SERCOM_USART_CTRLA_DORD_Pos)) // data order
-
-	// set UART stop bits/parity
-	// SERCOM_USART_CTRLB_CHSIZE(charSize) |
-	// 	nbStopBits << SERCOM_USART_CTRLB_SBMODE_Pos |
-	// 	(parityMode == SERCOM_NO_PARITY ? 0 : parityMode) << SERCOM_USART_CTRLB_PMODE_Pos; //If no parity use default value
-	uart.Bus.CTRLB.SetBits((0 << sam.SERCOM_USART_CTRLB_CHSIZE_Pos) | // 8 bits is 0
-		(0 << sam.SERCOM_USART_CTRLB_SBMODE_Pos) | // 1 stop bit is zero
-		(0 << sam.SERCOM_USART_CTRLB_PMODE_Pos)) // no parity
+	uart.Bus.CTRLA.SetBits(lsbFirst << sam.SERCOM_USART_CTRLA_DORD_Pos) // data order
+	if err := uart.configureFraming(config); err != nil {
+		return err
+	}
 
 	// set UART pads. This is not same as pins...
 	//  SERCOM_USART_CTRLA_TXPO(txPad) |
@@ -662,11 +655,74 @@ func (uart *UART) writeByte(c byte) error {
 
 func (uart *UART) flush() {}
 
+// SetFormat sets the number of data bits (5 to 8), stop bits (1 or 2) and the
+// parity of the UART.
+func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
+	if databits < 5 || databits > 8 || stopbits < 1 || stopbits > 2 || parity > ParityOdd {
+		return ErrInvalidUARTFormat
+	}
+
+	// The frame format can only be changed while the USART is disabled.
+	enabled := uart.Bus.CTRLA.HasBits(sam.SERCOM_USART_CTRLA_ENABLE)
+	if enabled {
+		uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_CTRLA_ENABLE)
+		for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_SYNCBUSY_ENABLE) {
+		}
+	}
+
+	// A FORM of 1 is a USART frame with parity.
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_CTRLA_FORM_Msk)
+	if parity != ParityNone {
+		uart.Bus.CTRLA.SetBits(1 << sam.SERCOM_USART_CTRLA_FORM_Pos)
+	}
+
+	// CHSIZE is 0 for 8 bits, and the number of bits for 5 to 7 bits.
+	ctrlb := uint32(databits&7) << sam.SERCOM_USART_CTRLB_CHSIZE_Pos
+	if stopbits == 2 {
+		ctrlb |= sam.SERCOM_USART_CTRLB_SBMODE
+	}
+	if parity == ParityOdd {
+		ctrlb |= sam.SERCOM_USART_CTRLB_PMODE
+	}
+	uart.Bus.CTRLB.ClearBits(sam.SERCOM_USART_CTRLB_CHSIZE_Msk |
+		sam.SERCOM_USART_CTRLB_SBMODE |
+		sam.SERCOM_USART_CTRLB_PMODE)
+	uart.Bus.CTRLB.SetBits(ctrlb)
+
+	if enabled {
+		uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_CTRLA_ENABLE)
+		for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_SYNCBUSY_ENABLE) {
+		}
+	}
+	return nil
+}
+
+// waitTxComplete waits until the last stop bit has been sent. The TXC flag is
+// cleared by writing new data.
+func (uart *UART) waitTxComplete() {
+	for !uart.Bus.INTFLAG.HasBits(sam.SERCOM_USART_INTFLAG_TXC) {
+	}
+}
+
 // handleInterrupt should be called from the appropriate interrupt handler for
 // this UART instance.
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
+	// The error flags of a character must be read before its data, and are
+	// cleared by writing a one. A framing error with only zero bits is a
+	// break.
+	status := uart.Bus.STATUS.Get() & (sam.SERCOM_USART_STATUS_PERR | sam.SERCOM_USART_STATUS_FERR)
+	data := byte(uart.Bus.DATA.Get() & 0xFF)
+	var events UARTEvent
+	if status != 0 {
+		uart.Bus.STATUS.Set(status)
+		events = UARTError
+		if status == sam.SERCOM_USART_STATUS_FERR && data == 0 {
+			events = UARTBreak
+		}
+	}
+	uart.receiveChar(data, events)
+
 	// should reset IRQ
-	uart.Receive(byte((uart.Bus.DATA.Get() & 0xFF)))
 	uart.Bus.INTFLAG.SetBits(sam.SERCOM_USART_INTFLAG_RXC)
 }
 
@@ -147,6 +147,9 @@ func handleUSBIRQ(intr interrupt.Interrupt) {
 		if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
 			// Standard Requests
 			ok = handleStandardSetup(setup)
+		} else if setup.BmRequestType == usb.REQUEST_DEVICETOHOST_VENDOR_DEVICE {
+			// Vendor Device Requests
+			ok = handleVendorSetup(setup)
 		} else {
 			// Class Interface Requests
 			if setup.WIndex < uint16(len(usbSetupHandler)) && usbSetupHandler[setup.WIndex] != nil {
@@ -383,11 +386,11 @@ func ReceiveUSBControlPacket() ([cdcLineInfoSize]byte, error) {
 	bytesread := uint32((usbEndpointDescriptors[0].DeviceDescBank[0].PCKSIZE.Get() >>
 		usb_DEVICE_PCKSIZE_BYTE_COUNT_Pos) & usb_DEVICE_PCKSIZE_BYTE_COUNT_Mask)
 
-	if bytesread != cdcLineInfoSize {
+	if bytesread > cdcLineInfoSize {
 		return b, ErrUSBBytesRead
 	}
 
-	copy(b[:7], udd_ep_out_cache_buffer[0][:7])
+	copy(b[:bytesread], udd_ep_out_cache_buffer[0][:bytesread])
 
 	return b, nil
 }
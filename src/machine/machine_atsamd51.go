@@ -971,6 +971,8 @@ type UART struct {
 	Bus       *sam.SERCOM_USART_INT_Type
 	SERCOM    uint8
 	Interrupt interrupt.Interrupt // RXC interrupt
+	uartDMA
+	uartFraming
 }
 
 var (
@@ -1079,18 +1081,10 @@ func (uart *UART) Configure(config UARTConfig) error {
 	uart.SetBaudRate(config.BaudRate)
 
 	// setup UART frame
-	// SERCOM_USART_CTRLA_FORM( (parityMode == SERCOM_NO_PARITY ? 0 : 1) ) |
-	// dataOrder << SERCOM_USART_CTRLA_DORD_Pos;
-	uart.Bus.CTRLA.SetBits((0 << sam.SERCOM_USART_INT_CTRLA_FORM_Pos) | // no parity
-		(lsbFirst << sam.SERCOM_USART_INT_CTRLA_DORD_Pos)) // data order
-
-	// set UART stop bits/parity
-	// SERCOM_USART_CTRLB_CHSIZE(charSize) |
-	// 	nbStopBits << SERCOM_USART_CTRLB_SBMODE_Pos |
-	// 	(parityMode == SERCOM_NO_PARITY ? 0 : parityMode) << SERCOM_USART_CTRLB_PMODE_Pos; //If no parity use default value
-	uart.Bus.CTRLB.SetBits((0 << sam.SERCOM_USART_INT_CTRLB_CHSIZE_Pos) | // 8 bits is 0
-		(0 << sam.SERCOM_USART_INT_CTRLB_SBMODE_Pos) | // 1 stop bit is zero
-		(0 << sam.SERCOM_USART_INT_CTRLB_PMODE_Pos)) // no parity
+	uart.Bus.CTRLA.SetBits(lsbFirst << sam.SERCOM_USART_INT_CTRLA_DORD_Pos) // data order
+	if err := uart.configureFraming(config); err != nil {
+		return err
+	}
 
 	// set UART pads. This is not same as pins...
 	//  SERCOM_USART_CTRLA_TXPO(txPad) |
@@ -1147,9 +1141,80 @@ func (uart *UART) writeByte(c byte) error {
 
 func (uart *UART) flush() {}
 
+// The DMAC is paced by the DRE flag, which needs no configuration.
+func (uart *UART) setTxDMA(enabled bool) {}
+
+// The register that TX DMA transfers write to.
+func (uart *UART) txDataRegister() unsafe.Pointer {
+	return unsafe.Pointer(&uart.Bus.DATA)
+}
+
+// SetFormat sets the number of data bits (5 to 8), stop bits (1 or 2) and the
+// parity of the UART.
+func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
+	if databits < 5 || databits > 8 || stopbits < 1 || stopbits > 2 || parity > ParityOdd {
+		return ErrInvalidUARTFormat
+	}
+
+	// The frame format can only be changed while the USART is disabled.
+	enabled := uart.Bus.CTRLA.HasBits(sam.SERCOM_USART_INT_CTRLA_ENABLE)
+	if enabled {
+		uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_INT_CTRLA_ENABLE)
+		for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_INT_SYNCBUSY_ENABLE) {
+		}
+	}
+
+	// A FORM of 1 is a USART frame with parity.
+	uart.Bus.CTRLA.ClearBits(sam.SERCOM_USART_INT_CTRLA_FORM_Msk)
+	if parity != ParityNone {
+		uart.Bus.CTRLA.SetBits(1 << sam.SERCOM_USART_INT_CTRLA_FORM_Pos)
+	}
+
+	// CHSIZE is 0 for 8 bits, and the number of bits for 5 to 7 bits.
+	ctrlb := uint32(databits&7) << sam.SERCOM_USART_INT_CTRLB_CHSIZE_Pos
+	if stopbits == 2 {
+		ctrlb |= sam.SERCOM_USART_INT_CTRLB_SBMODE
+	}
+	if parity == ParityOdd {
+		ctrlb |= sam.SERCOM_USART_INT_CTRLB_PMODE
+	}
+	uart.Bus.CTRLB.ClearBits(sam.SERCOM_USART_INT_CTRLB_CHSIZE_Msk |
+		sam.SERCOM_USART_INT_CTRLB_SBMODE |
+		sam.SERCOM_USART_INT_CTRLB_PMODE)
+	uart.Bus.CTRLB.SetBits(ctrlb)
+
+	if enabled {
+		uart.Bus.CTRLA.SetBits(sam.SERCOM_USART_INT_CTRLA_ENABLE)
+		for uart.Bus.SYNCBUSY.HasBits(sam.SERCOM_USART_INT_SYNCBUSY_ENABLE) {
+		}
+	}
+	return nil
+}
+
+// waitTxComplete waits until the last stop bit has been sent. The TXC flag is
+// cleared by writing new data.
+func (uart *UART) waitTxComplete() {
+	for !uart.Bus.INTFLAG.HasBits(sam.SERCOM_USART_INT_INTFLAG_TXC) {
+	}
+}
+
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
+	// The error flags of a character must be read before its data, and are
+	// cleared by writing a one. A framing error with only zero bits is a
+	// break.
+	status := uart.Bus.STATUS.Get() & (sam.SERCOM_USART_INT_STATUS_PERR | sam.SERCOM_USART_INT_STATUS_FERR)
+	data := byte(uart.Bus.DATA.Get() & 0xFF)
+	var events UARTEvent
+	if status != 0 {
+		uart.Bus.STATUS.Set(status)
+		events = UARTError
+		if status == sam.SERCOM_USART_INT_STATUS_FERR && data == 0 {
+			events = UARTBreak
+		}
+	}
+	uart.receiveChar(data, events)
+
 	// should reset IRQ
-	uart.Receive(byte((uart.Bus.DATA.Get() & 0xFF)))
 	uart.Bus.INTFLAG.SetBits(sam.SERCOM_USART_INT_INTFLAG_RXC)
 }
 
@@ -1625,7 +1690,84 @@ func (spi SPI) Tx(w, r []byte) error {
 	return nil
 }
 
+// SetTxDMA makes Tx use the given DMA channel when it only writes data, which
+// is the common case for displays. The channel must have been claimed with the
+// TX trigger of this SERCOM (for example DMATriggerSERCOM1TX). Other
+// goroutines can run while the data is being sent. Passing nil goes back to
+// sending one byte at a time.
+func (spi SPI) SetTxDMA(ch *DMAChannel) {
+	spiTxDMA[spi.SERCOM] = ch
+	if ch != nil {
+		ch.Configure(DMAConfig{Direction: DMAMemToPeripheral, Size: DMASize8})
+	}
+}
+
+// DMA channels set with SetTxDMA, by SERCOM. They can't be stored in SPI
+// itself as it is used by value.
+var spiTxDMA [8]*DMAChannel
+
+// State of the transactions started with TxAsync, by DMA channel.
+var spiAsync [dmaChannelCount]struct {
+	bus        *sam.SERCOM_SPIM_Type
+	completion Completion
+}
+
+// TxAsync starts a transaction like Tx and returns immediately. Writes without
+// reads are done with the DMA channel set with SetTxDMA while other goroutines
+// run, and the Completion is used to wait for their end. Other transactions
+// are done synchronously, before TxAsync returns.
+//
+// Only one transaction can be in progress on a bus: ErrDMABusy is returned if
+// the previous one hasn't completed yet.
+func (spi SPI) TxAsync(w, r []byte) (*Completion, error) {
+	ch := spiTxDMA[spi.SERCOM]
+	if ch == nil || r != nil || len(w) == 0 {
+		err := spi.Tx(w, r)
+		if err != nil {
+			return nil, err
+		}
+		return &completed, nil
+	}
+
+	state := &spiAsync[ch.index]
+	if !state.completion.Done() {
+		return nil, ErrDMABusy
+	}
+	state.bus = spi.Bus
+	state.completion.start()
+	ch.SetCallback(spiHandleDMA)
+	err := ch.Start(unsafe.Pointer(&spi.Bus.DATA), unsafe.Pointer(&w[0]), len(w))
+	if err != nil {
+		state.completion.complete(err)
+		return nil, err
+	}
+	return &state.completion, nil
+}
+
+// spiHandleDMA is called from the DMA interrupt when the data of a write
+// started with TxAsync has been copied to the SERCOM.
+func spiHandleDMA(ch *DMAChannel) {
+	state := &spiAsync[ch.index]
+	ch.SetCallback(nil)
+
+	// Wait for the last byte to be shifted out, which only takes a few bit
+	// times, and clear the received data.
+	for !state.bus.INTFLAG.HasBits(sam.SERCOM_SPIM_INTFLAG_TXC) {
+	}
+	for state.bus.INTFLAG.HasBits(sam.SERCOM_SPIM_INTFLAG_RXC) {
+		state.bus.DATA.Get()
+	}
+	state.completion.complete(nil)
+}
+
 func (spi SPI) tx(tx []byte) {
+	if ch := spiTxDMA[spi.SERCOM]; ch != nil && len(tx) != 0 {
+		err := ch.Start(unsafe.Pointer(&spi.Bus.DATA), unsafe.Pointer(&tx[0]), len(tx))
+		if err == nil {
+			ch.Wait()
+			tx = nil
+		}
+	}
 	for i := 0; i < len(tx); i++ {
 		for !spi.Bus.INTFLAG.HasBits(sam.SERCOM_SPIM_INTFLAG_DRE) {
 		}
@@ -0,0 +1,297 @@
+//go:build (sam && atsamd51) || (sam && atsame5x)
+
+package machine
+
+import (
+	"device/sam"
+	"internal/task"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// DMAC registers. See chapter 22.8 of the SAM D5x/E5x datasheet and
+// sam.DMAC_Type.
+type dmacRegs struct {
+	CTRL       volatile.Register16
+	CRCCTRL    volatile.Register16
+	_          [3]volatile.Register32 // CRC registers, DBGCTRL
+	SWTRIGCTRL volatile.Register32
+	PRICTRL0   volatile.Register32
+	_          [2]volatile.Register32
+	INTPEND    volatile.Register16
+	_          volatile.Register16
+	INTSTATUS  volatile.Register32
+	BUSYCH     volatile.Register32
+	PENDCH     volatile.Register32
+	ACTIVE     volatile.Register32
+	BASEADDR   volatile.Register32
+	WRBADDR    volatile.Register32
+	_          volatile.Register32
+	CHANNEL    [32]dmacChannelRegs
+}
+
+type dmacChannelRegs struct {
+	CHCTRLA    volatile.Register32
+	CHCTRLB    volatile.Register8
+	CHPRILVL   volatile.Register8
+	CHEVCTRL   volatile.Register8
+	_          [5]volatile.Register8
+	CHINTENCLR volatile.Register8
+	CHINTENSET volatile.Register8
+	CHINTFLAG  volatile.Register8
+	CHSTATUS   volatile.Register8
+}
+
+// Transfer descriptor, as stored in SRAM. The descriptors of all channels
+// live in one array at BASEADDR.
+type dmacDescriptor struct {
+	btctrl   uint16
+	btcnt    uint16
+	srcaddr  uint32
+	dstaddr  uint32
+	descaddr uint32
+}
+
+const (
+	dmacCTRL_DMAENABLE = 1 << 1
+	dmacCTRL_LVLEN     = 0xf << 8 // all priority levels
+
+	dmacCHCTRLA_ENABLE              = 1 << 1
+	dmacCHCTRLA_TRIGSRC_Pos         = 8
+	dmacCHCTRLA_TRIGACT_Pos         = 20
+	dmacCHCTRLA_TRIGACT_BURST       = 2
+	dmacCHCTRLA_TRIGACT_TRANSACTION = 3
+
+	dmacCHINT_TCMPL = 1 << 1
+
+	dmacBTCTRL_VALID        = 1 << 0
+	dmacBTCTRL_BEATSIZE_Pos = 8
+	dmacBTCTRL_SRCINC       = 1 << 10
+	dmacBTCTRL_DSTINC       = 1 << 11
+)
+
+// Only the first few of the 32 channels are used, to limit the memory needed
+// for descriptors.
+const dmaChannelCount = 8
+
+var dmac = (*dmacRegs)(unsafe.Pointer(sam.DMAC))
+
+// Descriptor and write-back memory. The DMAC needs them to be 16-byte aligned,
+// which is done by hand.
+var dmacMemory [(2*dmaChannelCount + 1) * 4]uint32
+
+// DMATrigger is the peripheral trigger source (TRIGSRC) that paces a DMA
+// transfer. See table 22-2 of the SAM D5x/E5x datasheet for other values.
+type DMATrigger uint8
+
+const (
+	DMATriggerMemory DMATrigger = 0x00 // software trigger, as fast as possible
+
+	DMATriggerSERCOM0RX DMATrigger = 0x04
+	DMATriggerSERCOM0TX DMATrigger = 0x05
+	DMATriggerSERCOM1RX DMATrigger = 0x06
+	DMATriggerSERCOM1TX DMATrigger = 0x07
+	DMATriggerSERCOM2RX DMATrigger = 0x08
+	DMATriggerSERCOM2TX DMATrigger = 0x09
+	DMATriggerSERCOM3RX DMATrigger = 0x0a
+	DMATriggerSERCOM3TX DMATrigger = 0x0b
+	DMATriggerSERCOM4RX DMATrigger = 0x0c
+	DMATriggerSERCOM4TX DMATrigger = 0x0d
+	DMATriggerSERCOM5RX DMATrigger = 0x0e
+	DMATriggerSERCOM5TX DMATrigger = 0x0f
+	DMATriggerSERCOM6RX DMATrigger = 0x10
+	DMATriggerSERCOM6TX DMATrigger = 0x11
+	DMATriggerSERCOM7RX DMATrigger = 0x12
+	DMATriggerSERCOM7TX DMATrigger = 0x13
+	DMATriggerADC0      DMATrigger = 0x44 // ADC0 result ready
+	DMATriggerADC1      DMATrigger = 0x46 // ADC1 result ready
+	DMATriggerDAC0      DMATrigger = 0x48 // DAC channel 0 empty
+	DMATriggerDAC1      DMATrigger = 0x49 // DAC channel 1 empty
+)
+
+// DMAChannel is one of the DMAC channels of the SAM D5x/E5x.
+type DMAChannel struct {
+	index    uint8
+	trigger  DMATrigger
+	btctrl   uint16
+	callback func(ch *DMAChannel)
+	chain    *DMAChannel
+	waiter   *task.Task
+}
+
+var (
+	dmaChannelList     [dmaChannelCount]DMAChannel
+	dmaClaimed         uint8
+	dmacDescriptors    *[dmaChannelCount]dmacDescriptor
+	dmaInterruptActive bool
+)
+
+// ClaimDMAChannel returns a DMA channel that hasn't been claimed yet, for
+// transfers paced by the given trigger.
+func ClaimDMAChannel(trigger DMATrigger) (*DMAChannel, error) {
+	if dmacDescriptors == nil {
+		initDMAC()
+	}
+	for i := uint8(0); i < dmaChannelCount; i++ {
+		if dmaClaimed&(1<<i) == 0 {
+			dmaClaimed |= 1 << i
+			ch := &dmaChannelList[i]
+			*ch = DMAChannel{index: i, trigger: trigger}
+			ch.Configure(DMAConfig{})
+			ch.enableInterrupt()
+			return ch, nil
+		}
+	}
+	return nil, ErrNoDMAChannel
+}
+
+// Enable the DMAC. Its AHB clock is enabled after reset.
+func initDMAC() {
+	base := (uintptr(unsafe.Pointer(&dmacMemory)) + 15) &^ 15
+	dmacDescriptors = (*[dmaChannelCount]dmacDescriptor)(unsafe.Pointer(base))
+	writeback := base + dmaChannelCount*unsafe.Sizeof(dmacDescriptor{})
+	dmac.CTRL.Set(0)
+	dmac.BASEADDR.Set(uint32(base))
+	dmac.WRBADDR.Set(uint32(writeback))
+	dmac.CTRL.Set(dmacCTRL_DMAENABLE | dmacCTRL_LVLEN)
+}
+
+// Release aborts any transfer in progress and returns the channel, so that it
+// can be claimed again.
+func (ch *DMAChannel) Release() {
+	ch.Abort()
+	ch.SetCallback(nil)
+	ch.ChainTo(nil)
+	ch.regs().CHINTENCLR.Set(dmacCHINT_TCMPL)
+	dmaClaimed &^= 1 << ch.index
+}
+
+func (ch *DMAChannel) regs() *dmacChannelRegs {
+	return &dmac.CHANNEL[ch.index]
+}
+
+// Configure sets the direction and transfer size for the following transfers.
+func (ch *DMAChannel) Configure(config DMAConfig) {
+	btctrl := uint16(dmacBTCTRL_VALID | uint16(config.Size)<<dmacBTCTRL_BEATSIZE_Pos)
+	switch config.Direction {
+	case DMAMemToMem:
+		btctrl |= dmacBTCTRL_DSTINC
+		if !config.FixedMemory {
+			btctrl |= dmacBTCTRL_SRCINC
+		}
+	case DMAMemToPeripheral:
+		if !config.FixedMemory {
+			btctrl |= dmacBTCTRL_SRCINC
+		}
+	case DMAPeripheralToMem:
+		if !config.FixedMemory {
+			btctrl |= dmacBTCTRL_DSTINC
+		}
+	}
+	ch.btctrl = btctrl
+}
+
+// Prepare sets up a transfer of count units from src to dst, without starting
+// it. It is started by Trigger, or by the end of the transfer of a channel
+// that is chained to this one.
+func (ch *DMAChannel) Prepare(dst, src unsafe.Pointer, count int) error {
+	if count <= 0 || count > 0xffff {
+		return ErrDMATransferSize
+	}
+	if ch.Busy() {
+		return ErrDMABusy
+	}
+
+	// The DMAC expects the end address of incremented addresses.
+	size := uintptr(count) << (ch.btctrl >> dmacBTCTRL_BEATSIZE_Pos & 3)
+	srcaddr, dstaddr := uintptr(src), uintptr(dst)
+	if ch.btctrl&dmacBTCTRL_SRCINC != 0 {
+		srcaddr += size
+	}
+	if ch.btctrl&dmacBTCTRL_DSTINC != 0 {
+		dstaddr += size
+	}
+	desc := &dmacDescriptors[ch.index]
+	desc.btctrl = ch.btctrl
+	desc.btcnt = uint16(count)
+	desc.srcaddr = uint32(srcaddr)
+	desc.dstaddr = uint32(dstaddr)
+	desc.descaddr = 0
+
+	trigact := uint32(dmacCHCTRLA_TRIGACT_BURST)
+	if ch.trigger == DMATriggerMemory {
+		trigact = dmacCHCTRLA_TRIGACT_TRANSACTION
+	}
+	ch.regs().CHCTRLA.Set(uint32(ch.trigger)<<dmacCHCTRLA_TRIGSRC_Pos | trigact<<dmacCHCTRLA_TRIGACT_Pos)
+	return nil
+}
+
+// Trigger starts the transfer set up with Prepare.
+func (ch *DMAChannel) Trigger() {
+	ch.regs().CHCTRLA.SetBits(dmacCHCTRLA_ENABLE)
+	if ch.trigger == DMATriggerMemory {
+		dmac.SWTRIGCTRL.SetBits(1 << ch.index)
+	}
+}
+
+// Busy returns whether a transfer is in progress. The channel is disabled by
+// the DMAC at the end of the transfer.
+func (ch *DMAChannel) Busy() bool {
+	return ch.regs().CHCTRLA.HasBits(dmacCHCTRLA_ENABLE)
+}
+
+// Abort stops the transfer in progress, if any.
+func (ch *DMAChannel) Abort() {
+	regs := ch.regs()
+	regs.CHCTRLA.ClearBits(dmacCHCTRLA_ENABLE)
+	for regs.CHCTRLA.HasBits(dmacCHCTRLA_ENABLE) {
+	}
+}
+
+// ChainTo starts the transfer prepared on next as soon as a transfer on this
+// channel has finished. This is done from the DMAC interrupt. Passing nil
+// removes the chain.
+func (ch *DMAChannel) ChainTo(next *DMAChannel) {
+	ch.chain = next
+}
+
+// SetCallback sets a function that is called from an interrupt whenever a
+// transfer on this channel has finished. Passing nil removes the callback.
+func (ch *DMAChannel) SetCallback(callback func(ch *DMAChannel)) {
+	ch.callback = callback
+}
+
+// Enable the transfer complete interrupt, which is used by the chain, the
+// callback and Wait.
+func (ch *DMAChannel) enableInterrupt() {
+	regs := ch.regs()
+	if !dmaInterruptActive {
+		dmaInterruptActive = true
+		interrupt.New(sam.IRQ_DMAC_0, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_1, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_2, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_3, dmaHandleInterrupt).Enable()
+		interrupt.New(sam.IRQ_DMAC_OTHER, dmaHandleInterrupt).Enable()
+	}
+	regs.CHINTFLAG.Set(dmacCHINT_TCMPL)
+	regs.CHINTENSET.Set(dmacCHINT_TCMPL)
+}
+
+func dmaHandleInterrupt(intr interrupt.Interrupt) {
+	for i := range dmaChannelList {
+		ch := &dmaChannelList[i]
+		regs := ch.regs()
+		if !regs.CHINTFLAG.HasBits(dmacCHINT_TCMPL) {
+			continue
+		}
+		regs.CHINTFLAG.Set(dmacCHINT_TCMPL)
+		if ch.chain != nil {
+			ch.chain.Trigger()
+		}
+		if ch.callback != nil {
+			ch.callback(ch)
+		}
+		ch.wake()
+	}
+}
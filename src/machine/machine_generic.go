@@ -6,7 +6,9 @@ import (
 	"crypto/rand"
 )
 
-// Dummy machine package that calls out to external functions.
+// Dummy machine package that calls out to external functions. These are
+// declared in machine_generic_extern.go, or implemented in Go by the simulator
+// in machine_generic_sim.go when building with the machinesim build tag.
 
 const deviceName = "generic"
 
@@ -39,15 +41,6 @@ func (p Pin) Get() bool {
 	return gpioGet(p)
 }
 
-//export __tinygo_gpio_configure
-func gpioConfigure(pin Pin, config PinConfig)
-
-//export __tinygo_gpio_set
-func gpioSet(pin Pin, value bool)
-
-//export __tinygo_gpio_get
-func gpioGet(pin Pin) bool
-
 type SPI struct {
 	Bus uint8
 }
@@ -102,15 +95,6 @@ func (spi SPI) Tx(w, r []byte) error {
 	return nil
 }
 
-//export __tinygo_spi_configure
-func spiConfigure(bus uint8, sck Pin, SDO Pin, SDI Pin)
-
-//export __tinygo_spi_transfer
-func spiTransfer(bus uint8, w uint8) uint8
-
-//export __tinygo_spi_tx
-func spiTX(bus uint8, wptr *byte, wlen int, rptr *byte, rlen int) uint8
-
 // InitADC enables support for ADC peripherals.
 func InitADC() {
 	// Nothing to do here.
@@ -125,9 +109,6 @@ func (adc ADC) Get() uint16 {
 	return adcRead(adc.Pin)
 }
 
-//export __tinygo_adc_read
-func adcRead(pin Pin) uint16
-
 // I2C is a generic implementation of the Inter-IC communication protocol.
 type I2C struct {
 	Bus uint8
@@ -154,30 +135,9 @@ func (i2c *I2C) SetBaudRate(br uint32) error {
 
 // Tx does a single I2C transaction at the specified address.
 func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
-	var wptr, rptr *byte
-	var wlen, rlen int
-	if len(w) != 0 {
-		wptr = &w[0]
-		wlen = len(w)
-	}
-	if len(r) != 0 {
-		rptr = &r[0]
-		rlen = len(r)
-	}
-	i2cTransfer(i2c.Bus, wptr, wlen, rptr, rlen)
-	// TODO: do something with the returned error code.
-	return nil
+	return i2cTx(i2c.Bus, addr, w, r)
 }
 
-//export __tinygo_i2c_configure
-func i2cConfigure(bus uint8, scl Pin, sda Pin)
-
-//export __tinygo_i2c_set_baud_rate
-func i2cSetBaudRate(bus uint8, br uint32)
-
-//export __tinygo_i2c_transfer
-func i2cTransfer(bus uint8, w *byte, wlen int, r *byte, rlen int) int
-
 type UART struct {
 	Bus uint8
 }
@@ -189,17 +149,23 @@ func (uart *UART) Configure(config UARTConfig) {
 
 // Read from the UART.
 func (uart *UART) Read(data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
 	return uartRead(uart.Bus, &data[0], len(data)), nil
 }
 
 // Write to the UART.
 func (uart *UART) Write(data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
 	return uartWrite(uart.Bus, &data[0], len(data)), nil
 }
 
 // Buffered returns the number of bytes currently stored in the RX buffer.
 func (uart *UART) Buffered() int {
-	return 0
+	return uartBuffered(uart.Bus)
 }
 
 // ReadByte reads a single byte from the UART.
@@ -215,15 +181,6 @@ func (uart *UART) WriteByte(b byte) error {
 	return nil
 }
 
-//export __tinygo_uart_configure
-func uartConfigure(bus uint8, tx Pin, rx Pin)
-
-//export __tinygo_uart_read
-func uartRead(bus uint8, buf *byte, bufLen int) int
-
-//export __tinygo_uart_write
-func uartWrite(bus uint8, buf *byte, bufLen int) int
-
 var (
 	hardwareUART0 = &UART{0}
 	hardwareUART1 = &UART{1}
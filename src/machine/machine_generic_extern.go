@@ -0,0 +1,69 @@
+//go:build !baremetal && !machinesim
+
+package machine
+
+// External functions used by machine_generic.go. They are implemented by the
+// environment, for example by the simulator on the TinyGo playground.
+
+//export __tinygo_gpio_configure
+func gpioConfigure(pin Pin, config PinConfig)
+
+//export __tinygo_gpio_set
+func gpioSet(pin Pin, value bool)
+
+//export __tinygo_gpio_get
+func gpioGet(pin Pin) bool
+
+//export __tinygo_spi_configure
+func spiConfigure(bus uint8, sck Pin, SDO Pin, SDI Pin)
+
+//export __tinygo_spi_transfer
+func spiTransfer(bus uint8, w uint8) uint8
+
+//export __tinygo_spi_tx
+func spiTX(bus uint8, wptr *byte, wlen int, rptr *byte, rlen int) uint8
+
+//export __tinygo_adc_read
+func adcRead(pin Pin) uint16
+
+//export __tinygo_i2c_configure
+func i2cConfigure(bus uint8, scl Pin, sda Pin)
+
+//export __tinygo_i2c_set_baud_rate
+func i2cSetBaudRate(bus uint8, br uint32)
+
+// Do a single I2C transaction. Note that the address is not passed on to the
+// environment.
+func i2cTx(bus uint8, addr uint16, w, r []byte) error {
+	var wptr, rptr *byte
+	var wlen, rlen int
+	if len(w) != 0 {
+		wptr = &w[0]
+		wlen = len(w)
+	}
+	if len(r) != 0 {
+		rptr = &r[0]
+		rlen = len(r)
+	}
+	i2cTransfer(bus, wptr, wlen, rptr, rlen)
+	// TODO: do something with the returned error code.
+	return nil
+}
+
+//export __tinygo_i2c_transfer
+func i2cTransfer(bus uint8, w *byte, wlen int, r *byte, rlen int) int
+
+//export __tinygo_uart_configure
+func uartConfigure(bus uint8, tx Pin, rx Pin)
+
+//export __tinygo_uart_read
+func uartRead(bus uint8, buf *byte, bufLen int) int
+
+//export __tinygo_uart_write
+func uartWrite(bus uint8, buf *byte, bufLen int) int
+
+// The number of bytes that can be read from the UART without blocking. There
+// is no external function for this, so assume there are none.
+func uartBuffered(bus uint8) int {
+	return 0
+}
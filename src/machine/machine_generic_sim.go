@@ -0,0 +1,216 @@
+//go:build !baremetal && machinesim
+
+package machine
+
+// This file implements the external functions of machine_generic.go in Go,
+// so that code using the machine package can be tested on a host system
+// without any hardware attached:
+//
+//	tinygo test -tags=machinesim ./...
+//
+// Pins keep their state in memory and can be driven from a test with
+// Pin.SimDrive. Virtual devices can be attached to the SPI, I2C and UART
+// buses (see machine_generic_sim_bus.go), and pin changes can be recorded to a
+// VCD file that can be inspected with a waveform viewer such as GTKWave.
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// simMutex protects all simulator state. It is not held while calling
+// virtual devices, so that they can use the machine package themselves.
+var simMutex sync.Mutex
+
+type simPin struct {
+	mode    PinMode
+	output  bool   // value set with Pin.Set
+	driven  bool   // whether the pin is driven by Pin.SimDrive
+	input   bool   // value set with Pin.SimDrive
+	analog  uint16 // value set with ADC.SimSet
+	watches []func(value bool)
+}
+
+var simPins [256]simPin
+
+// Return the current level of the pin. Outputs are at the level they were
+// set to, inputs at the level they are driven to or else the level of the
+// pull resistor. simMutex must be held.
+func (p *simPin) level() bool {
+	switch {
+	case p.mode == PinOutput:
+		return p.output
+	case p.driven:
+		return p.input
+	default:
+		return p.mode == PinInputPullup
+	}
+}
+
+// Change the state of a pin and notify watchers if its level changed.
+func simUpdatePin(pin Pin, update func(p *simPin)) {
+	simMutex.Lock()
+	p := &simPins[pin]
+	old := p.level()
+	update(p)
+	value := p.level()
+	watches := p.watches
+	simMutex.Unlock()
+	if value != old {
+		for _, watch := range watches {
+			watch(value)
+		}
+	}
+}
+
+// Call fn each time the level of the pin changes.
+func simWatchPin(pin Pin, fn func(value bool)) {
+	simMutex.Lock()
+	simPins[pin].watches = append(simPins[pin].watches, fn)
+	simMutex.Unlock()
+}
+
+func gpioConfigure(pin Pin, config PinConfig) {
+	simUpdatePin(pin, func(p *simPin) {
+		p.mode = config.Mode
+	})
+}
+
+func gpioSet(pin Pin, value bool) {
+	simUpdatePin(pin, func(p *simPin) {
+		p.output = value
+	})
+}
+
+func gpioGet(pin Pin) bool {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	return simPins[pin].level()
+}
+
+// SimDrive drives the pin externally to the given level, like a button or
+// another chip would. It has no effect while the pin is configured as an
+// output.
+func (p Pin) SimDrive(value bool) {
+	simUpdatePin(p, func(p *simPin) {
+		p.driven = true
+		p.input = value
+	})
+}
+
+// SimRelease stops driving the pin externally, so that it returns to the
+// level of its pull resistor.
+func (p Pin) SimRelease() {
+	simUpdatePin(p, func(p *simPin) {
+		p.driven = false
+	})
+}
+
+func adcRead(pin Pin) uint16 {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	return simPins[pin].analog
+}
+
+// SimSet sets the value that is returned by Get. Like on real hardware, the
+// value is scaled to 16 bits.
+func (adc ADC) SimSet(value uint16) {
+	simMutex.Lock()
+	simPins[adc.Pin].analog = value
+	simMutex.Unlock()
+}
+
+// VCDRecorder records the level of pins to a Value Change Dump file. Create
+// one with SimRecordVCD.
+type VCDRecorder struct {
+	// Now returns the time since the start of the recording. By default it
+	// is the time elapsed since SimRecordVCD was called. It can be replaced
+	// to get a deterministic output in tests.
+	Now func() time.Duration
+
+	mutex   sync.Mutex
+	w       io.Writer
+	err     error
+	last    time.Duration
+	stopped bool
+}
+
+// SimRecordVCD starts recording the level of the given pins to w, in the
+// Value Change Dump format with a resolution of one nanosecond. Recording
+// continues until Stop is called.
+func SimRecordVCD(w io.Writer, pins ...Pin) *VCDRecorder {
+	start := time.Now()
+	r := &VCDRecorder{
+		Now: func() time.Duration { return time.Since(start) },
+		w:   w,
+	}
+	r.printf("$timescale 1ns $end\n")
+	r.printf("$scope module machine $end\n")
+	for i, pin := range pins {
+		r.printf("$var wire 1 %s pin%d $end\n", vcdIdentifier(i), pin)
+	}
+	r.printf("$upscope $end\n")
+	r.printf("$enddefinitions $end\n")
+	r.printf("#0\n$dumpvars\n")
+	for i, pin := range pins {
+		r.printf("%c%s\n", vcdValue(gpioGet(pin)), vcdIdentifier(i))
+	}
+	r.printf("$end\n")
+	for i, pin := range pins {
+		id := vcdIdentifier(i)
+		simWatchPin(pin, func(value bool) {
+			r.change(id, value)
+		})
+	}
+	return r
+}
+
+// Stop stops recording and returns the first error that happened while
+// writing, if any.
+func (r *VCDRecorder) Stop() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+	return r.err
+}
+
+// Write a single value change.
+func (r *VCDRecorder) change(id string, value bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return
+	}
+	if now := r.Now(); now != r.last {
+		r.last = now
+		r.printf("#%d\n", now.Nanoseconds())
+	}
+	r.printf("%c%s\n", vcdValue(value), id)
+}
+
+func (r *VCDRecorder) printf(format string, args ...interface{}) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+// Return the short identifier of a signal in a VCD file, which consists of
+// printable ASCII characters.
+func vcdIdentifier(index int) string {
+	const first, count = '!', '~' - '!' + 1
+	id := string(rune(first + index%count))
+	for index >= count {
+		index = index/count - 1
+		id = string(rune(first+index%count)) + id
+	}
+	return id
+}
+
+func vcdValue(value bool) byte {
+	if value {
+		return '1'
+	}
+	return '0'
+}
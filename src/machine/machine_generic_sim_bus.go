@@ -0,0 +1,239 @@
+//go:build !baremetal && machinesim
+
+package machine
+
+// Virtual devices on the simulated SPI, I2C and UART buses.
+
+import "unsafe"
+
+// SimSPIDevice is a virtual device on a simulated SPI bus.
+type SimSPIDevice interface {
+	// Transfer is called for each byte that is sent to the device while it
+	// is selected. It returns the byte that the device sends back.
+	Transfer(w byte) byte
+}
+
+// SimSPISelector can be implemented by a SimSPIDevice that needs to know
+// when its chip select pin changes, for example to reset its command state.
+type SimSPISelector interface {
+	Select(selected bool)
+}
+
+// SimSPIFunc is a SimSPIDevice implemented by a function.
+type SimSPIFunc func(w byte) byte
+
+// Transfer calls f(w).
+func (f SimSPIFunc) Transfer(w byte) byte {
+	return f(w)
+}
+
+type simSPIDevice struct {
+	cs  Pin
+	dev SimSPIDevice
+}
+
+var simSPIDevices = map[uint8][]simSPIDevice{}
+
+// SimAttach attaches a virtual device to this SPI bus. The device receives
+// all bytes transferred while the chip select pin is low, or all bytes if cs
+// is NoPin. Bytes transferred while no device is selected read as zero.
+func (spi SPI) SimAttach(cs Pin, dev SimSPIDevice) {
+	simMutex.Lock()
+	simSPIDevices[spi.Bus] = append(simSPIDevices[spi.Bus], simSPIDevice{cs, dev})
+	simMutex.Unlock()
+	if selector, ok := dev.(SimSPISelector); ok && cs != NoPin {
+		simWatchPin(cs, func(value bool) {
+			selector.Select(!value)
+		})
+	}
+}
+
+func spiConfigure(bus uint8, sck Pin, SDO Pin, SDI Pin) {
+}
+
+func spiTransfer(bus uint8, w uint8) uint8 {
+	simMutex.Lock()
+	var selected SimSPIDevice
+	for _, d := range simSPIDevices[bus] {
+		if d.cs == NoPin || !simPins[d.cs].level() {
+			selected = d.dev
+			break
+		}
+	}
+	simMutex.Unlock()
+	if selected == nil {
+		return 0
+	}
+	return selected.Transfer(w)
+}
+
+func spiTX(bus uint8, wptr *byte, wlen int, rptr *byte, rlen int) uint8 {
+	w := unsafe.Slice(wptr, wlen)
+	r := unsafe.Slice(rptr, rlen)
+	n := wlen
+	if rlen > n {
+		n = rlen
+	}
+	for i := 0; i < n; i++ {
+		var b byte
+		if i < wlen {
+			b = w[i]
+		}
+		b = spiTransfer(bus, b)
+		if i < rlen {
+			r[i] = b
+		}
+	}
+	return 0
+}
+
+// SimI2CDevice is a virtual device on a simulated I2C bus.
+type SimI2CDevice interface {
+	// Tx handles a single transaction addressed to this device: w contains
+	// the bytes written to the device and r must be filled with the bytes it
+	// sends back. Returning an error makes I2C.Tx fail.
+	Tx(w, r []byte) error
+}
+
+// SimI2CRegisters is a virtual I2C device with 8-bit registers, as used by
+// most sensors. The first byte written selects a register, the following
+// bytes are written to consecutive registers. Reads return consecutive
+// registers starting at the selected register.
+type SimI2CRegisters struct {
+	Registers [256]byte
+
+	// OnWrite, if set, is called for every register written over I2C. It can
+	// be used to simulate the behavior of the device, for example to start a
+	// measurement.
+	OnWrite func(reg, value uint8)
+
+	reg uint8
+}
+
+// Tx implements SimI2CDevice.
+func (d *SimI2CRegisters) Tx(w, r []byte) error {
+	if len(w) != 0 {
+		d.reg = w[0]
+		for _, value := range w[1:] {
+			d.Registers[d.reg] = value
+			if d.OnWrite != nil {
+				d.OnWrite(d.reg, value)
+			}
+			d.reg++
+		}
+	}
+	for i := range r {
+		r[i] = d.Registers[d.reg]
+		d.reg++
+	}
+	return nil
+}
+
+type simI2CAddress struct {
+	bus  uint8
+	addr uint16
+}
+
+var simI2CDevices = map[simI2CAddress]SimI2CDevice{}
+
+// SimAttach attaches a virtual device at the given address to this I2C bus.
+// Transactions to addresses without a device fail, like they would if the
+// device didn't acknowledge its address.
+func (i2c *I2C) SimAttach(addr uint16, dev SimI2CDevice) {
+	simMutex.Lock()
+	simI2CDevices[simI2CAddress{i2c.Bus, addr}] = dev
+	simMutex.Unlock()
+}
+
+func i2cConfigure(bus uint8, scl Pin, sda Pin) {
+}
+
+func i2cSetBaudRate(bus uint8, br uint32) {
+}
+
+func i2cTx(bus uint8, addr uint16, w, r []byte) error {
+	simMutex.Lock()
+	dev := simI2CDevices[simI2CAddress{bus, addr}]
+	simMutex.Unlock()
+	if dev == nil {
+		return errI2CAckExpected
+	}
+	return dev.Tx(w, r)
+}
+
+type simUART struct {
+	loopback bool
+	rx       []byte
+	tx       []byte
+}
+
+var simUARTs = map[uint8]*simUART{}
+
+// Return the state of this UART. simMutex must be held.
+func getSimUART(bus uint8) *simUART {
+	uart := simUARTs[bus]
+	if uart == nil {
+		uart = &simUART{}
+		simUARTs[bus] = uart
+	}
+	return uart
+}
+
+// SimLoopback connects TX to RX, so that all data written to the UART can be
+// read back.
+func (uart *UART) SimLoopback(enable bool) {
+	simMutex.Lock()
+	getSimUART(uart.Bus).loopback = enable
+	simMutex.Unlock()
+}
+
+// SimReceive makes the data available for reading, as if it was sent by the
+// other side.
+func (uart *UART) SimReceive(data []byte) {
+	simMutex.Lock()
+	u := getSimUART(uart.Bus)
+	u.rx = append(u.rx, data...)
+	simMutex.Unlock()
+}
+
+// SimTransmitted returns all data written to the UART since the last call.
+// Data is not recorded in loopback mode.
+func (uart *UART) SimTransmitted() []byte {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	u := getSimUART(uart.Bus)
+	data := u.tx
+	u.tx = nil
+	return data
+}
+
+func uartConfigure(bus uint8, tx Pin, rx Pin) {
+}
+
+func uartRead(bus uint8, buf *byte, bufLen int) int {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	u := getSimUART(bus)
+	n := copy(unsafe.Slice(buf, bufLen), u.rx)
+	u.rx = u.rx[n:]
+	return n
+}
+
+func uartWrite(bus uint8, buf *byte, bufLen int) int {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	u := getSimUART(bus)
+	data := unsafe.Slice(buf, bufLen)
+	if u.loopback {
+		u.rx = append(u.rx, data...)
+	} else {
+		u.tx = append(u.tx, data...)
+	}
+	return bufLen
+}
+
+func uartBuffered(bus uint8) int {
+	simMutex.Lock()
+	defer simMutex.Unlock()
+	return len(getSimUART(bus).rx)
+}
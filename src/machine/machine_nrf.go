@@ -161,6 +161,7 @@ func (p Pin) SetInterrupt(change PinChange, callback func(Pin)) error {
 // UART on the NRF.
 type UART struct {
 	Buffer *RingBuffer
+	uartFraming
 }
 
 // UART
@@ -171,7 +172,7 @@ var (
 )
 
 // Configure the UART.
-func (uart *UART) Configure(config UARTConfig) {
+func (uart *UART) Configure(config UARTConfig) error {
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
@@ -179,6 +180,11 @@ func (uart *UART) Configure(config UARTConfig) {
 
 	uart.SetBaudRate(config.BaudRate)
 
+	// Frame format, 8-1-N by default.
+	if err := uart.configureFraming(config); err != nil {
+		return err
+	}
+
 	// Set TX and RX pins
 	if config.TX == 0 && config.RX == 0 {
 		// Use default pins
@@ -190,12 +196,14 @@ func (uart *UART) Configure(config UARTConfig) {
 	nrf.UART0.ENABLE.Set(nrf.UART_ENABLE_ENABLE_Enabled)
 	nrf.UART0.TASKS_STARTTX.Set(1)
 	nrf.UART0.TASKS_STARTRX.Set(1)
-	nrf.UART0.INTENSET.Set(nrf.UART_INTENSET_RXDRDY_Msk)
+	nrf.UART0.INTENSET.Set(nrf.UART_INTENSET_RXDRDY_Msk | nrf.UART_INTENSET_ERROR_Msk)
 
 	// Enable RX IRQ.
 	intr := interrupt.New(nrf.IRQ_UART0, _UART0.handleInterrupt)
 	intr.SetPriority(0xc0) // low priority
 	intr.Enable()
+
+	return nil
 }
 
 // SetBaudRate sets the communication speed for the UART.
@@ -224,10 +232,45 @@ func (uart *UART) writeByte(c byte) error {
 
 func (uart *UART) flush() {}
 
+// SetFormat sets the number of data bits, stop bits and the parity of the
+// UART. Only 8 data bits are supported. Even parity is supported on all chips,
+// odd parity and 2 stop bits only on the nrf52833 and nrf52840.
+func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
+	if databits != 8 {
+		return ErrInvalidUARTFormat
+	}
+	config, ok := uartConfig(stopbits, parity)
+	if !ok {
+		return ErrInvalidUARTFormat
+	}
+	nrf.UART0.CONFIG.Set(config)
+	return nil
+}
+
+// The TXDRDY event of the last byte is only generated after its stop bit has
+// been sent, so there is nothing left to wait for.
+func (uart *UART) waitTxComplete() {}
+
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
+	// The ERROR event of a character comes before its RXDRDY event, if any.
+	// A break doesn't produce a character.
+	var events UARTEvent
+	if nrf.UART0.EVENTS_ERROR.Get() != 0 {
+		nrf.UART0.EVENTS_ERROR.Set(0x0)
+		src := nrf.UART0.ERRORSRC.Get()
+		nrf.UART0.ERRORSRC.Set(src) // write one to clear
+		if src&nrf.UART_ERRORSRC_BREAK != 0 {
+			events |= UARTBreak
+		}
+		if src&(nrf.UART_ERRORSRC_PARITY|nrf.UART_ERRORSRC_FRAMING) != 0 {
+			events |= UARTError
+		}
+	}
 	if nrf.UART0.EVENTS_RXDRDY.Get() != 0 {
-		uart.Receive(byte(nrf.UART0.RXD.Get()))
+		uart.receiveChar(byte(nrf.UART0.RXD.Get()), events)
 		nrf.UART0.EVENTS_RXDRDY.Set(0x0)
+	} else if events != 0 {
+		uart.receiveEvent(events)
 	}
 }
 
@@ -52,6 +52,20 @@ func (uart *UART) setPins(tx, rx Pin) {
 	nrf.UART0.PSELRXD.Set(uint32(rx))
 }
 
+// uartConfig returns the CONFIG register value for the frame format. This chip
+// only supports 1 stop bit and even parity.
+func uartConfig(stopbits uint8, parity UARTParity) (uint32, bool) {
+	switch {
+	case stopbits != 1:
+		return 0, false
+	case parity == ParityNone:
+		return 0, true
+	case parity == ParityEven:
+		return nrf.UART_CONFIG_PARITY_Included << nrf.UART_CONFIG_PARITY_Pos, true
+	}
+	return 0, false
+}
+
 func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSELSCL.Set(uint32(scl))
 	i2c.Bus.PSELSDA.Set(uint32(sda))
@@ -22,6 +22,29 @@ func (uart *UART) setPins(tx, rx Pin) {
 	nrf.UART0.PSEL.RXD.Set(uint32(rx))
 }
 
+// uartConfig returns the CONFIG register value for the frame format.
+func uartConfig(stopbits uint8, parity UARTParity) (uint32, bool) {
+	var config uint32
+	switch stopbits {
+	case 1:
+	case 2:
+		config |= nrf.UART_CONFIG_STOP_Two << nrf.UART_CONFIG_STOP_Pos
+	default:
+		return 0, false
+	}
+	switch parity {
+	case ParityNone:
+	case ParityEven:
+		config |= nrf.UART_CONFIG_PARITY_Included << nrf.UART_CONFIG_PARITY_Pos
+	case ParityOdd:
+		config |= nrf.UART_CONFIG_PARITY_Included<<nrf.UART_CONFIG_PARITY_Pos |
+			nrf.UART_CONFIG_PARITYTYPE_Odd<<nrf.UART_CONFIG_PARITYTYPE_Pos
+	default:
+		return 0, false
+	}
+	return config, true
+}
+
 func (i2c *I2C) setPins(scl, sda Pin) {
 	i2c.Bus.PSEL.SCL.Set(uint32(scl))
 	i2c.Bus.PSEL.SDA.Set(uint32(sda))
@@ -151,6 +151,9 @@ func handleUSBIRQ(interrupt.Interrupt) {
 		if (setup.BmRequestType & usb.REQUEST_TYPE) == usb.REQUEST_STANDARD {
 			// Standard Requests
 			ok = handleStandardSetup(setup)
+		} else if setup.BmRequestType == usb.REQUEST_DEVICETOHOST_VENDOR_DEVICE {
+			// Vendor Device Requests
+			ok = handleVendorSetup(setup)
 		} else {
 			// Class Interface Requests
 			if setup.WIndex < uint16(len(usbSetupHandler)) && usbSetupHandler[setup.WIndex] != nil {
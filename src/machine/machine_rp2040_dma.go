@@ -0,0 +1,216 @@
+//go:build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"internal/task"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// Single DMA channel. See rp.DMA_Type.
+type dmaChannel struct {
+	READ_ADDR   volatile.Register32
+	WRITE_ADDR  volatile.Register32
+	TRANS_COUNT volatile.Register32
+	CTRL_TRIG   volatile.Register32
+	AL1_CTRL    volatile.Register32     // CTRL_TRIG without starting the channel
+	_           [11]volatile.Register32 // other aliases
+}
+
+// DMA channels usable on the RP2040.
+var dmaChannels = (*[12]dmaChannel)(unsafe.Pointer(rp.DMA))
+
+// Static assignment of DMA channels to peripherals. These channels are
+// reserved, and will not be returned by ClaimDMAChannel.
+const (
+	spi0DMAChannel = iota
+	spi1DMAChannel
+	spi0RxDMAChannel
+	spi1RxDMAChannel
+)
+
+// DMATrigger is the data request signal (DREQ) that paces a DMA transfer. See
+// section 2.5.3.1 of the RP2040 datasheet. The triggers of PIO state machines
+// are returned by PIOStateMachine.TxDREQ and RxDREQ.
+type DMATrigger uint8
+
+const (
+	DMATriggerSPI0TX   DMATrigger = 16
+	DMATriggerSPI0RX   DMATrigger = 17
+	DMATriggerSPI1TX   DMATrigger = 18
+	DMATriggerSPI1RX   DMATrigger = 19
+	DMATriggerUART0TX  DMATrigger = 20
+	DMATriggerUART0RX  DMATrigger = 21
+	DMATriggerUART1TX  DMATrigger = 22
+	DMATriggerUART1RX  DMATrigger = 23
+	DMATriggerPWMWrap0 DMATrigger = 24 // add the slice number for other slices
+	DMATriggerI2C0TX   DMATrigger = 32
+	DMATriggerI2C0RX   DMATrigger = 33
+	DMATriggerI2C1TX   DMATrigger = 34
+	DMATriggerI2C1RX   DMATrigger = 35
+	DMATriggerADC      DMATrigger = 36
+	DMATriggerMemory   DMATrigger = 0x3f // unpaced, as fast as possible
+)
+
+// DMAChannel is one of the 12 DMA channels of the RP2040.
+type DMAChannel struct {
+	index    uint8
+	trigger  DMATrigger
+	ctrl     uint32
+	callback func(ch *DMAChannel)
+	waiter   *task.Task
+}
+
+var (
+	dmaChannelList = [12]DMAChannel{
+		spi0DMAChannel:   {index: spi0DMAChannel, trigger: DMATriggerSPI0TX},
+		spi1DMAChannel:   {index: spi1DMAChannel, trigger: DMATriggerSPI1TX},
+		spi0RxDMAChannel: {index: spi0RxDMAChannel, trigger: DMATriggerSPI0RX},
+		spi1RxDMAChannel: {index: spi1RxDMAChannel, trigger: DMATriggerSPI1RX},
+	}
+	dmaClaimed uint16 = 1<<spi0DMAChannel | 1<<spi1DMAChannel |
+		1<<spi0RxDMAChannel | 1<<spi1RxDMAChannel
+	dmaInterruptActive bool
+)
+
+// ClaimDMAChannel returns a DMA channel that hasn't been claimed yet, for
+// transfers paced by the given trigger.
+func ClaimDMAChannel(trigger DMATrigger) (*DMAChannel, error) {
+	for i := uint8(0); i < uint8(len(dmaChannelList)); i++ {
+		if dmaClaimed&(1<<i) == 0 {
+			dmaClaimed |= 1 << i
+			ch := &dmaChannelList[i]
+			*ch = DMAChannel{index: i, trigger: trigger}
+			ch.Configure(DMAConfig{})
+			return ch, nil
+		}
+	}
+	return nil, ErrNoDMAChannel
+}
+
+// Release aborts any transfer in progress and returns the channel, so that it
+// can be claimed again.
+func (ch *DMAChannel) Release() {
+	ch.Abort()
+	ch.SetCallback(nil)
+	rp.DMA.INTE0.ClearBits(1 << ch.index)
+	dmaClaimed &^= 1 << ch.index
+}
+
+func (ch *DMAChannel) regs() *dmaChannel {
+	return &dmaChannels[ch.index]
+}
+
+// Configure sets the direction and transfer size for the following transfers.
+// It also removes a chain set with ChainTo.
+func (ch *DMAChannel) Configure(config DMAConfig) {
+	ch.enableInterrupt()
+
+	ctrl := uint32(config.Size)<<rp.DMA_CH0_CTRL_TRIG_DATA_SIZE_Pos |
+		uint32(ch.trigger)<<rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_Pos |
+		uint32(ch.index)<<rp.DMA_CH0_CTRL_TRIG_CHAIN_TO_Pos |
+		rp.DMA_CH0_CTRL_TRIG_EN
+	switch config.Direction {
+	case DMAMemToMem:
+		ctrl |= rp.DMA_CH0_CTRL_TRIG_INCR_WRITE
+		if !config.FixedMemory {
+			ctrl |= rp.DMA_CH0_CTRL_TRIG_INCR_READ
+		}
+	case DMAMemToPeripheral:
+		if !config.FixedMemory {
+			ctrl |= rp.DMA_CH0_CTRL_TRIG_INCR_READ
+		}
+	case DMAPeripheralToMem:
+		if !config.FixedMemory {
+			ctrl |= rp.DMA_CH0_CTRL_TRIG_INCR_WRITE
+		}
+	}
+	ch.ctrl = ctrl
+}
+
+// Prepare sets up a transfer of count units from src to dst, without starting
+// it. It is started by Trigger, or by the end of the transfer of a channel
+// that is chained to this one.
+func (ch *DMAChannel) Prepare(dst, src unsafe.Pointer, count int) error {
+	if count <= 0 {
+		return ErrDMATransferSize
+	}
+	if ch.Busy() {
+		return ErrDMABusy
+	}
+	regs := ch.regs()
+	regs.READ_ADDR.Set(uint32(uintptr(src)))
+	regs.WRITE_ADDR.Set(uint32(uintptr(dst)))
+	regs.TRANS_COUNT.Set(uint32(count))
+	regs.AL1_CTRL.Set(ch.ctrl)
+	return nil
+}
+
+// Trigger starts the transfer set up with Prepare.
+func (ch *DMAChannel) Trigger() {
+	rp.DMA.MULTI_CHAN_TRIGGER.Set(1 << ch.index)
+}
+
+// Busy returns whether a transfer is in progress.
+func (ch *DMAChannel) Busy() bool {
+	return ch.regs().CTRL_TRIG.HasBits(rp.DMA_CH0_CTRL_TRIG_BUSY)
+}
+
+// Abort stops the transfer in progress, if any.
+func (ch *DMAChannel) Abort() {
+	// Clear the enable bit first, so that the abort doesn't trigger a chained
+	// channel (erratum RP2040-E13).
+	ch.regs().AL1_CTRL.Set(ch.ctrl &^ rp.DMA_CH0_CTRL_TRIG_EN)
+	rp.DMA.CHAN_ABORT.Set(1 << ch.index)
+	for rp.DMA.CHAN_ABORT.HasBits(1 << ch.index) {
+	}
+}
+
+// ChainTo starts the transfer prepared on next as soon as a transfer on this
+// channel has finished. The RP2040 does this in hardware. Passing nil removes
+// the chain. It takes effect on the next call to Prepare.
+func (ch *DMAChannel) ChainTo(next *DMAChannel) {
+	index := ch.index
+	if next != nil {
+		index = next.index
+	}
+	ch.ctrl = ch.ctrl&^rp.DMA_CH0_CTRL_TRIG_CHAIN_TO_Msk | uint32(index)<<rp.DMA_CH0_CTRL_TRIG_CHAIN_TO_Pos
+}
+
+// SetCallback sets a function that is called from an interrupt whenever a
+// transfer on this channel has finished. Passing nil removes the callback.
+func (ch *DMAChannel) SetCallback(callback func(ch *DMAChannel)) {
+	ch.callback = callback
+}
+
+// Enable the interrupt at the end of the transfers of this channel, which is
+// used by the callback and by Wait.
+func (ch *DMAChannel) enableInterrupt() {
+	if rp.DMA.INTE0.HasBits(1 << ch.index) {
+		return
+	}
+	if !dmaInterruptActive {
+		dmaInterruptActive = true
+		interrupt.New(rp.IRQ_DMA_IRQ_0, dmaHandleInterrupt).Enable()
+	}
+	rp.DMA.INTS0.Set(1 << ch.index)
+	rp.DMA.INTE0.SetBits(1 << ch.index)
+}
+
+func dmaHandleInterrupt(intr interrupt.Interrupt) {
+	status := rp.DMA.INTS0.Get()
+	rp.DMA.INTS0.Set(status)
+	for i := range dmaChannelList {
+		ch := &dmaChannelList[i]
+		if status&(1<<i) == 0 {
+			continue
+		}
+		if ch.callback != nil {
+			ch.callback(ch)
+		}
+		ch.wake()
+	}
+}
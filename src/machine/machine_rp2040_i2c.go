@@ -6,6 +6,7 @@ import (
 	"device/rp"
 	"errors"
 	"internal/itoa"
+	"runtime/interrupt"
 )
 
 // I2C on the RP2040.
@@ -55,6 +56,15 @@ type I2C struct {
 	Bus          *rp.I2C0_Type
 	mode         I2CMode
 	txInProgress bool
+
+	// State of the transaction started with TxAsync.
+	completion   Completion
+	asyncW       []byte
+	asyncR       []byte
+	asyncCmds    int // number of commands written to IC_DATA_CMD
+	asyncRead    int // number of bytes read into asyncR
+	asyncAborted bool
+	asyncAbort   i2cAbortError
 }
 
 var (
@@ -67,6 +77,7 @@ var (
 	ErrI2CAlreadyListening = errors.New("i2c already listening")
 	ErrI2CWrongMode        = errors.New("i2c wrong mode")
 	ErrI2CUnderflow        = errors.New("i2c underflow")
+	ErrI2CBusy             = errors.New("i2c transaction in progress")
 )
 
 // Tx performs a write and then a read transfer placing the result in
@@ -82,10 +93,17 @@ var (
 //	i2c.Tx(addr, w, nil)
 //
 // Performs only a write transfer.
+//
+// ErrI2CBusy is returned while a transaction started with TxAsync is still in
+// progress.
 func (i2c *I2C) Tx(addr uint16, w, r []byte) error {
 	if i2c.mode != I2CModeController {
 		return ErrI2CWrongMode
 	}
+	if !i2c.completion.Done() {
+		// A transaction started with TxAsync is still in progress.
+		return ErrI2CBusy
+	}
 
 	// timeout in microseconds.
 	const timeout = 40 * 1000 // 40ms is a reasonable time for a real-time system.
@@ -407,24 +425,133 @@ func (i2c *I2C) tx(addr uint8, tx, rx []byte, timeout_us uint64) (err error) {
 			rx[rxCtr] = uint8(i2c.Bus.IC_DATA_CMD.Get())
 		}
 	}
-	// From Pico SDK: A lot of things could have just happened due to the ingenious and
-	// creative design of I2C. Try to figure things out.
 	if abort {
-		switch {
-		case abortReason == 0 || abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_7B_ADDR_NOACK != 0:
-			// No reported errors - seems to happen if there is nothing connected to the bus.
-			// Address byte not acknowledged
-			err = ErrI2CGeneric
-		case abortReason&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_TXDATA_NOACK != 0:
-			// Address acknowledged, some data not acknowledged
-			fallthrough
-		default:
-			err = abortReason
-		}
+		err = abortReason.err()
 	}
 	return err
 }
 
+// TxAsync starts a transaction like Tx and returns immediately. The
+// transaction is driven by the I2C interrupt while other goroutines run, and
+// the Completion is used to wait for its end and to get its error.
+//
+// Unlike Tx, there is no timeout. Only one transaction can be in progress on
+// a bus: ErrI2CBusy is returned if the previous one hasn't completed yet.
+func (i2c *I2C) TxAsync(addr uint16, w, r []byte) (*Completion, error) {
+	if i2c.mode != I2CModeController {
+		return nil, ErrI2CWrongMode
+	}
+	if addr >= 0x80 || isReservedI2CAddr(uint8(addr)) {
+		return nil, ErrInvalidTgtAddr
+	}
+	if len(w) == 0 && len(r) == 0 {
+		return &completed, nil
+	}
+	if !i2c.completion.Done() {
+		return nil, ErrI2CBusy
+	}
+
+	err := i2c.disable()
+	if err != nil {
+		return nil, err
+	}
+	i2c.Bus.IC_TAR.Set(uint32(addr))
+	i2c.enable()
+	i2c.Bus.IC_CLR_STOP_DET.Get()
+	i2c.clearAbortReason()
+
+	i2c.asyncW, i2c.asyncR = w, r
+	i2c.asyncCmds, i2c.asyncRead = 0, 0
+	i2c.asyncAborted, i2c.asyncAbort = false, 0
+	i2c.completion.start()
+
+	// The TX FIFO is empty, so the interrupt fires right away to write the
+	// first commands.
+	i2c.Bus.IC_INTR_MASK.Set(rp.I2C0_IC_INTR_MASK_M_TX_EMPTY |
+		rp.I2C0_IC_INTR_MASK_M_RX_FULL |
+		rp.I2C0_IC_INTR_MASK_M_TX_ABRT |
+		rp.I2C0_IC_INTR_MASK_M_STOP_DET)
+	switch i2c.Bus {
+	case rp.I2C0:
+		interrupt.New(rp.IRQ_I2C0_IRQ, _I2C0.handleInterrupt).Enable()
+	case rp.I2C1:
+		interrupt.New(rp.IRQ_I2C1_IRQ, _I2C1.handleInterrupt).Enable()
+	}
+	return &i2c.completion, nil
+}
+
+// handleInterrupt moves the transaction started with TxAsync forward: it
+// reads the received bytes, writes the next commands, and completes the
+// transaction on the STOP condition.
+func (i2c *I2C) handleInterrupt(intr interrupt.Interrupt) {
+	if i2c.completion.Done() {
+		// The peripheral was reset (for example by Configure) while the
+		// interrupt was still enabled.
+		intr.Disable()
+		return
+	}
+
+	stat := i2c.Bus.IC_INTR_STAT.Get()
+	if stat&rp.I2C0_IC_INTR_MASK_M_TX_ABRT != 0 {
+		// The controller flushes the TX FIFO and sends a STOP condition.
+		i2c.asyncAbort = i2c.getAbortReason()
+		i2c.asyncAborted = true
+		i2c.clearAbortReason()
+	}
+
+	for i2c.readAvailable() != 0 {
+		b := uint8(i2c.Bus.IC_DATA_CMD.Get())
+		if i2c.asyncRead < len(i2c.asyncR) {
+			i2c.asyncR[i2c.asyncRead] = b
+			i2c.asyncRead++
+		}
+	}
+
+	txlen := len(i2c.asyncW)
+	total := txlen + len(i2c.asyncR)
+	throttled := false
+	for !i2c.asyncAborted && i2c.asyncCmds < total && i2c.writeAvailable() != 0 {
+		n := i2c.asyncCmds
+		cmd := boolToBit(n == 0)<<rp.I2C0_IC_DATA_CMD_RESTART_Pos |
+			boolToBit(n == total-1)<<rp.I2C0_IC_DATA_CMD_STOP_Pos
+		if n < txlen {
+			cmd |= uint32(i2c.asyncW[n])
+		} else {
+			// Never have more reads in flight than fit in the RX FIFO.
+			const fifoDepth = 16
+			if n-txlen-i2c.asyncRead >= fifoDepth {
+				throttled = true
+				break
+			}
+			cmd |= rp.I2C0_IC_DATA_CMD_CMD
+		}
+		i2c.Bus.IC_DATA_CMD.Set(cmd)
+		i2c.asyncCmds++
+	}
+	if i2c.asyncAborted || i2c.asyncCmds == total {
+		// Nothing left to write, only wait for the STOP condition.
+		i2c.Bus.IC_INTR_MASK.ClearBits(rp.I2C0_IC_INTR_MASK_M_TX_EMPTY)
+	} else if throttled {
+		// The TX FIFO may be empty while waiting for the RX FIFO to be read,
+		// which would fire TX_EMPTY continuously. Wait for RX_FULL instead:
+		// TX_EMPTY is enabled again once the received bytes have been read.
+		i2c.Bus.IC_INTR_MASK.ClearBits(rp.I2C0_IC_INTR_MASK_M_TX_EMPTY)
+	} else {
+		i2c.Bus.IC_INTR_MASK.SetBits(rp.I2C0_IC_INTR_MASK_M_TX_EMPTY)
+	}
+
+	if stat&rp.I2C0_IC_INTR_MASK_M_STOP_DET != 0 {
+		i2c.Bus.IC_CLR_STOP_DET.Get()
+		i2c.Bus.IC_INTR_MASK.Set(0)
+		var err error
+		if i2c.asyncAborted {
+			err = i2c.asyncAbort.err()
+		}
+		i2c.asyncW, i2c.asyncR = nil, nil
+		i2c.completion.complete(err)
+	}
+}
+
 // listen sets up for async handling of requests on the I2C bus.
 func (i2c *I2C) listen(addr uint8) error {
 	if addr >= 0x80 || isReservedI2CAddr(addr) {
@@ -570,6 +697,23 @@ func (i2c *I2C) interrupted(mask uint32) bool {
 
 type i2cAbortError uint32
 
+// err returns the error of a transaction that was aborted for this reason.
+func (b i2cAbortError) err() error {
+	// From Pico SDK: A lot of things could have just happened due to the ingenious and
+	// creative design of I2C. Try to figure things out.
+	switch {
+	case b == 0 || b&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_7B_ADDR_NOACK != 0:
+		// No reported errors - seems to happen if there is nothing connected to the bus.
+		// Address byte not acknowledged
+		return ErrI2CGeneric
+	case b&rp.I2C0_IC_TX_ABRT_SOURCE_ABRT_TXDATA_NOACK != 0:
+		// Address acknowledged, some data not acknowledged
+		fallthrough
+	default:
+		return b
+	}
+}
+
 func (b i2cAbortError) Error() string {
 	return "i2c abort, reason " + itoa.Uitoa(uint(b))
 }
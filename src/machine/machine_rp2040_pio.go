@@ -0,0 +1,619 @@
+//go:build rp2040
+
+package machine
+
+import (
+	"device/rp"
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// Programmable I/O (PIO) support. Each of the two PIO blocks has four state
+// machines that share 32 words of instruction memory. Programs can be written
+// in the pioasm language and converted to Go with `tinygo pioasm`, for example
+// from a go:generate line:
+//
+//	//go:generate tinygo pioasm -o ws2812_pio.go ws2812.pio
+
+var (
+	ErrPIONoSpace        = errors.New("machine: no space in PIO instruction memory")
+	ErrPIONoStateMachine = errors.New("machine: no free PIO state machine")
+)
+
+type pioStateMachineRegs struct {
+	clkdiv    volatile.Register32
+	execctrl  volatile.Register32
+	shiftctrl volatile.Register32
+	addr      volatile.Register32
+	instr     volatile.Register32
+	pinctrl   volatile.Register32
+}
+
+type pioIRQRegs struct {
+	inte volatile.Register32
+	intf volatile.Register32
+	ints volatile.Register32
+}
+
+type pioRegs struct {
+	ctrl            volatile.Register32
+	fstat           volatile.Register32
+	fdebug          volatile.Register32
+	flevel          volatile.Register32
+	txf             [4]volatile.Register32
+	rxf             [4]volatile.Register32
+	irq             volatile.Register32
+	irqForce        volatile.Register32
+	inputSyncBypass volatile.Register32
+	dbgPadout       volatile.Register32
+	dbgPadoe        volatile.Register32
+	dbgCfginfo      volatile.Register32
+	instrMem        [32]volatile.Register32
+	sm              [4]pioStateMachineRegs
+	intr            volatile.Register32
+	irqCtrl         [2]pioIRQRegs
+}
+
+// Bit positions in the PIO registers.
+const (
+	pioCtrlSMEnablePos      = 0
+	pioCtrlSMRestartPos     = 4
+	pioCtrlClkdivRestartPos = 8
+
+	pioFstatRxFullPos  = 0
+	pioFstatRxEmptyPos = 8
+	pioFstatTxFullPos  = 16
+	pioFstatTxEmptyPos = 24
+
+	pioClkdivFracPos = 8
+	pioClkdivIntPos  = 16
+
+	pioExecCtrlStatusNPos     = 0
+	pioExecCtrlStatusSelPos   = 4
+	pioExecCtrlWrapBottomPos  = 7
+	pioExecCtrlWrapTopPos     = 12
+	pioExecCtrlOutStickyPos   = 17
+	pioExecCtrlInlineOutEnPos = 18
+	pioExecCtrlOutEnSelPos    = 19
+	pioExecCtrlJmpPinPos      = 24
+	pioExecCtrlSidePindirPos  = 29
+	pioExecCtrlSideEnPos      = 30
+
+	pioShiftCtrlAutopushPos    = 16
+	pioShiftCtrlAutopullPos    = 17
+	pioShiftCtrlInShiftdirPos  = 18
+	pioShiftCtrlOutShiftdirPos = 19
+	pioShiftCtrlPushThreshPos  = 20
+	pioShiftCtrlPullThreshPos  = 25
+	pioShiftCtrlFjoinTxPos     = 30
+	pioShiftCtrlFjoinRxPos     = 31
+
+	pioPinCtrlOutBasePos      = 0
+	pioPinCtrlSetBasePos      = 5
+	pioPinCtrlSidesetBasePos  = 10
+	pioPinCtrlInBasePos       = 15
+	pioPinCtrlOutCountPos     = 20
+	pioPinCtrlSetCountPos     = 26
+	pioPinCtrlSidesetCountPos = 29
+)
+
+// Encoded instructions used to control state machines.
+const (
+	pioInstrJmp        = 0x0000
+	pioInstrSetPins    = 0xe000
+	pioInstrSetPindirs = 0xe080
+)
+
+// PIO is one of the two PIO blocks.
+type PIO struct {
+	regs             *pioRegs
+	index            uint8
+	usedInstructions uint32 // bitmask of instruction memory used by programs
+	claimed          uint8  // bitmask of claimed state machines
+	handler          func(pio *PIO)
+}
+
+var (
+	PIO0 = &PIO{regs: (*pioRegs)(unsafe.Pointer(rp.PIO0)), index: 0}
+	PIO1 = &PIO{regs: (*pioRegs)(unsafe.Pointer(rp.PIO1)), index: 1}
+)
+
+// PIOProgram is a program for the PIO state machines, as generated by
+// `tinygo pioasm`.
+type PIOProgram struct {
+	Instructions []uint16
+
+	// Origin is the address the program must be loaded at, or -1 if it can be
+	// loaded anywhere.
+	Origin int8
+}
+
+// PinMode returns the pin mode to use for pins that are controlled by this
+// PIO block.
+func (pio *PIO) PinMode() PinMode {
+	if pio.index == 0 {
+		return PinPIO0
+	}
+	return PinPIO1
+}
+
+// AddProgram loads a program into instruction memory and returns the offset
+// it was loaded at. Jump instructions are relocated to this offset. The offset
+// must be passed to the state machine configuration and to Init.
+func (pio *PIO) AddProgram(program *PIOProgram) (offset uint8, err error) {
+	length := len(program.Instructions)
+	if length == 0 || length > len(pio.regs.instrMem) {
+		return 0, ErrPIONoSpace
+	}
+	mask := uint32(1)<<length - 1 // also correct for 32 instructions
+	found := false
+	if program.Origin >= 0 {
+		offset = uint8(program.Origin)
+		found = int(offset)+length <= len(pio.regs.instrMem) && pio.usedInstructions&(mask<<offset) == 0
+	} else {
+		// Allocate from the end of instruction memory, like the Pico SDK.
+		for i := len(pio.regs.instrMem) - length; i >= 0; i-- {
+			if pio.usedInstructions&(mask<<i) == 0 {
+				offset = uint8(i)
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return 0, ErrPIONoSpace
+	}
+
+	for i, instr := range program.Instructions {
+		if instr&0xe000 == pioInstrJmp {
+			// Relocate the jump target.
+			instr = instr&^0x1f | (instr+uint16(offset))&0x1f
+		}
+		pio.regs.instrMem[int(offset)+i].Set(uint32(instr))
+	}
+	pio.usedInstructions |= mask << offset
+	return offset, nil
+}
+
+// RemoveProgram frees the instruction memory used by a program that was
+// loaded at the given offset with AddProgram.
+func (pio *PIO) RemoveProgram(program *PIOProgram, offset uint8) {
+	mask := uint32(1)<<len(program.Instructions) - 1
+	pio.usedInstructions &^= mask << offset
+}
+
+// StateMachine returns one of the four state machines of this PIO block.
+func (pio *PIO) StateMachine(index uint8) PIOStateMachine {
+	return PIOStateMachine{pio: pio, index: index & 3}
+}
+
+// ClaimStateMachine returns a state machine that hasn't been claimed yet, so
+// that different drivers can share a PIO block.
+func (pio *PIO) ClaimStateMachine() (PIOStateMachine, error) {
+	for i := uint8(0); i < 4; i++ {
+		if pio.claimed&(1<<i) == 0 {
+			pio.claimed |= 1 << i
+			return pio.StateMachine(i), nil
+		}
+	}
+	return PIOStateMachine{}, ErrPIONoStateMachine
+}
+
+// IRQFlags returns the state of the eight PIO IRQ flags, which are set by the
+// irq instruction.
+func (pio *PIO) IRQFlags() uint8 {
+	return uint8(pio.regs.irq.Get())
+}
+
+// ClearIRQ clears the given PIO IRQ flags.
+func (pio *PIO) ClearIRQ(flags uint8) {
+	pio.regs.irq.Set(uint32(flags))
+}
+
+// ForceIRQ sets the given PIO IRQ flags.
+func (pio *PIO) ForceIRQ(flags uint8) {
+	pio.regs.irqForce.Set(uint32(flags))
+}
+
+// PIOInterruptSource is a bitmask of events that trigger the PIO interrupt.
+// Shift a source left by the state machine index (for the FIFO sources) or by
+// the IRQ flag index (for PIOIntIRQ, only flags 0-3):
+//
+//	PIO0.SetInterrupt(machine.PIOIntRxNotEmpty<<sm.Index(), handler)
+type PIOInterruptSource uint32
+
+const (
+	PIOIntRxNotEmpty PIOInterruptSource = 1 << 0
+	PIOIntTxNotFull  PIOInterruptSource = 1 << 4
+	PIOIntIRQ        PIOInterruptSource = 1 << 8
+)
+
+// SetInterrupt calls the handler when one of the given events happens. The
+// handler is called in interrupt context and must clear the cause of the
+// interrupt, for example with ClearIRQ or by reading from the RX FIFO.
+// Passing a nil handler disables the interrupt.
+func (pio *PIO) SetInterrupt(sources PIOInterruptSource, handler func(pio *PIO)) {
+	irq := &pio.regs.irqCtrl[0]
+	if handler == nil {
+		irq.inte.Set(0)
+		pio.handler = nil
+		return
+	}
+	pio.handler = handler
+	irq.inte.Set(uint32(sources))
+	if pio.index == 0 {
+		interrupt.New(rp.IRQ_PIO0_IRQ_0, pio0HandleInterrupt).Enable()
+	} else {
+		interrupt.New(rp.IRQ_PIO1_IRQ_0, pio1HandleInterrupt).Enable()
+	}
+}
+
+func pio0HandleInterrupt(intr interrupt.Interrupt) {
+	PIO0.handleInterrupt()
+}
+
+func pio1HandleInterrupt(intr interrupt.Interrupt) {
+	PIO1.handleInterrupt()
+}
+
+func (pio *PIO) handleInterrupt() {
+	if pio.handler != nil {
+		pio.handler(pio)
+	}
+}
+
+// PIOFIFOJoin selects whether the TX and RX FIFOs of a state machine are
+// joined into a single FIFO of twice the depth.
+type PIOFIFOJoin uint8
+
+const (
+	PIOFIFOJoinNone PIOFIFOJoin = iota
+	PIOFIFOJoinTx               // only a TX FIFO, of 8 words
+	PIOFIFOJoinRx               // only an RX FIFO, of 8 words
+)
+
+// PIOMovStatus selects what the status source of the mov instruction
+// compares against.
+type PIOMovStatus uint8
+
+const (
+	PIOMovStatusTxLessThan PIOMovStatus = iota // all-ones if the TX FIFO level is less than N
+	PIOMovStatusRxLessThan                     // all-ones if the RX FIFO level is less than N
+)
+
+// PIOStateMachineConfig holds the configuration registers of a state machine.
+// Start with DefaultPIOStateMachineConfig or with the default config of a
+// program generated by `tinygo pioasm`, and use the setters to change it.
+type PIOStateMachineConfig struct {
+	ClkDiv    uint32
+	ExecCtrl  uint32
+	ShiftCtrl uint32
+	PinCtrl   uint32
+}
+
+// DefaultPIOStateMachineConfig returns a configuration with a clock divider
+// of 1, a wrap from 0 to 31, both shift registers shifting right without
+// autopush or autopull and no pins.
+func DefaultPIOStateMachineConfig() PIOStateMachineConfig {
+	cfg := PIOStateMachineConfig{}
+	cfg.SetClkDivIntFrac(1, 0)
+	cfg.SetWrap(0, 31)
+	cfg.SetInShift(true, false, 32)
+	cfg.SetOutShift(true, false, 32)
+	return cfg
+}
+
+// Replace the bits of a register value selected by mask (after shifting) with
+// the given value.
+func pioSetField(reg *uint32, pos, mask, value uint32) {
+	*reg = *reg&^(mask<<pos) | (value&mask)<<pos
+}
+
+func pioBool(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SetOutPins sets the pins used by the out instruction.
+func (cfg *PIOStateMachineConfig) SetOutPins(base Pin, count uint8) {
+	pioSetField(&cfg.PinCtrl, pioPinCtrlOutBasePos, 0x1f, uint32(base))
+	pioSetField(&cfg.PinCtrl, pioPinCtrlOutCountPos, 0x3f, uint32(count))
+}
+
+// SetSetPins sets the pins used by the set instruction. At most 5 pins can be
+// used.
+func (cfg *PIOStateMachineConfig) SetSetPins(base Pin, count uint8) {
+	pioSetField(&cfg.PinCtrl, pioPinCtrlSetBasePos, 0x1f, uint32(base))
+	pioSetField(&cfg.PinCtrl, pioPinCtrlSetCountPos, 0x7, uint32(count))
+}
+
+// SetInPins sets the first pin used by the in and wait instructions.
+func (cfg *PIOStateMachineConfig) SetInPins(base Pin) {
+	pioSetField(&cfg.PinCtrl, pioPinCtrlInBasePos, 0x1f, uint32(base))
+}
+
+// SetSidesetPins sets the first pin used by side-set.
+func (cfg *PIOStateMachineConfig) SetSidesetPins(base Pin) {
+	pioSetField(&cfg.PinCtrl, pioPinCtrlSidesetBasePos, 0x1f, uint32(base))
+}
+
+// SetSideset configures side-set. The bit count includes the enable bit if
+// side-set is optional. If pindirs is true, side-set changes the pin
+// directions instead of the pin values.
+func (cfg *PIOStateMachineConfig) SetSideset(bitCount uint8, optional, pindirs bool) {
+	pioSetField(&cfg.PinCtrl, pioPinCtrlSidesetCountPos, 0x7, uint32(bitCount))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlSideEnPos, 1, pioBool(optional))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlSidePindirPos, 1, pioBool(pindirs))
+}
+
+// SetClkDivIntFrac sets the clock divider as an integer part and a fractional
+// part in 1/256ths. An integer part of 0 divides by 65536.
+func (cfg *PIOStateMachineConfig) SetClkDivIntFrac(div uint16, frac uint8) {
+	cfg.ClkDiv = uint32(div)<<pioClkdivIntPos | uint32(frac)<<pioClkdivFracPos
+}
+
+// SetFrequency sets the clock divider so that the state machine runs at the
+// given frequency in Hz, which is limited to the range of the divider. A
+// frequency of 0 means no divider: the state machine runs at the system clock.
+func (cfg *PIOStateMachineConfig) SetFrequency(hz uint32) {
+	if hz == 0 {
+		cfg.SetClkDivIntFrac(1, 0)
+		return
+	}
+	div := uint64(CPUFrequency()) * 256 / uint64(hz) // in 1/256ths
+	switch {
+	case div < 256:
+		div = 256
+	case div >= 65536*256:
+		div = 0 // the maximum, divide by 65536
+	}
+	cfg.SetClkDivIntFrac(uint16(div>>8), uint8(div))
+}
+
+// SetWrap sets the addresses at which the program wraps: after executing the
+// instruction at wrap, it continues at wrapTarget. Both are absolute
+// addresses, so the offset of the program must be added.
+func (cfg *PIOStateMachineConfig) SetWrap(wrapTarget, wrap uint8) {
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlWrapBottomPos, 0x1f, uint32(wrapTarget))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlWrapTopPos, 0x1f, uint32(wrap))
+}
+
+// SetJmpPin sets the pin used by jmp pin.
+func (cfg *PIOStateMachineConfig) SetJmpPin(pin Pin) {
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlJmpPinPos, 0x1f, uint32(pin))
+}
+
+// SetInShift configures the input shift register. A threshold of 32 is
+// encoded as 0 by the hardware.
+func (cfg *PIOStateMachineConfig) SetInShift(shiftRight, autopush bool, pushThreshold uint8) {
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlInShiftdirPos, 1, pioBool(shiftRight))
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlAutopushPos, 1, pioBool(autopush))
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlPushThreshPos, 0x1f, uint32(pushThreshold))
+}
+
+// SetOutShift configures the output shift register. A threshold of 32 is
+// encoded as 0 by the hardware.
+func (cfg *PIOStateMachineConfig) SetOutShift(shiftRight, autopull bool, pullThreshold uint8) {
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlOutShiftdirPos, 1, pioBool(shiftRight))
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlAutopullPos, 1, pioBool(autopull))
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlPullThreshPos, 0x1f, uint32(pullThreshold))
+}
+
+// SetFIFOJoin joins the TX and RX FIFOs into a single FIFO.
+func (cfg *PIOStateMachineConfig) SetFIFOJoin(join PIOFIFOJoin) {
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlFjoinTxPos, 1, pioBool(join == PIOFIFOJoinTx))
+	pioSetField(&cfg.ShiftCtrl, pioShiftCtrlFjoinRxPos, 1, pioBool(join == PIOFIFOJoinRx))
+}
+
+// SetOutSpecial configures special behavior of out pins: with sticky, the
+// last value is kept on the pins. With hasEnablePin, the bit enablePinIndex
+// of the out data enables or disables the out pins.
+func (cfg *PIOStateMachineConfig) SetOutSpecial(sticky, hasEnablePin bool, enablePinIndex uint8) {
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlOutStickyPos, 1, pioBool(sticky))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlInlineOutEnPos, 1, pioBool(hasEnablePin))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlOutEnSelPos, 0x1f, uint32(enablePinIndex))
+}
+
+// SetMovStatus configures the status source of the mov instruction.
+func (cfg *PIOStateMachineConfig) SetMovStatus(status PIOMovStatus, n uint8) {
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlStatusSelPos, 1, uint32(status))
+	pioSetField(&cfg.ExecCtrl, pioExecCtrlStatusNPos, 0xf, uint32(n))
+}
+
+// PIOStateMachine is one of the four state machines of a PIO block.
+type PIOStateMachine struct {
+	pio   *PIO
+	index uint8
+}
+
+// PIO returns the PIO block of this state machine.
+func (sm PIOStateMachine) PIO() *PIO {
+	return sm.pio
+}
+
+// Index returns the index of this state machine in its PIO block.
+func (sm PIOStateMachine) Index() uint8 {
+	return sm.index
+}
+
+// Unclaim releases a state machine claimed with ClaimStateMachine.
+func (sm PIOStateMachine) Unclaim() {
+	sm.pio.claimed &^= 1 << sm.index
+}
+
+func (sm PIOStateMachine) regs() *pioStateMachineRegs {
+	return &sm.pio.regs.sm[sm.index]
+}
+
+// Init resets the state machine to a known state with the given
+// configuration and makes it start at initialPC (usually the offset of the
+// program) once it is enabled. The state machine is left disabled.
+func (sm PIOStateMachine) Init(initialPC uint8, cfg PIOStateMachineConfig) {
+	sm.SetEnabled(false)
+	sm.SetConfig(cfg)
+	sm.ClearFIFOs()
+
+	// Clear the sticky FIFO debug flags of this state machine.
+	sm.pio.regs.fdebug.Set(0x01010101 << sm.index)
+
+	sm.Restart()
+	sm.ClkDivRestart()
+	sm.Exec(pioInstrJmp | uint16(initialPC)&0x1f)
+}
+
+// SetConfig writes the configuration registers of the state machine.
+func (sm PIOStateMachine) SetConfig(cfg PIOStateMachineConfig) {
+	regs := sm.regs()
+	regs.clkdiv.Set(cfg.ClkDiv)
+	regs.execctrl.Set(cfg.ExecCtrl)
+	regs.shiftctrl.Set(cfg.ShiftCtrl)
+	regs.pinctrl.Set(cfg.PinCtrl)
+}
+
+// SetEnabled starts or stops the state machine.
+func (sm PIOStateMachine) SetEnabled(enabled bool) {
+	if enabled {
+		sm.pio.regs.ctrl.SetBits(1 << (pioCtrlSMEnablePos + sm.index))
+	} else {
+		sm.pio.regs.ctrl.ClearBits(1 << (pioCtrlSMEnablePos + sm.index))
+	}
+}
+
+// IsEnabled returns whether the state machine is running.
+func (sm PIOStateMachine) IsEnabled() bool {
+	return sm.pio.regs.ctrl.HasBits(1 << (pioCtrlSMEnablePos + sm.index))
+}
+
+// Restart clears the internal state of the state machine, such as the shift
+// counters and delays. It does not change the program counter.
+func (sm PIOStateMachine) Restart() {
+	sm.pio.regs.ctrl.SetBits(1 << (pioCtrlSMRestartPos + sm.index))
+}
+
+// ClkDivRestart restarts the clock divider of the state machine.
+func (sm PIOStateMachine) ClkDivRestart() {
+	sm.pio.regs.ctrl.SetBits(1 << (pioCtrlClkdivRestartPos + sm.index))
+}
+
+// Exec executes a single encoded instruction immediately.
+func (sm PIOStateMachine) Exec(instr uint16) {
+	sm.regs().instr.Set(uint32(instr))
+}
+
+// PC returns the current program counter of the state machine.
+func (sm PIOStateMachine) PC() uint8 {
+	return uint8(sm.regs().addr.Get())
+}
+
+// SetPindirsConsecutive sets the direction of count pins starting at pin, by
+// executing set instructions on the state machine.
+func (sm PIOStateMachine) SetPindirsConsecutive(pin Pin, count uint8, output bool) {
+	sm.setConsecutive(pioInstrSetPindirs, pin, count, output)
+}
+
+// SetPinsConsecutive sets the value of count pins starting at pin, by
+// executing set instructions on the state machine.
+func (sm PIOStateMachine) SetPinsConsecutive(pin Pin, count uint8, high bool) {
+	sm.setConsecutive(pioInstrSetPins, pin, count, high)
+}
+
+func (sm PIOStateMachine) setConsecutive(instr uint16, pin Pin, count uint8, value bool) {
+	regs := sm.regs()
+	pinctrl := regs.pinctrl.Get()
+	execctrl := regs.execctrl.Get()
+	regs.execctrl.ClearBits(1 << pioExecCtrlOutStickyPos)
+	data := uint16(0)
+	if value {
+		data = 0x1f
+	}
+	for count > 0 {
+		n := count
+		if n > 5 {
+			n = 5
+		}
+		regs.pinctrl.Set(uint32(n)<<pioPinCtrlSetCountPos | uint32(pin&0x1f)<<pioPinCtrlSetBasePos)
+		sm.Exec(instr | data&(1<<n-1))
+		pin += Pin(n)
+		count -= n
+	}
+	regs.pinctrl.Set(pinctrl)
+	regs.execctrl.Set(execctrl)
+}
+
+// ClearFIFOs discards all data in the TX and RX FIFOs.
+func (sm PIOStateMachine) ClearFIFOs() {
+	// Changing FJOIN_RX clears both FIFOs.
+	shiftctrl := &sm.regs().shiftctrl
+	shiftctrl.Set(shiftctrl.Get() ^ 1<<pioShiftCtrlFjoinRxPos)
+	shiftctrl.Set(shiftctrl.Get() ^ 1<<pioShiftCtrlFjoinRxPos)
+}
+
+// IsTxFIFOFull returns whether the TX FIFO is full.
+func (sm PIOStateMachine) IsTxFIFOFull() bool {
+	return sm.pio.regs.fstat.HasBits(1 << (pioFstatTxFullPos + sm.index))
+}
+
+// IsTxFIFOEmpty returns whether the TX FIFO is empty.
+func (sm PIOStateMachine) IsTxFIFOEmpty() bool {
+	return sm.pio.regs.fstat.HasBits(1 << (pioFstatTxEmptyPos + sm.index))
+}
+
+// IsRxFIFOFull returns whether the RX FIFO is full.
+func (sm PIOStateMachine) IsRxFIFOFull() bool {
+	return sm.pio.regs.fstat.HasBits(1 << (pioFstatRxFullPos + sm.index))
+}
+
+// IsRxFIFOEmpty returns whether the RX FIFO is empty.
+func (sm PIOStateMachine) IsRxFIFOEmpty() bool {
+	return sm.pio.regs.fstat.HasBits(1 << (pioFstatRxEmptyPos + sm.index))
+}
+
+// TxFIFOLevel returns the number of words in the TX FIFO.
+func (sm PIOStateMachine) TxFIFOLevel() int {
+	return int(sm.pio.regs.flevel.Get()>>(sm.index*8)) & 0xf
+}
+
+// RxFIFOLevel returns the number of words in the RX FIFO.
+func (sm PIOStateMachine) RxFIFOLevel() int {
+	return int(sm.pio.regs.flevel.Get()>>(sm.index*8+4)) & 0xf
+}
+
+// TxPut writes a word to the TX FIFO, waiting until there is space.
+func (sm PIOStateMachine) TxPut(value uint32) {
+	for sm.IsTxFIFOFull() {
+	}
+	sm.pio.regs.txf[sm.index].Set(value)
+}
+
+// RxGet reads a word from the RX FIFO, waiting until there is one.
+func (sm PIOStateMachine) RxGet() uint32 {
+	for sm.IsRxFIFOEmpty() {
+	}
+	return sm.pio.regs.rxf[sm.index].Get()
+}
+
+// TxRegister returns the TX FIFO register, for use as a DMA destination.
+func (sm PIOStateMachine) TxRegister() *volatile.Register32 {
+	return &sm.pio.regs.txf[sm.index]
+}
+
+// RxRegister returns the RX FIFO register, for use as a DMA source.
+func (sm PIOStateMachine) RxRegister() *volatile.Register32 {
+	return &sm.pio.regs.rxf[sm.index]
+}
+
+// TxDREQ returns the DMA trigger that paces transfers to the TX FIFO, for use
+// with ClaimDMAChannel.
+func (sm PIOStateMachine) TxDREQ() DMATrigger {
+	return DMATrigger(sm.pio.index*8 + sm.index)
+}
+
+// RxDREQ returns the DMA trigger that paces transfers from the RX FIFO, for
+// use with ClaimDMAChannel.
+func (sm PIOStateMachine) RxDREQ() DMATrigger {
+	return DMATrigger(sm.pio.index*8 + 4 + sm.index)
+}
@@ -91,6 +91,87 @@ func (spi SPI) Tx(w, r []byte) (err error) {
 	return err
 }
 
+// Completions of the transactions started with TxAsync on SPI0 and SPI1.
+var spiCompletions [2]Completion
+
+// Source of the zeros sent while only reading, and destination of the bytes
+// received while only writing, for TxAsync.
+var spiTxZero, spiRxDiscard byte
+
+// TxAsync starts a transaction like Tx and returns immediately. The bytes are
+// moved by DMA while other goroutines run, and the Completion is used to wait
+// for the end of the transaction. The same forms as Tx are supported,
+// including reading with a repeated value.
+//
+// Only one transaction can be in progress on a bus: ErrDMABusy is returned if
+// the previous one hasn't completed yet.
+func (spi SPI) TxAsync(w, r []byte) (*Completion, error) {
+	var src *byte
+	count, fixedSrc := len(w), false
+	switch {
+	case len(w) == 0:
+		// read only, so write zero and read a result.
+		src, count, fixedSrc = &spiTxZero, len(r), true
+	case len(r) == 0:
+		// write only
+		src = &w[0]
+	case len(w) == 1 && len(r) > 1:
+		// Read with custom repeated value.
+		src, count, fixedSrc = &w[0], len(r), true
+	case len(w) != len(r):
+		return nil, ErrTxInvalidSliceSize
+	default:
+		src = &w[0]
+	}
+	if count == 0 {
+		return &completed, nil
+	}
+	dst, fixedDst := &spiRxDiscard, true
+	if len(r) != 0 {
+		dst, fixedDst = &r[0], false
+	}
+
+	index := 0
+	if spi.Bus != rp.SPI0 {
+		index = 1
+	}
+	c := &spiCompletions[index]
+	if !c.Done() {
+		return nil, ErrDMABusy
+	}
+	tx := &dmaChannelList[spi0DMAChannel+index]
+	rx := &dmaChannelList[spi0RxDMAChannel+index]
+
+	// Drop anything left in the RX FIFO, so that it doesn't end up in r.
+	for spi.isReadable() {
+		spi.Bus.SSPDR.Get()
+	}
+
+	// Every byte written is also read, so the transaction is over once the
+	// RX channel has received the last byte.
+	rx.Configure(DMAConfig{Direction: DMAPeripheralToMem, Size: DMASize8, FixedMemory: fixedDst})
+	err := rx.Prepare(unsafe.Pointer(dst), unsafe.Pointer(&spi.Bus.SSPDR), count)
+	if err != nil {
+		return nil, err
+	}
+	tx.Configure(DMAConfig{Direction: DMAMemToPeripheral, Size: DMASize8, FixedMemory: fixedSrc})
+	err = tx.Prepare(unsafe.Pointer(&spi.Bus.SSPDR), unsafe.Pointer(src), count)
+	if err != nil {
+		return nil, err
+	}
+	c.start()
+	rx.SetCallback(spiHandleDMA)
+	rx.Trigger()
+	tx.Trigger()
+	return c, nil
+}
+
+// spiHandleDMA is called from the DMA interrupt when the RX channel of a SPI
+// bus has finished.
+func spiHandleDMA(ch *DMAChannel) {
+	spiCompletions[ch.index-spi0RxDMAChannel].complete(nil)
+}
+
 // Write a single byte and read a single byte from TX/RX FIFO.
 func (spi SPI) Transfer(w byte) (byte, error) {
 	for !spi.isWritable() {
@@ -296,40 +377,20 @@ func (spi SPI) tx(tx []byte) error {
 	}
 
 	// Pick the DMA channel reserved for this SPI peripheral.
-	var ch *dmaChannel
-	var dreq uint32
-	if spi.Bus == rp.SPI0 {
-		ch = &dmaChannels[spi0DMAChannel]
-		dreq = 16 // DREQ_SPI0_TX
-	} else { // SPI1
-		ch = &dmaChannels[spi1DMAChannel]
-		dreq = 18 // DREQ_SPI1_TX
-	}
-
-	// Configure the DMA peripheral as follows:
-	//   - set read address, write address, and number of transfer units (bytes)
-	//   - increment read address (in memory), don't increment write address (SSPDR)
-	//   - set data size to single bytes
-	//   - set the DREQ so that the DMA will fill the SPI FIFO as needed
-	//   - start the transfer
-	ch.READ_ADDR.Set(uint32(uintptr(unsafe.Pointer(&tx[0]))))
-	ch.WRITE_ADDR.Set(uint32(uintptr(unsafe.Pointer(&spi.Bus.SSPDR))))
-	ch.TRANS_COUNT.Set(uint32(len(tx)))
-	ch.CTRL_TRIG.Set(rp.DMA_CH0_CTRL_TRIG_INCR_READ |
-		rp.DMA_CH0_CTRL_TRIG_DATA_SIZE_SIZE_BYTE<<rp.DMA_CH0_CTRL_TRIG_DATA_SIZE_Pos |
-		dreq<<rp.DMA_CH0_CTRL_TRIG_TREQ_SEL_Pos |
-		rp.DMA_CH0_CTRL_TRIG_EN)
-
-	// Wait until the transfer is complete.
-	// TODO: do this more efficiently:
-	//   - Add a new API to start the transfer, without waiting for it to
-	//     complete. This way, the CPU can do something useful while the
-	//     transfer is in progress.
-	//   - If we have to wait, do so by waiting for an interrupt and blocking
-	//     this goroutine until finished (so that other goroutines can run or
-	//     the CPU can go to sleep).
-	for ch.CTRL_TRIG.Get()&rp.DMA_CH0_CTRL_TRIG_BUSY != 0 {
+	ch := &dmaChannelList[spi0DMAChannel]
+	if spi.Bus != rp.SPI0 {
+		ch = &dmaChannelList[spi1DMAChannel]
+	}
+
+	// Copy the buffer to the SPI FIFO, one byte at a time, paced by the SPI
+	// TX DREQ. Wait until the transfer is complete, letting other goroutines
+	// run in the meantime.
+	ch.Configure(DMAConfig{Direction: DMAMemToPeripheral, Size: DMASize8})
+	err := ch.Start(unsafe.Pointer(&spi.Bus.SSPDR), unsafe.Pointer(&tx[0]), len(tx))
+	if err != nil {
+		return err
 	}
+	ch.Wait()
 
 	// We didn't read any result values, which means the RX FIFO has likely
 	// overflown. We have to clean up this mess now.
@@ -5,6 +5,7 @@ package machine
 import (
 	"device/rp"
 	"runtime/interrupt"
+	"unsafe"
 )
 
 // UART on the RP2040.
@@ -12,6 +13,8 @@ type UART struct {
 	Buffer    *RingBuffer
 	Bus       *rp.UART0_Type
 	Interrupt interrupt.Interrupt
+	uartDMA
+	uartFraming
 }
 
 // Configure the UART.
@@ -32,8 +35,10 @@ func (uart *UART) Configure(config UARTConfig) error {
 
 	uart.SetBaudRate(config.BaudRate)
 
-	// default to 8-1-N
-	uart.SetFormat(8, 1, ParityNone)
+	// Frame format, 8-1-N by default.
+	if err := uart.configureFraming(config); err != nil {
+		return err
+	}
 
 	// Enable the UART, both TX and RX
 	settings := uint32(rp.UART0_UARTCR_UARTEN |
@@ -112,28 +117,55 @@ func (uart *UART) writeByte(c byte) error {
 	return nil
 }
 
+// Enable or disable the DMA request signal for the TX FIFO.
+func (uart *UART) setTxDMA(enabled bool) {
+	if enabled {
+		uart.Bus.UARTDMACR.SetBits(rp.UART0_UARTDMACR_TXDMAE)
+	} else {
+		uart.Bus.UARTDMACR.ClearBits(rp.UART0_UARTDMACR_TXDMAE)
+	}
+}
+
+// The register that TX DMA transfers write to.
+func (uart *UART) txDataRegister() unsafe.Pointer {
+	return unsafe.Pointer(&uart.Bus.UARTDR)
+}
+
 func (uart *UART) flush() {
 	for uart.Bus.UARTFR.HasBits(rp.UART0_UARTFR_BUSY) {
 		gosched()
 	}
 }
 
-// SetFormat for number of data bits, stop bits, and parity for the UART.
+// SetFormat for number of data bits (5 to 8), stop bits (1 or 2), and parity
+// for the UART.
 func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
-	var pen, pev uint8
+	if databits < 5 || databits > 8 || stopbits < 1 || stopbits > 2 || parity > ParityOdd {
+		return ErrInvalidUARTFormat
+	}
+	var pen, pev uint32
 	if parity != ParityNone {
 		pen = rp.UART0_UARTLCR_H_PEN
 	}
 	if parity == ParityEven {
 		pev = rp.UART0_UARTLCR_H_EPS
 	}
-	uart.Bus.UARTLCR_H.SetBits(uint32((databits-5)<<rp.UART0_UARTLCR_H_WLEN_Pos |
-		(stopbits-1)<<rp.UART0_UARTLCR_H_STP2_Pos |
-		pen | pev))
+	uart.Bus.UARTLCR_H.ClearBits(rp.UART0_UARTLCR_H_WLEN_Msk |
+		rp.UART0_UARTLCR_H_STP2 |
+		rp.UART0_UARTLCR_H_PEN |
+		rp.UART0_UARTLCR_H_EPS)
+	uart.Bus.UARTLCR_H.SetBits(uint32(databits-5)<<rp.UART0_UARTLCR_H_WLEN_Pos |
+		uint32(stopbits-1)<<rp.UART0_UARTLCR_H_STP2_Pos |
+		pen | pev)
 
 	return nil
 }
 
+// The last stop bit has been sent once the UART is no longer busy.
+func (uart *UART) waitTxComplete() {
+	uart.flush()
+}
+
 func initUART(uart *UART) {
 	var resetVal uint32
 	switch {
@@ -155,5 +187,14 @@ func initUART(uart *UART) {
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
 	for uart.Bus.UARTFR.HasBits(rp.UART0_UARTFR_RXFE) {
 	}
-	uart.Receive(byte((uart.Bus.UARTDR.Get() & 0xFF)))
+	// The error flags of a character are read together with its data.
+	data := uart.Bus.UARTDR.Get()
+	var events UARTEvent
+	switch {
+	case data&rp.UART0_UARTDR_BE != 0:
+		events = UARTBreak
+	case data&(rp.UART0_UARTDR_FE|rp.UART0_UARTDR_PE) != 0:
+		events = UARTError
+	}
+	uart.receiveChar(byte(data&0xFF), events)
 }
@@ -24,10 +24,16 @@ type UART struct {
 	txReg       *volatile.Register32
 	statusReg   *volatile.Register32
 	txEmptyFlag uint32
+
+	// Bits of the received characters that are data, not parity.
+	rxMask uint8
+
+	uartDMA
+	uartFraming
 }
 
 // Configure the UART.
-func (uart *UART) Configure(config UARTConfig) {
+func (uart *UART) Configure(config UARTConfig) error {
 	// Default baud rate to 115200.
 	if config.BaudRate == 0 {
 		config.BaudRate = 115200
@@ -52,18 +58,81 @@ func (uart *UART) Configure(config UARTConfig) {
 	// Set baud rate
 	uart.SetBaudRate(config.BaudRate)
 
+	// Frame format, 8-1-N by default.
+	uart.Bus.CR1.Set(0)
+	if err := uart.configureFraming(config); err != nil {
+		return err
+	}
+
 	// Enable USART port, tx, rx and rx interrupts
-	uart.Bus.CR1.Set(stm32.USART_CR1_TE | stm32.USART_CR1_RE | stm32.USART_CR1_RXNEIE | stm32.USART_CR1_UE)
+	uart.Bus.CR1.SetBits(stm32.USART_CR1_TE | stm32.USART_CR1_RE | stm32.USART_CR1_RXNEIE | stm32.USART_CR1_UE)
 
 	// Enable RX IRQ
 	uart.Interrupt.SetPriority(0xc0)
 	uart.Interrupt.Enable()
+
+	return nil
+}
+
+// SetFormat sets the number of data bits, stop bits (1 or 2) and the parity
+// of the UART. The number of data bits plus the parity bit must be 8 or 9, or
+// 7 on the F7, L0, L4, L5 and WL series.
+func (uart *UART) SetFormat(databits, stopbits uint8, parity UARTParity) error {
+	if databits > 8 || stopbits < 1 || stopbits > 2 || parity > ParityOdd {
+		return ErrInvalidUARTFormat
+	}
+	bits := databits
+	if parity != ParityNone {
+		bits++
+	}
+
+	// The frame format can only be changed while the USART is disabled.
+	enabled := uart.Bus.CR1.HasBits(stm32.USART_CR1_UE)
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_UE)
+	defer func() {
+		if enabled {
+			uart.Bus.CR1.SetBits(stm32.USART_CR1_UE)
+		}
+	}()
+
+	if !uart.setWordLength(bits) {
+		return ErrInvalidUARTFormat
+	}
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_PCE | stm32.USART_CR1_PS)
+	if parity != ParityNone {
+		uart.Bus.CR1.SetBits(stm32.USART_CR1_PCE)
+	}
+	if parity == ParityOdd {
+		uart.Bus.CR1.SetBits(stm32.USART_CR1_PS)
+	}
+	uart.Bus.CR2.ReplaceBits(uint32(stopbits-1)<<1, 0x3, stm32.USART_CR2_STOP_Pos) // 0b10 is 2 stop bits
+	uart.rxMask = 0xFF >> (8 - databits)
+	return nil
+}
+
+// waitTxComplete waits until the last stop bit has been sent. The TC flag is
+// cleared by writing new data.
+func (uart *UART) waitTxComplete() {
+	for !uart.statusReg.HasBits(uartStatusTC) {
+	}
 }
 
 // handleInterrupt should be called from the appropriate interrupt handler for
 // this UART instance.
 func (uart *UART) handleInterrupt(interrupt.Interrupt) {
-	uart.Receive(byte((uart.rxReg.Get() & 0xFF)))
+	// The error flags of a character must be read before its data. A framing
+	// error with only zero bits is a break.
+	status := uart.statusReg.Get() & (uartStatusPE | uartStatusFE)
+	data := byte(uart.rxReg.Get()) & uart.rxMask
+	var events UARTEvent
+	if status != 0 {
+		uart.clearRxErrors()
+		events = UARTError
+		if status == uartStatusFE && data == 0 {
+			events = UARTBreak
+		}
+	}
+	uart.receiveChar(data, events)
 }
 
 // SetBaudRate sets the communication speed for the UART. Defer to chip-specific
@@ -0,0 +1,35 @@
+//go:build stm32f4 || stm32f1
+
+package machine
+
+// UART frame format and receive errors for 'older' STM32 MCUs, including the
+// F1 and F4 series of MCUs.
+
+import (
+	"device/stm32"
+)
+
+const (
+	uartStatusPE = stm32.USART_SR_PE
+	uartStatusFE = stm32.USART_SR_FE
+	uartStatusTC = stm32.USART_SR_TC
+)
+
+// setWordLength sets the number of bits of a character, including the parity
+// bit: 8 or 9.
+func (uart *UART) setWordLength(bits uint8) bool {
+	switch bits {
+	case 8:
+		uart.Bus.CR1.ClearBits(stm32.USART_CR1_M)
+	case 9:
+		uart.Bus.CR1.SetBits(stm32.USART_CR1_M)
+	default:
+		return false
+	}
+	return true
+}
+
+// clearRxErrors clears the receive error flags. Reading the status register
+// and then the data register, as the interrupt handler does, already clears
+// them on these chips.
+func (uart *UART) clearRxErrors() {}
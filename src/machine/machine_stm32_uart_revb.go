@@ -0,0 +1,38 @@
+//go:build stm32l5 || stm32f7 || stm32l4 || stm32l0 || stm32wlx
+
+package machine
+
+// UART frame format and receive errors for 'newer' STM32 MCUs, including the
+// F7, L5 and L4 series of MCUs.
+
+import (
+	"device/stm32"
+)
+
+const (
+	uartStatusPE = stm32.USART_ISR_PE
+	uartStatusFE = stm32.USART_ISR_FE
+	uartStatusTC = stm32.USART_ISR_TC
+)
+
+// setWordLength sets the number of bits of a character, including the parity
+// bit: 7, 8 or 9.
+func (uart *UART) setWordLength(bits uint8) bool {
+	uart.Bus.CR1.ClearBits(stm32.USART_CR1_M0 | stm32.USART_CR1_M1)
+	switch bits {
+	case 7:
+		uart.Bus.CR1.SetBits(stm32.USART_CR1_M1)
+	case 8:
+	case 9:
+		uart.Bus.CR1.SetBits(stm32.USART_CR1_M0)
+	default:
+		return false
+	}
+	return true
+}
+
+// clearRxErrors clears the receive error flags, which would otherwise stay
+// set for the next characters.
+func (uart *UART) clearRxErrors() {
+	uart.Bus.ICR.Set(stm32.USART_ICR_PECF | stm32.USART_ICR_FECF | stm32.USART_ICR_ORECF)
+}
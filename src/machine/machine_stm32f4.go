@@ -665,6 +665,20 @@ func (uart *UART) setRegisters() {
 	uart.txEmptyFlag = stm32.USART_SR_TXE
 }
 
+// Enable or disable the DMA request for the transmit data register.
+func (uart *UART) setTxDMA(enabled bool) {
+	if enabled {
+		uart.Bus.CR3.SetBits(stm32.USART_CR3_DMAT)
+	} else {
+		uart.Bus.CR3.ClearBits(stm32.USART_CR3_DMAT)
+	}
+}
+
+// The register that TX DMA transfers write to.
+func (uart *UART) txDataRegister() unsafe.Pointer {
+	return unsafe.Pointer(&uart.Bus.DR)
+}
+
 // -- SPI ----------------------------------------------------------------------
 
 type SPI struct {
@@ -672,6 +686,161 @@ type SPI struct {
 	AltFuncSelector uint8
 }
 
+// DMA channels set with SetTxDMA, by bus. They can't be stored in SPI itself
+// as it is used by value.
+var spiTxDMA [6]struct {
+	bus *stm32.SPI_Type
+	ch  *DMAChannel
+}
+
+// SetTxDMA makes Tx use the given DMA channel when it only writes data, which
+// is the common case for displays. The channel must have been claimed with the
+// TX trigger of this SPI bus (for example DMATriggerSPI1TX). Other goroutines
+// can run while the data is being sent. Passing nil goes back to sending one
+// byte at a time.
+func (spi SPI) SetTxDMA(ch *DMAChannel) {
+	for i := range spiTxDMA {
+		if spiTxDMA[i].bus == spi.Bus || spiTxDMA[i].bus == nil {
+			spiTxDMA[i].bus = spi.Bus
+			spiTxDMA[i].ch = ch
+			break
+		}
+	}
+	if ch != nil {
+		ch.Configure(DMAConfig{Direction: DMAMemToPeripheral, Size: DMASize8})
+		spi.Bus.CR2.SetBits(stm32.SPI_CR2_TXDMAEN)
+	} else {
+		spi.Bus.CR2.ClearBits(stm32.SPI_CR2_TXDMAEN)
+	}
+}
+
+// Return the DMA channel set with SetTxDMA, or nil.
+func (spi SPI) txDMA() *DMAChannel {
+	for i := range spiTxDMA {
+		if spiTxDMA[i].bus == spi.Bus {
+			return spiTxDMA[i].ch
+		}
+	}
+	return nil
+}
+
+// Tx handles read/write operation for SPI interface. See spi_tx.go for the
+// different ways to call it. Writes without reads use DMA if a channel has
+// been set with SetTxDMA.
+func (spi SPI) Tx(w, r []byte) error {
+	var err error
+
+	switch {
+	case w == nil:
+		// read only, so write zero and read a result.
+		for i := range r {
+			r[i], err = spi.Transfer(0)
+			if err != nil {
+				return err
+			}
+		}
+	case r == nil:
+		// write only
+		if ch := spi.txDMA(); ch != nil && len(w) != 0 && spi.txWithDMA(ch, w) == nil {
+			return nil
+		}
+		for _, b := range w {
+			_, err = spi.Transfer(b)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		// write/read
+		if len(w) != len(r) {
+			return ErrTxInvalidSliceSize
+		}
+
+		for i, b := range w {
+			r[i], err = spi.Transfer(b)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// State of the transactions started with TxAsync, by DMA stream.
+var spiAsync [len(dmaChannelList)]struct {
+	bus        *stm32.SPI_Type
+	completion Completion
+}
+
+// TxAsync starts a transaction like Tx and returns immediately. Writes without
+// reads are done with the DMA channel set with SetTxDMA while other goroutines
+// run, and the Completion is used to wait for their end. Other transactions
+// are done synchronously, before TxAsync returns.
+//
+// Only one transaction can be in progress on a bus: ErrDMABusy is returned if
+// the previous one hasn't completed yet.
+func (spi SPI) TxAsync(w, r []byte) (*Completion, error) {
+	ch := spi.txDMA()
+	if ch == nil || r != nil || len(w) == 0 {
+		err := spi.Tx(w, r)
+		if err != nil {
+			return nil, err
+		}
+		return &completed, nil
+	}
+
+	state := &spiAsync[ch.controller*8+ch.stream]
+	if !state.completion.Done() {
+		return nil, ErrDMABusy
+	}
+	state.bus = spi.Bus
+	state.completion.start()
+	ch.SetCallback(spiHandleDMA)
+	err := ch.Start(unsafe.Pointer(&spi.Bus.DR), unsafe.Pointer(&w[0]), len(w))
+	if err != nil {
+		state.completion.complete(err)
+		return nil, err
+	}
+	return &state.completion, nil
+}
+
+// spiHandleDMA is called from the DMA interrupt when the data of a write
+// started with TxAsync has been copied to the SPI peripheral.
+func spiHandleDMA(ch *DMAChannel) {
+	state := &spiAsync[ch.controller*8+ch.stream]
+	ch.SetCallback(nil)
+
+	// Wait for the last byte to be shifted out, which only takes a few bit
+	// times, then clear the overrun flag caused by not reading the received
+	// data.
+	for !state.bus.SR.HasBits(stm32.SPI_SR_TXE) {
+	}
+	for state.bus.SR.HasBits(stm32.SPI_SR_BSY) {
+	}
+	state.bus.DR.Get()
+	state.bus.SR.Get()
+	state.completion.complete(nil)
+}
+
+func (spi SPI) txWithDMA(ch *DMAChannel, w []byte) error {
+	err := ch.Start(unsafe.Pointer(&spi.Bus.DR), unsafe.Pointer(&w[0]), len(w))
+	if err != nil {
+		return err
+	}
+	ch.Wait()
+
+	// Wait for the last byte to be shifted out, then clear the overrun flag
+	// caused by not reading the received data.
+	for !spi.Bus.SR.HasBits(stm32.SPI_SR_TXE) {
+	}
+	for spi.Bus.SR.HasBits(stm32.SPI_SR_BSY) {
+	}
+	spi.Bus.DR.Get()
+	spi.Bus.SR.Get()
+	return nil
+}
+
 func (spi SPI) config8Bits() {
 	// no-op on this series
 }
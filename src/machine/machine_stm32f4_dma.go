@@ -0,0 +1,322 @@
+//go:build stm32f4
+
+package machine
+
+import (
+	"device/stm32"
+	"internal/task"
+	"runtime/interrupt"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// DMA controller registers. See chapter 10.5 of RM0090 and stm32.DMA_Type.
+type stm32DMA struct {
+	LISR   volatile.Register32
+	HISR   volatile.Register32
+	LIFCR  volatile.Register32
+	HIFCR  volatile.Register32
+	STREAM [8]stm32DMAStream
+}
+
+type stm32DMAStream struct {
+	CR   volatile.Register32
+	NDTR volatile.Register32
+	PAR  volatile.Register32
+	M0AR volatile.Register32
+	M1AR volatile.Register32
+	FCR  volatile.Register32
+}
+
+const (
+	dmaCR_EN        = 1 << 0
+	dmaCR_TCIE      = 1 << 4
+	dmaCR_DIR_Pos   = 6
+	dmaCR_PINC      = 1 << 9
+	dmaCR_MINC      = 1 << 10
+	dmaCR_PSIZE_Pos = 11
+	dmaCR_MSIZE_Pos = 13
+	dmaCR_CHSEL_Pos = 25
+
+	dmaFCR_DMDIS    = 1 << 2
+	dmaFCR_FTH_FULL = 3
+
+	dmaISR_TCIF = 1 << 5
+	dmaISR_ALL  = 0x3d // all flags of a stream
+)
+
+var dmaControllers = [2]*stm32DMA{
+	(*stm32DMA)(unsafe.Pointer(stm32.DMA1)),
+	(*stm32DMA)(unsafe.Pointer(stm32.DMA2)),
+}
+
+// DMATrigger is the peripheral request that paces a DMA transfer. On the
+// STM32F4, each request is only connected to one or two streams, with a fixed
+// channel number. See tables 42 and 43 of RM0090.
+//
+// It contains up to two stream candidates of 8 bits each: bit 7 is set for a
+// valid candidate, bit 6 selects DMA2, bits 5:3 are the stream and bits 2:0
+// are the channel.
+type DMATrigger uint16
+
+// Parts of a DMATrigger candidate.
+const (
+	dmaValid = 1 << 7
+	dmaDMA2  = 1 << 6
+)
+
+const (
+	// Memory to memory transfers can use any stream of DMA2.
+	DMATriggerMemory DMATrigger = 0
+
+	DMATriggerSPI1TX   DMATrigger = dmaValid | dmaDMA2 | 3<<3 | 3 | (dmaValid|dmaDMA2|5<<3|3)<<8
+	DMATriggerSPI1RX   DMATrigger = dmaValid | dmaDMA2 | 0<<3 | 3 | (dmaValid|dmaDMA2|2<<3|3)<<8
+	DMATriggerSPI2TX   DMATrigger = dmaValid | 4<<3 | 0
+	DMATriggerSPI2RX   DMATrigger = dmaValid | 3<<3 | 0
+	DMATriggerSPI3TX   DMATrigger = dmaValid | 5<<3 | 0 | (dmaValid|7<<3|0)<<8
+	DMATriggerSPI3RX   DMATrigger = dmaValid | 0<<3 | 0 | (dmaValid|2<<3|0)<<8
+	DMATriggerUSART1TX DMATrigger = dmaValid | dmaDMA2 | 7<<3 | 4
+	DMATriggerUSART1RX DMATrigger = dmaValid | dmaDMA2 | 2<<3 | 4 | (dmaValid|dmaDMA2|5<<3|4)<<8
+	DMATriggerUSART2TX DMATrigger = dmaValid | 6<<3 | 4
+	DMATriggerUSART2RX DMATrigger = dmaValid | 5<<3 | 4
+	DMATriggerUSART6TX DMATrigger = dmaValid | dmaDMA2 | 6<<3 | 5 | (dmaValid|dmaDMA2|7<<3|5)<<8
+	DMATriggerUSART6RX DMATrigger = dmaValid | dmaDMA2 | 1<<3 | 5 | (dmaValid|dmaDMA2|2<<3|5)<<8
+	DMATriggerI2C1TX   DMATrigger = dmaValid | 6<<3 | 1 | (dmaValid|7<<3|1)<<8
+	DMATriggerI2C1RX   DMATrigger = dmaValid | 0<<3 | 1 | (dmaValid|5<<3|1)<<8
+	DMATriggerADC1     DMATrigger = dmaValid | dmaDMA2 | 0<<3 | 0 | (dmaValid|dmaDMA2|4<<3|0)<<8
+)
+
+// DMAChannel is a stream of one of the two DMA controllers of the STM32F4,
+// set to the channel of a peripheral request.
+type DMAChannel struct {
+	controller uint8 // 0 for DMA1, 1 for DMA2
+	stream     uint8
+	cr         uint32
+	callback   func(ch *DMAChannel)
+	chain      *DMAChannel
+	waiter     *task.Task
+}
+
+var (
+	dmaChannelList     [16]DMAChannel // DMA1 streams followed by DMA2 streams
+	dmaClaimed         uint16
+	dmaInterruptActive uint16
+)
+
+// ClaimDMAChannel returns a DMA stream that hasn't been claimed yet and that
+// is connected to the given trigger.
+func ClaimDMAChannel(trigger DMATrigger) (*DMAChannel, error) {
+	if trigger == DMATriggerMemory {
+		for stream := uint8(0); stream < 8; stream++ {
+			if ch := claimDMAStream(1, stream, 0); ch != nil {
+				return ch, nil
+			}
+		}
+		return nil, ErrNoDMAChannel
+	}
+	for ; trigger != 0; trigger >>= 8 {
+		candidate := uint8(trigger)
+		if candidate&dmaValid == 0 {
+			continue
+		}
+		if ch := claimDMAStream(candidate>>6&1, candidate>>3&7, candidate&7); ch != nil {
+			return ch, nil
+		}
+	}
+	return nil, ErrNoDMAChannel
+}
+
+func claimDMAStream(controller, stream, channel uint8) *DMAChannel {
+	index := controller*8 + stream
+	if dmaClaimed&(1<<index) != 0 {
+		return nil
+	}
+	dmaClaimed |= 1 << index
+	if controller == 0 {
+		stm32.RCC.AHB1ENR.SetBits(stm32.RCC_AHB1ENR_DMA1EN)
+	} else {
+		stm32.RCC.AHB1ENR.SetBits(stm32.RCC_AHB1ENR_DMA2EN)
+	}
+	ch := &dmaChannelList[index]
+	*ch = DMAChannel{controller: controller, stream: stream, cr: uint32(channel) << dmaCR_CHSEL_Pos}
+	ch.Configure(DMAConfig{})
+	if dmaInterruptActive&(1<<index) == 0 {
+		dmaInterruptActive |= 1 << index
+		dmaInterruptFor(index).Enable()
+	}
+	return ch
+}
+
+// Release aborts any transfer in progress and returns the channel, so that it
+// can be claimed again.
+func (ch *DMAChannel) Release() {
+	ch.Abort()
+	ch.SetCallback(nil)
+	ch.ChainTo(nil)
+	dmaClaimed &^= 1 << (ch.controller*8 + ch.stream)
+}
+
+func (ch *DMAChannel) regs() *stm32DMAStream {
+	return &dmaControllers[ch.controller].STREAM[ch.stream]
+}
+
+// Configure sets the direction and transfer size for the following transfers.
+func (ch *DMAChannel) Configure(config DMAConfig) {
+	cr := ch.cr & (7 << dmaCR_CHSEL_Pos)
+	cr |= uint32(config.Size)<<dmaCR_PSIZE_Pos | uint32(config.Size)<<dmaCR_MSIZE_Pos
+	switch config.Direction {
+	case DMAMemToMem:
+		// The peripheral port is the source.
+		cr |= 2<<dmaCR_DIR_Pos | dmaCR_MINC
+		if !config.FixedMemory {
+			cr |= dmaCR_PINC
+		}
+	case DMAMemToPeripheral:
+		cr |= 1 << dmaCR_DIR_Pos
+		if !config.FixedMemory {
+			cr |= dmaCR_MINC
+		}
+	case DMAPeripheralToMem:
+		if !config.FixedMemory {
+			cr |= dmaCR_MINC
+		}
+	}
+	// The transfer complete interrupt is used by the chain, the callback and
+	// Wait.
+	cr |= dmaCR_TCIE
+	ch.cr = cr
+}
+
+// Prepare sets up a transfer of count units from src to dst, without starting
+// it. It is started by Trigger, or by the end of the transfer of a channel
+// that is chained to this one.
+func (ch *DMAChannel) Prepare(dst, src unsafe.Pointer, count int) error {
+	if count <= 0 || count > 0xffff {
+		return ErrDMATransferSize
+	}
+	if ch.Busy() {
+		return ErrDMABusy
+	}
+	regs := ch.regs()
+	peripheral, memory := src, dst
+	if (ch.cr>>dmaCR_DIR_Pos)&3 == 1 {
+		peripheral, memory = dst, src
+	}
+	regs.PAR.Set(uint32(uintptr(peripheral)))
+	regs.M0AR.Set(uint32(uintptr(memory)))
+	regs.NDTR.Set(uint32(count))
+	if (ch.cr>>dmaCR_DIR_Pos)&3 == 2 {
+		// Memory to memory transfers must use the FIFO.
+		regs.FCR.Set(dmaFCR_DMDIS | dmaFCR_FTH_FULL)
+	} else {
+		regs.FCR.Set(0)
+	}
+	regs.CR.Set(ch.cr)
+	return nil
+}
+
+// Trigger starts the transfer set up with Prepare.
+func (ch *DMAChannel) Trigger() {
+	ch.clearFlags()
+	ch.regs().CR.SetBits(dmaCR_EN)
+}
+
+// Busy returns whether a transfer is in progress. The stream is disabled by
+// hardware at the end of the transfer.
+func (ch *DMAChannel) Busy() bool {
+	return ch.regs().CR.HasBits(dmaCR_EN)
+}
+
+// Abort stops the transfer in progress, if any.
+func (ch *DMAChannel) Abort() {
+	regs := ch.regs()
+	regs.CR.ClearBits(dmaCR_EN)
+	for regs.CR.HasBits(dmaCR_EN) {
+	}
+	ch.clearFlags()
+}
+
+// Position of the interrupt flags of this stream in LISR/HISR.
+func (ch *DMAChannel) flagShift() uint32 {
+	return [4]uint32{0, 6, 16, 22}[ch.stream&3]
+}
+
+func (ch *DMAChannel) clearFlags() {
+	dma := dmaControllers[ch.controller]
+	if ch.stream < 4 {
+		dma.LIFCR.Set(dmaISR_ALL << ch.flagShift())
+	} else {
+		dma.HIFCR.Set(dmaISR_ALL << ch.flagShift())
+	}
+}
+
+// ChainTo starts the transfer prepared on next as soon as a transfer on this
+// channel has finished. This is done from the DMA interrupt. Passing nil
+// removes the chain.
+func (ch *DMAChannel) ChainTo(next *DMAChannel) {
+	ch.chain = next
+}
+
+// SetCallback sets a function that is called from an interrupt whenever a
+// transfer on this channel has finished. Passing nil removes the callback.
+func (ch *DMAChannel) SetCallback(callback func(ch *DMAChannel)) {
+	ch.callback = callback
+}
+
+// Return the interrupt of a stream, with DMA2 streams starting at 8.
+func dmaInterruptFor(index uint8) interrupt.Interrupt {
+	switch index {
+	case 0:
+		return interrupt.New(stm32.IRQ_DMA1_Stream0, func(interrupt.Interrupt) { dmaHandleInterrupt(0) })
+	case 1:
+		return interrupt.New(stm32.IRQ_DMA1_Stream1, func(interrupt.Interrupt) { dmaHandleInterrupt(1) })
+	case 2:
+		return interrupt.New(stm32.IRQ_DMA1_Stream2, func(interrupt.Interrupt) { dmaHandleInterrupt(2) })
+	case 3:
+		return interrupt.New(stm32.IRQ_DMA1_Stream3, func(interrupt.Interrupt) { dmaHandleInterrupt(3) })
+	case 4:
+		return interrupt.New(stm32.IRQ_DMA1_Stream4, func(interrupt.Interrupt) { dmaHandleInterrupt(4) })
+	case 5:
+		return interrupt.New(stm32.IRQ_DMA1_Stream5, func(interrupt.Interrupt) { dmaHandleInterrupt(5) })
+	case 6:
+		return interrupt.New(stm32.IRQ_DMA1_Stream6, func(interrupt.Interrupt) { dmaHandleInterrupt(6) })
+	case 7:
+		return interrupt.New(stm32.IRQ_DMA1_Stream7, func(interrupt.Interrupt) { dmaHandleInterrupt(7) })
+	case 8:
+		return interrupt.New(stm32.IRQ_DMA2_Stream0, func(interrupt.Interrupt) { dmaHandleInterrupt(8) })
+	case 9:
+		return interrupt.New(stm32.IRQ_DMA2_Stream1, func(interrupt.Interrupt) { dmaHandleInterrupt(9) })
+	case 10:
+		return interrupt.New(stm32.IRQ_DMA2_Stream2, func(interrupt.Interrupt) { dmaHandleInterrupt(10) })
+	case 11:
+		return interrupt.New(stm32.IRQ_DMA2_Stream3, func(interrupt.Interrupt) { dmaHandleInterrupt(11) })
+	case 12:
+		return interrupt.New(stm32.IRQ_DMA2_Stream4, func(interrupt.Interrupt) { dmaHandleInterrupt(12) })
+	case 13:
+		return interrupt.New(stm32.IRQ_DMA2_Stream5, func(interrupt.Interrupt) { dmaHandleInterrupt(13) })
+	case 14:
+		return interrupt.New(stm32.IRQ_DMA2_Stream6, func(interrupt.Interrupt) { dmaHandleInterrupt(14) })
+	default:
+		return interrupt.New(stm32.IRQ_DMA2_Stream7, func(interrupt.Interrupt) { dmaHandleInterrupt(15) })
+	}
+}
+
+func dmaHandleInterrupt(index uint8) {
+	ch := &dmaChannelList[index]
+	dma := dmaControllers[ch.controller]
+	status := dma.LISR.Get()
+	if ch.stream >= 4 {
+		status = dma.HISR.Get()
+	}
+	if status&(dmaISR_TCIF<<ch.flagShift()) == 0 {
+		return
+	}
+	ch.clearFlags()
+	if ch.chain != nil {
+		ch.chain.Trigger()
+	}
+	if ch.callback != nil {
+		ch.callback(ch)
+	}
+	ch.wake()
+}
@@ -1,6 +1,7 @@
 package machine
 
 import (
+	"internal/task"
 	_ "unsafe"
 )
 
@@ -11,3 +12,9 @@ import (
 
 //go:linkname gosched runtime.Gosched
 func gosched()
+
+//go:linkname pauseTask runtime.pauseTask
+func pauseTask()
+
+//go:linkname resumeTask runtime.resumeTask
+func resumeTask(t *task.Task)
@@ -13,8 +13,47 @@ type UARTConfig struct {
 	RX       Pin
 	RTS      Pin
 	CTS      Pin
+
+	// DataBits, StopBits and Parity set the frame format. The default is 8
+	// data bits, 1 stop bit and no parity (8N1). The UARTs of the RP2040,
+	// SAMD21/51, nRF and STM32 return ErrInvalidUARTFormat from Configure for
+	// formats they don't support. Other chips ignore these fields, DE and
+	// IdleTime.
+	DataBits uint8
+	StopBits uint8
+	Parity   UARTParity
+
+	// DE is the driver enable pin of an RS-485 transceiver, if any. It is
+	// driven high while data is being sent and low otherwise, so that the UART
+	// can share a half-duplex bus. Leave it unset (or set it to NoPin) if
+	// there is no DE pin. Because the zero value means unset, pin 0 can't be
+	// used as the DE pin.
+	DE Pin
+
+	// IdleTime is how long in nanoseconds the RX line must be quiet after
+	// receiving data before the UART reports UARTIdle, such as the 3.5
+	// character times that end a Modbus RTU frame. The default is one
+	// character time.
+	IdleTime uint64
 }
 
+// UARTParity is the parity setting to be used for UART communication.
+type UARTParity uint8
+
+const (
+	// ParityNone means to not use any parity checking. This is
+	// the most common setting.
+	ParityNone UARTParity = iota
+
+	// ParityEven means to expect that the total number of 1 bits sent
+	// should be an even number.
+	ParityEven
+
+	// ParityOdd means to expect that the total number of 1 bits sent
+	// should be an odd number.
+	ParityOdd
+)
+
 // NullSerial is a serial version of /dev/null (or null router): it drops
 // everything that is written to it.
 type NullSerial struct {
@@ -25,5 +25,6 @@ var (
 var _ interface { // 2
 	Configure(config SPIConfig) error
 	Tx(w, r []byte) error
+	TxAsync(w, r []byte) (*Completion, error)
 	Transfer(w byte) (byte, error)
 } = (*SPI)(nil)
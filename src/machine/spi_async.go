@@ -0,0 +1,17 @@
+//go:build !baremetal || atmega || esp32 || fe310 || k210 || nrf || (nxp && !mk66f18) || (sam && !atsamd51 && !atsame5x) || (stm32 && !stm32f4 && !stm32f7x2 && !stm32l5x2)
+
+// This file implements the SPI TxAsync function for targets where SPI
+// transactions are not done with DMA or interrupts (yet).
+
+package machine
+
+// TxAsync performs a transaction like Tx. On this chip, the transaction is
+// done before TxAsync returns, so the returned Completion is always done and
+// errors are returned directly.
+func (spi SPI) TxAsync(w, r []byte) (*Completion, error) {
+	err := spi.Tx(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return &completed, nil
+}
@@ -6,23 +6,6 @@ import "errors"
 
 var errUARTBufferEmpty = errors.New("UART buffer empty")
 
-// UARTParity is the parity setting to be used for UART communication.
-type UARTParity uint8
-
-const (
-	// ParityNone means to not use any parity checking. This is
-	// the most common setting.
-	ParityNone UARTParity = iota
-
-	// ParityEven means to expect that the total number of 1 bits sent
-	// should be an even number.
-	ParityEven
-
-	// ParityOdd means to expect that the total number of 1 bits sent
-	// should be an odd number.
-	ParityOdd
-)
-
 // To implement the UART interface for a board, you must declare a concrete type as follows:
 //
 // 		type UART struct {
@@ -62,6 +45,8 @@ func (uart *UART) Read(data []byte) (n int, err error) {
 // WriteByte writes a byte of data over the UART's Tx.
 // This function blocks until the data is finished being sent.
 func (uart *UART) WriteByte(c byte) error {
+	uart.startTx()
+	defer uart.endTx()
 	err := uart.writeByte(c)
 	if err != nil {
 		return err
@@ -73,6 +58,15 @@ func (uart *UART) WriteByte(c byte) error {
 // Write data over the UART's Tx.
 // This function blocks until the data is finished being sent.
 func (uart *UART) Write(data []byte) (n int, err error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	uart.startTx()
+	defer uart.endTx()
+	if uart.writeDMA(data) {
+		uart.flush()
+		return len(data), nil
+	}
 	for i, v := range data {
 		err = uart.writeByte(v)
 		if err != nil {
@@ -0,0 +1,37 @@
+//go:build rp2040 || (sam && atsamd51) || (sam && atsame5x) || stm32f4
+
+package machine
+
+import "unsafe"
+
+// uartDMA is embedded in the UART type of chips with DMA support.
+type uartDMA struct {
+	txDMA *DMAChannel
+}
+
+// SetTxDMA makes Write send data using the given DMA channel, which must have
+// been claimed with the TX trigger of this UART (for example
+// DMATriggerUART0TX). Other goroutines can run while the data is being sent.
+// Passing nil goes back to sending one byte at a time.
+func (uart *UART) SetTxDMA(ch *DMAChannel) {
+	uart.txDMA = ch
+	if ch != nil {
+		ch.Configure(DMAConfig{Direction: DMAMemToPeripheral, Size: DMASize8})
+	}
+	uart.setTxDMA(ch != nil)
+}
+
+// Send data using the DMA channel set with SetTxDMA, if any. It returns false
+// if the data needs to be sent without DMA.
+func (uart *UART) writeDMA(data []byte) bool {
+	ch := uart.txDMA
+	if ch == nil {
+		return false
+	}
+	err := ch.Start(uart.txDataRegister(), unsafe.Pointer(&data[0]), len(data))
+	if err != nil {
+		return false
+	}
+	ch.Wait()
+	return true
+}
@@ -0,0 +1,128 @@
+//go:build rp2040 || sam || nrf || stm32
+
+package machine
+
+import (
+	"errors"
+	"runtime/interrupt"
+	"runtime/volatile"
+	_ "unsafe" // for go:linkname
+)
+
+var ErrInvalidUARTFormat = errors.New("machine: unsupported UART frame format")
+
+// UARTEvent is a set of line events seen by a UART receiver, as returned by
+// UART.Events.
+type UARTEvent uint8
+
+const (
+	// UARTBreak means that the RX line was held low for longer than a
+	// character.
+	UARTBreak UARTEvent = 1 << iota
+
+	// UARTIdle means that the RX line has been quiet for IdleTime after
+	// receiving data, which ends a frame in protocols like Modbus RTU.
+	UARTIdle
+
+	// UARTError means that a character was received with a parity or framing
+	// error. Such characters are dropped.
+	UARTError
+)
+
+// uartFraming is embedded in the UART type of chips that support frame
+// formats, line events and RS-485 direction control.
+type uartFraming struct {
+	de       Pin   // NoPin if there is no DE pin
+	idleTime int64 // nanoseconds
+	events   volatile.Register8
+	lastRx   int64
+	rxActive bool
+}
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+// configureFraming sets the frame format and the DE pin of the UART from
+// config. It is called by Configure while the UART is not running yet.
+func (uart *UART) configureFraming(config UARTConfig) error {
+	if config.DataBits == 0 {
+		config.DataBits = 8
+	}
+	if config.StopBits == 0 {
+		config.StopBits = 1
+	}
+	if err := uart.SetFormat(config.DataBits, config.StopBits, config.Parity); err != nil {
+		return err
+	}
+
+	// The zero value of UARTConfig.DE means that there is no DE pin.
+	uart.de = config.DE
+	if uart.de == 0 {
+		uart.de = NoPin
+	}
+	if uart.de != NoPin {
+		uart.de.Configure(PinConfig{Mode: PinOutput})
+		uart.de.Low()
+	}
+
+	uart.idleTime = int64(config.IdleTime)
+	if uart.idleTime == 0 && config.BaudRate != 0 {
+		// Start, data, parity and stop bits.
+		bits := 1 + uint32(config.DataBits) + uint32(config.StopBits)
+		if config.Parity != ParityNone {
+			bits++
+		}
+		uart.idleTime = int64(bits) * 1e9 / int64(config.BaudRate)
+	}
+	return nil
+}
+
+// Events returns the line events seen since the last call, and clears them.
+// UARTIdle is only returned once per burst of received data.
+func (uart *UART) Events() UARTEvent {
+	mask := interrupt.Disable()
+	events := UARTEvent(uart.events.Get())
+	uart.events.Set(0)
+	if uart.rxActive && nanotime()-uart.lastRx >= uart.idleTime {
+		uart.rxActive = false
+		events |= UARTIdle
+	}
+	interrupt.Restore(mask)
+	return events
+}
+
+// receiveChar is called by the interrupt handler for every received
+// character. Characters with line events, such as a break, are not added to
+// the RX buffer.
+func (uart *UART) receiveChar(c byte, events UARTEvent) {
+	if events != 0 {
+		uart.receiveEvent(events)
+		return
+	}
+	uart.lastRx = nanotime()
+	uart.rxActive = true
+	uart.Receive(c)
+}
+
+// receiveEvent is called by the interrupt handler for line events.
+func (uart *UART) receiveEvent(events UARTEvent) {
+	uart.lastRx = nanotime()
+	uart.rxActive = true
+	uart.events.SetBits(uint8(events))
+}
+
+// startTx drives the DE pin high before sending data.
+func (uart *UART) startTx() {
+	if uart.de != NoPin {
+		uart.de.High()
+	}
+}
+
+// endTx releases the bus by driving the DE pin low, once the last stop bit
+// has left the shift register.
+func (uart *UART) endTx() {
+	if uart.de != NoPin {
+		uart.waitTxComplete()
+		uart.de.Low()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build (atmega || esp || nrf || sam || sifive || stm32 || k210 || nxp) && !(sam && atsamd51) && !(sam && atsame5x) && !stm32f4
+
+package machine
+
+// uartDMA is embedded in the UART type of chips that share a UART
+// implementation with chips that support DMA.
+type uartDMA struct{}
+
+// This chip doesn't support DMA, so the data is always sent one byte at a
+// time.
+func (uart *UART) writeDMA(data []byte) bool {
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build atmega || esp || sifive || k210 || nxp
+
+package machine
+
+// This chip doesn't support RS-485 direction control, so there is nothing to
+// do around sending data.
+func (uart *UART) startTx() {}
+
+func (uart *UART) endTx() {}
@@ -176,6 +176,13 @@ func sendDescriptor(setup usb.Setup) {
 				strToUTF16LEDescriptor(usbSerial(), b)
 				sendUSBPacket(0, b, setup.WLength)
 			}
+
+		default:
+			if s, ok := usbDescriptor.Strings[setup.WValueL]; ok {
+				b := usb_trans_buffer[:(len(s)<<1)+2]
+				strToUTF16LEDescriptor(s, b)
+				sendUSBPacket(0, b, setup.WLength)
+			}
 		}
 		return
 	case descriptor.TypeHIDReport:
@@ -183,6 +190,11 @@ func sendDescriptor(setup usb.Setup) {
 			sendUSBPacket(0, h, setup.WLength)
 			return
 		}
+	case descriptor.TypeBOS:
+		if usbDescriptor.BOS != nil {
+			sendUSBPacket(0, usbDescriptor.BOS, setup.WLength)
+			return
+		}
 	case descriptor.TypeDeviceQualifier:
 		// skip
 	default:
@@ -272,6 +284,24 @@ func handleStandardSetup(setup usb.Setup) bool {
 	}
 }
 
+// handleVendorSetup handles the vendor requests to the device for the
+// descriptors of the platform capabilities in the BOS descriptor.
+func handleVendorSetup(setup usb.Setup) bool {
+	switch setup.BRequest {
+	case usb.VENDOR_REQUEST_WEBUSB:
+		if setup.WIndex == usb.WEBUSB_GET_URL && setup.WValueL == 1 && usbDescriptor.WebUSBURL != nil {
+			sendUSBPacket(0, usbDescriptor.WebUSBURL, setup.WLength)
+			return true
+		}
+	case usb.VENDOR_REQUEST_MS_OS_20:
+		if setup.WIndex == usb.MS_OS_20_DESCRIPTOR_INDEX && usbDescriptor.MSOS20 != nil {
+			sendUSBPacket(0, usbDescriptor.MSOS20, setup.WLength)
+			return true
+		}
+	}
+	return false
+}
+
 func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.Setup) bool) {
 	if len(usbDescriptor.Device) == 0 {
 		usbDescriptor = descriptor.CDC
@@ -305,6 +335,14 @@ func EnableCDC(txHandler func(), rxHandler func([]byte), setupHandler func(usb.S
 		})
 }
 
+// ConfigureUSB configures the USB device with the descriptor built by b and the
+// handlers registered with b.AddHandlers. It must be called after all
+// functions have been added to b.
+func ConfigureUSB(b *descriptor.Builder) {
+	endpoints, setup := b.Handlers()
+	ConfigureUSBEndpoint(b.Descriptor(), endpoints, setup)
+}
+
 func ConfigureUSBEndpoint(desc descriptor.Descriptor, epSettings []usb.EndpointConfig, setup []usb.SetupConfig) {
 	usbDescriptor = desc
 
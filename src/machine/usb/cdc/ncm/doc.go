@@ -0,0 +1,6 @@
+// package ncm is for USB network adapters, using the CDC Network Control
+// Model (NCM). The device appears as an Ethernet adapter to the host, and the
+// program sends and receives Ethernet frames, for example with a TCP/IP stack.
+//
+// Linux, macOS and recent versions of Windows support NCM without drivers.
+package ncm
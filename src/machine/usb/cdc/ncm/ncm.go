@@ -0,0 +1,294 @@
+//go:build sam || nrf52840 || rp2040
+
+package ncm
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/descriptor"
+	"runtime/interrupt"
+)
+
+const (
+	// Class specific requests.
+	ncmSetEthernetPacketFilter = 0x43
+	ncmGetNTBParameters        = 0x80
+	ncmGetNTBFormat            = 0x83
+	ncmSetNTBFormat            = 0x84
+	ncmGetNTBInputSize         = 0x85
+	ncmSetNTBInputSize         = 0x86
+
+	// Notifications.
+	ncmNetworkConnection     = 0x00
+	ncmConnectionSpeedChange = 0x2a
+
+	// Speed reported to the host, in bits per second.
+	ncmSpeed = 12000000
+)
+
+// Notifications still to be sent.
+const (
+	notifyNone = iota
+	notifySpeed
+	notifyConnection
+)
+
+// NCM is a USB network adapter.
+type NCM struct {
+	iface     uint8 // communication interface
+	notifyEp  uint32
+	inEp      uint32
+	outEp     uint32
+	inMaxSize uint32
+
+	rx ntbQueue
+
+	// NTB being sent to the host.
+	tx       [ntbMaxSize]byte
+	txLen    int
+	txPos    int
+	txZlp    bool
+	txBusy   bool
+	sequence uint16
+
+	// Notifications of the network connection to the host.
+	notified      bool
+	notifyPending bool
+	notifyNext    uint8
+	notification  [16]byte
+
+	setupBuf [4]byte
+}
+
+var port *NCM
+
+// New adds a network adapter to the USB device built by b. mac is the MAC
+// address of the adapter on the host side, the program must use another one
+// for its own network interface. It must only be called once.
+func New(b *descriptor.Builder, mac [6]byte) (*NCM, error) {
+	index, err := b.AddString(macString(mac))
+	if err != nil {
+		return nil, err
+	}
+	f := descriptor.NCMFunction(index)
+	if err := b.Add(f); err != nil {
+		return nil, err
+	}
+	data := f.Interfaces[1].Alternates[0]
+	port = &NCM{
+		iface:     f.FirstInterface,
+		notifyEp:  uint32(f.Interfaces[0].Endpoints[0].Number),
+		outEp:     uint32(data.Endpoints[0].Number),
+		inEp:      uint32(data.Endpoints[1].Number),
+		inMaxSize: ntbMaxSize,
+	}
+	b.AddHandlers(
+		[]usb.EndpointConfig{
+			{
+				Index:     uint8(port.notifyEp),
+				IsIn:      true,
+				Type:      usb.ENDPOINT_TYPE_INTERRUPT,
+				TxHandler: notifyHandler,
+			},
+			{
+				Index:     uint8(port.outEp),
+				IsIn:      false,
+				Type:      usb.ENDPOINT_TYPE_BULK,
+				RxHandler: rxHandler,
+			},
+			{
+				Index:     uint8(port.inEp),
+				IsIn:      true,
+				Type:      usb.ENDPOINT_TYPE_BULK,
+				TxHandler: txHandler,
+			},
+		},
+		[]usb.SetupConfig{
+			{
+				Index:   port.iface,
+				Handler: setupHandler,
+			},
+		})
+	return port, nil
+}
+
+// ReadPacket copies the next Ethernet frame received from the host to b, and
+// returns its length. It returns 0 if no frame has been received. Frames that
+// don't fit in b are dropped with ErrShortBuffer, MaxFrameSize is always
+// enough.
+func (n *NCM) ReadPacket(b []byte) (int, error) {
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	n.connect()
+	return n.rx.next(b)
+}
+
+// WritePacket sends an Ethernet frame to the host. It returns ErrBusy while the
+// previous frame is being sent.
+func (n *NCM) WritePacket(frame []byte) error {
+	if len(frame) > MaxFrameSize || uint32(datagramOffset+len(frame)) > n.inMaxSize {
+		return ErrFrameTooLarge
+	}
+	if !machine.USBDev.InitEndpointComplete {
+		return ErrNotConnected
+	}
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	n.connect()
+	if n.txBusy {
+		return ErrBusy
+	}
+	n.sequence++
+	n.txLen = writeNTB(n.tx[:], n.sequence, frame)
+	n.txPos = 0
+	n.txBusy = true
+	n.sendPacket()
+	return nil
+}
+
+// connect tells the host that the network is connected, once the device has
+// been configured.
+func (n *NCM) connect() {
+	if !n.notified && machine.USBDev.InitEndpointComplete {
+		n.notified = true
+		n.notify()
+	}
+}
+
+// sendPacket sends the next packet of the NTB in tx.
+func (n *NCM) sendPacket() {
+	c := n.txLen - n.txPos
+	if c > packetSize {
+		c = packetSize
+	}
+	machine.SendUSBInPacket(n.inEp, n.tx[n.txPos:n.txPos+c])
+	n.txPos += c
+	// The NTB is shorter than the maximum size, so the host expects a short
+	// packet at its end.
+	n.txZlp = n.txPos == n.txLen && c == packetSize
+}
+
+// notify sends the connection speed and the network connection
+// notifications.
+func (n *NCM) notify() {
+	n.notifyNext = notifySpeed
+	if !n.notifyPending {
+		n.sendNotification()
+	}
+}
+
+// sendNotification sends the next notification on the interrupt endpoint.
+func (n *NCM) sendNotification() {
+	b := n.notification[:]
+	b[0] = usb.REQUEST_DEVICETOHOST_CLASS_INTERFACE
+	b[4], b[5] = n.iface, 0
+	switch n.notifyNext {
+	case notifySpeed:
+		b[1] = ncmConnectionSpeedChange
+		b[2], b[3] = 0, 0
+		b[6], b[7] = 8, 0
+		putLE32(b[8:], ncmSpeed)  // downstream
+		putLE32(b[12:], ncmSpeed) // upstream
+		n.notifyNext = notifyConnection
+	case notifyConnection:
+		b[1] = ncmNetworkConnection
+		b[2], b[3] = 1, 0 // connected
+		b[6], b[7] = 0, 0
+		b = b[:8]
+		n.notifyNext = notifyNone
+	default:
+		n.notifyPending = false
+		return
+	}
+	n.notifyPending = true
+	machine.SendUSBInPacket(n.notifyEp, b)
+}
+
+// from Interrupt In
+func notifyHandler() {
+	port.sendNotification()
+}
+
+// from BulkOut
+func rxHandler(b []byte) {
+	port.rx.receive(b)
+}
+
+// from BulkIn
+func txHandler() {
+	n := port
+	switch {
+	case n.txPos < n.txLen:
+		n.sendPacket()
+	case n.txZlp:
+		n.txZlp = false
+		machine.SendUSBInPacket(n.inEp, n.tx[:0])
+	default:
+		n.txBusy = false
+	}
+}
+
+func setupHandler(setup usb.Setup) bool {
+	n := port
+	switch setup.BmRequestType {
+	case usb.REQUEST_DEVICETOHOST_CLASS_INTERFACE:
+		switch setup.BRequest {
+		case ncmGetNTBParameters:
+			machine.SendUSBInPacket(0, ntbParameters[:])
+			return true
+		case ncmGetNTBFormat:
+			n.setupBuf[0], n.setupBuf[1] = 0, 0 // 16-bit NTBs
+			machine.SendUSBInPacket(0, n.setupBuf[:2])
+			return true
+		case ncmGetNTBInputSize:
+			putLE32(n.setupBuf[:], n.inMaxSize)
+			machine.SendUSBInPacket(0, n.setupBuf[:4])
+			return true
+		}
+	case usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE:
+		switch setup.BRequest {
+		case ncmSetEthernetPacketFilter:
+			// All frames are sent to the host anyway. The host sets the
+			// filter when it starts the network, so this is a good time to
+			// tell it that the network is connected.
+			machine.SendZlp()
+			n.notify()
+			return true
+		case ncmSetNTBFormat:
+			if setup.WValueL != 0 || setup.WValueH != 0 {
+				// Only 16-bit NTBs are supported.
+				return false
+			}
+			machine.SendZlp()
+			return true
+		case ncmSetNTBInputSize:
+			b, err := machine.ReceiveUSBControlPacket()
+			if err != nil {
+				return false
+			}
+			size := le32(b[:])
+			if size < ntbMinInSize {
+				return false
+			}
+			if size > ntbMaxSize {
+				size = ntbMaxSize
+			}
+			n.inMaxSize = size
+			machine.SendZlp()
+			return true
+		}
+	}
+	return false
+}
+
+// macString returns the MAC address string descriptor: 12 hexadecimal
+// digits.
+func macString(mac [6]byte) string {
+	const digits = "0123456789ABCDEF"
+	var s [12]byte
+	for i, b := range mac {
+		s[i*2] = digits[b>>4]
+		s[i*2+1] = digits[b&0xf]
+	}
+	return string(s[:])
+}
@@ -0,0 +1,202 @@
+package ncm
+
+// This file implements the framing of Ethernet frames in NCM transfer blocks
+// (NTBs), see the "Universal Serial Bus Communications Class Subclass
+// Specification for Network Control Model Devices". Only 16-bit NTBs are
+// supported. It doesn't depend on the machine package, so that it can be
+// tested on the host.
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotConnected  = errors.New("ncm: not connected")
+	ErrBusy          = errors.New("ncm: previous frame not sent yet")
+	ErrFrameTooLarge = errors.New("ncm: frame too large")
+	ErrShortBuffer   = errors.New("ncm: buffer too small for frame")
+)
+
+const (
+	// MaxFrameSize is the maximum size of an Ethernet frame, without the frame
+	// check sequence.
+	MaxFrameSize = 1514
+
+	packetSize = 64   // size of the bulk endpoints
+	ntbMaxSize = 2048 // maximum size of NTBs in both directions
+
+	// Smallest NTB input size that the host may set with SetNtbInputSize.
+	ntbMinInSize = 2048
+
+	nthSignature = 0x484d434e // "NCMH"
+	ndpSignature = 0x304d434e // "NCM0", datagrams without CRC
+	nthLen       = 12
+	ndpLen       = 16 // with one datagram pointer and the terminating entry
+
+	// Offset of the datagram in the NTBs sent to the host.
+	datagramOffset = nthLen + ndpLen
+)
+
+// ntbParameters is the response to GET_NTB_PARAMETERS.
+var ntbParameters = [28]byte{
+	28, 0x00, // Length
+	0x01, 0x00, // NtbFormatsSupported: 16-bit NTBs
+	0x00, 0x08, 0x00, 0x00, // NtbInMaxSize
+	0x04, 0x00, // NdpInDivisor
+	0x00, 0x00, // NdpInPayloadRemainder
+	0x04, 0x00, // NdpInAlignment
+	0x00, 0x00, // reserved
+	0x00, 0x08, 0x00, 0x00, // NtbOutMaxSize
+	0x04, 0x00, // NdpOutDivisor
+	0x00, 0x00, // NdpOutPayloadRemainder
+	0x04, 0x00, // NdpOutAlignment
+	0x00, 0x00, // NtbOutMaxDatagrams: no limit
+}
+
+// writeNTB writes an NTB with a single frame to b, and returns its length.
+func writeNTB(b []byte, sequence uint16, frame []byte) int {
+	length := datagramOffset + len(frame)
+
+	// NTB header
+	putLE32(b[0:], nthSignature)
+	putLE16(b[4:], nthLen)
+	putLE16(b[6:], sequence)
+	putLE16(b[8:], uint16(length))
+	putLE16(b[10:], nthLen) // index of the NDP
+
+	// NTB datagram pointer table
+	putLE32(b[12:], ndpSignature)
+	putLE16(b[16:], ndpLen)
+	putLE16(b[18:], 0) // no next NDP
+	putLE16(b[20:], datagramOffset)
+	putLE16(b[22:], uint16(len(frame)))
+	putLE32(b[24:], 0)
+
+	copy(b[datagramOffset:], frame)
+	return length
+}
+
+// ntbQueue collects the NTBs received from the host in bulk OUT packets, and
+// returns their frames. NTBs that arrive while the queue is full are dropped.
+type ntbQueue struct {
+	ntbs    [2][ntbMaxSize]byte
+	lengths [2]int
+	first   int // NTB with the next frame
+	count   int // number of complete NTBs
+
+	// NTB being received.
+	received    int
+	blockLength int
+
+	// Position in the first NTB: the datagram pointer table, and its next
+	// entry. entry is 0 before the first frame of the NTB is read.
+	ndp   int
+	entry int
+}
+
+// receive adds a bulk OUT packet to the NTB being received.
+func (q *ntbQueue) receive(p []byte) {
+	if q.received == 0 {
+		if len(p) < nthLen || le32(p) != nthSignature {
+			// Not the start of an NTB, so it is ignored.
+			return
+		}
+		q.blockLength = int(le16(p[8:]))
+	}
+
+	var ntb []byte
+	if q.count < len(q.ntbs) {
+		ntb = q.ntbs[(q.first+q.count)%len(q.ntbs)][:]
+	}
+	if q.received+len(p) <= len(ntb) {
+		copy(ntb[q.received:], p)
+	}
+	q.received += len(p)
+
+	// NTBs end with a short packet, unless the host knows their length.
+	if len(p) == packetSize && q.received < q.blockLength {
+		return
+	}
+	if ntb != nil && q.received <= len(ntb) && q.blockLength <= q.received && q.blockLength >= nthLen {
+		q.lengths[(q.first+q.count)%len(q.ntbs)] = q.blockLength
+		q.count++
+	}
+	q.received = 0
+}
+
+// next copies the next frame to b, and returns its length. It returns 0 when
+// there are no frames, and ErrShortBuffer (skipping the frame) when the frame
+// doesn't fit.
+func (q *ntbQueue) next(b []byte) (int, error) {
+	for q.count != 0 {
+		frame := q.datagram(q.ntbs[q.first][:q.lengths[q.first]])
+		if frame == nil {
+			// No more frames in this NTB.
+			q.first = (q.first + 1) % len(q.ntbs)
+			q.count--
+			q.entry = 0
+			continue
+		}
+		if len(frame) > len(b) {
+			return 0, ErrShortBuffer
+		}
+		return copy(b, frame), nil
+	}
+	return 0, nil
+}
+
+// datagram returns the next datagram of ntb, or nil when there are no more.
+// Invalid datagram pointers end the NTB.
+func (q *ntbQueue) datagram(ntb []byte) []byte {
+	if q.entry == 0 {
+		q.ndp = int(le16(ntb[10:]))
+		q.entry = q.ndp + 8
+	}
+	for {
+		if q.ndp < nthLen || q.ndp+ndpLen > len(ntb) || le32(ntb[q.ndp:]) != ndpSignature {
+			return nil
+		}
+		end := q.ndp + int(le16(ntb[q.ndp+4:]))
+		if end > len(ntb) || q.entry+4 > end {
+			return nil
+		}
+		index := int(le16(ntb[q.entry:]))
+		length := int(le16(ntb[q.entry+2:]))
+		q.entry += 4
+		if index != 0 && length != 0 {
+			if index < nthLen || index+length > len(ntb) {
+				return nil
+			}
+			return ntb[index : index+length]
+		}
+
+		// End of this table, continue with the next one. Tables must come
+		// after each other, so that a loop can't be made.
+		next := int(le16(ntb[q.ndp+6:]))
+		if next <= q.ndp {
+			return nil
+		}
+		q.ndp = next
+		q.entry = next + 8
+	}
+}
+
+func le16(b []byte) uint16 {
+	return uint16(b[0]) | uint16(b[1])<<8
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE16(b []byte, v uint16) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
@@ -0,0 +1,177 @@
+package ncm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// frame returns a test frame of n bytes.
+func frame(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = seed + byte(i)
+	}
+	return b
+}
+
+// ntb returns an NTB with the frames, in one table, like Linux sends them.
+func ntb(frames ...[]byte) []byte {
+	ndpLength := 8 + 4*(len(frames)+1)
+	b := make([]byte, nthLen+ndpLength)
+	putLE32(b[0:], nthSignature)
+	putLE16(b[4:], nthLen)
+	putLE16(b[10:], nthLen)
+	putLE32(b[12:], ndpSignature)
+	putLE16(b[16:], uint16(ndpLength))
+	for i, f := range frames {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		putLE16(b[20+4*i:], uint16(len(b)))
+		putLE16(b[22+4*i:], uint16(len(f)))
+		b = append(b, f...)
+	}
+	putLE16(b[8:], uint16(len(b)))
+	return b
+}
+
+// send sends an NTB in bulk OUT packets.
+func send(q *ntbQueue, ntb []byte) {
+	for len(ntb) >= packetSize {
+		q.receive(ntb[:packetSize])
+		ntb = ntb[packetSize:]
+	}
+	q.receive(ntb)
+}
+
+// read returns the frames in q.
+func read(t *testing.T, q *ntbQueue) [][]byte {
+	t.Helper()
+	var frames [][]byte
+	for {
+		b := make([]byte, MaxFrameSize)
+		n, err := q.next(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return frames
+		}
+		frames = append(frames, b[:n])
+	}
+}
+
+func TestWriteNTB(t *testing.T) {
+	f := frame(MaxFrameSize, 3)
+	b := make([]byte, ntbMaxSize)
+	n := writeNTB(b, 7, f)
+	if n != datagramOffset+len(f) || int(le16(b[8:])) != n || le16(b[6:]) != 7 {
+		t.Errorf("unexpected NTB header: %x", b[:nthLen])
+	}
+
+	// The queue reads what was written.
+	var q ntbQueue
+	send(&q, b[:n])
+	frames := read(t, &q)
+	if len(frames) != 1 || !bytes.Equal(frames[0], f) {
+		t.Errorf("read %d frames", len(frames))
+	}
+}
+
+func TestReceive(t *testing.T) {
+	var q ntbQueue
+	f1, f2, f3 := frame(60, 1), frame(1514, 2), frame(42, 3)
+	send(&q, ntb(f1, f2))
+	send(&q, ntb(f3))
+	frames := read(t, &q)
+	if len(frames) != 3 || !bytes.Equal(frames[0], f1) || !bytes.Equal(frames[1], f2) || !bytes.Equal(frames[2], f3) {
+		t.Errorf("read %d frames, or different frames", len(frames))
+	}
+
+	// NTBs padded to a multiple of the packet size don't need a short packet
+	// at the end, and a zero-length packet after them is ignored.
+	b := ntb(f1)
+	for len(b)%packetSize != 0 {
+		b = append(b, 0)
+	}
+	putLE16(b[8:], uint16(len(b)))
+	send(&q, b)
+	if q.count != 1 {
+		t.Errorf("padded NTB not received")
+	}
+	if frames := read(t, &q); len(frames) != 1 || !bytes.Equal(frames[0], f1) {
+		t.Errorf("padded NTB: read %d frames", len(frames))
+	}
+
+	// Frames that don't fit are skipped.
+	send(&q, ntb(f2, f3))
+	if _, err := q.next(make([]byte, 100)); err != ErrShortBuffer {
+		t.Errorf("expected ErrShortBuffer, got %v", err)
+	}
+	if frames := read(t, &q); len(frames) != 1 || !bytes.Equal(frames[0], f3) {
+		t.Errorf("read %d frames after a short buffer", len(frames))
+	}
+}
+
+func TestReceiveFull(t *testing.T) {
+	var q ntbQueue
+	f := frame(100, 0)
+	for i := 0; i < 3; i++ {
+		f[0] = byte(i)
+		send(&q, ntb(f))
+	}
+	frames := read(t, &q)
+	if len(frames) != 2 || frames[0][0] != 0 || frames[1][0] != 1 {
+		t.Errorf("read %d frames, expected the first 2", len(frames))
+	}
+
+	// There is room again.
+	send(&q, ntb(f))
+	if frames := read(t, &q); len(frames) != 1 || frames[0][0] != 2 {
+		t.Errorf("read %d frames after the queue was full", len(frames))
+	}
+}
+
+func TestReceiveInvalid(t *testing.T) {
+	var q ntbQueue
+	f := frame(100, 0)
+
+	// Packets that aren't the start of an NTB are ignored.
+	q.receive(f[:10])
+	q.receive(f[:packetSize])
+
+	// An NTB that ends early is dropped.
+	b := ntb(f)
+	send(&q, b[:len(b)-1])
+
+	// An NTB that is too large is dropped.
+	b = ntb(frame(MaxFrameSize, 0), frame(MaxFrameSize, 0))
+	send(&q, b)
+	if q.count != 0 {
+		t.Errorf("%d invalid NTBs received", q.count)
+	}
+
+	// Datagrams outside of the NTB end it.
+	b = ntb(f, f)
+	putLE16(b[20:], uint16(len(b)))
+	send(&q, b)
+	if frames := read(t, &q); len(frames) != 0 {
+		t.Errorf("read %d frames from an invalid NTB", len(frames))
+	}
+
+	// So does a table that points to itself.
+	b = ntb(f)
+	putLE16(b[18:], nthLen)
+	putLE16(b[20:], 0)
+	putLE16(b[22:], 0)
+	send(&q, b)
+	if frames := read(t, &q); len(frames) != 0 {
+		t.Errorf("read %d frames from an invalid NTB", len(frames))
+	}
+
+	// The queue still works.
+	send(&q, ntb(f))
+	if frames := read(t, &q); len(frames) != 1 || !bytes.Equal(frames[0], f) {
+		t.Errorf("read %d frames after invalid NTBs", len(frames))
+	}
+}
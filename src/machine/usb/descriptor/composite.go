@@ -0,0 +1,398 @@
+package descriptor
+
+import (
+	"errors"
+	"machine/usb"
+)
+
+var (
+	ErrTooManyInterfaces = errors.New("usb: too many interfaces")
+	ErrTooManyEndpoints  = errors.New("usb: too many endpoints")
+	ErrEndpointInUse     = errors.New("usb: endpoint already in use")
+	ErrStringTooLong     = errors.New("usb: string descriptor too long")
+)
+
+// Longest string that fits in a string descriptor, which has a 2-byte header
+// and 2 bytes per character, and is sent from a 255-byte buffer.
+const maxStringLength = 126
+
+// Function is a class driver of a composite device, such as a serial port or a
+// MIDI port. Its interfaces are grouped with an interface association
+// descriptor when there is more than one.
+type Function struct {
+	// Class, SubClass and Protocol of the interface association descriptor.
+	Class    uint8
+	SubClass uint8
+	Protocol uint8
+
+	Interfaces []Interface
+
+	// WinUSBGUID makes Windows use the WinUSB driver for the function, with
+	// this device interface GUID, such as
+	// "{01234567-89ab-cdef-0123-456789abcdef}". Programs (and browsers, with
+	// WebUSB) can then use the function without installing a driver.
+	WinUSBGUID string
+
+	// FirstInterface is the number of the first interface, set by
+	// Builder.Add. The other interfaces are numbered consecutively.
+	FirstInterface uint8
+}
+
+// Interface is an interface of a Function.
+type Interface struct {
+	Class    uint8
+	SubClass uint8
+	Protocol uint8
+
+	// ClassSpecific descriptors follow the interface descriptor. Bytes at the
+	// offsets in InterfaceRefs are interface numbers relative to the first
+	// interface of the function: FirstInterface is added to them in the
+	// configuration descriptor.
+	ClassSpecific []byte
+	InterfaceRefs []int
+
+	// HIDReport is the report descriptor of a HID interface. The HID
+	// descriptor that refers to it is added after ClassSpecific.
+	HIDReport []byte
+
+	Endpoints []*Endpoint
+
+	// Alternates are the alternate settings 1, 2, etc. of the interface, with
+	// their own endpoints.
+	Alternates []Interface
+}
+
+// Endpoint is an endpoint of an Interface.
+type Endpoint struct {
+	// Number is the endpoint number. Builder.Add allocates a free number when
+	// it is 0.
+	Number uint8
+
+	In            bool
+	Type          uint8 // usb.ENDPOINT_TYPE_BULK, etc.
+	MaxPacketSize uint16
+	Interval      uint8
+
+	// Audio endpoints have the refresh and synch address fields (both zero)
+	// of the USB Audio 1.0 specification.
+	Audio bool
+
+	// ClassSpecific descriptors follow the endpoint descriptor.
+	ClassSpecific []byte
+}
+
+// Builder assembles the descriptor of a composite device from its functions,
+// allocating their interface and endpoint numbers. The zero value is an empty
+// device.
+//
+// The drivers of the functions register their endpoint and setup handlers
+// with AddHandlers. Once all functions have been added, machine.ConfigureUSB
+// configures the device with the descriptor and these handlers.
+type Builder struct {
+	functions  []*Function
+	interfaces uint8
+	endpoints  uint32 // bitmap of the endpoint numbers in use
+	strings    []string
+
+	webUSB      bool
+	landingPage string
+
+	endpointConfigs []usb.EndpointConfig
+	setupConfigs    []usb.SetupConfig
+}
+
+// Add adds f to the device, after the functions added before. It sets the
+// interface numbers and the endpoint numbers that are 0, which the driver of
+// f needs to register its endpoint and setup handlers with AddHandlers.
+//
+// The drivers in machine/usb use fixed interface and endpoint numbers, see
+// CDCFunction and MIDIFunction.
+func (b *Builder) Add(f *Function) error {
+	if int(b.interfaces)+len(f.Interfaces) > usb.NumberOfInterfaces {
+		return ErrTooManyInterfaces
+	}
+
+	// Check the fixed endpoint numbers first, so that allocated numbers
+	// don't take them.
+	endpoints := f.endpoints()
+	used := b.endpoints
+	for _, ep := range endpoints {
+		if ep.Number == 0 {
+			continue
+		}
+		if ep.Number >= usb.NumberOfEndpoints {
+			return ErrTooManyEndpoints
+		}
+		if used&(1<<ep.Number) != 0 {
+			return ErrEndpointInUse
+		}
+		used |= 1 << ep.Number
+	}
+	var numbers []uint8
+	for _, ep := range endpoints {
+		if ep.Number != 0 {
+			continue
+		}
+		n := uint8(1)
+		for n < usb.NumberOfEndpoints && used&(1<<n) != 0 {
+			n++
+		}
+		if n == usb.NumberOfEndpoints {
+			return ErrTooManyEndpoints
+		}
+		used |= 1 << n
+		numbers = append(numbers, n)
+	}
+
+	for _, ep := range endpoints {
+		if ep.Number == 0 {
+			ep.Number, numbers = numbers[0], numbers[1:]
+		}
+	}
+	f.FirstInterface = b.interfaces
+	b.interfaces += uint8(len(f.Interfaces))
+	b.endpoints = used
+	b.functions = append(b.functions, f)
+	return nil
+}
+
+// endpoints returns the endpoints of all interfaces and alternate settings of
+// f.
+func (f *Function) endpoints() []*Endpoint {
+	var endpoints []*Endpoint
+	for _, intf := range f.Interfaces {
+		endpoints = append(endpoints, intf.Endpoints...)
+		for _, alt := range intf.Alternates {
+			endpoints = append(endpoints, alt.Endpoints...)
+		}
+	}
+	return endpoints
+}
+
+// AddString adds a string descriptor to the device, and returns its index, for
+// use in class-specific descriptors. s must be at most 126 bytes long.
+func (b *Builder) AddString(s string) (uint8, error) {
+	if len(s) > maxStringLength {
+		return 0, ErrStringTooLong
+	}
+	b.strings = append(b.strings, s)
+	return usb.ISERIAL + uint8(len(b.strings)), nil
+}
+
+// AddHandlers registers the endpoint and setup handlers of a function added to
+// the device, for machine.ConfigureUSB.
+func (b *Builder) AddHandlers(endpoints []usb.EndpointConfig, setup []usb.SetupConfig) {
+	b.endpointConfigs = append(b.endpointConfigs, endpoints...)
+	b.setupConfigs = append(b.setupConfigs, setup...)
+}
+
+// Handlers returns the endpoint and setup handlers registered with
+// AddHandlers.
+func (b *Builder) Handlers() ([]usb.EndpointConfig, []usb.SetupConfig) {
+	return b.endpointConfigs, b.setupConfigs
+}
+
+// Descriptor returns the descriptor of the device, for
+// machine.ConfigureUSBEndpoint.
+func (b *Builder) Descriptor() Descriptor {
+	device := make([]byte, deviceTypeLen)
+	copy(device, deviceCDC[:])
+
+	conf := make([]byte, configurationTypeLen, 256)
+	copy(conf, configurationCDC[:])
+	ConfigurationType{conf}.NumInterfaces(b.interfaces)
+
+	d := Descriptor{
+		Device: device,
+	}
+	for _, f := range b.functions {
+		if len(f.Interfaces) > 1 {
+			iad := InterfaceAssociationType{grow(&conf, interfaceAssociationTypeLen)}
+			iad.Length(interfaceAssociationTypeLen)
+			iad.Type(TypeInterfaceAssociation)
+			iad.FirstInterface(f.FirstInterface)
+			iad.InterfaceCount(uint8(len(f.Interfaces)))
+			iad.FunctionClass(f.Class)
+			iad.FunctionSubClass(f.SubClass)
+			iad.FunctionProtocol(f.Protocol)
+		}
+
+		for i := range f.Interfaces {
+			intf := &f.Interfaces[i]
+			number := f.FirstInterface + uint8(i)
+			appendInterface(&conf, &d, f, intf, number, 0)
+			for j := range intf.Alternates {
+				appendInterface(&conf, &d, f, &intf.Alternates[j], number, uint8(j+1))
+			}
+		}
+	}
+
+	if len(b.strings) != 0 {
+		d.Strings = make(map[uint8]string)
+		for i, s := range b.strings {
+			d.Strings[usb.ISERIAL+1+uint8(i)] = s
+		}
+	}
+
+	ConfigurationType{conf}.TotalLength(uint16(len(conf)))
+	d.Configuration = conf
+	b.bos(&d)
+	return d
+}
+
+// appendInterface appends the descriptors of an interface of f, or of one of
+// its alternate settings, to conf.
+func appendInterface(conf *[]byte, d *Descriptor, f *Function, intf *Interface, number, alternate uint8) {
+	desc := InterfaceType{grow(conf, interfaceTypeLen)}
+	desc.Length(interfaceTypeLen)
+	desc.Type(TypeInterface)
+	desc.InterfaceNumber(number)
+	desc.AlternateSetting(alternate)
+	desc.NumEndpoints(uint8(len(intf.Endpoints)))
+	desc.InterfaceClass(intf.Class)
+	desc.InterfaceSubClass(intf.SubClass)
+	desc.InterfaceProtocol(intf.Protocol)
+
+	cs := grow(conf, len(intf.ClassSpecific))
+	copy(cs, intf.ClassSpecific)
+	for _, offset := range intf.InterfaceRefs {
+		cs[offset] += f.FirstInterface
+	}
+
+	if intf.HIDReport != nil {
+		hid := ClassHIDType{grow(conf, ClassHIDTypeLen)}
+		copy(hid.Bytes(), classHID[:])
+		hid.ClassLength(uint16(len(intf.HIDReport)))
+		if d.HID == nil {
+			d.HID = make(map[uint16][]byte)
+		}
+		d.HID[uint16(number)] = intf.HIDReport
+	}
+
+	for _, ep := range intf.Endpoints {
+		length := endpointTypeLen
+		if ep.Audio {
+			length = endpointMIDITypeLen
+		}
+		desc := EndpointType{grow(conf, length)}
+		desc.Length(uint8(length))
+		desc.Type(TypeEndpoint)
+		if ep.In {
+			desc.EndpointAddress(ep.Number | usb.EndpointIn)
+		} else {
+			desc.EndpointAddress(ep.Number | usb.EndpointOut)
+		}
+		desc.Attributes(ep.Type)
+		desc.MaxPacketSize(ep.MaxPacketSize)
+		desc.Interval(ep.Interval)
+
+		copy(grow(conf, len(ep.ClassSpecific)), ep.ClassSpecific)
+	}
+}
+
+// grow appends n zero bytes to b, and returns them.
+func grow(b *[]byte, n int) []byte {
+	*b = append(*b, make([]byte, n)...)
+	return (*b)[len(*b)-n:]
+}
+
+// CDCFunction returns the function of the USB serial port (CDC ACM), which is
+// used by machine.USBCDC. It must be added first, because the serial port
+// uses interfaces 0 and 1, and endpoints 1 to 3.
+func CDCFunction() *Function {
+	return &Function{
+		Class:    usb.DEVICE_CLASS_COMMUNICATIONS,
+		SubClass: 0x02, // abstract control model
+		Protocol: 0x01, // AT commands
+		Interfaces: []Interface{
+			{
+				Class:    usb.DEVICE_CLASS_COMMUNICATIONS,
+				SubClass: 0x02,
+				Protocol: 0x01,
+				ClassSpecific: Append([][]byte{
+					ClassSpecificCDCHeader.Bytes(),
+					ClassSpecificCDCCallManagement.Bytes(),
+					ClassSpecificCDCACM.Bytes(),
+					ClassSpecificCDCUnion.Bytes(),
+				}),
+				// Data interface of the call management descriptor, and the
+				// control and data interfaces of the union descriptor.
+				InterfaceRefs: []int{9, 17, 18},
+				Endpoints: []*Endpoint{
+					{
+						Number:        usb.CDC_ENDPOINT_ACM,
+						In:            true,
+						Type:          usb.ENDPOINT_TYPE_INTERRUPT,
+						MaxPacketSize: 0x10,
+						Interval:      0x10,
+					},
+				},
+			},
+			{
+				Class: 0x0a, // data
+				Endpoints: []*Endpoint{
+					{
+						Number:        usb.CDC_ENDPOINT_OUT,
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+					},
+					{
+						Number:        usb.CDC_ENDPOINT_IN,
+						In:            true,
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+					},
+				},
+			},
+		},
+	}
+}
+
+// MIDIFunction returns the function of the USB MIDI port, which is used by
+// machine/usb/adc/midi. It uses endpoints 6 and 7.
+func MIDIFunction() *Function {
+	// The audio control header lists the MIDI streaming interface.
+	audio := Append([][]byte{ClassSpecificAudioInterface.Bytes()})
+	audio[8] = 1
+
+	return &Function{
+		Class:    0x01, // audio
+		SubClass: 0x01, // audio control
+		Interfaces: []Interface{
+			{
+				Class:         0x01,
+				SubClass:      0x01,
+				ClassSpecific: audio,
+				InterfaceRefs: []int{8},
+			},
+			{
+				Class:    0x01,
+				SubClass: 0x03, // MIDI streaming
+				ClassSpecific: Append([][]byte{
+					ClassSpecificMIDIHeader.Bytes(),
+					ClassSpecificMIDIInJack1.Bytes(),
+					ClassSpecificMIDIInJack2.Bytes(),
+					ClassSpecificMIDIOutJack1.Bytes(),
+					ClassSpecificMIDIOutJack2.Bytes(),
+				}),
+				Endpoints: []*Endpoint{
+					{
+						Number:        usb.MIDI_ENDPOINT_OUT,
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+						Audio:         true,
+						ClassSpecific: ClassSpecificMIDIOutEndpoint.Bytes(),
+					},
+					{
+						Number:        usb.MIDI_ENDPOINT_IN,
+						In:            true,
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+						Audio:         true,
+						ClassSpecific: ClassSpecificMIDIInEndpoint.Bytes(),
+					},
+				},
+			},
+		},
+	}
+}
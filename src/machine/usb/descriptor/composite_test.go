@@ -0,0 +1,288 @@
+package descriptor
+
+import (
+	"bytes"
+	"machine/usb"
+	"testing"
+)
+
+func TestBuilderCDC(t *testing.T) {
+	var b Builder
+	if err := b.Add(CDCFunction()); err != nil {
+		t.Fatal(err)
+	}
+	d := b.Descriptor()
+
+	expected := Append([][]byte{CDC.Configuration})
+	ConfigurationType{expected}.TotalLength(uint16(len(expected)))
+	if !bytes.Equal(d.Configuration, expected) {
+		t.Errorf("configuration descriptor:\n%x\nexpected:\n%x", d.Configuration, expected)
+	}
+	if !bytes.Equal(d.Device, CDC.Device) {
+		t.Errorf("device descriptor:\n%x\nexpected:\n%x", d.Device, CDC.Device)
+	}
+}
+
+func TestBuilderMIDI(t *testing.T) {
+	var b Builder
+	cdc := CDCFunction()
+	midi := MIDIFunction()
+	if err := b.Add(cdc); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(midi); err != nil {
+		t.Fatal(err)
+	}
+	if midi.FirstInterface != 2 {
+		t.Errorf("MIDI interfaces start at %d", midi.FirstInterface)
+	}
+	d := b.Descriptor()
+
+	// The CDC part is the same as in TestBuilderCDC, the MIDI part is the
+	// same as in CDCMIDI.
+	cdcLen := len(CDC.Configuration)
+	midiLen := len(CDCMIDI.Configuration) - cdcLen
+	if len(d.Configuration) != cdcLen+midiLen {
+		t.Fatalf("configuration descriptor has length %d, expected %d", len(d.Configuration), cdcLen+midiLen)
+	}
+	if d.Configuration[4] != 4 {
+		t.Errorf("%d interfaces, expected 4", d.Configuration[4])
+	}
+	got := d.Configuration[cdcLen:]
+	expected := CDCMIDI.Configuration[cdcLen:]
+	if !bytes.Equal(got, expected) {
+		t.Errorf("MIDI descriptors:\n%x\nexpected:\n%x", got, expected)
+	}
+}
+
+func TestBuilderAllocation(t *testing.T) {
+	var b Builder
+	if err := b.Add(CDCFunction()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(MIDIFunction()); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &Endpoint{In: true, Type: usb.ENDPOINT_TYPE_BULK, MaxPacketSize: 64}
+	out := &Endpoint{Type: usb.ENDPOINT_TYPE_BULK, MaxPacketSize: 64}
+	vendor := &Function{
+		Interfaces: []Interface{
+			{Class: usb.DEVICE_CLASS_VENDOR_SPECIFIC, Endpoints: []*Endpoint{in, out}},
+		},
+	}
+	if err := b.Add(vendor); err != nil {
+		t.Fatal(err)
+	}
+	if vendor.FirstInterface != 4 || in.Number != 4 || out.Number != 5 {
+		t.Errorf("vendor function has interface %d, endpoints %d and %d", vendor.FirstInterface, in.Number, out.Number)
+	}
+
+	// A vendor interface doesn't get an interface association descriptor.
+	d := b.Descriptor()
+	expected := []byte{
+		9, TypeInterface, 4, 0, 2, 0xff, 0, 0, 0,
+		7, TypeEndpoint, 0x84, 0x02, 64, 0, 0,
+		7, TypeEndpoint, 0x05, 0x02, 64, 0, 0,
+	}
+	if got := d.Configuration[len(d.Configuration)-len(expected):]; !bytes.Equal(got, expected) {
+		t.Errorf("vendor descriptors:\n%x\nexpected:\n%x", got, expected)
+	}
+
+	// All endpoints are in use now.
+	err := b.Add(&Function{
+		Interfaces: []Interface{{Endpoints: []*Endpoint{{}}}},
+	})
+	if err != ErrTooManyEndpoints {
+		t.Errorf("expected ErrTooManyEndpoints, got %v", err)
+	}
+	err = b.Add(&Function{
+		Interfaces: []Interface{{Endpoints: []*Endpoint{{Number: 2}}}},
+	})
+	if err != ErrEndpointInUse {
+		t.Errorf("expected ErrEndpointInUse, got %v", err)
+	}
+	err = b.Add(&Function{Interfaces: make([]Interface, usb.NumberOfInterfaces)})
+	if err != ErrTooManyInterfaces {
+		t.Errorf("expected ErrTooManyInterfaces, got %v", err)
+	}
+}
+
+func TestBuilderHandlers(t *testing.T) {
+	var b Builder
+	b.AddHandlers([]usb.EndpointConfig{{Index: 4, IsIn: true}}, nil)
+	b.AddHandlers([]usb.EndpointConfig{{Index: 5}}, []usb.SetupConfig{{Index: 2}})
+	endpoints, setup := b.Handlers()
+	if len(endpoints) != 2 || endpoints[0].Index != 4 || endpoints[1].Index != 5 {
+		t.Errorf("endpoint handlers: %+v", endpoints)
+	}
+	if len(setup) != 1 || setup[0].Index != 2 {
+		t.Errorf("setup handlers: %+v", setup)
+	}
+}
+
+func TestBuilderHID(t *testing.T) {
+	var b Builder
+	b.Add(CDCFunction())
+	report := Append([][]byte{
+		HIDUsagePageGenericDesktop,
+		HIDUsageDesktopJoystick,
+		HIDCollectionApplication,
+		HIDCollectionEnd,
+	})
+	b.Add(&Function{
+		Interfaces: []Interface{
+			{
+				Class:     usb.DEVICE_CLASS_HUMAN_INTERFACE,
+				HIDReport: report,
+				Endpoints: []*Endpoint{
+					{In: true, Type: usb.ENDPOINT_TYPE_INTERRUPT, MaxPacketSize: 64, Interval: 1},
+				},
+			},
+		},
+	})
+	d := b.Descriptor()
+	if !bytes.Equal(d.HID[2], report) {
+		t.Errorf("report descriptor of interface 2 not set")
+	}
+	hid, err := FindClassHIDType(d.Configuration, classHID[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := hid.Bytes()[7]; int(l) != len(report) {
+		t.Errorf("HID descriptor has report length %d, expected %d", l, len(report))
+	}
+}
+
+func TestBuilderNCM(t *testing.T) {
+	var b Builder
+	b.Add(CDCFunction())
+	index, err := b.AddString("020000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncm := NCMFunction(index)
+	if err := b.Add(ncm); err != nil {
+		t.Fatal(err)
+	}
+	d := b.Descriptor()
+	if s := d.Strings[4]; s != "020000000001" {
+		t.Errorf("string descriptor 4 is %q", s)
+	}
+
+	expected := []byte{
+		8, TypeInterfaceAssociation, 2, 2, 0x02, 0x0d, 0, 0,
+		9, TypeInterface, 2, 0, 1, 0x02, 0x0d, 0, 0,
+		5, TypeClassSpecific, 0x00, 0x20, 0x01, // header
+		5, TypeClassSpecific, 0x06, 2, 3, // union
+		13, TypeClassSpecific, 0x0f, 4, 0, 0, 0, 0, 0xea, 0x05, 0, 0, 0, // ethernet
+		6, TypeClassSpecific, 0x1a, 0x00, 0x01, 0x01, // NCM
+		7, TypeEndpoint, 0x84, 0x03, 0x10, 0, 0x10,
+		9, TypeInterface, 3, 0, 0, 0x0a, 0, 0x01, 0,
+		9, TypeInterface, 3, 1, 2, 0x0a, 0, 0x01, 0,
+		7, TypeEndpoint, 0x05, 0x02, 64, 0, 0,
+		7, TypeEndpoint, 0x86, 0x02, 64, 0, 0,
+	}
+	if got := d.Configuration[len(CDC.Configuration):]; !bytes.Equal(got, expected) {
+		t.Errorf("NCM descriptors:\n%x\nexpected:\n%x", got, expected)
+	}
+	if d.Configuration[4] != 4 {
+		t.Errorf("%d interfaces, expected 4", d.Configuration[4])
+	}
+}
+
+func TestBuilderString(t *testing.T) {
+	var b Builder
+	if _, err := b.AddString(string(make([]byte, 126))); err != nil {
+		t.Errorf("string of 126 bytes: %v", err)
+	}
+	if _, err := b.AddString(string(make([]byte, 127))); err != ErrStringTooLong {
+		t.Errorf("string of 127 bytes: got %v, expected ErrStringTooLong", err)
+	}
+	if len(b.strings) != 1 {
+		t.Errorf("%d strings, expected 1", len(b.strings))
+	}
+}
+
+func TestBuilderDFU(t *testing.T) {
+	var b Builder
+	b.Add(CDCFunction())
+	if err := b.Add(DFUFunction()); err != nil {
+		t.Fatal(err)
+	}
+	d := b.Descriptor()
+
+	// A single interface, without an interface association descriptor.
+	expected := []byte{
+		9, TypeInterface, 2, 0, 0, 0xfe, 0x01, 0x01, 0,
+		9, TypeDFUFunctional, 0x08, 0xe8, 0x03, 0x40, 0x00, 0x10, 0x01,
+	}
+	if got := d.Configuration[len(CDC.Configuration):]; !bytes.Equal(got, expected) {
+		t.Errorf("DFU descriptors:\n%x\nexpected:\n%x", got, expected)
+	}
+	if d.BOS != nil {
+		t.Errorf("unexpected BOS descriptor")
+	}
+	if !bytes.Equal(d.Device, CDC.Device) {
+		t.Errorf("device descriptor:\n%x\nexpected:\n%x", d.Device, CDC.Device)
+	}
+}
+
+func TestBuilderWebUSB(t *testing.T) {
+	var b Builder
+	b.Add(CDCFunction())
+	const guid = "{975f44d9-0d08-43fd-8b3e-127ca8afff9d}"
+	vendor := VendorFunction(guid)
+	if err := b.Add(vendor); err != nil {
+		t.Fatal(err)
+	}
+	b.WebUSB("https://example.com/app")
+	d := b.Descriptor()
+
+	if d.Device[2] != 0x10 || d.Device[3] != 0x02 {
+		t.Errorf("device descriptor has USB version %x%02x, expected 210", d.Device[3], d.Device[2])
+	}
+
+	msOS20Len := byte(len(d.MSOS20))
+	expected := []byte{
+		5, TypeBOS, 57, 0, 2,
+		// WebUSB
+		24, TypeDeviceCapability, 0x05, 0,
+		0x38, 0xb6, 0x08, 0x34, 0xa9, 0x09, 0xa0, 0x47, 0x8b, 0xfd, 0xa0, 0x76, 0x88, 0x15, 0xb6, 0x65,
+		0x00, 0x01, usb.VENDOR_REQUEST_WEBUSB, 1,
+		// Microsoft OS 2.0
+		28, TypeDeviceCapability, 0x05, 0,
+		0xdf, 0x60, 0xdd, 0xd8, 0x89, 0x45, 0xc7, 0x4c, 0x9c, 0xd2, 0x65, 0x9d, 0x9e, 0x64, 0x8a, 0x9f,
+		0x00, 0x00, 0x03, 0x06, msOS20Len, 0, usb.VENDOR_REQUEST_MS_OS_20, 0,
+	}
+	if !bytes.Equal(d.BOS, expected) {
+		t.Errorf("BOS descriptor:\n%x\nexpected:\n%x", d.BOS, expected)
+	}
+
+	url := append([]byte{18, 0x03, 0x01}, "example.com/app"...)
+	if !bytes.Equal(d.WebUSBURL, url) {
+		t.Errorf("URL descriptor:\n%x\nexpected:\n%x", d.WebUSBURL, url)
+	}
+
+	// Descriptor set header, configuration subset, function subset, compatible
+	// ID and registry property.
+	propertyLen := 10 + 2*len("DeviceInterfaceGUIDs\x00") + 2*(len(guid)+2)
+	functionLen := 8 + 20 + propertyLen
+	if len(d.MSOS20) != 10+8+functionLen {
+		t.Fatalf("Microsoft OS 2.0 descriptor set has length %d, expected %d", len(d.MSOS20), 10+8+functionLen)
+	}
+	header := []byte{
+		10, 0, 0x00, 0, 0x00, 0x00, 0x03, 0x06, msOS20Len, 0,
+		8, 0, 0x01, 0, 0, 0, msOS20Len - 10, 0,
+		8, 0, 0x02, 0, vendor.FirstInterface, 0, byte(functionLen), 0,
+		20, 0, 0x03, 0, 'W', 'I', 'N', 'U', 'S', 'B', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		byte(propertyLen), 0, 0x04, 0, 7, 0, 42, 0, 'D', 0, 'e', 0, 'v', 0,
+	}
+	if got := d.MSOS20[:len(header)]; !bytes.Equal(got, header) {
+		t.Errorf("Microsoft OS 2.0 descriptor set:\n%x\nexpected:\n%x", got, header)
+	}
+	value := d.MSOS20[len(d.MSOS20)-2*(len(guid)+2)-2:]
+	if value[0] != byte(2*(len(guid)+2)) || value[2] != '{' || value[3] != 0 || value[len(value)-6] != '}' {
+		t.Errorf("registry property data: %x", value)
+	}
+}
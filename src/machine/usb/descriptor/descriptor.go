@@ -12,7 +12,10 @@ const (
 	TypeEndpoint              = 0x5
 	TypeDeviceQualifier       = 0x6
 	TypeInterfaceAssociation  = 0xb
+	TypeBOS                   = 0xf
+	TypeDeviceCapability      = 0x10
 	TypeClassHID              = 0x21
+	TypeDFUFunctional         = 0x21
 	TypeHIDReport             = 0x22
 	TypeClassSpecific         = 0x24
 	TypeClassSpecificEndpoint = 0x25
@@ -35,6 +38,13 @@ type Descriptor struct {
 	Device        []byte
 	Configuration []byte
 	HID           map[uint16][]byte
+	Strings       map[uint8]string // string descriptors other than those in package usb
+
+	// Binary device object store, and the descriptors of its platform
+	// capabilities.
+	BOS       []byte
+	MSOS20    []byte // Microsoft OS 2.0 descriptor set
+	WebUSBURL []byte // URL descriptor of the WebUSB landing page
 }
 
 func (d *Descriptor) Configure(idVendor, idProduct uint16) {
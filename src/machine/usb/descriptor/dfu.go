@@ -0,0 +1,39 @@
+package descriptor
+
+const (
+	dfuClass               = 0xfe // application specific
+	dfuSubClass            = 0x01
+	dfuProtocolRuntime     = 0x01
+	dfuFunctionalTypeLen   = 9
+	dfuAttributeWillDetach = 0x08
+)
+
+var dfuFunctional = [dfuFunctionalTypeLen]byte{
+	dfuFunctionalTypeLen,
+	TypeDFUFunctional,
+	dfuAttributeWillDetach, // Attributes: the device detaches by itself
+	0xe8, 0x03,             // DetachTimeOut (1000 ms)
+	0x40, 0x00, // TransferSize
+	0x10, 0x01, // DFU version 1.1
+}
+
+var DFUFunctional = ClassSpecificType{
+	data: dfuFunctional[:],
+}
+
+// DFUFunction returns the runtime function of Device Firmware Upgrade, which
+// is used by machine/usb/dfu. It has no endpoints: the host sends the DFU_DETACH
+// request to its interface, and the device then restarts into its bootloader to
+// receive the new firmware.
+func DFUFunction() *Function {
+	return &Function{
+		Interfaces: []Interface{
+			{
+				Class:         dfuClass,
+				SubClass:      dfuSubClass,
+				Protocol:      dfuProtocolRuntime,
+				ClassSpecific: DFUFunctional.Bytes(),
+			},
+		},
+	}
+}
@@ -0,0 +1,277 @@
+package descriptor
+
+import (
+	"errors"
+	"machine/usb"
+)
+
+var (
+	ErrHIDReportTooLong        = errors.New("HID report longer than a packet")
+	ErrHIDFeatureReportTooLong = errors.New("HID feature report longer than 7 bytes")
+)
+
+// Maximum length of feature reports, including the ID. SET_REPORT requests
+// are received with machine.ReceiveUSBControlPacket, which only receives that
+// many bytes.
+const hidMaxFeatureReportLength = 7
+
+const hidFeature = 0xB0
+
+// Types of HID reports, as in the GET_REPORT and SET_REPORT requests.
+const (
+	HIDReportInput   = 1
+	HIDReportOutput  = 2
+	HIDReportFeature = 3
+)
+
+// Flags of the fields of HID reports. Without flags, a field is data, an array
+// of usage indexes, and absolute.
+const (
+	HIDConstant  = 0x01
+	HIDVariable  = 0x02
+	HIDRelative  = 0x04
+	HIDNullState = 0x40
+)
+
+// HIDField is a field of a HID report: Count values of Size bits each.
+type HIDField struct {
+	Report uint8 // HIDReportInput, HIDReportOutput or HIDReportFeature
+	Flags  uint8
+
+	// UsagePage is the usage page of Usages, 0 for that of the application.
+	UsagePage uint16
+
+	// Usages are the usages of the values, or of the array elements. When
+	// Usages is nil, the usages are UsageMinimum to UsageMaximum, and fields
+	// without either (such as padding) have no usages.
+	Usages       []uint16
+	UsageMinimum uint16
+	UsageMaximum uint16
+
+	LogicalMinimum int32
+	LogicalMaximum int32
+
+	Size  uint8 // bits per value
+	Count uint8
+}
+
+// HIDApplication is a top level collection of a HID report descriptor, such as
+// a gamepad. Its input, output and feature reports are made of its fields, in
+// order.
+type HIDApplication struct {
+	UsagePage uint16
+	Usage     uint16
+
+	// ID is the report ID of the reports, which is their first byte. It must
+	// be set when an interface has more than one application.
+	ID uint8
+
+	Fields []HIDField
+}
+
+// ReportLength returns the length in bytes of the report of type report,
+// including its ID, or 0 if the application doesn't have this report.
+func (a *HIDApplication) ReportLength(report uint8) int {
+	bits := 0
+	for _, f := range a.Fields {
+		if f.Report == report {
+			bits += int(f.Size) * int(f.Count)
+		}
+	}
+	if bits == 0 {
+		return 0
+	}
+	n := (bits + 7) / 8
+	if a.ID != 0 {
+		n++
+	}
+	return n
+}
+
+// HIDReportDescriptor returns the report descriptor of the applications.
+// Global items are only added when they change, like in hand written report
+// descriptors.
+func HIDReportDescriptor(apps ...HIDApplication) []byte {
+	var b []byte
+	add := func(item []byte) {
+		b = append(b, item...)
+	}
+
+	// Global state, with values that are never used for the items that have
+	// not been set yet.
+	page := int64(-1)
+	min, max := int64(-1)<<32, int64(-1)<<32
+	size, count := -1, -1
+
+	for _, a := range apps {
+		add(HIDUsagePage(a.UsagePage))
+		add(HIDUsage(uint32(a.Usage)))
+		add(HIDCollectionApplication)
+		page = int64(a.UsagePage)
+		if a.ID != 0 {
+			add(HIDReportID(int(a.ID)))
+		}
+
+		for _, f := range a.Fields {
+			p := f.UsagePage
+			if p == 0 {
+				p = a.UsagePage
+			}
+			hasUsages := f.Usages != nil || f.UsageMinimum != 0 || f.UsageMaximum != 0
+			if hasUsages && int64(p) != page {
+				add(HIDUsagePage(p))
+				page = int64(p)
+			}
+			if f.Flags&HIDConstant == 0 {
+				if int64(f.LogicalMinimum) != min {
+					add(HIDLogicalMinimum(int(f.LogicalMinimum)))
+					min = int64(f.LogicalMinimum)
+				}
+				if int64(f.LogicalMaximum) != max {
+					add(HIDLogicalMaximum(int(f.LogicalMaximum)))
+					max = int64(f.LogicalMaximum)
+				}
+			}
+			if f.Usages != nil {
+				for _, u := range f.Usages {
+					add(HIDUsage(uint32(u)))
+				}
+			} else if hasUsages {
+				add(HIDUsageMinimum(int(f.UsageMinimum)))
+				add(HIDUsageMaximum(int(f.UsageMaximum)))
+			}
+			if int(f.Size) != size {
+				add(HIDReportSize(int(f.Size)))
+				size = int(f.Size)
+			}
+			if int(f.Count) != count {
+				add(HIDReportCount(int(f.Count)))
+				count = int(f.Count)
+			}
+			switch f.Report {
+			case HIDReportInput:
+				add(HIDInput(uint32(f.Flags)))
+			case HIDReportOutput:
+				add(HIDOutput(uint32(f.Flags)))
+			case HIDReportFeature:
+				add(hidShortItem(hidFeature, uint32(f.Flags)))
+			}
+		}
+
+		add(HIDCollectionEnd)
+	}
+	return b
+}
+
+// HIDFunction returns a HID function with the reports of the applications,
+// which is used by machine/usb/hid/custom. It has an interrupt IN endpoint,
+// and an interrupt OUT endpoint if there are output reports. Reports must fit
+// in a packet of these endpoints, and feature reports can be at most 7 bytes
+// long (including the ID).
+func HIDFunction(apps ...HIDApplication) (*Function, error) {
+	out := false
+	for i := range apps {
+		for _, report := range []uint8{HIDReportInput, HIDReportOutput, HIDReportFeature} {
+			if apps[i].ReportLength(report) > usb.EndpointPacketSize {
+				return nil, ErrHIDReportTooLong
+			}
+		}
+		if apps[i].ReportLength(HIDReportFeature) > hidMaxFeatureReportLength {
+			return nil, ErrHIDFeatureReportTooLong
+		}
+		if apps[i].ReportLength(HIDReportOutput) != 0 {
+			out = true
+		}
+	}
+
+	intf := Interface{
+		Class:     usb.DEVICE_CLASS_HUMAN_INTERFACE,
+		HIDReport: HIDReportDescriptor(apps...),
+		Endpoints: []*Endpoint{
+			{
+				In:            true,
+				Type:          usb.ENDPOINT_TYPE_INTERRUPT,
+				MaxPacketSize: usb.EndpointPacketSize,
+				Interval:      1,
+			},
+		},
+	}
+	if out {
+		intf.Endpoints = append(intf.Endpoints, &Endpoint{
+			Type:          usb.ENDPOINT_TYPE_INTERRUPT,
+			MaxPacketSize: usb.EndpointPacketSize,
+			Interval:      1,
+		})
+	}
+	return &Function{Interfaces: []Interface{intf}}, nil
+}
+
+// HIDConsumerControl returns a consumer control application, for media keys
+// and the like. Its input report is the 16-bit usage of the key being pressed,
+// or 0.
+func HIDConsumerControl(id uint8) HIDApplication {
+	return HIDApplication{
+		UsagePage: 0x0C, // consumer
+		Usage:     0x01, // consumer control
+		ID:        id,
+		Fields: []HIDField{
+			{
+				Report:         HIDReportInput,
+				LogicalMinimum: 0,
+				LogicalMaximum: 0x1FFF,
+				UsageMinimum:   0,
+				UsageMaximum:   0x1FFF,
+				Size:           16,
+				Count:          1,
+			},
+		},
+	}
+}
+
+// HIDGamepad returns a gamepad application. Its input report has 16 buttons,
+// a hat switch (0 to 7 clockwise from up, 8 when released), and the X, Y, Z,
+// Rz, Rx and Ry axes, from -127 to 127.
+func HIDGamepad(id uint8) HIDApplication {
+	return HIDApplication{
+		UsagePage: 0x01, // generic desktop
+		Usage:     0x05, // gamepad
+		ID:        id,
+		Fields: []HIDField{
+			{
+				Report:         HIDReportInput,
+				Flags:          HIDVariable,
+				UsagePage:      0x09, // button
+				UsageMinimum:   1,
+				UsageMaximum:   16,
+				LogicalMinimum: 0,
+				LogicalMaximum: 1,
+				Size:           1,
+				Count:          16,
+			},
+			{
+				Report:         HIDReportInput,
+				Flags:          HIDVariable | HIDNullState,
+				Usages:         []uint16{0x39}, // hat switch
+				LogicalMinimum: 0,
+				LogicalMaximum: 7,
+				Size:           4,
+				Count:          1,
+			},
+			{
+				Report: HIDReportInput,
+				Flags:  HIDConstant,
+				Size:   4,
+				Count:  1,
+			},
+			{
+				Report:         HIDReportInput,
+				Flags:          HIDVariable,
+				Usages:         []uint16{0x30, 0x31, 0x32, 0x35, 0x33, 0x34}, // X, Y, Z, Rz, Rx, Ry
+				LogicalMinimum: -127,
+				LogicalMaximum: 127,
+				Size:           8,
+				Count:          6,
+			},
+		},
+	}
+}
@@ -0,0 +1,150 @@
+package descriptor
+
+import (
+	"bytes"
+	"testing"
+)
+
+// vendorApplication is a vendor defined application with 64 byte input and
+// output reports, and a 4 byte feature report.
+var vendorApplication = HIDApplication{
+	UsagePage: 0xFF00,
+	Usage:     0x01,
+	Fields: []HIDField{
+		{Report: HIDReportInput, Flags: HIDVariable, Usages: []uint16{0x02}, LogicalMaximum: 255, Size: 8, Count: 64},
+		{Report: HIDReportOutput, Flags: HIDVariable, Usages: []uint16{0x03}, LogicalMaximum: 255, Size: 8, Count: 64},
+		{Report: HIDReportFeature, Flags: HIDVariable, Usages: []uint16{0x04}, LogicalMaximum: 255, Size: 8, Count: 4},
+	},
+}
+
+func TestHIDReportDescriptor(t *testing.T) {
+	// The consumer control collection of CDCHID, which hosts know.
+	cdc := CDCHID.HID[2]
+	expected := cdc[bytes.LastIndex(cdc, HIDUsagePageConsumer):]
+	if got := HIDReportDescriptor(HIDConsumerControl(3)); !bytes.Equal(got, expected) {
+		t.Errorf("consumer control:\n%x\nexpected:\n%x", got, expected)
+	}
+
+	expected = []byte{
+		0x05, 0x01, // Usage Page (Generic Desktop)
+		0x09, 0x05, // Usage (Gamepad)
+		0xa1, 0x01, // Collection (Application)
+		0x85, 0x04, //   Report ID (4)
+		0x05, 0x09, //   Usage Page (Button)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x25, 0x01, //   Logical Maximum (1)
+		0x19, 0x01, //   Usage Minimum (1)
+		0x29, 0x10, //   Usage Maximum (16)
+		0x75, 0x01, //   Report Size (1)
+		0x95, 0x10, //   Report Count (16)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x05, 0x01, //   Usage Page (Generic Desktop)
+		0x25, 0x07, //   Logical Maximum (7)
+		0x09, 0x39, //   Usage (Hat Switch)
+		0x75, 0x04, //   Report Size (4)
+		0x95, 0x01, //   Report Count (1)
+		0x81, 0x42, //   Input (Data, Variable, Absolute, Null State)
+		0x81, 0x01, //   Input (Constant)
+		0x15, 0x81, //   Logical Minimum (-127)
+		0x25, 0x7f, //   Logical Maximum (127)
+		0x09, 0x30, //   Usage (X)
+		0x09, 0x31, //   Usage (Y)
+		0x09, 0x32, //   Usage (Z)
+		0x09, 0x35, //   Usage (Rz)
+		0x09, 0x33, //   Usage (Rx)
+		0x09, 0x34, //   Usage (Ry)
+		0x75, 0x08, //   Report Size (8)
+		0x95, 0x06, //   Report Count (6)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0xc0, // End Collection
+	}
+	if got := HIDReportDescriptor(HIDGamepad(4)); !bytes.Equal(got, expected) {
+		t.Errorf("gamepad:\n%x\nexpected:\n%x", got, expected)
+	}
+
+	expected = []byte{
+		0x06, 0x00, 0xff, // Usage Page (Vendor Defined 0xFF00)
+		0x09, 0x01, // Usage (1)
+		0xa1, 0x01, // Collection (Application)
+		0x15, 0x00, //   Logical Minimum (0)
+		0x26, 0xff, 0x00, //   Logical Maximum (255)
+		0x09, 0x02, //   Usage (2)
+		0x75, 0x08, //   Report Size (8)
+		0x95, 0x40, //   Report Count (64)
+		0x81, 0x02, //   Input (Data, Variable, Absolute)
+		0x09, 0x03, //   Usage (3)
+		0x91, 0x02, //   Output (Data, Variable, Absolute)
+		0x09, 0x04, //   Usage (4)
+		0x95, 0x04, //   Report Count (4)
+		0xb1, 0x02, //   Feature (Data, Variable, Absolute)
+		0xc0, // End Collection
+	}
+	if got := HIDReportDescriptor(vendorApplication); !bytes.Equal(got, expected) {
+		t.Errorf("vendor defined:\n%x\nexpected:\n%x", got, expected)
+	}
+}
+
+func TestHIDReportLength(t *testing.T) {
+	gamepad := HIDGamepad(1)
+	consumer := HIDConsumerControl(0)
+	for _, test := range []struct {
+		app    *HIDApplication
+		report uint8
+		length int
+	}{
+		{&gamepad, HIDReportInput, 1 + 2 + 1 + 6},
+		{&gamepad, HIDReportOutput, 0},
+		{&consumer, HIDReportInput, 2},
+		{&vendorApplication, HIDReportInput, 64},
+		{&vendorApplication, HIDReportOutput, 64},
+		{&vendorApplication, HIDReportFeature, 4},
+	} {
+		if n := test.app.ReportLength(test.report); n != test.length {
+			t.Errorf("report %d of usage %x has length %d, expected %d", test.report, test.app.Usage, n, test.length)
+		}
+	}
+}
+
+func TestHIDFunction(t *testing.T) {
+	var b Builder
+	b.Add(CDCFunction())
+	gamepad, err := HIDFunction(HIDGamepad(1), HIDConsumerControl(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(gamepad.Interfaces[0].Endpoints); n != 1 {
+		t.Errorf("gamepad has %d endpoints, expected only an IN endpoint", n)
+	}
+	vendor, err := HIDFunction(vendorApplication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(vendor.Interfaces[0].Endpoints); n != 2 {
+		t.Errorf("vendor defined function has %d endpoints, expected 2", n)
+	}
+	b.Add(gamepad)
+	b.Add(vendor)
+	d := b.Descriptor()
+	if !bytes.Equal(d.HID[2], HIDReportDescriptor(HIDGamepad(1), HIDConsumerControl(2))) {
+		t.Errorf("report descriptor of interface 2 not set")
+	}
+	if !bytes.Equal(d.HID[3], HIDReportDescriptor(vendorApplication)) {
+		t.Errorf("report descriptor of interface 3 not set")
+	}
+
+	// With a report ID, the input report doesn't fit in a packet anymore.
+	app := vendorApplication
+	app.ID = 1
+	if _, err := HIDFunction(app); err != ErrHIDReportTooLong {
+		t.Errorf("expected ErrHIDReportTooLong, got %v", err)
+	}
+
+	// Feature reports are set with SET_REPORT requests, which are limited to
+	// 7 bytes.
+	app = vendorApplication
+	app.Fields = append([]HIDField(nil), app.Fields...)
+	app.Fields[2].Count = 8
+	if _, err := HIDFunction(app); err != ErrHIDFeatureReportTooLong {
+		t.Errorf("expected ErrHIDFeatureReportTooLong, got %v", err)
+	}
+}
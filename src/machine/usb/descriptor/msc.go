@@ -0,0 +1,80 @@
+package descriptor
+
+var configurationCDCMSC = [configurationTypeLen]byte{
+	configurationTypeLen,
+	TypeConfiguration,
+	0x62, 0x00, // adjust length as needed
+	0x03, // number of interfaces
+	0x01, // configuration value
+	0x00, // index to string description
+	0xa0, // attributes
+	0x32, // maxpower
+}
+
+var ConfigurationCDCMSC = ConfigurationType{
+	data: configurationCDCMSC[:],
+}
+
+var interfaceMSC = [interfaceTypeLen]byte{
+	interfaceTypeLen,
+	TypeInterface,
+	0x02, // InterfaceNumber
+	0x00, // AlternateSetting
+	0x02, // NumEndpoints
+	0x08, // InterfaceClass (mass storage)
+	0x06, // InterfaceSubClass (SCSI transparent command set)
+	0x50, // InterfaceProtocol (bulk-only transport)
+	0x00, // Interface
+}
+
+var InterfaceMSC = InterfaceType{
+	data: interfaceMSC[:],
+}
+
+var endpointEP6INBulk = [endpointTypeLen]byte{
+	endpointTypeLen,
+	TypeEndpoint,
+	0x86, // EndpointAddress
+	0x02, // Attributes
+	0x40, // MaxPacketSizeL
+	0x00, // MaxPacketSizeH
+	0x00, // Interval
+}
+
+var EndpointEP6INBulk = EndpointType{
+	data: endpointEP6INBulk[:],
+}
+
+var endpointEP7OUTBulk = [endpointTypeLen]byte{
+	endpointTypeLen,
+	TypeEndpoint,
+	0x07, // EndpointAddress
+	0x02, // Attributes
+	0x40, // MaxPacketSizeL
+	0x00, // MaxPacketSizeH
+	0x00, // Interval
+}
+
+var EndpointEP7OUTBulk = EndpointType{
+	data: endpointEP7OUTBulk[:],
+}
+
+var CDCMSC = Descriptor{
+	Device: DeviceCDC.Bytes(),
+	Configuration: Append([][]byte{
+		ConfigurationCDCMSC.Bytes(),
+		InterfaceAssociationCDC.Bytes(),
+		InterfaceCDCControl.Bytes(),
+		ClassSpecificCDCHeader.Bytes(),
+		ClassSpecificCDCCallManagement.Bytes(),
+		ClassSpecificCDCACM.Bytes(),
+		ClassSpecificCDCUnion.Bytes(),
+		EndpointEP1IN.Bytes(),
+		InterfaceCDCData.Bytes(),
+		EndpointEP2OUT.Bytes(),
+		EndpointEP3IN.Bytes(),
+		InterfaceMSC.Bytes(),
+		EndpointEP6INBulk.Bytes(),
+		EndpointEP7OUTBulk.Bytes(),
+	}),
+}
@@ -0,0 +1,112 @@
+package descriptor
+
+import (
+	"machine/usb"
+)
+
+const (
+	cdcSubClassNCM   = 0x0d
+	cdcFunctionalNCM = 0x1a
+)
+
+var classSpecificNCMHeader = [classSpecificTypeLen]byte{
+	classSpecificTypeLen,
+	TypeClassSpecific,
+	cdcFunctionalHeader,
+	0x20, // CDC version 1.20
+	0x01, //
+}
+
+var ClassSpecificNCMHeader = ClassSpecificType{
+	data: classSpecificNCMHeader[:],
+}
+
+const classSpecificEthernetLen = 13
+
+var classSpecificEthernet = [classSpecificEthernetLen]byte{
+	classSpecificEthernetLen,
+	TypeClassSpecific,
+	cdcFunctionalEthernet,
+	0x00,                   // MACAddress string index
+	0x00, 0x00, 0x00, 0x00, // EthernetStatistics
+	0xea, 0x05, // MaxSegmentSize (1514)
+	0x00, 0x00, // NumberMCFilters
+	0x00, // NumberPowerFilters
+}
+
+var ClassSpecificEthernet = ClassSpecificType{
+	data: classSpecificEthernet[:],
+}
+
+const classSpecificNCMLen = 6
+
+var classSpecificNCM = [classSpecificNCMLen]byte{
+	classSpecificNCMLen,
+	TypeClassSpecific,
+	cdcFunctionalNCM,
+	0x00, 0x01, // NCM version 1.0
+	0x01, // NetworkCapabilities: SetEthernetPacketFilter
+}
+
+var ClassSpecificNCM = ClassSpecificType{
+	data: classSpecificNCM[:],
+}
+
+// NCMFunction returns the function of a USB network adapter (CDC NCM), which
+// is used by machine/usb/cdc/ncm. The MAC address of the adapter is the string
+// descriptor at index mac, see Builder.AddString.
+//
+// The endpoints of the data interface are in its alternate setting 1: the
+// host selects it to start the network.
+func NCMFunction(mac uint8) *Function {
+	cs := Append([][]byte{
+		ClassSpecificNCMHeader.Bytes(),
+		ClassSpecificCDCUnion.Bytes(),
+		ClassSpecificEthernet.Bytes(),
+		ClassSpecificNCM.Bytes(),
+	})
+	cs[13] = mac // MACAddress of the Ethernet networking descriptor
+
+	return &Function{
+		Class:    usb.DEVICE_CLASS_COMMUNICATIONS,
+		SubClass: cdcSubClassNCM,
+		Interfaces: []Interface{
+			{
+				Class:         usb.DEVICE_CLASS_COMMUNICATIONS,
+				SubClass:      cdcSubClassNCM,
+				ClassSpecific: cs,
+				// Control and data interfaces of the union descriptor.
+				InterfaceRefs: []int{8, 9},
+				Endpoints: []*Endpoint{
+					{
+						In:            true,
+						Type:          usb.ENDPOINT_TYPE_INTERRUPT,
+						MaxPacketSize: 0x10,
+						Interval:      0x10,
+					},
+				},
+			},
+			{
+				Class:    0x0a, // data
+				Protocol: 0x01, // network transfer block
+				Alternates: []Interface{
+					{
+						Class:    0x0a,
+						Protocol: 0x01,
+						Endpoints: []*Endpoint{
+							{
+								Type:          usb.ENDPOINT_TYPE_BULK,
+								MaxPacketSize: usb.EndpointPacketSize,
+							},
+							{
+								In:            true,
+								Type:          usb.ENDPOINT_TYPE_BULK,
+								MaxPacketSize: usb.EndpointPacketSize,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
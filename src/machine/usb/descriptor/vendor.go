@@ -0,0 +1,30 @@
+package descriptor
+
+import (
+	"machine/usb"
+)
+
+// VendorFunction returns a vendor specific function with a bulk OUT and a bulk
+// IN endpoint, which is used by machine/usb/vendor. See Function.WinUSBGUID
+// for interfaceGUID, which may be empty.
+func VendorFunction(interfaceGUID string) *Function {
+	return &Function{
+		Interfaces: []Interface{
+			{
+				Class: usb.DEVICE_CLASS_VENDOR_SPECIFIC,
+				Endpoints: []*Endpoint{
+					{
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+					},
+					{
+						In:            true,
+						Type:          usb.ENDPOINT_TYPE_BULK,
+						MaxPacketSize: usb.EndpointPacketSize,
+					},
+				},
+			},
+		},
+		WinUSBGUID: interfaceGUID,
+	}
+}
@@ -0,0 +1,166 @@
+package descriptor
+
+import (
+	"internal/binary"
+	"machine/usb"
+	"strings"
+)
+
+const (
+	bosTypeLen          = 5
+	webUSBCapabilityLen = 24
+	msOS20CapabilityLen = 28
+	capabilityPlatform  = 0x05
+
+	webUSBTypeURL = 0x03
+
+	// Microsoft OS 2.0 descriptor types.
+	msOS20SetHeader           = 0x00
+	msOS20SubsetConfiguration = 0x01
+	msOS20SubsetFunction      = 0x02
+	msOS20CompatibleID        = 0x03
+	msOS20RegistryProperty    = 0x04
+
+	// Windows 8.1, the first version that reads Microsoft OS 2.0 descriptors.
+	msOS20WindowsVersion = 0x06030000
+)
+
+// UUIDs of the platform capabilities, in the byte order of the descriptor.
+var (
+	webUSBUUID = [16]byte{0x38, 0xb6, 0x08, 0x34, 0xa9, 0x09, 0xa0, 0x47, 0x8b, 0xfd, 0xa0, 0x76, 0x88, 0x15, 0xb6, 0x65}
+	msOS20UUID = [16]byte{0xdf, 0x60, 0xdd, 0xd8, 0x89, 0x45, 0xc7, 0x4c, 0x9c, 0xd2, 0x65, 0x9d, 0x9e, 0x64, 0x8a, 0x9f}
+)
+
+// WebUSB adds the WebUSB platform capability to the device, so that browsers
+// let web pages use its vendor specific functions. landingPage is the URL of
+// the page to use the device with, or empty. On Windows, these functions also
+// need a WinUSBGUID.
+func (b *Builder) WebUSB(landingPage string) {
+	b.webUSB = true
+	b.landingPage = landingPage
+}
+
+// bos adds the BOS descriptor and the descriptors of its platform
+// capabilities to d, if the device has any.
+func (b *Builder) bos(d *Descriptor) {
+	var winUSB []*Function
+	for _, f := range b.functions {
+		if f.WinUSBGUID != "" {
+			winUSB = append(winUSB, f)
+		}
+	}
+	if !b.webUSB && len(winUSB) == 0 {
+		return
+	}
+
+	bos := make([]byte, bosTypeLen, bosTypeLen+webUSBCapabilityLen+msOS20CapabilityLen)
+	bos[0] = bosTypeLen
+	bos[1] = TypeBOS
+
+	if b.webUSB {
+		c := grow(&bos, webUSBCapabilityLen)
+		c[0] = webUSBCapabilityLen
+		c[1] = TypeDeviceCapability
+		c[2] = capabilityPlatform
+		copy(c[4:20], webUSBUUID[:])
+		binary.LittleEndian.PutUint16(c[20:], 0x0100) // WebUSB version 1.0
+		c[22] = usb.VENDOR_REQUEST_WEBUSB
+		if b.landingPage != "" {
+			c[23] = 1 // URL index of the landing page
+			d.WebUSBURL = urlDescriptor(b.landingPage)
+		}
+		bos[4]++
+	}
+
+	if len(winUSB) != 0 {
+		d.MSOS20 = msOS20Descriptor(winUSB)
+		c := grow(&bos, msOS20CapabilityLen)
+		c[0] = msOS20CapabilityLen
+		c[1] = TypeDeviceCapability
+		c[2] = capabilityPlatform
+		copy(c[4:20], msOS20UUID[:])
+		binary.LittleEndian.PutUint32(c[20:], msOS20WindowsVersion)
+		binary.LittleEndian.PutUint16(c[24:], uint16(len(d.MSOS20)))
+		c[26] = usb.VENDOR_REQUEST_MS_OS_20
+		bos[4]++
+	}
+
+	binary.LittleEndian.PutUint16(bos[2:], uint16(len(bos)))
+	d.BOS = bos
+
+	// Hosts only ask for the BOS descriptor of USB 2.1 devices.
+	DeviceType{d.Device}.USB(0x0210)
+}
+
+// urlDescriptor returns the WebUSB URL descriptor of url.
+func urlDescriptor(url string) []byte {
+	scheme := byte(0xff) // URL includes the scheme
+	if strings.HasPrefix(url, "https://") {
+		scheme, url = 0x01, url[len("https://"):]
+	} else if strings.HasPrefix(url, "http://") {
+		scheme, url = 0x00, url[len("http://"):]
+	}
+	b := make([]byte, 3+len(url))
+	b[0] = byte(len(b))
+	b[1] = webUSBTypeURL
+	b[2] = scheme
+	copy(b[3:], url)
+	return b
+}
+
+// msOS20Descriptor returns the Microsoft OS 2.0 descriptor set, which gives
+// the functions the WinUSB driver.
+func msOS20Descriptor(functions []*Function) []byte {
+	b := make([]byte, 0, 256)
+
+	header := grow(&b, 10)
+	binary.LittleEndian.PutUint16(header[0:], 10)
+	binary.LittleEndian.PutUint16(header[2:], msOS20SetHeader)
+	binary.LittleEndian.PutUint32(header[4:], msOS20WindowsVersion)
+
+	subset := grow(&b, 8)
+	binary.LittleEndian.PutUint16(subset[0:], 8)
+	binary.LittleEndian.PutUint16(subset[2:], msOS20SubsetConfiguration)
+
+	for _, f := range functions {
+		start := len(b)
+		subset := grow(&b, 8)
+		binary.LittleEndian.PutUint16(subset[0:], 8)
+		binary.LittleEndian.PutUint16(subset[2:], msOS20SubsetFunction)
+		subset[4] = f.FirstInterface
+
+		id := grow(&b, 20)
+		binary.LittleEndian.PutUint16(id[0:], 20)
+		binary.LittleEndian.PutUint16(id[2:], msOS20CompatibleID)
+		copy(id[4:], "WINUSB")
+
+		// DeviceInterfaceGUIDs is a REG_MULTI_SZ: a list of strings, ending
+		// with an empty string.
+		const name = "DeviceInterfaceGUIDs\x00"
+		value := f.WinUSBGUID + "\x00\x00"
+		length := 10 + 2*len(name) + 2*len(value)
+		property := grow(&b, length)
+		binary.LittleEndian.PutUint16(property[0:], uint16(length))
+		binary.LittleEndian.PutUint16(property[2:], msOS20RegistryProperty)
+		binary.LittleEndian.PutUint16(property[4:], 7) // REG_MULTI_SZ
+		binary.LittleEndian.PutUint16(property[6:], uint16(2*len(name)))
+		putUTF16(property[8:], name)
+		p := property[8+2*len(name):]
+		binary.LittleEndian.PutUint16(p[0:], uint16(2*len(value)))
+		putUTF16(p[2:], value)
+
+		binary.LittleEndian.PutUint16(b[start+6:], uint16(len(b)-start))
+	}
+
+	binary.LittleEndian.PutUint16(b[8:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[10+6:], uint16(len(b)-10))
+	return b
+}
+
+// putUTF16 writes the ASCII string s to b as UTF-16LE.
+func putUTF16(b []byte, s string) {
+	for i := 0; i < len(s); i++ {
+		b[2*i] = s[i]
+		b[2*i+1] = 0
+	}
+}
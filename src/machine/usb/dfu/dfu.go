@@ -0,0 +1,62 @@
+//go:build sam || nrf52840 || rp2040
+
+package dfu
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/descriptor"
+)
+
+const (
+	// Class specific requests.
+	dfuDetach    = 0x00
+	dfuGetStatus = 0x03
+	dfuGetState  = 0x05
+
+	dfuStateAppIdle = 0x00
+)
+
+// Response to dfuGetStatus: no error, no poll timeout, in state appIDLE.
+var status = [6]byte{0x00, 0x00, 0x00, 0x00, dfuStateAppIdle, 0x00}
+
+// Response to dfuGetState.
+var state = [1]byte{dfuStateAppIdle}
+
+// New adds the DFU runtime interface to the USB device built by b. It must
+// only be called once.
+func New(b *descriptor.Builder) error {
+	f := descriptor.DFUFunction()
+	if err := b.Add(f); err != nil {
+		return err
+	}
+	b.AddHandlers(nil,
+		[]usb.SetupConfig{
+			{
+				Index:   f.FirstInterface,
+				Handler: setupHandler,
+			},
+		})
+	return nil
+}
+
+func setupHandler(setup usb.Setup) bool {
+	switch setup.BmRequestType {
+	case usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE:
+		if setup.BRequest == dfuDetach {
+			machine.SendZlp()
+			machine.EnterBootloader()
+			return true
+		}
+	case usb.REQUEST_DEVICETOHOST_CLASS_INTERFACE:
+		switch setup.BRequest {
+		case dfuGetStatus:
+			machine.SendUSBInPacket(0, status[:])
+			return true
+		case dfuGetState:
+			machine.SendUSBInPacket(0, state[:])
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+// package dfu is for the runtime part of USB Device Firmware Upgrade (DFU). It
+// adds a DFU interface to the device, so that DFU tools (such as dfu-util) can
+// tell the program to restart into its bootloader to upgrade the firmware,
+// without pressing buttons on the board.
+//
+// The device restarts with machine.EnterBootloader, so the firmware upgrade
+// itself is done by the bootloader of the board, which may use another
+// protocol than DFU (such as UF2).
+package dfu
@@ -0,0 +1,52 @@
+//go:build sam || nrf52840 || rp2040
+
+// package consumer is for USB consumer control devices, such as media keys and
+// volume knobs.
+package consumer
+
+import (
+	"machine/usb/descriptor"
+	"machine/usb/hid/custom"
+)
+
+// Usages of common keys, from the consumer page of the HID usage tables.
+const (
+	ScanNextTrack     = 0xB5
+	ScanPreviousTrack = 0xB6
+	Stop              = 0xB7
+	PlayPause         = 0xCD
+	Mute              = 0xE2
+	VolumeIncrement   = 0xE9
+	VolumeDecrement   = 0xEA
+)
+
+// Consumer is a USB consumer control device.
+type Consumer struct {
+	hid    *custom.HID
+	report *custom.Report
+}
+
+// New adds a consumer control interface to the USB device built by b.
+func New(b *descriptor.Builder) (*Consumer, error) {
+	h, err := custom.New(b, descriptor.HIDConsumerControl(0))
+	if err != nil {
+		return nil, err
+	}
+	return &Consumer{
+		hid:    h,
+		report: h.Report(0, descriptor.HIDReportInput),
+	}, nil
+}
+
+// Press presses the key with the given usage, releasing the one pressed
+// before.
+func (c *Consumer) Press(usage uint16) error {
+	c.report.Set(0, 0, int32(usage))
+	return c.hid.SendReport(c.report)
+}
+
+// Release releases the key pressed.
+func (c *Consumer) Release() error {
+	c.report.Set(0, 0, 0)
+	return c.hid.SendReport(c.report)
+}
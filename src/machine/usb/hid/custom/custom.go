@@ -0,0 +1,207 @@
+//go:build sam || nrf52840 || rp2040
+
+package custom
+
+import (
+	"errors"
+	"machine"
+	"machine/usb"
+	"machine/usb/descriptor"
+	"runtime/interrupt"
+)
+
+var (
+	ErrNotConnected = errors.New("hid: not connected")
+	ErrNotInput     = errors.New("hid: not an input report")
+	ErrBufferFull   = errors.New("hid: transmit buffer full")
+)
+
+// Number of input reports that can wait to be sent.
+const queueSize = 4
+
+// HID is a HID interface with custom reports.
+type HID struct {
+	iface uint8
+	inEp  uint32
+	ids   bool // reports start with their ID
+
+	reports []*Report
+
+	// Input reports being sent and waiting to be sent.
+	queue   [queueSize][usb.EndpointPacketSize]byte
+	lengths [queueSize]uint8
+	first   int
+	count   int
+
+	reportHandler func(*Report)
+}
+
+// New adds a HID interface with the reports of the applications to the USB
+// device built by b. New can be called more than once, for more than one
+// interface.
+func New(b *descriptor.Builder, apps ...descriptor.HIDApplication) (*HID, error) {
+	f, err := descriptor.HIDFunction(apps...)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Add(f); err != nil {
+		return nil, err
+	}
+
+	h := &HID{iface: f.FirstInterface}
+	for i := range apps {
+		if apps[i].ID != 0 {
+			h.ids = true
+		}
+		for _, typ := range []uint8{descriptor.HIDReportInput, descriptor.HIDReportOutput, descriptor.HIDReportFeature} {
+			if r := newReport(&apps[i], typ); r != nil {
+				h.reports = append(h.reports, r)
+			}
+		}
+	}
+
+	endpoints := f.Interfaces[0].Endpoints
+	h.inEp = uint32(endpoints[0].Number)
+	config := []usb.EndpointConfig{
+		{
+			Index:     uint8(h.inEp),
+			IsIn:      true,
+			Type:      usb.ENDPOINT_TYPE_INTERRUPT,
+			TxHandler: h.txHandler,
+		},
+	}
+	if len(endpoints) > 1 {
+		config = append(config, usb.EndpointConfig{
+			Index:     endpoints[1].Number,
+			IsIn:      false,
+			Type:      usb.ENDPOINT_TYPE_INTERRUPT,
+			RxHandler: h.rxHandler,
+		})
+	}
+	b.AddHandlers(config,
+		[]usb.SetupConfig{
+			{
+				Index:   h.iface,
+				Handler: h.setupHandler,
+			},
+		})
+	return h, nil
+}
+
+// Report returns the report of type typ with the ID id, or nil if there is no
+// such report. The ID is 0 for applications without ID.
+func (h *HID) Report(id, typ uint8) *Report {
+	for _, r := range h.reports {
+		if r.ID == id && r.Type == typ {
+			return r
+		}
+	}
+	return nil
+}
+
+// SetReportHandler sets the handler function for the output and feature
+// reports set by the host. It is called from the USB interrupt.
+//
+// Hosts send output reports on the interrupt OUT endpoint, and feature reports
+// with SET_REPORT requests.
+func (h *HID) SetReportHandler(reportHandler func(*Report)) {
+	h.reportHandler = reportHandler
+}
+
+// SendReport sends the input report r to the host, with the values it has
+// now. It doesn't block: while other reports are being sent, r waits in a
+// queue, and ErrBufferFull is returned when the queue is full.
+func (h *HID) SendReport(r *Report) error {
+	if r.Type != descriptor.HIDReportInput {
+		return ErrNotInput
+	}
+	if !machine.USBDev.InitEndpointComplete {
+		return ErrNotConnected
+	}
+	mask := interrupt.Disable()
+	defer interrupt.Restore(mask)
+	if h.count == queueSize {
+		return ErrBufferFull
+	}
+	i := (h.first + h.count) % queueSize
+	h.lengths[i] = uint8(copy(h.queue[i][:], r.data))
+	h.count++
+	if h.count == 1 {
+		h.send()
+	}
+	return nil
+}
+
+// send sends the first report in the queue.
+func (h *HID) send() {
+	machine.SendUSBInPacket(h.inEp, h.queue[h.first][:h.lengths[h.first]])
+}
+
+// setReport replaces the report of type typ with b, received from the host.
+func (h *HID) setReport(typ uint8, b []byte) bool {
+	id := uint8(0)
+	if h.ids && len(b) != 0 {
+		id = b[0]
+	}
+	r := h.Report(id, typ)
+	if r == nil || !r.set(b) {
+		return false
+	}
+	if h.reportHandler != nil {
+		h.reportHandler(r)
+	}
+	return true
+}
+
+// from InterruptIn
+func (h *HID) txHandler() {
+	if h.count == 0 {
+		return
+	}
+	h.first = (h.first + 1) % queueSize
+	h.count--
+	if h.count != 0 {
+		h.send()
+	}
+}
+
+// from InterruptOut
+func (h *HID) rxHandler(b []byte) {
+	h.setReport(descriptor.HIDReportOutput, b)
+}
+
+func (h *HID) setupHandler(setup usb.Setup) bool {
+	switch setup.BmRequestType {
+	case usb.REQUEST_DEVICETOHOST_CLASS_INTERFACE:
+		if setup.BRequest == usb.GET_REPORT {
+			r := h.Report(setup.WValueL, setup.WValueH)
+			if r == nil {
+				return false
+			}
+			data := r.data
+			if len(data) > int(setup.WLength) {
+				data = data[:setup.WLength]
+			}
+			machine.SendUSBInPacket(0, data)
+			return true
+		}
+	case usb.REQUEST_HOSTTODEVICE_CLASS_INTERFACE:
+		switch setup.BRequest {
+		case usb.SET_REPORT:
+			r := h.Report(setup.WValueL, setup.WValueH)
+			if r == nil || int(setup.WLength) != len(r.data) {
+				return false
+			}
+			b, err := machine.ReceiveUSBControlPacket()
+			if err != nil || !h.setReport(r.Type, b[:setup.WLength]) {
+				return false
+			}
+			machine.SendZlp()
+			return true
+		case usb.SET_IDLE:
+			machine.SendZlp()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+// package custom is for USB HID interfaces with reports declared by the
+// program, as descriptor.HIDApplication values. The report descriptor and the
+// report buffers are generated from these declarations.
+//
+// The interfaces are added with descriptor.Builder, so they can't be used with
+// the keyboard, mouse and joystick packages, which use a fixed descriptor.
+package custom
@@ -0,0 +1,128 @@
+package custom
+
+import (
+	"machine/usb/descriptor"
+)
+
+// Report is the buffer of a HID report: its ID, if any, followed by the values
+// of its fields.
+type Report struct {
+	Type uint8 // descriptor.HIDReportInput, HIDReportOutput or HIDReportFeature
+	ID   uint8
+
+	data   []byte
+	fields []field
+}
+
+// field is the position of a field in the report data, after the ID.
+type field struct {
+	offset int // in bits
+	size   uint8
+	count  uint8
+	signed bool
+}
+
+// newReport returns the report of type typ of a, or nil if a doesn't have one.
+func newReport(a *descriptor.HIDApplication, typ uint8) *Report {
+	length := a.ReportLength(typ)
+	if length == 0 {
+		return nil
+	}
+	r := &Report{
+		Type:   typ,
+		ID:     a.ID,
+		data:   make([]byte, length),
+		fields: make([]field, len(a.Fields)),
+	}
+	if a.ID != 0 {
+		r.data[0] = a.ID
+	}
+
+	offset := 0
+	for i, f := range a.Fields {
+		if f.Report != typ {
+			continue
+		}
+		r.fields[i] = field{
+			offset: offset,
+			size:   f.Size,
+			count:  f.Count,
+			signed: f.LogicalMinimum < 0,
+		}
+		offset += int(f.Size) * int(f.Count)
+	}
+	return r
+}
+
+// Bytes returns the report as it is sent, including its ID.
+func (r *Report) Bytes() []byte {
+	return r.data
+}
+
+// values returns the report without its ID.
+func (r *Report) values() []byte {
+	if r.ID != 0 {
+		return r.data[1:]
+	}
+	return r.data
+}
+
+// Set sets value index of field, which is the index of the field in the
+// application. Values that don't fit in the field are truncated, and fields of
+// other reports are ignored.
+func (r *Report) Set(field, index int, value int32) {
+	if field >= len(r.fields) || index >= int(r.fields[field].count) {
+		return
+	}
+	f := r.fields[field]
+	b := r.values()
+	offset := f.offset + index*int(f.size)
+	for i := 0; i < int(f.size); i++ {
+		bit := offset + i
+		if value&(1<<i) != 0 {
+			b[bit/8] |= 1 << (bit % 8)
+		} else {
+			b[bit/8] &^= 1 << (bit % 8)
+		}
+	}
+}
+
+// Get returns value index of field, like Set. Fields with a negative logical
+// minimum are signed.
+func (r *Report) Get(field, index int) int32 {
+	if field >= len(r.fields) || index >= int(r.fields[field].count) {
+		return 0
+	}
+	f := r.fields[field]
+	b := r.values()
+	offset := f.offset + index*int(f.size)
+	var value int32
+	for i := 0; i < int(f.size); i++ {
+		bit := offset + i
+		if b[bit/8]&(1<<(bit%8)) != 0 {
+			value |= 1 << i
+		}
+	}
+	if f.signed && f.size < 32 && value&(1<<(f.size-1)) != 0 {
+		value -= 1 << f.size
+	}
+	return value
+}
+
+// Clear sets all values to 0.
+func (r *Report) Clear() {
+	b := r.values()
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// set replaces the report with b, which was received from the host. It returns
+// false if b isn't this report.
+func (r *Report) set(b []byte) bool {
+	if len(b) != len(r.data) || (r.ID != 0 && b[0] != r.ID) {
+		return false
+	}
+	copy(r.data, b)
+	return true
+}
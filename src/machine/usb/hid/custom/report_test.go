@@ -0,0 +1,83 @@
+package custom
+
+import (
+	"bytes"
+	"machine/usb/descriptor"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	gamepad := descriptor.HIDGamepad(1)
+	r := newReport(&gamepad, descriptor.HIDReportInput)
+	if r == nil || r.ID != 1 {
+		t.Fatal("no input report with ID 1")
+	}
+	if newReport(&gamepad, descriptor.HIDReportOutput) != nil {
+		t.Error("gamepad has an output report")
+	}
+
+	r.Set(0, 0, 1)   // button 1
+	r.Set(0, 15, 1)  // button 16
+	r.Set(1, 0, 3)   // hat switch
+	r.Set(3, 0, -1)  // X
+	r.Set(3, 5, 127) // Ry
+	expected := []byte{1, 0x01, 0x80, 0x03, 0xff, 0, 0, 0, 0, 0x7f}
+	if !bytes.Equal(r.Bytes(), expected) {
+		t.Errorf("report:\n%x\nexpected:\n%x", r.Bytes(), expected)
+	}
+	if v := r.Get(3, 0); v != -1 {
+		t.Errorf("X is %d, expected -1", v)
+	}
+	if v := r.Get(1, 0); v != 3 {
+		t.Errorf("hat switch is %d, expected 3", v)
+	}
+
+	// Values are truncated, and don't change the other fields.
+	r.Set(1, 0, 0x18)
+	r.Set(0, 15, 0)
+	r.Set(4, 0, 1) // no such field
+	expected = []byte{1, 0x01, 0x00, 0x08, 0xff, 0, 0, 0, 0, 0x7f}
+	if !bytes.Equal(r.Bytes(), expected) {
+		t.Errorf("report:\n%x\nexpected:\n%x", r.Bytes(), expected)
+	}
+
+	r.Clear()
+	if !bytes.Equal(r.Bytes(), []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("cleared report: %x", r.Bytes())
+	}
+}
+
+func TestReportSet(t *testing.T) {
+	app := descriptor.HIDApplication{
+		UsagePage: 0xFF00,
+		Usage:     0x01,
+		Fields: []descriptor.HIDField{
+			{Report: descriptor.HIDReportInput, Usages: []uint16{0x02}, LogicalMaximum: 255, Size: 8, Count: 8},
+			{Report: descriptor.HIDReportFeature, Usages: []uint16{0x03}, LogicalMaximum: 255, Size: 8, Count: 4},
+			{Report: descriptor.HIDReportFeature, Usages: []uint16{0x04}, LogicalMaximum: 1, Size: 1, Count: 1},
+		},
+	}
+	r := newReport(&app, descriptor.HIDReportFeature)
+	if len(r.Bytes()) != 5 {
+		t.Fatalf("feature report has length %d, expected 5", len(r.Bytes()))
+	}
+	if r.set([]byte{1, 2, 3, 4}) {
+		t.Error("report of the wrong length accepted")
+	}
+	if !r.set([]byte{1, 2, 3, 4, 1}) {
+		t.Fatal("report not accepted")
+	}
+	if r.Get(1, 3) != 4 || r.Get(2, 0) != 1 {
+		t.Errorf("unexpected values in %x", r.Bytes())
+	}
+
+	// With an ID, the first byte must match.
+	app.ID = 5
+	r = newReport(&app, descriptor.HIDReportFeature)
+	if r.set([]byte{4, 1, 2, 3, 4, 1}) {
+		t.Error("report with the wrong ID accepted")
+	}
+	if !r.set([]byte{5, 1, 2, 3, 4, 1}) || r.Get(1, 0) != 1 {
+		t.Errorf("report with ID not accepted")
+	}
+}
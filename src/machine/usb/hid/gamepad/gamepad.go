@@ -0,0 +1,91 @@
+//go:build sam || nrf52840 || rp2040
+
+// package gamepad is for USB gamepads with 16 buttons, a hat switch and 6
+// axes, which work without drivers on the usual hosts.
+package gamepad
+
+import (
+	"machine/usb/descriptor"
+	"machine/usb/hid/custom"
+)
+
+// Hat is the direction of the hat switch.
+type Hat uint8
+
+const (
+	HatUp Hat = iota
+	HatUpRight
+	HatRight
+	HatDownRight
+	HatDown
+	HatDownLeft
+	HatLeft
+	HatUpLeft
+	HatCentered
+)
+
+// Axis is an axis of the gamepad.
+type Axis uint8
+
+const (
+	X Axis = iota
+	Y
+	Z
+	Rz
+	Rx
+	Ry
+)
+
+// Fields of the input report.
+const (
+	fieldButtons = iota
+	fieldHat
+	fieldPadding
+	fieldAxes
+)
+
+// Gamepad is a USB gamepad.
+type Gamepad struct {
+	hid    *custom.HID
+	report *custom.Report
+}
+
+// New adds a gamepad interface to the USB device built by b.
+func New(b *descriptor.Builder) (*Gamepad, error) {
+	h, err := custom.New(b, descriptor.HIDGamepad(0))
+	if err != nil {
+		return nil, err
+	}
+	g := &Gamepad{
+		hid:    h,
+		report: h.Report(0, descriptor.HIDReportInput),
+	}
+	g.report.Set(fieldHat, 0, int32(HatCentered))
+	return g, nil
+}
+
+// Press presses button, from 0 to 15. The change is sent by Send.
+func (g *Gamepad) Press(button int) {
+	g.report.Set(fieldButtons, button, 1)
+}
+
+// Release releases button, from 0 to 15. The change is sent by Send.
+func (g *Gamepad) Release(button int) {
+	g.report.Set(fieldButtons, button, 0)
+}
+
+// SetHat sets the direction of the hat switch. The change is sent by Send.
+func (g *Gamepad) SetHat(direction Hat) {
+	g.report.Set(fieldHat, 0, int32(direction))
+}
+
+// SetAxis sets the position of axis, from -127 to 127. The change is sent by
+// Send.
+func (g *Gamepad) SetAxis(axis Axis, value int8) {
+	g.report.Set(fieldAxes, int(axis), int32(value))
+}
+
+// Send sends the state of the gamepad to the host. See custom.HID.SendReport.
+func (g *Gamepad) Send() error {
+	return g.hid.SendReport(g.report)
+}
@@ -0,0 +1,274 @@
+package msc
+
+// This file implements the bulk-only transport, see the "Universal Serial Bus
+// Mass Storage Class Bulk-Only Transport" specification. It doesn't depend on
+// the machine package, so that it can be tested on the host.
+
+import (
+	"io"
+)
+
+// BlockDevice is the storage presented to the host. It is implemented by
+// machine.BlockDevice, such as machine.Flash.
+type BlockDevice interface {
+	io.ReaderAt
+	io.WriterAt
+	Size() int64
+	EraseBlockSize() int64
+	EraseBlocks(start, len int64) error
+}
+
+const (
+	packetSize = 64  // size of the bulk endpoints
+	blockSize  = 512 // size of a SCSI logical block
+
+	cbwSignature = 0x43425355 // "USBC"
+	cbwLen       = 31
+	cswSignature = 0x53425355 // "USBS"
+	cswLen       = 13
+
+	cswPassed     = 0
+	cswFailed     = 1
+	cswPhaseError = 2
+)
+
+// Transport states.
+const (
+	stateCommand = iota // waiting for a command block wrapper (CBW)
+	stateDataIn         // sending data to the host
+	stateDataOut        // receiving data from the host
+	stateStatus         // sending the command status wrapper (CSW)
+)
+
+// MSC is a USB mass storage device with a single logical unit.
+type MSC struct {
+	dev      BlockDevice
+	send     func(b []byte) // sends a packet on the bulk IN endpoint
+	blocks   uint32         // number of logical blocks
+	readOnly bool
+	ejected  bool
+
+	// Transport state of the current command.
+	state       uint8
+	status      uint8
+	tag         uint32
+	hostLength  uint32 // transfer length expected by the host
+	dataLength  uint32 // part of the transfer that carries data
+	transferred uint32
+
+	// Block being read or written, or the response to other commands.
+	lba     uint32
+	reading bool
+	buf     [blockSize]byte
+	bufLen  int
+	bufPos  int
+	packet  [packetSize]byte
+
+	// Sense data of the last command.
+	senseKey uint8
+	asc      uint8
+
+	// Erase block being written.
+	cache      []byte
+	cacheIndex int64
+	cacheDirty bool
+}
+
+func newMSC(dev BlockDevice, send func(b []byte)) *MSC {
+	cacheSize := dev.EraseBlockSize()
+	if cacheSize < blockSize {
+		cacheSize = blockSize
+	}
+	return &MSC{
+		dev:        dev,
+		send:       send,
+		blocks:     uint32(dev.Size() / blockSize),
+		cache:      make([]byte, cacheSize),
+		cacheIndex: -1,
+	}
+}
+
+// SetReadOnly sets whether the host can write to the disk. The host only
+// notices the change after the disk has been ejected, or the next time it is
+// connected.
+func (m *MSC) SetReadOnly(readOnly bool) {
+	m.readOnly = readOnly
+}
+
+// Ejected returns whether the host has ejected the disk. This is a good time
+// to read the files written by the host. The disk is loaded again when the
+// host asks for it.
+func (m *MSC) Ejected() bool {
+	return m.ejected
+}
+
+// reset aborts the current command, on a bulk-only mass storage reset.
+func (m *MSC) reset() {
+	m.state = stateCommand
+	m.cacheIndex = -1
+	m.cacheDirty = false
+}
+
+// rxHandler is called for every packet received on the bulk OUT endpoint.
+func (m *MSC) rxHandler(b []byte) {
+	switch m.state {
+	case stateCommand:
+		m.receiveCommand(b)
+	case stateDataOut:
+		m.receiveData(b)
+	}
+}
+
+// txHandler is called when a packet has been sent on the bulk IN endpoint.
+func (m *MSC) txHandler() {
+	switch m.state {
+	case stateDataIn:
+		m.sendData()
+	case stateStatus:
+		m.state = stateCommand
+	}
+}
+
+// receiveCommand parses a CBW and runs its command.
+func (m *MSC) receiveCommand(b []byte) {
+	if len(b) != cbwLen || le32(b[0:]) != cbwSignature {
+		// Not a valid CBW, so it is ignored.
+		return
+	}
+	m.tag = le32(b[4:])
+	m.hostLength = le32(b[8:])
+	m.dataLength = 0
+	hostIn := b[12]&0x80 != 0
+	cbLen := int(b[14] & 0x1f)
+	if cbLen == 0 || cbLen > 16 {
+		m.status = cswPhaseError
+		m.sendStatus()
+		return
+	}
+	m.transferred = 0
+	m.status = cswPassed
+	m.bufLen, m.bufPos = 0, 0
+	m.reading = false
+
+	dataIn, dataOut := m.command(b[15 : 15+cbLen])
+
+	// Compare what the host and the device expect, see section 6.7 of the
+	// specification. If the host expects more data than the device has, the
+	// data is padded (or ignored) and reported in the residue.
+	switch {
+	case m.hostLength == 0:
+		if dataIn != 0 || dataOut != 0 {
+			m.status = cswPhaseError
+		}
+		m.sendStatus()
+	case hostIn:
+		if dataOut != 0 || dataIn > m.hostLength {
+			m.status = cswPhaseError
+			m.sendStatus()
+			return
+		}
+		m.dataLength = dataIn
+		m.state = stateDataIn
+		m.sendData()
+	default:
+		if dataIn != 0 || dataOut > m.hostLength {
+			m.status = cswPhaseError
+			m.sendStatus()
+			return
+		}
+		m.dataLength = dataOut
+		m.state = stateDataOut
+	}
+}
+
+// sendData sends the next packet of the data-in phase, or the CSW once all
+// data has been sent.
+func (m *MSC) sendData() {
+	n := m.hostLength - m.transferred
+	if n == 0 {
+		m.sendStatus()
+		return
+	}
+	if n > packetSize {
+		n = packetSize
+	}
+	p := m.packet[:n]
+	i := uint32(0)
+	for i < n && m.transferred+i < m.dataLength {
+		if m.bufPos == m.bufLen && !m.readBlock() {
+			m.dataLength = m.transferred + i
+			break
+		}
+		c := uint32(copy(p[i:], m.buf[m.bufPos:m.bufLen]))
+		if c > m.dataLength-m.transferred-i {
+			c = m.dataLength - m.transferred - i
+		}
+		m.bufPos += int(c)
+		i += c
+	}
+	for ; i < n; i++ {
+		p[i] = 0
+	}
+	m.transferred += n
+	m.send(p)
+}
+
+// receiveData handles a packet of the data-out phase, and sends the CSW once
+// all data has been received.
+func (m *MSC) receiveData(b []byte) {
+	if uint32(len(b)) > m.hostLength-m.transferred {
+		b = b[:m.hostLength-m.transferred]
+	}
+	for len(b) != 0 {
+		c := uint32(len(b))
+		if m.transferred < m.dataLength {
+			if c > m.dataLength-m.transferred {
+				c = m.dataLength - m.transferred
+			}
+			if c > uint32(blockSize-m.bufLen) {
+				c = uint32(blockSize - m.bufLen)
+			}
+			copy(m.buf[m.bufLen:], b[:c])
+			m.bufLen += int(c)
+			if m.bufLen == blockSize && !m.writeBlock() {
+				// Ignore the rest of the data.
+				m.dataLength = m.transferred + c
+			}
+		}
+		b = b[c:]
+		m.transferred += c
+	}
+	if m.transferred == m.hostLength {
+		if !m.flush() {
+			m.dataLength = 0
+		}
+		m.cacheIndex = -1
+		m.sendStatus()
+	}
+}
+
+// sendStatus sends the CSW of the current command.
+func (m *MSC) sendStatus() {
+	residue := m.hostLength - m.dataLength
+	if m.status == cswPhaseError {
+		residue = m.hostLength
+	}
+	p := m.packet[:cswLen]
+	putLE32(p[0:], cswSignature)
+	putLE32(p[4:], m.tag)
+	putLE32(p[8:], residue)
+	p[12] = m.status
+	m.state = stateStatus
+	m.send(p)
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
@@ -0,0 +1,8 @@
+// package msc is for USB Mass Storage Class devices. It presents a block
+// device, such as machine.Flash, to the host as a disk using the SCSI
+// transparent command set over the bulk-only transport (BOT).
+//
+// The disk is formatted by the host: once formatted, files can be copied to
+// and from it like with any USB stick. The program sees the raw blocks, so it
+// needs a filesystem implementation (such as FAT) to read these files.
+package msc
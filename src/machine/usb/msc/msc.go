@@ -0,0 +1,86 @@
+//go:build sam || nrf52840 || rp2040
+
+package msc
+
+import (
+	"machine"
+	"machine/usb"
+	"machine/usb/descriptor"
+)
+
+const (
+	mscEndpointIn  = usb.MSC_ENDPOINT_IN  // to PC
+	mscEndpointOut = usb.MSC_ENDPOINT_OUT // from PC
+
+	// Class specific requests.
+	mscGetMaxLUN = 0xfe
+	mscReset     = 0xff
+)
+
+var port *MSC
+
+// Response to mscGetMaxLUN: there is only one logical unit.
+var maxLUN = [1]byte{0}
+
+// New configures the USB device to present dev as a disk, next to the USB
+// serial port. The MSC interface and endpoints replace those of HID and MIDI,
+// so these can't be used at the same time. It must only be called once.
+//
+// Data written by the host is collected per erase block, so this needs as
+// much RAM as the erase block size of dev.
+func New(dev machine.BlockDevice) *MSC {
+	port = newMSC(dev, sendUSBPacket)
+	machine.ConfigureUSBEndpoint(descriptor.CDCMSC,
+		[]usb.EndpointConfig{
+			{
+				Index:     usb.MSC_ENDPOINT_OUT,
+				IsIn:      false,
+				Type:      usb.ENDPOINT_TYPE_BULK,
+				RxHandler: rxHandler,
+			},
+			{
+				Index:     usb.MSC_ENDPOINT_IN,
+				IsIn:      true,
+				Type:      usb.ENDPOINT_TYPE_BULK,
+				TxHandler: txHandler,
+			},
+		},
+		[]usb.SetupConfig{
+			{
+				Index:   usb.MSC_INTERFACE,
+				Handler: setupHandler,
+			},
+		})
+	return port
+}
+
+// sendUSBPacket sends a packet on the bulk IN endpoint.
+func sendUSBPacket(b []byte) {
+	machine.SendUSBInPacket(mscEndpointIn, b)
+}
+
+// from BulkOut
+func rxHandler(b []byte) {
+	port.rxHandler(b)
+}
+
+// from BulkIn
+func txHandler() {
+	port.txHandler()
+}
+
+func setupHandler(setup usb.Setup) bool {
+	if setup.BmRequestType&usb.REQUEST_TYPE != usb.REQUEST_CLASS {
+		return false
+	}
+	switch setup.BRequest {
+	case mscGetMaxLUN:
+		machine.SendUSBInPacket(0, maxLUN[:])
+		return true
+	case mscReset:
+		port.reset()
+		machine.SendZlp()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package msc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// ramDevice is a block device in RAM that behaves like flash: data can only be
+// written to erased memory.
+type ramDevice struct {
+	data           []byte
+	eraseBlockSize int64
+	erases         int
+}
+
+func newRAMDevice(size, eraseBlockSize int64) *ramDevice {
+	dev := &ramDevice{data: make([]byte, size), eraseBlockSize: eraseBlockSize}
+	for i := range dev.data {
+		dev.data[i] = 0xff
+	}
+	return dev
+}
+
+func (dev *ramDevice) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(dev.data)) {
+		return 0, errors.New("read past end")
+	}
+	return copy(p, dev.data[off:]), nil
+}
+
+func (dev *ramDevice) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(dev.data)) {
+		return 0, errors.New("write past end")
+	}
+	for i, b := range p {
+		if dev.data[off+int64(i)] != 0xff {
+			return 0, errors.New("write to memory that isn't erased")
+		}
+		dev.data[off+int64(i)] = b
+	}
+	return len(p), nil
+}
+
+func (dev *ramDevice) Size() int64           { return int64(len(dev.data)) }
+func (dev *ramDevice) EraseBlockSize() int64 { return dev.eraseBlockSize }
+
+func (dev *ramDevice) EraseBlocks(start, len int64) error {
+	for i := start * dev.eraseBlockSize; i < (start+len)*dev.eraseBlockSize; i++ {
+		dev.data[i] = 0xff
+	}
+	dev.erases++
+	return nil
+}
+
+// host sends commands to an MSC like a USB host would, and collects the
+// packets sent back.
+type host struct {
+	t       *testing.T
+	msc     *MSC
+	packets [][]byte
+	tag     uint32
+}
+
+func newHost(t *testing.T, dev BlockDevice) *host {
+	h := &host{t: t}
+	h.msc = newMSC(dev, func(b []byte) {
+		h.packets = append(h.packets, append([]byte(nil), b...))
+	})
+	return h
+}
+
+// cbw returns a command block wrapper.
+func (h *host) cbw(length uint32, in bool, cb ...byte) []byte {
+	h.tag++
+	b := make([]byte, cbwLen)
+	putLE32(b[0:], cbwSignature)
+	putLE32(b[4:], h.tag)
+	putLE32(b[8:], length)
+	if in {
+		b[12] = 0x80
+	}
+	b[14] = byte(len(cb))
+	copy(b[15:], cb)
+	return b
+}
+
+// receive acknowledges the packets sent by the MSC until it has sent the CSW,
+// and returns the data and the status.
+func (h *host) receive() (data []byte, residue uint32, status byte) {
+	h.t.Helper()
+	for i := 0; ; i++ {
+		if i > 10000 || len(h.packets) == 0 {
+			h.t.Fatal("no CSW")
+		}
+		p := h.packets[0]
+		h.packets = h.packets[1:]
+		h.msc.txHandler()
+		if len(p) == cswLen && le32(p) == cswSignature {
+			if tag := le32(p[4:]); tag != h.tag {
+				h.t.Errorf("CSW tag is %d, expected %d", tag, h.tag)
+			}
+			if h.msc.state != stateCommand {
+				h.t.Error("not waiting for a command after the CSW")
+			}
+			return data, le32(p[8:]), p[12]
+		}
+		if len(p) > packetSize {
+			h.t.Fatalf("packet of %d bytes", len(p))
+		}
+		data = append(data, p...)
+	}
+}
+
+// in runs a command that sends length bytes to the host.
+func (h *host) in(length uint32, cb ...byte) ([]byte, uint32, byte) {
+	h.t.Helper()
+	h.msc.rxHandler(h.cbw(length, true, cb...))
+	return h.receive()
+}
+
+// out runs a command that receives data from the host.
+func (h *host) out(data []byte, cb ...byte) (uint32, byte) {
+	h.t.Helper()
+	h.msc.rxHandler(h.cbw(uint32(len(data)), false, cb...))
+	for len(data) != 0 {
+		if len(h.packets) != 0 {
+			h.t.Fatal("packet sent during data-out phase")
+		}
+		n := len(data)
+		if n > packetSize {
+			n = packetSize
+		}
+		h.msc.rxHandler(data[:n])
+		data = data[n:]
+	}
+	_, residue, status := h.receive()
+	return residue, status
+}
+
+// sense returns the sense key and ASC of the previous command.
+func (h *host) sense() (key, asc byte) {
+	h.t.Helper()
+	data, _, status := h.in(18, scsiRequestSense, 0, 0, 0, 18, 0)
+	if status != cswPassed || len(data) != 18 {
+		h.t.Fatalf("REQUEST SENSE failed: status %d, %d bytes", status, len(data))
+	}
+	return data[2], data[12]
+}
+
+func rw10(op byte, lba uint32, count uint16) []byte {
+	cb := []byte{op, 0, 0, 0, 0, 0, 0, byte(count >> 8), byte(count), 0}
+	putBE32(cb[2:], lba)
+	return cb
+}
+
+func TestInquiry(t *testing.T) {
+	h := newHost(t, newRAMDevice(64*1024, 4096))
+	data, residue, status := h.in(36, scsiInquiry, 0, 0, 0, 36, 0)
+	if status != cswPassed || residue != 0 {
+		t.Fatalf("status %d, residue %d", status, residue)
+	}
+	if len(data) != 36 || data[1] != 0x80 || string(data[8:16]) != "TinyGo  " {
+		t.Errorf("unexpected inquiry data: %x", data)
+	}
+
+	// The allocation length limits the response.
+	data, residue, status = h.in(5, scsiInquiry, 0, 0, 0, 5, 0)
+	if status != cswPassed || residue != 0 || len(data) != 5 {
+		t.Errorf("status %d, residue %d, %d bytes", status, residue, len(data))
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	h := newHost(t, newRAMDevice(64*1024, 4096))
+	if _, _, status := h.in(0, scsiTestUnitReady, 0, 0, 0, 0, 0); status != cswPassed {
+		t.Errorf("TEST UNIT READY: status %d", status)
+	}
+	data, _, status := h.in(8, scsiReadCapacity10, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	if status != cswPassed || !bytes.Equal(data, []byte{0, 0, 0, 127, 0, 0, 2, 0}) {
+		t.Errorf("READ CAPACITY: status %d, data %x", status, data)
+	}
+
+	// MODE SENSE is padded to the length expected by the host.
+	data, residue, status := h.in(192, scsiModeSense6, 0, 0x3f, 0, 192, 0)
+	if status != cswPassed || residue != 188 || len(data) != 192 || data[2] != 0 {
+		t.Errorf("MODE SENSE: status %d, residue %d, data %x", status, residue, data[:4])
+	}
+	h.msc.SetReadOnly(true)
+	data, _, _ = h.in(4, scsiModeSense6, 0, 0x3f, 0, 4, 0)
+	if data[2] != 0x80 {
+		t.Error("MODE SENSE doesn't report the write protection")
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	dev := newRAMDevice(64*1024, 4096)
+	h := newHost(t, dev)
+
+	// Write blocks 6 to 9, which are in two erase blocks.
+	data := make([]byte, 4*blockSize)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	residue, status := h.out(data, rw10(scsiWrite10, 6, 4)...)
+	if status != cswPassed || residue != 0 {
+		t.Fatalf("WRITE: status %d, residue %d", status, residue)
+	}
+	if !bytes.Equal(dev.data[6*blockSize:10*blockSize], data) {
+		t.Error("data not written to the device")
+	}
+	if dev.erases != 2 {
+		t.Errorf("%d erases, expected 2", dev.erases)
+	}
+	for i := 0; i < 6*blockSize; i++ {
+		if dev.data[i] != 0xff {
+			t.Fatalf("byte %d outside the written blocks changed", i)
+		}
+	}
+
+	got, residue, status := h.in(3*blockSize, rw10(scsiRead10, 7, 3)...)
+	if status != cswPassed || residue != 0 || !bytes.Equal(got, data[blockSize:]) {
+		t.Errorf("READ: status %d, residue %d, data differs: %v", status, residue, !bytes.Equal(got, data[blockSize:]))
+	}
+
+	// Writing the same data again doesn't erase anything.
+	h.out(data, rw10(scsiWrite10, 6, 4)...)
+	if dev.erases != 2 {
+		t.Errorf("rewriting the same data caused %d erases", dev.erases-2)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h := newHost(t, newRAMDevice(64*1024, 4096))
+
+	// Unknown commands fail, and the data the host sends is ignored.
+	if residue, status := h.out(make([]byte, 100), 0xc5, 0, 0, 0, 0, 0); status != cswFailed || residue != 100 {
+		t.Errorf("unknown command: status %d, residue %d", status, residue)
+	}
+	if key, asc := h.sense(); key != senseIllegalRequest || asc != ascInvalidCommand {
+		t.Errorf("unknown command: sense %x/%x", key, asc)
+	}
+	if key, _ := h.sense(); key != senseNone {
+		t.Error("sense data not cleared by REQUEST SENSE")
+	}
+
+	// Reading past the end.
+	if _, residue, status := h.in(2*blockSize, rw10(scsiRead10, 127, 2)...); status != cswFailed || residue != 2*blockSize {
+		t.Errorf("read past end: status %d, residue %d", status, residue)
+	}
+	if key, asc := h.sense(); key != senseIllegalRequest || asc != ascLBAOutOfRange {
+		t.Errorf("read past end: sense %x/%x", key, asc)
+	}
+
+	// Writing to a read-only disk.
+	h.msc.SetReadOnly(true)
+	if _, status := h.out(make([]byte, blockSize), rw10(scsiWrite10, 0, 1)...); status != cswFailed {
+		t.Errorf("write to read-only disk: status %d", status)
+	}
+	if key, asc := h.sense(); key != senseDataProtect || asc != ascWriteProtected {
+		t.Errorf("write to read-only disk: sense %x/%x", key, asc)
+	}
+
+	// The host expects less data than the command reads.
+	if _, _, status := h.in(blockSize, rw10(scsiRead10, 0, 2)...); status != cswPhaseError {
+		t.Errorf("short data-in: status %d", status)
+	}
+
+	// Invalid CBWs are ignored.
+	h.msc.rxHandler([]byte("USBC"))
+	if len(h.packets) != 0 || h.msc.state != stateCommand {
+		t.Error("invalid CBW not ignored")
+	}
+}
+
+func TestEject(t *testing.T) {
+	h := newHost(t, newRAMDevice(64*1024, 4096))
+	if _, _, status := h.in(0, scsiStartStopUnit, 0, 0, 0, 0x02, 0); status != cswPassed {
+		t.Fatalf("START STOP UNIT: status %d", status)
+	}
+	if !h.msc.Ejected() {
+		t.Error("not ejected")
+	}
+	if _, _, status := h.in(0, scsiTestUnitReady, 0, 0, 0, 0, 0); status != cswFailed {
+		t.Errorf("TEST UNIT READY after eject: status %d", status)
+	}
+	if key, asc := h.sense(); key != senseNotReady || asc != ascMediumNotPresent {
+		t.Errorf("TEST UNIT READY after eject: sense %x/%x", key, asc)
+	}
+	h.in(0, scsiStartStopUnit, 0, 0, 0, 0x03, 0)
+	if h.msc.Ejected() {
+		t.Error("not loaded again")
+	}
+}
@@ -0,0 +1,263 @@
+package msc
+
+// This file implements the SCSI commands that hosts send to USB disks, see the
+// SCSI Primary Commands (SPC) and SCSI Block Commands (SBC) specifications.
+
+import (
+	"bytes"
+)
+
+// Operation codes.
+const (
+	scsiTestUnitReady        = 0x00
+	scsiRequestSense         = 0x03
+	scsiInquiry              = 0x12
+	scsiModeSense6           = 0x1a
+	scsiStartStopUnit        = 0x1b
+	scsiPreventAllowRemoval  = 0x1e
+	scsiReadFormatCapacities = 0x23
+	scsiReadCapacity10       = 0x25
+	scsiRead10               = 0x28
+	scsiWrite10              = 0x2a
+	scsiVerify10             = 0x2f
+	scsiSynchronizeCache10   = 0x35
+	scsiModeSense10          = 0x5a
+)
+
+// Sense keys and additional sense codes (ASC).
+const (
+	senseNone           = 0x0
+	senseNotReady       = 0x2
+	senseMediumError    = 0x3
+	senseIllegalRequest = 0x5
+	senseDataProtect    = 0x7
+
+	ascWriteError       = 0x0c
+	ascReadError        = 0x11
+	ascInvalidCommand   = 0x20
+	ascLBAOutOfRange    = 0x21
+	ascWriteProtected   = 0x27
+	ascMediumNotPresent = 0x3a
+)
+
+// command runs a SCSI command. It returns the number of bytes to send to the
+// host (dataIn) or to receive from the host (dataOut). The response of
+// commands other than READ is stored in buf.
+func (m *MSC) command(cb []byte) (dataIn, dataOut uint32) {
+	if cb[0] != scsiRequestSense {
+		m.setSense(senseNone, 0)
+	}
+	switch cb[0] {
+	case scsiTestUnitReady:
+		m.checkReady()
+	case scsiRequestSense:
+		// Fixed format sense data of the previous command.
+		b := m.response(18)
+		b[0] = 0x70 // current error
+		b[2] = m.senseKey
+		b[7] = 10 // additional sense length
+		b[12] = m.asc
+		m.setSense(senseNone, 0)
+		return m.truncate(uint32(cb[4])), 0
+	case scsiInquiry:
+		b := m.response(36)
+		b[1] = 0x80 // removable
+		b[2] = 0x04 // SPC-2
+		b[3] = 0x02 // response data format
+		b[4] = 36 - 5
+		copy(b[8:16], "TinyGo  ")
+		copy(b[16:32], "Mass Storage    ")
+		copy(b[32:36], "1.0 ")
+		return m.truncate(be16(cb[3:])), 0
+	case scsiModeSense6:
+		// Only the header, without block descriptors or pages.
+		b := m.response(4)
+		b[0] = 3 // mode data length
+		b[2] = m.writeProtect()
+		return m.truncate(uint32(cb[4])), 0
+	case scsiModeSense10:
+		b := m.response(8)
+		b[1] = 6 // mode data length
+		b[3] = m.writeProtect()
+		return m.truncate(be16(cb[7:])), 0
+	case scsiStartStopUnit:
+		if cb[4]&0x02 != 0 {
+			// Load (start) or eject (stop) the medium.
+			m.ejected = cb[4]&0x01 == 0
+		}
+	case scsiPreventAllowRemoval, scsiVerify10, scsiSynchronizeCache10:
+		// Nothing to do: the data is written at the end of each WRITE
+		// command.
+	case scsiReadFormatCapacities:
+		if !m.checkReady() {
+			break
+		}
+		b := m.response(12)
+		b[3] = 8 // capacity list length
+		putBE32(b[4:], m.blocks)
+		b[8] = 0x02 // formatted media
+		b[10] = blockSize >> 8
+		b[11] = blockSize & 0xff
+		return m.truncate(be16(cb[7:])), 0
+	case scsiReadCapacity10:
+		if !m.checkReady() {
+			break
+		}
+		b := m.response(8)
+		putBE32(b[0:], m.blocks-1)
+		putBE32(b[4:], blockSize)
+		return m.truncate(8), 0
+	case scsiRead10, scsiWrite10:
+		if !m.checkReady() {
+			break
+		}
+		lba, count := be32(cb[2:]), be16(cb[7:])
+		if uint64(lba)+uint64(count) > uint64(m.blocks) {
+			m.setSense(senseIllegalRequest, ascLBAOutOfRange)
+			break
+		}
+		m.lba = lba
+		if cb[0] == scsiRead10 {
+			m.reading = true
+			return count * blockSize, 0
+		}
+		if m.readOnly {
+			m.setSense(senseDataProtect, ascWriteProtected)
+			break
+		}
+		return 0, count * blockSize
+	default:
+		m.setSense(senseIllegalRequest, ascInvalidCommand)
+	}
+	return 0, 0
+}
+
+// setSense sets the sense data returned by the next REQUEST SENSE command. The
+// command fails, unless the sense key is senseNone.
+func (m *MSC) setSense(key, asc uint8) {
+	m.senseKey = key
+	m.asc = asc
+	if key != senseNone {
+		m.status = cswFailed
+	}
+}
+
+// checkReady fails the command if the medium has been ejected.
+func (m *MSC) checkReady() bool {
+	if m.ejected {
+		m.setSense(senseNotReady, ascMediumNotPresent)
+		return false
+	}
+	return true
+}
+
+// writeProtect returns the device-specific parameter of the mode parameter
+// header.
+func (m *MSC) writeProtect() byte {
+	if m.readOnly {
+		return 0x80
+	}
+	return 0
+}
+
+// response returns a zeroed response of n bytes in buf.
+func (m *MSC) response(n int) []byte {
+	b := m.buf[:n]
+	for i := range b {
+		b[i] = 0
+	}
+	m.bufLen = n
+	return b
+}
+
+// truncate limits the response to the allocation length of the command, and
+// returns its length.
+func (m *MSC) truncate(allocation uint32) uint32 {
+	if uint32(m.bufLen) > allocation {
+		m.bufLen = int(allocation)
+	}
+	return uint32(m.bufLen)
+}
+
+// readBlock reads the next block of a READ command into buf.
+func (m *MSC) readBlock() bool {
+	if !m.reading {
+		return false
+	}
+	_, err := m.dev.ReadAt(m.buf[:], int64(m.lba)*blockSize)
+	if err != nil {
+		m.setSense(senseMediumError, ascReadError)
+		m.reading = false
+		return false
+	}
+	m.lba++
+	m.bufPos, m.bufLen = 0, blockSize
+	return true
+}
+
+// writeBlock writes buf to the next block of a WRITE command. Flash memory has
+// to be erased before it is written, so blocks are collected in a copy of
+// their erase block, which is written back when the command writes to another
+// erase block, or at its end.
+func (m *MSC) writeBlock() bool {
+	offset := int64(m.lba) * blockSize
+	index := offset / int64(len(m.cache))
+	if index != m.cacheIndex {
+		if !m.flush() {
+			return false
+		}
+		m.cacheIndex = -1
+		_, err := m.dev.ReadAt(m.cache, index*int64(len(m.cache)))
+		if err != nil {
+			m.setSense(senseMediumError, ascWriteError)
+			return false
+		}
+		m.cacheIndex = index
+	}
+
+	// Blocks that don't change (which happens a lot with the FAT filesystem)
+	// don't cause the erase block to be written.
+	b := m.cache[offset-index*int64(len(m.cache)):][:blockSize]
+	if !bytes.Equal(b, m.buf[:]) {
+		copy(b, m.buf[:])
+		m.cacheDirty = true
+	}
+	m.lba++
+	m.bufLen = 0
+	return true
+}
+
+// flush writes the erase block collected by writeBlock to the device, if it
+// has changed.
+func (m *MSC) flush() bool {
+	if !m.cacheDirty {
+		return true
+	}
+	m.cacheDirty = false
+	eraseBlockSize := m.dev.EraseBlockSize()
+	start := m.cacheIndex * int64(len(m.cache))
+	err := m.dev.EraseBlocks(start/eraseBlockSize, int64(len(m.cache))/eraseBlockSize)
+	if err == nil {
+		_, err = m.dev.WriteAt(m.cache, start)
+	}
+	if err != nil {
+		m.setSense(senseMediumError, ascWriteError)
+		return false
+	}
+	return true
+}
+
+func be16(b []byte) uint32 {
+	return uint32(b[0])<<8 | uint32(b[1])
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
@@ -10,6 +10,7 @@ const (
 	DescriptorConfigHID
 	DescriptorConfigMIDI
 	DescriptorConfigJoystick
+	DescriptorConfigMSC
 )
 
 const (
@@ -60,11 +61,12 @@ const (
 	CONFIG_REMOTE_WAKEUP = 0x20
 
 	// Interface
-	NumberOfInterfaces = 3
+	NumberOfInterfaces = 8
 	CDC_ACM_INTERFACE  = 0 // CDC ACM
 	CDC_DATA_INTERFACE = 1 // CDC Data
 	CDC_FIRST_ENDPOINT = 1
 	HID_INTERFACE      = 2 // HID
+	MSC_INTERFACE      = 2 // Mass Storage, instead of HID
 
 	// Endpoint
 	CONTROL_ENDPOINT  = 0
@@ -75,6 +77,8 @@ const (
 	HID_ENDPOINT_OUT  = 5 // for Interrupt Out
 	MIDI_ENDPOINT_IN  = 6 // for Bulk In
 	MIDI_ENDPOINT_OUT = 7 // for Bulk Out
+	MSC_ENDPOINT_IN   = 6 // for Bulk In, instead of MIDI
+	MSC_ENDPOINT_OUT  = 7 // for Bulk Out, instead of MIDI
 	NumberOfEndpoints = 8
 
 	// bmRequestType
@@ -96,6 +100,14 @@ const (
 	REQUEST_DEVICETOHOST_CLASS_INTERFACE    = (REQUEST_DEVICETOHOST | REQUEST_CLASS | REQUEST_INTERFACE)
 	REQUEST_HOSTTODEVICE_CLASS_INTERFACE    = (REQUEST_HOSTTODEVICE | REQUEST_CLASS | REQUEST_INTERFACE)
 	REQUEST_DEVICETOHOST_STANDARD_INTERFACE = (REQUEST_DEVICETOHOST | REQUEST_STANDARD | REQUEST_INTERFACE)
+	REQUEST_DEVICETOHOST_VENDOR_DEVICE      = (REQUEST_DEVICETOHOST | REQUEST_VENDOR | REQUEST_DEVICE)
+
+	// Vendor requests for the descriptors of the BOS platform capabilities,
+	// and their index (in wIndex).
+	VENDOR_REQUEST_WEBUSB     = 0x01
+	VENDOR_REQUEST_MS_OS_20   = 0x02
+	WEBUSB_GET_URL            = 0x02
+	MS_OS_20_DESCRIPTOR_INDEX = 0x07
 )
 
 type Setup struct {
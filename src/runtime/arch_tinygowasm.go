@@ -8,9 +8,6 @@ import (
 
 const GOARCH = "wasm"
 
-// The bitness of the CPU (e.g. 8, 32, 64).
-const TargetBits = 32
-
 const deferExtraRegs = 0
 
 const callInstSize = 1 // unknown and irrelevant (llvm.returnaddress doesn't work), so make something up
@@ -27,35 +24,20 @@ const (
 	// See https://github.com/WebAssembly/multi-memory
 	wasmMemoryIndex = 0
 
-	// wasmPageSize is the size of a page in WebAssembly's 32-bit memory. This
-	// is also its only unit of change.
+	// wasmPageSize is the size of a page in WebAssembly memory (both 32-bit
+	// and 64-bit). This is also its only unit of change.
 	//
 	// See https://www.w3.org/TR/wasm-core-1/#page-size
 	wasmPageSize = 64 * 1024
 )
 
-// wasm_memory_size invokes the "memory.size" instruction, which returns the
-// current size to the memory at the given index (always wasmMemoryIndex), in
-// pages.
-//
-//export llvm.wasm.memory.size.i32
-func wasm_memory_size(index int32) int32
-
-// wasm_memory_grow invokes the "memory.grow" instruction, which attempts to
-// increase the size of the memory at the given index (always wasmMemoryIndex),
-// by the delta (in pages). This returns the previous size on success of -1 on
-// failure.
-//
-//export llvm.wasm.memory.grow.i32
-func wasm_memory_grow(index int32, delta int32) int32
-
 var (
 	// heapStart is the current memory offset which starts the heap. The heap
 	// extends from this offset until heapEnd (exclusive).
 	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
 
 	// heapEnd is the current memory length in bytes.
-	heapEnd = uintptr(wasm_memory_size(wasmMemoryIndex) * wasmPageSize)
+	heapEnd = wasmMemorySize()
 
 	globalsStart = uintptr(unsafe.Pointer(&globalsStartSymbol))
 	globalsEnd   = uintptr(unsafe.Pointer(&heapStartSymbol))
@@ -70,6 +52,14 @@ func align(ptr uintptr) uintptr {
 	return (ptr + heapAlign - 1) &^ (heapAlign - 1)
 }
 
+// wasmMemorySize returns the current size of the linear memory in bytes. The
+// page count is converted to uintptr first: with 32-bit memory, the size in
+// bytes doesn't fit in the int32 returned by wasm_memory_size once the memory
+// is 2GB or larger.
+func wasmMemorySize() uintptr {
+	return uintptr(wasm_memory_size(wasmMemoryIndex)) * wasmPageSize
+}
+
 //export tinygo_getCurrentStackPointer
 func getCurrentStackPointer() uintptr
 
@@ -84,7 +74,7 @@ func growHeap() bool {
 		return false
 	}
 
-	setHeapEnd(uintptr(wasm_memory_size(wasmMemoryIndex) * wasmPageSize))
+	setHeapEnd(wasmMemorySize())
 
 	// Heap has grown successfully.
 	return true
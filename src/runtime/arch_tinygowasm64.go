@@ -0,0 +1,27 @@
+//go:build tinygo.wasm64
+
+// The wasm64 target (-target=wasm64) uses the memory64 proposal. It is based
+// on wasm-unknown-host instead of WASI: wasi-libc can't be built for wasm64, and
+// the WASI preview 1 imports take i32 pointers, so no WASI runtime accepts a
+// module with a 64-bit memory. System calls therefore go through the
+// "tinygo_host" imports, which take i64 pointers on this target.
+
+package runtime
+
+// The bitness of the CPU (e.g. 8, 32, 64).
+const TargetBits = 64
+
+// wasm_memory_size invokes the "memory.size" instruction, which returns the
+// current size to the memory at the given index (always wasmMemoryIndex), in
+// pages. With the memory64 proposal, the size is a 64-bit value.
+//
+//export llvm.wasm.memory.size.i64
+func wasm_memory_size(index int32) int64
+
+// wasm_memory_grow invokes the "memory.grow" instruction, which attempts to
+// increase the size of the memory at the given index (always wasmMemoryIndex),
+// by the delta (in pages). This returns the previous size on success of -1 on
+// failure.
+//
+//export llvm.wasm.memory.grow.i64
+func wasm_memory_grow(index int32, delta int64) int64
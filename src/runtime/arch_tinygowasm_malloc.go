@@ -1,15 +1,32 @@
-//go:build tinygo.wasm && !(custommalloc || wasm_unknown)
+//go:build tinygo.wasm && !custommalloc && (!wasm_unknown || wasm_unknown_malloc)
 
 package runtime
 
-import "unsafe"
+import (
+	"internal/task"
+	"unsafe"
+)
 
 // The below functions override the default allocator of wasi-libc. This ensures
 // code linked from other languages can allocate memory without colliding with
 // our GC allocations.
+//
+// On wasm-unknown, these functions are only included with the
+// wasm_unknown_malloc build tag. The host can then use malloc and free to
+// allocate buffers that it passes to exported functions. Freed buffers are
+// only reclaimed by a garbage collector, which is why the wasm-unknown-host
+// target doesn't use the leaking GC of wasm-unknown.
 
 var allocs = make(map[uintptr][]byte)
 
+// allocsLock protects the allocs map with -scheduler=threads.
+//
+// Note that free may also be called by a thread that is exiting and that
+// doesn't run goroutine code anymore (wasi-libc frees the thread stack this
+// way). Such a thread isn't stopped by the GC, but deleting from the map
+// doesn't allocate so it can't interfere with the GC in a harmful way.
+var allocsLock task.PMutex
+
 //export malloc
 func libc_malloc(size uintptr) unsafe.Pointer {
 	if size == 0 {
@@ -17,7 +34,9 @@ func libc_malloc(size uintptr) unsafe.Pointer {
 	}
 	buf := make([]byte, size)
 	ptr := unsafe.Pointer(&buf[0])
+	allocsLock.Lock()
 	allocs[uintptr(ptr)] = buf
+	allocsLock.Unlock()
 	return ptr
 }
 
@@ -26,9 +45,13 @@ func libc_free(ptr unsafe.Pointer) {
 	if ptr == nil {
 		return
 	}
-	if _, ok := allocs[uintptr(ptr)]; ok {
+	allocsLock.Lock()
+	_, ok := allocs[uintptr(ptr)]
+	if ok {
 		delete(allocs, uintptr(ptr))
-	} else {
+	}
+	allocsLock.Unlock()
+	if !ok {
 		panic("free: invalid pointer")
 	}
 }
@@ -50,16 +73,19 @@ func libc_realloc(oldPtr unsafe.Pointer, size uintptr) unsafe.Pointer {
 	// it is theoretically possible. For now, just always allocate fresh.
 	buf := make([]byte, size)
 
+	allocsLock.Lock()
 	if oldPtr != nil {
 		if oldBuf, ok := allocs[uintptr(oldPtr)]; ok {
 			copy(buf, oldBuf)
 			delete(allocs, uintptr(oldPtr))
 		} else {
+			allocsLock.Unlock()
 			panic("realloc: invalid pointer")
 		}
 	}
 
 	ptr := unsafe.Pointer(&buf[0])
 	allocs[uintptr(ptr)] = buf
+	allocsLock.Unlock()
 	return ptr
 }
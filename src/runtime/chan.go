@@ -29,6 +29,11 @@ import (
 	"unsafe"
 )
 
+// chanLock protects the state of all channels when goroutines run in parallel
+// (with -scheduler=threads). A single lock is used for all channels, because a
+// select statement may need to modify multiple channels at once.
+var chanLock task.PMutex
+
 func chanDebug(ch *channel) {
 	if schedulerDebug {
 		if ch.bufSize > 0 {
@@ -184,7 +189,7 @@ func (ch *channel) resumeRX(ok bool) unsafe.Pointer {
 	}
 
 	// push task onto runqueue
-	runqueue.Push(b.t)
+	runqueuePushBack(b.t)
 
 	return dst
 }
@@ -211,7 +216,7 @@ func (ch *channel) resumeTX() unsafe.Pointer {
 	}
 
 	// push task onto runqueue
-	runqueue.Push(b.t)
+	runqueuePushBack(b.t)
 
 	return src
 }
@@ -451,16 +456,19 @@ type chanSelectState struct {
 // May panic if the channel is closed.
 func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList) {
 	i := interrupt.Disable()
+	chanLock.Lock()
 
 	if ch.trySend(value) {
 		// value immediately sent
 		chanDebug(ch)
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		return
 	}
 
 	if ch == nil {
 		// A nil channel blocks forever. Do not schedule this goroutine again.
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		deadlock()
 	}
@@ -475,6 +483,7 @@ func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	}
 	ch.blocked = blockedlist
 	chanDebug(ch)
+	chanLock.Unlock()
 	interrupt.Restore(i)
 	task.Pause()
 	sender.Ptr = nil
@@ -486,16 +495,19 @@ func chanSend(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 // Returns the comma-ok value.
 func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList) bool {
 	i := interrupt.Disable()
+	chanLock.Lock()
 
 	if rx, ok := ch.tryRecv(value); rx {
 		// value immediately available
 		chanDebug(ch)
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		return ok
 	}
 
 	if ch == nil {
 		// A nil channel blocks forever. Do not schedule this goroutine again.
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		deadlock()
 	}
@@ -510,6 +522,7 @@ func chanRecv(ch *channel, value unsafe.Pointer, blockedlist *channelBlockedList
 	}
 	ch.blocked = blockedlist
 	chanDebug(ch)
+	chanLock.Unlock()
 	interrupt.Restore(i)
 	task.Pause()
 	ok := receiver.Data == 1
@@ -525,9 +538,11 @@ func chanClose(ch *channel) {
 		runtimePanic("close of nil channel")
 	}
 	i := interrupt.Disable()
+	chanLock.Lock()
 	switch ch.state {
 	case chanStateClosed:
 		// Not allowed by the language spec.
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		runtimePanic("close of closed channel")
 	case chanStateSend:
@@ -535,6 +550,7 @@ func chanClose(ch *channel) {
 		// But when a goroutine tries to send while the channel is being closed,
 		// that is clearly invalid: the send should have been completed already
 		// before the close.
+		chanLock.Unlock()
 		interrupt.Restore(i)
 		runtimePanic("close channel during send")
 	case chanStateRecv:
@@ -547,6 +563,7 @@ func chanClose(ch *channel) {
 		// Easy case. No available sender or receiver.
 	}
 	ch.state = chanStateClosed
+	chanLock.Unlock()
 	interrupt.Restore(i)
 	chanDebug(ch)
 }
@@ -559,9 +576,11 @@ func chanClose(ch *channel) {
 // of picking the first one that can proceed.
 func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelBlockedList) (uintptr, bool) {
 	istate := interrupt.Disable()
+	chanLock.Lock()
 
-	if selected, ok := tryChanSelect(recvbuf, states); selected != ^uintptr(0) {
+	if selected, ok := trySelect(recvbuf, states); selected != ^uintptr(0) {
 		// one channel was immediately ready
+		chanLock.Unlock()
 		interrupt.Restore(istate)
 		return selected, ok
 	}
@@ -590,6 +609,7 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 			case chanStateRecv:
 				// already in correct state
 			default:
+				chanLock.Unlock()
 				interrupt.Restore(istate)
 				runtimePanic("invalid channel state")
 			}
@@ -603,6 +623,7 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 			case chanStateBuf:
 				// already in correct state
 			default:
+				chanLock.Unlock()
 				interrupt.Restore(istate)
 				runtimePanic("invalid channel state")
 			}
@@ -616,6 +637,7 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 	t.Data = 1
 
 	// wait for one case to fire
+	chanLock.Unlock()
 	interrupt.Restore(istate)
 	task.Pause()
 
@@ -625,6 +647,15 @@ func chanSelect(recvbuf unsafe.Pointer, states []chanSelectState, ops []channelB
 
 // tryChanSelect is like chanSelect, but it does a non-blocking select operation.
 func tryChanSelect(recvbuf unsafe.Pointer, states []chanSelectState) (uintptr, bool) {
+	chanLock.Lock()
+	selected, ok := trySelect(recvbuf, states)
+	chanLock.Unlock()
+	return selected, ok
+}
+
+// trySelect is the implementation of tryChanSelect, which is also used by
+// chanSelect. The caller must hold chanLock.
+func trySelect(recvbuf unsafe.Pointer, states []chanSelectState) (uintptr, bool) {
 	istate := interrupt.Disable()
 
 	// See whether we can receive from one of the channels.
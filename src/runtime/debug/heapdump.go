@@ -0,0 +1,28 @@
+package debug
+
+import (
+	"io"
+	_ "unsafe" // for go:linkname
+)
+
+// WriteHeap writes a snapshot of the heap to w, for offline analysis with the
+// "tinygo heapdump" command. It runs a garbage collection cycle first, so that
+// the dump only contains reachable objects.
+//
+// The dump contains all heap objects with their size and (with the precise GC)
+// pointer layout, and all references from globals and the stack into the heap.
+// To also record the location in the code where each object was allocated,
+// build the program with -tags=gc.allocsites. This uses one or two extra words
+// of memory for every heap block.
+//
+// The dump is first written to a buffer on the heap, which is as large as the
+// dump itself, and is then passed to w in a single Write call.
+//
+// This is a TinyGo extension, and is only supported by the conservative and
+// precise garbage collectors.
+func WriteHeap(w io.Writer) error {
+	return writeHeapDump(w)
+}
+
+//go:linkname writeHeapDump runtime.writeHeapDump
+func writeHeapDump(w io.Writer) error
@@ -0,0 +1,15 @@
+//go:build gc.allocsites && !(avr || tinygo.wasm || xtensa)
+
+package runtime
+
+// Record the allocation site of every heap object, so that it can be included
+// in heap dumps. Many objects are allocated by runtime functions (such as
+// sliceAppend) on behalf of user code, so the caller of the function that
+// called runtime.alloc is recorded as well. This costs two words of memory per
+// heap block, and relies on frame pointers (see compileopts.FramePointers).
+// Enable using -tags=gc.allocsites.
+const (
+	gcAllocSites   = true
+	gcAllocCallers = true
+	allocSiteWords = 2
+)
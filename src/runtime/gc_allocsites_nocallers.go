@@ -0,0 +1,13 @@
+//go:build gc.allocsites && (avr || tinygo.wasm || xtensa)
+
+package runtime
+
+// Record the allocation site of every heap object, like in gc_allocsites.go.
+// These architectures cannot find the return address of a caller, so only the
+// direct caller of runtime.alloc is recorded. This costs one word of memory per
+// heap block.
+const (
+	gcAllocSites   = true
+	gcAllocCallers = false
+	allocSiteWords = 1
+)
@@ -0,0 +1,9 @@
+//go:build !gc.allocsites
+
+package runtime
+
+const (
+	gcAllocSites   = false
+	gcAllocCallers = false
+	allocSiteWords = 0
+)
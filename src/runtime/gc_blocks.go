@@ -50,6 +50,7 @@ const (
 
 var (
 	metadataStart unsafe.Pointer // pointer to the start of the heap metadata
+	allocSites    unsafe.Pointer // allocation site of each block (only with gcAllocSites)
 	nextAlloc     gcBlock        // the next block that should be tried by the allocator
 	endBlock      gcBlock        // the block just past the end of the available space
 	gcTotalAlloc  uint64         // total number of bytes allocated
@@ -57,8 +58,18 @@ var (
 	gcMallocs     uint64         // total number of allocations
 	gcFrees       uint64         // total number of objects freed
 	gcFreedBlocks uint64         // total number of freed blocks
+	gcNumGC       uint32         // number of completed GC cycles
+	gcPauseTotal  uint64         // total time spent in the GC, in nanoseconds
+
+	gcPauseHist     [numPauseBuckets]uint64 // histogram of GC cycle durations
+	gcMallocsBySize [numSizeClasses]uint64  // number of allocations per size class
+	gcFreesBySize   [numSizeClasses]uint64  // number of freed objects per size class
 )
 
+// gcLock protects the heap when goroutines run in parallel (with
+// -scheduler=threads). It is held while allocating and during a GC cycle.
+var gcLock task.PMutex
+
 // zeroSizedAlloc is just a sentinel that gets returned when allocating 0 bytes.
 var zeroSizedAlloc uint8
 
@@ -189,6 +200,31 @@ func (b gcBlock) unmark() {
 	}
 }
 
+// allocSite returns the address of the code that allocated the object starting
+// at this block and the return address of its caller, or 0 if unknown. It
+// always returns 0 if allocation sites are not recorded.
+func (b gcBlock) allocSite() (site, caller uintptr) {
+	if !gcAllocSites {
+		return 0, 0
+	}
+	sites := (*[2]uintptr)(unsafe.Add(allocSites, uintptr(b)*allocSiteWords*unsafe.Sizeof(uintptr(0))))
+	site = sites[0]
+	if gcAllocCallers {
+		caller = sites[1]
+	}
+	return
+}
+
+// setAllocSite stores the allocation site of the object starting at this
+// block, and the return address of the caller of that code.
+func (b gcBlock) setAllocSite(site, caller uintptr) {
+	sites := (*[2]uintptr)(unsafe.Add(allocSites, uintptr(b)*allocSiteWords*unsafe.Sizeof(uintptr(0))))
+	sites[0] = site
+	if gcAllocCallers {
+		sites[1] = caller
+	}
+}
+
 func isOnHeap(ptr uintptr) bool {
 	return ptr >= heapStart && ptr < uintptr(metadataStart)
 }
@@ -214,8 +250,16 @@ func setHeapEnd(newHeapEnd uintptr) {
 	}
 
 	// Save some old variables we need later.
+	oldHeapEnd := heapEnd
 	oldMetadataStart := metadataStart
 	oldMetadataSize := heapEnd - uintptr(metadataStart)
+	oldAllocSites := allocSites
+	oldEndBlock := endBlock
+	if gcAllocSites {
+		// Only copy the block states, the allocation sites are copied
+		// separately below.
+		oldMetadataSize = uintptr(allocSites) - uintptr(metadataStart)
+	}
 
 	// Increase the heap. After setting the new heapEnd, calculateHeapAddresses
 	// will update metadataStart and the memcpy will copy the metadata to the
@@ -226,12 +270,15 @@ func setHeapEnd(newHeapEnd uintptr) {
 	heapEnd = newHeapEnd
 	calculateHeapAddresses()
 	memcpy(metadataStart, oldMetadataStart, oldMetadataSize)
+	if gcAllocSites {
+		memcpy(allocSites, oldAllocSites, uintptr(oldEndBlock)*allocSiteWords*unsafe.Sizeof(uintptr(0)))
+	}
 
 	// Note: the memcpy above assumes the heap grows enough so that the new
 	// metadata does not overlap the old metadata. If that isn't true, memmove
 	// should be used to avoid corruption.
 	// This assert checks whether that's true.
-	if gcAsserts && uintptr(metadataStart) < uintptr(oldMetadataStart)+oldMetadataSize {
+	if gcAsserts && uintptr(metadataStart) < oldHeapEnd {
 		runtimePanic("gc: heap did not grow enough at once")
 	}
 }
@@ -244,9 +291,21 @@ func setHeapEnd(newHeapEnd uintptr) {
 func calculateHeapAddresses() {
 	totalSize := heapEnd - heapStart
 
+	if gcAllocSites {
+		// Reserve allocSiteWords words per block at the end of the heap to
+		// store the allocation site of each object. This is a slight
+		// overestimation of the number of blocks, so that there is always
+		// enough space.
+		siteSize := allocSiteWords * unsafe.Sizeof(uintptr(0))
+		sitesSize := (totalSize/(bytesPerBlock+siteSize) + 1) * siteSize
+		sitesStart := (heapEnd - sitesSize) &^ (unsafe.Alignof(uintptr(0)) - 1)
+		allocSites = unsafe.Pointer(sitesStart)
+		totalSize = sitesStart - heapStart
+	}
+
 	// Allocate some memory to keep 2 bits of information about every block.
 	metadataSize := (totalSize + blocksPerStateByte*bytesPerBlock) / (1 + blocksPerStateByte*bytesPerBlock)
-	metadataStart = unsafe.Pointer(heapEnd - metadataSize)
+	metadataStart = unsafe.Pointer(heapStart + totalSize - metadataSize)
 
 	// Use the rest of the available memory as heap.
 	numBlocks := (uintptr(metadataStart) - heapStart) / bytesPerBlock
@@ -283,11 +342,14 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 		runtimePanicAt(returnAddress(0), "heap alloc in interrupt")
 	}
 
+	gcLock.Lock()
+
 	gcTotalAlloc += uint64(size)
 	gcMallocs++
 
 	neededBlocks := (size + (bytesPerBlock - 1)) / bytesPerBlock
 	gcTotalBlocks += uint64(neededBlocks)
+	gcMallocsBySize[sizeClass(neededBlocks)]++
 
 	// Continue looping until a run of free blocks has been found that fits the
 	// requested size.
@@ -321,6 +383,7 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 					// Unfortunately the heap could not be increased. This
 					// happens on baremetal systems for example (where all
 					// available RAM has already been dedicated to the heap).
+					gcLock.Unlock()
 					runtimePanicAt(returnAddress(0), "out of memory")
 				}
 			}
@@ -364,6 +427,15 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 			for i := thisAlloc + 1; i != nextAlloc; i++ {
 				i.setState(blockStateTail)
 			}
+			if gcAllocSites {
+				// The caller is often a runtime function such as
+				// sliceAppend, so record its caller as well.
+				var caller uintptr
+				if gcAllocCallers {
+					caller = uintptr(returnAddress(1))
+				}
+				thisAlloc.setAllocSite(uintptr(returnAddress(0)), caller)
+			}
 
 			// Return a pointer to this allocation.
 			pointer := thisAlloc.pointer()
@@ -377,6 +449,7 @@ func alloc(size uintptr, layout unsafe.Pointer) unsafe.Pointer {
 				size -= add
 			}
 			memzero(pointer, size)
+			gcLock.Unlock()
 			return pointer
 		}
 	}
@@ -410,16 +483,24 @@ func free(ptr unsafe.Pointer) {
 
 // GC performs a garbage collection cycle.
 func GC() {
+	gcLock.Lock()
 	runGC()
+	gcLock.Unlock()
 }
 
 // runGC performs a garbage collection cycle. It is the internal implementation
 // of the runtime.GC() function. The difference is that it returns the number of
-// free bytes in the heap after the GC is finished.
+// free bytes in the heap after the GC is finished. It must be called with
+// gcLock held.
 func runGC() (freeBytes uintptr) {
 	if gcDebug {
 		println("running collection cycle...")
 	}
+	start := nanotime()
+
+	// Make sure no other goroutine is modifying the heap while it is being
+	// marked and swept.
+	task.GCStopWorld()
 
 	// Mark phase: mark all reachable objects, recursively.
 	markStack()
@@ -460,14 +541,46 @@ func runGC() (freeBytes uintptr) {
 	// the next collection cycle.
 	freeBytes = sweep()
 
+	task.GCResumeWorld()
+
 	// Show how much has been sweeped, for debugging.
 	if gcDebug {
 		dumpHeap()
 	}
 
+	// Update GC statistics.
+	duration := uint64(nanotime() - start)
+	gcNumGC++
+	gcPauseTotal += duration
+	bucket := 0
+	for limit := uint64(1000); bucket < numPauseBuckets-1 && duration >= limit; limit *= 10 {
+		bucket++
+	}
+	gcPauseHist[bucket]++
+
 	return
 }
 
+// sizeClass returns the size class (as reported in MemStats.BySize) for an
+// object of the given number of blocks.
+func sizeClass(blocks uintptr) int {
+	class := 0
+	for class < numSizeClasses-1 && blocks > 1<<class {
+		class++
+	}
+	return class
+}
+
+// sizeClassSize returns the maximum size of an object in the given size class,
+// as reported in MemStats.BySize.
+func sizeClassSize(class int) uint32 {
+	if class == numSizeClasses-1 {
+		// The last size class contains all larger objects.
+		return ^uint32(0)
+	}
+	return uint32(bytesPerBlock) << class
+}
+
 // markRoots reads all pointers from start to end (exclusive) and if they look
 // like a heap pointer and are unmarked, marks them and scans that object as
 // well (recursively). The start and end parameters must be valid pointers and
@@ -600,6 +713,11 @@ func finishMark() {
 
 // mark a GC root at the address addr.
 func markRoot(addr, root uintptr) {
+	if heapDumper != nil {
+		// Not a real GC cycle: the roots are being written to a heap dump.
+		heapDumper.root(addr, root)
+		return
+	}
 	if isOnHeap(root) {
 		block := blockFromAddr(root)
 		if block.state() == blockStateFree {
@@ -623,20 +741,29 @@ func markRoot(addr, root uintptr) {
 func sweep() (freeBytes uintptr) {
 	freeCurrentObject := false
 	var freed uint64
+	var objectBlocks uintptr // size of the object that is currently being freed
 	for block := gcBlock(0); block < endBlock; block++ {
-		switch block.state() {
+		state := block.state()
+		if objectBlocks != 0 && (state != blockStateTail || !freeCurrentObject) {
+			// Reached the end of a freed object.
+			gcFreesBySize[sizeClass(objectBlocks)]++
+			objectBlocks = 0
+		}
+		switch state {
 		case blockStateHead:
 			// Unmarked head. Free it, including all tail blocks following it.
 			block.markFree()
 			freeCurrentObject = true
 			gcFrees++
 			freed++
+			objectBlocks = 1
 		case blockStateTail:
 			if freeCurrentObject {
 				// This is a tail object following an unmarked head.
 				// Free it now.
 				block.markFree()
 				freed++
+				objectBlocks++
 			}
 		case blockStateMark:
 			// This is a marked object. The next tail blocks must not be freed,
@@ -648,6 +775,9 @@ func sweep() (freeBytes uintptr) {
 			freeBytes += bytesPerBlock
 		}
 	}
+	if objectBlocks != 0 {
+		gcFreesBySize[sizeClass(objectBlocks)]++
+	}
 	gcFreedBlocks += freed
 	freeBytes += uintptr(freed) * bytesPerBlock
 	return
@@ -679,16 +809,32 @@ func dumpHeap() {
 // The returned memory statistics are up to date as of the
 // call to ReadMemStats. This would not do GC implicitly for you.
 func ReadMemStats(m *MemStats) {
+	gcLock.Lock()
 	m.HeapIdle = 0
 	m.HeapInuse = 0
+	m.HeapObjects = 0
+	var freeRun, largestFreeRun uintptr
 	for block := gcBlock(0); block < endBlock; block++ {
 		bstate := block.state()
 		if bstate == blockStateFree {
 			m.HeapIdle += uint64(bytesPerBlock)
+			freeRun++
+			if freeRun > largestFreeRun {
+				largestFreeRun = freeRun
+			}
 		} else {
 			m.HeapInuse += uint64(bytesPerBlock)
+			freeRun = 0
+			if bstate != blockStateTail {
+				m.HeapObjects++
+			}
 		}
 	}
+	m.HeapLargestFree = uint64(largestFreeRun) * uint64(bytesPerBlock)
+	m.HeapFragmentation = 0
+	if m.HeapIdle != 0 {
+		m.HeapFragmentation = uint32((m.HeapIdle - m.HeapLargestFree) * 100 / m.HeapIdle)
+	}
 	m.HeapReleased = 0 // always 0, we don't currently release memory back to the OS.
 	m.HeapSys = m.HeapInuse + m.HeapIdle
 	m.GCSys = uint64(heapEnd - uintptr(metadataStart))
@@ -698,6 +844,15 @@ func ReadMemStats(m *MemStats) {
 	m.Sys = uint64(heapEnd - heapStart)
 	m.HeapAlloc = (gcTotalBlocks - gcFreedBlocks) * uint64(bytesPerBlock)
 	m.Alloc = m.HeapAlloc
+	m.NumGC = gcNumGC
+	m.PauseTotalNs = gcPauseTotal
+	m.PauseHist = gcPauseHist
+	for i := range m.BySize {
+		m.BySize[i].Size = sizeClassSize(i)
+		m.BySize[i].Mallocs = gcMallocsBySize[i]
+		m.BySize[i].Frees = gcFreesBySize[i]
+	}
+	gcLock.Unlock()
 }
 
 func SetFinalizer(obj interface{}, finalizer interface{}) {
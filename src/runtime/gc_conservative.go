@@ -27,3 +27,10 @@ func (scanner *gcObjectScanner) pointerFree() bool {
 func (scanner gcObjectScanner) nextIsPointer(ptr, parent, addrOfWord uintptr) bool {
 	return isOnHeap(ptr)
 }
+
+// layout writes the layout of an object to a heap dump. The layout is not known
+// in the conservative GC.
+func (d *heapDumpState) layout(block gcBlock) {
+	d.word(0) // layout value
+	d.word(0) // number of bits
+}
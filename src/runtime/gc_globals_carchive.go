@@ -0,0 +1,30 @@
+//go:build (gc.conservative || gc.precise) && tinygo.carchive
+
+package runtime
+
+// This file implements findGlobals for static libraries, where there are no
+// linker-defined symbols for the start and end of the globals of the Go code.
+// Instead, the compiler creates a table of all globals that may contain
+// pointers (see transform.MakeGlobalsTable).
+
+import "unsafe"
+
+type globalsTableEntry struct {
+	start uintptr
+	size  uintptr
+}
+
+//go:extern runtime.globalsTable
+var globalsTable struct {
+	len     uintptr
+	globals [0]globalsTableEntry
+}
+
+// findGlobals finds all globals (which are reachable by definition) and calls
+// the callback for them.
+func findGlobals(found func(start, end uintptr)) {
+	globals := unsafe.Slice((*globalsTableEntry)(unsafe.Pointer(&globalsTable.globals)), globalsTable.len)
+	for _, global := range globals {
+		found(global.start, global.start+global.size)
+	}
+}
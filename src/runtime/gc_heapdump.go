@@ -0,0 +1,218 @@
+//go:build gc.conservative || gc.precise
+
+package runtime
+
+// This file implements heap dumps for the block-based GC. A heap dump is a
+// binary snapshot of the heap that can be analyzed offline with the
+// "tinygo heapdump" command, which uses the debug information of the
+// executable to attribute objects to types and allocation sites.
+//
+// The dump is a stream of bytes. All words are pointer-sized and stored in the
+// byte order of the target. It starts with a header:
+//
+//	[8]byte  magic: "TGHEAP\x00\x01"
+//	uint8    pointer size in bytes
+//	uint8    flags (see heapDumpFlag*)
+//	[2]uint8 reserved (zero)
+//	word     heap start address
+//	word     number of blocks in the heap
+//	word     number of bytes per block
+//
+// Then a number of records follows, each starting with a tag byte:
+//
+//	'S': block states, 2 bits per block as stored in the heap metadata
+//	     ((number of blocks + 3) / 4 bytes).
+//	'O': heap object
+//	       word  address of the object (the start of the first block)
+//	       word  size of the object in bytes (a multiple of the block size)
+//	       word  allocation site (return address of runtime.alloc), or 0
+//	       word  caller of the allocation site (only with heapDumpFlagAllocCallers)
+//	       word  raw layout value (precise GC only, otherwise 0)
+//	       word  number of bits in the layout bitmap (0 if unknown)
+//	       bytes layout bitmap, little endian (ceil(bits/8) bytes)
+//	'R': root reference
+//	       uint8 root kind (see heapDumpRoot*)
+//	       word  address of the root (0 if unknown)
+//	       word  address of the referenced object
+//	'E': end of the dump.
+
+import (
+	"internal/task"
+	"unsafe"
+)
+
+const (
+	heapDumpFlagBigEndian    = 1 << 0 // words are stored in big endian byte order
+	heapDumpFlagPrecise      = 1 << 1 // objects have a layout value
+	heapDumpFlagAllocSites   = 1 << 2 // allocation sites are recorded
+	heapDumpFlagAllocCallers = 1 << 3 // callers of allocation sites are recorded
+)
+
+const (
+	heapDumpRootGlobal = 0 // a pointer in a global variable
+	heapDumpRootStack  = 1 // a pointer on the stack, or a goroutine stack
+)
+
+// heapDumpWriter is the same as io.Writer, which cannot be imported in the
+// runtime.
+type heapDumpWriter interface {
+	Write([]byte) (int, error)
+}
+
+type heapDumpState struct {
+	bigEndian bool
+	rootKind  uint8
+	n         int    // number of bytes in the dump so far
+	buf       []byte // may be too small, in which case n > len(buf)
+}
+
+// heapDumper is set while the roots are written to a heap dump, see markRoot.
+// It is protected by gcLock.
+var heapDumper *heapDumpState
+
+// writeHeapDump writes a snapshot of the heap to w. It is called from
+// runtime/debug.WriteHeap.
+//
+// A GC cycle is run first, so that all objects in the dump are reachable. The
+// dump is written to a buffer that is allocated up front, so that the heap
+// doesn't change while it is being dumped. Only once the dump is complete is it
+// written to w. The buffer itself is included in the dump.
+func writeHeapDump(w heapDumpWriter) error {
+	GC()
+
+	d := &heapDumpState{}
+	for {
+		// Hold the heap lock and stop all other threads, so that the heap and
+		// the stacks don't change and no GC runs while dumping. This also
+		// makes sure markRoot calls of other collections don't end up in the
+		// dump.
+		gcLock.Lock()
+		task.GCStopWorld()
+		d.n = 0
+		d.dump()
+		task.GCResumeWorld()
+		gcLock.Unlock()
+
+		if d.n <= len(d.buf) {
+			break
+		}
+		// The buffer was too small. Allocate a new one with some slack, as
+		// allocating it may grow the heap (and therefore the dump).
+		d.buf = nil
+		d.buf = make([]byte, d.n+d.n/4)
+	}
+
+	_, err := w.Write(d.buf[:d.n])
+	return err
+}
+
+// dump writes the heap dump to d.buf. It must be called with gcLock held and
+// the world stopped, and must not allocate.
+func (d *heapDumpState) dump() {
+	// Write the header.
+	d.string("TGHEAP\x00\x01")
+	var flags uint8
+	one := uint16(1)
+	d.bigEndian = *(*uint8)(unsafe.Pointer(&one)) == 0
+	if d.bigEndian {
+		flags |= heapDumpFlagBigEndian
+	}
+	if preciseHeap {
+		flags |= heapDumpFlagPrecise
+	}
+	if gcAllocSites {
+		flags |= heapDumpFlagAllocSites
+	}
+	if gcAllocCallers {
+		flags |= heapDumpFlagAllocCallers
+	}
+	d.byte(uint8(unsafe.Sizeof(uintptr(0))))
+	d.byte(flags)
+	d.byte(0) // reserved
+	d.byte(0)
+	d.word(heapStart)
+	d.word(uintptr(endBlock))
+	d.word(bytesPerBlock)
+
+	// Write the raw block states.
+	d.byte('S')
+	d.bytes(unsafe.Slice((*byte)(metadataStart), (uintptr(endBlock)+blocksPerStateByte-1)/blocksPerStateByte))
+
+	// Write all objects.
+	for block := gcBlock(0); block < endBlock; block++ {
+		if block.state() != blockStateHead {
+			continue
+		}
+		d.object(block)
+	}
+
+	// Write all roots. This works just like the mark phase of the GC, except
+	// that markRoot redirects all roots to the heap dump.
+	d.rootKind = heapDumpRootStack
+	heapDumper = d
+	markStack()
+	d.rootKind = heapDumpRootGlobal
+	findGlobals(markRoots)
+	heapDumper = nil
+
+	d.byte('E')
+}
+
+// object writes a single object record for the object starting at block.
+func (d *heapDumpState) object(block gcBlock) {
+	d.byte('O')
+	d.word(block.address())
+	d.word(block.findNext().address() - block.address())
+	site, caller := block.allocSite()
+	d.word(site)
+	if gcAllocCallers {
+		d.word(caller)
+	}
+	d.layout(block)
+}
+
+// root writes a root record if root points to a heap object. It is called from
+// markRoot while writing a heap dump.
+func (d *heapDumpState) root(addr, root uintptr) {
+	if !isOnHeap(root) {
+		return
+	}
+	block := blockFromAddr(root)
+	if block.state() == blockStateFree {
+		return
+	}
+
+	d.byte('R')
+	d.byte(d.rootKind)
+	d.word(addr)
+	d.word(block.findHead().address())
+}
+
+func (d *heapDumpState) word(value uintptr) {
+	for i := uintptr(0); i < unsafe.Sizeof(value); i++ {
+		shift := i * 8
+		if d.bigEndian {
+			shift = (unsafe.Sizeof(value) - 1 - i) * 8
+		}
+		d.byte(uint8(value >> shift))
+	}
+}
+
+func (d *heapDumpState) byte(value uint8) {
+	if d.n < len(d.buf) {
+		d.buf[d.n] = value
+	}
+	d.n++
+}
+
+func (d *heapDumpState) bytes(data []byte) {
+	for _, c := range data {
+		d.byte(c)
+	}
+}
+
+func (d *heapDumpState) string(s string) {
+	for i := 0; i < len(s); i++ {
+		d.byte(s[i])
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !(gc.conservative || gc.precise)
+
+package runtime
+
+// heapDumpWriter is the same as io.Writer, which cannot be imported in the
+// runtime.
+type heapDumpWriter interface {
+	Write([]byte) (int, error)
+}
+
+type heapDumpUnsupportedError struct{}
+
+func (heapDumpUnsupportedError) Error() string {
+	return "heap dumps are not supported by this garbage collector"
+}
+
+// writeHeapDump is only implemented for the block-based GCs.
+func writeHeapDump(w heapDumpWriter) error {
+	return heapDumpUnsupportedError{}
+}
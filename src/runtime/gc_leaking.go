@@ -82,6 +82,11 @@ func ReadMemStats(m *MemStats) {
 	// no free -- current in use heap is the total allocated
 	m.HeapAlloc = gcTotalAlloc
 	m.Alloc = m.HeapAlloc
+	m.HeapObjects = gcMallocs
+	// The remaining heap is a single contiguous range, so there is no
+	// fragmentation.
+	m.HeapLargestFree = uint64(heapEnd - heapptr)
+	m.HeapFragmentation = 0
 }
 
 func GC() {
@@ -6,6 +6,11 @@
 // non-pointer object) and have fewer false positives in a GC cycle. It does
 // however use a bit more RAM to store the layout of each object.
 //
+// Only the heap is scanned precisely everywhere. The stack is scanned precisely
+// on baremetal systems (see gc_stack_precise.go) and conservatively elsewhere.
+// Globals are always scanned conservatively: findGlobals only knows where the
+// globals start and end, not which words in them are pointers.
+//
 // The pointer/non-pointer information for objects is stored in the first word
 // of the object. It is described below but in essence it contains a bitstring
 // of a particular size. This size does not indicate the size of the object:
@@ -59,11 +64,22 @@ import "unsafe"
 
 const preciseHeap = true
 
+// goroutineStackLayout is used as the object layout of goroutine stacks when
+// precise stack maps are in use (see gc_stack_precise.go). Only its address is
+// used.
+var goroutineStackLayout uint8
+
 type gcObjectScanner struct {
 	index      uintptr
 	size       uintptr
 	bitmap     uintptr
 	bitmapAddr unsafe.Pointer
+
+	// Goroutine stacks are not scanned using a layout, but by following the
+	// stack objects in the stack chain.
+	stack      bool
+	nextFrame  uintptr // address of the next stack object in the stack
+	frameStart uintptr // address of the first slot of the current stack object
 }
 
 func newGCObjectScanner(block gcBlock) gcObjectScanner {
@@ -72,6 +88,24 @@ func newGCObjectScanner(block gcBlock) gcObjectScanner {
 	}
 	scanner := gcObjectScanner{}
 	layout := *(*uintptr)(unsafe.Pointer(block.address()))
+	if preciseStacks && layout == uintptr(unsafe.Pointer(&goroutineStackLayout)) {
+		// This is a goroutine stack. The stack chain of a paused goroutine is
+		// stored in the word after the stack canary (the first word of the
+		// stack). Stack objects are linked from low to high addresses, so
+		// they will be found in order while scanning the stack.
+		scanner.stack = true
+		scanner.nextFrame = *(*uintptr)(unsafe.Pointer(block.address() + 2*unsafe.Sizeof(uintptr(0))))
+		return scanner
+	}
+	scanner.setLayout(layout)
+	return scanner
+}
+
+// setLayout decodes the given object layout value into the scanner.
+func (scanner *gcObjectScanner) setLayout(layout uintptr) {
+	scanner.index = 0
+	scanner.bitmap = 0
+	scanner.bitmapAddr = nil
 	if layout == 0 {
 		// Unknown layout. Assume all words in the object could be pointers.
 		// This layout value below corresponds to a slice of pointers like:
@@ -104,10 +138,13 @@ func newGCObjectScanner(block gcBlock) gcObjectScanner {
 		scanner.size = *(*uintptr)(layoutAddr)
 		scanner.bitmapAddr = unsafe.Add(layoutAddr, unsafe.Sizeof(uintptr(0)))
 	}
-	return scanner
 }
 
 func (scanner *gcObjectScanner) pointerFree() bool {
+	if scanner.stack {
+		// Goroutine stacks usually contain pointers.
+		return false
+	}
 	if scanner.bitmapAddr != nil {
 		// While the format allows for large objects without pointers, this is
 		// optimized by the compiler so if bitmapAddr is set, we know that there
@@ -119,6 +156,10 @@ func (scanner *gcObjectScanner) pointerFree() bool {
 }
 
 func (scanner *gcObjectScanner) nextIsPointer(word, parent, addrOfWord uintptr) bool {
+	if preciseStacks && scanner.stack {
+		return scanner.nextStackWordIsPointer(word, addrOfWord)
+	}
+
 	index := scanner.index
 	scanner.index++
 	if scanner.index == scanner.size {
@@ -131,6 +172,39 @@ func (scanner *gcObjectScanner) nextIsPointer(word, parent, addrOfWord uintptr)
 	}
 
 	// Might be a pointer. Now look at the object layout to know for sure.
+	return scanner.isPointer(index)
+}
+
+// nextStackWordIsPointer returns whether the word at the given address in a
+// goroutine stack is a pointer, according to the stack objects in this stack.
+func (scanner *gcObjectScanner) nextStackWordIsPointer(word, addrOfWord uintptr) bool {
+	if addrOfWord == scanner.nextFrame {
+		// Found the start of a stack object. It starts with a pointer to the
+		// parent object and the layout of the slots that follow.
+		scanner.nextFrame = word
+		scanner.setLayout(*(*uintptr)(unsafe.Pointer(addrOfWord + unsafe.Sizeof(uintptr(0)))))
+		scanner.frameStart = addrOfWord + 2*unsafe.Sizeof(uintptr(0))
+		return false
+	}
+	if scanner.frameStart == 0 || addrOfWord < scanner.frameStart {
+		// Not part of a stack object.
+		return false
+	}
+	offset := addrOfWord - scanner.frameStart
+	index := offset / unsafe.Sizeof(uintptr(0))
+	if index >= scanner.size || offset%unsafe.Sizeof(uintptr(0)) != 0 {
+		// Past the end of the stack object, or not aligned.
+		return false
+	}
+	if !isOnHeap(word) {
+		return false
+	}
+	return scanner.isPointer(index)
+}
+
+// isPointer returns whether the word at the given index may be a pointer
+// according to the layout.
+func (scanner *gcObjectScanner) isPointer(index uintptr) bool {
 	if scanner.bitmapAddr != nil {
 		if (*(*uint8)(unsafe.Add(scanner.bitmapAddr, index/8))>>(index%8))&1 == 0 {
 			return false
@@ -145,3 +219,25 @@ func (scanner *gcObjectScanner) nextIsPointer(word, parent, addrOfWord uintptr)
 	// Probably a pointer.
 	return true
 }
+
+// layout writes the layout of an object to a heap dump: the raw layout value
+// followed by the layout bitmap.
+func (d *heapDumpState) layout(block gcBlock) {
+	d.word(*(*uintptr)(block.pointer()))
+	scanner := newGCObjectScanner(block)
+	if scanner.stack {
+		// Goroutine stacks don't have a regular layout.
+		d.word(0)
+		return
+	}
+	d.word(scanner.size)
+	for i := uintptr(0); i < scanner.size; i += 8 {
+		var bits uint8
+		for j := uintptr(0); j < 8 && i+j < scanner.size; j++ {
+			if scanner.isPointer(i + j) {
+				bits |= 1 << j
+			}
+		}
+		d.byte(bits)
+	}
+}
@@ -8,7 +8,13 @@ import (
 	"unsafe"
 )
 
+const preciseStacks = false
+
+// stackChainStart is thread-local with -scheduler=threads, as every thread has
+// its own stack. It is a regular global otherwise.
+//
 //go:extern runtime.stackChainStart
+//go:threadlocal
 var stackChainStart *stackChainObject
 
 type stackChainObject struct {
@@ -48,6 +54,11 @@ func markStack() {
 	if task.OnSystemStack() {
 		markRoots(getCurrentStackPointer(), stackTop)
 	}
+
+	// With -scheduler=threads, every goroutine runs on the stack of its own
+	// thread. These stacks are not heap allocated, so scan them here. This is
+	// a no-op with other schedulers.
+	task.GCScanStacks(getCurrentStackPointer())
 }
 
 // trackPointer is a stub function call inserted by the compiler during IR
@@ -55,6 +66,16 @@ func markStack() {
 // code.
 func trackPointer(ptr, alloca unsafe.Pointer)
 
+// getStackChain returns the current stack chain, so that it can be restored
+// with setStackChain after a panic unwound the stack.
+func getStackChain() unsafe.Pointer {
+	return unsafe.Pointer(stackChainStart)
+}
+
+func setStackChain(chain unsafe.Pointer) {
+	stackChainStart = (*stackChainObject)(chain)
+}
+
 // swapStackChain swaps the stack chain.
 // This is called from internal/task when switching goroutines.
 func swapStackChain(dst **stackChainObject) {
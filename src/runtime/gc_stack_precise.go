@@ -0,0 +1,96 @@
+//go:build gc.precise && baremetal && !tinygo.wasm
+
+package runtime
+
+import (
+	"internal/task"
+	"runtime/volatile"
+	"unsafe"
+)
+
+// This file implements precise stack scanning using stack maps. The compiler
+// stores every pointer that is live across a call in a stack object, and moves
+// all allocas that may contain pointers into this stack object as well. Each
+// stack object starts with a header that links it to the stack object of the
+// calling function and describes which words in the stack object are pointers
+// (using the same layout format as heap objects, see gc_precise.go).
+//
+// Because all pointers on the stack can be found this way, the stack doesn't
+// need to be scanned conservatively:
+//
+//   - The current stack is scanned by following stackChainStart.
+//   - Goroutine stacks are heap allocations with a special layout. When the GC
+//     finds one, it follows the stack chain that is saved inside the stack
+//     (see internal/task).
+//   - While a goroutine is running, the stack chain of the system stack is
+//     saved in the goroutine stack instead.
+//
+// This only makes stack scanning precise. The other roots are still scanned
+// conservatively: the compiler doesn't emit layouts for globals, so findGlobals
+// (see gc_globals.go) reports the whole data and bss sections and a global that
+// looks like a pointer may keep a heap object alive.
+
+const preciseStacks = true
+
+//go:extern runtime.stackChainStart
+var stackChainStart *stackChainObject
+
+type stackChainObject struct {
+	parent *stackChainObject
+	layout uintptr
+}
+
+// systemStackChain points to the location where the stack chain of the system
+// stack is stored while a goroutine is running.
+var systemStackChain **stackChainObject
+
+// markStack marks all root pointers found on the current stack, and on the
+// system stack if currently running in a goroutine.
+func markStack() {
+	// Hack to force LLVM to consider stackChainStart to be live.
+	// Without this hack, loads and stores may be considered dead and objects on
+	// the stack might not be correctly tracked. With this volatile load, LLVM
+	// is forced to consider stackChainStart (and everything it points to) as
+	// live.
+	volatile.LoadUint32((*uint32)(unsafe.Pointer(&stackChainStart)))
+
+	markStackChain(stackChainStart)
+	if !task.OnSystemStack() {
+		markStackChain(*systemStackChain)
+	}
+}
+
+// markStackChain marks all pointers in the given stack object and all of its
+// parents.
+func markStackChain(chain *stackChainObject) {
+	for ; chain != nil; chain = chain.parent {
+		var scanner gcObjectScanner
+		scanner.setLayout(chain.layout)
+		start := uintptr(unsafe.Pointer(chain)) + unsafe.Sizeof(stackChainObject{})
+		for i := uintptr(0); i < scanner.size; i++ {
+			if !scanner.isPointer(i) {
+				continue
+			}
+			addr := start + i*unsafe.Sizeof(uintptr(0))
+			markRoot(addr, *(*uintptr)(unsafe.Pointer(addr)))
+		}
+	}
+}
+
+// trackPointer is a stub function call inserted by the compiler during IR
+// construction. Calls to it are later replaced with regular stack bookkeeping
+// code.
+func trackPointer(ptr, alloca unsafe.Pointer)
+
+// swapStackChain swaps the stack chain.
+// This is called from internal/task when switching goroutines.
+func swapStackChain(dst **stackChainObject) {
+	*dst, stackChainStart = stackChainStart, *dst
+	systemStackChain = dst
+}
+
+// allocGoroutineStack allocates a new goroutine stack. It is called from
+// internal/task.
+func allocGoroutineStack(size uintptr) unsafe.Pointer {
+	return alloc(size, unsafe.Pointer(&goroutineStackLayout))
+}
@@ -1,9 +1,11 @@
-//go:build (gc.conservative || gc.precise) && !tinygo.wasm
+//go:build (gc.conservative || (gc.precise && !baremetal)) && !tinygo.wasm
 
 package runtime
 
 import "internal/task"
 
+const preciseStacks = false
+
 // markStack marks all root pointers found on the stack.
 //
 // This implementation is conservative and relies on the stack top (provided by
@@ -0,0 +1,14 @@
+//go:build !(gc.conservative || gc.custom || gc.precise) && tinygo.wasm
+
+package runtime
+
+import "unsafe"
+
+// There is no stack chain when the GC doesn't need to scan the stack.
+
+func getStackChain() unsafe.Pointer {
+	return nil
+}
+
+func setStackChain(chain unsafe.Pointer) {
+}
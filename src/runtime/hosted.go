@@ -3,3 +3,6 @@
 package runtime
 
 const baremetal = false
+
+// Only baremetal targets can be built with -buildmode=c-archive.
+const carchive = false
@@ -1,32 +1,359 @@
-// Package metrics is a dummy package that is not yet implemented.
-
+// Package metrics provides a subset of the upstream runtime/metrics API.
+//
+// Only the metrics that TinyGo can provide are supported, see All for the
+// complete list. Metrics that are not supported are reported with KindBad, as
+// described in the upstream documentation.
 package metrics
 
-type Description struct{}
+import (
+	"math"
+	"runtime"
+	"unsafe"
+)
 
-func All() []Description {
-	return nil
+// Description describes a runtime metric.
+type Description struct {
+	// Name is the full name of the metric which includes the unit.
+	Name string
+
+	// Description is an English language sentence describing the metric.
+	Description string
+
+	// Kind is the kind of value for this metric.
+	Kind ValueKind
+
+	// Cumulative is whether or not the metric is cumulative.
+	Cumulative bool
 }
 
-type Float64Histogram struct{}
+// ValueKind is a tag for a metric Value which indicates its type.
+type ValueKind int
 
-type Sample struct{}
+const (
+	// KindBad indicates that the Value has no type and should not be used.
+	KindBad ValueKind = iota
 
-func Read(m []Sample) {}
+	// KindUint64 indicates that the type of the Value is a uint64.
+	KindUint64
 
-type Value struct{}
+	// KindFloat64 indicates that the type of the Value is a float64.
+	KindFloat64
 
-func (v Value) Float64() float64 {
-	return 0
+	// KindFloat64Histogram indicates that the type of the Value is a
+	// *Float64Histogram.
+	KindFloat64Histogram
+)
+
+// Float64Histogram represents a distribution of float64 values.
+type Float64Histogram struct {
+	// Counts contains the weights for each histogram bucket.
+	Counts []uint64
+
+	// Buckets contains the boundaries of the histogram buckets, in
+	// increasing order. Bucket i covers the range [Buckets[i],
+	// Buckets[i+1]), so len(Buckets) == len(Counts)+1.
+	Buckets []float64
 }
-func (v Value) Float64Histogram() *Float64Histogram {
-	return nil
+
+// Sample captures a single metric sample.
+type Sample struct {
+	// Name is the name of the metric sampled.
+	Name string
+
+	// Value is the value of the metric sample.
+	Value Value
 }
+
+// Value represents a metric value returned by the runtime.
+type Value struct {
+	kind    ValueKind
+	scalar  uint64
+	pointer unsafe.Pointer
+}
+
+// Kind returns the tag representing the kind of value this is.
 func (v Value) Kind() ValueKind {
-	return ValueKind{}
+	return v.kind
 }
+
+// Uint64 returns the internal uint64 value for the metric.
+//
+// If v.Kind() != KindUint64, this method panics.
 func (v Value) Uint64() uint64 {
-	return 0
+	if v.kind != KindUint64 {
+		panic("called Uint64 on non-uint64 metric value")
+	}
+	return v.scalar
+}
+
+// Float64 returns the internal float64 value for the metric.
+//
+// If v.Kind() != KindFloat64, this method panics.
+func (v Value) Float64() float64 {
+	if v.kind != KindFloat64 {
+		panic("called Float64 on non-float64 metric value")
+	}
+	return math.Float64frombits(v.scalar)
 }
 
-type ValueKind struct{}
+// Float64Histogram returns the internal *Float64Histogram value for the metric.
+//
+// The returned histogram may be reused by subsequent calls to Read with the
+// same Sample, so it must not be modified.
+//
+// If v.Kind() != KindFloat64Histogram, this method panics.
+func (v Value) Float64Histogram() *Float64Histogram {
+	if v.kind != KindFloat64Histogram {
+		panic("called Float64Histogram on non-Float64Histogram metric value")
+	}
+	return (*Float64Histogram)(v.pointer)
+}
+
+// metric describes a supported metric and how to read it from the memory
+// statistics.
+type metric struct {
+	Description
+	read func(ms *runtime.MemStats, v *Value)
+}
+
+var metrics = []metric{
+	{
+		Description: Description{
+			Name:        "/gc/cycles/total:gc-cycles",
+			Description: "Count of all completed GC cycles.",
+			Kind:        KindUint64,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(uint64(ms.NumGC))
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/allocs-by-size:bytes",
+			Description: "Distribution of heap allocations by approximate size.",
+			Kind:        KindFloat64Histogram,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			h := v.histogram(len(ms.BySize))
+			for i, class := range ms.BySize {
+				h.Counts[i] = class.Mallocs
+			}
+			setSizeBuckets(h, ms)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/allocs:bytes",
+			Description: "Cumulative sum of memory allocated to the heap by the application.",
+			Kind:        KindUint64,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.TotalAlloc)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/allocs:objects",
+			Description: "Cumulative count of heap allocations triggered by the application.",
+			Kind:        KindUint64,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.Mallocs)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/frees-by-size:bytes",
+			Description: "Distribution of freed heap allocations by approximate size.",
+			Kind:        KindFloat64Histogram,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			h := v.histogram(len(ms.BySize))
+			for i, class := range ms.BySize {
+				h.Counts[i] = class.Frees
+			}
+			setSizeBuckets(h, ms)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/frees:objects",
+			Description: "Cumulative count of heap allocations whose storage was freed by the garbage collector.",
+			Kind:        KindUint64,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.Frees)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/gc/heap/objects:objects",
+			Description: "Number of objects, live or unswept, occupying heap memory.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.HeapObjects)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/memory/classes/heap/free:bytes",
+			Description: "Memory that is completely free and eligible to be returned to the underlying system, but has not been.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.HeapIdle)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/memory/classes/heap/objects:bytes",
+			Description: "Memory occupied by live objects and dead objects that have not yet been marked free by the garbage collector.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.HeapAlloc)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/memory/classes/metadata/other:bytes",
+			Description: "Memory that is reserved for or used to hold runtime metadata.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.GCSys)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/memory/classes/total:bytes",
+			Description: "All memory mapped by the Go runtime into the current process as read-write.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.Sys)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/sched/pauses/total/gc:seconds",
+			Description: "Distribution of individual GC-related stop-the-world pause latencies.",
+			Kind:        KindFloat64Histogram,
+			Cumulative:  true,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			h := v.histogram(len(ms.PauseHist))
+			copy(h.Counts, ms.PauseHist[:])
+			// See the PauseHist documentation for the bucket boundaries.
+			h.Buckets[0] = 0
+			limit := 1e-6
+			for i := 1; i < len(h.Buckets)-1; i++ {
+				h.Buckets[i] = limit
+				limit *= 10
+			}
+			h.Buckets[len(h.Buckets)-1] = math.Inf(1)
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/tinygo/heap/fragmentation:percent",
+			Description: "Percentage of free heap memory that is not part of the largest contiguous free range.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(uint64(ms.HeapFragmentation))
+		},
+	},
+	{
+		Description: Description{
+			Name:        "/tinygo/heap/largest-free:bytes",
+			Description: "Size of the largest contiguous range of free heap memory.",
+			Kind:        KindUint64,
+		},
+		read: func(ms *runtime.MemStats, v *Value) {
+			v.setUint64(ms.HeapLargestFree)
+		},
+	},
+}
+
+// All returns a slice of containing metric descriptions for all supported
+// metrics.
+func All() []Description {
+	descriptions := make([]Description, len(metrics))
+	for i, m := range metrics {
+		descriptions[i] = m.Description
+	}
+	return descriptions
+}
+
+// Read populates each Value field in the given slice of metric samples.
+//
+// Desired metrics should be present in the slice with the appropriate name.
+// Metrics that are not supported will have a value of KindBad.
+//
+// All metrics are read from a single call to runtime.ReadMemStats, so they
+// are consistent with each other.
+func Read(m []Sample) {
+	if len(m) == 0 {
+		return
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	for i := range m {
+		sample := &m[i]
+		found := false
+		for _, metric := range metrics {
+			if metric.Name == sample.Name {
+				metric.read(&ms, &sample.Value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sample.Value = Value{}
+		}
+	}
+}
+
+func (v *Value) setUint64(n uint64) {
+	v.kind = KindUint64
+	v.scalar = n
+	v.pointer = nil
+}
+
+// histogram returns the histogram stored in the value, reusing the previous
+// one if possible.
+func (v *Value) histogram(buckets int) *Float64Histogram {
+	var h *Float64Histogram
+	if v.kind == KindFloat64Histogram {
+		h = (*Float64Histogram)(v.pointer)
+	}
+	if h == nil || len(h.Counts) != buckets {
+		h = &Float64Histogram{
+			Counts:  make([]uint64, buckets),
+			Buckets: make([]float64, buckets+1),
+		}
+	}
+	v.kind = KindFloat64Histogram
+	v.scalar = 0
+	v.pointer = unsafe.Pointer(h)
+	return h
+}
+
+// setSizeBuckets sets the bucket boundaries for a histogram based on the size
+// classes in MemStats.BySize. A size class includes its upper bound while a
+// bucket excludes it, so the boundaries are one past the size of each class.
+func setSizeBuckets(h *Float64Histogram, ms *runtime.MemStats) {
+	h.Buckets[0] = 0
+	for i, class := range ms.BySize[:len(ms.BySize)-1] {
+		h.Buckets[i+1] = float64(class.Size) + 1
+	}
+	h.Buckets[len(h.Buckets)-1] = math.Inf(1)
+}
@@ -2,8 +2,19 @@ package runtime
 
 // Memory statistics
 
-// Subset of memory statistics from upstream Go.
-// Works with conservative gc only.
+// Subset of memory statistics from upstream Go, with a few TinyGo-specific
+// additions that are useful on systems with a small and fixed-size heap.
+// Most statistics are only filled in by the block-based GCs (conservative and
+// precise).
+
+const (
+	// numSizeClasses is the number of size classes reported in
+	// MemStats.BySize.
+	numSizeClasses = 8
+
+	// numPauseBuckets is the number of buckets in MemStats.PauseHist.
+	numPauseBuckets = 8
+)
 
 // A MemStats records statistics about the memory allocator.
 type MemStats struct {
@@ -67,6 +78,69 @@ type MemStats struct {
 	// Frees is the cumulative count of heap objects freed.
 	Frees uint64
 
+	// HeapObjects is the number of allocated heap objects.
+	//
+	// Like HeapAlloc, this includes unreachable objects that have not yet
+	// been freed by the garbage collector.
+	HeapObjects uint64
+
+	// HeapLargestFree is the size in bytes of the largest contiguous range
+	// of free heap memory.
+	//
+	// This is TinyGo-specific. An allocation larger than this will trigger
+	// a garbage collection cycle, and will fail if the GC can't free enough
+	// memory and the heap can't grow.
+	HeapLargestFree uint64
+
+	// HeapFragmentation is the percentage (0-100) of idle heap memory that
+	// is not part of the largest free range.
+	//
+	// This is TinyGo-specific. A high value means that the free memory is
+	// spread out over many small ranges, so that large allocations may fail
+	// even though HeapIdle is large enough.
+	HeapFragmentation uint32
+
+	// Garbage collector statistics.
+
+	// PauseTotalNs is the cumulative nanoseconds spent in garbage
+	// collection cycles. TinyGo stops the world for the entire cycle, so
+	// this is the total time spent in the GC.
+	PauseTotalNs uint64
+
+	// NumGC is the number of completed GC cycles.
+	NumGC uint32
+
+	// PauseHist is a histogram of GC cycle durations.
+	//
+	// This is TinyGo-specific. PauseHist[0] counts cycles that took less
+	// than 1µs, and PauseHist[i] counts cycles that took at least 10^(i-1)µs
+	// and less than 10^iµs. The last bucket also counts all longer cycles.
+	PauseHist [numPauseBuckets]uint64
+
+	// BySize reports per-size class allocation statistics.
+	//
+	// BySize[N] gives statistics for allocations of size S where
+	// BySize[N-1].Size < S ≤ BySize[N].Size. The last size class also
+	// contains all larger allocations.
+	//
+	// Unlike upstream Go, TinyGo doesn't use size classes internally. These
+	// size classes are powers of two of the heap block size.
+	BySize [numSizeClasses]struct {
+		// Size is the maximum byte size of an object in this
+		// size class.
+		Size uint32
+
+		// Mallocs is the cumulative count of heap objects
+		// allocated in this size class. The cumulative bytes
+		// of allocation is Size*Mallocs. The number of live
+		// objects in this size class is Mallocs - Frees.
+		Mallocs uint64
+
+		// Frees is the cumulative count of heap objects freed
+		// in this size class.
+		Frees uint64
+	}
+
 	// Off-heap memory statistics.
 	//
 	// The following statistics measure runtime-internal
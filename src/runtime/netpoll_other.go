@@ -0,0 +1,8 @@
+//go:build !wasip2
+
+package runtime
+
+// There are no pollables to wait on outside of WASI Preview 2.
+func netpoll(timeout timeUnit, hasTimeout bool) bool {
+	return false
+}
@@ -0,0 +1,120 @@
+//go:build wasip2
+
+package runtime
+
+// This file integrates WASI pollables (returned by sockets, streams and name
+// lookups) with the scheduler. A goroutine that needs to wait for a pollable
+// is paused and added to pollWaiters. When there are no other runnable
+// goroutines, the scheduler calls netpoll which blocks in wasi:io/poll.poll
+// until at least one of the pollables is ready (or until the next sleeping
+// goroutine or timer needs to run).
+
+import (
+	"internal/cm"
+	"internal/task"
+	monotonicclock "internal/wasi/clocks/v0.2.0/monotonic-clock"
+	"internal/wasi/io/v0.2.0/poll"
+)
+
+type pollWaiter struct {
+	pollable poll.Pollable
+	task     *task.Task
+}
+
+var (
+	pollWaiters   []pollWaiter
+	pollPollables []poll.Pollable
+)
+
+// Wait until one of the given pollables is ready. Other goroutines will
+// continue to run in the meantime. The pollables are still owned by the caller.
+// The signature must match the declaration in internal/netdev, which waits for
+// data and for its deadline timer at the same time.
+//
+//go:linkname netdev_pollableWait internal/netdev.pollableWait
+func netdev_pollableWait(pollables []poll.Pollable) {
+	pollableWait(pollables)
+}
+
+//go:linkname wasihttp_pollableWait runtime/wasihttp.pollableWait
+func wasihttp_pollableWait(pollables []poll.Pollable) {
+	pollableWait(pollables)
+}
+
+func pollableWait(pollables []poll.Pollable) {
+	if !hasScheduler {
+		poll.Poll(cm.ToList(pollables))
+		return
+	}
+	for _, p := range pollables {
+		if p.Ready() {
+			return
+		}
+	}
+	t := task.Current()
+	for _, p := range pollables {
+		pollWaiters = append(pollWaiters, pollWaiter{
+			pollable: p,
+			task:     t,
+		})
+	}
+	task.Pause()
+}
+
+// Wait until at least one goroutine waiting on a pollable can be resumed, or
+// until the timeout expires (if hasTimeout is set). Goroutines with a ready
+// pollable are added to the runqueue. It returns false without waiting if
+// there are no goroutines waiting on a pollable.
+func netpoll(timeout timeUnit, hasTimeout bool) bool {
+	if len(pollWaiters) == 0 {
+		return false
+	}
+
+	pollables := pollPollables[:0]
+	for _, w := range pollWaiters {
+		pollables = append(pollables, w.pollable)
+	}
+	var timer poll.Pollable
+	if hasTimeout {
+		if timeout < 0 {
+			timeout = 0
+		}
+		timer = monotonicclock.SubscribeDuration(monotonicclock.Duration(timeout))
+		pollables = append(pollables, timer)
+	}
+	pollPollables = pollables
+
+	ready := poll.Poll(cm.ToList(pollables))
+	for _, index := range ready.Slice() {
+		if int(index) >= len(pollWaiters) || pollWaiters[index].task == nil {
+			continue
+		}
+		t := pollWaiters[index].task
+		scheduleLogTask("  pollable ready:", t)
+		runqueue.Push(t)
+
+		// A goroutine may wait on more than one pollable, but it must only
+		// be resumed once.
+		for i := range pollWaiters {
+			if pollWaiters[i].task == t {
+				pollWaiters[i].task = nil
+			}
+		}
+	}
+	if hasTimeout {
+		timer.ResourceDrop()
+	}
+
+	// Remove the goroutines that were resumed.
+	waiters := pollWaiters[:0]
+	for _, w := range pollWaiters {
+		if w.task != nil {
+			waiters = append(waiters, w)
+		}
+	}
+	for i := len(waiters); i < len(pollWaiters); i++ {
+		pollWaiters[i] = pollWaiter{}
+	}
+	pollWaiters = waiters
+	return true
+}
@@ -0,0 +1,26 @@
+//go:build tinygo.wasm
+
+package runtime
+
+import "unsafe"
+
+// Call fn(args) and return whether the call was interrupted by a panic. This
+// is implemented in asm_tinygowasm_eh.S using the exception handling proposal.
+//
+//export tinygo_try
+func tinygo_try(fn uintptr, args unsafe.Pointer) bool
+
+// wasmTry is used by the compiler instead of a setjmp-like checkpoint when
+// recover() is implemented using WebAssembly exceptions. It calls the wrapper
+// fn with the packed arguments, and returns true if the call panicked, after
+// which the caller runs its deferred functions.
+func wasmTry(fn uintptr, args unsafe.Pointer) bool {
+	chain := getStackChain()
+	if !tinygo_try(fn, args) {
+		return false
+	}
+	// Functions that were unwound didn't get a chance to remove themselves
+	// from the stack chain.
+	setStackChain(chain)
+	return true
+}
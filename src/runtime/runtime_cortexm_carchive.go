@@ -0,0 +1,140 @@
+//go:build cortexm && tinygo.carchive
+
+package runtime
+
+// This file implements the runtime for -buildmode=c-archive, where Go code is
+// linked as a static library into existing C firmware (for example, running on
+// Zephyr or FreeRTOS). The C firmware owns the chip: it must call tinygo_init
+// once before calling any exported Go function. Time, sleeping and console
+// output are provided by the firmware, which must implement the following
+// functions:
+//
+//	uint64_t tinygo_host_nanotime(void);  // monotonic time in nanoseconds
+//	void tinygo_host_sleep(uint64_t ns);  // sleep, for example using k_sleep or vTaskDelay
+//	void tinygo_host_putchar(char c);     // write a byte to the console
+//	void tinygo_host_abort(void);         // called on a fatal runtime error
+//
+// The runtime is not thread safe: Go code must only be called from a single
+// thread at a time.
+//
+// Goroutines are supported with -scheduler=tasks. They run inside tinygo_tick,
+// which the firmware must call periodically from an RTOS thread (for example
+// on every RTOS tick, but not from an interrupt handler). It runs all runnable
+// goroutines and returns the number of nanoseconds until a sleeping goroutine
+// needs to run again, or -1 if no goroutine is sleeping. tinygo_init and
+// exported functions run outside of a goroutine, so they may start goroutines
+// but must not block.
+
+import (
+	"unsafe"
+)
+
+type timeUnit int64
+
+const carchive = true
+
+// Time left until the next sleeping goroutine must be run, set by sleepTicks
+// when called from the scheduler.
+var schedulerTimeLeft timeUnit
+
+// The heap is provided by the C firmware in tinygo_init.
+var (
+	heapStart uintptr
+	heapEnd   uintptr
+)
+
+// Initialize the runtime with the given memory area as the Go heap, and run all
+// package initializers. The memory is typically allocated from the RTOS heap
+// (using k_malloc or pvPortMalloc, for example) and must remain valid for as
+// long as Go code is used.
+//
+//export tinygo_init
+func carchiveInit(heap unsafe.Pointer, size uintptr) {
+	heapStart = uintptr(heap)
+	heapEnd = heapStart + size
+	initHeap()
+	initAll()
+}
+
+// Run all goroutines that are ready to run, and return the time in nanoseconds
+// until this function must be called again. It returns -1 if no goroutine is
+// sleeping, or if goroutines are not supported (-scheduler=none).
+//
+//export tinygo_tick
+func carchiveTick() int64 {
+	if !hasScheduler {
+		return -1
+	}
+	schedulerTimeLeft = -1
+	scheduler(true)
+	if schedulerTimeLeft < 0 {
+		return -1
+	}
+	return ticksToNanoseconds(schedulerTimeLeft)
+}
+
+//export tinygo_host_nanotime
+func hostNanotime() uint64
+
+//export tinygo_host_sleep
+func hostSleep(ns uint64)
+
+//export tinygo_host_putchar
+func hostPutchar(c byte)
+
+//export tinygo_host_abort
+func hostAbort()
+
+func ticksToNanoseconds(ticks timeUnit) int64 {
+	return int64(ticks)
+}
+
+func nanosecondsToTicks(ns int64) timeUnit {
+	return timeUnit(ns)
+}
+
+func sleepTicks(d timeUnit) {
+	if hasScheduler {
+		// Called from the scheduler, which returns to tinygo_tick right after
+		// this call.
+		schedulerTimeLeft = d
+		return
+	}
+	hostSleep(uint64(d))
+}
+
+func ticks() timeUnit {
+	return timeUnit(hostNanotime())
+}
+
+func putchar(c byte) {
+	hostPutchar(c)
+}
+
+func getchar() byte {
+	// dummy, TODO
+	return 0
+}
+
+func buffered() int {
+	// dummy, TODO
+	return 0
+}
+
+func waitForEvents() {
+	// The scheduler returns to tinygo_tick instead of waiting for events, so
+	// this is never called.
+}
+
+func exit(code int) {
+	abort()
+}
+
+func abort() {
+	hostAbort()
+
+	// The host should not return from tinygo_host_abort, but make sure Go
+	// code doesn't continue running if it does.
+	for {
+	}
+}
@@ -6,9 +6,6 @@ const (
 	stdout = 1
 )
 
-func putchar(c byte) {
-}
-
 func getchar() byte {
 	// dummy, TODO
 	return 0
@@ -19,11 +16,6 @@ func buffered() int {
 	return 0
 }
 
-//go:linkname now time.now
-func now() (sec int64, nsec int32, mono int64) {
-	return 0, 0, 0
-}
-
 // Abort executes the wasm 'unreachable' instruction.
 func abort() {
 	trap()
@@ -43,7 +35,3 @@ func procPin() {
 //go:linkname procUnpin sync/atomic.runtime_procUnpin
 func procUnpin() {
 }
-
-func hardwareRand() (n uint64, ok bool) {
-	return 0, false
-}
@@ -0,0 +1,40 @@
+//go:build tinygo.wasm && js
+
+package runtime
+
+// Support for //go:wasmexport functions with parameters and results that are
+// marshalled by wasm_exec.js. See compiler/jsexport.go for details.
+
+import "unsafe"
+
+// Memory allocated by wasm_exec.js for the parameters and results of these
+// functions. Unlike memory allocated with malloc, it is scanned for pointers
+// by the GC, as it contains strings and slices.
+var jsExportAllocs map[uintptr][]unsafe.Pointer
+
+//export tinygo_jsexport_alloc
+func jsExportAlloc(size uintptr) unsafe.Pointer {
+	if size == 0 {
+		return nil
+	}
+	buf := make([]unsafe.Pointer, (size+unsafe.Sizeof(uintptr(0))-1)/unsafe.Sizeof(uintptr(0)))
+	ptr := unsafe.Pointer(&buf[0])
+	if jsExportAllocs == nil {
+		jsExportAllocs = make(map[uintptr][]unsafe.Pointer)
+	}
+	jsExportAllocs[uintptr(ptr)] = buf
+	return ptr
+}
+
+//export tinygo_jsexport_free
+func jsExportFree(ptr unsafe.Pointer) {
+	delete(jsExportAllocs, uintptr(ptr))
+}
+
+// Called by the wrapper of a //go:wasmexport function with an error result,
+// to store the message that is thrown in JavaScript.
+func jsExportError(err *error, message *string) {
+	if *err != nil {
+		*message = (*err).Error()
+	}
+}
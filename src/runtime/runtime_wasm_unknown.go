@@ -5,6 +5,29 @@ package runtime
 // TODO: this is essentially reactor mode wasm. So we might want to support
 // -buildmode=c-shared (and default to it).
 
+// By default, wasm-unknown doesn't import anything from the host. Each of the
+// following build tags adds imports from the "tinygo_host" module, so that a
+// host only needs to provide what the module uses. The wasm-unknown-host
+// target sets all of them.
+//
+//	wasm_unknown_log: log(ptr i32, len i32)
+//	    Write a line of output (for example from println or a panic), without
+//	    the trailing newline. Lines longer than the output buffer are split.
+//	    Without it, output is discarded.
+//	wasm_unknown_time: nanotime() i64, walltime() i64
+//	    Return a monotonic time in nanoseconds (used for time.Since and
+//	    time.Sleep), and the wall clock time in nanoseconds since the Unix
+//	    epoch (used for time.Now). Without it, the clock doesn't advance.
+//	wasm_unknown_random: random(ptr i32, len i32)
+//	    Fill the buffer with random bytes. Used for math/rand and to seed the
+//	    hash of maps. Without it, there is no source of randomness.
+//
+// Pointers are offsets in the exported memory of the module. On wasm64, the
+// ptr and len parameters are i64 instead of i32.
+//
+// The wasm_unknown_malloc build tag exports malloc and free, see
+// arch_tinygowasm_malloc.go.
+
 type timeUnit int64
 
 // libc constructors
@@ -27,12 +50,5 @@ func nanosecondsToTicks(ns int64) timeUnit {
 // with the wasm32-unknown-unknown target there is no way to determine any `precision`
 const timePrecisionNanoseconds = 1000
 
-func sleepTicks(d timeUnit) {
-}
-
-func ticks() timeUnit {
-	return timeUnit(0)
-}
-
 func beforeExit() {
 }
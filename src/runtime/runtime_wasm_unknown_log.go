@@ -0,0 +1,30 @@
+//go:build wasm_unknown && wasm_unknown_log
+
+package runtime
+
+import "unsafe"
+
+//go:wasmimport tinygo_host log
+func hostLog(ptr unsafe.Pointer, len uintptr)
+
+const putcharBufferSize = 120
+
+// Using global variables to avoid heap allocation.
+var (
+	putcharBuffer   = [putcharBufferSize]byte{}
+	putcharPosition uintptr
+)
+
+func putchar(c byte) {
+	if c == '\n' {
+		hostLog(unsafe.Pointer(&putcharBuffer[0]), putcharPosition)
+		putcharPosition = 0
+		return
+	}
+	putcharBuffer[putcharPosition] = c
+	putcharPosition++
+	if putcharPosition >= putcharBufferSize {
+		hostLog(unsafe.Pointer(&putcharBuffer[0]), putcharPosition)
+		putcharPosition = 0
+	}
+}
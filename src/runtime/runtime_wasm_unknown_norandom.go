@@ -0,0 +1,8 @@
+//go:build wasm_unknown && !wasm_unknown_random
+
+package runtime
+
+// Without the wasm_unknown_random build tag, there is no source of randomness.
+func hardwareRand() (n uint64, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,13 @@
+//go:build wasm_unknown && wasm_unknown_random
+
+package runtime
+
+import "unsafe"
+
+//go:wasmimport tinygo_host random
+func hostRandom(ptr unsafe.Pointer, len uintptr)
+
+func hardwareRand() (n uint64, ok bool) {
+	hostRandom(unsafe.Pointer(&n), unsafe.Sizeof(n))
+	return n, true
+}
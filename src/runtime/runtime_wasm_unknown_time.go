@@ -0,0 +1,30 @@
+//go:build wasm_unknown && wasm_unknown_time
+
+package runtime
+
+//go:wasmimport tinygo_host nanotime
+func hostNanotime() int64
+
+//go:wasmimport tinygo_host walltime
+func hostWalltime() int64
+
+//go:linkname now time.now
+func now() (sec int64, nsec int32, mono int64) {
+	wall := hostWalltime()
+	sec = wall / (1000 * 1000 * 1000)
+	nsec = int32(wall - sec*(1000*1000*1000))
+	mono = hostNanotime()
+	return
+}
+
+// There is no way to yield to the host, so this waits until the given time
+// has passed. Plugins usually shouldn't sleep at all.
+func sleepTicks(d timeUnit) {
+	end := ticks() + d
+	for ticks() < end {
+	}
+}
+
+func ticks() timeUnit {
+	return timeUnit(hostNanotime())
+}
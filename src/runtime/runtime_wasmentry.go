@@ -15,7 +15,7 @@ import (
 func wasmEntryCommand() {
 	// These need to be initialized early so that the heap can be initialized.
 	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
-	heapEnd = uintptr(wasm_memory_size(0) * wasmPageSize)
+	heapEnd = wasmMemorySize()
 	wasmExportState = wasmExportStateInMain
 	run()
 	wasmExportState = wasmExportStateExited
@@ -29,10 +29,10 @@ func wasmEntryReactor() {
 
 	// Initialize the heap.
 	heapStart = uintptr(unsafe.Pointer(&heapStartSymbol))
-	heapEnd = uintptr(wasm_memory_size(0) * wasmPageSize)
+	heapEnd = wasmMemorySize()
 	initHeap()
 
-	if hasScheduler {
+	if hasScheduler && !hasThreads {
 		// A package initializer might do funky stuff like start a goroutine and
 		// wait until it completes, so we have to run package initializers in a
 		// goroutine.
@@ -48,7 +48,9 @@ func wasmEntryReactor() {
 		}
 	} else {
 		// There are no goroutines (except for the main one, if you can call it
-		// that), so we can just run all the package initializers.
+		// that), or every goroutine runs in its own thread. Either way, we can
+		// just run all the package initializers.
+		task.Init(stackTop)
 		initAll()
 		wasmExportState = wasmExportStateReactor
 	}
@@ -1,3 +1,5 @@
+//go:build !scheduler.threads
+
 package runtime
 
 // This file implements the TinyGo scheduler. This scheduler is a very simple
@@ -18,8 +20,10 @@ import (
 const schedulerDebug = false
 
 // On JavaScript, we can't do a blocking sleep. Instead we have to return and
-// queue a new scheduler invocation using setTimeout.
-const asyncScheduler = GOOS == "js"
+// queue a new scheduler invocation using setTimeout. Similarly, with
+// -buildmode=c-archive the scheduler returns to the RTOS, which invokes it
+// again on the next tick.
+const asyncScheduler = GOOS == "js" || carchive
 
 var schedulerDone bool
 
@@ -199,6 +203,11 @@ func scheduler(returnAtDeadlock bool) {
 		t := runqueue.Pop()
 		if t == nil {
 			if sleepQueue == nil && timerQueue == nil {
+				if netpoll(0, false) {
+					// Some goroutines were waiting on I/O and at least one of
+					// them can now continue.
+					continue
+				}
 				if returnAtDeadlock {
 					return
 				}
@@ -230,6 +239,10 @@ func scheduler(returnAtDeadlock bool) {
 					println("---   timer waiting:", tim, tim.whenTicks())
 				}
 			}
+			if netpoll(timeLeft, true) {
+				// Waited for I/O and the deadline at the same time.
+				continue
+			}
 			sleepTicks(timeLeft)
 			if asyncScheduler {
 				// The sleepTicks function above only sets a timeout at which
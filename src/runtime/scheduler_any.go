@@ -1,4 +1,4 @@
-//go:build !scheduler.none
+//go:build !scheduler.none && !scheduler.threads
 
 package runtime
 
@@ -16,6 +16,18 @@ func sleep(duration int64) {
 	task.Pause()
 }
 
+// pauseTask pauses the current goroutine until it is resumed with resumeTask.
+// It is used by the machine package to wait for an interrupt.
+func pauseTask() {
+	task.Pause()
+}
+
+// resumeTask resumes a goroutine paused with pauseTask. It can be called from
+// an interrupt.
+func resumeTask(t *task.Task) {
+	runqueuePushBack(t)
+}
+
 // run is called by the program entry point to execute the go program.
 // With a scheduler, init and the main function are invoked in a goroutine before starting the scheduler.
 func run() {
@@ -29,3 +41,5 @@ func run() {
 }
 
 const hasScheduler = true
+
+const hasThreads = false
@@ -2,6 +2,8 @@
 
 package runtime
 
+import "internal/task"
+
 //go:linkname sleep time.Sleep
 func sleep(duration int64) {
 	if duration <= 0 {
@@ -11,6 +13,16 @@ func sleep(duration int64) {
 	sleepTicks(nanosecondsToTicks(duration))
 }
 
+// pauseTask waits for an interrupt, as there are no other goroutines to run.
+// The caller checks whether the interrupt it was waiting for has happened.
+func pauseTask() {
+	waitForEvents()
+}
+
+// resumeTask does nothing, as pauseTask returns after every interrupt.
+func resumeTask(t *task.Task) {
+}
+
 // getSystemStackPointer returns the current stack pointer of the system stack.
 // This is always the current stack pointer.
 func getSystemStackPointer() uintptr {
@@ -26,3 +38,5 @@ func run() {
 }
 
 const hasScheduler = false
+
+const hasThreads = false
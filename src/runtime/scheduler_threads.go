@@ -0,0 +1,197 @@
+//go:build scheduler.threads
+
+package runtime
+
+// This file implements the runtime side of the threads scheduler, where every
+// goroutine runs on its own thread. There is no run queue: goroutines are
+// paused and resumed directly, and the operating system decides which threads
+// run. Timers are handled by a separate goroutine that is started when the
+// first timer is added.
+
+import "internal/task"
+
+const schedulerDebug = false
+
+const hasScheduler = true
+
+// hasThreads is true when goroutines run in parallel, each in its own thread.
+const hasThreads = true
+
+// Timers, sorted by the time when they expire.
+var (
+	timerQueue   *timerNode
+	timerLock    task.PMutex
+	timerStarted bool
+
+	// timerFutex is incremented every time a timer is added or removed, to
+	// wake up the timer goroutine.
+	timerFutex task.Futex
+)
+
+// runqueue is not used with threads, but the GC refers to it on baremetal
+// systems.
+var runqueue task.Queue
+
+// Simple logging, for debugging.
+func scheduleLog(msg string) {
+	if schedulerDebug {
+		println("---", msg)
+	}
+}
+
+// Simple logging with a task pointer, for debugging.
+func scheduleLogTask(msg string, t *task.Task) {
+	if schedulerDebug {
+		println("---", msg, t)
+	}
+}
+
+// Simple logging with a channel and task pointer.
+func scheduleLogChan(msg string, ch *channel, t *task.Task) {
+	if schedulerDebug {
+		println("---", msg, ch, t)
+	}
+}
+
+// deadlock is called when a goroutine cannot proceed any more, but is in theory
+// not exited (so deferred calls won't run). With threads, the thread is simply
+// blocked forever.
+//
+//go:noinline
+func deadlock() {
+	task.Pause()
+	panic("unreachable")
+}
+
+// Goexit terminates the currently running goroutine. No other goroutines are affected.
+//
+// Unlike the main Go implementation, no deferred calls will be run.
+//
+//go:inline
+func Goexit() {
+	deadlock()
+}
+
+// Resume the given goroutine. It does not need to be paused already: in that
+// case, its next call to task.Pause returns immediately.
+func runqueuePushBack(t *task.Task) {
+	t.Resume()
+}
+
+// pauseTask pauses the current goroutine until it is resumed with resumeTask.
+// It is used by the machine package to wait for an interrupt.
+func pauseTask() {
+	task.Pause()
+}
+
+// resumeTask resumes a goroutine paused with pauseTask.
+func resumeTask(t *task.Task) {
+	t.Resume()
+}
+
+// Pause the current goroutine for a given time.
+//
+//go:linkname sleep time.Sleep
+func sleep(duration int64) {
+	if duration <= 0 {
+		return
+	}
+	task.Sleep(duration)
+}
+
+// Gosched yields the processor. Threads are scheduled by the operating system,
+// so this only gives the GC a chance to run.
+func Gosched() {
+	task.Sleep(0)
+}
+
+// addTimer adds the given timer node to the timer queue. It must not be in the
+// queue already.
+func addTimer(tim *timerNode) {
+	timerLock.Lock()
+	if !timerStarted {
+		timerStarted = true
+		go timerLoop()
+	}
+	q := &timerQueue
+	for ; *q != nil; q = &(*q).next {
+		if tim.whenTicks() < (*q).whenTicks() {
+			// this will finish earlier than the next - insert here
+			break
+		}
+	}
+	tim.next = *q
+	*q = tim
+	timerFutex.Add(1)
+	timerLock.Unlock()
+	timerFutex.Wake()
+}
+
+// removeTimer is the implementation of time.stopTimer. It removes a timer from
+// the timer queue, returning true if the timer is present in the timer queue.
+func removeTimer(tim *timer) bool {
+	removedTimer := false
+	timerLock.Lock()
+	for t := &timerQueue; *t != nil; t = &(*t).next {
+		if (*t).timer == tim {
+			*t = (*t).next
+			removedTimer = true
+			break
+		}
+	}
+	if removedTimer {
+		timerFutex.Add(1)
+	}
+	timerLock.Unlock()
+	if removedTimer {
+		timerFutex.Wake()
+	}
+	return removedTimer
+}
+
+// timerLoop runs in its own goroutine, and calls the callback of every timer
+// when it expires.
+func timerLoop() {
+	for {
+		timerLock.Lock()
+		seq := timerFutex.Load()
+		tn := timerQueue
+		if tn == nil {
+			// Wait until a timer is added.
+			timerLock.Unlock()
+			timerFutex.Wait(seq)
+			continue
+		}
+		now := ticks()
+		when := tn.whenTicks()
+		if now < when {
+			// Wait until the first timer expires, or the timer queue changes.
+			timerLock.Unlock()
+			timerFutex.WaitUntil(seq, uint64(ticksToNanoseconds(when-now)))
+			continue
+		}
+
+		// Pop timer from queue, and run the callback stored in it.
+		timerQueue = tn.next
+		tn.next = nil
+		timerLock.Unlock()
+		scheduleLog("--- timer awoke")
+		tn.callback(tn, ticksToNanoseconds(now-when))
+	}
+}
+
+// scheduler is only used with a cooperative scheduler, for //go:wasmexport
+// functions and reactors. With threads, these run directly on the calling
+// thread instead.
+func scheduler(returnAtDeadlock bool) {
+	runtimePanic("no scheduler loop with -scheduler=threads")
+}
+
+// run is called by the program entry point to execute the go program.
+// The main goroutine runs on the main thread.
+func run() {
+	initHeap()
+	task.Init(stackTop)
+	initAll()
+	callMain()
+}
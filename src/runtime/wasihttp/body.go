@@ -0,0 +1,207 @@
+//go:build wasip2
+
+package wasihttp
+
+import (
+	"errors"
+	"internal/cm"
+	"io"
+	"net/http"
+
+	"internal/wasi/io/v0.2.0/streams"
+
+	"internal/wasi/http/v0.2.0/types"
+)
+
+// A resource that owns an incoming body, like an incoming request or
+// response. It is dropped after the body.
+type bodyOwner interface {
+	ResourceDrop()
+}
+
+// bodyReader reads an incoming request or response body.
+type bodyReader struct {
+	owner   bodyOwner
+	body    types.IncomingBody
+	stream  streams.InputStream
+	trailer *http.Header // set to the trailers once the whole body was read
+	err     error
+	done    bool
+}
+
+// Consume the body of an incoming request or response.
+func newBodyReader(owner bodyOwner, result cm.Result[types.IncomingBody, types.IncomingBody, struct{}], trailer *http.Header) (*bodyReader, error) {
+	if result.IsErr() {
+		owner.ResourceDrop()
+		return nil, errors.New("wasihttp: body was already consumed")
+	}
+	body := *result.OK()
+	stream := body.Stream()
+	if stream.IsErr() {
+		body.ResourceDrop()
+		owner.ResourceDrop()
+		return nil, errors.New("wasihttp: body stream was already consumed")
+	}
+	return &bodyReader{
+		owner:   owner,
+		body:    body,
+		stream:  *stream.OK(),
+		trailer: trailer,
+	}, nil
+}
+
+func (r *bodyReader) Read(buf []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	for {
+		result := r.stream.Read(uint64(len(buf)))
+		if err := result.Err(); err != nil {
+			if err.Closed() {
+				r.err = io.EOF
+				if err := r.finish(true); err != nil {
+					r.err = err
+				}
+			} else {
+				r.err = streamError(*err)
+			}
+			return 0, r.err
+		}
+		data := result.OK().Slice()
+		if len(data) == 0 {
+			wait(r.stream.Subscribe())
+			continue
+		}
+		return copy(buf, data), nil
+	}
+}
+
+func (r *bodyReader) Close() error {
+	if r.err == nil {
+		r.err = errors.New("wasihttp: read on closed body")
+	}
+	return r.finish(false)
+}
+
+// Drop the stream, body and owner. If the whole body was read, the trailers
+// are received first.
+func (r *bodyReader) finish(eof bool) error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	r.stream.ResourceDrop()
+	defer r.owner.ResourceDrop()
+	if !eof {
+		r.body.ResourceDrop()
+		return nil
+	}
+
+	future := types.IncomingBodyFinish(r.body)
+	defer future.ResourceDrop()
+	wait(future.Subscribe())
+	result := future.Get()
+	if result.Some() == nil || result.Some().IsErr() {
+		return errors.New("wasihttp: trailers are not available")
+	}
+	trailers := result.Some().OK()
+	if err := trailers.Err(); err != nil {
+		return httpError(*err)
+	}
+	if fields := trailers.OK().Some(); fields != nil {
+		if r.trailer != nil {
+			*r.trailer = fromFields(*fields)
+		}
+		fields.ResourceDrop()
+	}
+	return nil
+}
+
+// bodyWriter writes an outgoing request or response body.
+type bodyWriter struct {
+	body   types.OutgoingBody
+	stream streams.OutputStream
+}
+
+func newBodyWriter(body types.OutgoingBody) (*bodyWriter, error) {
+	stream := body.Write()
+	if stream.IsErr() {
+		body.ResourceDrop()
+		return nil, errors.New("wasihttp: body stream was already taken")
+	}
+	return &bodyWriter{
+		body:   body,
+		stream: *stream.OK(),
+	}, nil
+}
+
+func (w *bodyWriter) Write(buf []byte) (int, error) {
+	written := 0
+	for written < len(buf) {
+		result := w.stream.CheckWrite()
+		if err := result.Err(); err != nil {
+			return written, streamError(*err)
+		}
+		n := int(*result.OK())
+		if n == 0 {
+			wait(w.stream.Subscribe())
+			continue
+		}
+		if n > len(buf)-written {
+			n = len(buf) - written
+		}
+		if result := w.stream.Write(cm.ToList(buf[written : written+n])); result.IsErr() {
+			return written, streamError(*result.Err())
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Flush the written data, and wait until it has been flushed.
+func (w *bodyWriter) flush() error {
+	if result := w.stream.Flush(); result.IsErr() {
+		return streamError(*result.Err())
+	}
+	for {
+		result := w.stream.CheckWrite()
+		if err := result.Err(); err != nil {
+			return streamError(*err)
+		}
+		if *result.OK() != 0 {
+			return nil
+		}
+		wait(w.stream.Subscribe())
+	}
+}
+
+// Drop the body without finishing it, which tells the host that the body is
+// incomplete.
+func (w *bodyWriter) abort() {
+	w.stream.ResourceDrop()
+	w.body.ResourceDrop()
+}
+
+// Flush the remaining data and finish the body, with optional trailers.
+func (w *bodyWriter) finish(trailer http.Header) error {
+	err := w.flush()
+	w.stream.ResourceDrop()
+	trailers := cm.None[types.Trailers]()
+	if err == nil && len(trailer) != 0 {
+		var fields types.Fields
+		fields, err = toFields(trailer)
+		trailers = cm.Some(fields)
+	}
+	if err != nil {
+		w.body.ResourceDrop()
+		return err
+	}
+	result := types.OutgoingBodyFinish(w.body, trailers)
+	if err := result.Err(); err != nil {
+		return httpError(*err)
+	}
+	return nil
+}
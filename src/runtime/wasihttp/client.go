@@ -0,0 +1,158 @@
+//go:build wasip2
+
+package wasihttp
+
+import (
+	"errors"
+	"internal/cm"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	outgoinghandler "internal/wasi/http/v0.2.0/outgoing-handler"
+	"internal/wasi/http/v0.2.0/types"
+)
+
+// Transport is an http.RoundTripper that sends requests using the
+// wasi:http/outgoing-handler interface. The host makes the connections, so
+// connection pooling, proxies and TLS are configured there.
+type Transport struct {
+	// Timeouts of the request, if not zero. The host may not support all of
+	// them, in which case RoundTrip returns an error.
+	ConnectTimeout      time.Duration // connecting to the server
+	FirstByteTimeout    time.Duration // receiving the first byte of the response
+	BetweenBytesTimeout time.Duration // between chunks of the response body
+}
+
+// InstallTransport sets http.DefaultTransport to a Transport, so that http.Get
+// and the default http.Client send requests using wasi:http. It should be
+// called before making any requests.
+func InstallTransport() {
+	http.DefaultTransport = &Transport{}
+}
+
+// RoundTrip implements http.RoundTripper. The request body is written before
+// waiting for the response.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.roundTrip(req)
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return resp, err
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		return nil, errors.New("wasihttp: nil Request.URL")
+	}
+	header := req.Header
+	if req.ContentLength > 0 && header.Get("Content-Length") == "" {
+		header = header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	}
+	fields, err := toFields(header)
+	if err != nil {
+		return nil, err
+	}
+	request := types.NewOutgoingRequest(fields)
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if request.SetMethod(toMethod(req.Method)) ||
+		request.SetScheme(cm.Some(toScheme(req.URL.Scheme))) ||
+		request.SetAuthority(cm.Some(host)) ||
+		request.SetPathWithQuery(cm.Some(req.URL.RequestURI())) {
+		request.ResourceDrop()
+		return nil, errors.New("wasihttp: invalid request " + req.Method + " " + req.URL.String())
+	}
+	body := request.Body()
+	options, err := t.options()
+	if err != nil {
+		body.OK().ResourceDrop()
+		request.ResourceDrop()
+		return nil, err
+	}
+
+	result := outgoinghandler.Handle(request, options)
+	if err := result.Err(); err != nil {
+		body.OK().ResourceDrop()
+		return nil, httpError(*err)
+	}
+	future := *result.OK()
+	defer future.ResourceDrop()
+
+	w, err := newBodyWriter(*body.OK())
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		if _, err := io.Copy(w, req.Body); err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	if err := w.finish(req.Trailer); err != nil {
+		return nil, err
+	}
+
+	wait(future.Subscribe())
+	get := future.Get()
+	if get.Some() == nil || get.Some().IsErr() {
+		return nil, errors.New("wasihttp: response is not available")
+	}
+	got := get.Some().OK()
+	if err := got.Err(); err != nil {
+		return nil, httpError(*err)
+	}
+	response := *got.OK()
+
+	headers := response.Headers()
+	respHeader := fromFields(headers)
+	headers.ResourceDrop()
+	status := int(response.Status())
+	resp := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        respHeader,
+		ContentLength: contentLength(respHeader),
+		Request:       req,
+	}
+	resp.Body, err = newBodyReader(response, response.Consume(), &resp.Trailer)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Create the request options, or none if no timeouts are set.
+func (t *Transport) options() (cm.Option[types.RequestOptions], error) {
+	if t.ConnectTimeout == 0 && t.FirstByteTimeout == 0 && t.BetweenBytesTimeout == 0 {
+		return cm.None[types.RequestOptions](), nil
+	}
+	options := types.NewRequestOptions()
+	for _, timeout := range []struct {
+		duration time.Duration
+		set      func(cm.Option[types.Duration]) cm.BoolResult
+	}{
+		{t.ConnectTimeout, options.SetConnectTimeout},
+		{t.FirstByteTimeout, options.SetFirstByteTimeout},
+		{t.BetweenBytesTimeout, options.SetBetweenBytesTimeout},
+	} {
+		if timeout.duration == 0 {
+			continue
+		}
+		if timeout.set(cm.Some(types.Duration(timeout.duration))) {
+			options.ResourceDrop()
+			return cm.None[types.RequestOptions](), errors.New("wasihttp: timeout is not supported by the host")
+		}
+	}
+	return cm.Some(options), nil
+}
@@ -0,0 +1,241 @@
+//go:build wasip2
+
+package wasihttp
+
+import (
+	"errors"
+	"internal/cm"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	incominghandler "internal/wasi/http/v0.2.0/incoming-handler"
+	"internal/wasi/http/v0.2.0/types"
+)
+
+var handler http.Handler
+
+func init() {
+	incominghandler.Exports.Handle = handle
+}
+
+// Handle registers the handler for incoming HTTP requests. If it is not
+// called, http.DefaultServeMux is used.
+func Handle(h http.Handler) {
+	handler = h
+}
+
+// HandleFunc registers the handler function for incoming HTTP requests.
+func HandleFunc(f func(http.ResponseWriter, *http.Request)) {
+	handler = http.HandlerFunc(f)
+}
+
+// handle implements wasi:http/incoming-handler.handle.
+func handle(request types.IncomingRequest, responseOut types.ResponseOutparam) {
+	req, err := newRequest(request)
+	if err != nil {
+		code := types.ErrorCodeInternalError(cm.Some(err.Error()))
+		if err, ok := err.(*requestError); ok {
+			code = err.code
+		}
+		setError(responseOut, code)
+		return
+	}
+	w := &responseWriter{
+		out:    responseOut,
+		header: make(http.Header),
+	}
+	h := handler
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	h.ServeHTTP(w, req)
+	req.Body.Close()
+	w.finish()
+}
+
+// requestError is returned by newRequest for invalid requests, with the error
+// code to report to the host.
+type requestError struct {
+	code types.ErrorCode
+	msg  string
+}
+
+func (e *requestError) Error() string {
+	return "wasihttp: " + e.msg
+}
+
+// Convert an incoming request to an http.Request. The request resource is
+// owned by the body of the returned request.
+func newRequest(request types.IncomingRequest) (*http.Request, error) {
+	var requestURI string
+	if path := request.PathWithQuery(); path.Some() != nil {
+		requestURI = *path.Some()
+	}
+	if requestURI == "" {
+		requestURI = "/"
+	}
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		request.ResourceDrop()
+		return nil, &requestError{types.ErrorCodeHTTPRequestURIInvalid(), "invalid request URI " + strconv.Quote(requestURI)}
+	}
+	method := fromMethod(request.Method())
+	if !isToken(method) {
+		request.ResourceDrop()
+		return nil, &requestError{types.ErrorCodeHTTPRequestMethodInvalid(), "invalid method " + strconv.Quote(method)}
+	}
+	var host string
+	if authority := request.Authority(); authority.Some() != nil {
+		host = *authority.Some()
+	}
+
+	headers := request.Headers()
+	header := fromFields(headers)
+	headers.ResourceDrop()
+	if err := checkHeader(header); err != nil {
+		request.ResourceDrop()
+		return nil, &requestError{types.ErrorCodeHTTPProtocolError(), err.Error()}
+	}
+
+	req := &http.Request{
+		Method:        method,
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: contentLength(header),
+		Host:          host,
+		RequestURI:    requestURI,
+	}
+	body, err := newBodyReader(request, request.Consume(), &req.Trailer)
+	if err != nil {
+		return nil, &requestError{types.ErrorCodeInternalError(cm.Some(err.Error())), err.Error()}
+	}
+	req.Body = body
+	return req, nil
+}
+
+// checkHeader returns an error if the header contains invalid names or values,
+// or a Content-Length that isn't a number.
+func checkHeader(header http.Header) error {
+	for key, values := range header {
+		if !isToken(key) {
+			return errors.New("invalid header name " + strconv.Quote(key))
+		}
+		for _, value := range values {
+			if strings.ContainsAny(value, "\r\n\x00") {
+				return errors.New("invalid value for header " + key)
+			}
+		}
+	}
+	if values := header["Content-Length"]; len(values) != 0 {
+		if n, err := strconv.ParseInt(values[0], 10, 64); err != nil || n < 0 || len(values) > 1 {
+			return errors.New("invalid Content-Length")
+		}
+	}
+	return nil
+}
+
+// isToken returns whether s is a valid HTTP token, as used for methods and
+// header names (RFC 9110 section 5.6.2).
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("\"(),/:;<=>?@[\\]{}", c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func setError(out types.ResponseOutparam, code types.ErrorCode) {
+	types.ResponseOutparamSet(out, cm.Err[cm.Result[types.ErrorCode, types.OutgoingResponse, types.ErrorCode]](code))
+}
+
+// responseWriter is the http.ResponseWriter passed to the handler. The
+// response is sent when the headers are written, after which the body is
+// streamed.
+type responseWriter struct {
+	out     types.ResponseOutparam
+	header  http.Header
+	trailer http.Header
+	body    *bodyWriter
+	sent    bool
+	err     error
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.sent {
+		return
+	}
+	w.sent = true
+
+	var header http.Header
+	header, w.trailer = splitTrailers(w.header)
+	fields, err := toFields(header)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	response := types.NewOutgoingResponse(fields)
+	if response.SetStatusCode(types.StatusCode(statusCode)) {
+		response.ResourceDrop()
+		w.fail(errors.New("wasihttp: invalid status code " + strconv.Itoa(statusCode)))
+		return
+	}
+	body := response.Body()
+	types.ResponseOutparamSet(w.out, cm.OK[cm.Result[types.ErrorCode, types.OutgoingResponse, types.ErrorCode]](response))
+	w.body, w.err = newBodyWriter(*body.OK())
+}
+
+func (w *responseWriter) Write(buf []byte) (int, error) {
+	if !w.sent {
+		if w.header.Get("Content-Type") == "" {
+			w.header.Set("Content-Type", http.DetectContentType(buf))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.body.Write(buf)
+}
+
+// Flush implements http.Flusher.
+func (w *responseWriter) Flush() {
+	if !w.sent {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err == nil {
+		w.err = w.body.flush()
+	}
+}
+
+// Send the response if the handler did not write anything, and finish the
+// body.
+func (w *responseWriter) finish() {
+	if !w.sent {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.body != nil {
+		// The response was already sent, so an error can only be reported by
+		// not finishing the body.
+		w.body.finish(w.trailer)
+	}
+}
+
+// Report an error to the host instead of sending a response.
+func (w *responseWriter) fail(err error) {
+	w.err = err
+	setError(w.out, types.ErrorCodeInternalError(cm.Some(err.Error())))
+}
@@ -0,0 +1,177 @@
+//go:build wasip2
+
+// Package wasihttp connects the net/http package to the wasi:http interfaces
+// of WASI Preview 2.
+//
+// A program serves HTTP requests by registering an http.Handler with Handle,
+// which is then called for every request delivered through the exported
+// wasi:http/incoming-handler interface. The host calls this interface without
+// running main.main, so the handler must be registered from an init function.
+// When no handler is registered, http.DefaultServeMux is used. Such a program
+// is built for the wasip2-http target, and can be tested locally with
+// wasmtime serve:
+//
+//	tinygo build -target=wasip2-http -o server.wasm .
+//	wasmtime serve -Scli server.wasm
+//
+// Outgoing requests are sent by a Transport, using the imported
+// wasi:http/outgoing-handler interface. Call InstallTransport to use it for
+// http.Get and the default http.Client. A command that only makes outgoing
+// requests is built for the tinygo:wasip2/http-command world instead:
+//
+//	tinygo build -target=wasip2-http -wit-world=tinygo:wasip2/http-command -o client.wasm .
+//
+// Blocking operations (like reading a request body, or waiting for a
+// response) only pause the calling goroutine, so other goroutines keep running
+// in the meantime.
+package wasihttp
+
+import (
+	"errors"
+	"internal/cm"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"internal/wasi/io/v0.2.0/poll"
+	"internal/wasi/io/v0.2.0/streams"
+
+	"internal/wasi/http/v0.2.0/types"
+)
+
+// Wait until one of the given pollables is ready. This is implemented in the
+// runtime, which integrates it with the scheduler.
+func pollableWait(pollables []poll.Pollable)
+
+// Wait until the pollable is ready, and drop it.
+func wait(p poll.Pollable) {
+	pollableWait([]poll.Pollable{p})
+	p.ResourceDrop()
+}
+
+// Convert HTTP headers to a new fields resource. Headers that the host does
+// not allow to be set (like Connection) are left out.
+func toFields(header http.Header) (types.Fields, error) {
+	fields := types.NewFields()
+	for key, values := range header {
+		for _, value := range values {
+			result := fields.Append(types.FieldKey(key), types.FieldValue(cm.ToList([]byte(value))))
+			if err := result.Err(); err != nil && !err.Forbidden() {
+				fields.ResourceDrop()
+				return cm.ResourceNone, errors.New("wasihttp: invalid header " + strconv.Quote(key) + ": " + err.String())
+			}
+		}
+	}
+	return fields, nil
+}
+
+// Convert a fields resource to HTTP headers. The fields resource is not
+// dropped.
+func fromFields(fields types.Fields) http.Header {
+	header := make(http.Header)
+	entries := fields.Entries()
+	for _, entry := range entries.Slice() {
+		header.Add(string(entry.F0), string(entry.F1.Slice()))
+	}
+	return header
+}
+
+// Split the trailers declared by a handler (in the Trailer header, or using
+// http.TrailerPrefix) from the response headers.
+func splitTrailers(header http.Header) (headers, trailers http.Header) {
+	headers = make(http.Header, len(header))
+	trailers = make(http.Header)
+	declared := make(map[string]bool)
+	for _, values := range header["Trailer"] {
+		for _, key := range strings.Split(values, ",") {
+			declared[http.CanonicalHeaderKey(strings.TrimSpace(key))] = true
+		}
+	}
+	for key, values := range header {
+		switch {
+		case strings.HasPrefix(key, http.TrailerPrefix):
+			trailers[http.CanonicalHeaderKey(key[len(http.TrailerPrefix):])] = values
+		case key == "Trailer":
+		case declared[key]:
+			trailers[key] = values
+		default:
+			headers[key] = values
+		}
+	}
+	return headers, trailers
+}
+
+func toMethod(method string) types.Method {
+	switch method {
+	case "", http.MethodGet:
+		return types.MethodGet()
+	case http.MethodHead:
+		return types.MethodHead()
+	case http.MethodPost:
+		return types.MethodPost()
+	case http.MethodPut:
+		return types.MethodPut()
+	case http.MethodDelete:
+		return types.MethodDelete()
+	case http.MethodConnect:
+		return types.MethodConnect()
+	case http.MethodOptions:
+		return types.MethodOptions()
+	case http.MethodTrace:
+		return types.MethodTrace()
+	case http.MethodPatch:
+		return types.MethodPatch()
+	}
+	return types.MethodOther(method)
+}
+
+func fromMethod(method types.Method) string {
+	if other := method.Other(); other != nil {
+		return *other
+	}
+	return strings.ToUpper(method.String())
+}
+
+func toScheme(scheme string) types.Scheme {
+	switch scheme {
+	case "http":
+		return types.SchemeHTTP()
+	case "https":
+		return types.SchemeHTTPS()
+	}
+	return types.SchemeOther(scheme)
+}
+
+// Parse the Content-Length header, returning -1 if it is not known.
+func contentLength(header http.Header) int64 {
+	n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// Convert an error code of the wasi:http implementation to an error.
+func httpError(code types.ErrorCode) error {
+	msg := "wasihttp: " + code.String()
+	if info := code.InternalError(); info != nil && info.Some() != nil {
+		msg += ": " + *info.Some()
+	}
+	return errors.New(msg)
+}
+
+// Convert a stream error to an error. The failed operation might have been
+// caused by an HTTP protocol error, which is reported if so.
+func streamError(err streams.StreamError) error {
+	if err.Closed() {
+		return io.ErrClosedPipe
+	}
+	ioErr := *err.LastOperationFailed()
+	defer ioErr.ResourceDrop()
+	code := types.HTTPErrorCode(ioErr)
+	if code.Some() != nil {
+		return httpError(*code.Some())
+	}
+	return errors.New("wasihttp: " + ioErr.ToDebugString())
+}
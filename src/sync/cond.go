@@ -7,6 +7,7 @@ type Cond struct {
 
 	unlocking *earlySignal
 	blocked   task.Stack
+	lock      task.PMutex // protects the fields above with -scheduler=threads
 }
 
 // earlySignal is a type used to implement a stack for signalling waiters while they are unlocking.
@@ -40,21 +41,27 @@ func (c *Cond) trySignal() bool {
 }
 
 func (c *Cond) Signal() {
+	c.lock.Lock()
 	c.trySignal()
+	c.lock.Unlock()
 }
 
 func (c *Cond) Broadcast() {
 	// Signal everything.
+	c.lock.Lock()
 	for c.trySignal() {
 	}
+	c.lock.Unlock()
 }
 
 func (c *Cond) Wait() {
 	// Add an earlySignal frame to the stack so we can be signalled while unlocking.
+	c.lock.Lock()
 	early := earlySignal{
 		next: c.unlocking,
 	}
 	c.unlocking = &early
+	c.lock.Unlock()
 
 	// Temporarily unlock L.
 	c.L.Unlock()
@@ -63,7 +70,9 @@ func (c *Cond) Wait() {
 	defer c.L.Lock()
 
 	// If we were signaled while unlocking, immediately complete.
+	c.lock.Lock()
 	if early.signaled {
+		c.lock.Unlock()
 		return
 	}
 
@@ -80,5 +89,6 @@ func (c *Cond) Wait() {
 
 	// Wait for a signal.
 	c.blocked.Push(task.Current())
+	c.lock.Unlock()
 	task.Pause()
 }
@@ -10,24 +10,30 @@ import (
 type Mutex struct {
 	state   uint8 // Set to non-zero if locked.
 	blocked task.Stack
+	lock    task.PMutex // protects the fields above with -scheduler=threads
 }
 
 //go:linkname scheduleTask runtime.runqueuePushBack
 func scheduleTask(*task.Task)
 
 func (m *Mutex) Lock() {
+	m.lock.Lock()
 	if m.islocked() {
 		// Push self onto stack of blocked tasks, and wait to be resumed.
 		m.blocked.Push(task.Current())
+		m.lock.Unlock()
 		task.Pause()
 		return
 	}
 
 	m.setlock(true)
+	m.lock.Unlock()
 }
 
 func (m *Mutex) Unlock() {
+	m.lock.Lock()
 	if !m.islocked() {
+		m.lock.Unlock()
 		panic("sync: unlock of unlocked Mutex")
 	}
 
@@ -37,6 +43,7 @@ func (m *Mutex) Unlock() {
 	} else {
 		m.setlock(false)
 	}
+	m.lock.Unlock()
 }
 
 // TryLock tries to lock m and reports whether it succeeded.
@@ -45,10 +52,12 @@ func (m *Mutex) Unlock() {
 // and use of TryLock is often a sign of a deeper problem
 // in a particular use of mutexes.
 func (m *Mutex) TryLock() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	if m.islocked() {
 		return false
 	}
-	m.Lock()
+	m.setlock(true)
 	return true
 }
 
@@ -79,6 +88,9 @@ type RWMutex struct {
 	// Iff the mutex is write-locked, it contains rwMutexStateWLocked.
 	// While the mutex is read-locked, it contains the current number of readers.
 	state uint32
+
+	// lock protects the fields above with -scheduler=threads.
+	lock task.PMutex
 }
 
 const (
@@ -88,29 +100,35 @@ const (
 )
 
 func (rw *RWMutex) Lock() {
+	rw.lock.Lock()
 	if rw.state == 0 {
 		// The mutex is completely unlocked.
 		// Lock without waiting.
 		rw.state = rwMutexStateWLocked
+		rw.lock.Unlock()
 		return
 	}
 
 	// Wait for the lock to be released.
 	rw.waitingWriters.Push(task.Current())
+	rw.lock.Unlock()
 	task.Pause()
 }
 
 func (rw *RWMutex) Unlock() {
+	rw.lock.Lock()
 	switch rw.state {
 	case rwMutexStateWLocked:
 		// This is correct.
 
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		rw.lock.Unlock()
 		panic("sync: unlock of unlocked RWMutex")
 
 	default:
 		// The mutex is read-locked instead of write-locked.
+		rw.lock.Unlock()
 		panic("sync: write-unlock of read-locked RWMutex")
 	}
 
@@ -125,32 +143,40 @@ func (rw *RWMutex) Unlock() {
 		// Nothing is waiting for the lock.
 		rw.state = rwMutexStateUnlocked
 	}
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) RLock() {
+	rw.lock.Lock()
 	if rw.state == rwMutexStateWLocked {
 		// Wait for the write lock to be released.
 		rw.waitingReaders.Push(task.Current())
+		rw.lock.Unlock()
 		task.Pause()
 		return
 	}
 
 	if rw.state == rwMutexMaxReaders {
+		rw.lock.Unlock()
 		panic("sync: too many readers on RWMutex")
 	}
 
 	// Increase the reader count.
 	rw.state++
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) RUnlock() {
+	rw.lock.Lock()
 	switch rw.state {
 	case rwMutexStateUnlocked:
 		// The mutex is already unlocked.
+		rw.lock.Unlock()
 		panic("sync: unlock of unlocked RWMutex")
 
 	case rwMutexStateWLocked:
 		// The mutex is write-locked instead of read-locked.
+		rw.lock.Unlock()
 		panic("sync: read-unlock of write-locked RWMutex")
 	}
 
@@ -161,6 +187,7 @@ func (rw *RWMutex) RUnlock() {
 		// Try to unblock a writer.
 		rw.maybeUnblockWriter()
 	}
+	rw.lock.Unlock()
 }
 
 func (rw *RWMutex) maybeUnblockReaders() bool {
@@ -5,12 +5,15 @@ import "internal/task"
 type WaitGroup struct {
 	counter uint
 	waiters task.Stack
+	lock    task.PMutex // protects the fields above with -scheduler=threads
 }
 
 func (wg *WaitGroup) Add(delta int) {
+	wg.lock.Lock()
 	if delta > 0 {
 		// Check for overflow.
 		if uint(delta) > (^uint(0))-wg.counter {
+			wg.lock.Unlock()
 			panic("sync: WaitGroup counter overflowed")
 		}
 
@@ -19,6 +22,7 @@ func (wg *WaitGroup) Add(delta int) {
 	} else {
 		// Check for underflow.
 		if uint(-delta) > wg.counter {
+			wg.lock.Unlock()
 			panic("sync: negative WaitGroup counter")
 		}
 
@@ -34,6 +38,7 @@ func (wg *WaitGroup) Add(delta int) {
 			}
 		}
 	}
+	wg.lock.Unlock()
 }
 
 func (wg *WaitGroup) Done() {
@@ -41,13 +46,16 @@ func (wg *WaitGroup) Done() {
 }
 
 func (wg *WaitGroup) Wait() {
+	wg.lock.Lock()
 	if wg.counter == 0 {
 		// Everything already finished.
+		wg.lock.Unlock()
 		return
 	}
 
 	// Push the current goroutine onto the waiter stack.
 	wg.waiters.Push(task.Current())
+	wg.lock.Unlock()
 
 	// Pause until the waiters are awoken by Add/Done.
 	task.Pause()
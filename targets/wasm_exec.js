@@ -137,6 +137,7 @@
 		constructor() {
 			this._callbackTimeouts = new Map();
 			this._nextCallbackTimeoutID = 1;
+			this._jspiTasks = new Map(); // goroutines of -scheduler=jspi, indexed by task state pointer
 
 			const mem = () => {
 				// The buffer may change when requesting more memory.
@@ -235,6 +236,15 @@
 				return decoder.decode(new DataView(this._inst.exports.memory.buffer, ptr, len));
 			}
 
+			// Imports used by -scheduler=jspi must suspend the WebAssembly stack
+			// when they return a promise.
+			const suspending = (fn) => {
+				if (typeof WebAssembly.Suspending === "undefined") {
+					return fn;
+				}
+				return new WebAssembly.Suspending(fn);
+			}
+
 			const timeOrigin = Date.now() - performance.now();
 			this.importObject = {
 				wasi_snapshot_preview1: {
@@ -293,9 +303,54 @@
 					// func sleepTicks(timeout float64)
 					"runtime.sleepTicks": (timeout) => {
 						// Do not sleep, only reactivate scheduler after the given timeout.
-						setTimeout(this._inst.exports.go_scheduler, timeout);
+						setTimeout(this._goScheduler, timeout);
+					},
+
+					// func (*state) launch() in internal/task (-scheduler=jspi)
+					tinygo_jspi_launch: (state) => {
+						// This runs the goroutine on a new stack until it pauses
+						// for the first time. When the goroutine exits, the
+						// scheduler (if it is waiting) continues.
+						this._jspiStart(state).then(() => {
+							const task = this._jspiTasks.get(state);
+							this._jspiTasks.delete(state);
+							if (task !== undefined && task.paused !== undefined) {
+								task.paused();
+							}
+						});
 					},
 
+					// func (*state) resume() in internal/task (-scheduler=jspi)
+					tinygo_jspi_resume: suspending((state) => {
+						// Wake up the goroutine, and suspend the scheduler until it
+						// pauses again.
+						const task = this._jspiTasks.get(state);
+						return new Promise((resolve) => {
+							task.paused = resolve;
+							task.wake();
+						});
+					}),
+
+					// func (*state) pause() in internal/task (-scheduler=jspi)
+					tinygo_jspi_pause: suspending((state) => {
+						let task = this._jspiTasks.get(state);
+						if (task === undefined) {
+							task = {};
+							this._jspiTasks.set(state, task);
+						}
+						// Suspend the goroutine until it is resumed, and let the
+						// scheduler continue.
+						const paused = task.paused;
+						task.paused = undefined;
+						const wake = new Promise((resolve) => {
+							task.wake = resolve;
+						});
+						if (paused !== undefined) {
+							paused();
+						}
+						return wake;
+					}),
+
 					// func finalizeRef(v ref)
 					"syscall/js.finalizeRef": (v_ref) => {
 						// Note: TinyGo does not support finalizers so this should never be
@@ -448,6 +503,331 @@
 			// Go 1.20 uses 'env'. Go 1.21 uses 'gojs'.
 			// For compatibility, we use both as long as Go 1.20 is supported.
 			this.importObject.env = this.importObject.gojs;
+
+			// Functions imported with //go:jsimport. The import name contains
+			// the JavaScript function and the signature, for example
+			// "document.getElementById(string)value", and the glue code is
+			// created from it when the module is instantiated.
+			const jsImports = {};
+			this.importObject.jsimport = new Proxy(jsImports, {
+				get: (target, name) => {
+					if (typeof name !== "string") {
+						return undefined;
+					}
+					if (!(name in target)) {
+						target[name] = makeJSImport(name);
+					}
+					return target[name];
+				},
+			});
+
+			// Converters from WebAssembly parameters to JavaScript values, and
+			// the number of WebAssembly parameters they use.
+			const typedArrays = {
+				"[]i8": Int8Array,
+				"[]u8": Uint8Array,
+				"[]i16": Int16Array,
+				"[]u16": Uint16Array,
+				"[]i32": Int32Array,
+				"[]u32": Uint32Array,
+				"[]i64": BigInt64Array,
+				"[]u64": BigUint64Array,
+				"[]f32": Float32Array,
+				"[]f64": Float64Array,
+			};
+			const paramConverter = (type) => {
+				switch (type) {
+					case "bool":
+						return [1, (v) => v !== 0];
+					case "i32":
+					case "f32":
+					case "f64":
+						return [1, (v) => v];
+					case "u32":
+						return [1, (v) => v >>> 0];
+					case "i64":
+						// Passed as a BigInt, as not all values fit in a Number.
+						return [1, (v) => v];
+					case "u64":
+						return [1, (v) => BigInt.asUintN(64, v)];
+					case "string":
+						return [2, (ptr, len) => loadString(ptr, len)];
+					case "value":
+						return [1, (v) => unboxValue(v)];
+				}
+				const TypedArray = typedArrays[type];
+				if (TypedArray !== undefined) {
+					return [2, (ptr, len) => new TypedArray(this._inst.exports.memory.buffer, ptr, len)];
+				}
+				throw new Error("jsimport: unknown parameter type " + type);
+			}
+			const resultConverter = (type) => {
+				switch (type) {
+					case "":
+						return (v) => undefined;
+					case "bool":
+						return (v) => v ? 1 : 0;
+					case "i32":
+						return (v) => v | 0;
+					case "u32":
+						return (v) => v >>> 0;
+					case "i64":
+						return (v) => BigInt.asIntN(64, BigInt(typeof v === "bigint" ? v : Math.trunc(v)));
+					case "u64":
+						return (v) => BigInt.asUintN(64, BigInt(typeof v === "bigint" ? v : Math.trunc(v)));
+					case "f32":
+					case "f64":
+						return (v) => +v;
+					case "value":
+						return (v) => boxValue(v);
+				}
+				throw new Error("jsimport: unknown result type " + type);
+			}
+
+			// Create the glue function of a //go:jsimport function.
+			const makeJSImport = (name) => {
+				const match = /^([^(]+)\(([^)]*)\)(.*)$/.exec(name);
+				if (match === null) {
+					throw new Error("jsimport: invalid import name " + name);
+				}
+				const path = match[1];
+				const params = match[2] === "" ? [] : match[2].split(",").map(paramConverter);
+				const result = resultConverter(match[3]);
+				const convertArgs = (wasmArgs) => {
+					const args = [];
+					let i = 0;
+					for (const [n, convert] of params) {
+						args.push(convert(...wasmArgs.slice(i, i + n)));
+						i += n;
+					}
+					return args;
+				}
+
+				if (path.startsWith(".")) {
+					// Method call, the receiver is the first parameter.
+					const method = path.slice(1);
+					return (...wasmArgs) => {
+						const [receiver, ...args] = convertArgs(wasmArgs);
+						return result(Reflect.apply(receiver[method], receiver, args));
+					}
+				}
+
+				// Function call, resolved relative to the global object the
+				// first time it is called.
+				let fn, receiver;
+				return (...wasmArgs) => {
+					if (fn === undefined) {
+						const names = path.split(".");
+						receiver = global;
+						for (const name of names.slice(0, -1)) {
+							receiver = receiver[name];
+						}
+						fn = receiver[names[names.length - 1]];
+						if (typeof fn !== "function") {
+							throw new Error("jsimport: " + path + " is not a function");
+						}
+					}
+					return result(Reflect.apply(fn, receiver, convertArgs(wasmArgs)));
+				}
+			}
+
+			// Types of marshalled //go:wasmexport parameters and results, with
+			// their size and alignment in memory (matching the Go layout).
+			const alignUp = (n, align) => Math.ceil(n / align) * align;
+			const numberType = (size, get, set) => ({
+				size: size,
+				align: size,
+				load: (addr) => get.call(mem(), addr, true),
+				store: (addr, v) => set.call(mem(), addr, v ?? 0, true),
+			});
+			const bigintType = (get, set, wrap) => ({
+				size: 8,
+				align: 8,
+				load: (addr) => get.call(mem(), addr, true),
+				store: (addr, v) => set.call(mem(), addr, wrap(64, BigInt(v ?? 0)), true),
+			});
+			const exportTypes = {
+				"bool": {
+					size: 1,
+					align: 1,
+					load: (addr) => mem().getUint8(addr) !== 0,
+					store: (addr, v) => mem().setUint8(addr, v ? 1 : 0),
+				},
+				"i8": numberType(1, DataView.prototype.getInt8, DataView.prototype.setInt8),
+				"u8": numberType(1, DataView.prototype.getUint8, DataView.prototype.setUint8),
+				"i16": numberType(2, DataView.prototype.getInt16, DataView.prototype.setInt16),
+				"u16": numberType(2, DataView.prototype.getUint16, DataView.prototype.setUint16),
+				"i32": numberType(4, DataView.prototype.getInt32, DataView.prototype.setInt32),
+				"u32": numberType(4, DataView.prototype.getUint32, DataView.prototype.setUint32),
+				"i64": bigintType(DataView.prototype.getBigInt64, DataView.prototype.setBigInt64, BigInt.asIntN),
+				"u64": bigintType(DataView.prototype.getBigUint64, DataView.prototype.setBigUint64, BigInt.asUintN),
+				"f32": numberType(4, DataView.prototype.getFloat32, DataView.prototype.setFloat32),
+				"f64": numberType(8, DataView.prototype.getFloat64, DataView.prototype.setFloat64),
+				"string": {
+					size: 8,
+					align: 4,
+					load: (addr) => loadString(mem().getUint32(addr, true), mem().getUint32(addr + 4, true)),
+					store: (addr, v, alloc) => {
+						const data = encoder.encode(v ?? "");
+						const ptr = alloc(data.length);
+						new Uint8Array(this._inst.exports.memory.buffer, ptr, data.length).set(data);
+						mem().setUint32(addr, ptr, true);
+						mem().setUint32(addr + 4, data.length, true);
+					},
+				},
+				"[]u8": {
+					size: 12,
+					align: 4,
+					load: (addr) => loadSlice(mem().getUint32(addr, true), mem().getUint32(addr + 4, true)).slice(),
+					store: (addr, v, alloc) => {
+						const data = v ?? [];
+						const ptr = alloc(data.length);
+						new Uint8Array(this._inst.exports.memory.buffer, ptr, data.length).set(data);
+						mem().setUint32(addr, ptr, true);
+						mem().setUint32(addr + 4, data.length, true);
+						mem().setUint32(addr + 8, data.length, true);
+					},
+				},
+				"error": {
+					// An interface: only the type code is read, to check for nil.
+					size: 8,
+					align: 4,
+					load: (addr) => mem().getUint32(addr, true) !== 0,
+				},
+			};
+			const structType = (fields) => {
+				let size = 0;
+				let align = 1;
+				const layout = fields.map(([name, type]) => {
+					size = alignUp(size, type.align);
+					const offset = size;
+					size += type.size;
+					align = Math.max(align, type.align);
+					return {name, type, offset};
+				});
+				return {
+					size: alignUp(size, align),
+					align: align,
+					fields: layout,
+					load: (addr) => {
+						const v = {};
+						for (const field of layout) {
+							v[field.name] = field.type.load(addr + field.offset);
+						}
+						return v;
+					},
+					store: (addr, v, alloc) => {
+						for (const field of layout) {
+							field.type.store(addr + field.offset, (v ?? {})[field.name], alloc);
+						}
+					},
+				};
+			}
+
+			// Parse the name of a marshalled //go:wasmexport function, which
+			// looks like "jsexport:name(param,param)result,error".
+			const parseJSExport = (name) => {
+				let pos = "jsexport:".length;
+				const expect = (c) => {
+					if (name[pos] !== c) {
+						throw new Error("jsexport: invalid export name " + name);
+					}
+					pos++;
+				}
+				const ident = () => {
+					const start = pos;
+					while (pos < name.length && !"(){}:,".includes(name[pos])) {
+						pos++;
+					}
+					return name.slice(start, pos);
+				}
+				const type = () => {
+					if (name[pos] === "{") {
+						pos++;
+						const fields = [];
+						while (name[pos] !== "}") {
+							if (fields.length) {
+								expect(",");
+							}
+							const fieldName = ident();
+							expect(":");
+							fields.push([fieldName, type()]);
+						}
+						pos++;
+						return structType(fields);
+					}
+					const typeName = ident();
+					if (!(typeName in exportTypes)) {
+						throw new Error("jsexport: unknown type " + typeName + " in " + name);
+					}
+					return exportTypes[typeName];
+				}
+				const list = (end) => {
+					const types = [];
+					while (pos < name.length && name[pos] !== end) {
+						if (types.length) {
+							expect(",");
+						}
+						types.push(type());
+					}
+					return types;
+				}
+				const funcName = ident();
+				expect("(");
+				const params = list(")");
+				expect(")");
+				const results = list(undefined);
+				return {funcName, params, results};
+			}
+
+			// Create a JavaScript function that calls a marshalled
+			// //go:wasmexport function. The parameters and results are stored
+			// in memory allocated with tinygo_jsexport_alloc, which is freed
+			// again after the call.
+			this._makeJSExport = (name, fn) => {
+				const {funcName, params, results} = parseJSExport(name);
+				const hasError = results.length !== 0 && results[results.length - 1] === exportTypes.error;
+				const paramsType = structType(params.map((type, i) => [i, type]));
+				const resultFields = results.map((type, i) => [i, type]);
+				if (hasError) {
+					resultFields.push(["message", exportTypes.string]);
+				}
+				const resultsType = structType(resultFields);
+				const hasResult = results.length > (hasError ? 1 : 0);
+				return [funcName, (...args) => {
+					const exports = this._inst.exports;
+					const allocs = [];
+					const alloc = (size) => {
+						if (size === 0) {
+							return 0;
+						}
+						const ptr = exports.tinygo_jsexport_alloc(size) >>> 0;
+						allocs.push(ptr);
+						return ptr;
+					}
+					try {
+						const paramsPtr = alloc(paramsType.size);
+						paramsType.store(paramsPtr, args, alloc);
+						const resultsPtr = alloc(resultsType.size);
+						fn(paramsPtr, resultsPtr);
+						if (hasError) {
+							const errField = resultsType.fields[results.length - 1];
+							if (errField.type.load(resultsPtr + errField.offset)) {
+								const messageField = resultsType.fields[results.length];
+								throw new Error(messageField.type.load(resultsPtr + messageField.offset));
+							}
+						}
+						if (hasResult) {
+							const field = resultsType.fields[0];
+							return field.type.load(resultsPtr + field.offset);
+						}
+					} finally {
+						for (const ptr of allocs) {
+							exports.tinygo_jsexport_free(ptr);
+						}
+					}
+				}];
+			}
 		}
 
 		async run(instance) {
@@ -466,8 +846,38 @@
 			this._idPool = [];      // unused ids that have been garbage collected
 			this.exited = false;    // whether the Go program has exited
 
+			// Functions exported with //go:wasmexport. Functions with
+			// marshalled parameters and results are wrapped, so that they can
+			// be called with regular JavaScript values.
+			this.exports = {};
+			for (const [name, value] of Object.entries(this._inst.exports)) {
+				if (name.startsWith("jsexport:")) {
+					const [funcName, fn] = this._makeJSExport(name, value);
+					this.exports[funcName] = fn;
+				} else if (typeof value === "function") {
+					this.exports[name] = value;
+				}
+			}
+
+			// Entry points into the scheduler. With -scheduler=jspi these must be
+			// able to suspend, so they return a promise.
+			let wrap = (fn) => fn;
+			if (this._inst.exports.tinygo_jspi_start) {
+				if (typeof WebAssembly.promising === "undefined") {
+					throw new Error("this program was built with -scheduler=jspi, which is not supported by this JavaScript engine");
+				}
+				wrap = WebAssembly.promising;
+				this._jspiStart = wrap(this._inst.exports.tinygo_jspi_start);
+			}
+			if (this._inst.exports.go_scheduler) {
+				this._goScheduler = wrap(this._inst.exports.go_scheduler);
+			}
+			if (this._inst.exports.resume) {
+				this._resumeExport = wrap(this._inst.exports.resume);
+			}
+
 			if (this._inst.exports._start) {
-				this._inst.exports._start();
+				wrap(this._inst.exports._start)();
 
 				// TODO: wait until the program exists.
 				await new Promise(() => {});
@@ -480,8 +890,16 @@
 			if (this.exited) {
 				throw new Error("Go program has already exited");
 			}
-			this._inst.exports.resume();
-			if (this.exited) {
+			// Note: with -scheduler=jspi, this returns a promise that resolves
+			// when the scheduler has nothing left to do.
+			const promise = this._resumeExport();
+			if (promise instanceof Promise) {
+				promise.then(() => {
+					if (this.exited) {
+						this._resolveExitPromise();
+					}
+				});
+			} else if (this.exited) {
 				this._resolveExitPromise();
 			}
 		}
@@ -490,9 +908,34 @@
 			const go = this;
 			return function () {
 				const event = { id: id, this: this, args: arguments };
+
+				// The event handler sets event.result when it returns.
+				let handled = false;
+				let result;
+				let resolveResult;
+				Object.defineProperty(event, "result", {
+					get: () => result,
+					set: (value) => {
+						handled = true;
+						result = value;
+						if (resolveResult !== undefined) {
+							resolveResult(value);
+						}
+					},
+				});
+
 				go._pendingEvent = event;
 				go._resume();
-				return event.result;
+				if (!handled && go._jspiStart !== undefined) {
+					// With -scheduler=jspi, the WebAssembly stack was suspended
+					// before the event handler returned (for example, because
+					// it waits for another goroutine). Return a promise for the
+					// result instead.
+					return new Promise((resolve) => {
+						resolveResult = resolve;
+					});
+				}
+				return result;
 			};
 		}
 	}
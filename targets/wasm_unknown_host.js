@@ -0,0 +1,52 @@
+// Run a module built for -target=wasm-unknown-host or -target=wasm64 with
+// NodeJS, providing the "tinygo_host" imports described in
+// src/runtime/runtime_wasm_unknown.go.
+//
+// Usage: node [--experimental-wasm-memory64] wasm_unknown_host.js module.wasm
+//
+// Modules built with -buildmode=default are started by calling _start. Modules
+// built with -buildmode=c-shared are only initialized by calling _initialize.
+"use strict";
+
+const fs = require('fs');
+const crypto = require('crypto');
+
+let memory;
+
+// Pointers and lengths are i32 values (numbers) on wasm32 and i64 values
+// (BigInts) on wasm64.
+function bytes(ptr, len) {
+	return new Uint8Array(memory.buffer, Number(ptr), Number(len));
+}
+
+const decoder = new TextDecoder('utf-8');
+
+const importObject = {
+	tinygo_host: {
+		log: (ptr, len) => {
+			console.log(decoder.decode(bytes(ptr, len)));
+		},
+		nanotime: () => {
+			return process.hrtime.bigint();
+		},
+		walltime: () => {
+			return BigInt(Date.now()) * 1000000n;
+		},
+		random: (ptr, len) => {
+			crypto.randomFillSync(bytes(ptr, len));
+		},
+	},
+};
+
+WebAssembly.instantiate(fs.readFileSync(process.argv[2]), importObject).then((result) => {
+	const exports = result.instance.exports;
+	memory = exports.memory;
+	if (exports._start) {
+		exports._start();
+	} else {
+		exports._initialize();
+	}
+}).catch((err) => {
+	console.error(err);
+	process.exit(1);
+});
@@ -1,6 +1,10 @@
 package main
 
-import "runtime"
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+)
 
 var xorshift32State uint32 = 1
 
@@ -20,6 +24,8 @@ func randuint32() uint32 {
 func main() {
 	testNonPointerHeap()
 	testKeepAlive()
+	testMemStats()
+	testHeapDump()
 }
 
 var scalarSlices [4][]byte
@@ -74,3 +80,83 @@ func testKeepAlive() {
 	var x int
 	runtime.KeepAlive(&x)
 }
+
+func testMemStats() {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if ms.NumGC == 0 {
+		println("NumGC is zero after a GC cycle")
+	}
+	var pauses uint64
+	for _, n := range ms.PauseHist {
+		pauses += n
+	}
+	if pauses != uint64(ms.NumGC) {
+		println("PauseHist doesn't add up to NumGC:", pauses, ms.NumGC)
+	}
+	var mallocs, frees uint64
+	for _, class := range ms.BySize {
+		mallocs += class.Mallocs
+		frees += class.Frees
+	}
+	if mallocs != ms.Mallocs || frees != ms.Frees {
+		println("BySize doesn't add up to Mallocs and Frees")
+	}
+	if ms.HeapObjects != ms.Mallocs-ms.Frees {
+		println("unexpected number of heap objects:", ms.HeapObjects, ms.Mallocs-ms.Frees)
+	}
+	if ms.HeapLargestFree > ms.HeapIdle || ms.HeapFragmentation > 100 {
+		println("unexpected free heap statistics")
+	}
+
+	samples := []metrics.Sample{
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/sched/pauses/total/gc:seconds"},
+		{Name: "/does/not/exist:bytes"},
+		{Name: "/gc/heap/allocs-by-size:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[0].Value.Uint64() < uint64(ms.NumGC) {
+		println("unexpected GC cycle metric")
+	}
+	if hist := samples[1].Value.Float64Histogram(); len(hist.Buckets) != len(hist.Counts)+1 {
+		println("unexpected GC pause histogram")
+	}
+	if samples[2].Value.Kind() != metrics.KindBad {
+		println("unexpected value for unknown metric")
+	}
+	// An allocation of BySize[0].Size bytes is in the first bucket.
+	if hist := samples[3].Value.Float64Histogram(); hist.Buckets[1] != float64(ms.BySize[0].Size)+1 {
+		println("unexpected allocation size buckets")
+	}
+	println("memstats ok")
+}
+
+// heapDumpWriter checks the heap dump header without allocating memory.
+type heapDumpWriter struct {
+	header [8]byte
+	n      int
+}
+
+func (w *heapDumpWriter) Write(buf []byte) (int, error) {
+	if w.n < len(w.header) {
+		copy(w.header[w.n:], buf)
+	}
+	w.n += len(buf)
+	return len(buf), nil
+}
+
+func testHeapDump() {
+	w := &heapDumpWriter{}
+	err := debug.WriteHeap(w)
+	if err != nil {
+		println("could not write heap dump:", err.Error())
+		return
+	}
+	if string(w.header[:]) != "TGHEAP\x00\x01" || w.n <= len(w.header) {
+		println("unexpected heap dump")
+		return
+	}
+	println("heapdump ok")
+}
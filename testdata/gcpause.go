@@ -0,0 +1,84 @@
+package main
+
+// Test that the GC keeps the objects of goroutines that are blocked (here on
+// channels and a mutex) alive, when those objects are only referenced from the
+// stack of the blocked goroutine.
+
+import (
+	"runtime"
+	"sync"
+)
+
+type node struct {
+	next  *node
+	value int
+}
+
+func makeList(n, seed int) *node {
+	var head *node
+	for i := 0; i < n; i++ {
+		head = &node{next: head, value: seed + i}
+	}
+	return head
+}
+
+func checkList(head *node, n, seed int) bool {
+	for i := n - 1; i >= 0; i-- {
+		if head == nil || head.value != seed+i {
+			return false
+		}
+		head = head.next
+	}
+	return head == nil
+}
+
+const (
+	numGoroutines = 4
+	listLength    = 50
+)
+
+var garbage []*node
+
+func main() {
+	ready := make(chan struct{})
+	wake := make(chan struct{})
+	results := make(chan bool)
+	var mu sync.Mutex
+	mu.Lock()
+
+	for i := 0; i < numGoroutines; i++ {
+		go func(seed int) {
+			list := makeList(listLength, seed)
+			ready <- struct{}{}
+			if seed%2 == 0 {
+				<-wake
+			} else {
+				mu.Lock()
+				mu.Unlock()
+			}
+			results <- checkList(list, listLength, seed)
+		}(i * 1000)
+	}
+	for i := 0; i < numGoroutines; i++ {
+		<-ready
+	}
+
+	// Run the GC while all goroutines are blocked, and reuse the memory of
+	// anything that was freed.
+	for i := 0; i < 20; i++ {
+		runtime.GC()
+		for j := 0; j < 100; j++ {
+			garbage = append(garbage[:0], makeList(10, -1))
+		}
+	}
+
+	close(wake)
+	mu.Unlock()
+	ok := true
+	for i := 0; i < numGoroutines; i++ {
+		if !<-results {
+			ok = false
+		}
+	}
+	println("blocked goroutines ok:", ok)
+}
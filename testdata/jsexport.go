@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+type Person struct {
+	Name string
+	Age  int32 `js:"age"`
+	Tags struct {
+		Admin bool
+		Score float64
+	}
+}
+
+func main() {
+}
+
+//go:wasmexport greet
+func greet(name string) string {
+	return "Hello, " + name + "!"
+}
+
+//go:wasmexport reverse
+func reverse(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i, b := range data {
+		result[len(data)-1-i] = b
+	}
+	return result
+}
+
+//go:wasmexport birthday
+func birthday(p Person, years int64) Person {
+	p.Name = strings.ToUpper(p.Name)
+	p.Age += int32(years)
+	p.Tags.Score *= 2
+	return p
+}
+
+//go:wasmexport parse
+func parse(s string) (int32, error) {
+	if s == "" {
+		return 0, errors.New("empty string")
+	}
+	return int32(len(s)), nil
+}
+
+//go:wasmexport check
+func check(ok bool) error {
+	if !ok {
+		return errors.New("check failed")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+require('../targets/wasm_exec.js');
+
+function runTests() {
+    const exports = go.exports;
+    console.log(exports.greet('world'));
+    console.log(exports.greet(''));
+    console.log(Array.from(exports.reverse(new Uint8Array([1, 2, 3]))).join(','));
+    console.log(exports.reverse(new Uint8Array([])).length);
+    console.log(JSON.stringify(exports.birthday({Name: 'ann', age: 30, Tags: {Admin: true, Score: 1.5}}, 2n)));
+    console.log(exports.parse('four'));
+    try {
+        exports.parse('');
+    } catch (err) {
+        console.log('error:', err.message);
+    }
+    console.log(exports.check(true));
+    try {
+        exports.check(false);
+    } catch (err) {
+        console.log('error:', err.message);
+    }
+}
+
+let go = new Go();
+WebAssembly.instantiate(fs.readFileSync(process.argv[2]), go.importObject).then((result) => {
+    go.run(result.instance);
+    runTests();
+}).catch((err) => {
+    console.error(err);
+    process.exit(1);
+});
@@ -0,0 +1,54 @@
+package main
+
+// Test TCP sockets: listen on a local port, connect to it and exchange some
+// data in both directions.
+
+import (
+	"bufio"
+	"net"
+	"strings"
+)
+
+func main() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		println("could not listen:", err.Error())
+		return
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	println("listening on loopback:", addr.IP.IsLoopback(), addr.Port != 0)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			println("could not accept:", err.Error())
+			return
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			println("could not read request:", err.Error())
+			return
+		}
+		println("server received:", strings.TrimSpace(line))
+		conn.Write([]byte(strings.ToUpper(line)))
+	}()
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		println("could not dial:", err.Error())
+		return
+	}
+	conn.Write([]byte("hello\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		println("could not read response:", err.Error())
+		return
+	}
+	println("client received:", strings.TrimSpace(line))
+	conn.Close()
+	<-done
+	ln.Close()
+}
@@ -0,0 +1,102 @@
+package main
+
+// Test goroutines that run in parallel, each in its own thread.
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	println("start")
+
+	// Start a number of goroutines that all run at the same time, and wait
+	// for them to finish.
+	var wg sync.WaitGroup
+	var counter atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			for j := 0; j < 1000; j++ {
+				counter.Add(1)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	println("counter:", counter.Load())
+
+	// A mutex protecting a regular variable.
+	var mu sync.Mutex
+	sum := 0
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(n int) {
+			for j := 0; j < 100; j++ {
+				mu.Lock()
+				sum += n
+				mu.Unlock()
+			}
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+	println("sum:", sum)
+
+	// Channels between threads.
+	ch := make(chan int)
+	results := make(chan int)
+	for i := 0; i < 4; i++ {
+		go func() {
+			total := 0
+			for n := range ch {
+				total += n
+			}
+			results <- total
+		}()
+	}
+	for i := 1; i <= 100; i++ {
+		ch <- i
+	}
+	close(ch)
+	total := 0
+	for i := 0; i < 4; i++ {
+		total += <-results
+	}
+	println("channel total:", total)
+
+	// Allocate from many threads at once, so that the GC runs while other
+	// threads are running or blocked.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(n int) {
+			var list []*[16]int
+			for j := 0; j < 10000; j++ {
+				obj := new([16]int)
+				obj[0] = n
+				obj[15] = j
+				list = append(list, obj)
+				if len(list) > 100 {
+					list = list[50:]
+				}
+			}
+			for _, obj := range list {
+				if obj[0] != n {
+					println("corrupted object!")
+				}
+			}
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+	println("allocated in parallel")
+
+	// Sleeping and timers.
+	timer := time.NewTimer(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	<-timer.C
+	println("timer fired")
+
+	println("done")
+}
@@ -0,0 +1,48 @@
+package main
+
+// This is the client side of the wasi:http round-trip test, see
+// TestWasip2HTTP. It sends requests to the server given on the command line.
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"runtime/wasihttp"
+	"strings"
+)
+
+func main() {
+	wasihttp.InstallTransport()
+	server := os.Args[1]
+
+	resp, err := http.Get(server + "/hello?name=gopher")
+	if err != nil {
+		println("GET /hello failed:", err.Error())
+		return
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	println("GET /hello:", resp.StatusCode, resp.Header.Get("Content-Type"))
+	print(string(body))
+
+	req, _ := http.NewRequest("PUT", server+"/echo", strings.NewReader("round trip"))
+	req.Header.Set("X-Test", "yes")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		println("PUT /echo failed:", err.Error())
+		return
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	println("PUT /echo:", resp.StatusCode, resp.Header.Get("X-Method"), resp.Header.Get("X-Request-Header"))
+	println("body:", string(body))
+	println("trailer:", resp.Trailer.Get("X-Length"))
+
+	resp, err = http.Get(server + "/missing")
+	if err != nil {
+		println("GET /missing failed:", err.Error())
+		return
+	}
+	resp.Body.Close()
+	println("GET /missing:", resp.StatusCode)
+}
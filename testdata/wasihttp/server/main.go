@@ -0,0 +1,41 @@
+package main
+
+// This is the server side of the wasi:http round-trip test, see
+// TestWasip2HTTP.
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/wasihttp"
+	"strings"
+)
+
+func init() {
+	wasihttp.HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hello":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "hello %s\n", r.URL.Query().Get("name"))
+		case "/echo":
+			// Echo the body in upper case, and send a trailer with its
+			// length.
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Trailer", "X-Length")
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Request-Header", r.Header.Get("X-Test"))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(strings.ToUpper(string(body))))
+			w.Header().Set("X-Length", fmt.Sprint(len(body)))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func main() {
+}
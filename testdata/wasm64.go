@@ -0,0 +1,65 @@
+package main
+
+// Test for -target=wasm64: pointers are 64 bits, and the heap must keep working
+// while it grows and objects are freed.
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+type node struct {
+	next  *node
+	value int
+	data  []byte
+}
+
+// These globals are initialized by interp, with 64-bit pointers.
+var (
+	primes = []int{2, 3, 5, 7, 11, 13}
+	names  = map[string]*int{
+		"first": &primes[0],
+		"last":  &primes[len(primes)-1],
+	}
+	head = &node{value: 1, next: &node{value: 2}}
+)
+
+func main() {
+	println("pointer size:", unsafe.Sizeof(uintptr(0)))
+	println("globals:", len(primes), *names["first"], *names["last"], head.value, head.next.value)
+
+	// Build a linked list with objects that contain pointers, while also
+	// allocating a lot of garbage. The list must survive garbage collection.
+	var list *node
+	for i := 0; i < 2000; i++ {
+		list = &node{next: list, value: i, data: make([]byte, 16*1024)}
+		list.data[0] = byte(i)
+		_ = make([]byte, 32*1024) // garbage
+	}
+	runtime.GC()
+	sum := 0
+	n := 0
+	for p := list; p != nil; p = p.next {
+		if p.data[0] != byte(p.value) {
+			panic("memory was overwritten!")
+		}
+		sum += p.value
+		n++
+	}
+	println("list:", n, sum)
+
+	// The list is about 32MB, so the heap must have grown beyond the initial
+	// memory size.
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	println("heap grown:", ms.HeapSys > 32*1024*1024)
+
+	// Free the list, and make sure the memory can be reused.
+	list = nil
+	runtime.GC()
+	big := make([]uint64, 4*1024*1024)
+	for i := range big {
+		big[i] = uint64(i) << 32
+	}
+	println("big:", len(big), big[len(big)-1]>>32)
+}
@@ -0,0 +1,34 @@
+package main
+
+// Test for -target=wasm64: addresses beyond 4GiB must not be truncated to 32
+// bits.
+
+import "unsafe"
+
+var value = 2
+
+func main() {
+	// Grow the linear memory beyond 4GiB, and write to the address 4GiB past a
+	// global. If addresses were truncated to 32 bits, this would overwrite the
+	// global itself. The new memory isn't part of the heap, so nothing may be
+	// allocated after growing it.
+	low := &value
+	high := (*int)(unsafe.Add(unsafe.Pointer(low), 4<<30))
+	const pageSize = 64 * 1024
+	pages := int64(uintptr(unsafe.Pointer(high))+unsafe.Sizeof(*high)+pageSize-1) / pageSize
+	if size := wasmMemorySize(0); size < pages {
+		if wasmMemoryGrow(0, pages-size) < 0 {
+			// NodeJS before version 22 limits memory64 to 4GiB.
+			println("could not grow memory beyond 4GiB")
+			return
+		}
+	}
+	*high = 12345
+	println("above 4GiB:", *high, *low)
+}
+
+//export llvm.wasm.memory.size.i64
+func wasmMemorySize(index int32) int64
+
+//export llvm.wasm.memory.grow.i64
+func wasmMemoryGrow(index int32, delta int64) int64
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+	"unsafe"
+)
+
+func init() {
+	println("called init")
+}
+
+func main() {
+}
+
+// The name is stored in a buffer allocated by the host using malloc.
+//
+//go:wasmexport greet
+func greet(name *byte, length uint32) {
+	println("hello,", string(unsafe.Slice(name, length)))
+}
+
+//go:wasmexport now
+func unixTime() int64 {
+	return time.Now().Unix()
+}
+
+//go:wasmexport sleep
+func sleep() bool {
+	start := time.Now()
+	time.Sleep(time.Millisecond)
+	return time.Since(start) >= time.Millisecond
+}
@@ -0,0 +1,207 @@
+//go:build tinygo && machinesim
+
+package machinesim
+
+// Tests for the machine package simulator. Run them with:
+//
+//	tinygo test -tags=machinesim ./tests/machinesim
+
+import (
+	"bytes"
+	"machine"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGPIO(t *testing.T) {
+	led := machine.Pin(1)
+	led.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	led.High()
+	if !led.Get() {
+		t.Error("output pin is low after setting it high")
+	}
+	led.SimDrive(false)
+	if !led.Get() {
+		t.Error("output pin is driven externally")
+	}
+
+	button := machine.Pin(2)
+	button.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+	if !button.Get() {
+		t.Error("input with pull-up is low")
+	}
+	button.SimDrive(false)
+	if button.Get() {
+		t.Error("input is not driven low")
+	}
+	button.SimRelease()
+	if !button.Get() {
+		t.Error("input with pull-up is low after release")
+	}
+}
+
+func TestADC(t *testing.T) {
+	adc := machine.ADC{Pin: machine.Pin(3)}
+	adc.Configure(machine.ADCConfig{})
+	adc.SimSet(0x8000)
+	if value := adc.Get(); value != 0x8000 {
+		t.Errorf("expected ADC value 0x8000, got %#x", value)
+	}
+}
+
+func TestVCD(t *testing.T) {
+	clk := machine.Pin(10)
+	data := machine.Pin(11)
+	clk.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	data.Configure(machine.PinConfig{Mode: machine.PinOutput})
+
+	buf := &bytes.Buffer{}
+	var now time.Duration
+	vcd := machine.SimRecordVCD(buf, clk, data)
+	vcd.Now = func() time.Duration { return now }
+	for _, bit := range []bool{true, false} {
+		now += 100
+		data.Set(bit)
+		clk.High()
+		now += 100
+		clk.Low()
+	}
+	if err := vcd.Stop(); err != nil {
+		t.Fatal("could not write VCD:", err)
+	}
+	clk.High() // not recorded
+
+	expected := strings.Join([]string{
+		"$timescale 1ns $end",
+		"$scope module machine $end",
+		"$var wire 1 ! pin10 $end",
+		"$var wire 1 \" pin11 $end",
+		"$upscope $end",
+		"$enddefinitions $end",
+		"#0",
+		"$dumpvars",
+		"0!",
+		"0\"",
+		"$end",
+		"#100",
+		"1\"",
+		"1!",
+		"#200",
+		"0!",
+		"#300",
+		"0\"",
+		"1!",
+		"#400",
+		"0!",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("unexpected VCD output:\n%s", buf.String())
+	}
+}
+
+func TestI2C(t *testing.T) {
+	i2c := machine.I2C0
+	i2c.Configure(machine.I2CConfig{})
+	sensor := &machine.SimI2CRegisters{}
+	sensor.Registers[0xd0] = 0x60 // chip ID
+	var writes []uint8
+	sensor.OnWrite = func(reg, value uint8) {
+		writes = append(writes, reg, value)
+	}
+	i2c.SimAttach(0x76, sensor)
+
+	// Read a register.
+	id := make([]byte, 1)
+	if err := i2c.ReadRegister(0x76, 0xd0, id); err != nil {
+		t.Fatal("could not read register:", err)
+	}
+	if id[0] != 0x60 {
+		t.Errorf("expected chip ID 0x60, got %#x", id[0])
+	}
+
+	// Write two consecutive registers.
+	if err := i2c.WriteRegister(0x76, 0xf4, []byte{0x27, 0xa0}); err != nil {
+		t.Fatal("could not write register:", err)
+	}
+	if sensor.Registers[0xf4] != 0x27 || sensor.Registers[0xf5] != 0xa0 {
+		t.Error("registers were not written")
+	}
+	if !bytes.Equal(writes, []byte{0xf4, 0x27, 0xf5, 0xa0}) {
+		t.Errorf("unexpected writes: %x", writes)
+	}
+
+	// There is no device at this address.
+	if err := i2c.Tx(0x77, []byte{0xd0}, id); err == nil {
+		t.Error("expected an error for a missing device")
+	}
+}
+
+// Device that returns each byte incremented by one.
+type spiDevice struct {
+	selects int
+}
+
+func (d *spiDevice) Transfer(w byte) byte {
+	return w + 1
+}
+
+func (d *spiDevice) Select(selected bool) {
+	if selected {
+		d.selects++
+	}
+}
+
+func TestSPI(t *testing.T) {
+	spi := machine.SPI0
+	spi.Configure(machine.SPIConfig{})
+	cs := machine.Pin(20)
+	cs.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	cs.High()
+	dev := &spiDevice{}
+	spi.SimAttach(cs, dev)
+
+	r := make([]byte, 3)
+	spi.Tx([]byte{1, 2, 3}, r)
+	if !bytes.Equal(r, []byte{0, 0, 0}) {
+		t.Errorf("device is not selected but returned %v", r)
+	}
+
+	cs.Low()
+	spi.Tx([]byte{1, 2, 3}, r)
+	cs.High()
+	if !bytes.Equal(r, []byte{2, 3, 4}) {
+		t.Errorf("unexpected response %v", r)
+	}
+	if dev.selects != 1 {
+		t.Errorf("device was selected %d times instead of once", dev.selects)
+	}
+}
+
+func TestUART(t *testing.T) {
+	uart := machine.UART1
+	uart.Configure(machine.UARTConfig{})
+
+	uart.SimReceive([]byte("AT\r\n"))
+	if n := uart.Buffered(); n != 4 {
+		t.Errorf("expected 4 bytes buffered, got %d", n)
+	}
+	buf := make([]byte, 16)
+	n, _ := uart.Read(buf)
+	if string(buf[:n]) != "AT\r\n" {
+		t.Errorf("unexpected data read: %q", buf[:n])
+	}
+
+	uart.Write([]byte("OK\r\n"))
+	if data := uart.SimTransmitted(); string(data) != "OK\r\n" {
+		t.Errorf("unexpected data written: %q", data)
+	}
+
+	uart.SimLoopback(true)
+	uart.Write([]byte("ping"))
+	n, _ = uart.Read(buf)
+	if string(buf[:n]) != "ping" {
+		t.Errorf("loopback returned %q", buf[:n])
+	}
+}
@@ -0,0 +1,37 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestJSImport(t *testing.T) {
+
+	wasmTmpDir, server := startServer(t)
+
+	err := run(t, "tinygo build -o "+wasmTmpDir+"/jsimport.wasm -target wasm testdata/jsimport.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := chromectx(t)
+
+	var log1 string
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(server.URL+"/run?file=jsimport.wasm"),
+		chromedp.InnerHTML("#log", &log1),
+		waitLog(`main
+jsimport
++5.000000e+000
+-21
+true false
+3 1
+18446744073709551615 1`),
+	)
+	t.Logf("log1: %s", log1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+}
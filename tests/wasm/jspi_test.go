@@ -0,0 +1,51 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+func TestJSPI(t *testing.T) {
+
+	wasmTmpDir, server := startServer(t)
+
+	err := run(t, "tinygo build -o "+wasmTmpDir+"/jspi.wasm -target wasm -scheduler=jspi testdata/jspi.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := chromectx(t)
+
+	var supported bool
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(server.URL+"/run?file=jspi.wasm"),
+		chromedp.Evaluate(`typeof WebAssembly.promising === "function"`, &supported),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !supported {
+		t.Skip("browser does not support JSPI")
+	}
+
+	var sum, slowSum int
+	err = chromedp.Run(ctx,
+		waitLog(`goroutine: 1
+ready`),
+		chromedp.Evaluate(`add(2, 3)`, &sum),
+		chromedp.Evaluate(`slowAdd(4, 5)`, &slowSum, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 5 {
+		t.Errorf("add(2, 3) returned %d, expected 5", sum)
+	}
+	if slowSum != 9 {
+		t.Errorf("slowAdd(4, 5) returned %d, expected 9", slowSum)
+	}
+}
@@ -0,0 +1,31 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Test recover() using the WebAssembly exception handling proposal.
+func TestRecover(t *testing.T) {
+
+	wasmTmpDir, server := startServer(t)
+
+	err := run(t, "tinygo build -o "+wasmTmpDir+"/recover.wasm -target wasm -scheduler=none -llvm-features=+exception-handling testdata/recover.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := chromectx(t)
+
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(server.URL+"/run?file=recover.wasm"),
+		waitLog(`div: 2
+div by zero: -1
+recovered: boom
+nested: 10`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
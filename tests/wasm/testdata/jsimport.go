@@ -0,0 +1,43 @@
+package main
+
+import "syscall/js"
+
+//go:jsimport document.querySelector
+func querySelector(selector string) js.Value
+
+//go:jsimport .setAttribute
+func setAttribute(element js.Value, name, value string)
+
+//go:jsimport .getAttribute
+func getAttribute(element js.Value, name string) js.Value
+
+//go:jsimport Math.max
+func mathMax(a, b float64) float64
+
+//go:jsimport Math.imul
+func mathImul(a, b int32) int32
+
+//go:jsimport Number.isInteger
+func isInteger(x float64) bool
+
+//go:jsimport Array.prototype.indexOf.call
+func indexOf(array js.Value, value float64) int
+
+//go:jsimport BigInt.asUintN
+func asUintN(bits float64, x int64) uint64
+
+//go:jsimport Float32Array.from
+func float32ArrayFrom(data []float32) js.Value
+
+func main() {
+	element := querySelector("#main")
+	println(element.Get("id").String())
+	setAttribute(element, "data-test", "jsimport")
+	println(getAttribute(element, "data-test").String())
+	println(mathMax(3, 5))
+	println(mathImul(-3, 7))
+	println(isInteger(2), isInteger(2.5))
+	array := float32ArrayFrom([]float32{1.5, 2.5, 3.5})
+	println(array.Length(), indexOf(array, 2.5))
+	println(asUintN(64, -1), asUintN(8, 1<<60+257))
+}
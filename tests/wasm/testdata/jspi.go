@@ -0,0 +1,34 @@
+package main
+
+import (
+	"syscall/js"
+	"time"
+)
+
+func main() {
+	ch := make(chan int)
+	go func() {
+		time.Sleep(time.Millisecond)
+		ch <- 1
+	}()
+	println("goroutine:", <-ch)
+
+	// The result of this handler is returned directly.
+	js.Global().Set("add", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return args[0].Int() + args[1].Int()
+	}))
+
+	// This handler has to wait for another goroutine, so the result is
+	// returned as a promise.
+	js.Global().Set("slowAdd", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		result := make(chan int)
+		go func() {
+			time.Sleep(time.Millisecond)
+			result <- args[0].Int() + args[1].Int()
+		}()
+		return <-result
+	}))
+
+	println("ready")
+	<-make(chan struct{})
+}
@@ -0,0 +1,33 @@
+package main
+
+func main() {
+	println("div:", safeDiv(6, 3))
+	println("div by zero:", safeDiv(1, 0))
+	println("recovered:", recoverValue())
+	println("nested:", nested())
+}
+
+func safeDiv(a, b int) (n int) {
+	defer func() {
+		if recover() != nil {
+			n = -1
+		}
+	}()
+	return a / b
+}
+
+func recoverValue() (s string) {
+	defer func() {
+		s = recover().(string)
+	}()
+	panic("boom")
+}
+
+func nested() (n int) {
+	defer func() {
+		recover()
+		n++
+	}()
+	n = safeDiv(1, 0) + 10
+	panic("again")
+}
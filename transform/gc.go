@@ -1,12 +1,21 @@
 package transform
 
 import (
+	"fmt"
+	"math/big"
+
 	"tinygo.org/x/go-llvm"
 )
 
 // MakeGCStackSlots converts all calls to runtime.trackPointer to explicit
 // stores to stack slots that are scannable by the GC.
-func MakeGCStackSlots(mod llvm.Module) bool {
+//
+// If precise is set, the stack objects form a precise stack map: the second
+// word of each stack object is an object layout (in the same format as heap
+// object layouts) instead of a slot count, and all allocas that may contain
+// pointers are moved into the stack object. This way, the GC never needs to
+// scan the stack conservatively.
+func MakeGCStackSlots(mod llvm.Module, precise bool) bool {
 	// Check whether there are allocations at all.
 	alloc := mod.NamedFunction("runtime.alloc")
 	if alloc.IsNil() {
@@ -34,7 +43,6 @@ func MakeGCStackSlots(mod llvm.Module) bool {
 	defer builder.Dispose()
 	targetData := llvm.NewTargetData(mod.DataLayout())
 	defer targetData.Dispose()
-	uintptrType := ctx.IntType(targetData.PointerSize() * 8)
 
 	// Look at *all* functions to see whether they are free of function pointer
 	// calls.
@@ -75,6 +83,23 @@ func MakeGCStackSlots(mod llvm.Module) bool {
 	// a heap allocation (and thus which functions do not).
 	markParentFunctions(allocatingFunctions, alloc)
 
+	// With -scheduler=threads, the GC may also run while a goroutine is
+	// blocked (for example while waiting for a lock). Such functions need to
+	// keep their pointers on the stack just like allocating functions.
+	if park := mod.NamedFunction("internal/task.gcPark"); !park.IsNil() {
+		markParentFunctions(allocatingFunctions, park)
+	}
+
+	// With a precise stack map, the stacks of paused goroutines are not scanned
+	// conservatively. So the GC may also run while a goroutine is paused (for
+	// example while blocked on a channel or sleeping), and functions that
+	// pause need to keep their pointers in stack objects as well.
+	if precise {
+		if pause := mod.NamedFunction("internal/task.Pause"); !pause.IsNil() {
+			markParentFunctions(allocatingFunctions, pause)
+		}
+	}
+
 	// Also trace all functions that call a function pointer.
 	for fn := range funcsWithFPCall {
 		// Assume that functions that call a function pointer do a heap
@@ -96,10 +121,10 @@ func MakeGCStackSlots(mod llvm.Module) bool {
 		return false
 	}
 	stackChainStart.SetLinkage(llvm.InternalLinkage)
-	stackChainStartType := stackChainStart.GlobalValueType()
-	stackChainStart.SetInitializer(llvm.ConstNull(stackChainStartType))
+	stackChainStart.SetInitializer(llvm.ConstNull(stackChainStart.GlobalValueType()))
 
 	// Iterate until runtime.trackPointer has no uses left.
+	handledFunctions := map[llvm.Value]struct{}{}
 	for use := trackPointer.FirstUse(); !use.IsNil(); use = trackPointer.FirstUse() {
 		// Pick the first use of runtime.trackPointer.
 		call := use.User()
@@ -121,19 +146,14 @@ func MakeGCStackSlots(mod llvm.Module) bool {
 			call.EraseFromParentAsInstruction()
 			continue
 		}
+		handledFunctions[fn] = struct{}{}
 
 		// Find all calls to runtime.trackPointer in this function.
 		var calls []llvm.Value
-		var returns []llvm.Value
 		for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
 			for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
-				switch inst.InstructionOpcode() {
-				case llvm.Call:
-					if inst.CalledValue() == trackPointer {
-						calls = append(calls, inst)
-					}
-				case llvm.Ret:
-					returns = append(returns, inst)
+				if inst.InstructionOpcode() == llvm.Call && inst.CalledValue() == trackPointer {
+					calls = append(calls, inst)
 				}
 			}
 		}
@@ -188,87 +208,393 @@ func MakeGCStackSlots(mod llvm.Module) bool {
 
 			if ptr := stripPointerCasts(ptr); !ptr.IsAAllocaInst().IsNil() {
 				// Allocas don't need to be tracked because they are allocated
-				// on the C stack which is scanned separately.
+				// on the C stack which is scanned separately (or, with precise
+				// stack maps, are moved into the stack object below).
 				continue
 			}
 			pointers = append(pointers, ptr)
 		}
 
-		if len(pointers) == 0 {
+		var allocas []llvm.Value
+		if precise {
+			allocas = findPointerAllocas(fn, targetData)
+		}
+
+		if len(pointers) == 0 && len(allocas) == 0 {
 			// This function does not need to keep track of stack pointers.
 			continue
 		}
 
-		// Determine the type of the required stack slot.
-		fields := []llvm.Type{
-			stackChainStartType, // Pointer to parent frame.
-			uintptrType,         // Number of elements in this frame.
+		createStackObject(fn, pointers, allocas, stackChainStart, precise, builder, targetData)
+	}
+
+	if precise {
+		// Allocas that contain pointers must be part of the stack map, even in
+		// functions that don't call runtime.trackPointer.
+		for fn := mod.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+			if _, ok := allocatingFunctions[fn]; !ok {
+				continue
+			}
+			if _, ok := handledFunctions[fn]; ok {
+				continue
+			}
+			allocas := findPointerAllocas(fn, targetData)
+			if len(allocas) == 0 {
+				continue
+			}
+			createStackObject(fn, nil, allocas, stackChainStart, precise, builder, targetData)
+		}
+	}
+
+	return true
+}
+
+// createStackObject creates a stack object at the start of the function, links
+// it into the stack chain and stores all pointers in it right after they are
+// created. With precise stack maps, the given allocas are replaced with fields
+// in the stack object.
+func createStackObject(fn llvm.Value, pointers, allocas []llvm.Value, stackChainStart llvm.Value, precise bool, builder llvm.Builder, targetData llvm.TargetData) {
+	ctx := fn.GlobalParent().Context()
+	stackChainStartType := stackChainStart.GlobalValueType()
+	uintptrType := ctx.IntType(targetData.PointerSize() * 8)
+
+	// Find all return instructions.
+	var returns []llvm.Value
+	for bb := fn.FirstBasicBlock(); !bb.IsNil(); bb = llvm.NextBasicBlock(bb) {
+		for inst := bb.FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+			if inst.InstructionOpcode() == llvm.Ret {
+				returns = append(returns, inst)
+			}
 		}
-		for _, ptr := range pointers {
-			fields = append(fields, ptr.Type())
+	}
+
+	// Determine the type of the required stack slot.
+	fields := []llvm.Type{
+		stackChainStartType, // Pointer to parent frame.
+		uintptrType,         // Number of elements or layout of this frame.
+	}
+	for _, ptr := range pointers {
+		fields = append(fields, ptr.Type())
+	}
+	allocaFields := make([]int, len(allocas))
+	for i, alloca := range allocas {
+		// Add padding if the alloca needs a higher alignment than its type.
+		fieldType := alloca.AllocatedType()
+		lastField := len(fields) - 1
+		end := targetData.ElementOffset(ctx.StructType(fields, false), lastField) + targetData.TypeAllocSize(fields[lastField])
+		abiAlign := uint64(targetData.ABITypeAlignment(fieldType))
+		offset := (end + abiAlign - 1) / abiAlign * abiAlign
+		if align := uint64(alloca.Alignment()); offset%align != 0 {
+			padding := (offset+align-1)/align*align - end
+			fields = append(fields, llvm.ArrayType(ctx.Int8Type(), int(padding)))
 		}
-		stackObjectType := ctx.StructType(fields, false)
+		allocaFields[i] = len(fields)
+		fields = append(fields, fieldType)
+	}
+	stackObjectType := ctx.StructType(fields, false)
 
-		// Create the stack object at the function entry.
-		builder.SetInsertPointBefore(fn.EntryBasicBlock().FirstInstruction())
-		stackObject := builder.CreateAlloca(stackObjectType, "gc.stackobject")
-		initialStackObject := llvm.ConstNull(stackObjectType)
+	// Create the stack object at the function entry.
+	builder.SetInsertPointBefore(fn.EntryBasicBlock().FirstInstruction())
+	stackObject := builder.CreateAlloca(stackObjectType, "gc.stackobject")
+	for _, alloca := range allocas {
+		if alloca.Alignment() > stackObject.Alignment() {
+			stackObject.SetAlignment(alloca.Alignment())
+		}
+	}
+	initialStackObject := llvm.ConstNull(stackObjectType)
+	var header llvm.Value
+	if precise {
+		header = createStackObjectLayout(fn.GlobalParent(), stackObjectType, len(pointers), allocas, allocaFields, targetData)
+	} else {
 		numSlots := (targetData.TypeAllocSize(stackObjectType) - uint64(targetData.PointerSize())*2) / uint64(targetData.ABITypeAlignment(uintptrType))
-		numSlotsValue := llvm.ConstInt(uintptrType, numSlots, false)
-		initialStackObject = builder.CreateInsertValue(initialStackObject, numSlotsValue, 1, "")
-		builder.CreateStore(initialStackObject, stackObject)
+		header = llvm.ConstInt(uintptrType, numSlots, false)
+	}
+	initialStackObject = builder.CreateInsertValue(initialStackObject, header, 1, "")
+	builder.CreateStore(initialStackObject, stackObject)
 
-		// Update stack start.
-		parent := builder.CreateLoad(stackChainStartType, stackChainStart, "")
+	// Replace the allocas with the corresponding field in the stack object.
+	allocaGEPs := make([]llvm.Value, len(allocas))
+	for i, alloca := range allocas {
 		gep := builder.CreateGEP(stackObjectType, stackObject, []llvm.Value{
 			llvm.ConstInt(ctx.Int32Type(), 0, false),
+			llvm.ConstInt(ctx.Int32Type(), uint64(allocaFields[i]), false),
+		}, "")
+		alloca.ReplaceAllUsesWith(gep)
+		allocaGEPs[i] = gep
+	}
+
+	// Update stack start.
+	parent := builder.CreateLoad(stackChainStartType, stackChainStart, "")
+	gep := builder.CreateGEP(stackObjectType, stackObject, []llvm.Value{
+		llvm.ConstInt(ctx.Int32Type(), 0, false),
+		llvm.ConstInt(ctx.Int32Type(), 0, false),
+	}, "")
+	builder.CreateStore(parent, gep)
+	builder.CreateStore(stackObject, stackChainStart)
+
+	// Do a store to the stack object after each new pointer that is created.
+	for i, ptr := range pointers {
+		// Insert the store after the pointer value is created.
+		insertionPoint := llvm.NextInstruction(ptr)
+		for !insertionPoint.IsAPHINode().IsNil() {
+			// PHI nodes are required to be at the start of the block.
+			// Insert after the last PHI node.
+			insertionPoint = llvm.NextInstruction(insertionPoint)
+		}
+		builder.SetInsertPointBefore(insertionPoint)
+
+		// Extract a pointer to the appropriate section of the stack object.
+		gep := builder.CreateGEP(stackObjectType, stackObject, []llvm.Value{
 			llvm.ConstInt(ctx.Int32Type(), 0, false),
+			llvm.ConstInt(ctx.Int32Type(), uint64(2+i), false),
 		}, "")
-		builder.CreateStore(parent, gep)
-		builder.CreateStore(stackObject, stackChainStart)
-
-		// Do a store to the stack object after each new pointer that is created.
-		pointerStores := make(map[llvm.Value]struct{})
-		for i, ptr := range pointers {
-			// Insert the store after the pointer value is created.
-			insertionPoint := llvm.NextInstruction(ptr)
-			for !insertionPoint.IsAPHINode().IsNil() {
-				// PHI nodes are required to be at the start of the block.
-				// Insert after the last PHI node.
-				insertionPoint = llvm.NextInstruction(insertionPoint)
-			}
-			builder.SetInsertPointBefore(insertionPoint)
-
-			// Extract a pointer to the appropriate section of the stack object.
-			gep := builder.CreateGEP(stackObjectType, stackObject, []llvm.Value{
-				llvm.ConstInt(ctx.Int32Type(), 0, false),
-				llvm.ConstInt(ctx.Int32Type(), uint64(2+i), false),
-			}, "")
-
-			// Store the pointer into the stack slot.
-			store := builder.CreateStore(ptr, gep)
-			pointerStores[store] = struct{}{}
-		}
-
-		// Make sure this stack object is popped from the linked list of stack
-		// objects at return.
-		for _, ret := range returns {
-			// Check for any tail calls at this return.
-			prev := llvm.PrevInstruction(ret)
-			if !prev.IsNil() && !prev.IsABitCastInst().IsNil() {
-				// A bitcast can appear before a tail call, so skip backwards more.
-				prev = llvm.PrevInstruction(prev)
-			}
-			if !prev.IsNil() && !prev.IsACallInst().IsNil() {
-				// This is no longer a tail call.
-				prev.SetTailCall(false)
+
+		// Store the pointer into the stack slot.
+		builder.CreateStore(ptr, gep)
+	}
+
+	// Make sure this stack object is popped from the linked list of stack
+	// objects at return.
+	for _, ret := range returns {
+		// Check for any tail calls at this return.
+		prev := llvm.PrevInstruction(ret)
+		if !prev.IsNil() && !prev.IsABitCastInst().IsNil() {
+			// A bitcast can appear before a tail call, so skip backwards more.
+			prev = llvm.PrevInstruction(prev)
+		}
+		if !prev.IsNil() && !prev.IsACallInst().IsNil() {
+			// This is no longer a tail call.
+			prev.SetTailCall(false)
+		}
+		builder.SetInsertPointBefore(ret)
+		builder.CreateStore(parent, stackChainStart)
+	}
+
+	// Remove the allocas that were replaced. This is done at the end, as the
+	// builder may have been positioned before one of them.
+	for i, alloca := range allocas {
+		name := alloca.Name()
+		alloca.EraseFromParentAsInstruction()
+		allocaGEPs[i].SetName(name)
+	}
+}
+
+// MakeGlobalsTable creates a table of all globals that may contain pointers,
+// for the runtime.findGlobals implementation of static libraries
+// (-buildmode=c-archive). Normally the GC scans all globals using
+// linker-defined symbols, but these are not available when linking into
+// foreign firmware.
+//
+// This must be run after all other optimizations, because the table keeps all
+// these globals alive.
+func MakeGlobalsTable(mod llvm.Module) {
+	table := mod.NamedGlobal("runtime.globalsTable")
+	if table.IsNil() || !table.Initializer().IsNil() {
+		// Not used, or already created.
+		return
+	}
+
+	ctx := mod.Context()
+	targetData := llvm.NewTargetData(mod.DataLayout())
+	defer targetData.Dispose()
+	ptrType := llvm.PointerType(ctx.Int8Type(), 0)
+	uintptrType := ctx.IntType(targetData.PointerSize() * 8)
+	ptrAlign := uint64(targetData.ABITypeAlignment(ptrType))
+	entryType := ctx.StructType([]llvm.Type{ptrType, uintptrType}, false)
+
+	var entries []llvm.Value
+	for global := mod.FirstGlobal(); !global.IsNil(); global = llvm.NextGlobal(global) {
+		if global == table || global.IsDeclaration() || global.IsGlobalConstant() || global.IsThreadLocal() {
+			continue
+		}
+		typ := global.GlobalValueType()
+		if !typeHasPointers(typ) && !isByteArray(typ) {
+			// Byte arrays are included as well, because LLVM may have
+			// replaced the original type of the global with a byte array.
+			continue
+		}
+		align := uint64(global.Alignment())
+		if align == 0 {
+			align = uint64(targetData.ABITypeAlignment(typ))
+		}
+		if align < ptrAlign {
+			// Pointers are always aligned, so this global doesn't contain any.
+			continue
+		}
+		size := targetData.TypeAllocSize(typ) &^ (ptrAlign - 1)
+		if size < uint64(targetData.PointerSize()) {
+			// Too small to contain a pointer.
+			continue
+		}
+		entries = append(entries, ctx.ConstStruct([]llvm.Value{
+			global,
+			llvm.ConstInt(uintptrType, size, false),
+		}, false))
+	}
+
+	// Create the table as {uintptr, [n]{ptr, uintptr}} and replace the
+	// declaration from the runtime with it.
+	initializer := ctx.ConstStruct([]llvm.Value{
+		llvm.ConstInt(uintptrType, uint64(len(entries)), false),
+		llvm.ConstArray(entryType, entries),
+	}, false)
+	newTable := llvm.AddGlobal(mod, initializer.Type(), "")
+	newTable.SetInitializer(initializer)
+	newTable.SetGlobalConstant(true)
+	newTable.SetLinkage(llvm.InternalLinkage)
+	newTable.SetAlignment(targetData.ABITypeAlignment(initializer.Type()))
+	table.ReplaceAllUsesWith(newTable)
+	name := table.Name()
+	table.EraseFromParentAsGlobal()
+	newTable.SetName(name)
+}
+
+// findPointerAllocas returns all allocas in the entry block of the function
+// that may contain a pointer. Byte arrays are included as well, because LLVM
+// may have replaced the original type of the alloca with a byte array.
+func findPointerAllocas(fn llvm.Value, targetData llvm.TargetData) []llvm.Value {
+	var allocas []llvm.Value
+	for inst := fn.EntryBasicBlock().FirstInstruction(); !inst.IsNil(); inst = llvm.NextInstruction(inst) {
+		if inst.IsAAllocaInst().IsNil() {
+			continue
+		}
+		if size := inst.Operand(0); size.IsAConstantInt().IsNil() || size.ZExtValue() != 1 {
+			// Dynamic or array allocas can't be part of a stack object.
+			continue
+		}
+		typ := inst.AllocatedType()
+		if targetData.TypeAllocSize(typ) < uint64(targetData.PointerSize()) {
+			// Too small to contain a pointer.
+			continue
+		}
+		if typeHasPointers(typ) || isByteArray(typ) {
+			allocas = append(allocas, inst)
+		}
+	}
+	return allocas
+}
+
+// createStackObjectLayout returns the layout value for the given stack object
+// type, in the same format as the layouts used for heap objects (see
+// gc_precise.go in the runtime). The layout covers all words after the two
+// header words. Byte arrays from allocas are conservatively marked as if all
+// words may be pointers.
+func createStackObjectLayout(mod llvm.Module, stackObjectType llvm.Type, numPointers int, allocas []llvm.Value, allocaFields []int, targetData llvm.TargetData) llvm.Value {
+	ctx := mod.Context()
+	pointerSize := uint64(targetData.PointerSize())
+	uintptrType := ctx.IntType(int(pointerSize) * 8)
+	headerSize := pointerSize * 2
+	objectSizeWords := (targetData.TypeAllocSize(stackObjectType) - headerSize) / pointerSize
+
+	bitmap := new(big.Int)
+	for i := 0; i < numPointers; i++ {
+		offset := targetData.ElementOffset(stackObjectType, 2+i)
+		bitmap.SetBit(bitmap, int((offset-headerSize)/pointerSize), 1)
+	}
+	for i, alloca := range allocas {
+		offset := targetData.ElementOffset(stackObjectType, allocaFields[i])
+		setPointerBits(bitmap, alloca.AllocatedType(), offset-headerSize, targetData)
+	}
+
+	var sizeFieldBits uint64
+	switch pointerSize * 8 {
+	case 16:
+		sizeFieldBits = 4
+	case 32:
+		sizeFieldBits = 5
+	case 64:
+		sizeFieldBits = 6
+	default:
+		panic("unknown pointer size")
+	}
+	layoutFieldBits := pointerSize*8 - 1 - sizeFieldBits
+
+	if objectSizeWords < layoutFieldBits {
+		// Store the layout directly in the pointer-sized value.
+		layout := bitmap.Uint64()<<(sizeFieldBits+1) | (objectSizeWords << 1) | 1
+		return llvm.ConstInt(uintptrType, layout, false)
+	}
+
+	// The layout doesn't fit in a single word, so store it in a global. This
+	// uses the same naming scheme as the compiler so that identical layouts
+	// are merged.
+	globalName := "runtime/gc.layout:" + fmt.Sprintf("%d-%0*x", objectSizeWords, (objectSizeWords+15)/16, bitmap)
+	global := mod.NamedGlobal(globalName)
+	if global.IsNil() {
+		bitmapBytes := make([]byte, int(objectSizeWords+7)/8)
+		bitmap.FillBytes(bitmapBytes)
+		var bitmapByteValues []llvm.Value
+		for i := len(bitmapBytes) - 1; i >= 0; i-- {
+			// Big-endian to little-endian.
+			bitmapByteValues = append(bitmapByteValues, llvm.ConstInt(ctx.Int8Type(), uint64(bitmapBytes[i]), false))
+		}
+		initializer := ctx.ConstStruct([]llvm.Value{
+			llvm.ConstInt(uintptrType, objectSizeWords, false),
+			llvm.ConstArray(ctx.Int8Type(), bitmapByteValues),
+		}, false)
+		global = llvm.AddGlobal(mod, initializer.Type(), globalName)
+		global.SetInitializer(initializer)
+		global.SetUnnamedAddr(true)
+		global.SetGlobalConstant(true)
+		global.SetLinkage(llvm.LinkOnceODRLinkage)
+		if targetData.PrefTypeAlignment(uintptrType) < 2 {
+			// AVR doesn't have alignment by default.
+			global.SetAlignment(2)
+		}
+	}
+	return llvm.ConstPtrToInt(global, uintptrType)
+}
+
+// setPointerBits sets the bits in the bitmap for all words (starting at the
+// given byte offset) that may contain a pointer.
+func setPointerBits(bitmap *big.Int, t llvm.Type, offset uint64, targetData llvm.TargetData) {
+	pointerSize := uint64(targetData.PointerSize())
+	switch t.TypeKind() {
+	case llvm.PointerTypeKind:
+		bitmap.SetBit(bitmap, int(offset/pointerSize), 1)
+	case llvm.StructTypeKind:
+		for i, subType := range t.StructElementTypes() {
+			setPointerBits(bitmap, subType, offset+targetData.ElementOffset(t, i), targetData)
+		}
+	case llvm.ArrayTypeKind:
+		if isByteArray(t) {
+			// Unknown contents, so treat every word as a possible pointer.
+			size := targetData.TypeAllocSize(t)
+			for word := (offset + pointerSize - 1) / pointerSize; (word+1)*pointerSize <= offset+size; word++ {
+				bitmap.SetBit(bitmap, int(word), 1)
 			}
-			builder.SetInsertPointBefore(ret)
-			builder.CreateStore(parent, stackChainStart)
+			return
+		}
+		elementType := t.ElementType()
+		elementSize := targetData.TypeAllocSize(elementType)
+		for i := 0; i < t.ArrayLength(); i++ {
+			setPointerBits(bitmap, elementType, offset+uint64(i)*elementSize, targetData)
 		}
 	}
+}
 
-	return true
+// isByteArray returns whether the given type is an array of i8.
+func isByteArray(t llvm.Type) bool {
+	return t.TypeKind() == llvm.ArrayTypeKind && t.ElementType().TypeKind() == llvm.IntegerTypeKind && t.ElementType().IntTypeWidth() == 8
+}
+
+// typeHasPointers returns whether this type is a pointer or contains pointers.
+func typeHasPointers(t llvm.Type) bool {
+	switch t.TypeKind() {
+	case llvm.PointerTypeKind:
+		return true
+	case llvm.StructTypeKind:
+		for _, subType := range t.StructElementTypes() {
+			if typeHasPointers(subType) {
+				return true
+			}
+		}
+		return false
+	case llvm.ArrayTypeKind:
+		return typeHasPointers(t.ElementType())
+	default:
+		return false
+	}
 }
 
 // markParentFunctions traverses all parent function calls (recursively) and
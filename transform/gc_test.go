@@ -10,6 +10,20 @@ import (
 func TestMakeGCStackSlots(t *testing.T) {
 	t.Parallel()
 	testTransform(t, "testdata/gc-stackslots", func(mod llvm.Module) {
-		transform.MakeGCStackSlots(mod)
+		transform.MakeGCStackSlots(mod, false)
+	})
+}
+
+func TestMakeGCStackSlotsPrecise(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/gc-stackslots-precise", func(mod llvm.Module) {
+		transform.MakeGCStackSlots(mod, true)
+	})
+}
+
+func TestMakeGlobalsTable(t *testing.T) {
+	t.Parallel()
+	testTransform(t, "testdata/globals-table", func(mod llvm.Module) {
+		transform.MakeGlobalsTable(mod)
 	})
 }
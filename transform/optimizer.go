@@ -154,13 +154,19 @@ func Optimize(mod llvm.Module, config *compileopts.Config) []error {
 		return []error{fmt.Errorf("could not build pass pipeline: %w", err)}
 	}
 
-	hasGCPass := MakeGCStackSlots(mod)
+	hasGCPass := MakeGCStackSlots(mod, config.PreciseStackMaps())
 	if hasGCPass {
 		if err := llvm.VerifyModule(mod, llvm.PrintMessageAction); err != nil {
 			return []error{errors.New("GC pass caused a verification failure")}
 		}
 	}
 
+	// Static libraries can't use linker-defined symbols to find all globals, so
+	// create a table of them instead.
+	if config.BuildMode() == "c-archive" {
+		MakeGlobalsTable(mod)
+	}
+
 	return nil
 }
 
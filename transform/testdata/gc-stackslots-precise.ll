@@ -0,0 +1,75 @@
+target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "thumbv7em-unknown-unknown-eabi"
+
+@runtime.stackChainStart = external global ptr
+@someGlobal = global i8 3
+
+declare void @runtime.trackPointer(ptr nocapture readonly)
+
+declare noalias nonnull ptr @runtime.alloc(i32, ptr)
+
+declare void @usePointer(ptr)
+
+declare void @"internal/task.Pause"()
+
+; Both the tracked pointer and the alloca containing a pointer must be part of
+; the stack map. The integer alloca doesn't need to be.
+define ptr @trackedAndAlloca() {
+  %str = alloca { ptr, i32 }, align 4
+  %buf = alloca [8 x i8], align 4
+  %num = alloca i32, align 4
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  call void @runtime.trackPointer(ptr %ptr)
+  store ptr %ptr, ptr %str, align 4
+  call void @usePointer(ptr %buf)
+  call void @usePointer(ptr %num)
+  %other = call ptr @runtime.alloc(i32 4, ptr null)
+  call void @runtime.trackPointer(ptr %other)
+  ret ptr %ptr
+}
+
+; This function has no tracked pointers, but the alloca must still be part of
+; the stack map.
+define void @onlyAlloca() {
+  %slice = alloca { ptr, i32, i32 }, align 4
+  call void @usePointer(ptr %slice)
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  ret void
+}
+
+; This function doesn't allocate, but the GC may run while it is paused, so it
+; needs a stack object just like an allocating function.
+define void @pausingFunction() {
+  %slice = alloca { ptr, i32, i32 }, align 4
+  call void @usePointer(ptr %slice)
+  call void @"internal/task.Pause"()
+  ret void
+}
+
+; The layout of this stack object doesn't fit in a single word.
+define void @largeAlloca() {
+  %arr = alloca [30 x ptr], align 4
+  call void @usePointer(ptr %arr)
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  ret void
+}
+
+; The alloca has a higher alignment than its type, so padding is needed.
+define void @alignedAlloca() {
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  call void @runtime.trackPointer(ptr %ptr)
+  %aligned = alloca { ptr, ptr }, align 16
+  call void @usePointer(ptr %aligned)
+  ret void
+}
+
+; This function doesn't allocate, so it doesn't need a stack object.
+define void @noAllocatingFunction() {
+  %str = alloca { ptr, i32 }, align 4
+  call void @usePointer2(ptr %str)
+  ret void
+}
+
+define void @usePointer2(ptr %ptr) {
+  ret void
+}
@@ -0,0 +1,105 @@
+target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "thumbv7em-unknown-unknown-eabi"
+
+@runtime.stackChainStart = internal global ptr null
+@someGlobal = global i8 3
+@"runtime/gc.layout:30-3fffffff" = linkonce_odr unnamed_addr constant { i32, [4 x i8] } { i32 30, [4 x i8] c"\FF\FF\FF?" }
+
+declare void @runtime.trackPointer(ptr nocapture readonly)
+
+declare noalias nonnull ptr @runtime.alloc(i32, ptr)
+
+declare void @usePointer(ptr)
+
+declare void @"internal/task.Pause"()
+
+define ptr @trackedAndAlloca() {
+  %gc.stackobject = alloca { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, align 4
+  store { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] } { ptr null, i32 3533, ptr null, ptr null, { ptr, i32 } zeroinitializer, [8 x i8] zeroinitializer }, ptr %gc.stackobject, align 4
+  %str = getelementptr { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, ptr %gc.stackobject, i32 0, i32 4
+  %buf = getelementptr { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, ptr %gc.stackobject, i32 0, i32 5
+  %1 = load ptr, ptr @runtime.stackChainStart, align 4
+  %2 = getelementptr { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, ptr %gc.stackobject, i32 0, i32 0
+  store ptr %1, ptr %2, align 4
+  store ptr %gc.stackobject, ptr @runtime.stackChainStart, align 4
+  %num = alloca i32, align 4
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  %3 = getelementptr { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, ptr %gc.stackobject, i32 0, i32 2
+  store ptr %ptr, ptr %3, align 4
+  store ptr %ptr, ptr %str, align 4
+  call void @usePointer(ptr %buf)
+  call void @usePointer(ptr %num)
+  %other = call ptr @runtime.alloc(i32 4, ptr null)
+  %4 = getelementptr { ptr, i32, ptr, ptr, { ptr, i32 }, [8 x i8] }, ptr %gc.stackobject, i32 0, i32 3
+  store ptr %other, ptr %4, align 4
+  store ptr %1, ptr @runtime.stackChainStart, align 4
+  ret ptr %ptr
+}
+
+define void @onlyAlloca() {
+  %gc.stackobject = alloca { ptr, i32, { ptr, i32, i32 } }, align 4
+  store { ptr, i32, { ptr, i32, i32 } } { ptr null, i32 71, { ptr, i32, i32 } zeroinitializer }, ptr %gc.stackobject, align 4
+  %slice = getelementptr { ptr, i32, { ptr, i32, i32 } }, ptr %gc.stackobject, i32 0, i32 2
+  %1 = load ptr, ptr @runtime.stackChainStart, align 4
+  %2 = getelementptr { ptr, i32, { ptr, i32, i32 } }, ptr %gc.stackobject, i32 0, i32 0
+  store ptr %1, ptr %2, align 4
+  store ptr %gc.stackobject, ptr @runtime.stackChainStart, align 4
+  call void @usePointer(ptr %slice)
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  store ptr %1, ptr @runtime.stackChainStart, align 4
+  ret void
+}
+
+define void @pausingFunction() {
+  %gc.stackobject = alloca { ptr, i32, { ptr, i32, i32 } }, align 4
+  store { ptr, i32, { ptr, i32, i32 } } { ptr null, i32 71, { ptr, i32, i32 } zeroinitializer }, ptr %gc.stackobject, align 4
+  %slice = getelementptr { ptr, i32, { ptr, i32, i32 } }, ptr %gc.stackobject, i32 0, i32 2
+  %1 = load ptr, ptr @runtime.stackChainStart, align 4
+  %2 = getelementptr { ptr, i32, { ptr, i32, i32 } }, ptr %gc.stackobject, i32 0, i32 0
+  store ptr %1, ptr %2, align 4
+  store ptr %gc.stackobject, ptr @runtime.stackChainStart, align 4
+  call void @usePointer(ptr %slice)
+  call void @"internal/task.Pause"()
+  store ptr %1, ptr @runtime.stackChainStart, align 4
+  ret void
+}
+
+define void @largeAlloca() {
+  %gc.stackobject = alloca { ptr, i32, [30 x ptr] }, align 4
+  store { ptr, i32, [30 x ptr] } { ptr null, i32 ptrtoint (ptr @"runtime/gc.layout:30-3fffffff" to i32), [30 x ptr] zeroinitializer }, ptr %gc.stackobject, align 4
+  %arr = getelementptr { ptr, i32, [30 x ptr] }, ptr %gc.stackobject, i32 0, i32 2
+  %1 = load ptr, ptr @runtime.stackChainStart, align 4
+  %2 = getelementptr { ptr, i32, [30 x ptr] }, ptr %gc.stackobject, i32 0, i32 0
+  store ptr %1, ptr %2, align 4
+  store ptr %gc.stackobject, ptr @runtime.stackChainStart, align 4
+  call void @usePointer(ptr %arr)
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  store ptr %1, ptr @runtime.stackChainStart, align 4
+  ret void
+}
+
+define void @alignedAlloca() {
+  %gc.stackobject = alloca { ptr, i32, ptr, [4 x i8], { ptr, ptr } }, align 16
+  store { ptr, i32, ptr, [4 x i8], { ptr, ptr } } { ptr null, i32 841, ptr null, [4 x i8] zeroinitializer, { ptr, ptr } zeroinitializer }, ptr %gc.stackobject, align 4
+  %aligned = getelementptr { ptr, i32, ptr, [4 x i8], { ptr, ptr } }, ptr %gc.stackobject, i32 0, i32 4
+  %1 = load ptr, ptr @runtime.stackChainStart, align 4
+  %2 = getelementptr { ptr, i32, ptr, [4 x i8], { ptr, ptr } }, ptr %gc.stackobject, i32 0, i32 0
+  store ptr %1, ptr %2, align 4
+  store ptr %gc.stackobject, ptr @runtime.stackChainStart, align 4
+  %ptr = call ptr @runtime.alloc(i32 4, ptr null)
+  %3 = getelementptr { ptr, i32, ptr, [4 x i8], { ptr, ptr } }, ptr %gc.stackobject, i32 0, i32 2
+  store ptr %ptr, ptr %3, align 4
+  call void @usePointer(ptr %aligned)
+  store ptr %1, ptr @runtime.stackChainStart, align 4
+  ret void
+}
+
+define void @noAllocatingFunction() {
+  %str = alloca { ptr, i32 }, align 4
+  call void @usePointer2(ptr %str)
+  ret void
+}
+
+define void @usePointer2(ptr %ptr) {
+  ret void
+}
@@ -0,0 +1,23 @@
+target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "thumbv7em-unknown-unknown-eabi"
+
+%runtime.globalsTableType = type { i32, [0 x { i32, i32 }] }
+
+@runtime.globalsTable = external global %runtime.globalsTableType
+
+; Globals that may contain pointers.
+@pointer = global ptr null
+@slice = internal global { ptr, i32, i32 } zeroinitializer
+@bytes = global [12 x i8] zeroinitializer, align 4
+
+; These can't contain a pointer and must not be in the table.
+@constant = constant ptr @pointer
+@integer = global i32 5
+@small = global [2 x i8] zeroinitializer, align 4
+@unaligned = global [8 x i8] zeroinitializer, align 1
+@external = external global ptr
+
+define i32 @numGlobals() {
+  %len = load i32, ptr @runtime.globalsTable, align 4
+  ret i32 %len
+}
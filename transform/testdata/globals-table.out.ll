@@ -0,0 +1,17 @@
+target datalayout = "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"
+target triple = "thumbv7em-unknown-unknown-eabi"
+
+@pointer = global ptr null
+@slice = internal global { ptr, i32, i32 } zeroinitializer
+@bytes = global [12 x i8] zeroinitializer, align 4
+@constant = constant ptr @pointer
+@integer = global i32 5
+@small = global [2 x i8] zeroinitializer, align 4
+@unaligned = global [8 x i8] zeroinitializer, align 1
+@external = external global ptr
+@runtime.globalsTable = internal constant { i32, [3 x { ptr, i32 }] } { i32 3, [3 x { ptr, i32 }] [{ ptr, i32 } { ptr @pointer, i32 4 }, { ptr, i32 } { ptr @slice, i32 12 }, { ptr, i32 } { ptr @bytes, i32 12 }] }, align 4
+
+define i32 @numGlobals() {
+  %len = load i32, ptr @runtime.globalsTable, align 4
+  ret i32 %len
+}
@@ -0,0 +1,209 @@
+package wit
+
+// Layout and flattening rules of the canonical ABI, for wasm32. See:
+// https://github.com/WebAssembly/component-model/blob/main/design/mvp/CanonicalABI.md
+
+// Maximum number of flattened parameters and results before they are passed
+// through memory instead.
+const (
+	MaxFlatParams  = 16
+	MaxFlatResults = 1
+)
+
+// CoreType is a WebAssembly value type used in flattened signatures.
+type CoreType uint8
+
+const (
+	I32 CoreType = iota
+	I64
+	F32
+	F64
+)
+
+// Join returns the core type that can hold values of both a and b, used for
+// the payloads of variant cases.
+func Join(a, b CoreType) CoreType {
+	if a == b {
+		return a
+	}
+	if (a == I32 && b == F32) || (a == F32 && b == I32) {
+		return I32
+	}
+	return I64
+}
+
+// Underlying follows type aliases until it reaches a primitive type or a type
+// definition that is not an alias.
+func (res *Resolve) Underlying(t Type) (Type, *TypeDef) {
+	for t.Primitive == "" {
+		def := res.Types[t.ID]
+		if def.Kind.Kind != "type" {
+			return t, def
+		}
+		t = *def.Kind.Type
+	}
+	return t, nil
+}
+
+// VariantCases returns the payload types of a variant-like type definition
+// (variant, enum, option or result). Cases without a payload are nil.
+func VariantCases(def *TypeDef) []*Type {
+	switch def.Kind.Kind {
+	case "variant", "enum":
+		cases := make([]*Type, len(def.Kind.Cases))
+		for i, c := range def.Kind.Cases {
+			cases[i] = c.Type
+		}
+		return cases
+	case "option":
+		return []*Type{nil, def.Kind.Type}
+	case "result":
+		return []*Type{def.Kind.OK, def.Kind.Err}
+	}
+	return nil
+}
+
+// DiscriminantSize returns the size in bytes of the discriminant of a variant
+// with the given number of cases.
+func DiscriminantSize(cases int) int {
+	switch {
+	case cases <= 1<<8:
+		return 1
+	case cases <= 1<<16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// AlignTo rounds n up to a multiple of align.
+func AlignTo(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+// SizeAlign returns the size and alignment of a type in linear memory.
+func (res *Resolve) SizeAlign(t Type) (size, align int) {
+	t, def := res.Underlying(t)
+	if def == nil {
+		switch t.Primitive {
+		case "bool", "u8", "s8":
+			return 1, 1
+		case "u16", "s16":
+			return 2, 2
+		case "u32", "s32", "f32", "char":
+			return 4, 4
+		case "u64", "s64", "f64":
+			return 8, 8
+		case "string":
+			return 8, 4
+		}
+		panic("unknown primitive type: " + t.Primitive)
+	}
+	switch def.Kind.Kind {
+	case "record", "tuple":
+		var types []Type
+		if def.Kind.Kind == "record" {
+			for _, f := range def.Kind.Fields {
+				types = append(types, f.Type)
+			}
+		} else {
+			types = def.Kind.Types
+		}
+		align = 1
+		for _, field := range types {
+			fieldSize, fieldAlign := res.SizeAlign(field)
+			size = AlignTo(size, fieldAlign) + fieldSize
+			if fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return AlignTo(size, align), align
+	case "variant", "enum", "option", "result":
+		cases := VariantCases(def)
+		disc := DiscriminantSize(len(cases))
+		maxSize, maxAlign := 0, disc
+		for _, payload := range cases {
+			if payload != nil {
+				caseSize, caseAlign := res.SizeAlign(*payload)
+				if caseSize > maxSize {
+					maxSize = caseSize
+				}
+				if caseAlign > maxAlign {
+					maxAlign = caseAlign
+				}
+			}
+		}
+		return AlignTo(AlignTo(disc, maxAlign)+maxSize, maxAlign), maxAlign
+	case "flags":
+		n := len(def.Kind.Flags)
+		switch {
+		case n == 0:
+			return 0, 1
+		case n <= 8:
+			return 1, 1
+		case n <= 16:
+			return 2, 2
+		default:
+			return 4 * ((n + 31) / 32), 4
+		}
+	case "list":
+		return 8, 4
+	case "handle", "resource", "future", "stream":
+		return 4, 4
+	}
+	panic("unknown type kind: " + def.Kind.Kind)
+}
+
+// Flatten returns the core types a value of this type is passed as, when it
+// is passed as a parameter or result instead of in memory.
+func (res *Resolve) Flatten(t Type) []CoreType {
+	t, def := res.Underlying(t)
+	if def == nil {
+		switch t.Primitive {
+		case "u64", "s64":
+			return []CoreType{I64}
+		case "f32":
+			return []CoreType{F32}
+		case "f64":
+			return []CoreType{F64}
+		case "string":
+			return []CoreType{I32, I32}
+		}
+		return []CoreType{I32}
+	}
+	switch def.Kind.Kind {
+	case "record":
+		var flat []CoreType
+		for _, f := range def.Kind.Fields {
+			flat = append(flat, res.Flatten(f.Type)...)
+		}
+		return flat
+	case "tuple":
+		var flat []CoreType
+		for _, field := range def.Kind.Types {
+			flat = append(flat, res.Flatten(field)...)
+		}
+		return flat
+	case "variant", "enum", "option", "result":
+		var payload []CoreType
+		for _, c := range VariantCases(def) {
+			if c == nil {
+				continue
+			}
+			for i, ct := range res.Flatten(*c) {
+				if i < len(payload) {
+					payload[i] = Join(payload[i], ct)
+				} else {
+					payload = append(payload, ct)
+				}
+			}
+		}
+		return append([]CoreType{I32}, payload...)
+	case "flags":
+		return make([]CoreType, (len(def.Kind.Flags)+31)/32)
+	case "list":
+		return []CoreType{I32, I32}
+	}
+	// handle, resource, future, stream
+	return []CoreType{I32}
+}
@@ -0,0 +1,946 @@
+package wit
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A package as read from one or more WIT files, before resolving.
+type sourcePackage struct {
+	name   *astPackageName
+	ifaces []*astInterface
+	worlds []*astWorld
+	uses   map[string]*astUsePath // top-level use aliases
+
+	id        int // index in Resolve.Packages, or -1 when not yet resolved
+	resolving bool
+}
+
+func (pkg *sourcePackage) key() string {
+	return packageKey(pkg.name.namespace, pkg.name.name, pkg.name.version)
+}
+
+func packageKey(namespace, name, version string) string {
+	key := namespace + ":" + name
+	if version != "" {
+		key += "@" + version
+	}
+	return key
+}
+
+type resolver struct {
+	res      *Resolve
+	packages map[string]*sourcePackage
+	anon     map[string]int // anonymous types, by structure
+}
+
+func errorAt(pos token.Position, format string, args ...interface{}) error {
+	return scanner.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Load reads the WIT package at path, which is either a single .wit file or a
+// directory of .wit files. For a directory, its dependencies are read from
+// the deps directory inside it, where each entry is a directory or file with
+// a single package. The main package is the last one in the returned
+// Resolve.
+//
+// Feature gates like @unstable are ignored: all items are included.
+func Load(path string) (*Resolve, error) {
+	r := &resolver{
+		res:      &Resolve{},
+		packages: make(map[string]*sourcePackage),
+		anon:     make(map[string]int),
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		deps, err := os.ReadDir(filepath.Join(path, "deps"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, dep := range deps {
+			depPath := filepath.Join(path, "deps", dep.Name())
+			if !dep.IsDir() && filepath.Ext(dep.Name()) != ".wit" {
+				continue
+			}
+			if _, err := r.loadPath(depPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	main, err := r.loadPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the main package last, and all other packages (including
+	// unused dependencies) before it.
+	keys := make([]string, 0, len(r.packages))
+	for key := range r.packages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if pkg := r.packages[key]; pkg != main {
+			if err := r.resolvePackage(pkg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := r.resolvePackage(main); err != nil {
+		return nil, err
+	}
+	return r.res, nil
+}
+
+// loadPath parses a package directory or file, and adds its packages
+// (including nested ones) to the resolver. It returns the main package.
+func (r *resolver) loadPath(path string) (*sourcePackage, error) {
+	var files []*astFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		paths, err = filepath.Glob(filepath.Join(path, "*.wit"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parseFile(path, data)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	var main, lastNested *sourcePackage
+	for _, f := range files {
+		for _, nested := range f.nested {
+			pkg := &sourcePackage{name: nested.pkg, ifaces: nested.ifaces, worlds: nested.worlds, id: -1}
+			if err := r.addPackage(pkg); err != nil {
+				return nil, err
+			}
+			lastNested = pkg
+		}
+		if f.pkg == nil {
+			continue
+		}
+		if main == nil {
+			main = &sourcePackage{name: f.pkg, id: -1}
+		} else if key := packageKey(f.pkg.namespace, f.pkg.name, f.pkg.version); key != main.key() {
+			return nil, errorAt(f.pkg.pos, "package %s conflicts with package %s declared in another file", key, main.key())
+		}
+	}
+	if main == nil {
+		for _, f := range files {
+			if len(f.ifaces) != 0 || len(f.worlds) != 0 {
+				return nil, fmt.Errorf("%s: no package declaration found", f.filename)
+			}
+		}
+		if lastNested == nil {
+			return nil, fmt.Errorf("%s: no package declaration found", path)
+		}
+		// A file with only nested packages: the last one is the main
+		// package.
+		return lastNested, nil
+	}
+	for _, f := range files {
+		main.ifaces = append(main.ifaces, f.ifaces...)
+		main.worlds = append(main.worlds, f.worlds...)
+		for _, use := range f.uses {
+			if main.uses == nil {
+				main.uses = make(map[string]*astUsePath)
+			}
+			main.uses[use.alias] = use.path
+		}
+	}
+	if err := r.addPackage(main); err != nil {
+		return nil, err
+	}
+	return main, nil
+}
+
+func (r *resolver) addPackage(pkg *sourcePackage) error {
+	if r.packages[pkg.key()] != nil {
+		return errorAt(pkg.name.pos, "package %s is defined more than once", pkg.key())
+	}
+	if pkg.uses == nil {
+		pkg.uses = make(map[string]*astUsePath)
+	}
+	r.packages[pkg.key()] = pkg
+	return nil
+}
+
+// findPackage returns the package a fully qualified path refers to. A path
+// without version matches a package of any version, if there is only one.
+func (r *resolver) findPackage(path *astUsePath) (*sourcePackage, error) {
+	if pkg := r.packages[packageKey(path.namespace, path.pkg, path.version)]; pkg != nil {
+		return pkg, nil
+	}
+	var found *sourcePackage
+	if path.version == "" {
+		for _, pkg := range r.packages {
+			if pkg.name.namespace == path.namespace && pkg.name.name == path.pkg {
+				if found != nil {
+					return nil, errorAt(path.pos, "package %s:%s is ambiguous, add a version", path.namespace, path.pkg)
+				}
+				found = pkg
+			}
+		}
+	}
+	if found == nil {
+		return nil, errorAt(path.pos, "package %s not found", packageKey(path.namespace, path.pkg, path.version))
+	}
+	return found, nil
+}
+
+// localPath resolves top-level use aliases: it returns the path an alias
+// refers to, or the path itself if it is not an alias.
+func (pkg *sourcePackage) localPath(path *astUsePath) *astUsePath {
+	if path.namespace == "" {
+		for _, iface := range pkg.ifaces {
+			if iface.name == path.name {
+				return path
+			}
+		}
+		for _, w := range pkg.worlds {
+			if w.name == path.name {
+				return path
+			}
+		}
+		if alias := pkg.uses[path.name]; alias != nil {
+			return alias
+		}
+	}
+	return path
+}
+
+func (r *resolver) resolvePackage(pkg *sourcePackage) error {
+	if pkg.id >= 0 {
+		return nil
+	}
+	if pkg.resolving {
+		return errorAt(pkg.name.pos, "package %s depends on itself", pkg.key())
+	}
+	pkg.resolving = true
+
+	// Resolve dependencies first.
+	var paths []*astUsePath
+	var aliases []string
+	for alias := range pkg.uses {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		paths = append(paths, pkg.uses[alias])
+	}
+	for _, iface := range pkg.ifaces {
+		paths = append(paths, interfacePaths(iface)...)
+	}
+	for _, w := range pkg.worlds {
+		for _, use := range w.uses {
+			paths = append(paths, use.path)
+		}
+		for _, item := range w.items {
+			if item.path != nil {
+				paths = append(paths, item.path)
+			}
+			if item.iface != nil {
+				paths = append(paths, interfacePaths(item.iface)...)
+			}
+		}
+		for _, include := range w.includes {
+			paths = append(paths, include.path)
+		}
+	}
+	for _, path := range paths {
+		path = pkg.localPath(path)
+		if path.namespace == "" {
+			continue
+		}
+		dep, err := r.findPackage(path)
+		if err != nil {
+			return err
+		}
+		if dep != pkg {
+			if err := r.resolvePackage(dep); err != nil {
+				return err
+			}
+		}
+	}
+
+	pkg.id = len(r.res.Packages)
+	p := &Package{
+		Name:       pkg.key(),
+		Docs:       Docs{Contents: pkg.name.docs},
+		Interfaces: make(map[string]int),
+		Worlds:     make(map[string]int),
+	}
+	r.res.Packages = append(r.res.Packages, p)
+
+	// Resolve interfaces in dependency order.
+	byName := make(map[string]*astInterface)
+	for _, iface := range pkg.ifaces {
+		if byName[iface.name] != nil {
+			return errorAt(iface.pos, "interface %s is defined more than once", iface.name)
+		}
+		byName[iface.name] = iface
+	}
+	visiting := make(map[string]bool)
+	var visit func(iface *astInterface) error
+	visit = func(iface *astInterface) error {
+		if _, ok := p.Interfaces[iface.name]; ok {
+			return nil
+		}
+		if visiting[iface.name] {
+			return errorAt(iface.pos, "interface %s depends on itself", iface.name)
+		}
+		visiting[iface.name] = true
+		for _, path := range interfacePaths(iface) {
+			path = pkg.localPath(path)
+			if dep := byName[path.name]; path.namespace == "" && dep != nil {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		name := iface.name
+		id, err := r.resolveInterface(pkg, iface, &name)
+		if err != nil {
+			return err
+		}
+		p.Interfaces[iface.name] = id
+		return nil
+	}
+	for _, iface := range pkg.ifaces {
+		if err := visit(iface); err != nil {
+			return err
+		}
+	}
+
+	// Resolve worlds, after the worlds they include.
+	worlds := make(map[string]*astWorld)
+	for _, w := range pkg.worlds {
+		if worlds[w.name] != nil || byName[w.name] != nil {
+			return errorAt(w.pos, "%s is defined more than once", w.name)
+		}
+		worlds[w.name] = w
+	}
+	visitingWorld := make(map[string]bool)
+	var visitWorld func(w *astWorld) error
+	visitWorld = func(w *astWorld) error {
+		if _, ok := p.Worlds[w.name]; ok {
+			return nil
+		}
+		if visitingWorld[w.name] {
+			return errorAt(w.pos, "world %s includes itself", w.name)
+		}
+		visitingWorld[w.name] = true
+		for _, include := range w.includes {
+			path := pkg.localPath(include.path)
+			if dep := worlds[path.name]; path.namespace == "" && dep != nil {
+				if err := visitWorld(dep); err != nil {
+					return err
+				}
+			}
+		}
+		id, err := r.resolveWorld(pkg, w)
+		if err != nil {
+			return err
+		}
+		p.Worlds[w.name] = id
+		return nil
+	}
+	for _, w := range pkg.worlds {
+		if err := visitWorld(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interfacePaths returns the interfaces used by an interface.
+func interfacePaths(iface *astInterface) []*astUsePath {
+	var paths []*astUsePath
+	for _, use := range iface.uses {
+		paths = append(paths, use.path)
+	}
+	return paths
+}
+
+// lookupInterface returns the interface ID of a use path, which must already
+// be resolved.
+func (r *resolver) lookupInterface(pkg *sourcePackage, path *astUsePath) (int, error) {
+	orig := path
+	path = pkg.localPath(path)
+	target := pkg
+	if path.namespace != "" {
+		var err error
+		target, err = r.findPackage(path)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if target.id >= 0 {
+		if id, ok := r.res.Packages[target.id].Interfaces[path.name]; ok {
+			return id, nil
+		}
+	}
+	return 0, errorAt(orig.pos, "interface %s not found in package %s", path.name, target.key())
+}
+
+// lookupWorld returns the world ID of a use path, which must already be
+// resolved.
+func (r *resolver) lookupWorld(pkg *sourcePackage, path *astUsePath) (int, error) {
+	orig := path
+	path = pkg.localPath(path)
+	target := pkg
+	if path.namespace != "" {
+		var err error
+		target, err = r.findPackage(path)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if target.id >= 0 {
+		if id, ok := r.res.Packages[target.id].Worlds[path.name]; ok {
+			return id, nil
+		}
+	}
+	return 0, errorAt(orig.pos, "world %s not found in package %s", path.name, target.key())
+}
+
+// A scope maps type names to types, within an interface or world.
+type scope struct {
+	types map[string]Type
+	owner Owner
+}
+
+func (r *resolver) resolveInterface(pkg *sourcePackage, ast *astInterface, name *string) (int, error) {
+	id := len(r.res.Interfaces)
+	iface := &Interface{
+		Name:      name,
+		Docs:      Docs{Contents: ast.docs},
+		Types:     make(map[string]int),
+		Functions: make(map[string]*Function),
+		Package:   &pkg.id,
+	}
+	r.res.Interfaces = append(r.res.Interfaces, iface)
+	s := &scope{types: make(map[string]Type), owner: Owner{Interface: &id}}
+	if err := r.resolveUses(pkg, s, ast.uses, iface.Types); err != nil {
+		return 0, err
+	}
+	if err := r.resolveTypeDefs(s, ast.types, iface.Types); err != nil {
+		return 0, err
+	}
+	for _, fn := range r.allFunctions(ast.funcs, ast.types) {
+		f, err := r.resolveFunction(s, fn.ast, fn.resource)
+		if err != nil {
+			return 0, err
+		}
+		if iface.Functions[f.Name] != nil {
+			return 0, errorAt(fn.ast.pos, "function %s is defined more than once", f.Name)
+		}
+		iface.Functions[f.Name] = f
+	}
+	return id, nil
+}
+
+// resolveUses adds the types imported with use statements to the scope, as
+// aliases owned by the scope.
+func (r *resolver) resolveUses(pkg *sourcePackage, s *scope, uses []*astUse, types map[string]int) error {
+	for _, use := range uses {
+		ifaceID, err := r.lookupInterface(pkg, use.path)
+		if err != nil {
+			return err
+		}
+		target := r.res.Interfaces[ifaceID]
+		for _, name := range use.names {
+			typeID, ok := target.Types[name.name]
+			if !ok {
+				return errorAt(name.pos, "type %s not found in interface %s", name.name, *target.Name)
+			}
+			if _, ok := s.types[name.alias]; ok {
+				return errorAt(name.pos, "type %s is defined more than once", name.alias)
+			}
+			alias := name.alias
+			id := r.addType(&TypeDef{
+				Name:  &alias,
+				Kind:  TypeDefKind{Kind: "type", Type: &Type{ID: typeID}},
+				Owner: s.owner,
+			})
+			s.types[alias] = Type{ID: id}
+			types[alias] = id
+		}
+	}
+	return nil
+}
+
+func (r *resolver) addType(def *TypeDef) int {
+	r.res.Types = append(r.res.Types, def)
+	return len(r.res.Types) - 1
+}
+
+// resolveTypeDefs resolves the named types of an interface or world, in
+// dependency order.
+func (r *resolver) resolveTypeDefs(s *scope, defs []*astTypeDef, types map[string]int) error {
+	byName := make(map[string]*astTypeDef)
+	for _, def := range defs {
+		if _, ok := s.types[def.name]; ok || byName[def.name] != nil {
+			return errorAt(def.pos, "type %s is defined more than once", def.name)
+		}
+		byName[def.name] = def
+	}
+	visiting := make(map[string]bool)
+	var visit func(def *astTypeDef) error
+	visit = func(def *astTypeDef) error {
+		if _, ok := s.types[def.name]; ok {
+			return nil
+		}
+		if visiting[def.name] {
+			return errorAt(def.pos, "type %s depends on itself", def.name)
+		}
+		visiting[def.name] = true
+		var deps []*astType
+		if def.alias != nil {
+			deps = append(deps, def.alias)
+		}
+		for _, field := range def.fields {
+			if field.typ != nil {
+				deps = append(deps, field.typ)
+			}
+		}
+		for len(deps) > 0 {
+			t := deps[len(deps)-1]
+			deps = deps[:len(deps)-1]
+			if t == nil {
+				continue
+			}
+			if t.kind == "name" && byName[t.name] != nil {
+				if err := visit(byName[t.name]); err != nil {
+					return err
+				}
+			}
+			deps = append(deps, t.args...)
+		}
+		kind, err := r.resolveKind(s, def)
+		if err != nil {
+			return err
+		}
+		name := def.name
+		id := r.addType(&TypeDef{
+			Name:  &name,
+			Kind:  kind,
+			Owner: s.owner,
+			Docs:  Docs{Contents: def.docs},
+		})
+		s.types[name] = Type{ID: id}
+		types[name] = id
+		return nil
+	}
+	for _, def := range defs {
+		if err := visit(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) resolveKind(s *scope, def *astTypeDef) (TypeDefKind, error) {
+	kind := TypeDefKind{Kind: def.kind}
+	switch def.kind {
+	case "type":
+		switch {
+		case def.alias.kind == "name":
+			// Aliases of resources refer to the resource, not a handle.
+			t, ok := s.types[def.alias.name]
+			if !ok {
+				return kind, errorAt(def.alias.pos, "type %s is not defined", def.alias.name)
+			}
+			kind.Type = &t
+		default:
+			t, err := r.resolveType(s, def.alias)
+			if err != nil {
+				return kind, err
+			}
+			if t.Primitive != "" {
+				kind.Type = &t
+			} else {
+				// A named anonymous type, like type names = list<string>.
+				kind = r.res.Types[t.ID].Kind
+			}
+		}
+	case "record", "flags":
+		for _, field := range def.fields {
+			f := Field{Name: field.name, Docs: Docs{Contents: field.docs}}
+			if field.typ != nil {
+				t, err := r.resolveType(s, field.typ)
+				if err != nil {
+					return kind, err
+				}
+				f.Type = t
+			}
+			if def.kind == "record" {
+				kind.Fields = append(kind.Fields, f)
+			} else {
+				kind.Flags = append(kind.Flags, f)
+			}
+		}
+	case "variant", "enum":
+		for _, field := range def.fields {
+			c := Case{Name: field.name, Docs: Docs{Contents: field.docs}}
+			if field.typ != nil {
+				t, err := r.resolveType(s, field.typ)
+				if err != nil {
+					return kind, err
+				}
+				c.Type = &t
+			}
+			kind.Cases = append(kind.Cases, c)
+		}
+	}
+	return kind, nil
+}
+
+// resolveType resolves a type expression to a primitive type or a type
+// definition. Anonymous types are shared between all uses.
+func (r *resolver) resolveType(s *scope, t *astType) (Type, error) {
+	if t == nil {
+		return Type{}, nil
+	}
+	if _, ok := primitiveTypes[t.kind]; ok {
+		return Type{Primitive: t.kind}, nil
+	}
+	if t.kind == "borrow" || t.kind == "own" {
+		// The argument is the resource itself, not an owned handle as
+		// resolveType would return for it.
+		arg := t.args[0]
+		resource, ok := s.types[arg.name]
+		if arg.kind != "name" || !ok || !r.isResource(resource) {
+			return Type{}, errorAt(arg.pos, "%s<...> must refer to a resource", t.kind)
+		}
+		return r.anonType(TypeDefKind{Kind: "handle", Handle: Handle{Borrow: t.kind == "borrow", Resource: resource.ID}}), nil
+	}
+	var args []Type
+	for _, arg := range t.args {
+		if arg == nil {
+			args = append(args, Type{})
+			continue
+		}
+		resolved, err := r.resolveType(s, arg)
+		if err != nil {
+			return Type{}, err
+		}
+		args = append(args, resolved)
+	}
+	kind := TypeDefKind{Kind: t.kind}
+	switch t.kind {
+	case "name":
+		named, ok := s.types[t.name]
+		if !ok {
+			return Type{}, errorAt(t.pos, "type %s is not defined", t.name)
+		}
+		if r.isResource(named) {
+			// A plain resource name is an owned handle.
+			kind = TypeDefKind{Kind: "handle", Handle: Handle{Resource: named.ID}}
+		} else {
+			return named, nil
+		}
+	case "list", "option", "future", "stream":
+		if len(args) > 0 {
+			kind.Type = &args[0]
+		}
+	case "tuple":
+		kind.Types = args
+	case "result":
+		if args[0] != (Type{}) {
+			kind.OK = &args[0]
+		}
+		if args[1] != (Type{}) {
+			kind.Err = &args[1]
+		}
+	default:
+		return Type{}, errorAt(t.pos, "unknown type %s", t.kind)
+	}
+	return r.anonType(kind), nil
+}
+
+// anonType returns the anonymous type definition of the given kind, which is
+// shared between all uses.
+func (r *resolver) anonType(kind TypeDefKind) Type {
+	key := anonKey(kind)
+	if id, ok := r.anon[key]; ok {
+		return Type{ID: id}
+	}
+	id := r.addType(&TypeDef{Kind: kind})
+	r.anon[key] = id
+	return Type{ID: id}
+}
+
+// isResource returns whether t is a resource, or an alias of one.
+func (r *resolver) isResource(t Type) bool {
+	if t.Primitive != "" {
+		return false
+	}
+	_, def := r.res.Underlying(t)
+	return def != nil && def.Kind.Kind == "resource"
+}
+
+// anonKey returns a string that identifies an anonymous type by its
+// structure.
+func anonKey(kind TypeDefKind) string {
+	typ := func(t *Type) string {
+		switch {
+		case t == nil:
+			return "_"
+		case t.Primitive != "":
+			return t.Primitive
+		}
+		return fmt.Sprint(t.ID)
+	}
+	key := kind.Kind
+	switch kind.Kind {
+	case "handle":
+		key += fmt.Sprint(kind.Handle.Borrow, kind.Handle.Resource)
+	case "tuple":
+		for i := range kind.Types {
+			key += " " + typ(&kind.Types[i])
+		}
+	case "result":
+		key += " " + typ(kind.OK) + " " + typ(kind.Err)
+	default:
+		key += " " + typ(kind.Type)
+	}
+	return key
+}
+
+type astFuncInfo struct {
+	ast      *astFunc
+	resource string
+}
+
+// allFunctions returns the freestanding functions followed by the functions of
+// each resource.
+func (r *resolver) allFunctions(funcs []*astFunc, types []*astTypeDef) []astFuncInfo {
+	var all []astFuncInfo
+	for _, fn := range funcs {
+		all = append(all, astFuncInfo{ast: fn})
+	}
+	for _, def := range types {
+		for _, fn := range def.funcs {
+			all = append(all, astFuncInfo{ast: fn, resource: def.name})
+		}
+	}
+	return all
+}
+
+func (r *resolver) resolveFunction(s *scope, fn *astFunc, resource string) (*Function, error) {
+	f := &Function{
+		Name: fn.name,
+		Kind: FunctionKind{Kind: fn.kind},
+		Docs: Docs{Contents: fn.docs},
+	}
+	if resource != "" {
+		f.Kind.Resource = s.types[resource].ID
+		switch fn.kind {
+		case "constructor":
+			f.Name = "[constructor]" + resource
+		case "method":
+			f.Name = "[method]" + resource + "." + fn.name
+			self, err := r.resolveType(s, &astType{pos: fn.pos, kind: "borrow", args: []*astType{{pos: fn.pos, kind: "name", name: resource}}})
+			if err != nil {
+				return nil, err
+			}
+			f.Params = append(f.Params, Param{Name: "self", Type: self})
+		case "static":
+			f.Name = "[static]" + resource + "." + fn.name
+		}
+	}
+	names := make(map[string]bool)
+	for _, param := range fn.params {
+		if names[param.name] {
+			return nil, errorAt(param.pos, "parameter %s is defined more than once", param.name)
+		}
+		names[param.name] = true
+		t, err := r.resolveType(s, param.typ)
+		if err != nil {
+			return nil, err
+		}
+		f.Params = append(f.Params, Param{Name: param.name, Type: t})
+	}
+	switch {
+	case fn.result != nil:
+		t, err := r.resolveType(s, fn.result)
+		if err != nil {
+			return nil, err
+		}
+		f.Result = &t
+	case fn.kind == "constructor":
+		t, err := r.resolveType(s, &astType{pos: fn.pos, kind: "name", name: resource})
+		if err != nil {
+			return nil, err
+		}
+		f.Result = &t
+	}
+	return f, nil
+}
+
+func (r *resolver) resolveWorld(pkg *sourcePackage, ast *astWorld) (int, error) {
+	id := len(r.res.Worlds)
+	w := &World{
+		Name:    ast.name,
+		Docs:    Docs{Contents: ast.docs},
+		Imports: make(map[string]WorldItem),
+		Exports: make(map[string]WorldItem),
+		Package: &pkg.id,
+	}
+	r.res.Worlds = append(r.res.Worlds, w)
+	s := &scope{types: make(map[string]Type), owner: Owner{World: &id}}
+	types := make(map[string]int)
+	if err := r.resolveUses(pkg, s, ast.uses, types); err != nil {
+		return 0, err
+	}
+	if err := r.resolveTypeDefs(s, ast.types, types); err != nil {
+		return 0, err
+	}
+	for name, typeID := range types {
+		typeID := typeID
+		w.Imports[name] = WorldItem{Type: &typeID}
+	}
+	for _, fn := range r.allFunctions(nil, ast.types) {
+		f, err := r.resolveFunction(s, fn.ast, fn.resource)
+		if err != nil {
+			return 0, err
+		}
+		w.Imports[f.Name] = WorldItem{Function: f}
+	}
+
+	for _, item := range ast.items {
+		items := w.Imports
+		if item.export {
+			items = w.Exports
+		}
+		var key string
+		var value WorldItem
+		switch {
+		case item.path != nil:
+			ifaceID, err := r.lookupInterface(pkg, item.path)
+			if err != nil {
+				return 0, err
+			}
+			key = r.res.InterfaceKey(ifaceID)
+			value = WorldItem{Interface: &ifaceID}
+		case item.iface != nil:
+			ifaceID, err := r.resolveInterface(pkg, item.iface, nil)
+			if err != nil {
+				return 0, err
+			}
+			key = item.name
+			value = WorldItem{Interface: &ifaceID}
+		default:
+			f, err := r.resolveFunction(s, item.fn, "")
+			if err != nil {
+				return 0, err
+			}
+			key = item.name
+			value = WorldItem{Function: f}
+		}
+		if _, ok := w.Imports[key]; ok {
+			return 0, errorAt(item.pos, "%s is already imported", key)
+		}
+		if _, ok := w.Exports[key]; ok {
+			return 0, errorAt(item.pos, "%s is already exported", key)
+		}
+		items[key] = value
+	}
+
+	for _, include := range ast.includes {
+		includeID, err := r.lookupWorld(pkg, include.path)
+		if err != nil {
+			return 0, err
+		}
+		included := r.res.Worlds[includeID]
+		for _, pair := range [][2]map[string]WorldItem{{included.Imports, w.Imports}, {included.Exports, w.Exports}} {
+			for key, item := range pair[0] {
+				if newName, ok := include.names[key]; ok {
+					key = newName
+				}
+				if existing, ok := pair[1][key]; ok {
+					if item.Interface != nil && existing.Interface != nil && *item.Interface == *existing.Interface {
+						continue
+					}
+					return 0, errorAt(include.path.pos, "%s from the included world conflicts with an existing item", key)
+				}
+				pair[1][key] = item
+			}
+		}
+	}
+
+	r.elaborate(w)
+	return id, nil
+}
+
+// elaborate adds the interfaces that the imports and exports of a world
+// depend on (through use statements) as imports of the world, unless they
+// are exported.
+func (r *resolver) elaborate(w *World) {
+	exported := make(map[int]bool)
+	for _, item := range w.Exports {
+		if item.Interface != nil {
+			exported[*item.Interface] = true
+		}
+	}
+	var add func(id int)
+	add = func(id int) {
+		for _, dep := range r.res.InterfaceDeps(id) {
+			if exported[dep] {
+				continue
+			}
+			key := r.res.InterfaceKey(dep)
+			if _, ok := w.Imports[key]; ok {
+				continue
+			}
+			dep := dep
+			w.Imports[key] = WorldItem{Interface: &dep}
+			add(dep)
+		}
+	}
+	for _, items := range []map[string]WorldItem{w.Imports, w.Exports} {
+		var keys []string
+		for key := range items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := items[key]
+			switch {
+			case item.Interface != nil:
+				add(*item.Interface)
+			case item.Type != nil:
+				def := r.res.Types[*item.Type]
+				if def.Kind.Kind == "type" && def.Kind.Type.Primitive == "" {
+					owner := r.res.Types[def.Kind.Type.ID].Owner
+					if owner.Interface != nil && !exported[*owner.Interface] {
+						dep := *owner.Interface
+						w.Imports[r.res.InterfaceKey(dep)] = WorldItem{Interface: &dep}
+						add(dep)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,828 @@
+package wit
+
+// This file contains a parser for the WIT text format. See:
+// https://github.com/WebAssembly/component-model/blob/main/design/mvp/WIT.md
+
+import (
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+type tokenKind uint8
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokPunct
+)
+
+// A single token in a WIT file. Explicit identifiers (like %type) are never
+// keywords.
+type witToken struct {
+	kind     tokenKind
+	text     string
+	offset   int
+	explicit bool
+	docs     []string
+}
+
+// Parsed WIT source file.
+type astFile struct {
+	pkg      *astPackageName
+	uses     []*astTopUse
+	ifaces   []*astInterface
+	worlds   []*astWorld
+	nested   []*astNested
+	filename string
+}
+
+// Nested package definition: package a:b { ... }
+type astNested struct {
+	pkg    *astPackageName
+	ifaces []*astInterface
+	worlds []*astWorld
+}
+
+type astPackageName struct {
+	pos       token.Position
+	namespace string
+	name      string
+	version   string
+	docs      string
+}
+
+// Top-level use: use a:b/c as d;
+type astTopUse struct {
+	path  *astUsePath
+	alias string
+}
+
+// Reference to an interface or world, either local (just a name) or in
+// another package.
+type astUsePath struct {
+	pos       token.Position
+	namespace string // empty for local names
+	pkg       string
+	name      string
+	version   string
+}
+
+type astInterface struct {
+	pos   token.Position
+	name  string
+	docs  string
+	uses  []*astUse
+	types []*astTypeDef
+	funcs []*astFunc
+}
+
+// Use inside an interface or world: use iface.{a, b as c};
+type astUse struct {
+	path  *astUsePath
+	names []astUseName
+}
+
+type astUseName struct {
+	pos   token.Position
+	name  string
+	alias string
+}
+
+type astTypeDef struct {
+	pos    token.Position
+	name   string
+	docs   string
+	kind   string // record, variant, enum, flags, resource, type
+	fields []astField
+	alias  *astType
+	funcs  []*astFunc // resource functions
+}
+
+// Record field, variant or enum case, or flag.
+type astField struct {
+	pos  token.Position
+	name string
+	docs string
+	typ  *astType // nil for cases without payload and for flags
+}
+
+type astType struct {
+	pos  token.Position
+	kind string // primitive name, "name", "list", "option", "result", "tuple", "borrow", "own", "future", "stream"
+	name string
+	args []*astType // may contain nil for "_" in result<_, E>
+}
+
+type astFunc struct {
+	pos    token.Position
+	name   string
+	docs   string
+	kind   string // freestanding, method, static, constructor
+	params []astParam
+	result *astType
+}
+
+type astParam struct {
+	pos  token.Position
+	name string
+	typ  *astType
+}
+
+type astWorld struct {
+	pos      token.Position
+	name     string
+	docs     string
+	uses     []*astUse
+	types    []*astTypeDef
+	items    []*astWorldItem
+	includes []*astInclude
+}
+
+// Import or export of a world.
+type astWorldItem struct {
+	pos    token.Position
+	export bool
+	name   string // for named items
+	docs   string
+	path   *astUsePath
+	fn     *astFunc
+	iface  *astInterface
+}
+
+type astInclude struct {
+	path  *astUsePath
+	names map[string]string // with { a as b }
+}
+
+var primitiveTypes = map[string]string{
+	"bool":    "bool",
+	"u8":      "u8",
+	"u16":     "u16",
+	"u32":     "u32",
+	"u64":     "u64",
+	"s8":      "s8",
+	"s16":     "s16",
+	"s32":     "s32",
+	"s64":     "s64",
+	"f32":     "f32",
+	"f64":     "f64",
+	"float32": "f32",
+	"float64": "f64",
+	"char":    "char",
+	"string":  "string",
+}
+
+type parser struct {
+	filename string
+	src      string
+	lines    []int // offsets of line starts
+	off      int
+	tok      witToken
+}
+
+// bailout is used to unwind the parser on the first error.
+type bailout struct{ err scanner.Error }
+
+// parseFile parses a single WIT file.
+func parseFile(filename string, src []byte) (f *astFile, err error) {
+	p := &parser{filename: filename, src: string(src), lines: []int{0}}
+	for i, c := range p.src {
+		if c == '\n' {
+			p.lines = append(p.lines, i+1)
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			f, err = nil, b.err
+		}
+	}()
+	p.next()
+	return p.file(), nil
+}
+
+func (p *parser) position(offset int) token.Position {
+	line := sort.Search(len(p.lines), func(i int) bool { return p.lines[i] > offset }) - 1
+	return token.Position{
+		Filename: p.filename,
+		Offset:   offset,
+		Line:     line + 1,
+		Column:   offset - p.lines[line] + 1,
+	}
+}
+
+func (p *parser) pos() token.Position {
+	return p.position(p.tok.offset)
+}
+
+func (p *parser) errorf(pos token.Position, msg string) {
+	panic(bailout{scanner.Error{Pos: pos, Msg: msg}})
+}
+
+// next reads the next token into p.tok, skipping whitespace and comments.
+// Doc comments are attached to the token that follows them.
+func (p *parser) next() {
+	var docs []string
+	for {
+		for p.off < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.off]) >= 0 {
+			p.off++
+		}
+		rest := p.src[p.off:]
+		switch {
+		case strings.HasPrefix(rest, "///") && !strings.HasPrefix(rest, "////"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			docs = append(docs, strings.TrimSpace(rest[3:end]))
+			p.off += end
+			continue
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			p.off += end
+			continue
+		case strings.HasPrefix(rest, "/*"):
+			isDoc := strings.HasPrefix(rest, "/**") && !strings.HasPrefix(rest, "/**/")
+			depth, i := 0, 0
+			for {
+				if i >= len(rest) {
+					p.errorf(p.position(p.off), "unterminated block comment")
+				}
+				if strings.HasPrefix(rest[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(rest[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			if isDoc {
+				for _, line := range strings.Split(rest[3:i-2], "\n") {
+					line = strings.TrimSpace(line)
+					line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+					docs = append(docs, line)
+				}
+			}
+			p.off += i
+			continue
+		}
+		break
+	}
+	p.tok = witToken{offset: p.off, docs: docs}
+	if p.off >= len(p.src) {
+		p.tok.kind = tokEOF
+		return
+	}
+	c := p.src[p.off]
+	switch {
+	case c == '%' || isIdentStart(c):
+		start := p.off
+		if c == '%' {
+			p.tok.explicit = true
+			p.off++
+			start++
+			if p.off >= len(p.src) || !isIdentStart(p.src[p.off]) {
+				p.errorf(p.position(p.off), "expected an identifier after '%'")
+			}
+		}
+		for p.off < len(p.src) && (isIdentStart(p.src[p.off]) || isDigit(p.src[p.off]) || p.src[p.off] == '-') {
+			p.off++
+		}
+		p.tok.kind = tokIdent
+		p.tok.text = p.src[start:p.off]
+		if !validIdent(p.tok.text) {
+			p.errorf(p.position(start), "invalid identifier: "+p.tok.text)
+		}
+	case strings.HasPrefix(p.src[p.off:], "->"):
+		p.tok.kind = tokPunct
+		p.tok.text = "->"
+		p.off += 2
+	case strings.IndexByte("{}()<>,:;.=@/*_", c) >= 0:
+		p.tok.kind = tokPunct
+		p.tok.text = string(c)
+		p.off++
+	default:
+		p.errorf(p.position(p.off), "unexpected character "+strconvQuote(c))
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// validIdent checks the kebab-case rules for identifiers: each word starts
+// with a letter and is either all lowercase or all uppercase.
+func validIdent(s string) bool {
+	for _, word := range strings.Split(s, "-") {
+		if word == "" || !isIdentStart(word[0]) {
+			return false
+		}
+		if strings.ToLower(word) != word && strings.ToUpper(word) != word {
+			return false
+		}
+	}
+	return true
+}
+
+func strconvQuote(c byte) string {
+	if c < ' ' || c > '~' {
+		return "0x" + string("0123456789abcdef"[c>>4]) + string("0123456789abcdef"[c&15])
+	}
+	return "'" + string(c) + "'"
+}
+
+// version reads a semantic version directly after the current token, which
+// is '@' or '='.
+func (p *parser) version() string {
+	for p.off < len(p.src) && (p.src[p.off] == ' ' || p.src[p.off] == '\t') {
+		p.off++
+	}
+	start := p.off
+	for p.off < len(p.src) && (isIdentStart(p.src[p.off]) || isDigit(p.src[p.off]) || strings.IndexByte(".-+", p.src[p.off]) >= 0) {
+		p.off++
+	}
+	for p.off > start && p.src[p.off-1] == '.' {
+		// The version is followed by '.' in use paths.
+		p.off--
+	}
+	version := p.src[start:p.off]
+	if version == "" || !isDigit(version[0]) {
+		p.errorf(p.position(start), "expected a version")
+	}
+	p.next()
+	return version
+}
+
+func (p *parser) is(text string) bool {
+	return (p.tok.kind == tokPunct || (p.tok.kind == tokIdent && !p.tok.explicit)) && p.tok.text == text
+}
+
+func (p *parser) expect(text string) token.Position {
+	pos := p.pos()
+	if !p.is(text) {
+		p.errorf(pos, "expected '"+text+"', found "+p.describe())
+	}
+	p.next()
+	return pos
+}
+
+func (p *parser) describe() string {
+	if p.tok.kind == tokEOF {
+		return "end of file"
+	}
+	return "'" + p.tok.text + "'"
+}
+
+// ident reads an identifier, which may be a keyword when explicit is false.
+func (p *parser) ident() (string, token.Position) {
+	pos := p.pos()
+	if p.tok.kind != tokIdent {
+		p.errorf(pos, "expected an identifier, found "+p.describe())
+	}
+	name := p.tok.text
+	p.next()
+	return name, pos
+}
+
+// docs returns the doc comment of the current token.
+func (p *parser) docs() string {
+	return strings.Join(p.tok.docs, "\n")
+}
+
+func (p *parser) file() *astFile {
+	f := &astFile{filename: p.filename}
+	p.attributes()
+	if p.is("package") {
+		pkg := p.packageName()
+		if p.is("{") {
+			f.nested = append(f.nested, p.nestedPackage(pkg))
+		} else {
+			p.expect(";")
+			f.pkg = pkg
+		}
+	}
+	for p.tok.kind != tokEOF {
+		docs := p.docs()
+		p.attributes()
+		switch {
+		case p.is("use"):
+			p.next()
+			path := p.usePath()
+			use := &astTopUse{path: path, alias: path.name}
+			if p.is("as") {
+				p.next()
+				use.alias, _ = p.ident()
+			}
+			p.expect(";")
+			f.uses = append(f.uses, use)
+		case p.is("interface"):
+			f.ifaces = append(f.ifaces, p.interfaceDecl(docs))
+		case p.is("world"):
+			f.worlds = append(f.worlds, p.world(docs))
+		case p.is("package"):
+			pkg := p.packageName()
+			if !p.is("{") {
+				p.errorf(p.pos(), "package declaration must come first")
+			}
+			f.nested = append(f.nested, p.nestedPackage(pkg))
+		default:
+			p.errorf(p.pos(), "expected 'interface', 'world', 'use' or 'package', found "+p.describe())
+		}
+	}
+	return f
+}
+
+// attributes skips feature gates like @since(version = 0.2.0) and
+// @unstable(feature = foo). All gated items are included, as if all features
+// were enabled.
+func (p *parser) attributes() {
+	for p.is("@") {
+		p.next()
+		name, pos := p.ident()
+		switch name {
+		case "since", "unstable", "deprecated":
+		default:
+			p.errorf(pos, "unknown attribute @"+name)
+		}
+		p.expect("(")
+		key, _ := p.ident()
+		if key == "version" {
+			// Read the version directly after '=', like after '@'.
+			if !p.is("=") {
+				p.expect("=")
+			}
+			p.version()
+		} else {
+			p.expect("=")
+			p.ident()
+		}
+		p.expect(")")
+	}
+}
+
+func (p *parser) packageName() *astPackageName {
+	pkg := &astPackageName{docs: p.docs()}
+	p.expect("package")
+	pkg.pos = p.pos()
+	pkg.namespace, _ = p.ident()
+	p.expect(":")
+	pkg.name, _ = p.ident()
+	if p.is("@") {
+		pkg.version = p.version()
+	}
+	return pkg
+}
+
+func (p *parser) nestedPackage(pkg *astPackageName) *astNested {
+	nested := &astNested{pkg: pkg}
+	p.expect("{")
+	for !p.is("}") {
+		docs := p.docs()
+		p.attributes()
+		switch {
+		case p.is("interface"):
+			nested.ifaces = append(nested.ifaces, p.interfaceDecl(docs))
+		case p.is("world"):
+			nested.worlds = append(nested.worlds, p.world(docs))
+		default:
+			p.errorf(p.pos(), "expected 'interface' or 'world', found "+p.describe())
+		}
+	}
+	p.next()
+	return nested
+}
+
+// usePath reads an interface or world reference: either a plain name or a
+// fully qualified name like wasi:io/streams@0.2.0.
+func (p *parser) usePath() *astUsePath {
+	name, pos := p.ident()
+	if !p.is(":") {
+		return &astUsePath{pos: pos, name: name}
+	}
+	p.next()
+	return p.qualifiedPath(name, pos)
+}
+
+// qualifiedPath reads the rest of a fully qualified name, after the namespace
+// and ':'.
+func (p *parser) qualifiedPath(namespace string, pos token.Position) *astUsePath {
+	path := &astUsePath{pos: pos, namespace: namespace}
+	path.pkg, _ = p.ident()
+	p.expect("/")
+	path.name, _ = p.ident()
+	if p.is("@") {
+		path.version = p.version()
+	}
+	return path
+}
+
+func (p *parser) interfaceDecl(docs string) *astInterface {
+	p.expect("interface")
+	iface := &astInterface{docs: docs}
+	iface.name, iface.pos = p.ident()
+	p.interfaceBody(iface)
+	return iface
+}
+
+func (p *parser) interfaceBody(iface *astInterface) {
+	p.expect("{")
+	for !p.is("}") {
+		docs := p.docs()
+		p.attributes()
+		switch {
+		case p.is("use"):
+			iface.uses = append(iface.uses, p.use())
+		case p.tok.kind == tokIdent && !p.tok.explicit && isTypeKeyword(p.tok.text):
+			iface.types = append(iface.types, p.typeDef(docs))
+		default:
+			fn := &astFunc{docs: docs, kind: "freestanding"}
+			fn.name, fn.pos = p.ident()
+			p.expect(":")
+			p.funcType(fn)
+			p.expect(";")
+			iface.funcs = append(iface.funcs, fn)
+		}
+	}
+	p.next()
+}
+
+func isTypeKeyword(s string) bool {
+	switch s {
+	case "type", "record", "variant", "enum", "flags", "resource":
+		return true
+	}
+	return false
+}
+
+func (p *parser) use() *astUse {
+	p.expect("use")
+	use := &astUse{path: p.usePath()}
+	p.expect(".")
+	p.expect("{")
+	for !p.is("}") {
+		var name astUseName
+		name.name, name.pos = p.ident()
+		name.alias = name.name
+		if p.is("as") {
+			p.next()
+			name.alias, _ = p.ident()
+		}
+		use.names = append(use.names, name)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	p.expect("}")
+	p.expect(";")
+	return use
+}
+
+func (p *parser) typeDef(docs string) *astTypeDef {
+	def := &astTypeDef{docs: docs, kind: p.tok.text}
+	p.next()
+	def.name, def.pos = p.ident()
+	switch def.kind {
+	case "type":
+		p.expect("=")
+		def.alias = p.typ()
+		p.expect(";")
+	case "resource":
+		if p.is(";") {
+			p.next()
+			break
+		}
+		p.expect("{")
+		for !p.is("}") {
+			fn := &astFunc{docs: p.docs()}
+			p.attributes()
+			if p.is("constructor") {
+				fn.pos = p.pos()
+				fn.kind = "constructor"
+				p.next()
+				fn.params = p.params()
+				if p.is("->") {
+					// Fallible constructors: constructor() -> result<r, e>
+					p.next()
+					fn.result = p.typ()
+				}
+			} else {
+				fn.name, fn.pos = p.ident()
+				fn.kind = "method"
+				p.expect(":")
+				if p.is("static") {
+					p.next()
+					fn.kind = "static"
+				}
+				p.funcType(fn)
+			}
+			p.expect(";")
+			def.funcs = append(def.funcs, fn)
+		}
+		p.next()
+	default:
+		p.expect("{")
+		for !p.is("}") {
+			field := astField{docs: p.docs()}
+			field.name, field.pos = p.ident()
+			switch def.kind {
+			case "record":
+				p.expect(":")
+				field.typ = p.typ()
+			case "variant":
+				if p.is("(") {
+					p.next()
+					field.typ = p.typ()
+					p.expect(")")
+				}
+			}
+			def.fields = append(def.fields, field)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		p.expect("}")
+	}
+	return def
+}
+
+// funcType reads the type of a function: func(params) -> result
+func (p *parser) funcType(fn *astFunc) {
+	if p.is("async") {
+		p.errorf(p.pos(), "async functions are not supported")
+	}
+	p.expect("func")
+	fn.params = p.params()
+	if p.is("->") {
+		p.next()
+		if p.is("(") {
+			p.errorf(p.pos(), "named or multiple results are not supported")
+		}
+		fn.result = p.typ()
+	}
+}
+
+func (p *parser) params() []astParam {
+	var params []astParam
+	p.expect("(")
+	for !p.is(")") {
+		var param astParam
+		param.name, param.pos = p.ident()
+		p.expect(":")
+		param.typ = p.typ()
+		params = append(params, param)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	p.expect(")")
+	return params
+}
+
+func (p *parser) typ() *astType {
+	pos := p.pos()
+	explicit := p.tok.explicit
+	name, _ := p.ident()
+	t := &astType{pos: pos, kind: name}
+	if explicit {
+		t.kind = "name"
+		t.name = name
+		return t
+	}
+	if prim, ok := primitiveTypes[name]; ok {
+		t.kind = prim
+		return t
+	}
+	switch name {
+	case "list", "option", "borrow", "own", "future", "stream":
+		if (name == "future" || name == "stream") && !p.is("<") {
+			return t
+		}
+		p.expect("<")
+		t.args = []*astType{p.typ()}
+		p.expect(">")
+	case "tuple":
+		p.expect("<")
+		for !p.is(">") {
+			t.args = append(t.args, p.typ())
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		p.expect(">")
+	case "result":
+		t.args = []*astType{nil, nil}
+		if !p.is("<") {
+			return t
+		}
+		p.next()
+		if p.is("_") {
+			p.next()
+		} else {
+			t.args[0] = p.typ()
+		}
+		if p.is(",") {
+			p.next()
+			t.args[1] = p.typ()
+		}
+		p.expect(">")
+	default:
+		t.kind = "name"
+		t.name = name
+	}
+	return t
+}
+
+func (p *parser) world(docs string) *astWorld {
+	p.expect("world")
+	w := &astWorld{docs: docs}
+	w.name, w.pos = p.ident()
+	p.expect("{")
+	for !p.is("}") {
+		docs := p.docs()
+		p.attributes()
+		switch {
+		case p.is("use"):
+			w.uses = append(w.uses, p.use())
+		case p.is("include"):
+			p.next()
+			include := &astInclude{path: p.usePath()}
+			if p.is("with") {
+				p.next()
+				p.expect("{")
+				include.names = make(map[string]string)
+				for !p.is("}") {
+					name, _ := p.ident()
+					p.expect("as")
+					include.names[name], _ = p.ident()
+					if !p.is(",") {
+						break
+					}
+					p.next()
+				}
+				p.expect("}")
+			} else {
+				p.expect(";")
+			}
+			w.includes = append(w.includes, include)
+		case p.is("import") || p.is("export"):
+			item := &astWorldItem{docs: docs, export: p.is("export")}
+			p.next()
+			item.pos = p.pos()
+			name, _ := p.ident()
+			qualified := false
+			if p.is(":") {
+				p.next()
+				qualified = !p.is("func") && !p.is("async") && !p.is("interface")
+			} else {
+				item.path = &astUsePath{pos: item.pos, name: name}
+			}
+			if item.path == nil && !qualified {
+				// Named import or export.
+				item.name = name
+				switch {
+				case p.is("interface"):
+					p.next()
+					item.iface = &astInterface{pos: item.pos, docs: docs}
+					p.interfaceBody(item.iface)
+				default:
+					item.fn = &astFunc{pos: item.pos, name: item.name, docs: docs, kind: "freestanding"}
+					p.funcType(item.fn)
+					p.expect(";")
+				}
+			} else {
+				if qualified {
+					item.path = p.qualifiedPath(name, item.pos)
+				}
+				p.expect(";")
+			}
+			w.items = append(w.items, item)
+		case p.tok.kind == tokIdent && !p.tok.explicit && isTypeKeyword(p.tok.text):
+			w.types = append(w.types, p.typeDef(docs))
+		default:
+			p.errorf(p.pos(), "expected 'import', 'export', 'use', 'include' or a type, found "+p.describe())
+		}
+	}
+	p.next()
+	return w
+}
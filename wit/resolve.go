@@ -0,0 +1,424 @@
+// Package wit reads WIT packages, which describe the interfaces and worlds of
+// WebAssembly components, and implements the parts of the canonical ABI that
+// are needed to generate bindings and to build components. The resolved form
+// mirrors the JSON printed by "wasm-tools component wit --json", so that
+// either can be used.
+package wit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Resolve is a set of resolved WIT packages, as printed by
+// "wasm-tools component wit --json". Items refer to each other by their index
+// in one of the slices.
+type Resolve struct {
+	Worlds     []*World     `json:"worlds"`
+	Interfaces []*Interface `json:"interfaces"`
+	Types      []*TypeDef   `json:"types"`
+	Packages   []*Package   `json:"packages"`
+}
+
+// Docs contains the documentation comment of a WIT item.
+type Docs struct {
+	Contents string `json:"contents"`
+}
+
+// Package is a WIT package, like "wasi:cli@0.2.0".
+type Package struct {
+	Name       string         `json:"name"`
+	Docs       Docs           `json:"docs"`
+	Interfaces map[string]int `json:"interfaces"`
+	Worlds     map[string]int `json:"worlds"`
+}
+
+// World is a WIT world: the imports and exports of a component.
+type World struct {
+	Name    string               `json:"name"`
+	Docs    Docs                 `json:"docs"`
+	Imports map[string]WorldItem `json:"imports"`
+	Exports map[string]WorldItem `json:"exports"`
+	Package *int                 `json:"package"`
+}
+
+// WorldItem is a single import or export of a world. Exactly one of the
+// fields is set.
+type WorldItem struct {
+	Interface *int
+	Function  *Function
+	Type      *int
+}
+
+// Interface is a WIT interface. Interfaces declared inline in a world have no
+// name.
+type Interface struct {
+	Name      *string              `json:"name"`
+	Docs      Docs                 `json:"docs"`
+	Types     map[string]int       `json:"types"`
+	Functions map[string]*Function `json:"functions"`
+	Package   *int                 `json:"package"`
+}
+
+// TypeDef is a type definition. Anonymous types (like list<u8>) have no name.
+type TypeDef struct {
+	Name  *string     `json:"name"`
+	Kind  TypeDefKind `json:"kind"`
+	Owner Owner       `json:"owner"`
+	Docs  Docs        `json:"docs"`
+}
+
+// Owner is the interface or world a type is defined in. Both are nil for
+// anonymous types that are not owned by anything.
+type Owner struct {
+	Interface *int `json:"interface"`
+	World     *int `json:"world"`
+}
+
+// Type is a reference to a type: either a primitive type like "u32" or
+// "string", or a type definition.
+type Type struct {
+	Primitive string // empty when this refers to a TypeDef
+	ID        int
+}
+
+// TypeDefKind describes what kind of type a TypeDef is, and holds the fields
+// relevant for that kind.
+type TypeDefKind struct {
+	Kind   string  // "record", "resource", "handle", "flags", "tuple", "variant", "enum", "option", "result", "list", "future", "stream", "type"
+	Fields []Field // record
+	Flags  []Field // flags
+	Types  []Type  // tuple
+	Cases  []Case  // variant, enum
+	Type   *Type   // option, list, type (alias), future, stream
+	OK     *Type   // result
+	Err    *Type   // result
+	Handle Handle  // handle
+}
+
+// Field is a record field or a flag.
+type Field struct {
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+	Docs Docs   `json:"docs"`
+}
+
+// Case is a variant or enum case. Type is nil for cases without a payload.
+type Case struct {
+	Name string `json:"name"`
+	Type *Type  `json:"type"`
+	Docs Docs   `json:"docs"`
+}
+
+// Handle is an owned or borrowed resource handle.
+type Handle struct {
+	Borrow   bool
+	Resource int
+}
+
+// Function is a freestanding function or a resource function.
+type Function struct {
+	Name   string       `json:"name"`
+	Kind   FunctionKind `json:"kind"`
+	Params []Param      `json:"params"`
+	Result *Type        `json:"result"`
+	Docs   Docs         `json:"docs"`
+
+	// Older versions of wasm-tools print a list of results instead.
+	Results []Param `json:"results"`
+}
+
+// Param is a function parameter.
+type Param struct {
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+}
+
+// FunctionKind is "freestanding", "method", "static" or "constructor". All but
+// freestanding functions belong to a resource.
+type FunctionKind struct {
+	Kind     string
+	Resource int
+}
+
+// Read decodes the JSON output of "wasm-tools component wit --json".
+func Read(r io.Reader) (*Resolve, error) {
+	res := &Resolve{}
+	if err := json.NewDecoder(r).Decode(res); err != nil {
+		return nil, err
+	}
+	for _, iface := range res.Interfaces {
+		for _, f := range iface.Functions {
+			if err := f.normalize(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, w := range res.Worlds {
+		for _, items := range []map[string]WorldItem{w.Imports, w.Exports} {
+			for _, item := range items {
+				if item.Function != nil {
+					if err := item.Function.normalize(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// World looks up a world by name. The name is either a fully qualified name
+// like "wasi:cli/command" or just the world name. If the name is empty, there
+// must be exactly one world in the last (main) package.
+func (res *Resolve) World(name string) (*World, error) {
+	var candidates []*World
+	for _, w := range res.Worlds {
+		switch {
+		case name == "":
+			if w.Package != nil && *w.Package == len(res.Packages)-1 {
+				candidates = append(candidates, w)
+			}
+		case name == w.Name || name == res.WorldName(w):
+			candidates = append(candidates, w)
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	var names []string
+	for _, w := range res.Worlds {
+		names = append(names, res.WorldName(w))
+	}
+	sort.Strings(names)
+	if len(candidates) == 0 && name != "" {
+		return nil, fmt.Errorf("world %q not found (available: %s)", name, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("specify a world with -wit-world (available: %s)", strings.Join(names, ", "))
+}
+
+// WorldName returns the fully qualified name of the world, without version.
+func (res *Resolve) WorldName(w *World) string {
+	if w.Package == nil {
+		return w.Name
+	}
+	pkg, _ := SplitVersion(res.Packages[*w.Package].Name)
+	return pkg + "/" + w.Name
+}
+
+// InterfaceDeps returns the other interfaces that an interface uses types
+// from, in a stable order.
+func (res *Resolve) InterfaceDeps(id int) []int {
+	var deps []int
+	seen := make(map[int]bool)
+	iface := res.Interfaces[id]
+	var names []string
+	for name := range iface.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := res.Types[iface.Types[name]]
+		if def.Kind.Kind != "type" || def.Kind.Type.Primitive != "" {
+			continue
+		}
+		owner := res.Types[def.Kind.Type.ID].Owner
+		if owner.Interface != nil && *owner.Interface != id && !seen[*owner.Interface] {
+			seen[*owner.Interface] = true
+			deps = append(deps, *owner.Interface)
+		}
+	}
+	return deps
+}
+
+// InterfaceKey returns the fully qualified name of an interface, like
+// "wasi:cli/environment@0.2.0", as used in world imports and exports and in
+// import module names of core modules.
+func (res *Resolve) InterfaceKey(id int) string {
+	iface := res.Interfaces[id]
+	name, version := SplitVersion(res.Packages[*iface.Package].Name)
+	key := name + "/" + *iface.Name
+	if version != "" {
+		key += "@" + version
+	}
+	return key
+}
+
+// SplitVersion splits "wasi:cli@0.2.0" into "wasi:cli" and "0.2.0".
+func SplitVersion(name string) (string, string) {
+	name, version, _ := strings.Cut(name, "@")
+	return name, version
+}
+
+func (f *Function) normalize() error {
+	if f.Result != nil || len(f.Results) == 0 {
+		return nil
+	}
+	if len(f.Results) > 1 || f.Results[0].Name != "" {
+		return fmt.Errorf("function %s: named results are not supported", f.Name)
+	}
+	f.Result = &f.Results[0].Type
+	f.Results = nil
+	return nil
+}
+
+// UnmarshalJSON decodes either {"interface": N}, {"interface": {"id": N}},
+// {"function": {...}} or {"type": N}.
+func (item *WorldItem) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Interface json.RawMessage `json:"interface"`
+		Function  *Function       `json:"function"`
+		Type      *int            `json:"type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	item.Function = raw.Function
+	item.Type = raw.Type
+	if raw.Interface != nil {
+		var id int
+		if err := json.Unmarshal(raw.Interface, &id); err != nil {
+			var obj struct {
+				ID int `json:"id"`
+			}
+			if err := json.Unmarshal(raw.Interface, &obj); err != nil {
+				return err
+			}
+			id = obj.ID
+		}
+		item.Interface = &id
+	}
+	if item.Interface == nil && item.Function == nil && item.Type == nil {
+		return fmt.Errorf("unknown world item: %s", data)
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a primitive type name or a type index.
+func (t *Type) UnmarshalJSON(data []byte) error {
+	var prim string
+	if err := json.Unmarshal(data, &prim); err == nil {
+		switch prim {
+		case "float32":
+			prim = "f32"
+		case "float64":
+			prim = "f64"
+		}
+		*t = Type{Primitive: prim}
+		return nil
+	}
+	return json.Unmarshal(data, &t.ID)
+}
+
+// UnmarshalJSON decodes a type definition kind, which is either a string
+// ("resource") or an object with a single key naming the kind.
+func (k *TypeDefKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*k = TypeDefKind{Kind: name}
+		return nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if len(obj) != 1 {
+		return fmt.Errorf("invalid type kind: %s", data)
+	}
+	for kind, value := range obj {
+		*k = TypeDefKind{Kind: kind}
+		switch kind {
+		case "record":
+			var v struct {
+				Fields []Field `json:"fields"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			k.Fields = v.Fields
+		case "flags":
+			var v struct {
+				Flags []Field `json:"flags"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			k.Flags = v.Flags
+		case "tuple":
+			var v struct {
+				Types []Type `json:"types"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			k.Types = v.Types
+		case "variant", "enum":
+			var v struct {
+				Cases []Case `json:"cases"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			k.Cases = v.Cases
+		case "result":
+			var v struct {
+				OK  *Type `json:"ok"`
+				Err *Type `json:"err"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			k.OK, k.Err = v.OK, v.Err
+		case "handle":
+			var v struct {
+				Own    *int `json:"own"`
+				Borrow *int `json:"borrow"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return err
+			}
+			switch {
+			case v.Own != nil:
+				k.Handle = Handle{Resource: *v.Own}
+			case v.Borrow != nil:
+				k.Handle = Handle{Borrow: true, Resource: *v.Borrow}
+			default:
+				return fmt.Errorf("invalid handle: %s", value)
+			}
+		default:
+			// option, list, type, future and stream all have a single
+			// (optional) type.
+			if string(value) != "null" {
+				var t Type
+				if err := json.Unmarshal(value, &t); err != nil {
+					return fmt.Errorf("unsupported type kind %q: %s", kind, value)
+				}
+				k.Type = &t
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes "freestanding", {"method": N}, {"static": N} or
+// {"constructor": N}.
+func (k *FunctionKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*k = FunctionKind{Kind: name}
+		return nil
+	}
+	var obj map[string]int
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	for kind, id := range obj {
+		*k = FunctionKind{Kind: kind, Resource: id}
+		return nil
+	}
+	return errors.New("empty function kind")
+}
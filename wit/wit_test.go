@@ -0,0 +1,193 @@
+package wit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoadDeps(t *testing.T) {
+	res, err := Load("testdata/cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packages []string
+	for _, pkg := range res.Packages {
+		packages = append(packages, pkg.Name)
+	}
+	if expected := []string{"wasi:io@0.2.0", "wasi:cli@0.2.0", "example:app"}; !reflect.DeepEqual(packages, expected) {
+		t.Errorf("expected packages %v, got %v", expected, packages)
+	}
+
+	world, err := res.World("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.Name != "app" {
+		t.Errorf("expected world app, got %s", world.Name)
+	}
+	checkKeys(t, "imports", world.Imports, []string{
+		"wasi:cli/environment@0.2.0",
+		"wasi:cli/stdout@0.2.0",
+		"wasi:io/error@0.2.0",
+		"wasi:io/streams@0.2.0",
+	})
+	checkKeys(t, "exports", world.Exports, []string{
+		"greet",
+		"wasi:cli/run@0.2.0",
+	})
+
+	streams := res.Interfaces[*world.Imports["wasi:io/streams@0.2.0"].Interface]
+	fn := streams.Functions["[method]output-stream.write"]
+	if fn == nil {
+		t.Fatal("method output-stream.write not found")
+	}
+	if len(fn.Params) != 2 || fn.Params[0].Name != "self" {
+		t.Fatalf("unexpected parameters: %+v", fn.Params)
+	}
+	self := res.Types[fn.Params[0].Type.ID]
+	if self.Kind.Kind != "handle" || !self.Kind.Handle.Borrow || self.Kind.Handle.Resource != streams.Types["output-stream"] {
+		t.Errorf("unexpected self parameter: %+v", self.Kind)
+	}
+
+	// The error resource is used (through an alias) in a variant case, as an
+	// owned handle.
+	streamError := res.Types[streams.Types["stream-error"]]
+	payload := res.Types[streamError.Kind.Cases[0].Type.ID]
+	if payload.Kind.Kind != "handle" || payload.Kind.Handle.Borrow {
+		t.Errorf("expected an owned handle, got %+v", payload.Kind)
+	}
+	if _, def := res.Underlying(Type{ID: payload.Kind.Handle.Resource}); def.Kind.Kind != "resource" || *def.Name != "error" {
+		t.Errorf("expected the error resource, got %+v", def)
+	}
+
+	// Anonymous types are shared.
+	stdout := res.Interfaces[*world.Imports["wasi:cli/stdout@0.2.0"].Interface]
+	run := res.Interfaces[*world.Exports["wasi:cli/run@0.2.0"].Interface]
+	flush := streams.Functions["[method]output-stream.blocking-flush"]
+	if *flush.Result != *streams.Functions["[method]output-stream.write"].Result {
+		t.Error("result<_, stream-error> is not shared")
+	}
+	if stdout.Functions["get-stdout"].Result == nil || run.Functions["run"].Result == nil {
+		t.Error("missing function results")
+	}
+	if docs := run.Functions["run"].Docs.Contents; docs != "Run the program." {
+		t.Errorf("unexpected docs: %q", docs)
+	}
+}
+
+func checkKeys(t *testing.T, name string, items map[string]WorldItem, expected []string) {
+	t.Helper()
+	var keys []string
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %s %v, got %v", name, expected, keys)
+	}
+}
+
+// The worlds used by the wasip2-http target combine wasi:cli and wasi:http.
+func TestLoadProxy(t *testing.T) {
+	res, err := Load("../src/internal/wasi/http/v0.2.0/proxy/proxy.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := res.World("tinygo:wasip2/http-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(t, "exports", world.Exports, []string{
+		"wasi:cli/run@0.2.0",
+		"wasi:http/incoming-handler@0.2.0",
+	})
+	for _, key := range []string{"wasi:cli/environment@0.2.0", "wasi:http/outgoing-handler@0.2.0", "wasi:http/types@0.2.0"} {
+		if _, ok := world.Imports[key]; !ok {
+			t.Errorf("world %s does not import %s", world.Name, key)
+		}
+	}
+
+	// Headers are an alias of the fields resource, and are passed as owned
+	// handles.
+	types := res.Interfaces[*world.Imports["wasi:http/types@0.2.0"].Interface]
+	fn := types.Functions["[method]incoming-request.headers"]
+	if fn == nil || fn.Result == nil {
+		t.Fatal("method incoming-request.headers not found")
+	}
+	_, handle := res.Underlying(*fn.Result)
+	if handle.Kind.Kind != "handle" || handle.Kind.Handle.Borrow {
+		t.Fatalf("expected an owned handle, got %+v", handle.Kind)
+	}
+	if _, def := res.Underlying(Type{ID: handle.Kind.Handle.Resource}); def.Kind.Kind != "resource" || *def.Name != "fields" {
+		t.Errorf("expected the fields resource, got %+v", def)
+	}
+
+	world, err = res.World("tinygo:wasip2/http-command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKeys(t, "exports", world.Exports, []string{
+		"wasi:cli/run@0.2.0",
+	})
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{"interface i {}", "no package declaration found"},
+		{"package a:b;\ninterface i {\n  f: func() -> foo;\n}", "3:16: type foo is not defined"},
+		{"package a:b;\ninterface i {\n  record r { x: r }\n}", "3:10: type r depends on itself"},
+		{"package a:b;\ninterface i {\n  use j.{t};\n}\ninterface j {\n  use i.{t};\n}", "2:11: interface i depends on itself"},
+		{"package a:b;\ninterface i {\n  use j.{t};\n}\ninterface j {}", "3:10: type t not found in interface j"},
+		{"package a:b;\nworld w {\n  import c:d/e;\n}", "3:10: package c:d not found"},
+		{"package a:b;\ninterface i {\n  f: func(x: u32, x: u32);\n}", "3:19: parameter x is defined more than once"},
+		{"package a:b;\ninterface i {\n  f: func() -> (a: u32);\n}", "3:16: named or multiple results are not supported"},
+		{"package a:b;\ninterface i {\n  f: func(x: borrow<u32>);\n}", "3:21: borrow<...> must refer to a resource"},
+		{"package a:b;\ninterface i {\n  f: func(x: u32) $\n}", "3:19: unexpected character '$'"},
+		{"package a:b;\ninterface i {\n  Foo-bar: func();\n}", "3:3: invalid identifier: Foo-bar"},
+		{"package a:b;\nworld w {\n  import f: func();\n  export f: func();\n}", "4:10: f is already imported"},
+	} {
+		path := filepath.Join(t.TempDir(), "test.wit")
+		if err := os.WriteFile(path, []byte(tc.src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if err == nil {
+			t.Errorf("expected error %q for:\n%s", tc.err, tc.src)
+			continue
+		}
+		msg := err.Error()
+		if len(msg) < len(tc.err) || msg[len(msg)-len(tc.err):] != tc.err {
+			t.Errorf("expected error %q, got %q", tc.err, msg)
+		}
+	}
+}
+
+func TestParseFeatureGates(t *testing.T) {
+	src := `
+/// Package docs.
+package a:b@1.0.0-rc.1;
+
+@since(version = 1.0.0)
+interface i {
+  @unstable(feature = foo)
+  f: func();
+  %type: func(%list: list<u8>);
+}
+`
+	f, err := parseFile("test.wit", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.pkg.version != "1.0.0-rc.1" || f.pkg.docs != "Package docs." {
+		t.Errorf("unexpected package: %+v", f.pkg)
+	}
+	if len(f.ifaces) != 1 || len(f.ifaces[0].funcs) != 2 || f.ifaces[0].funcs[1].name != "type" {
+		t.Errorf("unexpected interface: %+v", f.ifaces)
+	}
+}
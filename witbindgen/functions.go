@@ -0,0 +1,361 @@
+package witbindgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// funcNames holds the names used for a WIT function in the generated code.
+type funcNames struct {
+	resource string // Go type of the resource, for resource functions
+	method   bool   // whether this is a method (with a self parameter)
+	name     string // Go name of the imported function or method
+	field    string // name of the function in the Exports struct
+	wasm     string // suffix of the wasmimport_ or wasmexport_ function
+	short    string // WIT name without the resource prefix
+}
+
+func (g *generator) funcNames(fn *wit.Function) funcNames {
+	if fn.Kind.Kind == "freestanding" {
+		name := goName(fn.Name)
+		return funcNames{name: name, field: name, wasm: name, short: fn.Name}
+	}
+	resource := goName(*g.res.Types[fn.Kind.Resource].Name)
+	_, short, _ := strings.Cut(fn.Name, ".") // "[method]resource.name"
+	switch fn.Kind.Kind {
+	case "method":
+		name := goName(short)
+		return funcNames{resource: resource, method: true, name: name, field: name, wasm: resource + name, short: short}
+	case "static":
+		name := goName(short)
+		return funcNames{resource: resource, name: resource + name, field: name, wasm: resource + name, short: short}
+	case "constructor":
+		return funcNames{resource: resource, name: "New" + resource, field: "Constructor", wasm: "New" + resource, short: "constructor"}
+	}
+	g.fail("function %s: %s functions are not supported", fn.Name, fn.Kind.Kind)
+	return funcNames{name: "invalid", field: "invalid", wasm: "invalid"}
+}
+
+// paramNames returns the Go names of the parameters of a function.
+func (g *generator) paramNames(fn *wit.Function) []string {
+	var names []string
+	for i, param := range fn.Params {
+		if i == 0 && fn.Kind.Kind == "method" {
+			names = append(names, "self")
+			continue
+		}
+		names = append(names, paramName(param.Name))
+	}
+	return names
+}
+
+// flatNames returns variable names for the flattened values of a parameter or
+// result named base: base0, base1, etc.
+func flatNames(base string, n int) []string {
+	base = strings.TrimSuffix(base, "_")
+	var names []string
+	for i := 0; i < n; i++ {
+		names = append(names, base+fmt.Sprint(i))
+	}
+	return names
+}
+
+// signature returns the parameters and result of a Go function, as used in f.
+func (g *generator) signature(f *file, params []wit.Param, names []string, result *wit.Type) string {
+	var list []string
+	for i, param := range params {
+		list = append(list, names[i]+" "+g.goType(f, param.Type))
+	}
+	s := "(" + strings.Join(list, ", ") + ")"
+	if result != nil {
+		s += " (result " + g.goType(f, *result) + ")"
+	}
+	return s
+}
+
+// witSignature returns the function signature as written in WIT.
+func (g *generator) witSignature(fn *wit.Function) string {
+	var params []string
+	for i, param := range fn.Params {
+		if i == 0 && fn.Kind.Kind == "method" {
+			continue
+		}
+		params = append(params, param.Name+": "+g.witType(param.Type))
+	}
+	_, short, found := strings.Cut(fn.Name, ".")
+	if !found {
+		short = fn.Name
+	}
+	var s string
+	switch fn.Kind.Kind {
+	case "constructor":
+		return "constructor(" + strings.Join(params, ", ") + ")"
+	case "static":
+		s = short + ": static func(" + strings.Join(params, ", ") + ")"
+	default:
+		s = short + ": func(" + strings.Join(params, ", ") + ")"
+	}
+	if fn.Result != nil {
+		s += " -> " + g.witType(*fn.Result)
+	}
+	return s
+}
+
+// flatParamCount returns the number of core values the parameters of a
+// function are flattened to.
+func (g *generator) flatParamCount(fn *wit.Function) int {
+	n := 0
+	for _, param := range fn.Params {
+		n += len(g.res.Flatten(param.Type))
+	}
+	return n
+}
+
+// paramsStruct declares a struct type to pass the parameters of a function in
+// memory, for functions with too many parameters to pass them directly.
+func (g *generator) paramsStruct(pkg *goPackage, name string, fn *wit.Function, names []string) {
+	a := pkg.abi.sub()
+	a.printf("// %s holds the parameters of %q, which are passed in memory.\n", name, fn.Name)
+	a.printf("type %s struct {\n_ cm.HostLayout\n", name)
+	for i, param := range fn.Params {
+		a.printf("%s %s\n", names[i], g.goType(a, param.Type))
+	}
+	a.printf("}\n\n")
+	pkg.abi.buf.Write(a.buf.Bytes())
+}
+
+// importFunction generates a Go function (or method) that calls an imported
+// function.
+func (g *generator) importFunction(pkg *goPackage, fn *wit.Function) {
+	f := pkg.decls
+	names := g.funcNames(fn)
+	paramNames := g.paramNames(fn)
+	params := fn.Params
+	recv := ""
+	if names.method {
+		recv = "(self " + names.resource + ") "
+		params = params[1:]
+	}
+
+	switch fn.Kind.Kind {
+	case "method":
+		f.printf("// %s represents the imported method %q.\n", names.name, names.short)
+	case "static":
+		f.printf("// %s represents the imported static function %q.\n", names.name, names.short)
+	case "constructor":
+		f.printf("// %s represents the imported constructor for resource %q.\n", names.name, *g.res.Types[fn.Kind.Resource].Name)
+	default:
+		f.printf("// %s represents the imported function %q.\n", names.name, names.short)
+	}
+	f.docs("", fn.Docs)
+	f.wit("", g.witSignature(fn))
+	f.printf("//\n//go:nosplit\n")
+	f.printf("func %s%s%s {\n", recv, names.name, g.signature(f, params, paramNames[len(paramNames)-len(params):], fn.Result))
+
+	wasmName := "wasmimport_" + names.wasm
+	var args, decls []string
+	if g.flatParamCount(fn) > wit.MaxFlatParams {
+		g.paramsStruct(pkg, wasmName+"Params", fn, paramNames)
+		var fields []string
+		for _, name := range paramNames {
+			fields = append(fields, name+": "+name)
+		}
+		f.printf("params := %sParams{%s}\n", wasmName, strings.Join(fields, ", "))
+		args = append(args, "&params")
+		decls = append(decls, "params *"+wasmName+"Params")
+	} else {
+		for i, param := range fn.Params {
+			flat := flatNames(paramNames[i], len(g.res.Flatten(param.Type)))
+			g.lower(f, param.Type, paramNames[i], flat, ":=")
+			for j, typ := range g.flatGoTypes(pkg.wasm, param.Type) {
+				decls = append(decls, flat[j]+" "+typ)
+			}
+			args = append(args, flat...)
+		}
+	}
+
+	resultDecl := ""
+	call := func() string {
+		return wasmName + "(" + strings.Join(args, ", ") + ")"
+	}
+	if fn.Result != nil {
+		switch flat := g.res.Flatten(*fn.Result); {
+		case len(flat) > wit.MaxFlatResults:
+			args = append(args, "&result")
+			decls = append(decls, "result *"+g.goType(pkg.wasm, *fn.Result))
+			f.printf("%s\n", call())
+		case len(flat) == 1:
+			resultDecl = " (result0 " + g.flatGoTypes(pkg.wasm, *fn.Result)[0] + ")"
+			f.printf("result0 := %s\n", call())
+			f.printf("result = %s\n", g.lift(f, *fn.Result, []string{"result0"}))
+		default:
+			f.printf("%s\n", call())
+		}
+	} else {
+		f.printf("%s\n", call())
+	}
+	f.printf("return\n}\n\n")
+
+	pkg.wasm.printf("//go:wasmimport %s %s\n//go:noescape\nfunc %s(%s)%s\n\n", pkg.module, fn.Name, wasmName, strings.Join(decls, ", "), resultDecl)
+}
+
+// importResource generates the resource-drop method and the functions of an
+// imported resource.
+func (g *generator) importResource(pkg *goPackage, id int) {
+	f := pkg.decls
+	witName := *g.res.Types[id].Name
+	name := goName(witName)
+	f.printf("// ResourceDrop represents the imported resource-drop for resource %q.\n//\n// Drops a resource handle.\n//\n//go:nosplit\n", witName)
+	f.printf("func (self %s) ResourceDrop() {\nself0 := cm.Reinterpret[uint32](self)\nwasmimport_%sResourceDrop(self0)\nreturn\n}\n\n", name, name)
+	pkg.wasm.printf("//go:wasmimport %s [resource-drop]%s\n//go:noescape\nfunc wasmimport_%sResourceDrop(self0 uint32)\n\n", pkg.module, witName, name)
+
+	for _, fn := range pkg.imports {
+		if fn.Kind.Kind != "freestanding" && fn.Kind.Resource == id {
+			g.importFunction(pkg, fn)
+		}
+	}
+}
+
+// importExportedResource generates the functions to create, access and drop
+// handles of a resource implemented by the component.
+func (g *generator) importExportedResource(pkg *goPackage, id int) {
+	f := pkg.decls
+	witName := *g.res.Types[id].Name
+	name := goName(witName)
+	module := "[export]" + pkg.module
+
+	f.printf("// %sResourceNew represents the imported resource-new for resource %q.\n//\n// Creates a new resource handle.\n//\n//go:nosplit\n", name, witName)
+	f.printf("func %sResourceNew(rep cm.Rep) (result %s) {\nrep0 := cm.Reinterpret[uint32](rep)\nresult0 := wasmimport_%sResourceNew(rep0)\nresult = cm.Reinterpret[%s](result0)\nreturn\n}\n\n", name, name, name, name)
+	pkg.wasm.printf("//go:wasmimport %s [resource-new]%s\n//go:noescape\nfunc wasmimport_%sResourceNew(rep0 uint32) (result0 uint32)\n\n", module, witName, name)
+
+	f.printf("// ResourceRep represents the imported resource-rep for resource %q.\n//\n// Returns the underlying resource representation.\n//\n//go:nosplit\n", witName)
+	f.printf("func (self %s) ResourceRep() (result cm.Rep) {\nself0 := cm.Reinterpret[uint32](self)\nresult0 := wasmimport_%sResourceRep(self0)\nresult = cm.Reinterpret[cm.Rep](result0)\nreturn\n}\n\n", name, name)
+	pkg.wasm.printf("//go:wasmimport %s [resource-rep]%s\n//go:noescape\nfunc wasmimport_%sResourceRep(self0 uint32) (result0 uint32)\n\n", module, witName, name)
+
+	f.printf("// ResourceDrop represents the imported resource-drop for resource %q.\n//\n// Drops a resource handle.\n//\n//go:nosplit\n", witName)
+	f.printf("func (self %s) ResourceDrop() {\nself0 := cm.Reinterpret[uint32](self)\nwasmimport_%sResourceDrop(self0)\nreturn\n}\n\n", name, name)
+	pkg.wasm.printf("//go:wasmimport %s [resource-drop]%s\n//go:noescape\nfunc wasmimport_%sResourceDrop(self0 uint32)\n\n", module, witName, name)
+}
+
+// exportFunctions generates the Exports variable, through which the program
+// provides the implementation of exported functions and resources, and the
+// wasmexport functions that call them.
+func (g *generator) exportFunctions(pkg *goPackage) {
+	var resources []int
+	for _, id := range pkg.types {
+		if g.res.Types[id].Kind.Kind == "resource" {
+			resources = append(resources, id)
+		}
+	}
+	var funcs []*wit.Function
+	for _, fn := range pkg.exports {
+		if fn.Kind.Kind == "freestanding" {
+			funcs = append(funcs, fn)
+		}
+	}
+	if len(resources) == 0 && len(funcs) == 0 {
+		return
+	}
+
+	e := pkg.exportDecls
+	e.printf("// Exports represents the caller-defined exports from %q.\nvar Exports struct {\n", pkg.witName)
+	for i, id := range resources {
+		if i > 0 {
+			e.printf("\n")
+		}
+		witName := *g.res.Types[id].Name
+		name := goName(witName)
+		e.printf("\t// %s represents the caller-defined exports for resource %q.\n\t%s struct {\n", name, pkg.qualifiedName(witName), name)
+		e.printf("\t\t// Destructor represents the caller-defined, exported destructor for resource %q.\n", witName)
+		e.printf("\t\t//\n\t\t// Resource destructor.\n\t\tDestructor func(self cm.Rep)\n")
+		exportName := pkg.prefix + "[dtor]" + witName
+		pkg.wasm.printf("//go:wasmexport %s\n//export %s\nfunc wasmexport_%sDestructor(self0 uint32) {\nself := cm.Reinterpret[cm.Rep](self0)\nExports.%s.Destructor(self)\nreturn\n}\n\n", exportName, exportName, name, name)
+		for _, fn := range pkg.exports {
+			if fn.Kind.Kind != "freestanding" && fn.Kind.Resource == id {
+				e.printf("\n")
+				g.exportFunction(pkg, fn, name+".", "\t\t")
+			}
+		}
+		e.printf("\t}\n")
+	}
+	for i, fn := range funcs {
+		if i > 0 || len(resources) > 0 {
+			e.printf("\n")
+		}
+		g.exportFunction(pkg, fn, "", "\t")
+	}
+	e.printf("}\n")
+}
+
+// exportFunction generates the field in the Exports struct for an exported
+// function, and the wasmexport function that calls it.
+func (g *generator) exportFunction(pkg *goPackage, fn *wit.Function, fieldPrefix, indent string) {
+	g.exporting = true
+	defer func() {
+		g.exporting = false
+	}()
+	e := pkg.exportDecls
+	names := g.funcNames(fn)
+	paramNames := g.paramNames(fn)
+
+	switch fn.Kind.Kind {
+	case "method":
+		e.printf("%s// %s represents the caller-defined, exported method %q.\n", indent, names.field, names.short)
+	case "static":
+		e.printf("%s// %s represents the caller-defined, exported static function %q.\n", indent, names.field, names.short)
+	case "constructor":
+		e.printf("%s// %s represents the caller-defined, exported constructor for resource %q.\n", indent, names.field, *g.res.Types[fn.Kind.Resource].Name)
+	default:
+		e.printf("%s// %s represents the caller-defined, exported function %q.\n", indent, names.field, names.short)
+	}
+	e.docs(indent, fn.Docs)
+	e.wit(indent, g.witSignature(fn))
+	e.printf("%s%s func%s\n", indent, names.field, g.signature(e, fn.Params, paramNames, fn.Result))
+
+	w := pkg.wasm
+	body := w.sub()
+	wasmName := "wasmexport_" + names.wasm
+	var args, decls []string
+	if g.flatParamCount(fn) > wit.MaxFlatParams {
+		g.paramsStruct(pkg, wasmName+"Params", fn, paramNames)
+		decls = append(decls, "params *"+wasmName+"Params")
+		for _, name := range paramNames {
+			args = append(args, "params."+name)
+		}
+	} else {
+		for i, param := range fn.Params {
+			types := g.flatGoTypes(w, param.Type)
+			flat := flatNames(paramNames[i], len(types))
+			for j, typ := range types {
+				decls = append(decls, flat[j]+" "+typ)
+			}
+			body.printf("%s := %s\n", paramNames[i], g.lift(body, param.Type, flat))
+			args = append(args, paramNames[i])
+		}
+	}
+
+	call := "Exports." + fieldPrefix + names.field + "(" + strings.Join(args, ", ") + ")"
+	resultDecl := ""
+	if fn.Result != nil {
+		switch flat := g.res.Flatten(*fn.Result); {
+		case len(flat) > wit.MaxFlatResults:
+			resultDecl = " (result *" + g.goType(w, *fn.Result) + ")"
+			body.printf("ret := %s\nresult = &ret\n", call)
+		case len(flat) == 1:
+			resultDecl = " (result0 " + g.flatGoTypes(w, *fn.Result)[0] + ")"
+			body.printf("result := %s\n", call)
+			g.lower(body, *fn.Result, "result", []string{"result0"}, "=")
+		default:
+			body.printf("%s\n", call)
+		}
+	} else {
+		body.printf("%s\n", call)
+	}
+
+	exportName := pkg.prefix + fn.Name
+	w.printf("//go:wasmexport %s\n//export %s\nfunc %s(%s)%s {\n", exportName, exportName, wasmName, strings.Join(decls, ", "), resultDecl)
+	w.buf.Write(body.buf.Bytes())
+	w.printf("return\n}\n\n")
+}
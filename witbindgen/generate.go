@@ -0,0 +1,838 @@
+// Package witbindgen generates Go bindings for WebAssembly component model
+// worlds described in WIT, as read by package wit. The generated code uses the
+// canonical ABI helpers from the cm package (src/internal/cm in TinyGo, or
+// go.bytecodealliance.org/cm outside of it).
+package witbindgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// DefaultCMPackage is the import path of the package with canonical ABI
+// helpers used by the generated code, when not generating code inside the
+// TinyGo tree (where it is internal/cm).
+const DefaultCMPackage = "go.bytecodealliance.org/cm"
+
+// Config controls how Go bindings are generated.
+type Config struct {
+	// PackageRoot is the Go import path of the output directory. It is needed
+	// for generated packages to import each other.
+	PackageRoot string
+
+	// CMPackage is the import path of the cm package. If empty,
+	// DefaultCMPackage is used.
+	CMPackage string
+}
+
+// File is a generated file.
+type File struct {
+	Path    string // path relative to the output directory, using forward slashes
+	Content []byte
+}
+
+type generator struct {
+	res       *wit.Resolve
+	config    Config
+	worldPkg  *goPackage
+	ifaces    map[int]*goPackage
+	packages  []*goPackage
+	exporting bool // generating an exported function, where borrowed handles of exported resources are reps
+	err       error
+}
+
+// goPackage is a Go package generated for a WIT interface or world.
+type goPackage struct {
+	dir      string // relative to the output directory
+	name     string // Go package name
+	fileBase string // base name of the generated files
+	witName  string // like "wasi:io/streams@0.2.0"
+	witPkg   string // like "wasi:io@0.2.0"
+	docs     wit.Docs
+	isWorld  bool
+	imported bool
+	exported bool
+	module   string // wasm import module of imported functions
+	prefix   string // prefix of names of exported functions
+
+	types   []int
+	imports []*wit.Function
+	exports []*wit.Function
+
+	decls       *file // types and imported functions
+	wasm        *file // wasmimport and wasmexport declarations
+	exportDecls *file // the Exports variable
+	abi         *file // helper functions to lower and lift values
+	helpers     map[string]bool
+}
+
+// file is a generated Go file. The body is written to buf while the imports
+// are collected.
+type file struct {
+	pkg     *goPackage
+	imports map[string]string // import path => package name used in this file
+	names   map[string]bool   // package names in use
+	buf     *bytes.Buffer
+}
+
+func newFile(pkg *goPackage) *file {
+	return &file{
+		pkg:     pkg,
+		imports: make(map[string]string),
+		names:   make(map[string]bool),
+		buf:     new(bytes.Buffer),
+	}
+}
+
+func (f *file) printf(format string, args ...interface{}) {
+	fmt.Fprintf(f.buf, format, args...)
+}
+
+// sub returns a writer that shares the imports of f, but writes to a separate
+// buffer. It is used to generate helper functions while generating another
+// function.
+func (f *file) sub() *file {
+	sub := *f
+	sub.buf = new(bytes.Buffer)
+	return &sub
+}
+
+// docs writes a documentation comment, preceded by an empty comment line.
+func (f *file) docs(indent string, docs wit.Docs) {
+	contents := strings.TrimSpace(docs.Contents)
+	if contents == "" {
+		return
+	}
+	f.printf("%s//\n", indent)
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			f.printf("%s//\n", indent)
+		} else {
+			f.printf("%s// %s\n", indent, line)
+		}
+	}
+}
+
+// wit writes WIT source lines as a preformatted block in a doc comment.
+func (f *file) wit(indent string, lines ...string) {
+	f.printf("%s//\n", indent)
+	for _, line := range lines {
+		f.printf("%s//\t%s\n", indent, line)
+	}
+}
+
+// qualify returns the prefix to use for identifiers from pkg, adding an import
+// if needed.
+func (f *file) qualify(g *generator, pkg *goPackage) string {
+	if pkg == f.pkg {
+		return ""
+	}
+	importPath := path.Join(g.config.PackageRoot, pkg.dir)
+	if name, ok := f.imports[importPath]; ok {
+		return name + "."
+	}
+	witPkgName, _ := wit.SplitVersion(pkg.witPkg)
+	ns, name, _ := strings.Cut(witPkgName, ":")
+	candidates := []string{pkg.name, packageName(name) + pkg.name, packageName(ns) + packageName(name) + pkg.name}
+	for i := 2; ; i++ {
+		if i > 2 {
+			candidates = []string{pkg.name + strconv.Itoa(i)}
+		}
+		for _, c := range candidates {
+			if !f.names[c] && c != f.pkg.name && !reservedNames[c] {
+				f.imports[importPath] = c
+				f.names[c] = true
+				return c + "."
+			}
+		}
+	}
+}
+
+// bytes returns the formatted contents of the file.
+func (f *file) bytes(g *generator, doc string) ([]byte, error) {
+	body := f.buf.String()
+	for _, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); !strings.HasPrefix(line, "//") && strings.Contains(line, "cm.") {
+			f.imports[g.config.CMPackage] = "cm"
+			break
+		}
+	}
+	var out bytes.Buffer
+	out.WriteString("// Code generated by tinygo wit-bindgen. DO NOT EDIT.\n\n")
+	out.WriteString(doc)
+	fmt.Fprintf(&out, "package %s\n\n", f.pkg.name)
+	if len(f.imports) != 0 {
+		var paths []string
+		for p := range f.imports {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		out.WriteString("import (\n")
+		for _, p := range paths {
+			if name := f.imports[p]; name != path.Base(p) {
+				fmt.Fprintf(&out, "\t%s %q\n", name, p)
+			} else {
+				fmt.Fprintf(&out, "\t%q\n", p)
+			}
+		}
+		out.WriteString(")\n\n")
+	}
+	out.WriteString(body)
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not format generated code: %w", f.pkg.dir, err)
+	}
+	return src, nil
+}
+
+// Generate generates Go bindings for the given world: one Go package for each
+// imported or exported interface, and one for the world itself.
+func Generate(res *wit.Resolve, world *wit.World, config Config) ([]File, error) {
+	if config.CMPackage == "" {
+		config.CMPackage = DefaultCMPackage
+	}
+	g := &generator{
+		res:    res,
+		config: config,
+		ifaces: make(map[int]*goPackage),
+	}
+
+	worldName, version := world.Name, ""
+	witPkg := ""
+	if world.Package != nil {
+		witPkg = res.Packages[*world.Package].Name
+		_, version = wit.SplitVersion(witPkg)
+		worldName = res.WorldName(world)
+		if version != "" {
+			worldName += "@" + version
+		}
+	}
+	g.worldPkg = g.newPackage(path.Join(packageDir(witPkg), world.Name), world.Name, worldName, witPkg)
+	g.worldPkg.isWorld = true
+	g.worldPkg.docs = world.Docs
+	g.worldPkg.module = "$root"
+
+	for _, key := range sortedKeys(world.Imports) {
+		item := world.Imports[key]
+		switch {
+		case item.Interface != nil:
+			g.interfacePackage(key, *item.Interface).imported = true
+		case item.Function != nil:
+			g.worldPkg.imports = append(g.worldPkg.imports, item.Function)
+			g.worldPkg.imported = true
+		case item.Type != nil:
+			g.worldPkg.types = append(g.worldPkg.types, *item.Type)
+		}
+	}
+	for _, key := range sortedKeys(world.Exports) {
+		item := world.Exports[key]
+		switch {
+		case item.Interface != nil:
+			pkg := g.interfacePackage(key, *item.Interface)
+			if pkg.imported {
+				return nil, fmt.Errorf("interface %s is both imported and exported", key)
+			}
+			pkg.exported = true
+			pkg.prefix = key + "#"
+		case item.Function != nil:
+			g.worldPkg.exports = append(g.worldPkg.exports, item.Function)
+			g.worldPkg.exported = true
+		}
+	}
+	sort.Ints(g.worldPkg.types)
+
+	var files []File
+	for _, pkg := range g.packages {
+		g.generatePackage(pkg)
+		if g.err != nil {
+			return nil, g.err
+		}
+		pkgFiles, err := g.packageFiles(pkg)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, pkgFiles...)
+	}
+	return files, nil
+}
+
+// packageDir returns the directory for a WIT package: "wasi:io@0.2.0" is
+// placed in "wasi/io/v0.2.0".
+func packageDir(witPkg string) string {
+	name, version := wit.SplitVersion(witPkg)
+	dir := strings.ReplaceAll(name, ":", "/")
+	if version != "" {
+		dir = path.Join(dir, "v"+version)
+	}
+	return dir
+}
+
+func sortedKeys(m map[string]wit.WorldItem) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (g *generator) newPackage(dir, name, witName, witPkg string) *goPackage {
+	pkg := &goPackage{
+		dir:      dir,
+		name:     packageName(name),
+		fileBase: name,
+		witName:  witName,
+		witPkg:   witPkg,
+		helpers:  make(map[string]bool),
+	}
+	pkg.decls = newFile(pkg)
+	pkg.wasm = newFile(pkg)
+	pkg.exportDecls = newFile(pkg)
+	pkg.abi = newFile(pkg)
+	g.packages = append(g.packages, pkg)
+	return pkg
+}
+
+// interfacePackage returns the Go package for the interface with the given
+// world key, creating it if needed.
+func (g *generator) interfacePackage(key string, id int) *goPackage {
+	if pkg := g.ifaces[id]; pkg != nil {
+		return pkg
+	}
+	iface := g.res.Interfaces[id]
+	var pkg *goPackage
+	if iface.Name != nil && iface.Package != nil {
+		witPkg := g.res.Packages[*iface.Package].Name
+		pkg = g.newPackage(path.Join(packageDir(witPkg), *iface.Name), *iface.Name, key, witPkg)
+	} else {
+		// Interface declared inline in the world.
+		pkg = g.newPackage(path.Join(g.worldPkg.dir, key), key, key, g.worldPkg.witPkg)
+	}
+	pkg.docs = iface.Docs
+	pkg.module = key
+	for _, id := range iface.Types {
+		pkg.types = append(pkg.types, id)
+	}
+	sort.Ints(pkg.types)
+	var funcs []*wit.Function
+	for _, name := range sortedFuncNames(iface.Functions) {
+		funcs = append(funcs, iface.Functions[name])
+	}
+	pkg.imports = funcs
+	pkg.exports = funcs
+	g.ifaces[id] = pkg
+	return pkg
+}
+
+func sortedFuncNames(m map[string]*wit.Function) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *generator) fail(format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf(format, args...)
+	}
+}
+
+// packageFiles returns the files of a generated package.
+func (g *generator) packageFiles(pkg *goPackage) ([]File, error) {
+	var files []File
+	add := func(name string, f *file, doc string) error {
+		content, err := f.bytes(g, doc)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: path.Join(pkg.dir, name), Content: content})
+		return nil
+	}
+
+	what := "world"
+	switch {
+	case pkg.isWorld:
+	case pkg.imported:
+		what = "imported interface"
+	case pkg.exported:
+		what = "exported interface"
+	}
+	doc := fmt.Sprintf("// Package %s represents the %s %q.\n", pkg.name, what, pkg.witName)
+	docs := newFile(pkg)
+	docs.docs("", pkg.docs)
+	doc += docs.buf.String()
+	if err := add(pkg.fileBase+".wit.go", pkg.decls, doc); err != nil {
+		return nil, err
+	}
+	if pkg.wasm.buf.Len() != 0 {
+		body := pkg.wasm.buf.String()
+		pkg.wasm.buf.Reset()
+		pkg.wasm.printf("// This file contains wasmimport and wasmexport declarations for %q.\n\n", pkg.witPkg)
+		pkg.wasm.buf.WriteString(body)
+		if err := add(packageName(pkg.fileBase)+".wasm.go", pkg.wasm, ""); err != nil {
+			return nil, err
+		}
+	}
+	if pkg.exportDecls.buf.Len() != 0 {
+		if err := add(pkg.fileBase+".exports.go", pkg.exportDecls, ""); err != nil {
+			return nil, err
+		}
+	}
+	if pkg.abi.buf.Len() != 0 {
+		if err := add("abi.go", pkg.abi, ""); err != nil {
+			return nil, err
+		}
+	}
+	files = append(files, File{
+		Path: path.Join(pkg.dir, "empty.s"),
+		Content: []byte("// This file exists for testing this package without WebAssembly,\n" +
+			"// allowing empty function bodies with a //go:wasmimport directive.\n" +
+			"// See https://pkg.go.dev/cmd/compile for more information.\n"),
+	})
+	return files, nil
+}
+
+// generatePackage generates the contents of all files of a package.
+func (g *generator) generatePackage(pkg *goPackage) {
+	// Types, with the functions of each resource right after it.
+	for _, id := range pkg.types {
+		g.declareType(pkg, id)
+		if g.res.Types[id].Kind.Kind == "resource" {
+			if pkg.imported {
+				g.importResource(pkg, id)
+			}
+			if pkg.exported {
+				g.importExportedResource(pkg, id)
+			}
+		}
+	}
+
+	// Freestanding functions.
+	if pkg.imported {
+		for _, fn := range pkg.imports {
+			if fn.Kind.Kind == "freestanding" {
+				g.importFunction(pkg, fn)
+			}
+		}
+	}
+	if pkg.exported {
+		g.exportFunctions(pkg)
+	}
+}
+
+// qualifiedName returns the WIT name of a type in a package, like
+// "wasi:io/streams@0.2.0#input-stream".
+func (pkg *goPackage) qualifiedName(name string) string {
+	return pkg.witName + "#" + name
+}
+
+// ownerPackage returns the Go package in which a named type is declared.
+func (g *generator) ownerPackage(def *wit.TypeDef) *goPackage {
+	switch {
+	case def.Owner.Interface != nil:
+		if pkg := g.ifaces[*def.Owner.Interface]; pkg != nil {
+			return pkg
+		}
+		g.fail("type %s is defined in an interface that is not part of the world", *def.Name)
+	case def.Owner.World != nil:
+		return g.worldPkg
+	default:
+		g.fail("type %s is not defined in an interface or world", *def.Name)
+	}
+	return g.worldPkg
+}
+
+// exportedResource returns whether the resource is implemented by the
+// component (as opposed to being imported from the host).
+func (g *generator) exportedResource(id int) bool {
+	_, def := g.res.Underlying(wit.Type{ID: id})
+	if def == nil || def.Kind.Kind != "resource" || def.Owner.Interface == nil {
+		return false
+	}
+	pkg := g.ifaces[*def.Owner.Interface]
+	return pkg != nil && pkg.exported
+}
+
+var primitiveGoTypes = map[string]string{
+	"bool":   "bool",
+	"u8":     "uint8",
+	"u16":    "uint16",
+	"u32":    "uint32",
+	"u64":    "uint64",
+	"s8":     "int8",
+	"s16":    "int16",
+	"s32":    "int32",
+	"s64":    "int64",
+	"f32":    "float32",
+	"f64":    "float64",
+	"char":   "rune",
+	"string": "string",
+}
+
+// goType returns the Go type expression for a WIT type, as used in f.
+func (g *generator) goType(f *file, t wit.Type) string {
+	if t.Primitive != "" {
+		if s, ok := primitiveGoTypes[t.Primitive]; ok {
+			return s
+		}
+		g.fail("unsupported type %s", t.Primitive)
+		return "struct{}"
+	}
+	def := g.res.Types[t.ID]
+	if def.Name != nil {
+		return f.qualify(g, g.ownerPackage(def)) + goName(*def.Name)
+	}
+	return g.anonGoType(f, def)
+}
+
+// anonGoType returns the Go type expression for the structure of a type
+// definition, ignoring its name.
+func (g *generator) anonGoType(f *file, def *wit.TypeDef) string {
+	switch def.Kind.Kind {
+	case "list":
+		return "cm.List[" + g.goType(f, *def.Kind.Type) + "]"
+	case "option":
+		return "cm.Option[" + g.goType(f, *def.Kind.Type) + "]"
+	case "result":
+		ok, err := def.Kind.OK, def.Kind.Err
+		if ok == nil && err == nil {
+			return "cm.BoolResult"
+		}
+		okType, errType := "struct{}", "struct{}"
+		var shape string
+		if ok != nil {
+			okType = g.goType(f, *ok)
+			shape = okType
+		}
+		if err != nil {
+			errType = g.goType(f, *err)
+			if shape == "" {
+				shape = errType
+			} else if errSize, _ := g.res.SizeAlign(*err); errSize > g.size(*ok) {
+				shape = errType
+			}
+		}
+		return "cm.Result[" + shape + ", " + okType + ", " + errType + "]"
+	case "tuple":
+		types := def.Kind.Types
+		var elems []string
+		same := true
+		for _, t := range types {
+			elems = append(elems, g.goType(f, t))
+			same = same && elems[len(elems)-1] == elems[0]
+		}
+		switch {
+		case len(types) == 0:
+			return "struct{}"
+		case same:
+			return fmt.Sprintf("[%d]%s", len(types), elems[0])
+		case len(types) == 2:
+			return "cm.Tuple[" + strings.Join(elems, ", ") + "]"
+		case len(types) <= 16:
+			return fmt.Sprintf("cm.Tuple%d[%s]", len(types), strings.Join(elems, ", "))
+		}
+		g.fail("tuples with more than 16 fields are not supported")
+	case "handle":
+		if def.Kind.Handle.Borrow && g.exporting && g.exportedResource(def.Kind.Handle.Resource) {
+			return "cm.Rep"
+		}
+		return g.goType(f, wit.Type{ID: def.Kind.Handle.Resource})
+	case "type":
+		return g.goType(f, *def.Kind.Type)
+	default:
+		g.fail("unsupported type: %s", def.Kind.Kind)
+	}
+	return "struct{}"
+}
+
+func (g *generator) size(t wit.Type) int {
+	size, _ := g.res.SizeAlign(t)
+	return size
+}
+
+// tagType returns the Go type of the discriminant of a variant or enum.
+func tagType(cases int) string {
+	switch wit.DiscriminantSize(cases) {
+	case 1:
+		return "uint8"
+	case 2:
+		return "uint16"
+	default:
+		return "uint32"
+	}
+}
+
+// witType returns a type as written in WIT.
+func (g *generator) witType(t wit.Type) string {
+	if t.Primitive != "" {
+		return t.Primitive
+	}
+	def := g.res.Types[t.ID]
+	if def.Name != nil {
+		return *def.Name
+	}
+	return g.witAnonType(def)
+}
+
+func (g *generator) witAnonType(def *wit.TypeDef) string {
+	k := def.Kind
+	switch k.Kind {
+	case "list", "option":
+		return k.Kind + "<" + g.witType(*k.Type) + ">"
+	case "result":
+		switch {
+		case k.OK == nil && k.Err == nil:
+			return "result"
+		case k.Err == nil:
+			return "result<" + g.witType(*k.OK) + ">"
+		case k.OK == nil:
+			return "result<_, " + g.witType(*k.Err) + ">"
+		}
+		return "result<" + g.witType(*k.OK) + ", " + g.witType(*k.Err) + ">"
+	case "tuple":
+		var elems []string
+		for _, t := range k.Types {
+			elems = append(elems, g.witType(t))
+		}
+		return "tuple<" + strings.Join(elems, ", ") + ">"
+	case "handle":
+		name := g.witType(wit.Type{ID: k.Handle.Resource})
+		if k.Handle.Borrow {
+			return "borrow<" + name + ">"
+		}
+		return name
+	case "type":
+		return g.witType(*k.Type)
+	case "future", "stream":
+		if k.Type == nil {
+			return k.Kind
+		}
+		return k.Kind + "<" + g.witType(*k.Type) + ">"
+	}
+	return k.Kind
+}
+
+// declareType writes the Go declaration of a named type.
+func (g *generator) declareType(pkg *goPackage, id int) {
+	f := pkg.decls
+	def := g.res.Types[id]
+	witName := *def.Name
+	name := goName(witName)
+	direction := "imported"
+	if pkg.exported {
+		direction = "exported"
+	}
+
+	switch def.Kind.Kind {
+	case "record":
+		f.printf("// %s represents the record %q.\n", name, pkg.qualifiedName(witName))
+		f.docs("", def.Docs)
+		lines := []string{"record " + witName + " {"}
+		for _, field := range def.Kind.Fields {
+			lines = append(lines, "\t"+field.Name+": "+g.witType(field.Type)+",")
+		}
+		f.wit("", append(lines, "}")...)
+		f.printf("type %s struct {\n_ cm.HostLayout\n", name)
+		for i, field := range def.Kind.Fields {
+			if i > 0 && (hasDocs(def.Kind.Fields[i-1].Docs) || hasDocs(field.Docs)) {
+				f.printf("\n")
+			}
+			if contents := strings.TrimSpace(field.Docs.Contents); contents != "" {
+				for _, line := range strings.Split(contents, "\n") {
+					f.printf("%s\n", strings.TrimRight("// "+line, " \t"))
+				}
+			}
+			f.printf("%s %s\n", goName(field.Name), g.goType(f, field.Type))
+		}
+		f.printf("}\n\n")
+
+	case "variant":
+		g.declareVariant(pkg, def, name)
+
+	case "enum":
+		f.printf("// %s represents the enum %q.\n", name, pkg.qualifiedName(witName))
+		f.docs("", def.Docs)
+		lines := []string{"enum " + witName + " {"}
+		for _, c := range def.Kind.Cases {
+			lines = append(lines, "\t"+c.Name+",")
+		}
+		f.wit("", append(lines, "}")...)
+		f.printf("type %s %s\n\nconst (\n", name, tagType(len(def.Kind.Cases)))
+		for i, c := range def.Kind.Cases {
+			if i > 0 && (hasDocs(def.Kind.Cases[i-1].Docs) || hasDocs(c.Docs)) {
+				f.printf("\n")
+			}
+			f.comment("", c.Docs)
+			if i == 0 {
+				f.printf("%s%s %s = iota\n", name, goName(c.Name), name)
+			} else {
+				f.printf("%s%s\n", name, goName(c.Name))
+			}
+		}
+		f.printf(")\n\n")
+		g.stringsTable(f, name, def.Kind.Cases)
+		f.printf("// String implements [fmt.Stringer], returning the enum case name of e.\n")
+		f.printf("func (e %s) String() string {\nreturn strings%s[e]\n}\n\n", name, name)
+
+	case "flags":
+		n := len(def.Kind.Flags)
+		var typ string
+		switch {
+		case n == 0 || n > 32:
+			g.fail("flags %s: only 1 to 32 flags are supported", witName)
+			return
+		case n <= 8:
+			typ = "uint8"
+		case n <= 16:
+			typ = "uint16"
+		default:
+			typ = "uint32"
+		}
+		f.printf("// %s represents the flags %q.\n", name, pkg.qualifiedName(witName))
+		f.docs("", def.Docs)
+		lines := []string{"flags " + witName + " {"}
+		for _, flag := range def.Kind.Flags {
+			lines = append(lines, "\t"+flag.Name+",")
+		}
+		f.wit("", append(lines, "}")...)
+		f.printf("type %s %s\n\nconst (\n", name, typ)
+		for i, flag := range def.Kind.Flags {
+			if i > 0 && (hasDocs(def.Kind.Flags[i-1].Docs) || hasDocs(flag.Docs)) {
+				f.printf("\n")
+			}
+			f.comment("", flag.Docs)
+			if i == 0 {
+				f.printf("%s%s %s = 1 << iota\n", name, goName(flag.Name), name)
+			} else {
+				f.printf("%s%s\n", name, goName(flag.Name))
+			}
+		}
+		f.printf(")\n\n")
+
+	case "resource":
+		f.printf("// %s represents the %s resource %q.\n", name, direction, pkg.qualifiedName(witName))
+		f.docs("", def.Docs)
+		f.wit("", "resource "+witName)
+		f.printf("type %s cm.Resource\n\n", name)
+
+	case "type", "handle":
+		target := def.Kind.Type
+		if def.Kind.Kind == "handle" {
+			target = &wit.Type{ID: def.Kind.Handle.Resource}
+		}
+		f.printf("// %s represents the %s type alias %q.\n", name, direction, pkg.qualifiedName(witName))
+		if target.Primitive == "" && g.res.Types[target.ID].Name != nil {
+			// Alias of another named type, possibly in another package.
+			typ := g.goType(f, *target)
+			f.printf("//\n// See [%s] for more information.\n", typ)
+			f.printf("type %s = %s\n\n", name, typ)
+			return
+		}
+		f.docs("", def.Docs)
+		f.wit("", "type "+witName+" = "+g.witType(*target))
+		f.printf("type %s %s\n\n", name, g.goType(f, *target))
+
+	default:
+		// list, option, result, tuple
+		f.printf("// %s represents the %s %q.\n", name, def.Kind.Kind, pkg.qualifiedName(witName))
+		f.docs("", def.Docs)
+		f.wit("", "type "+witName+" = "+g.witAnonType(def))
+		f.printf("type %s %s\n\n", name, g.anonGoType(f, def))
+	}
+}
+
+func hasDocs(docs wit.Docs) bool {
+	return strings.TrimSpace(docs.Contents) != ""
+}
+
+// comment writes a documentation comment without a leading empty line.
+func (f *file) comment(indent string, docs wit.Docs) {
+	contents := strings.TrimSpace(docs.Contents)
+	if contents == "" {
+		return
+	}
+	for _, line := range strings.Split(contents, "\n") {
+		f.printf("%s\n", strings.TrimRight(indent+"// "+line, " \t"))
+	}
+}
+
+func (g *generator) stringsTable(f *file, name string, cases []wit.Case) {
+	f.printf("var strings%s = [%d]string{\n", name, len(cases))
+	for _, c := range cases {
+		f.printf("%q,\n", c.Name)
+	}
+	f.printf("}\n\n")
+}
+
+func (g *generator) declareVariant(pkg *goPackage, def *wit.TypeDef, name string) {
+	f := pkg.decls
+	witName := *def.Name
+	cases := def.Kind.Cases
+
+	// The shape is the largest payload, and the alignment type the payload
+	// with the largest alignment.
+	shape, align := "struct{}", "struct{}"
+	maxSize, maxAlign := 0, 0
+	for _, c := range cases {
+		if c.Type == nil {
+			continue
+		}
+		size, alignment := g.res.SizeAlign(*c.Type)
+		if size > maxSize {
+			maxSize = size
+			shape = g.goType(f, *c.Type)
+		}
+		if alignment > maxAlign {
+			maxAlign = alignment
+			align = g.goType(f, *c.Type)
+		}
+	}
+	tag := tagType(len(cases))
+
+	f.printf("// %s represents the variant %q.\n", name, pkg.qualifiedName(witName))
+	f.docs("", def.Docs)
+	lines := []string{"variant " + witName + " {"}
+	for _, c := range cases {
+		if c.Type != nil {
+			lines = append(lines, "\t"+c.Name+"("+g.witType(*c.Type)+"),")
+		} else {
+			lines = append(lines, "\t"+c.Name+",")
+		}
+	}
+	f.wit("", append(lines, "}")...)
+	f.printf("type %s cm.Variant[%s, %s, %s]\n\n", name, tag, shape, align)
+
+	for i, c := range cases {
+		caseName := goName(c.Name)
+		method := caseName
+		if method == "Tag" || method == "String" {
+			method += "_"
+		}
+		f.printf("// %s%s returns a [%s] of case %q.\n", name, caseName, name, c.Name)
+		f.docs("", c.Docs)
+		if c.Type != nil {
+			typ := g.goType(f, *c.Type)
+			f.printf("func %s%s(data %s) %s {\nreturn cm.New[%s](%d, data)\n}\n\n", name, caseName, typ, name, name, i)
+			f.printf("// %s returns a non-nil *[%s] if [%s] represents the variant case %q.\n", method, typ, name, c.Name)
+			f.printf("func (self *%s) %s() *%s {\nreturn cm.Case[%s](self, %d)\n}\n\n", name, method, typ, typ, i)
+		} else {
+			f.printf("func %s%s() %s {\nvar data struct{}\nreturn cm.New[%s](%d, data)\n}\n\n", name, caseName, name, name, i)
+			f.printf("// %s returns true if [%s] represents the variant case %q.\n", method, name, c.Name)
+			f.printf("func (self *%s) %s() bool {\nreturn self.Tag() == %d\n}\n\n", name, method, i)
+		}
+	}
+
+	g.stringsTable(f, name, cases)
+	f.printf("// String implements [fmt.Stringer], returning the variant case name of v.\n")
+	f.printf("func (v %s) String() string {\nreturn strings%s[v.Tag()]\n}\n\n", name, name)
+}
@@ -0,0 +1,339 @@
+package witbindgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+// Go types of core WebAssembly values.
+var coreGoTypes = [...]string{
+	wit.I32: "uint32",
+	wit.I64: "uint64",
+	wit.F32: "float32",
+	wit.F64: "float64",
+}
+
+// flatGoTypes returns the Go types of the flattened values of a type. They
+// are the Go equivalents of the core types returned by flatten, except that
+// pointers to string and list data have a pointer type.
+func (g *generator) flatGoTypes(f *file, t wit.Type) []string {
+	ut, def := g.res.Underlying(t)
+	if def == nil {
+		if ut.Primitive == "string" {
+			return []string{"*uint8", "uint32"}
+		}
+	} else {
+		switch def.Kind.Kind {
+		case "list":
+			return []string{"*" + g.goType(f, *def.Kind.Type), "uint32"}
+		case "record":
+			var types []string
+			for _, field := range def.Kind.Fields {
+				types = append(types, g.flatGoTypes(f, field.Type)...)
+			}
+			return types
+		case "tuple":
+			var types []string
+			for _, field := range def.Kind.Types {
+				types = append(types, g.flatGoTypes(f, field)...)
+			}
+			return types
+		}
+	}
+	var types []string
+	for _, ct := range g.res.Flatten(t) {
+		types = append(types, coreGoTypes[ct])
+	}
+	return types
+}
+
+// isBoolResult returns whether the type definition is a result without
+// payloads, which is represented as a cm.BoolResult.
+func isBoolResult(def *wit.TypeDef) bool {
+	return def.Kind.Kind == "result" && def.Kind.OK == nil && def.Kind.Err == nil
+}
+
+// lower writes statements to f that convert the Go value expr of type t to its
+// flattened values, and assign them to names using op (":=" or "=").
+func (g *generator) lower(f *file, t wit.Type, expr string, names []string, op string) {
+	if len(names) == 0 {
+		return
+	}
+	ut, def := g.res.Underlying(t)
+	var value string
+	if def == nil {
+		switch ut.Primitive {
+		case "bool":
+			value = "cm.BoolToU32(" + expr + ")"
+		case "u64", "s64":
+			value = "(uint64)(" + expr + ")"
+		case "f32":
+			value = "(float32)(" + expr + ")"
+		case "f64":
+			value = "(float64)(" + expr + ")"
+		case "string":
+			value = "cm.LowerString(" + expr + ")"
+		default:
+			value = "(uint32)(" + expr + ")"
+		}
+	} else {
+		switch {
+		case def.Kind.Kind == "list":
+			value = "cm.LowerList(" + expr + ")"
+		case def.Kind.Kind == "handle" || def.Kind.Kind == "resource":
+			value = "cm.Reinterpret[uint32](" + expr + ")"
+		case def.Kind.Kind == "enum" || def.Kind.Kind == "flags":
+			value = "(uint32)(" + expr + ")"
+		case isBoolResult(def):
+			value = "cm.BoolToU32(" + expr + ")"
+		default:
+			value = g.lowerHelper(f.pkg, t) + "(" + expr + ")"
+		}
+	}
+	f.printf("%s %s %s\n", strings.Join(names, ", "), op, value)
+}
+
+// lowerHelper returns the name of a function that lowers values of the given
+// record, tuple or variant type, generating it if needed.
+func (g *generator) lowerHelper(pkg *goPackage, t wit.Type) string {
+	h := pkg.abi.sub()
+	typ := g.goType(h, t)
+	name := "lower_" + mangle(typ)
+	if pkg.helpers[name] {
+		return name
+	}
+	pkg.helpers[name] = true
+
+	_, def := g.res.Underlying(t)
+	core := g.res.Flatten(t)
+	types := g.flatGoTypes(h, t)
+	flat := flatNames("f", len(types))
+	var results []string
+	for i, typ := range types {
+		results = append(results, flat[i]+" "+typ)
+	}
+	h.printf("func %s(v %s) (%s) {\n", name, typ, strings.Join(results, ", "))
+	switch k := def.Kind; k.Kind {
+	case "record":
+		i := 0
+		for _, field := range k.Fields {
+			n := len(g.res.Flatten(field.Type))
+			g.lower(h, field.Type, "v."+goName(field.Name), flat[i:i+n], "=")
+			i += n
+		}
+	case "tuple":
+		i := 0
+		for index, field := range k.Types {
+			n := len(g.res.Flatten(field))
+			g.lower(h, field, g.tupleField(h, def, index), flat[i:i+n], "=")
+			i += n
+		}
+	case "variant":
+		h.printf("f0 = (uint32)(v.Tag())\nswitch f0 {\n")
+		for i, c := range k.Cases {
+			if c.Type == nil {
+				continue
+			}
+			h.printf("case %d: // %s\n", i, c.Name)
+			expr := fmt.Sprintf("*cm.Case[%s](&v, %d)", g.goType(h, *c.Type), i)
+			g.lowerCase(h, *c.Type, expr, flat[1:], core[1:])
+		}
+		h.printf("}\n")
+	case "option":
+		h.printf("some := v.Some()\nif some != nil {\nf0 = 1\n")
+		g.lowerCase(h, *k.Type, "*some", flat[1:], core[1:])
+		h.printf("}\n")
+	case "result":
+		if k.OK != nil {
+			h.printf("if v.IsOK() {\n")
+			g.lowerCase(h, *k.OK, "*v.OK()", flat[1:], core[1:])
+			h.printf("} else {\nf0 = 1\n")
+		} else {
+			h.printf("if v.IsErr() {\nf0 = 1\n")
+		}
+		if k.Err != nil {
+			g.lowerCase(h, *k.Err, "*v.Err()", flat[1:], core[1:])
+		}
+		h.printf("}\n")
+	}
+	h.printf("return\n}\n\n")
+	pkg.abi.buf.Write(h.buf.Bytes())
+	return name
+}
+
+// tupleField returns the expression for a tuple field of v: tuples of a
+// single type are arrays, others are cm.Tuple types.
+func (g *generator) tupleField(f *file, def *wit.TypeDef, index int) string {
+	if strings.HasPrefix(g.anonGoType(f, def), "[") {
+		return fmt.Sprintf("v[%d]", index)
+	}
+	return fmt.Sprintf("v.F%d", index)
+}
+
+// lowerCase lowers the payload of a variant case, and assigns the values to
+// the joined payload values of the variant.
+func (g *generator) lowerCase(h *file, t wit.Type, expr string, targets []string, joined []wit.CoreType) {
+	core := g.res.Flatten(t)
+	types := g.flatGoTypes(h, t)
+	var tmps []string
+	for i := range core {
+		tmps = append(tmps, fmt.Sprintf("v%d", i+1))
+	}
+	g.lower(h, t, expr, tmps, ":=")
+	for i, tmp := range tmps {
+		h.printf("%s = %s\n", targets[i], coerceLower(tmp, core[i], types[i], joined[i]))
+	}
+}
+
+// coerceLower converts a flattened value to the joined core type of a variant
+// payload.
+func coerceLower(value string, from wit.CoreType, typ string, to wit.CoreType) string {
+	pointer := strings.HasPrefix(typ, "*")
+	switch {
+	case pointer && to == wit.I32:
+		return "cm.PointerToU32(" + value + ")"
+	case pointer:
+		return "cm.PointerToU64(" + value + ")"
+	case from == wit.F32 && to == wit.I32:
+		return "cm.F32ToU32(" + value + ")"
+	case from == wit.F32 && to == wit.I64:
+		return "cm.F32ToU64(" + value + ")"
+	case from == wit.F64 && to == wit.I64:
+		return "cm.F64ToU64(" + value + ")"
+	}
+	return "(" + coreGoTypes[to] + ")(" + value + ")"
+}
+
+// coerceLift converts a joined variant payload value back to the flattened
+// value of the case payload.
+func coerceLift(value string, from wit.CoreType, typ string, to wit.CoreType) string {
+	if strings.HasPrefix(typ, "*") {
+		if from == wit.I32 {
+			return "cm.U32ToPointer[" + typ[1:] + "](" + value + ")"
+		}
+		return "cm.U64ToPointer[" + typ[1:] + "](" + value + ")"
+	}
+	switch {
+	case from == to:
+		return value
+	case from == wit.I32 && to == wit.F32:
+		return "cm.U32ToF32(" + value + ")"
+	case from == wit.I64 && to == wit.F32:
+		return "cm.U64ToF32(" + value + ")"
+	case from == wit.I64 && to == wit.F64:
+		return "cm.U64ToF64(" + value + ")"
+	}
+	return "(" + typ + ")(" + value + ")"
+}
+
+// lift returns an expression that converts the flattened values to a Go value
+// of type t.
+func (g *generator) lift(f *file, t wit.Type, values []string) string {
+	typ := g.goType(f, t)
+	ut, def := g.res.Underlying(t)
+	if def == nil {
+		switch ut.Primitive {
+		case "bool":
+			if typ == "bool" {
+				return "cm.U32ToBool(" + values[0] + ")"
+			}
+			return "(" + typ + ")(cm.U32ToBool(" + values[0] + "))"
+		case "string":
+			return "cm.LiftString[" + typ + "](" + values[0] + ", " + values[1] + ")"
+		}
+		return "(" + typ + ")(" + values[0] + ")"
+	}
+	switch {
+	case def.Kind.Kind == "list":
+		return "cm.LiftList[" + typ + "](" + values[0] + ", " + values[1] + ")"
+	case def.Kind.Kind == "handle" || def.Kind.Kind == "resource":
+		return "cm.Reinterpret[" + typ + "](" + values[0] + ")"
+	case def.Kind.Kind == "enum" || def.Kind.Kind == "flags":
+		return "(" + typ + ")(" + values[0] + ")"
+	case isBoolResult(def):
+		return "(" + typ + ")(cm.U32ToBool(" + values[0] + "))"
+	case len(values) == 0:
+		return typ + "{}"
+	}
+	return g.liftHelper(f.pkg, t) + "(" + strings.Join(values, ", ") + ")"
+}
+
+// liftHelper returns the name of a function that lifts values of the given
+// record, tuple or variant type, generating it if needed.
+func (g *generator) liftHelper(pkg *goPackage, t wit.Type) string {
+	h := pkg.abi.sub()
+	typ := g.goType(h, t)
+	name := "lift_" + mangle(typ)
+	if pkg.helpers[name] {
+		return name
+	}
+	pkg.helpers[name] = true
+
+	_, def := g.res.Underlying(t)
+	core := g.res.Flatten(t)
+	types := g.flatGoTypes(h, t)
+	flat := flatNames("f", len(types))
+	var params []string
+	for i, typ := range types {
+		params = append(params, flat[i]+" "+typ)
+	}
+	h.printf("func %s(%s) (v %s) {\n", name, strings.Join(params, ", "), typ)
+	switch k := def.Kind; k.Kind {
+	case "record":
+		i := 0
+		for _, field := range k.Fields {
+			n := len(g.res.Flatten(field.Type))
+			h.printf("v.%s = %s\n", goName(field.Name), g.lift(h, field.Type, flat[i:i+n]))
+			i += n
+		}
+		h.printf("return\n")
+	case "tuple":
+		i := 0
+		for index, field := range k.Types {
+			n := len(g.res.Flatten(field))
+			h.printf("%s = %s\n", g.tupleField(h, def, index), g.lift(h, field, flat[i:i+n]))
+			i += n
+		}
+		h.printf("return\n")
+	case "variant":
+		h.printf("switch f0 {\n")
+		for i, c := range k.Cases {
+			h.printf("case %d:\n", i)
+			if c.Type == nil {
+				h.printf("return cm.New[%s](%d, struct{}{})\n", typ, i)
+			} else {
+				h.printf("return cm.New[%s](%d, %s)\n", typ, i, g.liftCase(h, *c.Type, flat[1:], core[1:]))
+			}
+		}
+		h.printf("}\nreturn\n")
+	case "option":
+		h.printf("if f0 == 0 {\nreturn\n}\n")
+		h.printf("return (%s)(cm.Some[%s](%s))\n", typ, g.goType(h, *k.Type), g.liftCase(h, *k.Type, flat[1:], core[1:]))
+	case "result":
+		ok, err := "struct{}{}", "struct{}{}"
+		if k.OK != nil {
+			ok = g.liftCase(h, *k.OK, flat[1:], core[1:])
+		}
+		if k.Err != nil {
+			err = g.liftCase(h, *k.Err, flat[1:], core[1:])
+		}
+		h.printf("if f0 == 0 {\nreturn cm.OK[%s](%s)\n}\nreturn cm.Err[%s](%s)\n", typ, ok, typ, err)
+	}
+	h.printf("}\n\n")
+	pkg.abi.buf.Write(h.buf.Bytes())
+	return name
+}
+
+// liftCase returns an expression that lifts the payload of a variant case from
+// the joined payload values of the variant.
+func (g *generator) liftCase(h *file, t wit.Type, joinedValues []string, joined []wit.CoreType) string {
+	core := g.res.Flatten(t)
+	types := g.flatGoTypes(h, t)
+	var values []string
+	for i := range core {
+		values = append(values, coerceLift(joinedValues[i], joined[i], types[i], core[i]))
+	}
+	return g.lift(h, t, values)
+}
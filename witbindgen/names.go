@@ -0,0 +1,113 @@
+package witbindgen
+
+import (
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// Words that are written in all caps (or mixed case) in Go identifiers.
+var initialisms = map[string]string{
+	"api":   "API",
+	"cpu":   "CPU",
+	"dns":   "DNS",
+	"fifo":  "FIFO",
+	"http":  "HTTP",
+	"https": "HTTPS",
+	"id":    "ID",
+	"io":    "IO",
+	"ip":    "IP",
+	"ipv4":  "IPv4",
+	"ipv6":  "IPv6",
+	"json":  "JSON",
+	"os":    "OS",
+	"tcp":   "TCP",
+	"tls":   "TLS",
+	"ttl":   "TTL",
+	"tty":   "TTY",
+	"udp":   "UDP",
+	"uri":   "URI",
+	"url":   "URL",
+	"utf8":  "UTF8",
+	"uuid":  "UUID",
+	"xml":   "XML",
+}
+
+// Identifiers that can't be used as parameter names, because they are Go
+// keywords, predeclared identifiers, or names used in the generated code.
+var reservedNames = map[string]bool{
+	"any": true, "append": true, "bool": true, "byte": true, "cap": true,
+	"clear": true, "close": true, "complex": true, "copy": true, "delete": true,
+	"error": true, "false": true, "float32": true, "float64": true, "imag": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"iota": true, "len": true, "make": true, "max": true, "min": true,
+	"new": true, "nil": true, "panic": true, "print": true, "println": true,
+	"real": true, "recover": true, "rune": true, "string": true, "true": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true,
+	"cm":      true, "params": true, "result": true, "ret": true, "self": true,
+}
+
+// goName converts a WIT identifier in kebab-case to an exported Go
+// identifier: "get-random-bytes" becomes "GetRandomBytes".
+func goName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(name, "-") {
+		if word == "" {
+			continue
+		}
+		if s, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(s)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// paramName converts a WIT identifier to an unexported Go identifier, for use
+// as a parameter or variable name: "ip-address" becomes "ipAddress".
+func paramName(name string) string {
+	first, rest, _ := strings.Cut(strings.TrimLeft(name, "-"), "-")
+	s := strings.ToLower(first) + goName(rest)
+	if token.IsKeyword(s) || reservedNames[s] {
+		s += "_"
+	}
+	return s
+}
+
+// packageName returns a valid Go package name for a WIT interface or world
+// name: "wall-clock" becomes "wallclock".
+func packageName(name string) string {
+	s := strings.ToLower(strings.ReplaceAll(name, "-", ""))
+	if token.IsKeyword(s) {
+		s += "_"
+	}
+	return s
+}
+
+// mangle turns a Go type expression into something that can be used as part
+// of an identifier: "cm.Option[wallclock.DateTime]" becomes
+// "OptionWallclockDateTime".
+func mangle(typ string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range strings.ReplaceAll(typ, "cm.", "") {
+		switch {
+		case r == '*':
+			b.WriteString("Ptr")
+			upper = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
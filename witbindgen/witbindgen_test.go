@@ -0,0 +1,203 @@
+package witbindgen
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinygo-org/tinygo/wit"
+)
+
+var flagUpdate = flag.Bool("update", false, "update tests based on test output")
+
+const packageRoot = "example.com/bindings"
+
+func generateDemo(t *testing.T) []File {
+	t.Helper()
+	f, err := os.Open("testdata/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	res, err := wit.Read(f)
+	if err != nil {
+		t.Fatal("could not read resolve:", err)
+	}
+	world, err := res.World("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(res, world, Config{PackageRoot: packageRoot, CMPackage: "internal/cm"})
+	if err != nil {
+		t.Fatal("could not generate bindings:", err)
+	}
+	return files
+}
+
+func TestWorld(t *testing.T) {
+	f, err := os.Open("testdata/demo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	res, err := wit.Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "demo", "example:demo/demo"} {
+		world, err := res.World(name)
+		if err != nil {
+			t.Errorf("World(%q): %v", name, err)
+		} else if world.Name != "demo" {
+			t.Errorf("World(%q): got world %s", name, world.Name)
+		}
+	}
+	if _, err := res.World("other"); err == nil {
+		t.Error("World(\"other\"): expected an error")
+	}
+}
+
+// TestGenerate compares the generated code with the expected output in
+// testdata/demo.txt. Run with -update to update it.
+func TestGenerate(t *testing.T) {
+	files := generateDemo(t)
+	var out bytes.Buffer
+	for _, file := range files {
+		fmt.Fprintf(&out, "-- %s --\n", file.Path)
+		out.Write(file.Content)
+	}
+	if *flagUpdate {
+		if err := os.WriteFile("testdata/demo.txt", out.Bytes(), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile("testdata/demo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Error("generated code differs from testdata/demo.txt (run with -update to update)")
+	}
+}
+
+// TestLoad checks that parsing testdata/demo.wit results in the same bindings
+// as the JSON in testdata/demo.json.
+func TestLoad(t *testing.T) {
+	res, err := wit.Load("testdata/demo.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	world, err := res.World("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(res, world, Config{PackageRoot: packageRoot, CMPackage: "internal/cm"})
+	if err != nil {
+		t.Fatal("could not generate bindings:", err)
+	}
+	expected := generateDemo(t)
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(files))
+	}
+	for i, file := range files {
+		if file.Path != expected[i].Path {
+			t.Errorf("expected file %s, got %s", expected[i].Path, file.Path)
+		} else if !bytes.Equal(file.Content, expected[i].Content) {
+			t.Errorf("%s differs from the bindings generated from JSON", file.Path)
+		}
+	}
+}
+
+// TestTypeCheck checks that the generated code compiles, against the cm
+// package in src/internal/cm.
+func TestTypeCheck(t *testing.T) {
+	files := generateDemo(t)
+	fset := token.NewFileSet()
+	sources := make(map[string][]*ast.File)
+	for _, file := range files {
+		if !strings.HasSuffix(file.Path, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file.Path, file.Content, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		importPath := path.Join(packageRoot, path.Dir(file.Path))
+		sources[importPath] = append(sources[importPath], f)
+	}
+
+	cmFiles := parseCM(t, fset)
+	std := importer.ForCompiler(fset, "source", nil)
+	checked := make(map[string]*types.Package)
+	var imp importerFunc
+	check := func(importPath string, files []*ast.File) (*types.Package, error) {
+		conf := types.Config{Importer: imp}
+		return conf.Check(importPath, fset, files, nil)
+	}
+	imp = func(importPath string) (*types.Package, error) {
+		if pkg := checked[importPath]; pkg != nil {
+			return pkg, nil
+		}
+		var pkg *types.Package
+		var err error
+		switch {
+		case importPath == "internal/cm":
+			pkg, err = check(importPath, cmFiles)
+		case sources[importPath] != nil:
+			pkg, err = check(importPath, sources[importPath])
+		default:
+			return std.Import(importPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		checked[importPath] = pkg
+		return pkg, nil
+	}
+	for importPath := range sources {
+		if _, err := imp(importPath); err != nil {
+			t.Errorf("%s: %v", importPath, err)
+		}
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// parseCM parses the files of src/internal/cm for the current Go version.
+func parseCM(t *testing.T, fset *token.FileSet) []*ast.File {
+	dir := filepath.Join("..", "src", "internal", "cm")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	return files
+}